@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"bsync-agent/internal/handlers"
+)
+
+// controlClient talks to the local control API of a running agent
+type controlClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// newControlClient locates the control API and token from the same configuration as the agent
+func newControlClient() (*controlClient, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	network, address, tokenFile := handlers.ControlEndpoint(config.Control, config.Syncthing.DataDir)
+	token, err := handlers.ReadControlToken(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read control token (is the agent running and is %s readable?): %w", tokenFile, err)
+	}
+
+	transport := &http.Transport{}
+	baseURL := "http://" + address
+	if network == "unix" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", address)
+		}
+		baseURL = "http://bsync-agent" // Host is ignored when dialing the socket
+	}
+
+	return &controlClient{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// call sends a request to the control API and decodes the JSON response into out
+func (c *controlClient) call(method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach the agent control API (is the agent running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 && len(body) > 0 && body[0] != '{' {
+		return fmt.Errorf("agent returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid response from agent: %w", err)
+	}
+	return nil
+}
+
+// parseCommandFlags parses the flags shared by all CLI subcommands
+func parseCommandFlags(name string, args []string) (*flag.FlagSet, bool) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print raw JSON output")
+	fs.Parse(args)
+	return fs, *jsonOutput
+}
+
+// mustControlClient creates a control client or exits with an error
+func mustControlClient() *controlClient {
+	client, err := newControlClient()
+	if err != nil {
+		exitWithError(err)
+	}
+	return client
+}
+
+// exitWithError prints an error and exits with status 1
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// formatBytes formats a byte count for humans
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func handleStatusCommand(args []string) {
+	_, jsonOutput := parseCommandFlags("status", args)
+
+	var response handlers.ControlStatusResponse
+	if err := mustControlClient().call("GET", "/api/v1/status", &response); err != nil {
+		exitWithError(err)
+	}
+	if jsonOutput {
+		printJSON(response)
+		return
+	}
+
+	status := response.Status
+	syncthing, _ := status["syncthing"].(map[string]interface{})
+	websocket, _ := status["websocket"].(map[string]interface{})
+	folders, _ := status["folders"].(map[string]interface{})
+	connections, _ := status["connections"].(map[string]interface{})
+
+	syncing, withErrors := 0, 0
+	for _, f := range folders {
+		folder, _ := f.(map[string]interface{})
+		if state, _ := folder["state"].(string); state == "syncing" || state == "scanning" {
+			syncing++
+		}
+		if errs, _ := folder["errors"].([]interface{}); len(errs) > 0 {
+			withErrors++
+		}
+	}
+	connected := 0
+	for _, c := range connections {
+		if conn, _ := c.(map[string]interface{}); conn["connected"] == true {
+			connected++
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Agent ID:\t%v\n", status["agent_id"])
+	fmt.Fprintf(w, "Device ID:\t%v\n", status["device_id"])
+	fmt.Fprintf(w, "Running:\t%v\n", status["running"])
+	fmt.Fprintf(w, "Sync engine running:\t%v\n", syncthing["running"])
+	fmt.Fprintf(w, "Server connected:\t%v\n", websocket["connected"])
+	fmt.Fprintf(w, "Folders:\t%d (%d syncing, %d with errors)\n", len(folders), syncing, withErrors)
+	fmt.Fprintf(w, "Devices:\t%d of %d connected\n", connected, len(connections))
+	w.Flush()
+}
+
+func handleFoldersCommand(args []string) {
+	_, jsonOutput := parseCommandFlags("folders", args)
+
+	var response handlers.FolderStatusResponse
+	if err := mustControlClient().call("GET", "/api/v1/folders", &response); err != nil {
+		exitWithError(err)
+	}
+	if jsonOutput {
+		printJSON(response)
+		return
+	}
+
+	if len(response.Folders) == 0 {
+		fmt.Println("No folders configured")
+		return
+	}
+
+	ids := make([]string, 0, len(response.Folders))
+	for id := range response.Folders {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLABEL\tTYPE\tSTATE\tIN SYNC\tNEED\tERRORS\tPATH")
+	for _, id := range ids {
+		folder := response.Folders[id]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d files (%s)\t%d files (%s)\t%d\t%s\n",
+			folder.ID, folder.Label, folder.Type, folder.State,
+			folder.InSyncFiles, formatBytes(folder.InSyncBytes),
+			folder.NeedFiles, formatBytes(folder.NeedBytes),
+			len(folder.Errors), folder.Path)
+	}
+	w.Flush()
+}
+
+func handleDevicesCommand(args []string) {
+	_, jsonOutput := parseCommandFlags("devices", args)
+
+	var response handlers.ListDevicesResponse
+	if err := mustControlClient().call("GET", "/api/v1/devices", &response); err != nil {
+		exitWithError(err)
+	}
+	if jsonOutput {
+		printJSON(response)
+		return
+	}
+
+	if len(response.Devices) == 0 {
+		fmt.Println("No devices configured")
+		return
+	}
+
+	ids := make([]string, 0, len(response.Devices))
+	for id := range response.Devices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DEVICE ID\tCONNECTED\tPAUSED\tADDRESS\tSENT\tRECEIVED")
+	for _, id := range ids {
+		device := response.Devices[id]
+		fmt.Fprintf(w, "%s\t%v\t%v\t%s\t%s\t%s\n",
+			id, device.Connected, device.Paused, device.Address,
+			formatBytes(device.BytesSent), formatBytes(device.BytesRecv))
+	}
+	w.Flush()
+}
+
+func handleScanCommand(args []string) {
+	fs, jsonOutput := parseCommandFlags("scan", args)
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: BSync scan [--json] <folder-id>")
+		os.Exit(1)
+	}
+
+	folderID := fs.Arg(0)
+
+	var response handlers.ScanFolderResponse
+	if err := mustControlClient().call("POST", "/api/v1/folders/"+url.PathEscape(folderID)+"/scan", &response); err != nil {
+		exitWithError(err)
+	}
+	if jsonOutput {
+		printJSON(response)
+	} else {
+		fmt.Println(response.Message)
+	}
+	if !response.Success {
+		os.Exit(1)
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"bsync-agent/internal/agent"
 	"bsync-agent/internal/embedded"
+	"bsync-agent/internal/handlers"
 	"gopkg.in/yaml.v2"
 )
 
@@ -23,13 +24,14 @@ const (
 )
 
 var (
-	configFile = flag.String("config", "", "Configuration file path")
-	dataDir    = flag.String("data", "", "Data directory (overrides config)")
-	serverURL  = flag.String("server", "", "Server URL (overrides config)")
-	agentID    = flag.String("agent-id", "", "Agent ID (overrides config)")
-	logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
-	version    = flag.Bool("version", false, "Show version information")
-	daemon     = flag.Bool("daemon", false, "Run as daemon")
+	configFile      = flag.String("config", "", "Configuration file path")
+	dataDir         = flag.String("data", "", "Data directory (overrides config)")
+	serverURL       = flag.String("server", "", "Server URL (overrides config)")
+	agentID         = flag.String("agent-id", "", "Agent ID (overrides config)")
+	enrollmentToken = flag.String("enrollment-token", "", "Enrollment token for the first connection (overrides config)")
+	logLevel        = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	version         = flag.Bool("version", false, "Show version information")
+	daemon          = flag.Bool("daemon", false, "Run as daemon")
 )
 
 func main() {
@@ -81,6 +83,26 @@ func main() {
 		log.Fatalf("Failed to start integrated agent: %v", err)
 	}
 
+	// Start local control API for the CLI (BSync status|folders|devices|scan)
+	if config.Control.Enabled {
+		controlServer := handlers.NewControlServer(integratedAgent, config.Control, config.Syncthing.DataDir)
+		if err := controlServer.Start(); err != nil {
+			log.Printf("⚠️ Control API disabled: %v", err)
+		} else {
+			defer controlServer.Stop()
+		}
+	}
+
+	// Serve Prometheus metrics, independent of the server connection
+	if config.Monitoring.MetricsEndpoint != "" {
+		metricsServer := handlers.NewMetricsServer(integratedAgent, config.Monitoring)
+		if err := metricsServer.Start(); err != nil {
+			log.Printf("⚠️ Metrics endpoint disabled: %v", err)
+		} else {
+			defer metricsServer.Stop()
+		}
+	}
+
 	// Run as daemon or foreground
 	if *daemon {
 		log.Println("Running as daemon...")
@@ -109,6 +131,9 @@ func loadConfig() (*agent.AgentConfig, error) {
 			Enabled:        true,
 			ReportInterval: 60 * time.Second,
 		},
+		Control: agent.ControlConfig{
+			Enabled: true,
+		},
 		LogLevel: "info",
 	}
 
@@ -129,6 +154,9 @@ func loadConfig() (*agent.AgentConfig, error) {
 	if *agentID != "" {
 		config.AgentID = *agentID
 	}
+	if *enrollmentToken != "" {
+		config.EnrollmentToken = *enrollmentToken
+	}
 	if *logLevel != "" {
 		config.LogLevel = *logLevel
 	}
@@ -234,35 +262,6 @@ func handleCLICommand(args []string) {
 	}
 }
 
-func handleStatusCommand(args []string) {
-	fmt.Println("Status command not yet implemented")
-	fmt.Println("This will show the current status of the integrated agent")
-	// TODO: Connect to running agent via local API and get status
-}
-
-func handleFoldersCommand(args []string) {
-	fmt.Println("Folders command not yet implemented")
-	fmt.Println("This will show folder information")
-	// TODO: Connect to running agent via local API and get folder status
-}
-
-func handleDevicesCommand(args []string) {
-	fmt.Println("Devices command not yet implemented")
-	fmt.Println("This will show device connection information")
-	// TODO: Connect to running agent via local API and get device connections
-}
-
-func handleScanCommand(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Usage: BSync scan <folder-id>")
-		os.Exit(1)
-	}
-	
-	folderID := args[0]
-	fmt.Printf("Scan command for folder '%s' not yet implemented\n", folderID)
-	// TODO: Connect to running agent via local API and trigger scan
-}
-
 func showUsage() {
 	fmt.Printf("BSync Integrated v%s\n\n", Version)
 	fmt.Println("USAGE:")
@@ -274,25 +273,27 @@ func showUsage() {
 	fmt.Println("  --data DIR           Data directory (overrides config)")
 	fmt.Println("  --server URL         Server URL (overrides config)")
 	fmt.Println("  --agent-id ID        Agent ID (overrides config)")
+	fmt.Println("  --enrollment-token T Enrollment token for the first connection")
 	fmt.Println("  --log-level LEVEL    Log level: debug, info, warn, error")
 	fmt.Println("  --daemon             Run as daemon")
 	fmt.Println("  --version            Show version information")
 	fmt.Println("  --help               Show this help")
 	fmt.Println("")
-	fmt.Println("COMMANDS:")
-	fmt.Println("  status               Show agent and sync status")
-	fmt.Println("  folders              List and manage folders")
-	fmt.Println("  devices              Show device connections")
-	fmt.Println("  scan FOLDER-ID       Trigger folder scan")
+	fmt.Println("COMMANDS (talk to the running agent via its local control API):")
+	fmt.Println("  status [--json]      Show agent and sync status")
+	fmt.Println("  folders [--json]     List folders and their sync state")
+	fmt.Println("  devices [--json]     Show device connections")
+	fmt.Println("  scan [--json] ID     Trigger folder scan")
 	fmt.Println("  version              Show version information")
 	fmt.Println("  help                 Show this help")
 	fmt.Println("")
 	fmt.Println("EXAMPLES:")
 	fmt.Println("  BSync --config /etc/bsync/agent.yaml")
 	fmt.Println("  BSync --data /var/lib/bsync --server ws://sync.company.com")
-	fmt.Println("  BSync status")
-	fmt.Println("  BSync folders")
-	fmt.Println("  BSync scan documents")
+	fmt.Println("  BSync --config /etc/bsync/agent.yaml --enrollment-token 3f9c...")
+	fmt.Println("  BSync --config /etc/bsync/agent.yaml status")
+	fmt.Println("  BSync folders --json")
+	fmt.Println("  BSync scan job-42")
 }
 
 // expandEnvPath expands environment variables in path
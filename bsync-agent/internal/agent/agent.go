@@ -2,11 +2,11 @@ package agent
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -18,6 +18,7 @@ import (
 
 	"bsync-agent/internal/embedded"
 	"bsync-agent/internal/integration"
+	"bsync-agent/pkg/protocol"
 	"bsync-agent/pkg/types"
 	"github.com/gorilla/websocket"
 )
@@ -38,6 +39,11 @@ type IntegratedAgent struct {
 	wsURL         string
 	wsMutex       sync.Mutex
 	reconnectMutex sync.Mutex  // Prevents concurrent reconnection attempts
+	credentialFile string      // Per-agent credential issued by the server at enrollment
+	
+	// Message protocol, negotiated on register
+	protocolVersion int
+	protocolMutex   sync.Mutex
 	
 	// Agent state
 	agentID  string
@@ -53,6 +59,7 @@ type IntegratedAgent struct {
 	pendingEventsFile string
 	pendingEventsMutex sync.Mutex
 	pendingEventsBuffer []PendingEvent
+	pendingEventsOnDisk int // events in pendingEventsFile as of the last load or save
 	lastPendingEventsSave time.Time
 	
 	// Progress tracking
@@ -68,6 +75,47 @@ type IntegratedAgent struct {
 	// Session tracking
 	activeSessions    map[string]*integration.SyncSessionStats // job_id -> current session
 	sessionMutex      sync.RWMutex
+
+	// Maintenance windows
+	windowState       *maintenanceWindowState
+	windowStateFile   string
+	windowMutex       sync.Mutex
+
+	// Bandwidth limits
+	bandwidthState      *bandwidthState
+	bandwidthStateFile  string
+	bandwidthMutex      sync.Mutex
+	appliedGlobalLimit  *rateLimits
+	appliedDeviceLimits map[string]rateLimits // device_id -> limits currently set in the engine
+
+	// Disk space guard of destination folders
+	diskGuardState     *diskGuardState
+	diskGuardStateFile string
+	diskGuardMutex     sync.Mutex
+
+	// Large deletion guard of source folders
+	deletionGuardState     *deletionGuardState
+	deletionGuardStateFile string
+	deletionCounters       map[string]*deletionCounter // job_id -> deletions in the current window
+	deletionGuardMutex     sync.Mutex
+
+	// Pre-scan and post-session hooks
+	hookState          *hookState
+	hookStateFile      string
+	lastPreScan        map[string]time.Time              // job_id -> last pre-scan hook run
+	hookRunning        map[string]bool                   // job_id -> pre-scan hook running
+	pendingHookResults map[string][]*protocol.HookResult // job_id -> pre-scan results waiting for the session
+	hooksMutex         sync.Mutex
+
+	// Conflict policies of sendreceive folders
+	conflictPolicyState     *conflictPolicyState
+	conflictPolicyStateFile string
+	conflictPolicyMutex     sync.Mutex
+	conflictQueue           chan integration.ConflictEvent // conflict copies waiting for the resolver
+
+	// Host metrics
+	lastCPUTimes     cpuTimes // previous reading, CPU usage is reported for the interval in between
+	hostMetricsMutex sync.Mutex
 }
 
 // FolderProgress tracks progress for folder operations
@@ -87,12 +135,28 @@ type AgentConfig struct {
 	AgentIDSuffix string `yaml:"agent_id_suffix"`
 	ServerURL     string `yaml:"server_url"`
 	
+	// Enrollment: the token is exchanged on first connect for a credential stored in CredentialFile
+	EnrollmentToken string `yaml:"enrollment_token"`
+	CredentialFile  string `yaml:"credential_file"` // default <data_dir>/agent_credential_<agent_id>
+	
 	// Syncthing configuration
 	Syncthing embedded.SyncthingConfig `yaml:"syncthing"`
 	
 	// Monitoring configuration
 	Monitoring MonitoringConfig `yaml:"monitoring"`
 	
+	// Local control API used by the CLI (status, folders, devices, scan)
+	Control ControlConfig `yaml:"control"`
+	
+	// TLS settings for the server connection (server CA pinning and client certificate)
+	TLS AgentTLSConfig `yaml:"tls"`
+	
+	// Commands run around syncs as defined by the jobs (pre-scan, post-session)
+	Hooks HooksConfig `yaml:"hooks"`
+	
+	// Versioning types the jobs may use on this agent (external commands)
+	Versioning VersioningConfig `yaml:"versioning"`
+	
 	// Logging
 	LogLevel   string `yaml:"log_level"`
 	EventDebug bool   `yaml:"event_debug"`
@@ -102,11 +166,31 @@ type AgentConfig struct {
 type MonitoringConfig struct {
 	Enabled               bool          `yaml:"enabled"`
 	ReportInterval        time.Duration `yaml:"report_interval"`
-	MetricsEndpoint       string        `yaml:"metrics_endpoint"`
+	MetricsEndpoint       string        `yaml:"metrics_endpoint"` // host:port serving Prometheus /metrics, empty to disable
+	MetricsToken          string        `yaml:"metrics_token"`    // optional bearer token required by /metrics
 	AutoResyncEnabled     bool          `yaml:"auto_resync_enabled"`
 	AutoResyncInterval    time.Duration `yaml:"auto_resync_interval"`
 }
 
+// ControlConfig holds the local control API configuration
+type ControlConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Socket    string `yaml:"socket"`     // Unix socket path (default: <data_dir>/bsync.sock, not on Windows)
+	Address   string `yaml:"address"`    // Loopback TCP address, used instead of the socket (default on Windows: 127.0.0.1:22102)
+	TokenFile string `yaml:"token_file"` // Bearer token file, created on first start (default: <data_dir>/control.token)
+}
+
+// AgentTLSConfig holds the server CA and the client certificate used for mutual TLS.
+// Empty paths default to files in the data directory, written when the server issues a certificate.
+type AgentTLSConfig struct {
+	CAFile   string `yaml:"ca_file"`   // CA that signed the server certificate (default: <data_dir>/ca.crt)
+	CertFile string `yaml:"cert_file"` // Client certificate (default: <data_dir>/agent.crt)
+	KeyFile  string `yaml:"key_file"`  // Client private key (default: <data_dir>/agent.key)
+
+	// InsecureSkipVerify accepts any server certificate while no CA file exists (testing only)
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
 // PendingEvent represents an event that needs to be sent to server
 type PendingEvent struct {
 	ID        string                 `json:"id"`
@@ -117,12 +201,20 @@ type PendingEvent struct {
 
 // SystemInfo represents system information
 type SystemInfo struct {
-	Hostname     string  `json:"hostname"`
-	OS           string  `json:"os"`
-	CPUUsage     float64 `json:"cpu_usage"`
-	MemoryUsage  int64   `json:"memory_usage"`
-	DiskUsage    int64   `json:"disk_usage"`
-	Uptime       int64   `json:"uptime"`
+	Hostname      string     `json:"hostname"`
+	OS            string     `json:"os"`
+	Architecture  string     `json:"architecture"`
+	CPUCount      int        `json:"cpu_count"`
+	CPUUsage      float64    `json:"cpu_usage"`              // percent since the previous report
+	LoadAverage   []float64  `json:"load_average,omitempty"` // 1, 5 and 15 minutes
+	MemoryUsage   int64      `json:"memory_usage"`           // bytes in use (total minus available)
+	MemoryTotal   int64      `json:"memory_total"`
+	MemoryPercent float64    `json:"memory_percent"`
+	DiskUsage     int64      `json:"disk_usage"` // bytes used on the data directory filesystem
+	DiskTotal     int64      `json:"disk_total"`
+	DiskFree      int64      `json:"disk_free"`
+	Uptime        int64      `json:"uptime"`          // host uptime in seconds
+	Disks         []DiskInfo `json:"disks,omitempty"` // filesystem of every synced folder
 }
 
 // generateAgentID generates an agent ID using hostname with optional prefix and suffix
@@ -195,6 +287,45 @@ func NewIntegratedAgent(config *AgentConfig) (*IntegratedAgent, error) {
 		periodicTimers: make(map[string]*time.Timer),
 		autoResyncTimers: make(map[string]*time.Timer),
 		activeSessions: make(map[string]*integration.SyncSessionStats),
+		windowState: &maintenanceWindowState{
+			Windows:        make(map[string][]*MaintenanceWindow),
+			WindowPaused:   make(map[string]bool),
+			ManuallyPaused: make(map[string]bool),
+		},
+		windowStateFile: fmt.Sprintf("%s/maintenance_windows_%s.json", config.Syncthing.DataDir, config.AgentID),
+		bandwidthState: &bandwidthState{
+			JobLimits: make(map[string]*jobBandwidth),
+		},
+		bandwidthStateFile:  fmt.Sprintf("%s/bandwidth_limits_%s.json", config.Syncthing.DataDir, config.AgentID),
+		appliedDeviceLimits: make(map[string]rateLimits),
+		diskGuardState: &diskGuardState{
+			Jobs:   make(map[string]*jobDiskGuard),
+			Paused: make(map[string]*diskGuardPause),
+		},
+		diskGuardStateFile: fmt.Sprintf("%s/disk_guard_%s.json", config.Syncthing.DataDir, config.AgentID),
+		deletionGuardState: &deletionGuardState{
+			Jobs:  make(map[string]*DeletionGuardPolicy),
+			Holds: make(map[string]*deletionHold),
+		},
+		deletionGuardStateFile: fmt.Sprintf("%s/deletion_guard_%s.json", config.Syncthing.DataDir, config.AgentID),
+		deletionCounters:       make(map[string]*deletionCounter),
+		hookState: &hookState{
+			Jobs: make(map[string]*jobHooks),
+		},
+		hookStateFile:      fmt.Sprintf("%s/hooks_%s.json", config.Syncthing.DataDir, config.AgentID),
+		lastPreScan:        make(map[string]time.Time),
+		hookRunning:        make(map[string]bool),
+		pendingHookResults: make(map[string][]*protocol.HookResult),
+		conflictPolicyState: &conflictPolicyState{
+			Jobs: make(map[string]*jobConflictPolicy),
+		},
+		conflictPolicyStateFile: fmt.Sprintf("%s/conflict_policy_%s.json", config.Syncthing.DataDir, config.AgentID),
+		conflictQueue:           make(chan integration.ConflictEvent, conflictQueueSize),
+	}
+
+	agent.credentialFile = config.CredentialFile
+	if agent.credentialFile == "" {
+		agent.credentialFile = fmt.Sprintf("%s/agent_credential_%s", config.Syncthing.DataDir, config.AgentID)
 	}
 
 	// Get event channel
@@ -249,6 +380,29 @@ func (ia *IntegratedAgent) Start(ctx context.Context) error {
 	go ia.maintainConnection(ctx)
 	go ia.handleWebSocketSender(ctx)
 	go ia.readWebSocketMessages()  // Start the single reader goroutine
+
+	// Enforce maintenance windows locally (keeps working while the server is unreachable)
+	ia.loadMaintenanceWindowState()
+	go ia.runMaintenanceWindows(ctx)
+
+	// Apply bandwidth limits and switch rate profiles by time of day
+	ia.loadBandwidthState()
+	go ia.runBandwidthLimits(ctx)
+
+	// Pause destination folders that run low on disk space
+	ia.loadDiskGuardState()
+	go ia.runDiskGuard(ctx)
+
+	// Holds of jobs paused on a large deletion last until the deletions are confirmed
+	ia.loadDeletionGuardState()
+
+	// Source folders with a pre-scan hook are scanned by the agent, after the hook
+	ia.loadHookState()
+	go ia.runPreScanHooks(ctx)
+
+	// Conflict copies of jobs with a conflict policy are resolved as soon as they appear
+	ia.loadConflictPolicyState()
+	go ia.runConflictResolver(ctx)
 	
 	// Start test trigger file watcher
 	go ia.watchTestTriggers()
@@ -423,15 +577,19 @@ func (ia *IntegratedAgent) connectWebSocket() error {
 	
 	// Configure TLS for wss:// connections
 	if strings.HasPrefix(ia.wsURL, "wss://") {
-		dialer.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true, // Set to true for self-signed certs in dev
-		}
+		dialer.TLSClientConfig = ia.clientTLSConfig()
 	}
 	
-	conn, _, err := dialer.Dial(ia.wsURL, nil)
+	conn, resp, err := dialer.Dial(ia.wsURL, ia.authHeaders())
 	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			ia.dropRejectedCertificate(resp)
+			return fmt.Errorf("server rejected agent authentication (%s); configure a new enrollment_token: %w",
+				readHandshakeError(resp), err)
+		}
 		return fmt.Errorf("failed to dial WebSocket: %w", err)
 	}
+	ia.storeIssuedCredential(resp)
 
 	// Set connection with proper locking
 	ia.wsMutex.Lock()
@@ -469,12 +627,7 @@ func (ia *IntegratedAgent) connectWebSocket() error {
 	})
 
 	// Queue registration message through safe channel instead of direct write
-	regMsg := map[string]interface{}{
-		"type":      "register",
-		"agent_id":  ia.agentID,
-		"device_id": ia.deviceID,
-		"data_dir":  ia.config.Syncthing.DataDir,
-	}
+	regMsg := ia.registerMessage()
 
 	// Queue registration through safe channel
 	select {
@@ -522,24 +675,24 @@ func (ia *IntegratedAgent) reconnectToServer() error {
 	
 	// Configure TLS for wss:// connections
 	if strings.HasPrefix(ia.wsURL, "wss://") {
-		dialer.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true, // Set to true for self-signed certs in dev
-		}
+		dialer.TLSClientConfig = ia.clientTLSConfig()
 	}
 	
-	conn, _, err := dialer.Dial(ia.wsURL, nil)
+	conn, resp, err := dialer.Dial(ia.wsURL, ia.authHeaders())
 	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			ia.dropRejectedCertificate(resp)
+			return fmt.Errorf("server rejected agent authentication (%s); configure a new enrollment_token: %w",
+				readHandshakeError(resp), err)
+		}
 		return fmt.Errorf("failed to reconnect to WebSocket server: %w", err)
 	}
+	ia.storeIssuedCredential(resp)
 	
 	ia.wsConn = conn
 	
 	// Re-register with server
-	regMsg := map[string]interface{}{
-		"type":      "register",
-		"agent_id":  ia.agentID,
-		"device_id": ia.deviceID,
-	}
+	regMsg := ia.registerMessage()
 	
 	// Set timeouts for the new connection
 	conn.SetReadDeadline(time.Now().Add(300 * time.Second))
@@ -601,7 +754,9 @@ func (ia *IntegratedAgent) readWebSocketMessages() {
 		
 		if needReconnect {
 			log.Printf("WebSocket connection lost, attempting to reconnect...")
-			if err := ia.reconnectToServer(); err != nil {
+			err := ia.reconnectToServer()
+			reconnectsTotal.Inc(reconnectResult(err))
+			if err != nil {
 				log.Printf("Failed to reconnect: %v, retrying in 10 seconds...", err)
 				time.Sleep(10 * time.Second)
 				continue
@@ -685,7 +840,11 @@ func (ia *IntegratedAgent) handleWebSocketMessage(msg map[string]interface{}) {
 		ia.handleReloadConfigMessage(msg)  
 	case "scan-folder":
 		if folderID, ok := msg["folder_id"].(string); ok {
-			ia.ScanFolder(folderID)
+			if strings.HasPrefix(folderID, "job-") {
+				ia.scanJobFolder(strings.TrimPrefix(folderID, "job-"))
+			} else {
+				ia.ScanFolder(folderID)
+			}
 		}
 	case "get-status":
 		status := ia.GetStatus()
@@ -693,6 +852,8 @@ func (ia *IntegratedAgent) handleWebSocketMessage(msg map[string]interface{}) {
 			"type": "status",
 			"data": status,
 		})
+	case "register_ack":
+		ia.handleRegisterAckMessage(msg)
 	case "deploy_job":
 		ia.handleDeployJobMessage(msg)
 	case "pause_job":
@@ -701,6 +862,24 @@ func (ia *IntegratedAgent) handleWebSocketMessage(msg map[string]interface{}) {
 		ia.handleResumeJobMessage(msg)
 	case "delete_job":
 		ia.handleDeleteJobMessage(msg)
+	case "maintenance_windows":
+		ia.handleMaintenanceWindowsMessage(msg)
+	case "set_bandwidth_limits":
+		ia.handleSetBandwidthLimitsMessage(msg)
+	case "create_certificate_request":
+		go ia.handleCreateCertificateRequestMessage(msg)
+	case "install_certificate":
+		go ia.handleInstallCertificateMessage(msg)
+	case "list_file_versions":
+		go ia.handleListFileVersionsMessage(msg)
+	case "restore_file_versions":
+		go ia.handleRestoreFileVersionsMessage(msg)
+	case "check_disk_space":
+		go ia.handleCheckDiskSpaceMessage(msg)
+	case "build_manifest":
+		go ia.handleBuildManifestMessage(msg)
+	case "resolve_conflict":
+		go ia.handleResolveConflictMessage(msg)
 	case "browse_folders":
 		ia.handleBrowseFoldersMessage(msg)
 	case "get_folder_stats":
@@ -734,7 +913,12 @@ func (ia *IntegratedAgent) handleCommand(cmd string, msg map[string]interface{},
 		ia.handleListFoldersMessage(cliID)
 	case "scan-folder":
 		if folderID, ok := msg["folder_id"].(string); ok {
-			err := ia.ScanFolder(folderID)
+			var err error
+			if strings.HasPrefix(folderID, "job-") {
+				err = ia.scanJobFolder(strings.TrimPrefix(folderID, "job-"))
+			} else {
+				err = ia.ScanFolder(folderID)
+			}
 			if err != nil {
 				ia.sendWebSocketMessage(map[string]interface{}{
 					"type":    "error",
@@ -1144,6 +1328,7 @@ func (ia *IntegratedAgent) handleAgentEvent(event types.AgentEvent) {
 					// Handle periodic stats for job folders
 					if strings.HasPrefix(folderID, "job-") {
 						jobID := strings.TrimPrefix(folderID, "job-")
+						ia.noteDeletionGuardFolderState(jobID, to)
 
 						if to == "scanning" || to == "syncing" {
 							// Start or update sync session
@@ -1170,6 +1355,14 @@ func (ia *IntegratedAgent) handleAgentEvent(event types.AgentEvent) {
 				}
 			}
 		}
+	case "local_change_detected":
+		if data, ok := event.Data.(map[string]interface{}); ok {
+			ia.countLocalDeletion(data)
+		}
+	case "conflict_detected":
+		if data, ok := event.Data.(integration.ConflictEvent); ok {
+			ia.queueConflict(data)
+		}
 	case "folder_scan_progress":
 		if data, ok := event.Data.(map[string]interface{}); ok {
 			if folderID, exists := data["folder_id"].(string); exists {
@@ -1252,16 +1445,51 @@ func (ia *IntegratedAgent) collectSystemInfo() SystemInfo {
 		}
 	}
 	
-	// TODO: Implement actual CPU, memory, disk usage collection
-	// For now, use placeholder values
-	return SystemInfo{
-		Hostname:    hostname,
-		OS:          osName,
-		CPUUsage:    15.5,
-		MemoryUsage: 52 * 1024 * 1024, // 52MB
-		DiskUsage:   1024 * 1024 * 1024, // 1GB
-		Uptime:      3600, // 1 hour
+	info := SystemInfo{
+		Hostname:     hostname,
+		OS:           osName,
+		Architecture: runtime.GOARCH,
+		CPUCount:     runtime.NumCPU(),
+	}
+
+	// Host metrics are read from /proc and statfs; readers that are not available on
+	// this platform leave their fields at zero
+	if usage, err := ia.cpuUsage(); err == nil {
+		info.CPUUsage = usage
+	} else if err != errHostMetricsUnsupported {
+		log.Printf("⚠️ Failed to read CPU usage: %v", err)
+	}
+
+	if loads, err := readLoadAverage(); err == nil {
+		info.LoadAverage = loads
+	} else if err != errHostMetricsUnsupported {
+		log.Printf("⚠️ Failed to read load average: %v", err)
+	}
+
+	if total, available, err := readMemory(); err == nil {
+		info.MemoryTotal = total
+		info.MemoryUsage = total - available
+		info.MemoryPercent = percentOf(info.MemoryUsage, total)
+	} else if err != errHostMetricsUnsupported {
+		log.Printf("⚠️ Failed to read memory usage: %v", err)
+	}
+
+	if uptime, err := readUptime(); err == nil {
+		info.Uptime = uptime
+	} else if err != errHostMetricsUnsupported {
+		log.Printf("⚠️ Failed to read uptime: %v", err)
 	}
+
+	if total, free, used, err := diskSpace(ia.config.Syncthing.DataDir); err == nil {
+		info.DiskTotal = total
+		info.DiskFree = free
+		info.DiskUsage = used
+	} else {
+		log.Printf("⚠️ Failed to read disk space of %s: %v", ia.config.Syncthing.DataDir, err)
+	}
+
+	info.Disks = ia.collectFolderDisks()
+	return info
 }
 
 
@@ -1375,7 +1603,9 @@ func (ia *IntegratedAgent) maintainConnection(ctx context.Context) {
 			
 			if needReconnect {
 				log.Println("WebSocket disconnected, attempting to reconnect...")
-				if err := ia.connectWebSocket(); err != nil {
+				err := ia.connectWebSocket()
+				reconnectsTotal.Inc(reconnectResult(err))
+				if err != nil {
 					log.Printf("Reconnection failed: %v", err)
 				}
 			}
@@ -1392,58 +1622,43 @@ func (ia *IntegratedAgent) GetSyncthing() *embedded.EmbeddedSyncthing {
 func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 	log.Printf("Received deploy job message: %+v", msg)
 	
-	// Extract job configuration from message
-	jobID, hasJobID := msg["job_id"].(string)
-	name, hasName := msg["name"].(string)
-	sourcePath, hasSourcePath := msg["source_path"].(string)
-	destinationPath, hasDestinationPath := msg["destination_path"].(string)
-	syncType, hasSyncType := msg["sync_type"].(string)
-	destinationAgentID, hasDestAgentID := msg["destination_agent_id"].(string)
+	// Decode job configuration from message (job_id, name, sync_type and source_agent_id are required)
+	var job protocol.DeployJob
+	if err := ia.decodeServerMessage(msg, &job); err != nil {
+		jobID, _ := msg["job_id"].(string)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":    "job_deploy_error",
+			"job_id":  jobID,
+			"message": err.Error(),
+		})
+		return
+	}
+	jobID := job.JobID
+	name := job.Name
+	sourcePath := job.SourcePath
+	destinationPath := job.DestinationPath
+	syncType := job.SyncType
+	destinationAgentID := job.DestinationAgentID
 
-	// Extract rescan_interval with default fallback
+	// Rescan interval with default fallback
 	rescanInterval := 3600 // default
-	if interval, ok := msg["rescan_interval_s"].(float64); ok {
-		rescanInterval = int(interval)
+	if job.RescanIntervalS != nil {
+		rescanInterval = *job.RescanIntervalS
 	}
 
-	// Extract ignore_patterns
 	var ignorePatterns []string
-	if patterns, ok := msg["ignore_patterns"].([]interface{}); ok {
-		for _, p := range patterns {
-			if pattern, ok := p.(string); ok && strings.TrimSpace(pattern) != "" {
-				ignorePatterns = append(ignorePatterns, strings.TrimSpace(pattern))
-			}
+	for _, pattern := range job.IgnorePatterns {
+		if strings.TrimSpace(pattern) != "" {
+			ignorePatterns = append(ignorePatterns, strings.TrimSpace(pattern))
 		}
 	}
-
-	// Basic validation - jobID, name, and syncType are always required
-	if !hasJobID || !hasName || !hasSyncType {
-		log.Printf("Deploy job missing required fields (jobID, name, or syncType)")
-		ia.sendWebSocketMessage(map[string]interface{}{
-			"type":    "job_deploy_error",
-			"job_id":  jobID,
-			"message": "Missing required fields for job deployment",
-		})
-		return
-	}
 	
 	// Determine if this agent is source or destination
-	sourceAgentID, hasSourceAgentID := msg["source_agent_id"].(string)
-	if !hasSourceAgentID {
-		log.Printf("Deploy job missing source_agent_id")
-		ia.sendWebSocketMessage(map[string]interface{}{
-			"type":    "job_deploy_error", 
-			"job_id":  jobID,
-			"message": "Missing source_agent_id field",
-		})
-		return
-	}
-	
-	isSourceAgent := sourceAgentID == ia.agentID
-	isDestinationAgent := hasDestAgentID && destinationAgentID == ia.agentID
+	isSourceAgent := job.SourceAgentID == ia.agentID
+	isDestinationAgent := destinationAgentID != "" && destinationAgentID == ia.agentID
 
 	// Role-specific validation
-	if isSourceAgent && !hasSourcePath {
+	if isSourceAgent && sourcePath == "" {
 		log.Printf("Deploy job missing source_path for source agent")
 		ia.sendWebSocketMessage(map[string]interface{}{
 			"type":    "job_deploy_error",
@@ -1453,7 +1668,7 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 		return
 	}
 
-	if isDestinationAgent && !hasDestinationPath {
+	if isDestinationAgent && destinationPath == "" {
 		log.Printf("Deploy job missing destination_path for destination agent")
 		ia.sendWebSocketMessage(map[string]interface{}{
 			"type":    "job_deploy_error",
@@ -1463,7 +1678,19 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 		return
 	}
 
+	// Hooks of this agent's role; a pre-scan hook takes over the scans of the source folder
+	hooks := ia.parseJobHooks(jobID, msg, isSourceAgent, isDestinationAgent)
+	if hooks != nil {
+		hooks.Name = name
+		hooks.RescanInterval = rescanInterval
+		hooks.FolderPath = destinationPath
+		if isSourceAgent {
+			hooks.FolderPath = sourcePath
+		}
+	}
+
 	var folderConfig embedded.FolderConfig
+	var versioningWarning string // reported with the deploy result when the versioning policy is not applied as sent
 	var folderID string
 
 	// Both agents use the same folder ID (this is required for Syncthing sync)
@@ -1474,16 +1701,16 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 	
 	if isSourceAgent {
 		// Source agent needs to add destination device
-		remoteDeviceID, _ = msg["destination_device_id"].(string)
+		remoteDeviceID = job.DestinationDeviceID
 		remoteAgentID = destinationAgentID
-		remoteIPAddress, _ = msg["destination_ip_address"].(string)
+		remoteIPAddress = job.DestinationIPAddress
 	}
 	
 	if isDestinationAgent {
 		// Destination agent needs to add source device
-		remoteDeviceID, _ = msg["source_device_id"].(string)
-		remoteAgentID, _ = msg["source_agent_id"].(string)
-		remoteIPAddress, _ = msg["source_ip_address"].(string)
+		remoteDeviceID = job.SourceDeviceID
+		remoteAgentID = job.SourceAgentID
+		remoteIPAddress = job.SourceIPAddress
 	}
 	
 	// 🆕 AUTO-EXTRACT IP: If server doesn't send IP, extract from agent connection or config
@@ -1521,43 +1748,35 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 
 		// Check for multi-destination support
 		var destinationDeviceIDs []string
-		isMultiDest, _ := msg["is_multi_destination"].(bool)
 
-		if isMultiDest {
+		if job.IsMultiDestination {
 			// Multi-destination mode: get array of destination device IDs
-			if destDeviceIDsRaw, ok := msg["destination_device_ids"].([]interface{}); ok {
-				for _, d := range destDeviceIDsRaw {
-					if deviceID, ok := d.(string); ok && deviceID != "" {
-						destinationDeviceIDs = append(destinationDeviceIDs, deviceID)
-					}
+			for _, deviceID := range job.DestinationDeviceIDs {
+				if deviceID != "" {
+					destinationDeviceIDs = append(destinationDeviceIDs, deviceID)
 				}
 			}
 
 			// Also auto-add all destination devices
-			if destAgentIDsRaw, ok := msg["destination_agent_ids"].([]interface{}); ok {
-				destIPsRaw, _ := msg["destination_ip_addresses"].([]interface{})
-
-				for i, d := range destAgentIDsRaw {
-					destAgentID, _ := d.(string)
-					var destDeviceID, destIPAddress string
+			for i, destAgentID := range job.DestinationAgentIDs {
+				var destDeviceID, destIPAddress string
 
-					if i < len(destinationDeviceIDs) {
-						destDeviceID = destinationDeviceIDs[i]
-					}
-					if i < len(destIPsRaw) {
-						destIPAddress, _ = destIPsRaw[i].(string)
-					}
+				if i < len(destinationDeviceIDs) {
+					destDeviceID = destinationDeviceIDs[i]
+				}
+				if i < len(job.DestinationIPAddresses) {
+					destIPAddress = job.DestinationIPAddresses[i]
+				}
 
-					if destDeviceID != "" && destIPAddress != "" {
-						remoteAddress := fmt.Sprintf("tcp://%s:22101", destIPAddress)
-						log.Printf("🔗 Auto-adding destination device %s (%s) at %s", destAgentID, destDeviceID, remoteAddress)
+				if destDeviceID != "" && destIPAddress != "" {
+					remoteAddress := fmt.Sprintf("tcp://%s:22101", destIPAddress)
+					log.Printf("🔗 Auto-adding destination device %s (%s) at %s", destAgentID, destDeviceID, remoteAddress)
 
-						err := ia.AddDevice(destDeviceID, destAgentID, remoteAddress)
-						if err != nil {
-							log.Printf("⚠️ Failed to auto-add destination device %s: %v (continuing)", destAgentID, err)
-						} else {
-							log.Printf("✅ Successfully auto-added destination device %s", destAgentID)
-						}
+					err := ia.AddDevice(destDeviceID, destAgentID, remoteAddress)
+					if err != nil {
+						log.Printf("⚠️ Failed to auto-add destination device %s: %v (continuing)", destAgentID, err)
+					} else {
+						log.Printf("✅ Successfully auto-added destination device %s", destAgentID)
 					}
 				}
 			}
@@ -1575,8 +1794,8 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 			log.Printf("🌟 Multi-destination job: Source will sync to %d destination(s)", len(destinationDeviceIDs))
 		} else {
 			// Legacy single destination mode
-			destinationDeviceID, hasDestDeviceID := msg["destination_device_id"].(string)
-			if !hasDestDeviceID {
+			destinationDeviceID := job.DestinationDeviceID
+			if destinationDeviceID == "" {
 				log.Printf("Deploy job missing destination_device_id for source agent")
 				ia.sendWebSocketMessage(map[string]interface{}{
 					"type":    "job_deploy_error",
@@ -1594,16 +1813,24 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 			fsWatcherEnabled = false
 		}
 
+		// The agent runs the pre-scan hook and scans the folder itself
+		sourceRescanInterval := rescanInterval
+		if hooks != nil && hooks.PreScan != nil {
+			sourceRescanInterval = 0
+			fsWatcherEnabled = false
+		}
+
 		folderConfig = embedded.FolderConfig{
 			ID:              folderID,
 			Label:           name, // Use job name as folder alias
 			Path:            sourcePath,
 			Type:            folderType,
 			Devices:         destinationDeviceIDs, // Connect to ALL destination devices
-			RescanIntervalS: rescanInterval,
+			RescanIntervalS: sourceRescanInterval,
 			FSWatcherEnabled: fsWatcherEnabled,
 			IgnorePerms:     false,
 			IgnorePatterns:  ignorePatterns,
+			MaxConflicts:    deployMaxConflicts(msg), // Conflict copies kept per file
 		}
 
 		log.Printf("📂 Source folder configured: ID=%s, Path=%s, Type=%s, Devices=%v", folderID, sourcePath, folderType, destinationDeviceIDs)
@@ -1617,8 +1844,8 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 		}
 		
 		// Get source device ID for Syncthing configuration
-		sourceDeviceID, hasSourceDeviceID := msg["source_device_id"].(string)
-		if !hasSourceDeviceID {
+		sourceDeviceID := job.SourceDeviceID
+		if sourceDeviceID == "" {
 			log.Printf("Deploy job missing source_device_id for destination agent")
 			ia.sendWebSocketMessage(map[string]interface{}{
 				"type":    "job_deploy_error",
@@ -1634,6 +1861,9 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 			fsWatcherEnabled = false
 		}
 		
+		var versioning *embedded.VersioningPolicy
+		versioning, versioningWarning = parseVersioningPolicy(msg["versioning"], ia.config.Versioning.AllowExternal)
+
 		folderConfig = embedded.FolderConfig{
 			ID:              folderID,
 			Label:           name, // Use job name as folder alias
@@ -1644,6 +1874,8 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 			FSWatcherEnabled: fsWatcherEnabled,
 			IgnorePerms:     false,
 			IgnorePatterns:  ignorePatterns,
+			Versioning:      versioning, // Archive overwritten/deleted files on the destination
+			MaxConflicts:    deployMaxConflicts(msg),                  // Conflict copies kept per file
 		}
 	}
 	
@@ -1663,7 +1895,8 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 			log.Printf("Updating existing job %s as folder %s", jobID, folderID)
 			err = ia.UpdateFolder(folderConfig)
 			// Trigger folder rescan after ignore pattern update (always trigger when updating)
-			if err == nil {
+			// (folders with a pre-scan hook are rescanned after the hook, once the new hooks are stored)
+			if err == nil && (hooks == nil || hooks.PreScan == nil) {
 				log.Printf("🔄 Job updated, triggering folder rescan for ignore pattern changes...")
 				if err := ia.triggerFolderRescan(folderID); err != nil {
 					log.Printf("❌ Failed to trigger folder rescan: %v", err)
@@ -1690,23 +1923,57 @@ func (ia *IntegratedAgent) handleDeployJobMessage(msg map[string]interface{}) {
 				action = "updated"
 			}
 			log.Printf("Successfully %s job %s as folder %s", action, jobID, folderID)
-			ia.sendWebSocketMessage(map[string]interface{}{
+
+			// Pause right away if the job is deployed outside its maintenance windows
+			ia.setJobMaintenanceWindows(jobID, msg)
+			ia.applyMaintenanceWindows()
+
+			ia.setJobBandwidthLimit(jobID, msg, folderConfig.Devices)
+			ia.applyBandwidthLimits()
+
+			ia.setJobDiskGuard(jobID, msg, isDestinationAgent, destinationPath)
+			ia.applyDiskGuard()
+
+			ia.setJobDeletionGuard(jobID, msg, isSourceAgent)
+
+			ia.setJobHooks(jobID, hooks)
+			if folderExists && hooks != nil && hooks.PreScan != nil {
+				go ia.runPreScanHook(jobID)
+			}
+
+			conflictPolicyPath := destinationPath
+			if isSourceAgent {
+				conflictPolicyPath = sourcePath
+			}
+			ia.setJobConflictPolicy(jobID, msg, isSourceAgent, conflictPolicyPath)
+
+			deployed := map[string]interface{}{
 				"type":      "job_deployed",
 				"job_id":    jobID,
 				"folder_id": folderID,
 				"message":   fmt.Sprintf("Job %s %s successfully", name, action),
-			})
+			}
+			if versioningWarning != "" {
+				deployed["warning"] = versioningWarning
+			}
+			ia.sendWebSocketMessage(deployed)
 		}
 	}
 }
 
 // handlePauseJobMessage handles pause job WebSocket message from server
 func (ia *IntegratedAgent) handlePauseJobMessage(msg map[string]interface{}) {
-	jobID, hasJobID := msg["job_id"].(string)
-	if !hasJobID {
-		log.Printf("Pause job missing job_id")
+	control := protocol.JobControl{Type: protocol.TypePauseJob}
+	if err := ia.decodeServerMessage(msg, &control); err != nil {
+		jobID, _ := msg["job_id"].(string)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":    "job_pause_error",
+			"job_id":  jobID,
+			"message": err.Error(),
+		})
 		return
 	}
+	jobID := control.JobID
 	
 	log.Printf("Received pause job message for job: %s", jobID)
 	
@@ -1719,6 +1986,10 @@ func (ia *IntegratedAgent) handlePauseJobMessage(msg map[string]interface{}) {
 	if err := ia.syncthing.PauseFolder(folderID); err == nil {
 		pausedFolders = append(pausedFolders, folderID)
 		log.Printf("Paused folder %s for job %s", folderID, jobID)
+		ia.noteJobPaused(jobID, control.Reason)
+		if control.Reason == pauseReasonDeletionGuard {
+			ia.holdForDeletionGuard(jobID)
+		}
 	} else {
 		log.Printf("Failed to pause folder %s for job %s: %v", folderID, jobID, err)
 	}
@@ -1741,11 +2012,17 @@ func (ia *IntegratedAgent) handlePauseJobMessage(msg map[string]interface{}) {
 
 // handleResumeJobMessage handles resume job WebSocket message from server
 func (ia *IntegratedAgent) handleResumeJobMessage(msg map[string]interface{}) {
-	jobID, hasJobID := msg["job_id"].(string)
-	if !hasJobID {
-		log.Printf("Resume job missing job_id")
+	control := protocol.JobControl{Type: protocol.TypeResumeJob}
+	if err := ia.decodeServerMessage(msg, &control); err != nil {
+		jobID, _ := msg["job_id"].(string)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":    "job_resume_error",
+			"job_id":  jobID,
+			"message": err.Error(),
+		})
 		return
 	}
+	jobID := control.JobID
 	
 	log.Printf("Received resume job message for job: %s", jobID)
 	
@@ -1754,10 +2031,40 @@ func (ia *IntegratedAgent) handleResumeJobMessage(msg map[string]interface{}) {
 	
 	resumedFolders := []string{}
 	
+	// Only the confirmation of the deletions (a resume tagged deletion_guard) releases a deletion guard hold
+	if control.Reason != pauseReasonDeletionGuard && ia.deletionGuardHolds(jobID) {
+		log.Printf("🛡️ Folder %s stays paused until the deletions are confirmed", folderID)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":            "job_resumed",
+			"job_id":          jobID,
+			"resumed_folders": resumedFolders,
+			"message":         fmt.Sprintf("Job %s stays paused until the deletions are confirmed", jobID),
+		})
+		return
+	}
+	
+	// The end of a maintenance window does not override a pause for low disk space
+	if control.Reason == pauseReasonMaintenanceWindow && ia.diskGuardHolds(jobID) {
+		ia.noteJobResumed(jobID, control.Reason)
+		log.Printf("💾 Folder %s stays paused for low disk space", folderID)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":            "job_resumed",
+			"job_id":          jobID,
+			"resumed_folders": resumedFolders,
+			"message":         fmt.Sprintf("Job %s stays paused for low disk space", jobID),
+		})
+		return
+	}
+	
 	// Try to resume the folder
 	if err := ia.syncthing.ResumeFolder(folderID); err == nil {
 		resumedFolders = append(resumedFolders, folderID)
 		log.Printf("Resumed folder %s for job %s", folderID, jobID)
+		ia.noteJobResumed(jobID, control.Reason)
+		ia.clearDiskGuardPause(jobID)
+		if control.Reason == pauseReasonDeletionGuard {
+			ia.releaseDeletionGuardHold(jobID)
+		}
 	} else {
 		log.Printf("Failed to resume folder %s for job %s: %v", folderID, jobID, err)
 	}
@@ -1780,11 +2087,17 @@ func (ia *IntegratedAgent) handleResumeJobMessage(msg map[string]interface{}) {
 
 // handleDeleteJobMessage handles delete job WebSocket message from server
 func (ia *IntegratedAgent) handleDeleteJobMessage(msg map[string]interface{}) {
-	jobID, hasJobID := msg["job_id"].(string)
-	if !hasJobID {
-		log.Printf("Delete job missing job_id")
+	control := protocol.JobControl{Type: protocol.TypeDeleteJob}
+	if err := ia.decodeServerMessage(msg, &control); err != nil {
+		jobID, _ := msg["job_id"].(string)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":    "job_delete_error",
+			"job_id":  jobID,
+			"message": err.Error(),
+		})
 		return
 	}
+	jobID := control.JobID
 	
 	log.Printf("Received delete job message for job: %s", jobID)
 	
@@ -1797,6 +2110,12 @@ func (ia *IntegratedAgent) handleDeleteJobMessage(msg map[string]interface{}) {
 	if err := ia.RemoveFolder(folderID); err == nil {
 		deletedFolders = append(deletedFolders, folderID)
 		log.Printf("Deleted folder %s for job %s", folderID, jobID)
+		ia.forgetJobMaintenanceWindows(jobID)
+		ia.forgetJobBandwidthLimit(jobID)
+		ia.forgetJobDiskGuard(jobID)
+		ia.forgetJobDeletionGuard(jobID)
+		ia.forgetJobHooks(jobID)
+		ia.forgetJobConflictPolicy(jobID)
 	} else {
 		log.Printf("Failed to delete folder %s for job %s: %v", folderID, jobID, err)
 	}
@@ -1999,6 +2318,7 @@ func (ia *IntegratedAgent) loadPendingEvents() ([]PendingEvent, error) {
 	
 	// Check if file exists
 	if _, err := os.Stat(ia.pendingEventsFile); os.IsNotExist(err) {
+		ia.pendingEventsOnDisk = 0
 		return []PendingEvent{}, nil
 	}
 	
@@ -2028,6 +2348,7 @@ func (ia *IntegratedAgent) loadPendingEvents() ([]PendingEvent, error) {
 		return []PendingEvent{}, nil
 	}
 	
+	ia.pendingEventsOnDisk = len(events)
 	return events, nil
 }
 
@@ -2083,6 +2404,7 @@ func (ia *IntegratedAgent) savePendingEvents(events []PendingEvent) error {
 		return fmt.Errorf("failed to rename pending events file: %w", err)
 	}
 	
+	ia.pendingEventsOnDisk = len(events)
 	return nil
 }
 
@@ -2238,11 +2560,14 @@ func (ia *IntegratedAgent) handleGetFolderStatsMessage(msg map[string]interface{
 	
 	// Get folder ID from message
 	folderID, hasFolderID := msg["folder_id"].(string)
+	// Echoed back so the server can match the response to its request
+	requestID, _ := msg["request_id"].(string)
 	if !hasFolderID {
 		log.Printf("Folder stats request missing folder_id")
 		ia.sendWebSocketMessage(map[string]interface{}{
-			"type":    "folder_stats_error",
-			"message": "Missing folder_id field",
+			"type":       "folder_stats_error",
+			"request_id": requestID,
+			"message":    "Missing folder_id field",
 		})
 		return
 	}
@@ -2252,9 +2577,10 @@ func (ia *IntegratedAgent) handleGetFolderStatsMessage(msg map[string]interface{
 	if err != nil {
 		log.Printf("Failed to get folder stats for %s: %v", folderID, err)
 		ia.sendWebSocketMessage(map[string]interface{}{
-			"type":      "folder_stats_error",
-			"folder_id": folderID,
-			"message":   fmt.Sprintf("Failed to get folder stats: %v", err),
+			"type":       "folder_stats_error",
+			"request_id": requestID,
+			"folder_id":  folderID,
+			"message":    fmt.Sprintf("Failed to get folder stats: %v", err),
 		})
 		return
 	}
@@ -2265,6 +2591,7 @@ func (ia *IntegratedAgent) handleGetFolderStatsMessage(msg map[string]interface{
 	// Add progress information to folder stats if available
 	response := map[string]interface{}{
 		"type":        "folder_stats_response",
+		"request_id":  requestID,
 		"folder_id":   folderID,
 		"agent_id":    ia.agentID,
 		"stats":       folderStats,
@@ -2749,6 +3076,9 @@ func (ia *IntegratedAgent) startSyncSession(jobID, state string) *integration.Sy
 	// Send session started event to server
 	ia.sendSessionEvent("session_started", session)
 
+	// Pre-scan hook results belong to the session the hook's scan started
+	ia.flushPendingHookResults(jobID, sessionID)
+
 	log.Printf("📊 [SESSION] Started new session: %s for job %s (state: %s)", sessionID, jobID, state)
 	return session
 }
@@ -2859,6 +3189,9 @@ func (ia *IntegratedAgent) finalizeSyncSession(jobID string) {
 
 	// Remove from active sessions
 	delete(ia.activeSessions, jobID)
+
+	// Destinations run their post-session hook once the transferred files are in place
+	go ia.runPostSessionHook(jobID, session.SessionID, session.Status, session.FilesTransferred, session.TotalDeltaBytes)
 }
 
 // updateSessionFileTransfer updates session stats when a file transfer completes
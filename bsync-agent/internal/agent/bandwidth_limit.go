@@ -0,0 +1,291 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+// bandwidthCheckInterval is how often time-of-day rate profiles are re-evaluated
+const bandwidthCheckInterval = 1 * time.Minute
+
+// BandwidthLimit is a send/receive rate limit in KiB/s (0 = unlimited), as used by the
+// sync engine. The first matching rate profile rule overrides the base limit, e.g.
+// 250 KiB/s on weekdays 08:00-18:00 and unlimited otherwise.
+type BandwidthLimit struct {
+	MaxSendKbps int               `json:"max_send_kbps"`
+	MaxRecvKbps int               `json:"max_recv_kbps"`
+	Timezone    string            `json:"timezone,omitempty"`
+	RateProfile []RateProfileRule `json:"rate_profile,omitempty"`
+}
+
+// RateProfileRule overrides the base limit during a daily time window
+type RateProfileRule struct {
+	Name        string `json:"name,omitempty"`
+	DaysOfWeek  []int  `json:"days_of_week,omitempty"`
+	StartTime   string `json:"start_time"`
+	EndTime     string `json:"end_time"`
+	MaxSendKbps int    `json:"max_send_kbps"`
+	MaxRecvKbps int    `json:"max_recv_kbps"`
+}
+
+// jobBandwidth is the limit of a job and the remote devices it applies to
+type jobBandwidth struct {
+	Limit   *BandwidthLimit `json:"limit"`
+	Devices []string        `json:"devices"`
+}
+
+// bandwidthState is persisted so rate profiles keep switching after an agent restart
+type bandwidthState struct {
+	AgentLimit *BandwidthLimit          `json:"agent_limit"`
+	JobLimits  map[string]*jobBandwidth `json:"job_limits"` // job_id -> limit
+}
+
+// rateLimits is a send/receive pair in KiB/s
+type rateLimits struct {
+	send int
+	recv int
+}
+
+// Effective returns the send/receive limits in force at t
+func (bl *BandwidthLimit) Effective(t time.Time) (int, int) {
+	for _, rule := range bl.RateProfile {
+		active, err := dailyWindowActive(rule.DaysOfWeek, rule.StartTime, rule.EndTime, bl.Timezone, t)
+		if err != nil {
+			log.Printf("⚠️ Ignoring rate profile rule %q: %v", rule.Name, err)
+			continue
+		}
+		if active {
+			return rule.MaxSendKbps, rule.MaxRecvKbps
+		}
+	}
+	return bl.MaxSendKbps, bl.MaxRecvKbps
+}
+
+// parseBandwidthLimit converts a bandwidth limit field of a server message (null = no limit)
+func parseBandwidthLimit(raw interface{}) (*BandwidthLimit, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var limit BandwidthLimit
+	if err := json.Unmarshal(data, &limit); err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// minNonZero combines two limits where 0 means unlimited
+func minNonZero(a, b int) int {
+	if a == 0 {
+		return b
+	}
+	if b == 0 || a < b {
+		return a
+	}
+	return b
+}
+
+// setJobBandwidthLimit records the limit of a deployed job for its remote devices.
+// Messages without bandwidth_limit (older servers) keep the current limit.
+func (ia *IntegratedAgent) setJobBandwidthLimit(jobID string, msg map[string]interface{}, devices []string) {
+	ia.bandwidthMutex.Lock()
+	raw, ok := msg["bandwidth_limit"]
+	if !ok {
+		// Device list may still have changed with the re-deploy
+		if existing, exists := ia.bandwidthState.JobLimits[jobID]; exists {
+			existing.Devices = devices
+		}
+		ia.bandwidthMutex.Unlock()
+		return
+	}
+
+	limit, err := parseBandwidthLimit(raw)
+	if err != nil {
+		ia.bandwidthMutex.Unlock()
+		log.Printf("⚠️ Invalid bandwidth limit for job %s: %v", jobID, err)
+		return
+	}
+
+	if limit == nil {
+		delete(ia.bandwidthState.JobLimits, jobID)
+	} else {
+		ia.bandwidthState.JobLimits[jobID] = &jobBandwidth{Limit: limit, Devices: devices}
+		log.Printf("🚦 Job %s bandwidth limit: send=%d KiB/s, recv=%d KiB/s, %d profile rule(s)",
+			jobID, limit.MaxSendKbps, limit.MaxRecvKbps, len(limit.RateProfile))
+	}
+	ia.bandwidthMutex.Unlock()
+
+	ia.saveBandwidthState()
+}
+
+// handleSetBandwidthLimitsMessage handles agent-wide limits pushed by the server
+func (ia *IntegratedAgent) handleSetBandwidthLimitsMessage(msg map[string]interface{}) {
+	limit, err := parseBandwidthLimit(msg["agent_limit"])
+	if err != nil {
+		log.Printf("⚠️ Invalid agent bandwidth limit: %v", err)
+		return
+	}
+
+	ia.bandwidthMutex.Lock()
+	ia.bandwidthState.AgentLimit = limit
+	ia.bandwidthMutex.Unlock()
+
+	if limit == nil {
+		log.Printf("🚦 Agent bandwidth limit removed")
+	} else {
+		log.Printf("🚦 Agent bandwidth limit: send=%d KiB/s, recv=%d KiB/s, %d profile rule(s)",
+			limit.MaxSendKbps, limit.MaxRecvKbps, len(limit.RateProfile))
+	}
+
+	ia.saveBandwidthState()
+	ia.applyBandwidthLimits()
+}
+
+// forgetJobBandwidthLimit drops the limit of a deleted job
+func (ia *IntegratedAgent) forgetJobBandwidthLimit(jobID string) {
+	ia.bandwidthMutex.Lock()
+	delete(ia.bandwidthState.JobLimits, jobID)
+	ia.bandwidthMutex.Unlock()
+
+	ia.saveBandwidthState()
+	ia.applyBandwidthLimits()
+}
+
+// runBandwidthLimits re-evaluates rate profiles periodically
+func (ia *IntegratedAgent) runBandwidthLimits(ctx context.Context) {
+	ia.applyBandwidthLimits()
+
+	ticker := time.NewTicker(bandwidthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ia.stopChan:
+			return
+		case <-ticker.C:
+			ia.applyBandwidthLimits()
+		}
+	}
+}
+
+// applyBandwidthLimits pushes the limits in force right now to the sync engine.
+// The agent limit goes to the global options; job limits go to the job's remote devices.
+// Rate limits are per device, so jobs sharing a device pair share the most restrictive limit.
+func (ia *IntegratedAgent) applyBandwidthLimits() {
+	now := time.Now()
+
+	ia.bandwidthMutex.Lock()
+	defer ia.bandwidthMutex.Unlock()
+
+	// Agent-wide limit (left untouched until the server configures one)
+	if ia.bandwidthState.AgentLimit != nil || ia.appliedGlobalLimit != nil {
+		desired := rateLimits{}
+		if ia.bandwidthState.AgentLimit != nil {
+			desired.send, desired.recv = ia.bandwidthState.AgentLimit.Effective(now)
+		}
+		if ia.appliedGlobalLimit == nil || *ia.appliedGlobalLimit != desired {
+			if err := ia.syncthing.SetGlobalRateLimits(desired.send, desired.recv); err != nil {
+				log.Printf("❌ Failed to apply agent bandwidth limit: %v", err)
+			} else {
+				ia.appliedGlobalLimit = &desired
+			}
+		}
+	}
+
+	// Per-device limits from job limits
+	desiredDevices := make(map[string]rateLimits)
+	for _, job := range ia.bandwidthState.JobLimits {
+		send, recv := job.Limit.Effective(now)
+		for _, deviceID := range job.Devices {
+			current := desiredDevices[deviceID]
+			desiredDevices[deviceID] = rateLimits{
+				send: minNonZero(current.send, send),
+				recv: minNonZero(current.recv, recv),
+			}
+		}
+	}
+	// Devices that are no longer limited by any job go back to unlimited
+	for deviceID := range ia.appliedDeviceLimits {
+		if _, ok := desiredDevices[deviceID]; !ok {
+			desiredDevices[deviceID] = rateLimits{}
+		}
+	}
+
+	for deviceID, desired := range desiredDevices {
+		if applied, ok := ia.appliedDeviceLimits[deviceID]; ok && applied == desired {
+			continue
+		}
+		if err := ia.syncthing.SetDeviceRateLimits(deviceID, desired.send, desired.recv); err != nil {
+			log.Printf("❌ Failed to apply bandwidth limit for device %s: %v", deviceID, err)
+			continue
+		}
+		if desired == (rateLimits{}) {
+			delete(ia.appliedDeviceLimits, deviceID)
+		} else {
+			ia.appliedDeviceLimits[deviceID] = desired
+		}
+	}
+}
+
+// loadBandwidthState restores bandwidth limits from disk
+func (ia *IntegratedAgent) loadBandwidthState() {
+	data, err := ioutil.ReadFile(ia.bandwidthStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read bandwidth limits: %v", err)
+		}
+		return
+	}
+
+	var state bandwidthState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Failed to parse bandwidth limits (file may be corrupted), starting empty: %v", err)
+		return
+	}
+
+	ia.bandwidthMutex.Lock()
+	ia.bandwidthState.AgentLimit = state.AgentLimit
+	if state.JobLimits != nil {
+		ia.bandwidthState.JobLimits = state.JobLimits
+	}
+	// Limits from the previous run are in the engine config; re-apply them on the first pass
+	for _, job := range ia.bandwidthState.JobLimits {
+		for _, deviceID := range job.Devices {
+			ia.appliedDeviceLimits[deviceID] = rateLimits{send: -1, recv: -1}
+		}
+	}
+	ia.bandwidthMutex.Unlock()
+
+	log.Printf("🚦 Loaded bandwidth limits for %d job(s)", len(state.JobLimits))
+}
+
+// saveBandwidthState writes bandwidth limits to disk atomically
+func (ia *IntegratedAgent) saveBandwidthState() {
+	ia.bandwidthMutex.Lock()
+	defer ia.bandwidthMutex.Unlock()
+
+	data, err := json.MarshalIndent(ia.bandwidthState, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to marshal bandwidth limits: %v", err)
+		return
+	}
+
+	tempFile := ia.bandwidthStateFile + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		log.Printf("❌ Failed to write bandwidth limits: %v", err)
+		return
+	}
+	if err := os.Rename(tempFile, ia.bandwidthStateFile); err != nil {
+		os.Remove(tempFile)
+		log.Printf("❌ Failed to save bandwidth limits: %v", err)
+	}
+}
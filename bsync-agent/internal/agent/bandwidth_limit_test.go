@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimitEffective(t *testing.T) {
+	// 2030-03-08 is a Friday, 2030-03-09 a Saturday
+	limit := &BandwidthLimit{
+		MaxSendKbps: 1000,
+		MaxRecvKbps: 2000,
+		Timezone:    "Europe/Berlin",
+		RateProfile: []RateProfileRule{
+			{Name: "business hours", DaysOfWeek: []int{1, 2, 3, 4, 5}, StartTime: "08:00", EndTime: "18:00", MaxSendKbps: 250, MaxRecvKbps: 500},
+			{Name: "broken", StartTime: "noon", EndTime: "18:00", MaxSendKbps: 1, MaxRecvKbps: 1},
+			{Name: "night", StartTime: "22:00", EndTime: "06:00"},
+			{Name: "shadowed", DaysOfWeek: []int{5}, StartTime: "09:00", EndTime: "10:00", MaxSendKbps: 10, MaxRecvKbps: 10},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		at       string
+		wantSend int
+		wantRecv int
+	}{
+		{name: "first matching rule wins", at: "2030-03-08T09:30:00+01:00", wantSend: 250, wantRecv: 500},
+		{name: "business hours in the job time zone", at: "2030-03-08T07:30:00Z", wantSend: 250, wantRecv: 500},
+		{name: "evening falls back to the base limit", at: "2030-03-08T19:00:00+01:00", wantSend: 1000, wantRecv: 2000},
+		{name: "night rule means unlimited", at: "2030-03-09T02:00:00+01:00", wantSend: 0, wantRecv: 0},
+		{name: "weekend daytime", at: "2030-03-09T09:30:00+01:00", wantSend: 1000, wantRecv: 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			send, recv := limit.Effective(at)
+			if send != tt.wantSend || recv != tt.wantRecv {
+				t.Errorf("Effective(%s) = %d/%d, want %d/%d", tt.at, send, recv, tt.wantSend, tt.wantRecv)
+			}
+		})
+	}
+}
+
+func TestMinNonZero(t *testing.T) {
+	tests := []struct {
+		a, b, want int
+	}{
+		{0, 0, 0},
+		{0, 250, 250},
+		{250, 0, 250},
+		{250, 1000, 250},
+		{1000, 250, 250},
+	}
+
+	for _, tt := range tests {
+		if got := minNonZero(tt.a, tt.b); got != tt.want {
+			t.Errorf("minNonZero(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package agent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// agentCertificateHeader is set by the server when it rejects the client certificate
+const agentCertificateHeader = "X-Agent-Certificate"
+
+// tlsPaths returns the server CA, client certificate and client key files
+func (ia *IntegratedAgent) tlsPaths() (caFile, certFile, keyFile string) {
+	dataDir := ia.config.Syncthing.DataDir
+	caFile, certFile, keyFile = ia.config.TLS.CAFile, ia.config.TLS.CertFile, ia.config.TLS.KeyFile
+	if caFile == "" {
+		caFile = filepath.Join(dataDir, "ca.crt")
+	}
+	if certFile == "" {
+		certFile = filepath.Join(dataDir, "agent.crt")
+	}
+	if keyFile == "" {
+		keyFile = filepath.Join(dataDir, "agent.key")
+	}
+	return caFile, certFile, keyFile
+}
+
+// clientTLSConfig builds the TLS configuration for the server connection: the server certificate is
+// verified against the CA file when one exists, otherwise against the system roots, and the client
+// certificate is presented when issued
+func (ia *IntegratedAgent) clientTLSConfig() *tls.Config {
+	caFile, certFile, keyFile := ia.tlsPaths()
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	pool := x509.NewCertPool()
+	if caPEM, err := ioutil.ReadFile(caFile); err != nil {
+		if ia.config.TLS.CAFile != "" {
+			log.Printf("⚠️ Failed to read CA file %s: %v", caFile, err)
+		}
+		pool = nil
+	} else if !pool.AppendCertsFromPEM(caPEM) {
+		log.Printf("⚠️ No certificates found in CA file %s", caFile)
+		pool = nil
+	}
+
+	switch {
+	case pool != nil && ia.config.TLS.CAFile != "":
+		config.RootCAs = pool
+	case pool != nil:
+		// CA pinned from the server's built-in CA: verify the chain only, since agents often
+		// reach the server by an address that is not in its certificate
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificateChain(rawCerts, pool)
+		}
+	case ia.config.TLS.InsecureSkipVerify:
+		log.Printf("⚠️ Server certificate is not verified (tls.insecure_skip_verify), set tls.ca_file instead")
+		config.InsecureSkipVerify = true
+	}
+
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			log.Printf("⚠️ Failed to load client certificate %s: %v", certFile, err)
+		} else if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && time.Now().After(leaf.NotAfter) {
+			// The server would fail the handshake: connect without it and enroll again
+			log.Printf("⚠️ Client certificate %s expired on %s, not using it", certFile, leaf.NotAfter.Format("2006-01-02"))
+		} else {
+			config.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return config
+}
+
+// dropRejectedCertificate removes the client certificate and key when the server rejected them as
+// revoked or unknown, so the next connection enrolls again instead of presenting them forever
+func (ia *IntegratedAgent) dropRejectedCertificate(resp *http.Response) {
+	if resp == nil || resp.Header.Get(agentCertificateHeader) != "rejected" {
+		return
+	}
+	_, certFile, keyFile := ia.tlsPaths()
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to remove %s: %v", file, err)
+		}
+	}
+	log.Printf("🔐 Server rejected the client certificate, removed %s (set a new enrollment_token to enroll again)", certFile)
+}
+
+// handleCreateCertificateRequestMessage creates a new private key and answers with a certificate request.
+// The key is kept in a pending file until the server sends the signed certificate.
+func (ia *IntegratedAgent) handleCreateCertificateRequestMessage(msg map[string]interface{}) {
+	requestID, _ := msg["request_id"].(string)
+	_, _, keyFile := ia.tlsPaths()
+
+	csrPEM, err := createCertificateRequest(ia.agentID, keyFile+".pending")
+	if err != nil {
+		log.Printf("❌ Failed to create certificate request: %v", err)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":       "certificate_request_response",
+			"request_id": requestID,
+			"error":      fmt.Sprintf("Failed to create certificate request: %v", err),
+		})
+		return
+	}
+
+	log.Printf("🔐 Sent certificate request to server")
+	ia.sendWebSocketMessage(map[string]interface{}{
+		"type":       "certificate_request_response",
+		"request_id": requestID,
+		"csr":        string(csrPEM),
+	})
+}
+
+// createCertificateRequest writes a new ECDSA key to keyFile and returns a PEM certificate request for it
+func createCertificateRequest(commonName, keyFile string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, err
+	}
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}), nil
+}
+
+// handleInstallCertificateMessage stores the certificate issued by the server with its pending key,
+// then reconnects so the new certificate is used
+func (ia *IntegratedAgent) handleInstallCertificateMessage(msg map[string]interface{}) {
+	certPEM, _ := msg["certificate"].(string)
+	caPEM, _ := msg["ca_certificate"].(string)
+	caFile, certFile, keyFile := ia.tlsPaths()
+	pendingKeyFile := keyFile + ".pending"
+
+	keyPEM, err := ioutil.ReadFile(pendingKeyFile)
+	if err != nil {
+		log.Printf("❌ No pending key for the issued certificate: %v", err)
+		return
+	}
+	cert, err := tls.X509KeyPair([]byte(certPEM), keyPEM)
+	if err != nil {
+		log.Printf("❌ Issued certificate does not match the pending key: %v", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(certFile+".pending", []byte(certPEM), 0644); err != nil {
+		log.Printf("❌ Failed to store certificate %s: %v", certFile, err)
+		return
+	}
+	// Replace key and certificate together so they always match
+	if err := os.Rename(pendingKeyFile, keyFile); err != nil {
+		log.Printf("❌ Failed to install key %s: %v", keyFile, err)
+		return
+	}
+	if err := os.Rename(certFile+".pending", certFile); err != nil {
+		log.Printf("❌ Failed to install certificate %s: %v", certFile, err)
+		return
+	}
+
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		log.Printf("🔐 Installed client certificate %s (expires %s)", certFile, leaf.NotAfter.Format("2006-01-02"))
+	}
+
+	// Pin the server CA, unless one is configured or the server certificate was not issued by it
+	if ia.config.TLS.CAFile == "" && caPEM != "" {
+		if ia.serverCertificateSignedBy([]byte(caPEM)) {
+			if err := ioutil.WriteFile(caFile, []byte(caPEM), 0644); err != nil {
+				log.Printf("⚠️ Failed to store server CA %s: %v", caFile, err)
+			} else {
+				log.Printf("🔐 Pinned server CA in %s", caFile)
+			}
+		}
+	}
+
+	log.Printf("🔄 Reconnecting with the new client certificate")
+	if err := ia.reconnectToServer(); err != nil {
+		log.Printf("⚠️ Reconnect failed, will retry: %v", err)
+	}
+}
+
+// serverCertificateSignedBy reports whether the certificate of the current server connection verifies against caPEM
+func (ia *IntegratedAgent) serverCertificateSignedBy(caPEM []byte) bool {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return false
+	}
+
+	ia.wsMutex.Lock()
+	defer ia.wsMutex.Unlock()
+	if ia.wsConn == nil {
+		return false
+	}
+	tlsConn, ok := ia.wsConn.UnderlyingConn().(*tls.Conn)
+	if !ok {
+		return false
+	}
+
+	var rawCerts [][]byte
+	for _, cert := range tlsConn.ConnectionState().PeerCertificates {
+		rawCerts = append(rawCerts, cert.Raw)
+	}
+	return verifyCertificateChain(rawCerts, pool) == nil
+}
+
+// verifyCertificateChain verifies a server certificate chain against roots without checking the host name
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
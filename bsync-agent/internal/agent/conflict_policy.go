@@ -0,0 +1,253 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bsync-agent/internal/embedded"
+	"bsync-agent/internal/integration"
+	"bsync-agent/pkg/protocol"
+)
+
+// Conflict policy strategies
+const (
+	conflictStrategySourceWins = "source_wins" // the version of the source agent is kept
+	conflictStrategyNewest     = "newest"      // the version with the newest modification time is kept
+	conflictStrategyLargest    = "largest"     // the largest version is kept
+	conflictStrategyKeepBoth   = "keep_both"   // conflict copies are left in place, up to MaxConflicts per file
+)
+
+// conflictQueueSize is the number of conflict copies waiting for the resolver
+const conflictQueueSize = 256
+
+// ConflictPolicy is applied to every conflict copy that appears in the folder of a job.
+// MaxConflicts is set on the folder, Syncthing deletes the oldest copies of a file beyond it.
+type ConflictPolicy struct {
+	Strategy     string `json:"strategy"`
+	MaxConflicts int    `json:"max_conflicts"` // 0 = unlimited
+}
+
+// jobConflictPolicy is the policy of one job folder on this agent
+type jobConflictPolicy struct {
+	Policy   *ConflictPolicy `json:"policy"`
+	Path     string          `json:"path"`
+	IsSource bool            `json:"is_source"`
+}
+
+// conflictPolicyState is persisted so conflicts are resolved right after an agent restart
+type conflictPolicyState struct {
+	Jobs map[string]*jobConflictPolicy `json:"jobs"` // job_id -> policy
+}
+
+// parseConflictPolicy converts the conflict_policy field of a deploy_job message (null = resolved manually)
+func parseConflictPolicy(raw interface{}) (*ConflictPolicy, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var policy ConflictPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	switch policy.Strategy {
+	case conflictStrategySourceWins, conflictStrategyNewest, conflictStrategyLargest, conflictStrategyKeepBoth:
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", policy.Strategy)
+	}
+	return &policy, nil
+}
+
+// deployMaxConflicts returns the conflict copies kept per file for the folder of a deploy_job message
+func deployMaxConflicts(msg map[string]interface{}) int {
+	policy, err := parseConflictPolicy(msg["conflict_policy"])
+	if err != nil || policy == nil {
+		return 0
+	}
+	return policy.MaxConflicts
+}
+
+// setJobConflictPolicy replaces the conflict policy of a job after it was deployed;
+// deploy messages without a conflict_policy field remove it
+func (ia *IntegratedAgent) setJobConflictPolicy(jobID string, msg map[string]interface{}, isSource bool, path string) {
+	policy, err := parseConflictPolicy(msg["conflict_policy"])
+	if err != nil {
+		log.Printf("⚠️ Invalid conflict policy for job %s, conflicts are left for manual resolution: %v", jobID, err)
+	}
+
+	ia.conflictPolicyMutex.Lock()
+	if policy == nil {
+		delete(ia.conflictPolicyState.Jobs, jobID)
+	} else {
+		ia.conflictPolicyState.Jobs[jobID] = &jobConflictPolicy{Policy: policy, Path: path, IsSource: isSource}
+		log.Printf("⚔️ Job %s resolves conflicts with strategy %s (max %d copies per file, 0 = unlimited)", jobID, policy.Strategy, policy.MaxConflicts)
+	}
+	ia.conflictPolicyMutex.Unlock()
+
+	ia.saveConflictPolicyState()
+}
+
+// forgetJobConflictPolicy drops the conflict policy of a deleted job
+func (ia *IntegratedAgent) forgetJobConflictPolicy(jobID string) {
+	ia.conflictPolicyMutex.Lock()
+	delete(ia.conflictPolicyState.Jobs, jobID)
+	ia.conflictPolicyMutex.Unlock()
+	ia.saveConflictPolicyState()
+}
+
+// queueConflict hands a conflict copy found in a job folder to the resolver
+func (ia *IntegratedAgent) queueConflict(conflict integration.ConflictEvent) {
+	if !strings.HasPrefix(conflict.FolderID, "job-") {
+		return
+	}
+	select {
+	case ia.conflictQueue <- conflict:
+	default:
+		log.Printf("⚠️ Conflict resolver queue full, %s in %s is left for manual resolution", conflict.Path, conflict.FolderID)
+	}
+}
+
+// runConflictResolver applies the conflict policies to the conflict copies found in job folders
+func (ia *IntegratedAgent) runConflictResolver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ia.stopChan:
+			return
+		case conflict := <-ia.conflictQueue:
+			ia.applyConflictPolicy(conflict)
+		}
+	}
+}
+
+// applyConflictPolicy decides which version of a conflicting file is kept, applies the decision and
+// reports it to the server. The conflict copy holds the version of this agent, the file at the
+// original path the version received from another device.
+func (ia *IntegratedAgent) applyConflictPolicy(conflict integration.ConflictEvent) {
+	jobID := strings.TrimPrefix(conflict.FolderID, "job-")
+
+	ia.conflictPolicyMutex.Lock()
+	job := ia.conflictPolicyState.Jobs[jobID]
+	ia.conflictPolicyMutex.Unlock()
+	if job == nil {
+		return
+	}
+
+	copyInfo, err := os.Lstat(filepath.Join(job.Path, filepath.FromSlash(conflict.Path)))
+	if os.IsNotExist(err) {
+		// Already resolved, or removed by Syncthing beyond max_conflicts
+		return
+	}
+	originalInfo, originalErr := os.Lstat(filepath.Join(job.Path, filepath.FromSlash(conflict.OriginalPath)))
+
+	resolution, reason := embedded.ConflictKeepBoth, "conflict copies are kept"
+	switch job.Policy.Strategy {
+	case conflictStrategySourceWins:
+		if job.IsSource {
+			resolution, reason = embedded.ConflictKeepLocal, "this agent is the source"
+		} else {
+			resolution, reason = embedded.ConflictKeepRemote, "the remote version comes from the source"
+		}
+	case conflictStrategyNewest:
+		switch {
+		case err != nil:
+		case originalErr != nil || copyInfo.ModTime().After(originalInfo.ModTime()):
+			resolution, reason = embedded.ConflictKeepLocal, fmt.Sprintf("local version modified %s is newer", copyInfo.ModTime().Format(time.RFC3339))
+		default:
+			resolution, reason = embedded.ConflictKeepRemote, fmt.Sprintf("remote version modified %s is newer", originalInfo.ModTime().Format(time.RFC3339))
+		}
+	case conflictStrategyLargest:
+		switch {
+		case err != nil:
+		case originalErr != nil || copyInfo.Size() > originalInfo.Size():
+			resolution, reason = embedded.ConflictKeepLocal, fmt.Sprintf("local version (%d bytes) is larger", copyInfo.Size())
+		default:
+			resolution, reason = embedded.ConflictKeepRemote, fmt.Sprintf("remote version (%d bytes) is not smaller", originalInfo.Size())
+		}
+	}
+
+	decision := &protocol.ConflictAutoResolved{
+		Type:         protocol.TypeConflictAutoResolved,
+		JobID:        jobID,
+		Path:         conflict.Path,
+		OriginalPath: conflict.OriginalPath,
+		Strategy:     job.Policy.Strategy,
+		Resolution:   resolution,
+		Reason:       reason,
+		Size:         conflict.Size,
+		DecidedAt:    time.Now(),
+	}
+
+	if err != nil {
+		decision.Error = fmt.Sprintf("Failed to read conflict copy: %v", err)
+	} else if resolution != embedded.ConflictKeepBoth {
+		// Keeping both leaves the copy where it is, so max_conflicts still applies to it
+		if _, err := ia.syncthing.ResolveFolderConflict(conflict.FolderID, conflict.Path, resolution); err != nil {
+			decision.Error = err.Error()
+		}
+	}
+
+	if decision.Error != "" {
+		log.Printf("❌ Conflict policy %s of job %s failed for %s: %s", job.Policy.Strategy, jobID, conflict.Path, decision.Error)
+	} else {
+		log.Printf("⚔️ Conflict policy %s of job %s kept %s for %s: %s", job.Policy.Strategy, jobID, resolution, conflict.OriginalPath, reason)
+	}
+	ia.sendWebSocketMessage(protocol.ToMap(decision))
+}
+
+// loadConflictPolicyState restores the conflict policies of the deployed jobs
+func (ia *IntegratedAgent) loadConflictPolicyState() {
+	data, err := ioutil.ReadFile(ia.conflictPolicyStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read conflict policy state: %v", err)
+		}
+		return
+	}
+
+	var state conflictPolicyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Failed to parse conflict policy state (file may be corrupted), starting empty: %v", err)
+		return
+	}
+
+	ia.conflictPolicyMutex.Lock()
+	if state.Jobs != nil {
+		ia.conflictPolicyState.Jobs = state.Jobs
+	}
+	ia.conflictPolicyMutex.Unlock()
+
+	log.Printf("⚔️ Loaded conflict policies for %d job(s)", len(state.Jobs))
+}
+
+// saveConflictPolicyState writes the conflict policies to disk atomically
+func (ia *IntegratedAgent) saveConflictPolicyState() {
+	ia.conflictPolicyMutex.Lock()
+	defer ia.conflictPolicyMutex.Unlock()
+
+	data, err := json.MarshalIndent(ia.conflictPolicyState, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to marshal conflict policy state: %v", err)
+		return
+	}
+
+	tempFile := ia.conflictPolicyStateFile + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		log.Printf("❌ Failed to write conflict policy state: %v", err)
+		return
+	}
+	if err := os.Rename(tempFile, ia.conflictPolicyStateFile); err != nil {
+		os.Remove(tempFile)
+		log.Printf("❌ Failed to save conflict policy state: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+
+	"bsync-agent/pkg/protocol"
+)
+
+// handleResolveConflictMessage applies the resolution chosen on the server to a conflict copy
+// this agent reported; the rename or delete then syncs to the other devices of the job
+func (ia *IntegratedAgent) handleResolveConflictMessage(msg map[string]interface{}) {
+	var request protocol.ResolveConflict
+	if err := ia.decodeServerMessage(msg, &request); err != nil {
+		requestID, _ := msg["request_id"].(string)
+		ia.sendWebSocketMessage(protocol.ToMap(&protocol.ConflictResolveResponse{
+			Type:      protocol.TypeConflictResolveResponse,
+			RequestID: requestID,
+			Error:     err.Error(),
+		}))
+		return
+	}
+	jobID, path, resolution := request.JobID, request.Path, request.Resolution
+	folderID := fmt.Sprintf("job-%s", jobID)
+
+	log.Printf("⚔️ Resolving conflict %s in job %s: keep %s", path, jobID, resolution)
+
+	response := &protocol.ConflictResolveResponse{
+		Type:      protocol.TypeConflictResolveResponse,
+		RequestID: request.RequestID,
+		JobID:     jobID,
+		Path:      path,
+	}
+	renamedTo, err := ia.syncthing.ResolveFolderConflict(folderID, path, resolution)
+	if err != nil {
+		log.Printf("❌ Failed to resolve conflict %s in job %s: %v", path, jobID, err)
+		response.Error = fmt.Sprintf("Failed to resolve conflict: %v", err)
+		ia.sendWebSocketMessage(protocol.ToMap(response))
+		return
+	}
+
+	response.Resolution = resolution
+	response.RenamedTo = renamedTo
+	ia.sendWebSocketMessage(protocol.ToMap(response))
+}
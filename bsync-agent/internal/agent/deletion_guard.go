@@ -0,0 +1,343 @@
+package agent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"bsync-agent/pkg/protocol"
+)
+
+// pauseReasonDeletionGuard marks the pause of a job held by the deletion guard. A resume with
+// this reason is the confirmation of the deletions by an administrator and releases the hold.
+const pauseReasonDeletionGuard = "deletion_guard"
+
+const (
+	// deletionGuardWindow is the period in which deletions on the source folder are added up, so a
+	// wipe spread over several scans (e.g. reported by the file watcher in batches) is still caught
+	deletionGuardWindow = 5 * time.Minute
+
+	// deletionGuardPercentMinFiles keeps the percentage threshold from firing on tiny folders
+	deletionGuardPercentMinFiles = 10
+
+	// deletionGuardSamplePaths is the number of deleted paths reported to the server
+	deletionGuardSamplePaths = 20
+
+	// deletionGuardTotalRefresh bounds how often the file count of the folder is read while counting
+	deletionGuardTotalRefresh = 5 * time.Second
+)
+
+// DeletionGuardPolicy is the number of deleted files, or the share of the folder, above which the
+// source agent pauses a job until the deletions are confirmed. 0 disables a threshold.
+type DeletionGuardPolicy struct {
+	MaxFiles   int64   `json:"max_files"`
+	MaxPercent float64 `json:"max_percent"`
+}
+
+// deletionHold records a job paused by the deletion guard
+type deletionHold struct {
+	Since        time.Time `json:"since"`
+	DeletedFiles int64     `json:"deleted_files"`
+	TotalFiles   int64     `json:"total_files"`
+	SamplePaths  []string  `json:"sample_paths,omitempty"`
+}
+
+// deletionGuardState is persisted so a hold survives an agent restart
+type deletionGuardState struct {
+	Jobs  map[string]*DeletionGuardPolicy `json:"jobs"`  // job_id -> policy, source folders only
+	Holds map[string]*deletionHold        `json:"holds"` // job_id -> paused until confirmed
+}
+
+// deletionCounter adds up the deletions of a source folder in the current window
+type deletionCounter struct {
+	Start       time.Time
+	Deleted     int64
+	SamplePaths []string
+	LocalFiles  int64 // files left in the folder, read at most every deletionGuardTotalRefresh
+	LocalAt     time.Time
+
+	// Confirmed deletions are let through until the folder is idle again after the resume
+	Confirmed bool
+	Busy      bool
+}
+
+// parseDeletionGuardPolicy converts the deletion_guard field of a deploy_job message (null = no guard)
+func parseDeletionGuardPolicy(raw interface{}) (*DeletionGuardPolicy, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var policy DeletionGuardPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	if policy.MaxFiles <= 0 && policy.MaxPercent <= 0 {
+		return nil, nil
+	}
+	return &policy, nil
+}
+
+// exceededBy reports whether deleted files in the current window exceed the policy. localFiles
+// is the number of files left in the folder, the percentage is only checked when it is known.
+func (p *DeletionGuardPolicy) exceededBy(deleted, localFiles int64, known bool) bool {
+	if p.MaxFiles > 0 && deleted > p.MaxFiles {
+		return true
+	}
+	// The folder held the files left plus the ones deleted in this window
+	return p.MaxPercent > 0 && deleted >= deletionGuardPercentMinFiles && known &&
+		float64(deleted)*100/float64(localFiles+deleted) > p.MaxPercent
+}
+
+// countDeletion adds a deleted path to the counter of the current window, starting a new
+// window when the one of counter is over
+func countDeletion(counter *deletionCounter, path string, now time.Time) *deletionCounter {
+	if counter == nil || now.Sub(counter.Start) > deletionGuardWindow {
+		counter = &deletionCounter{Start: now}
+	}
+	counter.Deleted++
+	if len(counter.SamplePaths) < deletionGuardSamplePaths {
+		counter.SamplePaths = append(counter.SamplePaths, path)
+	}
+	return counter
+}
+
+// setJobDeletionGuard replaces the deletion guard of a job. Only source folders count deletions;
+// deploy messages without a deletion_guard field remove the guard but keep an active hold.
+func (ia *IntegratedAgent) setJobDeletionGuard(jobID string, msg map[string]interface{}, isSource bool) {
+	var policy *DeletionGuardPolicy
+	if isSource {
+		var err error
+		if policy, err = parseDeletionGuardPolicy(msg["deletion_guard"]); err != nil {
+			log.Printf("⚠️ Invalid deletion guard for job %s, guard disabled: %v", jobID, err)
+		}
+	}
+
+	ia.deletionGuardMutex.Lock()
+	if policy == nil {
+		delete(ia.deletionGuardState.Jobs, jobID)
+	} else {
+		ia.deletionGuardState.Jobs[jobID] = policy
+		log.Printf("🛡️ Job %s pauses on more than %d deleted files / %.1f%% of the folder", jobID, policy.MaxFiles, policy.MaxPercent)
+	}
+	delete(ia.deletionCounters, jobID)
+	ia.deletionGuardMutex.Unlock()
+
+	ia.saveDeletionGuardState()
+}
+
+// forgetJobDeletionGuard drops all deletion guard state of a deleted job
+func (ia *IntegratedAgent) forgetJobDeletionGuard(jobID string) {
+	ia.deletionGuardMutex.Lock()
+	delete(ia.deletionGuardState.Jobs, jobID)
+	delete(ia.deletionGuardState.Holds, jobID)
+	delete(ia.deletionCounters, jobID)
+	ia.deletionGuardMutex.Unlock()
+	ia.saveDeletionGuardState()
+}
+
+// deletionGuardHolds reports whether a job folder is paused until its deletions are confirmed
+func (ia *IntegratedAgent) deletionGuardHolds(jobID string) bool {
+	ia.deletionGuardMutex.Lock()
+	defer ia.deletionGuardMutex.Unlock()
+	_, held := ia.deletionGuardState.Holds[jobID]
+	return held
+}
+
+// holdForDeletionGuard records a hold on a folder the server paused because the deletion guard
+// fired on another agent of the job (destinations keep their files until the confirmation)
+func (ia *IntegratedAgent) holdForDeletionGuard(jobID string) {
+	ia.deletionGuardMutex.Lock()
+	_, held := ia.deletionGuardState.Holds[jobID]
+	if !held {
+		ia.deletionGuardState.Holds[jobID] = &deletionHold{Since: time.Now()}
+	}
+	ia.deletionGuardMutex.Unlock()
+
+	if !held {
+		ia.saveDeletionGuardState()
+	}
+}
+
+// releaseDeletionGuardHold forgets the hold of a job after the deletions were confirmed. Further
+// deletions on the source are let through until the folder has finished scanning.
+func (ia *IntegratedAgent) releaseDeletionGuardHold(jobID string) {
+	ia.deletionGuardMutex.Lock()
+	_, held := ia.deletionGuardState.Holds[jobID]
+	delete(ia.deletionGuardState.Holds, jobID)
+	if _, guarded := ia.deletionGuardState.Jobs[jobID]; guarded {
+		ia.deletionCounters[jobID] = &deletionCounter{Start: time.Now(), Confirmed: true}
+	}
+	ia.deletionGuardMutex.Unlock()
+
+	if held {
+		log.Printf("🛡️ Deletions of job %s confirmed, hold released", jobID)
+		ia.saveDeletionGuardState()
+	}
+}
+
+// noteDeletionGuardFolderState ends the pass given to confirmed deletions once the folder
+// went through a scan or sync and is idle again
+func (ia *IntegratedAgent) noteDeletionGuardFolderState(jobID, state string) {
+	ia.deletionGuardMutex.Lock()
+	defer ia.deletionGuardMutex.Unlock()
+
+	counter := ia.deletionCounters[jobID]
+	if counter == nil || !counter.Confirmed {
+		return
+	}
+	switch state {
+	case "scanning", "syncing":
+		counter.Busy = true
+	case "idle":
+		if counter.Busy {
+			delete(ia.deletionCounters, jobID)
+		}
+	}
+}
+
+// countLocalDeletion adds a file deleted on a guarded source folder (local_change_detected event)
+// to the current window and pauses the job when the deletions exceed the policy
+func (ia *IntegratedAgent) countLocalDeletion(data map[string]interface{}) {
+	folderID, _ := data["folder_id"].(string)
+	action, _ := data["action"].(string)
+	itemType, _ := data["item_type"].(string)
+	if action != "deleted" || itemType == "dir" || !strings.HasPrefix(folderID, "job-") {
+		return
+	}
+	jobID := strings.TrimPrefix(folderID, "job-")
+	path, _ := data["path"].(string)
+	now := time.Now()
+
+	ia.deletionGuardMutex.Lock()
+	policy := ia.deletionGuardState.Jobs[jobID]
+	_, held := ia.deletionGuardState.Holds[jobID]
+	counter := ia.deletionCounters[jobID]
+	if policy == nil || held || (counter != nil && counter.Confirmed) {
+		ia.deletionGuardMutex.Unlock()
+		return
+	}
+	counter = countDeletion(counter, path, now)
+	ia.deletionCounters[jobID] = counter
+	deleted := counter.Deleted
+	localFiles := counter.LocalFiles
+	known := !counter.LocalAt.IsZero()
+	refresh := policy.MaxPercent > 0 && deleted >= deletionGuardPercentMinFiles && now.Sub(counter.LocalAt) > deletionGuardTotalRefresh
+	ia.deletionGuardMutex.Unlock()
+
+	readLocalFiles := func() {
+		if status, err := ia.syncthing.GetFolderStatus(folderID); err == nil {
+			localFiles, known = status.LocalFiles, true
+			ia.deletionGuardMutex.Lock()
+			counter.LocalFiles = localFiles
+			counter.LocalAt = now
+			ia.deletionGuardMutex.Unlock()
+		}
+	}
+	if refresh {
+		readLocalFiles()
+	}
+
+	if !policy.exceededBy(deleted, localFiles, known) {
+		return
+	}
+	if !known {
+		readLocalFiles()
+	}
+	total := localFiles + deleted
+
+	ia.deletionGuardMutex.Lock()
+	if _, held := ia.deletionGuardState.Holds[jobID]; held {
+		ia.deletionGuardMutex.Unlock()
+		return
+	}
+	hold := &deletionHold{
+		Since:        now,
+		DeletedFiles: deleted,
+		TotalFiles:   total,
+		SamplePaths:  append([]string(nil), counter.SamplePaths...),
+	}
+	ia.deletionGuardState.Holds[jobID] = hold
+	delete(ia.deletionCounters, jobID)
+	ia.deletionGuardMutex.Unlock()
+
+	ia.triggerDeletionGuard(jobID, folderID, hold)
+}
+
+// triggerDeletionGuard pauses the source folder so the deletions stop propagating and asks the
+// server to pause the destinations and wait for a confirmation
+func (ia *IntegratedAgent) triggerDeletionGuard(jobID, folderID string, hold *deletionHold) {
+	if err := ia.syncthing.PauseFolder(folderID); err != nil {
+		log.Printf("❌ Failed to pause folder %s after %d deletions: %v", folderID, hold.DeletedFiles, err)
+	} else {
+		ia.noteJobPaused(jobID, pauseReasonDeletionGuard)
+		log.Printf("🛡️ Folder %s paused: %d of %d files deleted within %v, waiting for confirmation",
+			folderID, hold.DeletedFiles, hold.TotalFiles, deletionGuardWindow)
+	}
+	ia.saveDeletionGuardState()
+
+	ia.sendWebSocketMessage(protocol.ToMap(&protocol.DeletionGuardTriggered{
+		Type:         protocol.TypeDeletionGuardTriggered,
+		JobID:        jobID,
+		FolderID:     folderID,
+		DeletedFiles: hold.DeletedFiles,
+		TotalFiles:   hold.TotalFiles,
+		SamplePaths:  hold.SamplePaths,
+		Since:        hold.Since,
+	}))
+}
+
+// loadDeletionGuardState restores guards and holds from disk
+func (ia *IntegratedAgent) loadDeletionGuardState() {
+	data, err := ioutil.ReadFile(ia.deletionGuardStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read deletion guard state: %v", err)
+		}
+		return
+	}
+
+	var state deletionGuardState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Failed to parse deletion guard state (file may be corrupted), starting empty: %v", err)
+		return
+	}
+
+	ia.deletionGuardMutex.Lock()
+	if state.Jobs != nil {
+		ia.deletionGuardState.Jobs = state.Jobs
+	}
+	if state.Holds != nil {
+		ia.deletionGuardState.Holds = state.Holds
+	}
+	ia.deletionGuardMutex.Unlock()
+
+	log.Printf("🛡️ Loaded deletion guards for %d job(s), %d held", len(state.Jobs), len(state.Holds))
+}
+
+// saveDeletionGuardState writes guards and holds to disk atomically
+func (ia *IntegratedAgent) saveDeletionGuardState() {
+	ia.deletionGuardMutex.Lock()
+	defer ia.deletionGuardMutex.Unlock()
+
+	data, err := json.MarshalIndent(ia.deletionGuardState, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to marshal deletion guard state: %v", err)
+		return
+	}
+
+	tempFile := ia.deletionGuardStateFile + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		log.Printf("❌ Failed to write deletion guard state: %v", err)
+		return
+	}
+	if err := os.Rename(tempFile, ia.deletionGuardStateFile); err != nil {
+		os.Remove(tempFile)
+		log.Printf("❌ Failed to save deletion guard state: %v", err)
+	}
+}
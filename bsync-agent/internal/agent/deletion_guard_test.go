@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeletionGuardPolicyExceededBy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     DeletionGuardPolicy
+		deleted    int64
+		localFiles int64
+		known      bool
+		want       bool
+	}{
+		{name: "at the file limit", policy: DeletionGuardPolicy{MaxFiles: 100}, deleted: 100, want: false},
+		{name: "above the file limit", policy: DeletionGuardPolicy{MaxFiles: 100}, deleted: 101, want: true},
+		{name: "file limit without the folder size", policy: DeletionGuardPolicy{MaxFiles: 100}, deleted: 101, known: false, want: true},
+		{name: "at the percentage", policy: DeletionGuardPolicy{MaxPercent: 50}, deleted: 50, localFiles: 50, known: true, want: false},
+		{name: "above the percentage", policy: DeletionGuardPolicy{MaxPercent: 50}, deleted: 51, localFiles: 49, known: true, want: true},
+		{name: "percentage of an unknown folder", policy: DeletionGuardPolicy{MaxPercent: 50}, deleted: 51, known: false, want: false},
+		{name: "percentage of a tiny folder", policy: DeletionGuardPolicy{MaxPercent: 50}, deleted: deletionGuardPercentMinFiles - 1, known: true, want: false},
+		{name: "whole small folder", policy: DeletionGuardPolicy{MaxPercent: 50}, deleted: deletionGuardPercentMinFiles, known: true, want: true},
+		{name: "percentage fires before the file limit", policy: DeletionGuardPolicy{MaxFiles: 1000, MaxPercent: 10}, deleted: 20, localFiles: 80, known: true, want: true},
+		{name: "file limit fires before the percentage", policy: DeletionGuardPolicy{MaxFiles: 10, MaxPercent: 90}, deleted: 11, localFiles: 1000, known: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.exceededBy(tt.deleted, tt.localFiles, tt.known); got != tt.want {
+				t.Errorf("exceededBy(%d, %d, %v) = %v, want %v", tt.deleted, tt.localFiles, tt.known, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountDeletion(t *testing.T) {
+	start := time.Date(2030, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		offsets     []time.Duration // time of each deletion after start
+		wantDeleted int64
+		wantStart   time.Time
+	}{
+		{name: "first deletion", offsets: []time.Duration{0}, wantDeleted: 1, wantStart: start},
+		{name: "within the window", offsets: []time.Duration{0, time.Minute, deletionGuardWindow}, wantDeleted: 3, wantStart: start},
+		{name: "after the window", offsets: []time.Duration{0, time.Minute, deletionGuardWindow + time.Second}, wantDeleted: 1, wantStart: start.Add(deletionGuardWindow + time.Second)},
+		{name: "window starts at the first deletion", offsets: []time.Duration{0, deletionGuardWindow + time.Second, 2 * deletionGuardWindow}, wantDeleted: 2, wantStart: start.Add(deletionGuardWindow + time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var counter *deletionCounter
+			for i, offset := range tt.offsets {
+				counter = countDeletion(counter, fmt.Sprintf("file-%d", i), start.Add(offset))
+			}
+			if counter.Deleted != tt.wantDeleted || !counter.Start.Equal(tt.wantStart) {
+				t.Errorf("counter = %d deletions since %v, want %d since %v", counter.Deleted, counter.Start, tt.wantDeleted, tt.wantStart)
+			}
+			if int64(len(counter.SamplePaths)) != counter.Deleted {
+				t.Errorf("sample paths = %v, want one per deletion", counter.SamplePaths)
+			}
+		})
+	}
+
+	t.Run("sample paths are capped", func(t *testing.T) {
+		var counter *deletionCounter
+		for i := 0; i < deletionGuardSamplePaths+5; i++ {
+			counter = countDeletion(counter, fmt.Sprintf("file-%d", i), start)
+		}
+		if counter.Deleted != deletionGuardSamplePaths+5 || len(counter.SamplePaths) != deletionGuardSamplePaths {
+			t.Errorf("counter = %d deletions, %d sample paths", counter.Deleted, len(counter.SamplePaths))
+		}
+	})
+}
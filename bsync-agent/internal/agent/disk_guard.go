@@ -0,0 +1,428 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"bsync-agent/pkg/protocol"
+)
+
+const (
+	// diskGuardCheckInterval is how often destination folders are checked against their minimum free space
+	diskGuardCheckInterval = 1 * time.Minute
+
+	// diskGuardResumeMargin is the extra free space, as a fraction of the threshold, required before a
+	// paused folder is resumed so a folder hovering around the threshold is not paused and resumed every check
+	diskGuardResumeMargin = 0.1
+
+	// folderSizeTimeout bounds walking a folder that is not deployed yet to measure its size
+	folderSizeTimeout = 20 * time.Second
+)
+
+// DiskGuardPolicy is the minimum free space kept on the filesystem of a destination folder.
+// The larger of both thresholds applies; the folder is paused while free space is below it.
+type DiskGuardPolicy struct {
+	MinFreeMB      int64   `json:"min_free_mb"`
+	MinFreePercent float64 `json:"min_free_percent"`
+}
+
+// thresholdBytes returns the minimum free space in bytes on a filesystem of the given size
+func (p *DiskGuardPolicy) thresholdBytes(totalBytes int64) int64 {
+	threshold := p.MinFreeMB * 1024 * 1024
+	if byPercent := int64(float64(totalBytes) * p.MinFreePercent / 100); byPercent > threshold {
+		threshold = byPercent
+	}
+	return threshold
+}
+
+// jobDiskGuard is the guard of one destination folder
+type jobDiskGuard struct {
+	Policy *DiskGuardPolicy `json:"policy"`
+	Path   string           `json:"path"`
+}
+
+// diskGuardPause records a folder paused because of low disk space
+type diskGuardPause struct {
+	Since          time.Time `json:"since"`
+	FreeBytes      int64     `json:"free_bytes"`
+	TotalBytes     int64     `json:"total_bytes"`
+	ThresholdBytes int64     `json:"threshold_bytes"`
+}
+
+// diskGuardState is persisted so disk guard pauses survive an agent restart
+type diskGuardState struct {
+	Jobs   map[string]*jobDiskGuard   `json:"jobs"`   // job_id -> guard
+	Paused map[string]*diskGuardPause `json:"paused"` // job_id -> paused by the guard
+}
+
+// parseDiskGuardPolicy converts the disk_guard field of a deploy_job message (null = no guard)
+func parseDiskGuardPolicy(raw interface{}) (*DiskGuardPolicy, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var policy DiskGuardPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	if policy.MinFreeMB <= 0 && policy.MinFreePercent <= 0 {
+		return nil, nil
+	}
+	return &policy, nil
+}
+
+// setJobDiskGuard replaces the disk guard of a job. Only destination folders are guarded;
+// deploy messages without a disk_guard field remove the guard.
+func (ia *IntegratedAgent) setJobDiskGuard(jobID string, msg map[string]interface{}, isDestination bool, path string) {
+	var policy *DiskGuardPolicy
+	if isDestination {
+		var err error
+		if policy, err = parseDiskGuardPolicy(msg["disk_guard"]); err != nil {
+			log.Printf("⚠️ Invalid disk guard for job %s, guard disabled: %v", jobID, err)
+		}
+	}
+
+	ia.diskGuardMutex.Lock()
+	if policy == nil {
+		delete(ia.diskGuardState.Jobs, jobID)
+	} else {
+		ia.diskGuardState.Jobs[jobID] = &jobDiskGuard{Policy: policy, Path: path}
+		log.Printf("💾 Job %s keeps at least %d MB / %.1f%% free on %s", jobID, policy.MinFreeMB, policy.MinFreePercent, path)
+	}
+	ia.diskGuardMutex.Unlock()
+
+	ia.saveDiskGuardState()
+}
+
+// forgetJobDiskGuard drops all disk guard state of a deleted job
+func (ia *IntegratedAgent) forgetJobDiskGuard(jobID string) {
+	ia.diskGuardMutex.Lock()
+	delete(ia.diskGuardState.Jobs, jobID)
+	delete(ia.diskGuardState.Paused, jobID)
+	ia.diskGuardMutex.Unlock()
+	ia.saveDiskGuardState()
+}
+
+// diskGuardHolds reports whether a job folder is paused because of low disk space
+func (ia *IntegratedAgent) diskGuardHolds(jobID string) bool {
+	ia.diskGuardMutex.Lock()
+	defer ia.diskGuardMutex.Unlock()
+	_, paused := ia.diskGuardState.Paused[jobID]
+	return paused
+}
+
+// clearDiskGuardPause forgets a disk guard pause after an operator resumed the job.
+// The next check pauses the folder again if free space is still below the threshold.
+func (ia *IntegratedAgent) clearDiskGuardPause(jobID string) {
+	ia.diskGuardMutex.Lock()
+	_, paused := ia.diskGuardState.Paused[jobID]
+	delete(ia.diskGuardState.Paused, jobID)
+	ia.diskGuardMutex.Unlock()
+
+	if paused {
+		ia.saveDiskGuardState()
+	}
+}
+
+// runDiskGuard checks the free space of guarded folders periodically
+func (ia *IntegratedAgent) runDiskGuard(ctx context.Context) {
+	ia.applyDiskGuard()
+
+	ticker := time.NewTicker(diskGuardCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ia.stopChan:
+			return
+		case <-ticker.C:
+			ia.applyDiskGuard()
+		}
+	}
+}
+
+// applyDiskGuard pauses guarded folders whose filesystem has less free space than their threshold
+// and resumes folders it paused once enough space is available again. Folders still paused by a
+// maintenance window or an operator stay paused. Paused folders are reported on every check so the
+// server catches up after a reconnect; it only notifies on changes.
+func (ia *IntegratedAgent) applyDiskGuard() {
+	type guardCheck struct {
+		jobID  string
+		guard  *jobDiskGuard
+		paused *diskGuardPause
+	}
+
+	var checks []guardCheck
+	ia.diskGuardMutex.Lock()
+	for jobID, guard := range ia.diskGuardState.Jobs {
+		checks = append(checks, guardCheck{jobID: jobID, guard: guard, paused: ia.diskGuardState.Paused[jobID]})
+	}
+	// Jobs whose guard was removed must not stay paused by it
+	for jobID, paused := range ia.diskGuardState.Paused {
+		if _, guarded := ia.diskGuardState.Jobs[jobID]; !guarded {
+			checks = append(checks, guardCheck{jobID: jobID, paused: paused})
+		}
+	}
+	ia.diskGuardMutex.Unlock()
+
+	changed := false
+	for _, check := range checks {
+		folderID := fmt.Sprintf("job-%s", check.jobID)
+
+		if check.guard == nil {
+			ia.releaseDiskGuardPause(check.jobID, folderID, "", check.paused)
+			changed = true
+			continue
+		}
+
+		total, free, _, err := diskSpace(existingParent(check.guard.Path))
+		if err != nil {
+			log.Printf("⚠️ Failed to check free space of folder %s (%s): %v", folderID, check.guard.Path, err)
+			continue
+		}
+		threshold := check.guard.Policy.thresholdBytes(total)
+		report := &diskGuardPause{FreeBytes: free, TotalBytes: total, ThresholdBytes: threshold}
+
+		switch {
+		case check.paused == nil && free < threshold:
+			if err := ia.syncthing.PauseFolder(folderID); err != nil {
+				log.Printf("❌ Failed to pause folder %s on low disk space: %v", folderID, err)
+				continue
+			}
+			report.Since = time.Now()
+			ia.diskGuardMutex.Lock()
+			ia.diskGuardState.Paused[check.jobID] = report
+			ia.diskGuardMutex.Unlock()
+			changed = true
+
+			log.Printf("⏸️ Folder %s paused: %d bytes free on %s, minimum is %d", folderID, free, check.guard.Path, threshold)
+			ia.sendDiskSpaceReport("disk_space_low", check.jobID, check.guard.Path, report)
+
+		case check.paused != nil && free >= threshold+int64(float64(threshold)*diskGuardResumeMargin):
+			ia.releaseDiskGuardPause(check.jobID, folderID, check.guard.Path, report)
+			changed = true
+
+		case check.paused != nil:
+			report.Since = check.paused.Since
+			ia.sendDiskSpaceReport("disk_space_low", check.jobID, check.guard.Path, report)
+		}
+	}
+
+	if changed {
+		ia.saveDiskGuardState()
+	}
+}
+
+// releaseDiskGuardPause resumes a folder paused by the disk guard unless a maintenance window
+// or an operator keeps it paused, then reports the recovery
+func (ia *IntegratedAgent) releaseDiskGuardPause(jobID, folderID, path string, report *diskGuardPause) {
+	ia.windowMutex.Lock()
+	heldByWindow := ia.windowState.WindowPaused[jobID] || ia.windowState.ManuallyPaused[jobID]
+	ia.windowMutex.Unlock()
+
+	if !heldByWindow {
+		if err := ia.syncthing.ResumeFolder(folderID); err != nil {
+			log.Printf("❌ Failed to resume folder %s after disk space recovered: %v", folderID, err)
+			return
+		}
+		log.Printf("▶️ Folder %s resumed, disk space recovered", folderID)
+	} else {
+		log.Printf("💾 Disk space recovered for folder %s, still paused by a maintenance window or an operator", folderID)
+	}
+
+	ia.diskGuardMutex.Lock()
+	delete(ia.diskGuardState.Paused, jobID)
+	ia.diskGuardMutex.Unlock()
+
+	ia.sendDiskSpaceReport("disk_space_recovered", jobID, path, report)
+}
+
+// sendDiskSpaceReport tells the server about a disk guard pause (disk_space_low) or its end (disk_space_recovered)
+func (ia *IntegratedAgent) sendDiskSpaceReport(msgType, jobID, path string, report *diskGuardPause) {
+	msg := &protocol.DiskSpaceReport{
+		Type:     msgType,
+		JobID:    jobID,
+		FolderID: fmt.Sprintf("job-%s", jobID),
+		Path:     path,
+	}
+	if report != nil {
+		msg.FreeBytes = report.FreeBytes
+		msg.TotalBytes = report.TotalBytes
+		msg.ThresholdBytes = report.ThresholdBytes
+		if !report.Since.IsZero() {
+			since := report.Since
+			msg.Since = &since
+		}
+	}
+	ia.sendWebSocketMessage(protocol.ToMap(msg))
+}
+
+// handleCheckDiskSpaceMessage reports the free space at a path before a job is deployed there and,
+// when measure_size is set, the size of the data at the path (the job folder totals if it is deployed)
+func (ia *IntegratedAgent) handleCheckDiskSpaceMessage(msg map[string]interface{}) {
+	var request protocol.CheckDiskSpace
+	if err := ia.decodeServerMessage(msg, &request); err != nil {
+		requestID, _ := msg["request_id"].(string)
+		ia.sendWebSocketMessage(protocol.ToMap(&protocol.DiskSpaceResponse{
+			Type:      protocol.TypeDiskSpaceResponse,
+			RequestID: requestID,
+			Error:     err.Error(),
+		}))
+		return
+	}
+	path := request.Path
+
+	response := &protocol.DiskSpaceResponse{
+		Type:      protocol.TypeDiskSpaceResponse,
+		RequestID: request.RequestID,
+		JobID:     request.JobID,
+		Path:      path,
+	}
+
+	total, free, used, err := diskSpace(existingParent(path))
+	if err != nil {
+		log.Printf("❌ Failed to check disk space of %s: %v", path, err)
+		response.Error = fmt.Sprintf("Failed to check disk space: %v", err)
+		ia.sendWebSocketMessage(protocol.ToMap(response))
+		return
+	}
+	response.TotalBytes = total
+	response.FreeBytes = free
+	response.UsedBytes = used
+
+	// Data the job folder already holds here does not need space again
+	measureSize := request.MeasureSize
+	if request.JobID != "" {
+		if status, err := ia.syncthing.GetFolderStatus(fmt.Sprintf("job-%s", request.JobID)); err == nil && status.Path == path {
+			response.FolderBytes = status.LocalBytes
+			if measureSize {
+				response.SizeBytes = status.GlobalBytes
+				response.SizeComplete = true
+				measureSize = false
+			}
+		}
+	}
+
+	if measureSize {
+		size, complete, err := folderSize(path, folderSizeTimeout)
+		if err != nil {
+			log.Printf("❌ Failed to measure size of %s: %v", path, err)
+			response.Error = fmt.Sprintf("Failed to measure folder size: %v", err)
+			ia.sendWebSocketMessage(protocol.ToMap(response))
+			return
+		}
+		response.SizeBytes = size
+		response.SizeComplete = complete
+	}
+
+	log.Printf("💾 Disk space of %s: %d of %d bytes free", path, free, total)
+	ia.sendWebSocketMessage(protocol.ToMap(response))
+}
+
+// folderSize sums the size of the regular files below path. It stops at the timeout and
+// reports complete = false, the size is then a lower bound.
+func folderSize(path string, timeout time.Duration) (size int64, complete bool, err error) {
+	deadline := time.Now().Add(timeout)
+	errTimeout := fmt.Errorf("timeout")
+
+	err = filepath.Walk(path, func(_ string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			// Unreadable entries are skipped, the size is a best effort
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errTimeout
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err == errTimeout {
+		return size, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
+// existingParent returns path or its closest existing parent, so free space can be
+// checked for a destination folder that is not created yet
+func existingParent(path string) string {
+	current := filepath.Clean(path)
+	for {
+		if _, err := os.Stat(current); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return current
+		}
+		current = parent
+	}
+}
+
+// loadDiskGuardState restores guards and pauses from disk
+func (ia *IntegratedAgent) loadDiskGuardState() {
+	data, err := ioutil.ReadFile(ia.diskGuardStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read disk guard state: %v", err)
+		}
+		return
+	}
+
+	var state diskGuardState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Failed to parse disk guard state (file may be corrupted), starting empty: %v", err)
+		return
+	}
+
+	ia.diskGuardMutex.Lock()
+	if state.Jobs != nil {
+		ia.diskGuardState.Jobs = state.Jobs
+	}
+	if state.Paused != nil {
+		ia.diskGuardState.Paused = state.Paused
+	}
+	ia.diskGuardMutex.Unlock()
+
+	log.Printf("💾 Loaded disk guards for %d job(s), %d paused", len(state.Jobs), len(state.Paused))
+}
+
+// saveDiskGuardState writes guards and pauses to disk atomically
+func (ia *IntegratedAgent) saveDiskGuardState() {
+	ia.diskGuardMutex.Lock()
+	defer ia.diskGuardMutex.Unlock()
+
+	data, err := json.MarshalIndent(ia.diskGuardState, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to marshal disk guard state: %v", err)
+		return
+	}
+
+	tempFile := ia.diskGuardStateFile + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		log.Printf("❌ Failed to write disk guard state: %v", err)
+		return
+	}
+	if err := os.Rename(tempFile, ia.diskGuardStateFile); err != nil {
+		os.Remove(tempFile)
+		log.Printf("❌ Failed to save disk guard state: %v", err)
+	}
+}
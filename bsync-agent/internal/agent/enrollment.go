@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Headers used on the WebSocket handshake with the server
+const (
+	agentCredentialHeader = "X-Agent-Credential"
+	enrollmentTokenHeader = "X-Enrollment-Token"
+)
+
+// authHeaders returns the handshake headers: the stored credential if the agent is enrolled,
+// plus the configured enrollment token so a revoked agent can enroll again
+func (ia *IntegratedAgent) authHeaders() http.Header {
+	header := http.Header{}
+	if credential := ia.loadCredential(); credential != "" {
+		header.Set(agentCredentialHeader, credential)
+	}
+	if ia.config.EnrollmentToken != "" {
+		header.Set(enrollmentTokenHeader, ia.config.EnrollmentToken)
+	}
+	return header
+}
+
+// loadCredential reads the credential issued by the server, empty if not enrolled yet
+func (ia *IntegratedAgent) loadCredential() string {
+	data, err := ioutil.ReadFile(ia.credentialFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read agent credential %s: %v", ia.credentialFile, err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// storeIssuedCredential saves the credential the server returns when an enrollment token was exchanged
+func (ia *IntegratedAgent) storeIssuedCredential(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	credential := resp.Header.Get(agentCredentialHeader)
+	if credential == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ia.credentialFile), 0755); err != nil {
+		log.Printf("❌ Failed to create directory for agent credential: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(ia.credentialFile, []byte(credential+"\n"), 0600); err != nil {
+		log.Printf("❌ Failed to store agent credential %s: %v (the agent will need a new enrollment token)", ia.credentialFile, err)
+		return
+	}
+
+	log.Printf("🔑 Enrolled with server, credential stored at %s", ia.credentialFile)
+	if ia.config.EnrollmentToken != "" {
+		log.Printf("🔑 The enrollment token is no longer needed and can be removed from the configuration")
+	}
+}
+
+// readHandshakeError returns the reason the server gave for rejecting the handshake
+func readHandshakeError(resp *http.Response) string {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || len(body) == 0 {
+		return resp.Status
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"bsync-agent/internal/embedded"
+)
+
+// VersioningConfig lets the administrator of an agent allow the "external" versioning type, which
+// runs a command defined on the server for every replaced or deleted file
+type VersioningConfig struct {
+	AllowExternal bool `yaml:"allow_external"`
+}
+
+// parseVersioningPolicy converts the versioning field of a deploy_job message (null = no versioning).
+// An external policy is replaced by trashcan versioning unless the agent allows external versioning
+// commands; warning explains the replacement for the deploy result.
+func parseVersioningPolicy(raw interface{}, allowExternal bool) (policy *embedded.VersioningPolicy, warning string) {
+	if raw == nil {
+		return nil, ""
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, ""
+	}
+	policy = &embedded.VersioningPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		log.Printf("⚠️ Invalid versioning policy, versioning disabled: %v", err)
+		return nil, fmt.Sprintf("invalid versioning policy, versioning disabled: %v", err)
+	}
+	if policy.Type == "" || policy.Type == "none" {
+		return nil, ""
+	}
+	if policy.Type == "external" && !allowExternal {
+		// Keep the replaced and deleted files the command would have handled
+		warning = "external versioning is disabled on this agent (versioning.allow_external: false), using trashcan versioning instead"
+		log.Printf("⚠️ %s", warning)
+		return &embedded.VersioningPolicy{Type: "trashcan"}, warning
+	}
+	return policy, ""
+}
+
+// handleListFileVersionsMessage lists archived file versions of a job folder for the server
+func (ia *IntegratedAgent) handleListFileVersionsMessage(msg map[string]interface{}) {
+	requestID, _ := msg["request_id"].(string)
+	jobID, _ := msg["job_id"].(string)
+	prefix, _ := msg["path"].(string)
+	folderID := fmt.Sprintf("job-%s", jobID)
+
+	log.Printf("🗂️ Listing file versions of job %s (path prefix %q)", jobID, prefix)
+
+	versions, err := ia.syncthing.GetFolderVersions(folderID)
+	if err != nil {
+		log.Printf("❌ Failed to list file versions of job %s: %v", jobID, err)
+		ia.sendWebSocketMessage(map[string]interface{}{
+			"type":       "file_versions_response",
+			"request_id": requestID,
+			"job_id":     jobID,
+			"error":      fmt.Sprintf("Failed to list file versions: %v", err),
+		})
+		return
+	}
+
+	files := make([]map[string]interface{}, 0, len(versions))
+	for path, fileVersions := range versions {
+		if prefix != "" && !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		// Newest version first
+		sort.Slice(fileVersions, func(i, j int) bool {
+			return fileVersions[i].VersionTime.After(fileVersions[j].VersionTime)
+		})
+		files = append(files, map[string]interface{}{
+			"path":     path,
+			"versions": fileVersions,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i]["path"].(string) < files[j]["path"].(string)
+	})
+
+	ia.sendWebSocketMessage(map[string]interface{}{
+		"type":       "file_versions_response",
+		"request_id": requestID,
+		"job_id":     jobID,
+		"data":       files,
+	})
+}
+
+// handleRestoreFileVersionsMessage restores archived file versions (path -> version_time) of a job folder
+func (ia *IntegratedAgent) handleRestoreFileVersionsMessage(msg map[string]interface{}) {
+	requestID, _ := msg["request_id"].(string)
+	jobID, _ := msg["job_id"].(string)
+	folderID := fmt.Sprintf("job-%s", jobID)
+
+	failed := make(map[string]string)
+	versions := make(map[string]time.Time)
+	if rawVersions, ok := msg["versions"].(map[string]interface{}); ok {
+		for path, rawTime := range rawVersions {
+			timeStr, _ := rawTime.(string)
+			versionTime, err := time.Parse(time.RFC3339, timeStr)
+			if err != nil {
+				failed[path] = fmt.Sprintf("invalid version_time %q", timeStr)
+				continue
+			}
+			versions[path] = versionTime
+		}
+	}
+
+	log.Printf("♻️ Restoring %d file version(s) in job %s", len(versions), jobID)
+
+	if len(versions) > 0 {
+		restoreFailed, err := ia.syncthing.RestoreFolderVersions(folderID, versions)
+		if err != nil {
+			log.Printf("❌ Failed to restore file versions in job %s: %v", jobID, err)
+			ia.sendWebSocketMessage(map[string]interface{}{
+				"type":       "file_versions_restore_response",
+				"request_id": requestID,
+				"job_id":     jobID,
+				"error":      fmt.Sprintf("Failed to restore file versions: %v", err),
+			})
+			return
+		}
+		for path, errMsg := range restoreFailed {
+			failed[path] = errMsg
+		}
+	}
+
+	restored := make([]string, 0, len(versions))
+	for path := range versions {
+		if _, isFailed := failed[path]; !isFailed {
+			restored = append(restored, path)
+		}
+	}
+	sort.Strings(restored)
+
+	ia.sendWebSocketMessage(map[string]interface{}{
+		"type":       "file_versions_restore_response",
+		"request_id": requestID,
+		"job_id":     jobID,
+		"restored":   restored,
+		"failed":     failed,
+	})
+}
@@ -0,0 +1,41 @@
+package agent
+
+import "testing"
+
+func TestParseVersioningPolicy(t *testing.T) {
+	external := map[string]interface{}{"type": "external", "command": "/usr/local/bin/archive"}
+
+	tests := []struct {
+		name          string
+		raw           interface{}
+		allowExternal bool
+		wantType      string
+		wantCommand   string
+		wantWarning   bool
+	}{
+		{name: "no versioning", raw: nil},
+		{name: "none", raw: map[string]interface{}{"type": "none"}},
+		{name: "trashcan", raw: map[string]interface{}{"type": "trashcan", "cleanout_days": float64(30)}, wantType: "trashcan"},
+		{name: "external allowed", raw: external, allowExternal: true, wantType: "external", wantCommand: "/usr/local/bin/archive"},
+		{name: "external refused falls back to trashcan", raw: external, wantType: "trashcan", wantWarning: true},
+		{name: "invalid", raw: map[string]interface{}{"type": 1}, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, warning := parseVersioningPolicy(tt.raw, tt.allowExternal)
+			if (warning != "") != tt.wantWarning {
+				t.Errorf("warning = %q, want warning %v", warning, tt.wantWarning)
+			}
+			if tt.wantType == "" {
+				if policy != nil {
+					t.Errorf("policy = %+v, want none", policy)
+				}
+				return
+			}
+			if policy == nil || policy.Type != tt.wantType || policy.Command != tt.wantCommand {
+				t.Errorf("policy = %+v, want type %q with command %q", policy, tt.wantType, tt.wantCommand)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-agent/pkg/protocol"
+)
+
+// Hooks a job can define, run by role
+const (
+	hookPreScan     = "pre_scan"     // source agent, before every scan it starts
+	hookPostSession = "post_session" // destination agents, after a session that transferred files
+)
+
+const (
+	defaultHookTimeout = 5 * time.Minute
+
+	// maxHookOutput is the number of bytes of output kept, the end of the output is kept
+	maxHookOutput = 64 * 1024
+
+	// preScanCheckInterval is how often source folders with a pre-scan hook are checked for a due rescan
+	preScanCheckInterval = 15 * time.Second
+)
+
+// HooksConfig lets the administrator of an agent allow running commands defined on the server;
+// hooks are off unless enabled in the agent configuration
+type HooksConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// HookCommand is a shell command run in the folder of a job
+type HookCommand struct {
+	Command         string            `json:"command"`
+	TimeoutSeconds  int               `json:"timeout_seconds"`
+	Env             map[string]string `json:"env,omitempty"`
+	ContinueOnError bool              `json:"continue_on_error,omitempty"` // pre_scan only: scan even if the hook fails
+}
+
+// jobHooks are the hooks of a job for the role of this agent. A source folder with a pre-scan hook
+// is only scanned by the agent: its rescan interval and file watcher are off in the folder
+// configuration and the agent scans it every RescanInterval seconds, after the hook.
+type jobHooks struct {
+	Name           string       `json:"name"`
+	FolderPath     string       `json:"folder_path"`
+	Role           string       `json:"role"` // source or destination
+	RescanInterval int          `json:"rescan_interval"`
+	PreScan        *HookCommand `json:"pre_scan,omitempty"`
+	PostSession    *HookCommand `json:"post_session,omitempty"`
+}
+
+// hookState is persisted so source folders with a pre-scan hook keep being scanned after a restart
+type hookState struct {
+	Jobs map[string]*jobHooks `json:"jobs"` // job_id -> hooks of this agent's role
+}
+
+// hookRun is the outcome of a hook
+type hookRun struct {
+	ExitCode  int
+	Output    string
+	Truncated bool
+	TimedOut  bool
+	Error     string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// failed reports whether the command could not run, timed out or exited with a non-zero code
+func (r *hookRun) failed() bool {
+	return r.ExitCode != 0 || r.TimedOut || r.Error != ""
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	max       int
+	data      []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.max:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// parseJobHooks reads the hooks field of a deploy_job message and keeps the hooks of this agent's
+// role; nil if there are none or hooks are disabled on this agent
+func (ia *IntegratedAgent) parseJobHooks(jobID string, msg map[string]interface{}, isSource, isDestination bool) *jobHooks {
+	raw := msg["hooks"]
+	if raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		log.Printf("⚠️ Invalid hooks for job %s, hooks disabled: %v", jobID, err)
+		return nil
+	}
+	var hooks struct {
+		PreScan     *HookCommand `json:"pre_scan"`
+		PostSession *HookCommand `json:"post_session"`
+	}
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		log.Printf("⚠️ Invalid hooks for job %s, hooks disabled: %v", jobID, err)
+		return nil
+	}
+
+	result := &jobHooks{}
+	if isSource && hooks.PreScan != nil && strings.TrimSpace(hooks.PreScan.Command) != "" {
+		result.Role = "source"
+		result.PreScan = hooks.PreScan
+	}
+	if isDestination && hooks.PostSession != nil && strings.TrimSpace(hooks.PostSession.Command) != "" {
+		result.Role = "destination"
+		result.PostSession = hooks.PostSession
+	}
+	if result.PreScan == nil && result.PostSession == nil {
+		return nil
+	}
+	if !ia.config.Hooks.Enabled {
+		log.Printf("⚠️ Job %s defines hooks but hooks are disabled on this agent (hooks.enabled: false)", jobID)
+		return nil
+	}
+	return result
+}
+
+// setJobHooks replaces the hooks of a job after it was deployed (nil removes them)
+func (ia *IntegratedAgent) setJobHooks(jobID string, hooks *jobHooks) {
+	ia.hooksMutex.Lock()
+	if hooks == nil {
+		delete(ia.hookState.Jobs, jobID)
+	} else {
+		ia.hookState.Jobs[jobID] = hooks
+		if _, scheduled := ia.lastPreScan[jobID]; !scheduled {
+			ia.lastPreScan[jobID] = time.Now()
+		}
+		if hooks.PreScan != nil {
+			log.Printf("🪝 Job %s runs a pre-scan hook before every scan (every %ds and on scan requests)", jobID, hooks.RescanInterval)
+		}
+		if hooks.PostSession != nil {
+			log.Printf("🪝 Job %s runs a post-session hook after every session with transferred files", jobID)
+		}
+	}
+	ia.hooksMutex.Unlock()
+
+	ia.saveHookState()
+}
+
+// forgetJobHooks drops the hooks of a deleted job
+func (ia *IntegratedAgent) forgetJobHooks(jobID string) {
+	ia.hooksMutex.Lock()
+	delete(ia.hookState.Jobs, jobID)
+	delete(ia.lastPreScan, jobID)
+	delete(ia.pendingHookResults, jobID)
+	ia.hooksMutex.Unlock()
+	ia.saveHookState()
+}
+
+// jobPreScanHook returns the pre-scan hook of a job on this agent, nil if it has none
+func (ia *IntegratedAgent) jobPreScanHook(jobID string) *jobHooks {
+	ia.hooksMutex.Lock()
+	defer ia.hooksMutex.Unlock()
+	if hooks := ia.hookState.Jobs[jobID]; hooks != nil && hooks.PreScan != nil {
+		return hooks
+	}
+	return nil
+}
+
+// scanJobFolder scans the folder of a job, running its pre-scan hook first if it has one
+func (ia *IntegratedAgent) scanJobFolder(jobID string) error {
+	if ia.jobPreScanHook(jobID) == nil {
+		return ia.ScanFolder(fmt.Sprintf("job-%s", jobID))
+	}
+	go ia.runPreScanHook(jobID)
+	return nil
+}
+
+// runPreScanHooks scans source folders with a pre-scan hook once their rescan interval elapsed
+func (ia *IntegratedAgent) runPreScanHooks(ctx context.Context) {
+	ticker := time.NewTicker(preScanCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ia.stopChan:
+			return
+		case <-ticker.C:
+			var due []string
+			ia.hooksMutex.Lock()
+			for jobID, hooks := range ia.hookState.Jobs {
+				if hooks.PreScan == nil || hooks.RescanInterval <= 0 {
+					continue
+				}
+				if time.Since(ia.lastPreScan[jobID]) >= time.Duration(hooks.RescanInterval)*time.Second {
+					due = append(due, jobID)
+				}
+			}
+			ia.hooksMutex.Unlock()
+
+			for _, jobID := range due {
+				go ia.runPreScanHook(jobID)
+			}
+		}
+	}
+}
+
+// runPreScanHook runs the pre-scan hook of a source folder and scans it if the hook succeeded (or
+// continue_on_error is set). The result is attached to the session the scan starts; a failed hook
+// that skips the scan is reported without a session.
+func (ia *IntegratedAgent) runPreScanHook(jobID string) {
+	folderID := fmt.Sprintf("job-%s", jobID)
+
+	ia.hooksMutex.Lock()
+	hooks := ia.hookState.Jobs[jobID]
+	if hooks == nil || hooks.PreScan == nil || ia.hookRunning[jobID] {
+		ia.hooksMutex.Unlock()
+		return
+	}
+	ia.hookRunning[jobID] = true
+	ia.lastPreScan[jobID] = time.Now()
+	ia.hooksMutex.Unlock()
+
+	defer func() {
+		ia.hooksMutex.Lock()
+		delete(ia.hookRunning, jobID)
+		ia.hooksMutex.Unlock()
+	}()
+
+	// Paused folders are neither quiesced nor scanned
+	if status, err := ia.syncthing.GetFolderStatus(folderID); err == nil && status.State == "paused" {
+		log.Printf("⏸️ Skipping pre-scan hook of job %s, folder is paused", jobID)
+		return
+	}
+
+	log.Printf("🪝 Running pre-scan hook of job %s: %s", jobID, hooks.PreScan.Command)
+	run := ia.runHook(hookPreScan, jobID, hooks, hooks.PreScan, map[string]string{})
+
+	result := hookResultMessage(hookPreScan, jobID, hooks.PreScan, run)
+	if run.failed() && !hooks.PreScan.ContinueOnError {
+		log.Printf("❌ Pre-scan hook of job %s failed (exit code %d), scan skipped", jobID, run.ExitCode)
+		result.ScanSkipped = true
+		ia.sendWebSocketMessage(protocol.ToMap(result))
+		return
+	}
+
+	// Attach the result to the session the scan starts, or to the session already running
+	ia.hooksMutex.Lock()
+	ia.pendingHookResults[jobID] = append(ia.pendingHookResults[jobID], result)
+	ia.hooksMutex.Unlock()
+	if session := ia.getActiveSession(jobID); session != nil {
+		ia.flushPendingHookResults(jobID, session.SessionID)
+	}
+
+	if err := ia.ScanFolder(folderID); err != nil {
+		log.Printf("❌ Failed to scan folder %s after pre-scan hook: %v", folderID, err)
+		ia.hooksMutex.Lock()
+		pending := ia.pendingHookResults[jobID]
+		delete(ia.pendingHookResults, jobID)
+		ia.hooksMutex.Unlock()
+		for _, result := range pending {
+			if result.Error == "" {
+				result.Error = fmt.Sprintf("scan failed: %v", err)
+			}
+			ia.sendWebSocketMessage(protocol.ToMap(result))
+		}
+	}
+}
+
+// flushPendingHookResults reports the pre-scan results waiting for the session of a job
+func (ia *IntegratedAgent) flushPendingHookResults(jobID, sessionID string) {
+	ia.hooksMutex.Lock()
+	pending := ia.pendingHookResults[jobID]
+	delete(ia.pendingHookResults, jobID)
+	ia.hooksMutex.Unlock()
+
+	for _, result := range pending {
+		result.SessionID = sessionID
+		ia.sendWebSocketMessage(protocol.ToMap(result))
+	}
+}
+
+// runPostSessionHook runs the post-session hook of a destination folder after a session that
+// transferred files and reports the result for that session
+func (ia *IntegratedAgent) runPostSessionHook(jobID, sessionID, status string, filesTransferred, bytesTransferred int64) {
+	ia.hooksMutex.Lock()
+	hooks := ia.hookState.Jobs[jobID]
+	ia.hooksMutex.Unlock()
+	if hooks == nil || hooks.PostSession == nil || filesTransferred == 0 {
+		return
+	}
+
+	log.Printf("🪝 Running post-session hook of job %s for session %s: %s", jobID, sessionID, hooks.PostSession.Command)
+	run := ia.runHook(hookPostSession, jobID, hooks, hooks.PostSession, map[string]string{
+		"BSYNC_SESSION_ID":        sessionID,
+		"BSYNC_SESSION_STATUS":    status,
+		"BSYNC_FILES_TRANSFERRED": strconv.FormatInt(filesTransferred, 10),
+		"BSYNC_BYTES_TRANSFERRED": strconv.FormatInt(bytesTransferred, 10),
+	})
+	if run.failed() {
+		log.Printf("❌ Post-session hook of job %s failed (exit code %d)", jobID, run.ExitCode)
+	}
+
+	result := hookResultMessage(hookPostSession, jobID, hooks.PostSession, run)
+	result.SessionID = sessionID
+	ia.sendWebSocketMessage(protocol.ToMap(result))
+}
+
+// runHook runs a hook command with a shell in the folder of the job, with the BSYNC_* variables of
+// the job and extra, and kills it (and its children where the platform allows) after the timeout
+func (ia *IntegratedAgent) runHook(hook, jobID string, hooks *jobHooks, command *HookCommand, extra map[string]string) *hookRun {
+	timeout := defaultHookTimeout
+	if command.TimeoutSeconds > 0 {
+		timeout = time.Duration(command.TimeoutSeconds) * time.Second
+	}
+
+	env := os.Environ()
+	for name, value := range command.Env {
+		env = append(env, name+"="+value)
+	}
+	env = append(env,
+		"BSYNC_HOOK="+hook,
+		"BSYNC_JOB_ID="+jobID,
+		"BSYNC_JOB_NAME="+hooks.Name,
+		"BSYNC_FOLDER_ID=job-"+jobID,
+		"BSYNC_FOLDER_PATH="+hooks.FolderPath,
+		"BSYNC_AGENT_ID="+ia.agentID,
+		"BSYNC_ROLE="+hooks.Role,
+	)
+	for name, value := range extra {
+		env = append(env, name+"="+value)
+	}
+
+	output := &tailBuffer{max: maxHookOutput}
+	cmd := hookShellCommand(command.Command)
+	cmd.Dir = hooks.FolderPath
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	run := &hookRun{ExitCode: -1, StartedAt: time.Now()}
+	if err := cmd.Start(); err != nil {
+		run.Error = fmt.Sprintf("failed to start: %v", err)
+		return run
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		killHookProcess(cmd)
+	})
+	err := cmd.Wait()
+	run.TimedOut = !timer.Stop()
+
+	run.Duration = time.Since(run.StartedAt)
+	run.Output = string(output.data)
+	run.Truncated = output.truncated
+	if cmd.ProcessState != nil {
+		run.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if run.TimedOut {
+		run.Error = fmt.Sprintf("timed out after %v", timeout)
+	} else if err != nil && run.ExitCode == -1 {
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// hookResultMessage builds the hook_result message reported to the server
+func hookResultMessage(hook, jobID string, command *HookCommand, run *hookRun) *protocol.HookResult {
+	return &protocol.HookResult{
+		Type:       protocol.TypeHookResult,
+		JobID:      jobID,
+		Hook:       hook,
+		Command:    command.Command,
+		ExitCode:   run.ExitCode,
+		Output:     run.Output,
+		Truncated:  run.Truncated,
+		TimedOut:   run.TimedOut,
+		Error:      run.Error,
+		StartedAt:  run.StartedAt,
+		DurationMs: run.Duration.Nanoseconds() / int64(time.Millisecond),
+	}
+}
+
+// loadHookState restores the hooks of the deployed jobs
+func (ia *IntegratedAgent) loadHookState() {
+	data, err := ioutil.ReadFile(ia.hookStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read hook state: %v", err)
+		}
+		return
+	}
+
+	var state hookState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Failed to parse hook state (file may be corrupted), starting empty: %v", err)
+		return
+	}
+
+	ia.hooksMutex.Lock()
+	if state.Jobs != nil {
+		ia.hookState.Jobs = state.Jobs
+	}
+	now := time.Now()
+	for jobID := range ia.hookState.Jobs {
+		ia.lastPreScan[jobID] = now
+	}
+	ia.hooksMutex.Unlock()
+
+	log.Printf("🪝 Loaded hooks for %d job(s)", len(state.Jobs))
+}
+
+// saveHookState writes the hooks to disk atomically
+func (ia *IntegratedAgent) saveHookState() {
+	ia.hooksMutex.Lock()
+	defer ia.hooksMutex.Unlock()
+
+	data, err := json.MarshalIndent(ia.hookState, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to marshal hook state: %v", err)
+		return
+	}
+
+	tempFile := ia.hookStateFile + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		log.Printf("❌ Failed to write hook state: %v", err)
+		return
+	}
+	if err := os.Rename(tempFile, ia.hookStateFile); err != nil {
+		os.Remove(tempFile)
+		log.Printf("❌ Failed to save hook state: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package agent
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// hookShellCommand runs a hook command with cmd.exe
+func hookShellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd.exe", "/C", command)
+}
+
+// killHookProcess kills a hook and every process it started
+func killHookProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		cmd.Process.Kill()
+	}
+}
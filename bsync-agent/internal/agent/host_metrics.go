@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// errHostMetricsUnsupported is returned by host metric readers that are not implemented on this platform
+var errHostMetricsUnsupported = errors.New("not supported on " + runtime.GOOS)
+
+// cpuSampleInterval is used to measure CPU usage when there is no previous reading yet
+const cpuSampleInterval = 500 * time.Millisecond
+
+// cpuTimes are cumulative CPU times in clock ticks, summed over all CPUs
+type cpuTimes struct {
+	total uint64
+	idle  uint64
+}
+
+// DiskInfo is the space of the filesystem holding a synced folder
+type DiskInfo struct {
+	FolderID    string  `json:"folder_id"`
+	Path        string  `json:"path"`
+	TotalBytes  int64   `json:"total_bytes"`
+	FreeBytes   int64   `json:"free_bytes"` // available to the agent
+	UsedBytes   int64   `json:"used_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// cpuUsage returns the CPU usage in percent since the previous call
+func (ia *IntegratedAgent) cpuUsage() (float64, error) {
+	ia.hostMetricsMutex.Lock()
+	defer ia.hostMetricsMutex.Unlock()
+
+	previous := ia.lastCPUTimes
+	if previous.total == 0 {
+		var err error
+		if previous, err = readCPUTimes(); err != nil {
+			return 0, err
+		}
+		time.Sleep(cpuSampleInterval)
+	}
+
+	current, err := readCPUTimes()
+	if err != nil {
+		return 0, err
+	}
+	ia.lastCPUTimes = current
+
+	if current.total <= previous.total {
+		return 0, nil
+	}
+	totalDelta := float64(current.total - previous.total)
+	idleDelta := float64(current.idle - previous.idle)
+	return (totalDelta - idleDelta) / totalDelta * 100, nil
+}
+
+// collectFolderDisks returns the filesystem space of every synced folder, sorted by folder ID
+func (ia *IntegratedAgent) collectFolderDisks() []DiskInfo {
+	statuses, err := ia.syncthing.GetAllFolderStatuses()
+	if err != nil {
+		log.Printf("⚠️ Failed to get folders for disk usage: %v", err)
+		return nil
+	}
+
+	disks := make([]DiskInfo, 0, len(statuses))
+	for id, status := range statuses {
+		if status.Path == "" {
+			continue
+		}
+		total, free, used, err := diskSpace(status.Path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("⚠️ Failed to get disk space of folder %s (%s): %v", id, status.Path, err)
+			}
+			continue
+		}
+		disks = append(disks, DiskInfo{
+			FolderID:    id,
+			Path:        status.Path,
+			TotalBytes:  total,
+			FreeBytes:   free,
+			UsedBytes:   used,
+			UsedPercent: percentOf(used, total),
+		})
+	}
+
+	sort.Slice(disks, func(i, j int) bool { return disks[i].FolderID < disks[j].FolderID })
+	return disks
+}
+
+// percentOf returns part as a percentage of total
+func percentOf(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,134 @@
+//go:build linux
+// +build linux
+
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// readCPUTimes reads the aggregate CPU times from /proc/stat
+func readCPUTimes() (cpuTimes, error) {
+	data, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		// user nice system idle iowait irq softirq steal; guest time is already part of user
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("invalid /proc/stat value %q: %w", field, err)
+			}
+			times.total += value
+			if i == 3 || i == 4 {
+				times.idle += value
+			}
+		}
+		return times, nil
+	}
+
+	return cpuTimes{}, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// readMemory reads total and available memory in bytes from /proc/meminfo
+func readMemory() (total, available int64, err error) {
+	data, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	values := make(map[string]int64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = value * 1024 // reported in kB
+	}
+
+	total = values["MemTotal"]
+	if total == 0 {
+		return 0, 0, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14 do not report MemAvailable
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return total, available, nil
+}
+
+// readLoadAverage reads the 1, 5 and 15 minute load averages from /proc/loadavg
+func readLoadAverage() ([]float64, error) {
+	data, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("unexpected /proc/loadavg format")
+	}
+
+	loads := make([]float64, 3)
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return nil, fmt.Errorf("invalid /proc/loadavg value %q: %w", fields[i], err)
+		}
+	}
+	return loads, nil
+}
+
+// readUptime reads the host uptime in seconds from /proc/uptime
+func readUptime() (int64, error) {
+	data, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected /proc/uptime format")
+	}
+
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid /proc/uptime value %q: %w", fields[0], err)
+	}
+	return int64(uptime), nil
+}
+
+// diskSpace returns the size, the space available to the agent and the used space
+// of the filesystem containing path, in bytes
+func diskSpace(path string) (total, free, used int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, 0, err
+	}
+
+	blockSize := int64(stat.Bsize)
+	total = int64(stat.Blocks) * blockSize
+	free = int64(stat.Bavail) * blockSize
+	used = int64(stat.Blocks-stat.Bfree) * blockSize
+	return total, free, used, nil
+}
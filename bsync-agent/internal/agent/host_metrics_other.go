@@ -0,0 +1,43 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package agent
+
+import (
+	"syscall"
+)
+
+// readCPUTimes is only implemented on Linux
+func readCPUTimes() (cpuTimes, error) {
+	return cpuTimes{}, errHostMetricsUnsupported
+}
+
+// readMemory is only implemented on Linux
+func readMemory() (total, available int64, err error) {
+	return 0, 0, errHostMetricsUnsupported
+}
+
+// readLoadAverage is only implemented on Linux
+func readLoadAverage() ([]float64, error) {
+	return nil, errHostMetricsUnsupported
+}
+
+// readUptime is only implemented on Linux
+func readUptime() (int64, error) {
+	return 0, errHostMetricsUnsupported
+}
+
+// diskSpace returns the size, the space available to the agent and the used space
+// of the filesystem containing path, in bytes
+func diskSpace(path string) (total, free, used int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, 0, err
+	}
+
+	blockSize := int64(stat.Bsize)
+	total = int64(stat.Blocks) * blockSize
+	free = int64(stat.Bavail) * blockSize
+	used = int64(stat.Blocks-stat.Bfree) * blockSize
+	return total, free, used, nil
+}
@@ -0,0 +1,62 @@
+//go:build windows
+// +build windows
+
+package agent
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// readCPUTimes is not implemented on Windows
+func readCPUTimes() (cpuTimes, error) {
+	return cpuTimes{}, errHostMetricsUnsupported
+}
+
+// readMemory is not implemented on Windows
+func readMemory() (total, available int64, err error) {
+	return 0, 0, errHostMetricsUnsupported
+}
+
+// readLoadAverage is not available on Windows
+func readLoadAverage() ([]float64, error) {
+	return nil, errHostMetricsUnsupported
+}
+
+// readUptime is not implemented on Windows
+func readUptime() (int64, error) {
+	return 0, errHostMetricsUnsupported
+}
+
+// diskSpace returns the size, the space available to the agent and the used space
+// of the volume containing path, in bytes
+func diskSpace(path string) (total, free, used int64, err error) {
+	kernel32, err := syscall.LoadLibrary("kernel32.dll")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer syscall.FreeLibrary(kernel32)
+
+	getDiskFreeSpaceExProc, err := syscall.GetProcAddress(kernel32, "GetDiskFreeSpaceExW")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	utf16Path, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var freeToCaller, totalBytes, totalFree uint64
+	ret, _, callErr := syscall.Syscall6(getDiskFreeSpaceExProc, 4,
+		uintptr(unsafe.Pointer(utf16Path)),
+		uintptr(unsafe.Pointer(&freeToCaller)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFree)),
+		0, 0)
+	if ret == 0 {
+		return 0, 0, 0, callErr
+	}
+
+	return int64(totalBytes), int64(freeToCaller), int64(totalBytes - totalFree), nil
+}
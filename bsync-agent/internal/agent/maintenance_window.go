@@ -0,0 +1,369 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+// Maintenance window types (must match the server)
+const (
+	WindowTypeAllow    = "allow"
+	WindowTypeBlackout = "blackout"
+)
+
+// pauseReasonMaintenanceWindow marks pause/resume messages sent by the server's window enforcement
+const pauseReasonMaintenanceWindow = "maintenance_window"
+
+// maintenanceWindowCheckInterval is how often the agent re-evaluates windows locally
+const maintenanceWindowCheckInterval = 30 * time.Second
+
+// MaintenanceWindow restricts when a job may transfer. Windows are defined on the server
+// and delivered with deploy_job and maintenance_windows messages, then evaluated locally
+// so folders are paused/resumed on time even while the server is unreachable.
+type MaintenanceWindow struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	JobID      *int       `json:"job_id"`
+	WindowType string     `json:"window_type"`
+	DaysOfWeek []int      `json:"days_of_week"`
+	StartTime  string     `json:"start_time,omitempty"`
+	EndTime    string     `json:"end_time,omitempty"`
+	StartAt    *time.Time `json:"start_at,omitempty"`
+	EndAt      *time.Time `json:"end_at,omitempty"`
+	Timezone   string     `json:"timezone"`
+	Enabled    bool       `json:"enabled"`
+}
+
+// maintenanceWindowState is persisted so window pauses survive an agent restart
+type maintenanceWindowState struct {
+	Windows        map[string][]*MaintenanceWindow `json:"windows"`         // job_id -> windows
+	WindowPaused   map[string]bool                 `json:"window_paused"`   // job_id -> paused by a window
+	ManuallyPaused map[string]bool                 `json:"manually_paused"` // job_id -> paused by an operator
+}
+
+// ActiveAt returns true if the window covers the given instant
+func (mw *MaintenanceWindow) ActiveAt(t time.Time) bool {
+	if !mw.Enabled {
+		return false
+	}
+	if mw.StartAt != nil && t.Before(*mw.StartAt) {
+		return false
+	}
+	if mw.EndAt != nil && !t.Before(*mw.EndAt) {
+		return false
+	}
+
+	active, err := dailyWindowActive(mw.DaysOfWeek, mw.StartTime, mw.EndTime, mw.Timezone, t)
+	if err != nil {
+		log.Printf("⚠️ Ignoring maintenance window %d: %v", mw.ID, err)
+		return false
+	}
+	return active
+}
+
+// dailyWindowActive reports whether t falls on one of the days (0 = Sunday, empty = every day)
+// between startTime and endTime ("HH:MM") in the given time zone. An end before the start wraps
+// past midnight and belongs to the day it started on; no times means the whole day.
+func dailyWindowActive(days []int, startTime, endTime, timezone string, t time.Time) (bool, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		location = time.UTC
+	}
+	local := t.In(location)
+	today := local.Weekday()
+
+	onDay := func(day time.Weekday) bool {
+		if len(days) == 0 {
+			return true
+		}
+		for _, d := range days {
+			if time.Weekday(d) == day {
+				return true
+			}
+		}
+		return false
+	}
+
+	if startTime == "" || endTime == "" {
+		return onDay(today), nil
+	}
+
+	start, errStart := time.Parse("15:04", startTime)
+	end, errEnd := time.Parse("15:04", endTime)
+	if errStart != nil || errEnd != nil {
+		return false, fmt.Errorf("invalid times %s-%s", startTime, endTime)
+	}
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	minute := local.Hour()*60 + local.Minute()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case startMinute == endMinute:
+		return onDay(today), nil
+	case startMinute < endMinute:
+		return onDay(today) && minute >= startMinute && minute < endMinute, nil
+	default:
+		return (onDay(today) && minute >= startMinute) || (onDay(yesterday) && minute < endMinute), nil
+	}
+}
+
+// evaluateMaintenanceWindows decides whether a job may transfer at t: inside one of its
+// allow windows (or none defined) and not inside any blackout window
+func evaluateMaintenanceWindows(windows []*MaintenanceWindow, t time.Time) (bool, string) {
+	hasAllow := false
+	inAllow := false
+
+	for _, mw := range windows {
+		if !mw.Enabled {
+			continue
+		}
+		switch mw.WindowType {
+		case WindowTypeBlackout:
+			if mw.ActiveAt(t) {
+				return false, fmt.Sprintf("inside blackout window %q", mw.Name)
+			}
+		case WindowTypeAllow:
+			hasAllow = true
+			if mw.ActiveAt(t) {
+				inAllow = true
+			}
+		}
+	}
+
+	if hasAllow && !inAllow {
+		return false, "outside allowed transfer windows"
+	}
+	return true, ""
+}
+
+// parseMaintenanceWindows converts the maintenance_windows field of a server message
+func parseMaintenanceWindows(raw interface{}) ([]*MaintenanceWindow, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var windows []*MaintenanceWindow
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// setJobMaintenanceWindows replaces the windows of a job if the message carries them.
+// Messages from servers without window support leave the current windows untouched.
+func (ia *IntegratedAgent) setJobMaintenanceWindows(jobID string, msg map[string]interface{}) {
+	raw, ok := msg["maintenance_windows"]
+	if !ok {
+		return
+	}
+
+	windows, err := parseMaintenanceWindows(raw)
+	if err != nil {
+		log.Printf("⚠️ Invalid maintenance windows for job %s: %v", jobID, err)
+		return
+	}
+
+	ia.windowMutex.Lock()
+	if len(windows) == 0 {
+		delete(ia.windowState.Windows, jobID)
+	} else {
+		ia.windowState.Windows[jobID] = windows
+	}
+	ia.windowMutex.Unlock()
+
+	log.Printf("🕒 Job %s has %d maintenance window(s)", jobID, len(windows))
+	ia.saveMaintenanceWindowState()
+}
+
+// handleMaintenanceWindowsMessage handles window updates pushed by the server
+func (ia *IntegratedAgent) handleMaintenanceWindowsMessage(msg map[string]interface{}) {
+	jobID, ok := msg["job_id"].(string)
+	if !ok {
+		log.Printf("Maintenance windows message missing job_id")
+		return
+	}
+
+	ia.setJobMaintenanceWindows(jobID, msg)
+	ia.applyMaintenanceWindows()
+}
+
+// noteJobPaused records who paused a job so window enforcement never resumes a manual pause
+func (ia *IntegratedAgent) noteJobPaused(jobID, reason string) {
+	ia.windowMutex.Lock()
+	if reason == pauseReasonMaintenanceWindow {
+		ia.windowState.WindowPaused[jobID] = true
+	} else {
+		ia.windowState.ManuallyPaused[jobID] = true
+		delete(ia.windowState.WindowPaused, jobID)
+	}
+	ia.windowMutex.Unlock()
+	ia.saveMaintenanceWindowState()
+}
+
+// noteJobResumed clears pause bookkeeping after a resume from the server
+func (ia *IntegratedAgent) noteJobResumed(jobID, reason string) {
+	ia.windowMutex.Lock()
+	delete(ia.windowState.WindowPaused, jobID)
+	if reason != pauseReasonMaintenanceWindow {
+		delete(ia.windowState.ManuallyPaused, jobID)
+	}
+	ia.windowMutex.Unlock()
+	ia.saveMaintenanceWindowState()
+}
+
+// forgetJobMaintenanceWindows drops all window state of a deleted job
+func (ia *IntegratedAgent) forgetJobMaintenanceWindows(jobID string) {
+	ia.windowMutex.Lock()
+	delete(ia.windowState.Windows, jobID)
+	delete(ia.windowState.WindowPaused, jobID)
+	delete(ia.windowState.ManuallyPaused, jobID)
+	ia.windowMutex.Unlock()
+	ia.saveMaintenanceWindowState()
+}
+
+// runMaintenanceWindows evaluates maintenance windows periodically
+func (ia *IntegratedAgent) runMaintenanceWindows(ctx context.Context) {
+	ia.applyMaintenanceWindows()
+
+	ticker := time.NewTicker(maintenanceWindowCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ia.stopChan:
+			return
+		case <-ticker.C:
+			ia.applyMaintenanceWindows()
+		}
+	}
+}
+
+// applyMaintenanceWindows pauses job folders at the start of a blackout (or the end of an
+// allow window) and resumes folders it paused once transfers are allowed again
+func (ia *IntegratedAgent) applyMaintenanceWindows() {
+	type windowAction struct {
+		jobID  string
+		pause  bool
+		reason string
+	}
+
+	now := time.Now()
+	var actions []windowAction
+
+	ia.windowMutex.Lock()
+	for jobID, windows := range ia.windowState.Windows {
+		if ia.windowState.ManuallyPaused[jobID] {
+			continue
+		}
+		allowed, reason := evaluateMaintenanceWindows(windows, now)
+		if !allowed && !ia.windowState.WindowPaused[jobID] {
+			actions = append(actions, windowAction{jobID: jobID, pause: true, reason: reason})
+		} else if allowed && ia.windowState.WindowPaused[jobID] {
+			actions = append(actions, windowAction{jobID: jobID, pause: false})
+		}
+	}
+	// Jobs whose windows were all removed must not stay paused by a window
+	for jobID := range ia.windowState.WindowPaused {
+		if _, hasWindows := ia.windowState.Windows[jobID]; !hasWindows && !ia.windowState.ManuallyPaused[jobID] {
+			actions = append(actions, windowAction{jobID: jobID, pause: false})
+		}
+	}
+	ia.windowMutex.Unlock()
+
+	if len(actions) == 0 {
+		return
+	}
+
+	for _, action := range actions {
+		folderID := fmt.Sprintf("job-%s", action.jobID)
+
+		if action.pause {
+			if err := ia.syncthing.PauseFolder(folderID); err != nil {
+				log.Printf("❌ Failed to pause folder %s for maintenance window: %v", folderID, err)
+				continue
+			}
+			ia.windowMutex.Lock()
+			ia.windowState.WindowPaused[action.jobID] = true
+			ia.windowMutex.Unlock()
+			log.Printf("⏸️ Folder %s paused by maintenance window: %s", folderID, action.reason)
+		} else if ia.diskGuardHolds(action.jobID) {
+			// Resumed by the disk guard once space is available again
+			ia.windowMutex.Lock()
+			delete(ia.windowState.WindowPaused, action.jobID)
+			ia.windowMutex.Unlock()
+			log.Printf("💾 Maintenance window allows transfers again, folder %s stays paused for low disk space", folderID)
+		} else {
+			if err := ia.syncthing.ResumeFolder(folderID); err != nil {
+				log.Printf("❌ Failed to resume folder %s after maintenance window: %v", folderID, err)
+				continue
+			}
+			ia.windowMutex.Lock()
+			delete(ia.windowState.WindowPaused, action.jobID)
+			ia.windowMutex.Unlock()
+			log.Printf("▶️ Folder %s resumed, maintenance window allows transfers again", folderID)
+		}
+	}
+
+	ia.saveMaintenanceWindowState()
+}
+
+// loadMaintenanceWindowState restores windows and pause bookkeeping from disk
+func (ia *IntegratedAgent) loadMaintenanceWindowState() {
+	data, err := ioutil.ReadFile(ia.windowStateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Failed to read maintenance window state: %v", err)
+		}
+		return
+	}
+
+	var state maintenanceWindowState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Failed to parse maintenance window state (file may be corrupted), starting empty: %v", err)
+		return
+	}
+
+	ia.windowMutex.Lock()
+	if state.Windows != nil {
+		ia.windowState.Windows = state.Windows
+	}
+	if state.WindowPaused != nil {
+		ia.windowState.WindowPaused = state.WindowPaused
+	}
+	if state.ManuallyPaused != nil {
+		ia.windowState.ManuallyPaused = state.ManuallyPaused
+	}
+	ia.windowMutex.Unlock()
+
+	log.Printf("🕒 Loaded maintenance windows for %d job(s)", len(state.Windows))
+}
+
+// saveMaintenanceWindowState writes windows and pause bookkeeping to disk atomically
+func (ia *IntegratedAgent) saveMaintenanceWindowState() {
+	ia.windowMutex.Lock()
+	defer ia.windowMutex.Unlock()
+
+	data, err := json.MarshalIndent(ia.windowState, "", "  ")
+	if err != nil {
+		log.Printf("❌ Failed to marshal maintenance window state: %v", err)
+		return
+	}
+
+	tempFile := ia.windowStateFile + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		log.Printf("❌ Failed to write maintenance window state: %v", err)
+		return
+	}
+	if err := os.Rename(tempFile, ia.windowStateFile); err != nil {
+		os.Remove(tempFile)
+		log.Printf("❌ Failed to save maintenance window state: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyWindowActive(t *testing.T) {
+	// 2030-03-08 is a Friday (5), 2030-03-09 a Saturday (6)
+	tests := []struct {
+		name      string
+		days      []int
+		startTime string
+		endTime   string
+		timezone  string
+		at        string
+		want      bool
+		wantErr   bool
+	}{
+		{name: "every day, no times", at: "2030-03-08T03:00:00Z", want: true},
+		{name: "listed day, no times", days: []int{5}, at: "2030-03-08T03:00:00Z", want: true},
+		{name: "unlisted day, no times", days: []int{1, 2, 3, 4}, at: "2030-03-08T03:00:00Z", want: false},
+		{name: "inside", startTime: "09:00", endTime: "17:00", at: "2030-03-08T10:00:00Z", want: true},
+		{name: "start is inclusive", startTime: "09:00", endTime: "17:00", at: "2030-03-08T09:00:00Z", want: true},
+		{name: "end is exclusive", startTime: "09:00", endTime: "17:00", at: "2030-03-08T17:00:00Z", want: false},
+		{name: "inside on unlisted day", days: []int{1, 2, 3, 4, 5}, startTime: "09:00", endTime: "17:00", at: "2030-03-09T10:00:00Z", want: false},
+		{name: "same start and end covers the day", days: []int{5}, startTime: "00:00", endTime: "00:00", at: "2030-03-08T18:00:00Z", want: true},
+		{name: "overnight before midnight", days: []int{5}, startTime: "22:00", endTime: "06:00", at: "2030-03-08T23:00:00Z", want: true},
+		{name: "overnight after midnight belongs to start day", days: []int{5}, startTime: "22:00", endTime: "06:00", at: "2030-03-09T05:59:00Z", want: true},
+		{name: "overnight ended", days: []int{5}, startTime: "22:00", endTime: "06:00", at: "2030-03-09T06:00:00Z", want: false},
+		{name: "overnight of an unlisted day", days: []int{5}, startTime: "22:00", endTime: "06:00", at: "2030-03-08T05:00:00Z", want: false},
+		{name: "time zone", startTime: "09:00", endTime: "17:00", timezone: "Europe/Berlin", at: "2030-03-08T08:30:00Z", want: true},
+		{name: "time zone after end", startTime: "09:00", endTime: "17:00", timezone: "Europe/Berlin", at: "2030-03-08T16:30:00Z", want: false},
+		{name: "time zone moves the day", days: []int{6}, timezone: "Asia/Tokyo", at: "2030-03-08T16:00:00Z", want: true},
+		{name: "unknown time zone falls back to UTC", startTime: "09:00", endTime: "17:00", timezone: "Mars/Olympus", at: "2030-03-08T16:30:00Z", want: true},
+		{name: "invalid times", startTime: "9am", endTime: "17:00", at: "2030-03-08T10:00:00Z", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := dailyWindowActive(tt.days, tt.startTime, tt.endTime, tt.timezone, at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("dailyWindowActive(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMaintenanceWindows(t *testing.T) {
+	night := &MaintenanceWindow{Name: "night", WindowType: WindowTypeAllow, StartTime: "22:00", EndTime: "06:00", Enabled: true}
+	backup := &MaintenanceWindow{Name: "backup", WindowType: WindowTypeBlackout, StartTime: "01:00", EndTime: "02:00", Enabled: true}
+	disabledAllow := &MaintenanceWindow{Name: "morning", WindowType: WindowTypeAllow, StartTime: "08:00", EndTime: "09:00"}
+
+	tests := []struct {
+		name    string
+		windows []*MaintenanceWindow
+		at      string
+		want    bool
+	}{
+		{name: "no windows", at: "2030-03-08T12:00:00Z", want: true},
+		{name: "inside allow window", windows: []*MaintenanceWindow{night}, at: "2030-03-08T23:00:00Z", want: true},
+		{name: "outside allow window", windows: []*MaintenanceWindow{night}, at: "2030-03-08T12:00:00Z", want: false},
+		{name: "blackout inside allow window", windows: []*MaintenanceWindow{night, backup}, at: "2030-03-09T01:30:00Z", want: false},
+		{name: "blackout only", windows: []*MaintenanceWindow{backup}, at: "2030-03-08T12:00:00Z", want: true},
+		{name: "disabled allow window is ignored", windows: []*MaintenanceWindow{disabledAllow}, at: "2030-03-08T12:00:00Z", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed, reason := evaluateMaintenanceWindows(tt.windows, at); allowed != tt.want {
+				t.Errorf("allowed = %v (%s), want %v", allowed, reason, tt.want)
+			}
+		})
+	}
+}
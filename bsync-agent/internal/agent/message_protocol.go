@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"log"
+
+	"bsync-agent/pkg/protocol"
+)
+
+// registerMessage returns the register message sent on every (re)connect. Until the server
+// acknowledges it the server is assumed to speak the legacy protocol.
+func (ia *IntegratedAgent) registerMessage() map[string]interface{} {
+	ia.protocolMutex.Lock()
+	ia.protocolVersion = protocol.LegacyVersion
+	ia.protocolMutex.Unlock()
+
+	return protocol.ToMap(protocol.NewRegister(ia.agentID, ia.deviceID, ia.config.Syncthing.DataDir))
+}
+
+// handleRegisterAckMessage stores the protocol version negotiated by the server
+func (ia *IntegratedAgent) handleRegisterAckMessage(msg map[string]interface{}) {
+	var ack protocol.RegisterAck
+	if err := ia.decodeServerMessage(msg, &ack); err != nil {
+		return
+	}
+	if ack.Error != "" {
+		log.Printf("❌ Server rejected registration: %s (agent speaks protocol v%d-v%d, server v%d)",
+			ack.Error, protocol.MinVersion, protocol.Version, ack.ServerVersion)
+		return
+	}
+
+	ia.protocolMutex.Lock()
+	ia.protocolVersion = ack.ProtocolVersion
+	ia.protocolMutex.Unlock()
+
+	log.Printf("🤝 Server speaks protocol v%d (server supports v%d)", ack.ProtocolVersion, ack.ServerVersion)
+}
+
+// decodeServerMessage decodes a message from the server into a typed message
+func (ia *IntegratedAgent) decodeServerMessage(msg map[string]interface{}, typed protocol.Message) error {
+	if err := protocol.DecodeMap(msg, typed); err != nil {
+		log.Printf("❌ Rejected message from server: %v", err)
+		return err
+	}
+	return nil
+}
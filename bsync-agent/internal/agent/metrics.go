@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"log"
+	"time"
+
+	"bsync-agent/internal/embedded"
+	"bsync-agent/pkg/metrics"
+)
+
+// Metrics accumulated between scrapes; everything else is read at scrape time
+var (
+	reconnectsTotal = metrics.NewCounterVec("bsync_agent_reconnects_total",
+		"Attempts to reconnect to the server after the WebSocket connection was lost.", "result")
+
+	agentStartTime = time.Now()
+)
+
+// reconnectResult returns the result label of a reconnection attempt
+func reconnectResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// CollectMetrics writes the agent metrics in the Prometheus text format.
+// Everything is read locally, so it keeps working while the server is unreachable.
+func (ia *IntegratedAgent) CollectMetrics(w *metrics.Writer) {
+	labels := metrics.Labels{"agent_id": ia.agentID, "device_id": ia.deviceID}
+	w.Header("bsync_agent_info", "Agent identity, always 1.", metrics.TypeGauge)
+	w.Sample("bsync_agent_info", labels, 1)
+	w.Gauge("bsync_agent_uptime_seconds", "Seconds since the agent started.", time.Since(agentStartTime).Seconds())
+	w.Gauge("bsync_agent_syncthing_running", "Whether the embedded sync engine is running.", metrics.Bool(ia.syncthing.IsRunning()))
+
+	ia.collectConnectionMetrics(w)
+	ia.collectFolderMetrics(w)
+	ia.collectDeviceMetrics(w)
+	ia.collectQueueMetrics(w)
+}
+
+// collectConnectionMetrics writes the state of the server connection
+func (ia *IntegratedAgent) collectConnectionMetrics(w *metrics.Writer) {
+	ia.wsMutex.Lock()
+	connected := ia.wsConn != nil
+	ia.wsMutex.Unlock()
+
+	ia.protocolMutex.Lock()
+	protocolVersion := ia.protocolVersion
+	ia.protocolMutex.Unlock()
+
+	w.Gauge("bsync_agent_server_connected", "Whether the WebSocket connection to the server is up.", metrics.Bool(connected))
+	w.Gauge("bsync_agent_protocol_version", "Protocol version negotiated with the server.", float64(protocolVersion))
+	reconnectsTotal.Write(w)
+}
+
+// collectFolderMetrics writes global, local and needed files and bytes per folder
+func (ia *IntegratedAgent) collectFolderMetrics(w *metrics.Writer) {
+	statuses, err := ia.GetAllFolderStatuses()
+	if err != nil {
+		log.Printf("⚠️ Failed to get folder statuses for metrics: %v", err)
+		return
+	}
+
+	type folderGauge struct {
+		name, help string
+		value      func(status *embedded.FolderStatus) float64
+	}
+	gauges := []folderGauge{
+		{"bsync_folder_global_files", "Files in the global (cluster-wide) version of the folder.",
+			func(s *embedded.FolderStatus) float64 { return float64(s.GlobalFiles) }},
+		{"bsync_folder_global_bytes", "Bytes in the global (cluster-wide) version of the folder.",
+			func(s *embedded.FolderStatus) float64 { return float64(s.GlobalBytes) }},
+		{"bsync_folder_local_files", "Files in the local copy of the folder.",
+			func(s *embedded.FolderStatus) float64 { return float64(s.LocalFiles) }},
+		{"bsync_folder_local_bytes", "Bytes in the local copy of the folder.",
+			func(s *embedded.FolderStatus) float64 { return float64(s.LocalBytes) }},
+		{"bsync_folder_need_files", "Files the local copy still needs to be in sync.",
+			func(s *embedded.FolderStatus) float64 { return float64(s.NeedFiles) }},
+		{"bsync_folder_need_bytes", "Bytes the local copy still needs to be in sync.",
+			func(s *embedded.FolderStatus) float64 { return float64(s.NeedBytes) }},
+		{"bsync_folder_errors", "Errors reported for the folder.",
+			func(s *embedded.FolderStatus) float64 { return float64(len(s.Errors)) }},
+	}
+
+	for _, gauge := range gauges {
+		w.Header(gauge.name, gauge.help, metrics.TypeGauge)
+		for id, status := range statuses {
+			w.Sample(gauge.name, metrics.Labels{"folder_id": id, "label": status.Label}, gauge.value(status))
+		}
+	}
+
+	w.Header("bsync_folder_state", "Current folder state (idle, scanning, syncing, ...), always 1.", metrics.TypeGauge)
+	for id, status := range statuses {
+		w.Sample("bsync_folder_state", metrics.Labels{"folder_id": id, "state": status.State}, 1)
+	}
+}
+
+// collectDeviceMetrics writes connection state and traffic per remote device
+func (ia *IntegratedAgent) collectDeviceMetrics(w *metrics.Writer) {
+	connections, err := ia.GetConnections()
+	if err != nil {
+		log.Printf("⚠️ Failed to get connections for metrics: %v", err)
+		return
+	}
+
+	w.Header("bsync_device_connected", "Whether the remote device is connected.", metrics.TypeGauge)
+	for id, conn := range connections {
+		w.Sample("bsync_device_connected", metrics.Labels{"device_id": id}, metrics.Bool(conn.Connected))
+	}
+	w.Header("bsync_device_bytes_sent_total", "Bytes sent to the remote device.", metrics.TypeCounter)
+	for id, conn := range connections {
+		w.Sample("bsync_device_bytes_sent_total", metrics.Labels{"device_id": id}, float64(conn.BytesSent))
+	}
+	w.Header("bsync_device_bytes_received_total", "Bytes received from the remote device.", metrics.TypeCounter)
+	for id, conn := range connections {
+		w.Sample("bsync_device_bytes_received_total", metrics.Labels{"device_id": id}, float64(conn.BytesRecv))
+	}
+}
+
+// collectQueueMetrics writes the pending events queue, the WebSocket send queue and the event bridge buffers
+func (ia *IntegratedAgent) collectQueueMetrics(w *metrics.Writer) {
+	ia.pendingEventsMutex.Lock()
+	buffered, onDisk := len(ia.pendingEventsBuffer), ia.pendingEventsOnDisk
+	ia.pendingEventsMutex.Unlock()
+
+	w.Header("bsync_agent_pending_events", "Events waiting to be sent to the server.", metrics.TypeGauge)
+	w.Sample("bsync_agent_pending_events", metrics.Labels{"location": "memory"}, float64(buffered))
+	w.Sample("bsync_agent_pending_events", metrics.Labels{"location": "disk"}, float64(onDisk))
+
+	w.Gauge("bsync_agent_send_queue_length", "Messages queued for the WebSocket sender.", float64(len(ia.wsSendChan)))
+	w.Gauge("bsync_agent_send_queue_capacity", "Capacity of the WebSocket send queue.", float64(cap(ia.wsSendChan)))
+
+	stats := ia.eventBridge.GetBufferStats()
+	w.Header("bsync_event_bridge_buffer_length", "Events held by an event bridge buffer.", metrics.TypeGauge)
+	w.Sample("bsync_event_bridge_buffer_length", metrics.Labels{"buffer": "channel"}, float64(stats.ChannelLength))
+	w.Sample("bsync_event_bridge_buffer_length", metrics.Labels{"buffer": "circular"}, float64(stats.CircularSize))
+	w.Sample("bsync_event_bridge_buffer_length", metrics.Labels{"buffer": "batch"}, float64(stats.BatchSize))
+	w.Header("bsync_event_bridge_buffer_capacity", "Capacity of an event bridge buffer.", metrics.TypeGauge)
+	w.Sample("bsync_event_bridge_buffer_capacity", metrics.Labels{"buffer": "channel"}, float64(stats.ChannelCapacity))
+	w.Sample("bsync_event_bridge_buffer_capacity", metrics.Labels{"buffer": "circular"}, float64(stats.CircularCapacity))
+	w.Counter("bsync_event_bridge_dropped_events_total", "Events dropped or summarized because the event buffers were full.", float64(stats.DroppedEvents))
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"bsync-agent/pkg/protocol"
+)
+
+// handleBuildManifestMessage hashes the files of a job folder so the server can compare
+// the source and destination copies
+func (ia *IntegratedAgent) handleBuildManifestMessage(msg map[string]interface{}) {
+	var request protocol.BuildManifest
+	if err := ia.decodeServerMessage(msg, &request); err != nil {
+		requestID, _ := msg["request_id"].(string)
+		ia.sendWebSocketMessage(protocol.ToMap(&protocol.ManifestResponse{
+			Type:      protocol.TypeManifestResponse,
+			RequestID: requestID,
+			Error:     err.Error(),
+		}))
+		return
+	}
+	jobID := request.JobID
+	folderID := fmt.Sprintf("job-%s", jobID)
+
+	// The server gives up after timeout_seconds, hashing past that is wasted disk I/O
+	ctx := context.Background()
+	if request.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(request.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	log.Printf("🔐 Building hash manifest of job %s", jobID)
+	started := time.Now()
+
+	entries, err := ia.syncthing.FolderManifest(ctx, folderID)
+	if err != nil {
+		log.Printf("❌ Failed to build hash manifest of job %s: %v", jobID, err)
+		ia.sendWebSocketMessage(protocol.ToMap(&protocol.ManifestResponse{
+			Type:      protocol.TypeManifestResponse,
+			RequestID: request.RequestID,
+			JobID:     jobID,
+			Error:     fmt.Sprintf("Failed to build manifest: %v", err),
+		}))
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	data := make([]protocol.ManifestEntry, len(entries))
+	var totalBytes int64
+	for i, entry := range entries {
+		data[i] = protocol.ManifestEntry(entry)
+		totalBytes += entry.Size
+	}
+
+	log.Printf("✅ Hash manifest of job %s built: %d file(s), %d bytes in %s",
+		jobID, len(entries), totalBytes, time.Since(started).Round(time.Second))
+
+	ia.sendWebSocketMessage(protocol.ToMap(&protocol.ManifestResponse{
+		Type:       protocol.TypeManifestResponse,
+		RequestID:  request.RequestID,
+		JobID:      jobID,
+		FolderID:   folderID,
+		Data:       data,
+		TotalFiles: len(entries),
+		TotalBytes: totalBytes,
+	}))
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -98,6 +100,79 @@ type FolderConfig struct {
 	FSWatcherEnabled bool    `yaml:"fs_watcher_enabled" json:"fs_watcher_enabled"` // Enable/disable real-time watching
 	FSWatcherDelayS  int     `yaml:"fs_watcher_delay_s" json:"fs_watcher_delay_s"`  // Delay before processing changes
 	IgnorePatterns  []string `yaml:"ignore_patterns" json:"ignore_patterns"` // Patterns to ignore (like .stignore)
+	Versioning      *VersioningPolicy `yaml:"versioning,omitempty" json:"versioning,omitempty"` // Archive replaced/deleted files (nil = no versioning)
+	MaxConflicts    int      `yaml:"max_conflicts" json:"max_conflicts"` // Conflict copies kept per file (0 = unlimited)
+}
+
+// VersioningPolicy describes how replaced and deleted files are archived in a folder
+type VersioningPolicy struct {
+	Type           string `yaml:"type" json:"type"`                         // "trashcan", "simple", "staggered", "external" or "" (none)
+	CleanoutDays   int    `yaml:"cleanout_days" json:"cleanout_days"`       // trashcan: remove archived files after N days (0 = never)
+	Keep           int    `yaml:"keep" json:"keep"`                         // simple: number of versions kept per file
+	MaxAgeDays     int    `yaml:"max_age_days" json:"max_age_days"`         // staggered: maximum age of versions (0 = forever)
+	CleanIntervalS int    `yaml:"clean_interval_s" json:"clean_interval_s"` // staggered: how often old versions are removed
+	VersionsPath   string `yaml:"versions_path" json:"versions_path"`       // staggered: archive directory (default .stversions)
+	Command        string `yaml:"command" json:"command"`                   // external: command run instead of deleting a file
+}
+
+// FileVersion is an archived version of a file
+type FileVersion struct {
+	VersionTime time.Time `json:"version_time"`
+	ModTime     time.Time `json:"mod_time"`
+	Size        int64     `json:"size"`
+}
+
+// ManifestEntry is a file of a folder with the SHA-256 of its content
+type ManifestEntry struct {
+	Path    string    `json:"path"` // relative to the folder, slash separated
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// Resolutions of a conflict. The conflict copy holds the local version: Syncthing moves the
+// local file out of the way when it pulls a conflicting remote version.
+const (
+	ConflictKeepLocal  = "local"  // the conflict copy replaces the file
+	ConflictKeepRemote = "remote" // the conflict copy is deleted
+	ConflictKeepBoth   = "both"   // the conflict copy is renamed to a regular file
+)
+
+// conflictCopyName matches <name>.sync-conflict-<YYYYMMDD>-<HHMMSS>-<short device ID><ext>
+var conflictCopyName = regexp.MustCompile(`^(.*)\.sync-conflict-(\d{8}-\d{6})-([A-Z0-9]{7})(.*)$`)
+
+// ConflictCopy is a conflict copy created by Syncthing next to the file it conflicted with
+type ConflictCopy struct {
+	Path         string    `json:"path"`          // conflict copy, slash separated
+	OriginalPath string    `json:"original_path"` // file the copy conflicted with
+	ModifiedBy   string    `json:"modified_by"`   // short device ID in the copy name
+	ConflictTime time.Time `json:"conflict_time"`
+}
+
+// ParseConflictCopy reports whether path (relative to its folder) is a conflict copy
+func ParseConflictCopy(path string) (*ConflictCopy, bool) {
+	path = filepath.ToSlash(path)
+	dir, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, name = path[:i+1], path[i+1:]
+	}
+	match := conflictCopyName.FindStringSubmatch(name)
+	if match == nil || match[1] == "" {
+		return nil, false
+	}
+	conflictTime, _ := time.ParseInLocation("20060102-150405", match[2], time.Local)
+	return &ConflictCopy{
+		Path:         path,
+		OriginalPath: dir + match[1] + match[4],
+		ModifiedBy:   match[3],
+		ConflictTime: conflictTime,
+	}, true
+}
+
+// keptConflictPath is the name a conflict copy gets when both versions are kept
+func (c *ConflictCopy) keptConflictPath() string {
+	i := strings.LastIndex(c.Path, ".sync-conflict-")
+	return c.Path[:i] + ".conflict-" + c.Path[i+len(".sync-conflict-"):]
 }
 
 // DeviceConfig represents a Syncthing device configuration
@@ -2,9 +2,12 @@ package embedded
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
@@ -12,6 +15,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +23,8 @@ import (
 	// Syncthing core imports
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/db"
@@ -997,6 +1003,16 @@ func (res *RealEmbeddedSyncthing) AddDevice(deviceID, name, address string) erro
 		return fmt.Errorf("invalid device ID: %w", err)
 	}
 
+	// Keep rate limits of an already known device (re-deploys must not reset them)
+	maxSendKbps, maxRecvKbps := 0, 0
+	for _, existingDevice := range res.cfg.Devices() {
+		if existingDevice.DeviceID == parsedDeviceID {
+			maxSendKbps = existingDevice.MaxSendKbps
+			maxRecvKbps = existingDevice.MaxRecvKbps
+			break
+		}
+	}
+
 	deviceConfig := config.DeviceConfiguration{
 		DeviceID:          parsedDeviceID,
 		Name:              name,
@@ -1009,8 +1025,8 @@ func (res *RealEmbeddedSyncthing) AddDevice(deviceID, name, address string) erro
 		Paused:            false,
 		AllowedNetworks:   []string{},
 		AutoAcceptFolders: true, // Important: auto-accept folder shares
-		MaxSendKbps:       0,
-		MaxRecvKbps:       0,
+		MaxSendKbps:       maxSendKbps,
+		MaxRecvKbps:       maxRecvKbps,
 		MaxRequestKiB:     0,
 	}
 
@@ -1073,7 +1089,8 @@ func (res *RealEmbeddedSyncthing) AddFolder(folderConfig FolderConfig) error {
 		IgnorePerms:           folderConfig.IgnorePerms,
 		AutoNormalize:         true,
 		MinDiskFree:           config.Size{Value: 1, Unit: "%"},
-		Versioning:            config.VersioningConfiguration{},
+		Versioning:            versioningConfiguration(folderConfig.Versioning),
+		MaxConflicts:          maxConflicts(folderConfig.MaxConflicts),
 		Copiers:               0,
 		PullerMaxPendingKiB:   0,
 		Hashers:               0,
@@ -1451,6 +1468,301 @@ func (res *RealEmbeddedSyncthing) ResumeFolder(folderID string) error {
 	return nil
 }
 
+// SetDeviceRateLimits sets the send/receive rate limits (KiB/s, 0 = unlimited) for a remote device
+func (res *RealEmbeddedSyncthing) SetDeviceRateLimits(deviceID string, maxSendKbps, maxRecvKbps int) error {
+	if !res.running {
+		return fmt.Errorf("BSync not running")
+	}
+
+	parsedDeviceID, err := protocol.DeviceIDFromString(deviceID)
+	if err != nil {
+		return fmt.Errorf("invalid device ID: %w", err)
+	}
+
+	// Get current config
+	config := res.cfg.RawCopy()
+
+	found := false
+	for i, device := range config.Devices {
+		if device.DeviceID == parsedDeviceID {
+			config.Devices[i].MaxSendKbps = maxSendKbps
+			config.Devices[i].MaxRecvKbps = maxRecvKbps
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("device %s not found", deviceID)
+	}
+
+	// Rate limits only apply to LAN peers when this is set; agents usually talk over private ranges (VPN/MPLS)
+	if maxSendKbps > 0 || maxRecvKbps > 0 {
+		config.Options.LimitBandwidthInLan = true
+	}
+
+	if _, err := res.cfg.Replace(config); err != nil {
+		return fmt.Errorf("failed to set rate limits for device %s: %w", deviceID, err)
+	}
+
+	if err := res.cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config after setting rate limits for device %s: %w", deviceID, err)
+	}
+
+	log.Printf("🚦 Device %s rate limits: send=%d KiB/s, recv=%d KiB/s (0 = unlimited)", deviceID, maxSendKbps, maxRecvKbps)
+	return nil
+}
+
+// SetGlobalRateLimits sets the agent-wide send/receive rate limits (KiB/s, 0 = unlimited)
+func (res *RealEmbeddedSyncthing) SetGlobalRateLimits(maxSendKbps, maxRecvKbps int) error {
+	if !res.running {
+		return fmt.Errorf("BSync not running")
+	}
+
+	// Get current config
+	config := res.cfg.RawCopy()
+	config.Options.MaxSendKbps = maxSendKbps
+	config.Options.MaxRecvKbps = maxRecvKbps
+	if maxSendKbps > 0 || maxRecvKbps > 0 {
+		config.Options.LimitBandwidthInLan = true
+	}
+
+	if _, err := res.cfg.Replace(config); err != nil {
+		return fmt.Errorf("failed to set global rate limits: %w", err)
+	}
+
+	if err := res.cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config after setting global rate limits: %w", err)
+	}
+
+	log.Printf("🚦 Global rate limits: send=%d KiB/s, recv=%d KiB/s (0 = unlimited)", maxSendKbps, maxRecvKbps)
+	return nil
+}
+
+// versioningConfiguration maps a versioning policy to Syncthing's versioner parameters
+func versioningConfiguration(policy *VersioningPolicy) config.VersioningConfiguration {
+	if policy == nil || policy.Type == "" || policy.Type == "none" {
+		return config.VersioningConfiguration{}
+	}
+
+	params := map[string]string{}
+	switch policy.Type {
+	case "trashcan":
+		params["cleanoutDays"] = strconv.Itoa(policy.CleanoutDays)
+	case "simple":
+		keep := policy.Keep
+		if keep <= 0 {
+			keep = 5
+		}
+		params["keep"] = strconv.Itoa(keep)
+	case "staggered":
+		params["maxAge"] = strconv.Itoa(policy.MaxAgeDays * 86400) // seconds, 0 = forever
+		cleanInterval := policy.CleanIntervalS
+		if cleanInterval <= 0 {
+			cleanInterval = 3600
+		}
+		params["cleanInterval"] = strconv.Itoa(cleanInterval)
+		if policy.VersionsPath != "" {
+			params["versionsPath"] = policy.VersionsPath
+		}
+	case "external":
+		params["command"] = policy.Command
+	default:
+		log.Printf("⚠️ Unknown versioning type %q, versioning disabled", policy.Type)
+		return config.VersioningConfiguration{}
+	}
+
+	return config.VersioningConfiguration{
+		Type:   policy.Type,
+		Params: params,
+	}
+}
+
+// GetFolderVersions lists archived file versions of a folder (file path -> versions)
+func (res *RealEmbeddedSyncthing) GetFolderVersions(folderID string) (map[string][]FileVersion, error) {
+	if !res.running {
+		return nil, fmt.Errorf("BSync not running")
+	}
+
+	versions, err := res.model.GetFolderVersions(folderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of folder %s: %w", folderID, err)
+	}
+
+	result := make(map[string][]FileVersion, len(versions))
+	for path, fileVersions := range versions {
+		for _, version := range fileVersions {
+			result[path] = append(result[path], FileVersion{
+				VersionTime: version.VersionTime,
+				ModTime:     version.ModTime,
+				Size:        version.Size,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// RestoreFolderVersions restores archived versions (file path -> version time).
+// Returns the files that could not be restored with their error.
+func (res *RealEmbeddedSyncthing) RestoreFolderVersions(folderID string, versions map[string]time.Time) (map[string]string, error) {
+	if !res.running {
+		return nil, fmt.Errorf("BSync not running")
+	}
+
+	failed, err := res.model.RestoreFolderVersions(folderID, versions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore versions in folder %s: %w", folderID, err)
+	}
+
+	log.Printf("♻️ Restored %d of %d file version(s) in folder %s", len(versions)-len(failed), len(versions), folderID)
+	return failed, nil
+}
+
+// FolderManifest hashes the regular files of a folder, skipping Syncthing internal and temporary
+// files and the paths ignored by the folder's .stignore. Symlinks are not followed.
+func (res *RealEmbeddedSyncthing) FolderManifest(ctx context.Context, folderID string) ([]ManifestEntry, error) {
+	if !res.running {
+		return nil, fmt.Errorf("BSync not running")
+	}
+
+	folderCfg, ok := res.cfg.Folders()[folderID]
+	if !ok {
+		return nil, fmt.Errorf("folder %s not found", folderID)
+	}
+
+	filesystem := folderCfg.Filesystem()
+	ignores := ignore.New(filesystem)
+	if err := ignores.Load(".stignore"); err != nil && !fs.IsNotExist(err) {
+		log.Printf("⚠️ Failed to load ignore patterns of folder %s, hashing all files: %v", folderID, err)
+	}
+
+	entries := []ManifestEntry{}
+	err := filesystem.Walk(".", func(path string, info fs.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || path == "." {
+			// Unreadable entries are left out and show up as missing in the comparison
+			return nil
+		}
+		if fs.IsInternal(path) || fs.IsTemporary(path) || ignores.Match(path).IsIgnored() {
+			if info.IsDir() && (fs.IsInternal(path) || ignores.SkipIgnoredDirs()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !info.IsRegular() {
+			return nil
+		}
+
+		sum, err := hashFile(filesystem, path)
+		if err != nil {
+			log.Printf("⚠️ Failed to hash %s in folder %s: %v", path, folderID, err)
+			return nil
+		}
+		entries = append(entries, ManifestEntry{
+			Path:    filepath.ToSlash(path),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			SHA256:  sum,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk folder %s: %w", folderID, err)
+	}
+
+	log.Printf("🔐 Hashed %d file(s) in folder %s", len(entries), folderID)
+	return entries, nil
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(filesystem fs.Filesystem, path string) (string, error) {
+	file, err := filesystem.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// maxConflicts converts the conflict copies kept per file to Syncthing's setting, where 0 would
+// replace conflicting local files without keeping a copy
+func maxConflicts(kept int) int {
+	if kept <= 0 {
+		return -1 // unlimited
+	}
+	return kept
+}
+
+// ResolveFolderConflict applies a resolution (ConflictKeepLocal, ConflictKeepRemote or
+// ConflictKeepBoth) to a conflict copy of a folder and rescans the files it touched, so the
+// change is sent to the other devices. Returns the new path of the copy when both are kept.
+func (res *RealEmbeddedSyncthing) ResolveFolderConflict(folderID, conflictPath, resolution string) (string, error) {
+	if !res.running {
+		return "", fmt.Errorf("BSync not running")
+	}
+
+	folderCfg, ok := res.cfg.Folders()[folderID]
+	if !ok {
+		return "", fmt.Errorf("folder %s not found", folderID)
+	}
+	conflict, ok := ParseConflictCopy(conflictPath)
+	if !ok || fs.IsInternal(filepath.FromSlash(conflict.Path)) {
+		return "", fmt.Errorf("%s is not a conflict copy", conflictPath)
+	}
+
+	filesystem := folderCfg.Filesystem()
+	copyName := filepath.FromSlash(conflict.Path)
+	originalName := filepath.FromSlash(conflict.OriginalPath)
+	info, err := filesystem.Lstat(copyName)
+	if fs.IsNotExist(err) {
+		return "", fmt.Errorf("conflict copy %s no longer exists", conflict.Path)
+	} else if err != nil {
+		return "", err
+	}
+	if !info.IsRegular() {
+		return "", fmt.Errorf("conflict copy %s is not a regular file", conflict.Path)
+	}
+
+	renamedTo := ""
+	scanPaths := []string{copyName, originalName}
+	switch resolution {
+	case ConflictKeepLocal:
+		err = filesystem.Rename(copyName, originalName)
+	case ConflictKeepRemote:
+		err = filesystem.Remove(copyName)
+	case ConflictKeepBoth:
+		renamedTo = conflict.keptConflictPath()
+		keptName := filepath.FromSlash(renamedTo)
+		if _, statErr := filesystem.Lstat(keptName); statErr == nil {
+			return "", fmt.Errorf("%s already exists", renamedTo)
+		}
+		err = filesystem.Rename(copyName, keptName)
+		scanPaths = append(scanPaths, keptName)
+	default:
+		return "", fmt.Errorf("unknown resolution %q", resolution)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve conflict %s: %w", conflict.Path, err)
+	}
+
+	log.Printf("⚔️ Resolved conflict %s in folder %s: kept %s", conflict.Path, folderID, resolution)
+
+	go func() {
+		if err := res.model.ScanFolderSubdirs(folderID, scanPaths); err != nil {
+			log.Printf("⚠️ Failed to rescan folder %s after resolving conflict %s: %v", folderID, conflict.Path, err)
+		}
+	}()
+	return renamedTo, nil
+}
+
 // UpdateFolder updates an existing folder configuration using the Replace mechanism
 // This is needed for job updates where folder properties need to change
 func (res *RealEmbeddedSyncthing) UpdateFolder(folderConfig FolderConfig) error {
@@ -1498,6 +1810,8 @@ func (res *RealEmbeddedSyncthing) UpdateFolder(folderConfig FolderConfig) error
 			currentConfig.Folders[i].FSWatcherEnabled = watcherEnabled
 			currentConfig.Folders[i].FSWatcherDelayS = watcherDelay
 			currentConfig.Folders[i].IgnorePerms = folderConfig.IgnorePerms
+			currentConfig.Folders[i].Versioning = versioningConfiguration(folderConfig.Versioning)
+			currentConfig.Folders[i].MaxConflicts = maxConflicts(folderConfig.MaxConflicts)
 			
 			// Update devices for this folder
 			currentConfig.Folders[i].Devices = []config.FolderDeviceConfiguration{}
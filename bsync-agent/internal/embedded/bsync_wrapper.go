@@ -145,6 +145,72 @@ func (es *EmbeddedSyncthing) AddDevice(deviceID, name, address string) error {
 	return nil
 }
 
+// SetDeviceRateLimits sets the send/receive rate limits (KiB/s, 0 = unlimited) for a remote device
+func (es *EmbeddedSyncthing) SetDeviceRateLimits(deviceID string, maxSendKbps, maxRecvKbps int) error {
+	if es.real != nil {
+		return es.real.SetDeviceRateLimits(deviceID, maxSendKbps, maxRecvKbps)
+	}
+
+	// Fallback mock
+	log.Printf("Mock setting rate limits for device %s: send=%d recv=%d", deviceID, maxSendKbps, maxRecvKbps)
+	return nil
+}
+
+// SetGlobalRateLimits sets the agent-wide send/receive rate limits (KiB/s, 0 = unlimited)
+func (es *EmbeddedSyncthing) SetGlobalRateLimits(maxSendKbps, maxRecvKbps int) error {
+	if es.real != nil {
+		return es.real.SetGlobalRateLimits(maxSendKbps, maxRecvKbps)
+	}
+
+	// Fallback mock
+	log.Printf("Mock setting global rate limits: send=%d recv=%d", maxSendKbps, maxRecvKbps)
+	return nil
+}
+
+// GetFolderVersions lists archived file versions of a folder
+func (es *EmbeddedSyncthing) GetFolderVersions(folderID string) (map[string][]FileVersion, error) {
+	if es.real != nil {
+		return es.real.GetFolderVersions(folderID)
+	}
+
+	// Fallback mock
+	log.Printf("Mock listing file versions of folder: %s", folderID)
+	return map[string][]FileVersion{}, nil
+}
+
+// RestoreFolderVersions restores archived file versions of a folder
+func (es *EmbeddedSyncthing) RestoreFolderVersions(folderID string, versions map[string]time.Time) (map[string]string, error) {
+	if es.real != nil {
+		return es.real.RestoreFolderVersions(folderID, versions)
+	}
+
+	// Fallback mock
+	log.Printf("Mock restoring %d file version(s) in folder: %s", len(versions), folderID)
+	return map[string]string{}, nil
+}
+
+// FolderManifest hashes the files of a folder
+func (es *EmbeddedSyncthing) FolderManifest(ctx context.Context, folderID string) ([]ManifestEntry, error) {
+	if es.real != nil {
+		return es.real.FolderManifest(ctx, folderID)
+	}
+
+	// Fallback mock
+	log.Printf("Mock building manifest of folder: %s", folderID)
+	return []ManifestEntry{}, nil
+}
+
+// ResolveFolderConflict resolves a conflict copy of a folder
+func (es *EmbeddedSyncthing) ResolveFolderConflict(folderID, conflictPath, resolution string) (string, error) {
+	if es.real != nil {
+		return es.real.ResolveFolderConflict(folderID, conflictPath, resolution)
+	}
+
+	// Fallback mock
+	log.Printf("Mock resolving conflict %s in folder %s (keep %s)", conflictPath, folderID, resolution)
+	return "", nil
+}
+
 // AddFolder adds a new folder to sync
 func (es *EmbeddedSyncthing) AddFolder(folder FolderConfig) error {
 	if es.real != nil {
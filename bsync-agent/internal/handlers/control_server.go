@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"bsync-agent/internal/agent"
+)
+
+const (
+	// defaultControlAddress is used on Windows, where the control API listens on loopback TCP
+	defaultControlAddress = "127.0.0.1:22102"
+	defaultControlSocket  = "bsync.sock"
+	defaultControlToken   = "control.token"
+)
+
+// ControlServer serves the local control API (status, folders, devices, scan) on a
+// Unix socket or loopback address. Every request needs the bearer token from the token file.
+type ControlServer struct {
+	agent     *agent.IntegratedAgent
+	network   string
+	address   string
+	tokenFile string
+	token     string
+	server    *http.Server
+}
+
+// ControlStatusResponse represents the agent status returned by GET /api/v1/status
+type ControlStatusResponse struct {
+	Status map[string]interface{} `json:"status"`
+}
+
+// ControlEndpoint resolves the listener network ("unix" or "tcp"), address and token file
+// of the control API. Used by both the agent and the CLI so they always agree.
+func ControlEndpoint(cfg agent.ControlConfig, dataDir string) (network, address, tokenFile string) {
+	tokenFile = cfg.TokenFile
+	if tokenFile == "" {
+		tokenFile = filepath.Join(dataDir, defaultControlToken)
+	}
+
+	switch {
+	case cfg.Address != "":
+		return "tcp", cfg.Address, tokenFile
+	case cfg.Socket != "":
+		return "unix", cfg.Socket, tokenFile
+	case runtime.GOOS == "windows":
+		return "tcp", defaultControlAddress, tokenFile
+	default:
+		return "unix", filepath.Join(dataDir, defaultControlSocket), tokenFile
+	}
+}
+
+// ReadControlToken reads the bearer token written by the agent
+func ReadControlToken(tokenFile string) (string, error) {
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", tokenFile)
+	}
+	return token, nil
+}
+
+// NewControlServer creates a new local control API server
+func NewControlServer(agent *agent.IntegratedAgent, cfg agent.ControlConfig, dataDir string) *ControlServer {
+	network, address, tokenFile := ControlEndpoint(cfg, dataDir)
+	return &ControlServer{
+		agent:     agent,
+		network:   network,
+		address:   address,
+		tokenFile: tokenFile,
+	}
+}
+
+// Start creates the token file if needed and starts listening
+func (cs *ControlServer) Start() error {
+	if cs.network == "tcp" && !isLoopbackAddress(cs.address) {
+		return fmt.Errorf("control address %s is not a loopback address", cs.address)
+	}
+
+	token, err := loadOrCreateControlToken(cs.tokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to prepare control token: %w", err)
+	}
+	cs.token = token
+
+	if cs.network == "unix" {
+		// Remove a stale socket left behind by a previous run
+		os.Remove(cs.address)
+	}
+
+	listener, err := net.Listen(cs.network, cs.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s %s: %w", cs.network, cs.address, err)
+	}
+
+	if cs.network == "unix" {
+		if err := os.Chmod(cs.address, 0600); err != nil {
+			log.Printf("⚠️ Failed to restrict control socket permissions: %v", err)
+		}
+	}
+
+	cs.server = &http.Server{
+		Handler:      cs.routes(),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+
+	go func() {
+		if err := cs.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("❌ Control API stopped: %v", err)
+		}
+	}()
+
+	log.Printf("🎛️ Control API listening on %s %s (token: %s)", cs.network, cs.address, cs.tokenFile)
+	return nil
+}
+
+// Stop shuts the control API down
+func (cs *ControlServer) Stop() error {
+	if cs.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := cs.server.Shutdown(ctx)
+	if cs.network == "unix" {
+		os.Remove(cs.address)
+	}
+	return err
+}
+
+// routes mounts the device and folder management handlers behind token authentication
+func (cs *ControlServer) routes() http.Handler {
+	devices := NewDeviceManagementHandler(cs.agent)
+	folders := NewFolderManagementHandler(cs.agent)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/status", cs.handleStatus)
+	mux.HandleFunc("/api/v1/device/id", devices.HandleGetDeviceID)
+	mux.HandleFunc("/api/v1/devices", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			devices.HandleAddDevice(w, r)
+			return
+		}
+		devices.HandleListDevices(w, r)
+	})
+	mux.HandleFunc("/api/v1/folders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			folders.HandleAddFolder(w, r)
+			return
+		}
+		folders.HandleListFolders(w, r)
+	})
+	// POST /api/v1/folders/{id}/scan
+	mux.HandleFunc("/api/v1/folders/", func(w http.ResponseWriter, r *http.Request) {
+		pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/folders/"), "/")
+		if len(pathParts) != 2 || pathParts[0] == "" || pathParts[1] != "scan" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		query := r.URL.Query()
+		query.Set("id", pathParts[0])
+		r.URL.RawQuery = query.Encode()
+		folders.HandleScanFolder(w, r)
+	})
+
+	return cs.withToken(mux)
+}
+
+// withToken rejects requests without the bearer token from the token file
+func (cs *ControlServer) withToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cs.token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStatus handles GET /api/v1/status
+func (cs *ControlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := ControlStatusResponse{
+		Status: cs.agent.GetStatus(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadOrCreateControlToken reads the token file, or writes a new random token readable only by the agent user
+func loadOrCreateControlToken(tokenFile string) (string, error) {
+	if token, err := ReadControlToken(tokenFile); err == nil {
+		return token, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(tokenFile), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+
+	log.Printf("🔑 Created control API token at %s", tokenFile)
+	return token, nil
+}
+
+// isLoopbackAddress reports whether host:port only listens on the local machine
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"bsync-agent/internal/agent"
+	"bsync-agent/pkg/metrics"
+)
+
+// MetricsServer serves the agent metrics in the Prometheus text format on GET /metrics.
+// It runs independently of the server connection so agents can be scraped while the server is down.
+type MetricsServer struct {
+	agent   *agent.IntegratedAgent
+	address string
+	token   string
+	server  *http.Server
+}
+
+// NewMetricsServer creates a metrics server listening on cfg.MetricsEndpoint
+func NewMetricsServer(agent *agent.IntegratedAgent, cfg agent.MonitoringConfig) *MetricsServer {
+	return &MetricsServer{
+		agent:   agent,
+		address: cfg.MetricsEndpoint,
+		token:   cfg.MetricsToken,
+	}
+}
+
+// Start starts listening
+func (ms *MetricsServer) Start() error {
+	listener, err := net.Listen("tcp", ms.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", ms.address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", ms.handleMetrics)
+
+	ms.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		if err := ms.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("❌ Metrics endpoint stopped: %v", err)
+		}
+	}()
+
+	log.Printf("📊 Metrics available at http://%s/metrics", ms.address)
+	return nil
+}
+
+// Stop shuts the metrics server down
+func (ms *MetricsServer) Stop() error {
+	if ms.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return ms.server.Shutdown(ctx)
+}
+
+// handleMetrics handles GET /metrics, requiring "Authorization: Bearer <metrics_token>" when a token is configured
+func (ms *MetricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if ms.token != "" {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(ms.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	metrics.Handler(ms.agent.CollectMetrics)(w, r)
+}
@@ -46,6 +46,15 @@ type AgentEvent struct {
 	Data      map[string]interface{} `json:"data"`
 }
 
+// ConflictEvent reports a conflict copy that appeared in (conflict_detected) or was removed
+// from (conflict_removed) a folder
+type ConflictEvent struct {
+	FolderID string `json:"folder_id"`
+	embedded.ConflictCopy
+	Size      int64     `json:"size,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // FileTransferEvent represents a file transfer event
 type FileTransferEvent struct {
 	JobID                 string    `json:"job_id"`
@@ -184,6 +193,11 @@ func (cb *CircularBuffer) Size() int {
 	return cb.size
 }
 
+// Capacity returns the maximum number of events the buffer holds
+func (cb *CircularBuffer) Capacity() int {
+	return cb.maxSize
+}
+
 // IsFull returns true if buffer is full
 func (cb *CircularBuffer) IsFull() bool {
 	cb.mutex.RLock()
@@ -269,6 +283,32 @@ func (eb *EventBridge) GetAgentEvents() <-chan types.AgentEvent {
 	return eb.agentEvents
 }
 
+// BufferStats reports how full the event channel, circular buffer and batch buffer are
+type BufferStats struct {
+	ChannelLength    int
+	ChannelCapacity  int
+	CircularSize     int
+	CircularCapacity int
+	BatchSize        int
+	DroppedEvents    int64
+}
+
+// GetBufferStats returns the current occupancy of the event buffers
+func (eb *EventBridge) GetBufferStats() BufferStats {
+	eb.batchMutex.RLock()
+	batchSize := len(eb.batchBuffer)
+	eb.batchMutex.RUnlock()
+
+	return BufferStats{
+		ChannelLength:    len(eb.agentEvents),
+		ChannelCapacity:  cap(eb.agentEvents),
+		CircularSize:     eb.circularBuffer.Size(),
+		CircularCapacity: eb.circularBuffer.Capacity(),
+		BatchSize:        batchSize,
+		DroppedEvents:    atomic.LoadInt64(&eb.droppedEvents),
+	}
+}
+
 // processEvents processes Syncthing events and converts them to agent events
 func (eb *EventBridge) processEvents(ctx context.Context) {
 	defer func() {
@@ -730,6 +770,8 @@ func (eb *EventBridge) isHighPriorityEvent(eventType string) bool {
 		return true
 	case "sync_completed", "folder_sync_completed":
 		return true
+	case "conflict_detected", "conflict_removed":
+		return true
 	default:
 		return false
 	}
@@ -995,11 +1037,15 @@ func (eb *EventBridge) handleLocalChangeDetected(event embedded.Event) {
 		"folder_id":   getStringFromData(data, "folderID"),
 		"path":        getStringFromData(data, "path"),
 		"action":      getStringFromData(data, "action"), // added, modified, deleted
+		"item_type":   getStringFromData(data, "type"),   // file, dir, symlink
 		"size":        getInt64FromData(data, "size"),
 		"timestamp":   event.Time,
 	}
 	
 	eb.sendAgentEvent("local_change_detected", changeEvent)
+
+	// Conflict copies are created locally, when a pulled version conflicts with the local file
+	eb.detectConflictCopy(event, getStringFromData(data, "action"), getStringFromData(data, "type"))
 }
 
 // handleRemoteChangeDetected handles remote file change events  
@@ -1017,6 +1063,41 @@ func (eb *EventBridge) handleRemoteChangeDetected(event embedded.Event) {
 	}
 	
 	eb.sendAgentEvent("remote_change_detected", changeEvent)
+
+	// A conflict copy deleted on another device is deleted here too
+	if getStringFromData(data, "action") == "deleted" {
+		eb.detectConflictCopy(event, "deleted", getStringFromData(data, "type"))
+	}
+}
+
+// detectConflictCopy reports conflict copies (.sync-conflict-*) appearing in or removed from a folder
+func (eb *EventBridge) detectConflictCopy(event embedded.Event, action, itemType string) {
+	data := event.Data
+	folderID := getStringFromData(data, "folderID")
+	path := getStringFromData(data, "path")
+	if itemType != "file" || folderID == "" {
+		return
+	}
+	conflict, ok := embedded.ParseConflictCopy(path)
+	if !ok {
+		return
+	}
+
+	conflictEvent := ConflictEvent{
+		FolderID:     folderID,
+		ConflictCopy: *conflict,
+		Timestamp:    event.Time,
+	}
+
+	if action == "deleted" {
+		eb.sendAgentEvent("conflict_removed", conflictEvent)
+		return
+	}
+	if size, err := eb.syncthing.GetFileInfo(folderID, path); err == nil {
+		conflictEvent.Size = size
+	}
+	log.Printf("⚔️ Conflict copy in folder %s: %s (conflicts with %s)", folderID, conflict.Path, conflict.OriginalPath)
+	eb.sendAgentEvent("conflict_detected", conflictEvent)
 }
 
 // handleFolderScanProgress handles folder scan progress events
@@ -0,0 +1,301 @@
+// Package metrics writes metrics in the Prometheus text exposition format (version 0.0.4).
+// Gauges and counters owned by other components are written at scrape time with a Writer;
+// histograms and counters that have to be accumulated between scrapes use HistogramVec and CounterVec.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefaultDurationBuckets are histogram buckets in seconds for request and query latencies
+var DefaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Labels are the label names and values of a sample
+type Labels map[string]string
+
+// Writer writes metric families. Every family must be written in one go: Header once, then its samples.
+type Writer struct {
+	w       *bufio.Writer
+	written map[string]bool
+}
+
+// NewWriter returns a writer for the Prometheus text format; call Flush when done
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), written: make(map[string]bool)}
+}
+
+// Header writes the HELP and TYPE lines of a metric family, once per name
+func (w *Writer) Header(name, help, metricType string) {
+	if w.written[name] {
+		return
+	}
+	w.written[name] = true
+	fmt.Fprintf(w.w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w.w, "# TYPE %s %s\n", name, metricType)
+}
+
+// Sample writes one sample of the current metric family
+func (w *Writer) Sample(name string, labels Labels, value float64) {
+	w.w.WriteString(name)
+	writeLabels(w.w, labels)
+	w.w.WriteByte(' ')
+	w.w.WriteString(formatValue(value))
+	w.w.WriteByte('\n')
+}
+
+// Gauge writes an unlabeled gauge
+func (w *Writer) Gauge(name, help string, value float64) {
+	w.Header(name, help, TypeGauge)
+	w.Sample(name, nil, value)
+}
+
+// Counter writes an unlabeled counter
+func (w *Writer) Counter(name, help string, value float64) {
+	w.Header(name, help, TypeCounter)
+	w.Sample(name, nil, value)
+}
+
+// Flush writes buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Handler serves the metrics written by collect
+func Handler(collect func(w *Writer)) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", ContentType)
+		w := NewWriter(rw)
+		collect(w)
+		w.Flush()
+	}
+}
+
+// Bool returns 1 for true and 0 for false
+func Bool(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*counterChild
+}
+
+type counterChild struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec returns a counter with the given label names
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterChild)}
+}
+
+// Add adds a non-negative value to the counter with the given label values
+func (v *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	child, ok := v.values[key]
+	if !ok {
+		child = &counterChild{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = child
+	}
+	child.value += value
+}
+
+// Inc increments the counter with the given label values
+func (v *CounterVec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Write writes the counter family
+func (v *CounterVec) Write(w *Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.Header(v.name, v.help, TypeCounter)
+	for _, key := range keys {
+		child := v.values[key]
+		w.Sample(v.name, labelsOf(v.labelNames, child.labelValues), child.value)
+	}
+}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*histogramChild
+}
+
+type histogramChild struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec returns a histogram with the given upper bounds and label names
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &HistogramVec{name: name, help: help, buckets: sorted, labelNames: labelNames, values: make(map[string]*histogramChild)}
+}
+
+// Observe records a value in the histogram with the given label values
+func (v *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	child, ok := v.values[key]
+	if !ok {
+		child = &histogramChild{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(v.buckets))}
+		v.values[key] = child
+	}
+	for i, upperBound := range v.buckets {
+		if value <= upperBound {
+			child.counts[i]++
+			break
+		}
+	}
+	child.count++
+	child.sum += value
+}
+
+// Write writes the histogram family
+func (v *HistogramVec) Write(w *Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.Header(v.name, v.help, TypeHistogram)
+	for _, key := range keys {
+		child := v.values[key]
+		labels := labelsOf(v.labelNames, child.labelValues)
+
+		var cumulative uint64
+		for i, upperBound := range v.buckets {
+			cumulative += child.counts[i]
+			w.Sample(v.name+"_bucket", withLabel(labels, "le", formatValue(upperBound)), float64(cumulative))
+		}
+		w.Sample(v.name+"_bucket", withLabel(labels, "le", "+Inf"), float64(child.count))
+		w.Sample(v.name+"_sum", labels, child.sum)
+		w.Sample(v.name+"_count", labels, float64(child.count))
+	}
+}
+
+func labelsOf(names, values []string) Labels {
+	labels := Labels{}
+	for i, name := range names {
+		if i < len(values) {
+			labels[name] = values[i]
+		} else {
+			labels[name] = ""
+		}
+	}
+	return labels
+}
+
+func withLabel(labels Labels, name, value string) Labels {
+	result := Labels{name: value}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+func writeLabels(w *bufio.Writer, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(name)
+		w.WriteString(`="`)
+		w.WriteString(escapeLabelValue(labels[name]))
+		w.WriteByte('"')
+	}
+	w.WriteByte('}')
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
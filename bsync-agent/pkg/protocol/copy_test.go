@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// otherModule maps each module holding this package to the module with the other copy
+var otherModule = map[string]string{
+	"bsync-server": "bsync-agent",
+	"bsync-agent":  "bsync-server",
+}
+
+// goFiles returns the Go files of a package directory by name
+func goFiles(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = data
+	}
+	return files
+}
+
+// TestCopiesIdentical fails when this package differs from its copy in the other module. Change
+// bsync-server/pkg/protocol and copy it with: cp bsync-server/pkg/protocol/*.go bsync-agent/pkg/protocol/
+func TestCopiesIdentical(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := filepath.Base(filepath.Dir(filepath.Dir(dir)))
+	other, ok := otherModule[module]
+	if !ok {
+		t.Skipf("package is not in a known module (%s)", module)
+	}
+	otherDir := filepath.Join(dir, "..", "..", "..", other, "pkg", "protocol")
+	if _, err := os.Stat(otherDir); os.IsNotExist(err) {
+		t.Skipf("%s is not checked out next to %s", other, module)
+	}
+
+	files, otherFiles := goFiles(t, dir), goFiles(t, otherDir)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	for name := range otherFiles {
+		if _, ok := files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, ok := files[name]
+		otherData, otherOk := otherFiles[name]
+		switch {
+		case !ok:
+			t.Errorf("%s only exists in %s/pkg/protocol", name, other)
+		case !otherOk:
+			t.Errorf("%s only exists in %s/pkg/protocol", name, module)
+		case !bytes.Equal(data, otherData):
+			t.Errorf("%s differs between %s/pkg/protocol and %s/pkg/protocol", name, module, other)
+		}
+	}
+}
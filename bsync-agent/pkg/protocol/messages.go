@@ -0,0 +1,442 @@
+package protocol
+
+import (
+	"time"
+)
+
+// Register is sent by the agent right after connecting (and after every reconnect)
+type Register struct {
+	Type               string `json:"type"`
+	AgentID            string `json:"agent_id"`
+	DeviceID           string `json:"device_id"`
+	DataDir            string `json:"data_dir,omitempty"`
+	ProtocolVersion    int    `json:"protocol_version,omitempty"`     // Highest version spoken by the agent; 0 for legacy agents
+	MinProtocolVersion int    `json:"min_protocol_version,omitempty"` // Lowest version accepted by the agent
+}
+
+// NewRegister returns a register message announcing the local protocol versions
+func NewRegister(agentID, deviceID, dataDir string) *Register {
+	return &Register{
+		Type:               TypeRegister,
+		AgentID:            agentID,
+		DeviceID:           deviceID,
+		DataDir:            dataDir,
+		ProtocolVersion:    Version,
+		MinProtocolVersion: MinVersion,
+	}
+}
+
+func (m *Register) MessageType() string { return TypeRegister }
+
+func (m *Register) Validate() error {
+	if m.AgentID == "" {
+		return missingField("agent_id")
+	}
+	return nil
+}
+
+// RegisterAck answers a register message from an agent that announced a protocol version.
+// Legacy servers never send it, so agents assume LegacyVersion until it arrives.
+type RegisterAck struct {
+	Type            string `json:"type"`
+	ProtocolVersion int    `json:"protocol_version"` // Negotiated version, 0 if negotiation failed
+	ServerVersion   int    `json:"server_protocol_version"`
+	Error           string `json:"error,omitempty"`
+}
+
+func (m *RegisterAck) MessageType() string { return TypeRegisterAck }
+
+func (m *RegisterAck) Validate() error {
+	if m.ProtocolVersion == 0 && m.Error == "" {
+		return missingField("protocol_version")
+	}
+	return nil
+}
+
+// Health is the periodic heartbeat of an agent
+type Health struct {
+	Type       string                 `json:"type"`
+	AgentID    string                 `json:"agent_id"`
+	SystemInfo map[string]interface{} `json:"system_info,omitempty"`
+	DataDir    string                 `json:"data_dir,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+func (m *Health) MessageType() string { return TypeHealth }
+
+func (m *Health) Validate() error {
+	if m.AgentID == "" {
+		return missingField("agent_id")
+	}
+	return nil
+}
+
+// SessionEvent reports a sync session lifecycle event (session_started, scan_completed, ...)
+type SessionEvent struct {
+	Type  string              `json:"type"`
+	Event SessionEventPayload `json:"event"`
+}
+
+// SessionEventPayload is the event carried by a SessionEvent
+type SessionEventPayload struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+func (m *SessionEvent) MessageType() string { return TypeSessionEvent }
+
+func (m *SessionEvent) Validate() error {
+	if m.Event.Type == "" {
+		return missingField("event.type")
+	}
+	if m.Event.Data == nil {
+		return missingField("event.data")
+	}
+	return nil
+}
+
+// FolderStatsPeriodic carries the folder statistics an agent sends while a job is syncing
+type FolderStatsPeriodic struct {
+	Type       string                 `json:"type"`
+	JobID      string                 `json:"job_id"`
+	FolderID   string                 `json:"folder_id"`
+	AgentID    string                 `json:"agent_id"`
+	Stats      map[string]interface{} `json:"stats"`
+	IsPeriodic bool                   `json:"is_periodic"`
+	Progress   map[string]interface{} `json:"progress,omitempty"`
+}
+
+func (m *FolderStatsPeriodic) MessageType() string { return TypeFolderStatsPeriodic }
+
+func (m *FolderStatsPeriodic) Validate() error {
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	if m.FolderID == "" {
+		return missingField("folder_id")
+	}
+	return nil
+}
+
+// DeployJob creates or updates the Syncthing folder of a job on the source or a destination agent.
+// Multi-destination jobs send the *_ids / *_addresses arrays to the source agent instead of the
+// single destination fields.
+type DeployJob struct {
+	Type                   string   `json:"type"`
+	JobID                  string   `json:"job_id"`
+	Name                   string   `json:"name"`
+	SourceAgentID          string   `json:"source_agent_id"`
+	DestinationAgentID     string   `json:"destination_agent_id,omitempty"`
+	DestinationAgentIDs    []string `json:"destination_agent_ids,omitempty"`
+	SourceDeviceID         string   `json:"source_device_id,omitempty"`
+	DestinationDeviceID    string   `json:"destination_device_id,omitempty"`
+	DestinationDeviceIDs   []string `json:"destination_device_ids,omitempty"`
+	SourceIPAddress        string   `json:"source_ip_address,omitempty"`
+	DestinationIPAddress   string   `json:"destination_ip_address,omitempty"`
+	DestinationIPAddresses []string `json:"destination_ip_addresses,omitempty"`
+	SourcePath             string   `json:"source_path,omitempty"`
+	DestinationPath        string   `json:"destination_path,omitempty"`
+	SyncType               string   `json:"sync_type"`
+	RescanIntervalS        *int     `json:"rescan_interval_s,omitempty"` // nil uses the agent default; 0 disables rescans (scheduled jobs)
+	IgnorePatterns         []string `json:"ignore_patterns,omitempty"`
+	IsMultiDestination     bool     `json:"is_multi_destination,omitempty"`
+
+	// Policies are defined by the server and interpreted by the agent
+	MaintenanceWindows interface{} `json:"maintenance_windows,omitempty"`
+	BandwidthLimit     interface{} `json:"bandwidth_limit,omitempty"`
+	Versioning         interface{} `json:"versioning,omitempty"`
+	DiskGuard          interface{} `json:"disk_guard,omitempty"`
+	DeletionGuard      interface{} `json:"deletion_guard,omitempty"`
+	Hooks              interface{} `json:"hooks,omitempty"`           // commands run around the syncs, by role
+	ConflictPolicy     interface{} `json:"conflict_policy,omitempty"` // resolves conflict copies automatically
+}
+
+func (m *DeployJob) MessageType() string { return TypeDeployJob }
+
+func (m *DeployJob) Validate() error {
+	switch {
+	case m.JobID == "":
+		return missingField("job_id")
+	case m.Name == "":
+		return missingField("name")
+	case m.SyncType == "":
+		return missingField("sync_type")
+	case m.SourceAgentID == "":
+		return missingField("source_agent_id")
+	}
+	return nil
+}
+
+// JobControl pauses, resumes or deletes a deployed job (pause_job, resume_job, delete_job)
+type JobControl struct {
+	Type     string `json:"type"`
+	JobID    string `json:"job_id"`
+	FolderID string `json:"folder_id,omitempty"`
+	Reason   string `json:"reason,omitempty"` // e.g. maintenance_window or deletion_guard for automatic pauses
+}
+
+// NewJobControl returns a job control message of the given type
+func NewJobControl(msgType, jobID, reason string) *JobControl {
+	return &JobControl{Type: msgType, JobID: jobID, Reason: reason}
+}
+
+func (m *JobControl) MessageType() string { return m.Type }
+
+func (m *JobControl) Validate() error {
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	return nil
+}
+
+// CheckDiskSpace asks an agent for the free space at a path before a job is deployed there
+type CheckDiskSpace struct {
+	Type        string `json:"type"`
+	RequestID   string `json:"request_id"`
+	JobID       string `json:"job_id,omitempty"`
+	Path        string `json:"path"`
+	MeasureSize bool   `json:"measure_size,omitempty"` // also report the size of the data at the path
+}
+
+func (m *CheckDiskSpace) MessageType() string { return TypeCheckDiskSpace }
+
+func (m *CheckDiskSpace) Validate() error {
+	if m.RequestID == "" {
+		return missingField("request_id")
+	}
+	if m.Path == "" {
+		return missingField("path")
+	}
+	return nil
+}
+
+// DiskSpaceResponse answers CheckDiskSpace
+type DiskSpaceResponse struct {
+	Type         string `json:"type"`
+	RequestID    string `json:"request_id"`
+	JobID        string `json:"job_id,omitempty"`
+	Path         string `json:"path"`
+	TotalBytes   int64  `json:"total_bytes"`
+	FreeBytes    int64  `json:"free_bytes"`
+	UsedBytes    int64  `json:"used_bytes"`
+	FolderBytes  int64  `json:"folder_bytes,omitempty"`  // data the job folder already holds at the path
+	SizeBytes    int64  `json:"size_bytes,omitempty"`    // size of the data at the path, with measure_size
+	SizeComplete bool   `json:"size_complete,omitempty"` // false if measuring timed out, size_bytes is then a lower bound
+	Error        string `json:"error,omitempty"`
+}
+
+func (m *DiskSpaceResponse) MessageType() string { return TypeDiskSpaceResponse }
+
+func (m *DiskSpaceResponse) Validate() error {
+	if m.RequestID == "" {
+		return missingField("request_id")
+	}
+	return nil
+}
+
+// DiskSpaceReport tells the server that the disk guard of a destination paused a job folder
+// (disk_space_low, repeated while it stays paused) or resumed it (disk_space_recovered)
+type DiskSpaceReport struct {
+	Type           string     `json:"type"`
+	JobID          string     `json:"job_id"`
+	FolderID       string     `json:"folder_id,omitempty"`
+	Path           string     `json:"path"`
+	FreeBytes      int64      `json:"free_bytes,omitempty"`
+	TotalBytes     int64      `json:"total_bytes,omitempty"`
+	ThresholdBytes int64      `json:"threshold_bytes,omitempty"`
+	Since          *time.Time `json:"since,omitempty"` // when the folder was paused
+}
+
+func (m *DiskSpaceReport) MessageType() string { return m.Type }
+
+func (m *DiskSpaceReport) Validate() error {
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	return nil
+}
+
+// DeletionGuardTriggered tells the server that the source agent paused a job after a large
+// deletion; the destinations are paused until an administrator confirms the deletions
+type DeletionGuardTriggered struct {
+	Type         string    `json:"type"`
+	JobID        string    `json:"job_id"`
+	FolderID     string    `json:"folder_id,omitempty"`
+	DeletedFiles int64     `json:"deleted_files"`
+	TotalFiles   int64     `json:"total_files"`
+	SamplePaths  []string  `json:"sample_paths,omitempty"`
+	Since        time.Time `json:"since"`
+}
+
+func (m *DeletionGuardTriggered) MessageType() string { return TypeDeletionGuardTriggered }
+
+func (m *DeletionGuardTriggered) Validate() error {
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	return nil
+}
+
+// BuildManifest asks an agent to hash the files of a job folder for a verification
+type BuildManifest struct {
+	Type           string `json:"type"`
+	RequestID      string `json:"request_id"`
+	JobID          string `json:"job_id"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // the server gives up after this, 0 means no limit
+}
+
+func (m *BuildManifest) MessageType() string { return TypeBuildManifest }
+
+func (m *BuildManifest) Validate() error {
+	if m.RequestID == "" {
+		return missingField("request_id")
+	}
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	return nil
+}
+
+// ManifestEntry is a file of a job folder as hashed by an agent
+type ManifestEntry struct {
+	Path    string    `json:"path"` // relative to the folder, slash separated
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// ManifestResponse answers BuildManifest with the files sorted by path
+type ManifestResponse struct {
+	Type       string          `json:"type"`
+	RequestID  string          `json:"request_id"`
+	JobID      string          `json:"job_id"`
+	FolderID   string          `json:"folder_id,omitempty"`
+	Data       []ManifestEntry `json:"data"`
+	TotalFiles int             `json:"total_files"`
+	TotalBytes int64           `json:"total_bytes"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func (m *ManifestResponse) MessageType() string { return TypeManifestResponse }
+
+func (m *ManifestResponse) Validate() error {
+	if m.RequestID == "" {
+		return missingField("request_id")
+	}
+	return nil
+}
+
+// HookResult reports a pre-scan or post-session hook run by an agent. Pre-scan results carry the
+// session the scan started, or none if the scan was skipped or failed.
+type HookResult struct {
+	Type        string    `json:"type"`
+	JobID       string    `json:"job_id"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Hook        string    `json:"hook"` // pre_scan or post_session
+	Command     string    `json:"command"`
+	ExitCode    int       `json:"exit_code"` // -1 if the command could not be started or was killed
+	Output      string    `json:"output"`
+	Truncated   bool      `json:"truncated,omitempty"`
+	TimedOut    bool      `json:"timed_out,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ScanSkipped bool      `json:"scan_skipped,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+func (m *HookResult) MessageType() string { return TypeHookResult }
+
+func (m *HookResult) Validate() error {
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	if m.Hook == "" {
+		return missingField("hook")
+	}
+	return nil
+}
+
+// ResolveConflict asks the agent that reported a conflict copy to apply the resolution chosen on the server
+type ResolveConflict struct {
+	Type         string `json:"type"`
+	RequestID    string `json:"request_id"`
+	JobID        string `json:"job_id"`
+	Path         string `json:"path"` // conflict copy
+	OriginalPath string `json:"original_path,omitempty"`
+	Resolution   string `json:"resolution"` // local (the copy replaces the file), remote (the copy is deleted) or both
+}
+
+func (m *ResolveConflict) MessageType() string { return TypeResolveConflict }
+
+func (m *ResolveConflict) Validate() error {
+	switch {
+	case m.RequestID == "":
+		return missingField("request_id")
+	case m.JobID == "":
+		return missingField("job_id")
+	case m.Path == "":
+		return missingField("path")
+	case m.Resolution == "":
+		return missingField("resolution")
+	}
+	return nil
+}
+
+// ConflictResolveResponse answers ResolveConflict
+type ConflictResolveResponse struct {
+	Type       string `json:"type"`
+	RequestID  string `json:"request_id"`
+	JobID      string `json:"job_id"`
+	Path       string `json:"path"`
+	Resolution string `json:"resolution,omitempty"`
+	RenamedTo  string `json:"renamed_to,omitempty"` // new name of the copy when both versions are kept
+	Error      string `json:"error,omitempty"`
+}
+
+func (m *ConflictResolveResponse) MessageType() string { return TypeConflictResolveResponse }
+
+func (m *ConflictResolveResponse) Validate() error {
+	if m.RequestID == "" {
+		return missingField("request_id")
+	}
+	return nil
+}
+
+// ConflictEvent is the data of a conflict_detected or conflict_removed event message: a conflict
+// copy appeared in or was removed from a job folder
+type ConflictEvent struct {
+	FolderID     string    `json:"folder_id"`
+	Path         string    `json:"path"`          // conflict copy, slash separated
+	OriginalPath string    `json:"original_path"` // file the copy conflicted with
+	ModifiedBy   string    `json:"modified_by"`   // short device ID in the copy name
+	ConflictTime time.Time `json:"conflict_time"`
+	Size         int64     `json:"size,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// ConflictAutoResolved reports a conflict copy resolved by the agent following the conflict
+// policy of the job; the decision is logged with the file transfers of the job
+type ConflictAutoResolved struct {
+	Type         string    `json:"type"`
+	JobID        string    `json:"job_id"`
+	Path         string    `json:"path"` // conflict copy
+	OriginalPath string    `json:"original_path"`
+	Strategy     string    `json:"strategy"`
+	Resolution   string    `json:"resolution"` // local, remote or both
+	Reason       string    `json:"reason,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	DecidedAt    time.Time `json:"decided_at"`
+	Error        string    `json:"error,omitempty"` // the decision could not be applied
+}
+
+func (m *ConflictAutoResolved) MessageType() string { return TypeConflictAutoResolved }
+
+func (m *ConflictAutoResolved) Validate() error {
+	if m.JobID == "" {
+		return missingField("job_id")
+	}
+	if m.Path == "" {
+		return missingField("path")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package protocol defines the typed, versioned messages exchanged between agents and the server
+// over /ws/agent. The same package exists in bsync-agent and bsync-server; keep both copies identical
+// (TestCopiesIdentical fails when they differ).
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Protocol versions. Version 1 is the untyped protocol spoken by agents and servers that do not
+// negotiate; version 2 adds typed messages and negotiation with register / register_ack.
+// Adding optional fields to a message needs no new version; bump Version for incompatible changes.
+const (
+	LegacyVersion = 1
+	Version       = 2
+	MinVersion    = LegacyVersion
+)
+
+// Message types sent by agents
+const (
+	TypeRegister                   = "register"
+	TypeHealth                     = "health"
+	TypeEvent                      = "event"
+	TypeSessionEvent               = "session_event"
+	TypeFolderStatsResponse        = "folder_stats_response"
+	TypeFolderStatsPeriodic        = "folder_stats_periodic"
+	TypeFolderStatsError           = "folder_stats_error"
+	TypeBrowseResponse             = "browse_response"
+	TypeBrowseError                = "browse_error"
+	TypeJobDeployed                = "job_deployed"
+	TypeJobDeployError             = "job_deploy_error"
+	TypeJobPaused                  = "job_paused"
+	TypeJobPauseError              = "job_pause_error"
+	TypeJobResumed                 = "job_resumed"
+	TypeJobResumeError             = "job_resume_error"
+	TypeJobDeleted                 = "job_deleted"
+	TypeJobDeleteError             = "job_delete_error"
+	TypeFileVersionsResponse       = "file_versions_response"
+	TypeFileVersionsRestore        = "file_versions_restore_response"
+	TypeCertificateRequestResponse = "certificate_request_response"
+	TypeDiskSpaceResponse          = "disk_space_response"
+	TypeDiskSpaceLow               = "disk_space_low"
+	TypeDiskSpaceRecovered         = "disk_space_recovered"
+	TypeDeletionGuardTriggered     = "deletion_guard_triggered"
+	TypeManifestResponse           = "manifest_response"
+	TypeHookResult                 = "hook_result"
+	TypeConflictResolveResponse    = "conflict_resolve_response"
+	TypeConflictAutoResolved       = "conflict_auto_resolved"
+)
+
+// Agent event types carried in the event of an event message
+const (
+	EventConflictDetected = "conflict_detected"
+	EventConflictRemoved  = "conflict_removed"
+)
+
+// Message types sent by the server
+const (
+	TypeRegisterAck              = "register_ack"
+	TypeDeployJob                = "deploy_job"
+	TypePauseJob                 = "pause_job"
+	TypeResumeJob                = "resume_job"
+	TypeDeleteJob                = "delete_job"
+	TypeMaintenanceWindows       = "maintenance_windows"
+	TypeSetBandwidthLimits       = "set_bandwidth_limits"
+	TypeListFileVersions         = "list_file_versions"
+	TypeRestoreFileVersions      = "restore_file_versions"
+	TypeCreateCertificateRequest = "create_certificate_request"
+	TypeInstallCertificate       = "install_certificate"
+	TypeBrowseFolders            = "browse_folders"
+	TypeGetFolderStats           = "get_folder_stats"
+	TypeCheckDiskSpace           = "check_disk_space"
+	TypeBuildManifest            = "build_manifest"
+	TypeResolveConflict          = "resolve_conflict"
+)
+
+// Message is implemented by all typed messages
+type Message interface {
+	// MessageType returns the value of the "type" field
+	MessageType() string
+	// Validate checks required fields after decoding
+	Validate() error
+}
+
+// DecodeError describes why a message could not be decoded
+type DecodeError struct {
+	Type  string // Message type
+	Field string // Offending field, empty if the error is not about one field
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("invalid %s message: field %q: %v", e.Type, e.Field, e.Err)
+	}
+	return fmt.Sprintf("invalid %s message: %v", e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Negotiate picks the protocol version both sides speak: the highest version up to
+// peerVersion that is supported locally. Peers that send no version are LegacyVersion.
+func Negotiate(peerVersion, peerMinVersion int) (int, error) {
+	if peerVersion <= 0 {
+		peerVersion = LegacyVersion
+	}
+	if peerMinVersion <= 0 {
+		peerMinVersion = LegacyVersion
+	}
+
+	version := peerVersion
+	if version > Version {
+		version = Version
+	}
+	if version < MinVersion || version < peerMinVersion {
+		return 0, fmt.Errorf("no common protocol version (local %d-%d, peer %d-%d)",
+			MinVersion, Version, peerMinVersion, peerVersion)
+	}
+	return version, nil
+}
+
+// Decode decodes a raw JSON message into msg: the type must match, field types must match and
+// required fields must be present. Unknown fields are ignored, so fields added to a message
+// don't break peers that don't know them yet.
+func Decode(data []byte, msg Message) error {
+	expected := msg.MessageType()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if err := decoder.Decode(msg); err != nil {
+		return &DecodeError{Type: expected, Field: fieldOf(err), Err: cleanError(err)}
+	}
+
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(data, &envelope)
+	if envelope.Type != expected {
+		return &DecodeError{Type: expected, Field: "type", Err: fmt.Errorf("got %q", envelope.Type)}
+	}
+
+	if err := msg.Validate(); err != nil {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.Type = expected
+			return decodeErr
+		}
+		return &DecodeError{Type: expected, Err: err}
+	}
+	return nil
+}
+
+// DecodeMap decodes a message that was already unmarshalled into a map
+func DecodeMap(data map[string]interface{}, msg Message) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return &DecodeError{Type: msg.MessageType(), Err: err}
+	}
+	return Decode(raw, msg)
+}
+
+// ToMap converts a typed message into the map form used by the WebSocket send queues
+func ToMap(msg Message) map[string]interface{} {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return map[string]interface{}{"type": msg.MessageType()}
+	}
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	m["type"] = msg.MessageType()
+	return m
+}
+
+// missingField returns the error Validate reports for an absent required field
+func missingField(field string) error {
+	return &DecodeError{Field: field, Err: errors.New("required field is missing")}
+}
+
+// fieldOf extracts the field name from encoding/json errors
+func fieldOf(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return typeErr.Field
+	}
+	return ""
+}
+
+// cleanError rewrites encoding/json errors into a short description
+func cleanError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		peerVersion    int
+		peerMinVersion int
+		want           int
+		wantErr        bool
+	}{
+		{name: "legacy peer", want: LegacyVersion},
+		{name: "same version", peerVersion: Version, peerMinVersion: MinVersion, want: Version},
+		{name: "newer peer", peerVersion: Version + 1, peerMinVersion: MinVersion, want: Version},
+		{name: "older peer", peerVersion: LegacyVersion, peerMinVersion: LegacyVersion, want: LegacyVersion},
+		{name: "newer peer still accepting ours", peerVersion: Version + 2, peerMinVersion: Version, want: Version},
+		{name: "newer peer dropped ours", peerVersion: Version + 2, peerMinVersion: Version + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Negotiate(tt.peerVersion, tt.peerMinVersion)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Negotiate(%d, %d) error = %v, wantErr %v", tt.peerVersion, tt.peerMinVersion, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Negotiate(%d, %d) = %d, want %d", tt.peerVersion, tt.peerMinVersion, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		msg       Message
+		wantField string
+		wantErr   bool
+	}{
+		{
+			name: "register from this version",
+			data: `{"type":"register","agent_id":"a1","device_id":"D1","protocol_version":2,"min_protocol_version":1}`,
+			msg:  &Register{},
+		},
+		{
+			name: "register from a legacy agent",
+			data: `{"type":"register","agent_id":"a1","device_id":"D1"}`,
+			msg:  &Register{},
+		},
+		{
+			name: "register with fields from a newer agent",
+			data: `{"type":"register","agent_id":"a1","device_id":"D1","protocol_version":3,"capabilities":["x"]}`,
+			msg:  &Register{},
+		},
+		{
+			name: "deploy_job with policies unknown to an older agent",
+			data: `{"type":"deploy_job","job_id":"1","name":"n","source_agent_id":"a1","sync_type":"sendreceive","future_policy":{"on":true}}`,
+			msg:  &DeployJob{},
+		},
+		{
+			name: "deploy_job with policies",
+			data: `{"type":"deploy_job","job_id":"1","name":"n","source_agent_id":"a1","sync_type":"sendreceive",` +
+				`"disk_guard":{"min_free_percent":5},"hooks":{"source":[]},"conflict_policy":"newest_wins"}`,
+			msg: &DeployJob{},
+		},
+		{
+			name:      "wrong type",
+			data:      `{"type":"health","agent_id":"a1"}`,
+			msg:       &Register{},
+			wantField: "type",
+			wantErr:   true,
+		},
+		{
+			name:      "missing required field",
+			data:      `{"type":"deploy_job","job_id":"1","name":"n","sync_type":"sendreceive"}`,
+			msg:       &DeployJob{},
+			wantField: "source_agent_id",
+			wantErr:   true,
+		},
+		{
+			name:      "wrong field type",
+			data:      `{"type":"register","agent_id":"a1","protocol_version":"2"}`,
+			msg:       &Register{},
+			wantField: "protocol_version",
+			wantErr:   true,
+		},
+		{
+			name:    "not JSON",
+			data:    `{"type":`,
+			msg:     &Register{},
+			wantErr: true,
+		},
+		{
+			name: "job control type from the message",
+			data: `{"type":"pause_job","job_id":"1","reason":"maintenance_window"}`,
+			msg:  &JobControl{Type: TypePauseJob},
+		},
+		{
+			name:      "job control of another type",
+			data:      `{"type":"resume_job","job_id":"1"}`,
+			msg:       &JobControl{Type: TypePauseJob},
+			wantField: "type",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Decode([]byte(tt.data), tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Decode() error %T is not a *DecodeError", err)
+			}
+			if decodeErr.Field != tt.wantField {
+				t.Errorf("field = %q, want %q", decodeErr.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestToMapDecodeMap(t *testing.T) {
+	interval := 0
+	sent := &DeployJob{
+		Type:            TypeDeployJob,
+		JobID:           "1",
+		Name:            "n",
+		SourceAgentID:   "a1",
+		SyncType:        "sendonly",
+		RescanIntervalS: &interval,
+	}
+
+	m := ToMap(sent)
+	if m["type"] != TypeDeployJob {
+		t.Fatalf("type = %v, want %s", m["type"], TypeDeployJob)
+	}
+
+	var received DeployJob
+	if err := DecodeMap(m, &received); err != nil {
+		t.Fatalf("DecodeMap() error = %v", err)
+	}
+	if received.JobID != sent.JobID || received.RescanIntervalS == nil || *received.RescanIntervalS != 0 {
+		t.Errorf("received %+v, want %+v", received, sent)
+	}
+}
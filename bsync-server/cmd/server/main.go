@@ -34,25 +34,53 @@ func main() {
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
 	log.Printf("🚀 Starting BSync Server")
-	log.Printf("📡 Server configuration:")
-	log.Printf("  Host: %s", *host)
-	log.Printf("  Port: %d", *port)
-	log.Printf("  Log Level: %s", *logLevel)
-	log.Printf("  TLS Enabled: %v", *tlsEnabled)
+
+	// Build configuration: defaults < config file < environment < command line flags
+	config := server.DefaultConfig()
+
 	if *configPath != "" {
-		log.Printf("  Config: %s", *configPath)
+		if err := config.LoadFromFile(*configPath); err != nil {
+			log.Fatalf("❌ Failed to load config file: %v", err)
+		}
 	}
 
-	config := &server.Config{
-		Host:     *host,
-		Port:     *port,
-		LogLevel: *logLevel,
+	if err := config.ApplyEnvOverrides(); err != nil {
+		log.Fatalf("❌ %v", err)
 	}
 
-	if *configPath != "" {
-		if err := config.LoadFromFile(*configPath); err != nil {
-			log.Printf("⚠️  Failed to load config file: %v", err)
+	// Only flags given explicitly override the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			config.Port = *port
+		case "host":
+			config.Host = *host
+		case "log-level":
+			config.LogLevel = *logLevel
+		case "tls":
+			config.TLS.Enabled = *tlsEnabled
+		case "cert":
+			config.TLS.CertFile = *certFile
+		case "key":
+			config.TLS.KeyFile = *keyFile
+		case "auto-tls":
+			config.TLS.AutoTLS = *autoTLS
 		}
+	})
+
+	if err := config.Validate(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	log.Printf("📡 Server configuration:")
+	log.Printf("  Host: %s", config.Host)
+	log.Printf("  Port: %d", config.Port)
+	log.Printf("  Log Level: %s", config.LogLevel)
+	log.Printf("  TLS Enabled: %v", config.TLS.Enabled)
+	log.Printf("  Event Store: %s", config.EventStore.Type)
+	log.Printf("  Scheduler Enabled: %v", config.Scheduler.Enabled)
+	if *configPath != "" {
+		log.Printf("  Config: %s", *configPath)
 	}
 
 	srv, err := server.NewSyncToolServer(config)
@@ -60,19 +88,14 @@ func main() {
 		log.Fatalf("❌ Failed to create server: %v", err)
 	}
 
-	tlsConfig := &server.TLSConfig{
-		Enabled:  *tlsEnabled,
-		CertFile: *certFile,
-		KeyFile:  *keyFile,
-		AutoTLS:  *autoTLS,
-	}
+	tlsConfig := &config.TLS
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	errChan := make(chan error, 1)
 	go func() {
-		if *tlsEnabled {
+		if tlsConfig.Enabled {
 			if err := srv.StartWithTLS(tlsConfig); err != nil {
 				errChan <- err
 			}
@@ -1,6 +1,9 @@
 package config
 
-import "os"
+import (
+	"os"
+	"sync"
+)
 
 type SMTPConfig struct {
 	Host     string
@@ -10,22 +13,73 @@ type SMTPConfig struct {
 	From     string
 }
 
+var (
+	settingsMu sync.RWMutex
+	smtpConfig *SMTPConfig
+	webURL     string
+)
+
+// SetSMTPConfig registers the SMTP settings loaded from the server config file
+func SetSMTPConfig(cfg *SMTPConfig) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	if cfg != nil && cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	smtpConfig = cfg
+}
+
+// LoadSMTPConfig returns the SMTP settings from the server config file,
+// falling back to SMTP_* environment variables
 func LoadSMTPConfig() *SMTPConfig {
-	return &SMTPConfig{
-		Host:     "smtp.gmail.com",
-		Port:     "587",
-		Username: "gustomanchannel@gmail.com",
-		Password: "blepfefvxwahjtft", // gunakan App Password
-		From:     "gustomanchannel@gmail.com",
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	if smtpConfig != nil {
+		cfg := *smtpConfig
+		return &cfg
+	}
+
+	cfg := &SMTPConfig{
+		Host:     getEnv("SMTP_HOST", "smtp.gmail.com"),
+		Port:     getEnv("SMTP_PORT", "587"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"), // gunakan App Password
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
 	}
+	return cfg
+}
+
+// SetWebURL registers the web application URL from the server config file
+func SetWebURL(url string) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	webURL = url
 }
 
 // GetWebURL returns the web application URL for email links
 func GetWebURL() string {
-	// Try to get from environment variable first
-	if webURL := os.Getenv("WEB_URL"); webURL != "" {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	if webURL != "" {
 		return webURL
 	}
+	// Try to get from environment variable first
+	if envURL := os.Getenv("WEB_URL"); envURL != "" {
+		return envURL
+	}
 	// Default to localhost for development
 	return "http://192.168.50.157:8000/"
 }
+
+func getEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
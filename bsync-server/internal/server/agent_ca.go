@@ -0,0 +1,659 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	agentCAValidity       = 10 * 365 * 24 * time.Hour
+	serverCertValidity    = 365 * 24 * time.Hour
+	certRotationInterval  = 1 * time.Hour
+	agentCACommonName     = "BSync Agent CA"
+	agentCertOrganization = "BSync Agents"
+)
+
+// AgentCA is the built-in certificate authority that issues client certificates to agents
+type AgentCA struct {
+	dir     string
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+
+	mutex   sync.Mutex
+	issuing map[string]bool // agent_id -> certificate issuance in progress
+}
+
+// AgentCertificate describes a client certificate issued to an agent
+type AgentCertificate struct {
+	ID           int        `json:"id"`
+	AgentID      string     `json:"agent_id"`
+	SerialNumber string     `json:"serial_number"`
+	Fingerprint  string     `json:"fingerprint"`
+	NotBefore    time.Time  `json:"not_before"`
+	NotAfter     time.Time  `json:"not_after"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+	Status       string     `json:"status"` // active, expired, revoked
+}
+
+// loadOrCreateAgentCA loads ca.crt/ca.key from dir, creating a new CA on first start
+func loadOrCreateAgentCA(dir string) (*AgentCA, error) {
+	certFile := filepath.Join(dir, "ca.crt")
+	keyFile := filepath.Join(dir, "ca.key")
+
+	ca := &AgentCA{dir: dir, issuing: make(map[string]bool)}
+
+	certPEM, certErr := ioutil.ReadFile(certFile)
+	keyPEM, keyErr := ioutil.ReadFile(keyFile)
+	if certErr == nil && keyErr == nil {
+		cert, err := parseCertificatePEM(certPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA certificate %s: %w", certFile, err)
+		}
+		keyBlock, _ := pem.Decode(keyPEM)
+		if keyBlock == nil {
+			return nil, fmt.Errorf("invalid CA key %s: no PEM data", keyFile)
+		}
+		key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA key %s: %w", keyFile, err)
+		}
+		ca.cert, ca.certPEM, ca.key = cert, certPEM, key
+		log.Printf("🔐 Loaded agent CA from %s (expires %s)", dir, cert.NotAfter.Format("2006-01-02"))
+		return ca, nil
+	}
+	if !os.IsNotExist(certErr) || !os.IsNotExist(keyErr) {
+		return nil, fmt.Errorf("agent CA in %s is incomplete (need ca.crt and ca.key)", dir)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: agentCACommonName, Organization: []string{agentCertOrganization}},
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              time.Now().Add(agentCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ca.cert, ca.key = cert, key
+	ca.certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(certFile, ca.certPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔐 Created agent CA in %s", dir)
+	return ca, nil
+}
+
+// CertPool returns a pool containing the CA certificate, used to verify agent client certificates
+func (ca *AgentCA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// signAgentCSR issues a client certificate for agentID from a PEM certificate request.
+// The subject always comes from agentID, never from the request.
+func (ca *AgentCA) signAgentCSR(csrPEM []byte, agentID string, lifetime time.Duration) (*x509.Certificate, []byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, nil, fmt.Errorf("no PEM certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate request: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate request signature: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: agentID, Organization: []string{agentCertOrganization}},
+		NotBefore:    time.Now().Add(-5 * time.Minute),
+		NotAfter:     time.Now().Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// issueServerCertificate writes a server certificate for host signed by the CA, so agents can
+// pin the server with the CA certificate. Reuses the existing certificate while it is valid.
+func (ca *AgentCA) issueServerCertificate(host string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(ca.dir, "server.crt")
+	keyFile = filepath.Join(ca.dir, "server.key")
+
+	if data, err := ioutil.ReadFile(certFile); err == nil {
+		if cert, err := parseCertificatePEM(data); err == nil && time.Until(cert.NotAfter) > 30*24*time.Hour {
+			if _, err := os.Stat(keyFile); err == nil {
+				return certFile, keyFile, nil
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return "", "", err
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hostname, Organization: []string{"BSync Server"}},
+		NotBefore:    time.Now().Add(-5 * time.Minute),
+		NotAfter:     time.Now().Add(serverCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	if hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if host != "" && ip == nil {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign server certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return "", "", err
+	}
+
+	log.Printf("🔐 Issued server certificate from agent CA: %s", certFile)
+	return certFile, keyFile, nil
+}
+
+// revocationList returns a PEM CRL signed by the CA for the given revoked certificates
+func (ca *AgentCA) revocationList(revoked []*AgentCertificate) ([]byte, error) {
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for _, cert := range revoked {
+		serial, ok := new(big.Int).SetString(cert.SerialNumber, 16)
+		if !ok || cert.RevokedAt == nil {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: *cert.RevokedAt})
+	}
+
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().Unix()),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(24 * time.Hour),
+		RevokedCertificateEntries: entries,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// beginIssue marks an issuance for agentID in progress, false if one is already running
+func (ca *AgentCA) beginIssue(agentID string) bool {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	if ca.issuing[agentID] {
+		return false
+	}
+	ca.issuing[agentID] = true
+	return true
+}
+
+// endIssue clears the in-progress mark set by beginIssue
+func (ca *AgentCA) endIssue(agentID string) {
+	ca.mutex.Lock()
+	delete(ca.issuing, agentID)
+	ca.mutex.Unlock()
+}
+
+// parseCertificatePEM parses the first certificate in PEM data
+func parseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// randomSerial returns a random 128-bit certificate serial number
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// certificateFingerprint returns the SHA-256 hex fingerprint of a certificate
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// agentCertificateHeader tells a rejected agent that its client certificate is revoked or unknown,
+// so it drops the certificate and enrolls again instead of presenting it on every reconnect
+const agentCertificateHeader = "X-Agent-Certificate"
+
+// errClientCertificateRequired rejects an agent with an active certificate that connected without it
+var errClientCertificateRequired = errors.New("client certificate required (revoke the agent certificates to enroll it again)")
+
+// certificateRejectedError is returned for a client certificate the agent must stop using
+type certificateRejectedError struct {
+	reason string
+}
+
+func (e *certificateRejectedError) Error() string {
+	return e.reason
+}
+
+// certificateSessionMessage reports whether a message may be exchanged with an agent connected
+// without a client certificate while tls.mtls.required is set: it only registers and obtains one
+func certificateSessionMessage(msgType string) bool {
+	switch msgType {
+	case "register", "health", "create_certificate_request", "certificate_request_response", "install_certificate":
+		return true
+	}
+	return false
+}
+
+// verifyAgentClientCertificate checks the client certificate of an agent connection.
+// verified is true when the agent presented a valid, unrevoked certificate issued to agentID.
+// An agent with a valid certificate can't fall back to its credential or enroll again without it:
+// an admin has to revoke its certificates or credential first.
+func (s *SyncToolServer) verifyAgentClientCertificate(r *http.Request, agentID string) (verified bool, err error) {
+	if s.agentCA == nil || s.db == nil {
+		return false, nil
+	}
+
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		var hasCertificate bool
+		if err := s.db.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM agent_certificates WHERE agent_id = $1 AND revoked_at IS NULL AND not_after > NOW())
+		`, agentID).Scan(&hasCertificate); err != nil {
+			return false, fmt.Errorf("failed to check agent certificate: %w", err)
+		}
+		if hasCertificate {
+			return false, errClientCertificateRequired
+		}
+		return false, nil
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	if leaf.Subject.CommonName != agentID {
+		return false, &certificateRejectedError{fmt.Sprintf("client certificate was issued to %q", leaf.Subject.CommonName)}
+	}
+	if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return false, &certificateRejectedError{fmt.Sprintf("client certificate %s has expired", leaf.SerialNumber.Text(16))}
+	}
+
+	serial := leaf.SerialNumber.Text(16)
+	var revokedAt *time.Time
+	err = s.db.QueryRow(`
+		SELECT revoked_at FROM agent_certificates WHERE serial_number = $1 AND agent_id = $2
+	`, serial, agentID).Scan(&revokedAt)
+	if err == sql.ErrNoRows {
+		return false, &certificateRejectedError{"unknown client certificate " + serial}
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check client certificate: %w", err)
+	}
+	if revokedAt != nil {
+		return false, &certificateRejectedError{fmt.Sprintf("client certificate %s is revoked", serial)}
+	}
+
+	// The agent switched to this certificate: older certificates are no longer needed
+	if _, err := s.db.Exec(`
+		UPDATE agent_certificates
+		SET revoked_at = NOW(), revoke_reason = 'superseded'
+		WHERE agent_id = $1 AND revoked_at IS NULL AND serial_number != $2 AND not_before < $3
+	`, agentID, serial, leaf.NotBefore); err != nil {
+		log.Printf("⚠️ Failed to revoke superseded certificates of agent %s: %v", agentID, err)
+	}
+
+	return true, nil
+}
+
+// ensureAgentCertificate issues a client certificate to an approved, connected agent
+// that has none or whose certificate is due for rotation
+func (s *SyncToolServer) ensureAgentCertificate(agentID string) {
+	if s.agentCA == nil || s.db == nil {
+		return
+	}
+
+	var approvalStatus string
+	var latestNotAfter *time.Time
+	err := s.db.QueryRow(`
+		SELECT COALESCE(ia.approval_status, ''),
+			(SELECT MAX(not_after) FROM agent_certificates ac
+			 WHERE ac.agent_id = ia.agent_id AND ac.revoked_at IS NULL)
+		FROM integrated_agents ia
+		WHERE ia.agent_id = $1
+	`, agentID).Scan(&approvalStatus, &latestNotAfter)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to check certificate of agent %s: %v", agentID, err)
+		}
+		return
+	}
+	if approvalStatus != "approved" {
+		return
+	}
+	if latestNotAfter != nil && time.Until(*latestNotAfter) > s.config.TLS.MTLS.RenewBefore {
+		return
+	}
+
+	if err := s.issueAgentCertificate(agentID); err != nil {
+		log.Printf("❌ Failed to issue certificate to agent %s: %v", agentID, err)
+	}
+}
+
+// issueAgentCertificate asks the agent for a certificate request, signs it and sends the certificate back.
+// The private key never leaves the agent.
+func (s *SyncToolServer) issueAgentCertificate(agentID string) error {
+	if !s.agentCA.beginIssue(agentID) {
+		return nil
+	}
+	defer s.agentCA.endIssue(agentID)
+
+	response, err := s.requestFromAgent(agentID, map[string]interface{}{
+		"type":        "create_certificate_request",
+		"common_name": agentID,
+	}, agentRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("certificate request failed: %w", err)
+	}
+	csrPEM, _ := response["csr"].(string)
+
+	cert, certPEM, err := s.agentCA.signAgentCSR([]byte(csrPEM), agentID, s.config.TLS.MTLS.CertLifetime)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`
+		INSERT INTO agent_certificates (agent_id, serial_number, fingerprint, not_before, not_after, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`, agentID, cert.SerialNumber.Text(16), certificateFingerprint(cert), cert.NotBefore, cert.NotAfter); err != nil {
+		return fmt.Errorf("failed to store certificate: %w", err)
+	}
+
+	if err := s.sendJobToAgent(agentID, map[string]interface{}{
+		"type":           "install_certificate",
+		"certificate":    string(certPEM),
+		"ca_certificate": string(s.agentCA.certPEM),
+		"not_after":      cert.NotAfter.Format(time.RFC3339),
+	}); err != nil {
+		return fmt.Errorf("failed to send certificate: %w", err)
+	}
+
+	log.Printf("🔐 Issued certificate %s to agent %s (expires %s)",
+		cert.SerialNumber.Text(16), agentID, cert.NotAfter.Format("2006-01-02"))
+	return nil
+}
+
+// revokeAgentCertificates revokes active certificates of an agent (all of them if serial is empty)
+func (s *SyncToolServer) revokeAgentCertificates(agentID, serial, reason string) (int64, error) {
+	result, err := s.db.Exec(`
+		UPDATE agent_certificates
+		SET revoked_at = NOW(), revoke_reason = $3
+		WHERE agent_id = $1 AND revoked_at IS NULL AND ($2 = '' OR serial_number = $2)
+	`, agentID, serial, reason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// runCertificateRotation periodically renews certificates of connected agents before they expire
+func (s *SyncToolServer) runCertificateRotation() {
+	ticker := time.NewTicker(certRotationInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.hub.mutex.RLock()
+		agentIDs := make([]string, 0, len(s.hub.agents))
+		for agentID := range s.hub.agents {
+			agentIDs = append(agentIDs, agentID)
+		}
+		s.hub.mutex.RUnlock()
+
+		for _, agentID := range agentIDs {
+			s.ensureAgentCertificate(agentID)
+		}
+	}
+}
+
+// loadAgentCertificates returns certificates of one agent, or all revoked certificates if agentID is empty
+func (s *SyncToolServer) loadAgentCertificates(agentID string) ([]*AgentCertificate, error) {
+	rows, err := s.db.Query(`
+		SELECT id, agent_id, serial_number, fingerprint, not_before, not_after, created_at,
+			revoked_at, COALESCE(revoke_reason, '')
+		FROM agent_certificates
+		WHERE ($1 != '' AND agent_id = $1) OR ($1 = '' AND revoked_at IS NOT NULL)
+		ORDER BY created_at DESC
+	`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	certificates := []*AgentCertificate{}
+	for rows.Next() {
+		cert := &AgentCertificate{}
+		if err := rows.Scan(&cert.ID, &cert.AgentID, &cert.SerialNumber, &cert.Fingerprint, &cert.NotBefore,
+			&cert.NotAfter, &cert.CreatedAt, &cert.RevokedAt, &cert.RevokeReason); err != nil {
+			return nil, err
+		}
+		switch {
+		case cert.RevokedAt != nil:
+			cert.Status = "revoked"
+		case now.After(cert.NotAfter):
+			cert.Status = "expired"
+		default:
+			cert.Status = "active"
+		}
+		certificates = append(certificates, cert)
+	}
+	return certificates, rows.Err()
+}
+
+// handleAgentCertificates handles client certificates of an agent
+// GET /api/agents/{agentId}/certificates - List certificates
+// POST /api/agents/{agentId}/certificates - Issue a new certificate now (rotation)
+// DELETE /api/agents/{agentId}/certificates?serial= - Revoke one or all active certificates and the credential,
+// reset the approval and disconnect the agent
+func (s *SyncToolServer) handleAgentCertificates(w http.ResponseWriter, r *http.Request, agentID string) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		certificates, err := s.loadAgentCertificates(agentID)
+		if err != nil {
+			log.Printf("❌ Failed to query certificates of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to fetch agent certificates"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"agent_id":     agentID,
+			"mtls_enabled": s.agentCA != nil,
+			"data":         certificates,
+			"total":        len(certificates),
+		})
+
+	case "POST":
+		if s.agentCA == nil {
+			http.Error(w, `{"error": "mTLS is not enabled"}`, http.StatusBadRequest)
+			return
+		}
+		if err := s.issueAgentCertificate(agentID); err != nil {
+			log.Printf("❌ Failed to issue certificate to agent %s: %v", agentID, err)
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to issue certificate: %v"}`, err), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Certificate issued. The agent reconnects with it and the previous certificate is revoked.",
+		})
+
+	case "DELETE":
+		revoked, err := s.revokeAgentCertificates(agentID, r.URL.Query().Get("serial"), "revoked by "+requestUsername(r))
+		if err != nil {
+			log.Printf("❌ Failed to revoke certificates of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to revoke agent certificates"}`, http.StatusInternalServerError)
+			return
+		}
+		if revoked == 0 {
+			http.Error(w, `{"error": "No active certificate found"}`, http.StatusNotFound)
+			return
+		}
+
+		// Revocation must outlast the connection: without its credential and approval the agent
+		// can't log in with the credential alone and isn't issued a new certificate on its own
+		if _, err := s.revokeAgentCredential(agentID, "certificate revoked by "+requestUsername(r)); err != nil {
+			log.Printf("❌ Failed to revoke credential of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to revoke agent credential"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := s.updateAgentApprovalStatus(agentID, "pending"); err != nil {
+			log.Printf("❌ Failed to reset approval of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to reset agent approval"}`, http.StatusInternalServerError)
+			return
+		}
+
+		disconnected := s.disconnectAgent(agentID)
+		log.Printf("🔐 Revoked %d certificate(s) and the credential of agent %s (disconnected: %v)", revoked, agentID, disconnected)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Revoked %d certificate(s) and the agent credential. "+
+				"The agent must enroll again with a new enrollment token and be approved.", revoked),
+			"disconnected": disconnected,
+		})
+
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAgentCA serves the public parts of the agent CA
+// GET /api/v1/agent-ca/certificate - CA certificate (PEM), for agent ca_file
+// GET /api/v1/agent-ca/crl - Certificate revocation list (PEM)
+func (s *SyncToolServer) handleAgentCA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.agentCA == nil {
+		http.Error(w, `{"error": "mTLS is not enabled"}`, http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v1/agent-ca/certificate":
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(s.agentCA.certPEM)
+
+	case "/api/v1/agent-ca/crl":
+		if s.db == nil {
+			http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+			return
+		}
+		revoked, err := s.loadAgentCertificates("")
+		if err != nil {
+			log.Printf("❌ Failed to query revoked certificates: %v", err)
+			http.Error(w, `{"error": "Failed to fetch revoked certificates"}`, http.StatusInternalServerError)
+			return
+		}
+		crl, err := s.agentCA.revocationList(revoked)
+		if err != nil {
+			log.Printf("❌ Failed to create CRL: %v", err)
+			http.Error(w, `{"error": "Failed to create CRL"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(crl)
+
+	default:
+		http.Error(w, `{"error": "Not found"}`, http.StatusNotFound)
+	}
+}
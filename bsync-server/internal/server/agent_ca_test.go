@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql/driver"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCSR returns a PEM certificate request for commonName
+func testCSR(t *testing.T, commonName string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName, Organization: []string{"Someone Else"}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+// testAgentCertificate returns a client certificate signed by ca
+func testAgentCertificate(t *testing.T, ca *AgentCA, commonName string, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestSignAgentCSR(t *testing.T) {
+	ca, err := loadOrCreateAgentCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := testCSR(t, "agent-1")
+	tampered := []byte(string(valid))
+	block, _ := pem.Decode(tampered)
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	tampered = pem.EncodeToMemory(block)
+
+	tests := []struct {
+		name    string
+		csr     []byte
+		wantErr bool
+	}{
+		{name: "request for the agent", csr: valid},
+		{name: "subject of another agent is replaced", csr: testCSR(t, "agent-2")},
+		{name: "not PEM", csr: []byte("csr"), wantErr: true},
+		{name: "certificate instead of request", csr: ca.certPEM, wantErr: true},
+		{name: "invalid signature", csr: tampered, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, certPEM, err := ca.signAgentCSR(tt.csr, "agent-1", 24*time.Hour)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("signAgentCSR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if cert.Subject.CommonName != "agent-1" || len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != agentCertOrganization {
+				t.Errorf("subject = %v, want CN=agent-1, O=%s", cert.Subject, agentCertOrganization)
+			}
+			if lifetime := time.Until(cert.NotAfter); lifetime > 24*time.Hour || lifetime < 23*time.Hour {
+				t.Errorf("certificate expires in %v, want 24h", lifetime)
+			}
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: ca.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+				t.Errorf("certificate does not verify as client certificate: %v", err)
+			}
+			if parsed, err := parseCertificatePEM(certPEM); err != nil || !parsed.Equal(cert) {
+				t.Errorf("PEM does not hold the certificate: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAgentClientCertificate(t *testing.T) {
+	ca, err := loadOrCreateAgentCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	current := testAgentCertificate(t, ca, "agent-1", now.Add(-time.Hour), now.Add(time.Hour))
+	expired := testAgentCertificate(t, ca, "agent-1", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	otherAgent := testAgentCertificate(t, ca, "agent-2", now.Add(-time.Hour), now.Add(time.Hour))
+
+	serialLookup := func(rows ...[]driver.Value) *fakeStatement {
+		return &fakeStatement{query: "WHERE serial_number = $1 AND agent_id = $2",
+			args: []driver.Value{current.SerialNumber.Text(16), "agent-1"}, columns: []string{"revoked_at"}, rows: rows}
+	}
+
+	tests := []struct {
+		name         string
+		leaf         *x509.Certificate
+		statements   []*fakeStatement
+		wantVerified bool
+		wantErr      error
+		wantRejected bool
+	}{
+		{
+			name:       "no certificate, none issued",
+			statements: []*fakeStatement{{query: "not_after > NOW()", columns: []string{"exists"}, rows: [][]driver.Value{{false}}}},
+		},
+		{
+			name:       "no certificate, but a valid one issued",
+			statements: []*fakeStatement{{query: "not_after > NOW()", columns: []string{"exists"}, rows: [][]driver.Value{{true}}}},
+			wantErr:    errClientCertificateRequired,
+		},
+		{
+			name:         "certificate of another agent",
+			leaf:         otherAgent,
+			wantRejected: true,
+		},
+		{
+			name:         "expired certificate",
+			leaf:         expired,
+			wantRejected: true,
+		},
+		{
+			name:         "unknown certificate",
+			leaf:         current,
+			statements:   []*fakeStatement{serialLookup()},
+			wantRejected: true,
+		},
+		{
+			name:         "revoked certificate",
+			leaf:         current,
+			statements:   []*fakeStatement{serialLookup([]driver.Value{now.Add(-time.Minute)})},
+			wantRejected: true,
+		},
+		{
+			name: "valid certificate revokes older ones",
+			leaf: current,
+			statements: []*fakeStatement{
+				serialLookup([]driver.Value{nil}),
+				{query: "revoke_reason = 'superseded'", affected: 1},
+			},
+			wantVerified: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, tt.statements...)
+			s := &SyncToolServer{db: db, agentCA: ca}
+
+			r := httptest.NewRequest("GET", "/ws/agent?agent_id=agent-1", nil)
+			if tt.leaf != nil {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.leaf, ca.cert}}}
+			}
+
+			verified, err := s.verifyAgentClientCertificate(r, "agent-1")
+			if verified != tt.wantVerified {
+				t.Errorf("verified = %v, want %v", verified, tt.wantVerified)
+			}
+			if _, rejected := err.(*certificateRejectedError); rejected != tt.wantRejected {
+				t.Errorf("error = %v, want rejected %v", err, tt.wantRejected)
+			}
+			if !tt.wantRejected && err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertificateSessionMessage(t *testing.T) {
+	tests := []struct {
+		msgType string
+		want    bool
+	}{
+		{msgType: "register", want: true},
+		{msgType: "create_certificate_request", want: true},
+		{msgType: "certificate_request_response", want: true},
+		{msgType: "install_certificate", want: true},
+		{msgType: "deploy_job", want: false},
+		{msgType: "browse_folders", want: false},
+		{msgType: "session_event", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msgType, func(t *testing.T) {
+			if got := certificateSessionMessage(tt.msgType); got != tt.want {
+				t.Errorf("certificateSessionMessage(%q) = %v, want %v", tt.msgType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-server/internal/models"
+)
+
+// Headers used by agents on the /ws/agent handshake
+const (
+	agentCredentialHeader = "X-Agent-Credential"
+	enrollmentTokenHeader = "X-Enrollment-Token"
+)
+
+const (
+	defaultEnrollmentTokenLifetime = 24 * time.Hour
+	maxEnrollmentTokenLifetime     = 365 * 24 * time.Hour
+	enrollmentTokenPrefixLength    = 8
+)
+
+// EnrollmentToken is an admin-generated token an agent exchanges for its credential
+type EnrollmentToken struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	MaxUses     *int       `json:"max_uses"` // nil = unlimited
+	UseCount    int        `json:"use_count"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	Status      string     `json:"status"` // active, expired, exhausted, revoked
+}
+
+// AgentCredential describes the credential of an agent (never the secret itself)
+type AgentCredential struct {
+	ID                int        `json:"id"`
+	AgentID           string     `json:"agent_id"`
+	EnrollmentTokenID *int       `json:"enrollment_token_id,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	LastRemoteAddr    string     `json:"last_remote_addr,omitempty"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy         string     `json:"revoked_by,omitempty"`
+}
+
+const enrollmentTokenColumns = `id, name, token_prefix, max_uses, use_count, expires_at, revoked_at,
+	COALESCE(created_by, ''), created_at, last_used_at`
+
+// scanEnrollmentToken scans a row selected with enrollmentTokenColumns
+func scanEnrollmentToken(row interface{ Scan(...interface{}) error }) (*EnrollmentToken, error) {
+	token := &EnrollmentToken{}
+	var maxUses sql.NullInt64
+	err := row.Scan(&token.ID, &token.Name, &token.TokenPrefix, &maxUses, &token.UseCount, &token.ExpiresAt,
+		&token.RevokedAt, &token.CreatedBy, &token.CreatedAt, &token.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		token.MaxUses = &n
+	}
+	token.Status = token.status(time.Now())
+	return token, nil
+}
+
+// status reports whether the token can still be used at t
+func (t *EnrollmentToken) status(now time.Time) string {
+	switch {
+	case t.RevokedAt != nil:
+		return "revoked"
+	case !now.Before(t.ExpiresAt):
+		return "expired"
+	case t.MaxUses != nil && t.UseCount >= *t.MaxUses:
+		return "exhausted"
+	default:
+		return "active"
+	}
+}
+
+// generateSecret returns a random hex secret for tokens and credentials
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashSecret returns the SHA-256 hex digest stored instead of a secret
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAgent authenticates an agent before the WebSocket upgrade: a valid client certificate,
+// or else its credential or an enrollment token. certVerified tells whether the agent presented a
+// client certificate; issuedCredential is set when an enrollment token was exchanged.
+func (s *SyncToolServer) authenticateAgent(r *http.Request, agentID string) (issuedCredential string, certVerified bool, err error) {
+	certVerified, err = s.verifyAgentClientCertificate(r, agentID)
+	if err != nil || certVerified {
+		return "", certVerified, err
+	}
+	issuedCredential, err = s.authenticateAgentConnection(r, agentID)
+	return issuedCredential, false, err
+}
+
+// authenticateAgentConnection checks the credential or enrollment token of an agent before the
+// WebSocket upgrade. When an enrollment token is exchanged, the new credential secret is returned
+// and must be sent to the agent in the upgrade response.
+func (s *SyncToolServer) authenticateAgentConnection(r *http.Request, agentID string) (issuedCredential string, err error) {
+	credential := r.Header.Get(agentCredentialHeader)
+	enrollmentToken := r.Header.Get(enrollmentTokenHeader)
+
+	if s.db == nil {
+		if s.config.AgentAuth.Required {
+			return "", fmt.Errorf("agent authentication requires a database")
+		}
+		return "", nil
+	}
+
+	if credential != "" {
+		valid, err := s.verifyAgentCredential(agentID, credential, r.RemoteAddr)
+		if err != nil {
+			return "", err
+		}
+		if valid {
+			return "", nil
+		}
+		if enrollmentToken == "" {
+			return "", fmt.Errorf("invalid or revoked credential")
+		}
+		// Credential was revoked: fall through and try to enroll again with the token
+	}
+
+	if enrollmentToken != "" {
+		return s.enrollAgent(agentID, enrollmentToken)
+	}
+
+	hasCredential, err := s.agentHasActiveCredential(agentID)
+	if err != nil {
+		return "", err
+	}
+	if hasCredential {
+		return "", fmt.Errorf("agent is enrolled but presented no credential")
+	}
+	if s.config.AgentAuth.Required {
+		return "", fmt.Errorf("credential or enrollment token required")
+	}
+
+	log.Printf("⚠️ Agent %s connected without credential (agent_auth.required is off)", agentID)
+	return "", nil
+}
+
+// verifyAgentCredential reports whether secret is the active credential of agentID
+func (s *SyncToolServer) verifyAgentCredential(agentID, secret, remoteAddr string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE agent_credentials
+		SET last_used_at = NOW(), last_remote_addr = $3
+		WHERE agent_id = $1 AND secret_hash = $2 AND revoked_at IS NULL
+	`, agentID, hashSecret(secret), remoteAddr)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify credential: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows == 1, nil
+}
+
+// agentHasActiveCredential reports whether the agent has already enrolled
+func (s *SyncToolServer) agentHasActiveCredential(agentID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM agent_credentials WHERE agent_id = $1 AND revoked_at IS NULL)
+	`, agentID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check agent credential: %w", err)
+	}
+	return exists, nil
+}
+
+// enrollAgent consumes one use of an enrollment token and issues a new credential for the agent.
+// An agent with an active credential or certificate is refused: a token never replaces them, an
+// admin revokes them first. Enrolling again resets the approval, so no certificate is issued
+// before an admin approved the agent again.
+func (s *SyncToolServer) enrollAgent(agentID, enrollmentToken string) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to start enrollment: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the token row so concurrent agents cannot exceed max_uses
+	token, err := scanEnrollmentToken(tx.QueryRow(`
+		SELECT `+enrollmentTokenColumns+`
+		FROM agent_enrollment_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashSecret(enrollmentToken)))
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("unknown enrollment token")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load enrollment token: %w", err)
+	}
+	if token.Status != "active" {
+		return "", fmt.Errorf("enrollment token %s is %s", token.TokenPrefix, token.Status)
+	}
+
+	var enrolled, enrolledBefore bool
+	if err := tx.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM agent_credentials WHERE agent_id = $1 AND revoked_at IS NULL)
+				OR EXISTS(SELECT 1 FROM agent_certificates WHERE agent_id = $1 AND revoked_at IS NULL),
+			EXISTS(SELECT 1 FROM agent_credentials WHERE agent_id = $1)
+				OR EXISTS(SELECT 1 FROM agent_certificates WHERE agent_id = $1)
+	`, agentID).Scan(&enrolled, &enrolledBefore); err != nil {
+		return "", fmt.Errorf("failed to check agent credential: %w", err)
+	}
+	if enrolled {
+		return "", fmt.Errorf("agent is already enrolled (revoke its credential to enroll again)")
+	}
+	if enrolledBefore {
+		if _, err := tx.Exec(`
+			UPDATE integrated_agents SET approval_status = 'pending', updated_at = NOW()
+			WHERE agent_id = $1
+		`, agentID); err != nil {
+			return "", fmt.Errorf("failed to reset agent approval: %w", err)
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate credential: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO agent_credentials (agent_id, secret_hash, enrollment_token_id, created_at, last_used_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+	`, agentID, hashSecret(secret), token.ID); err != nil {
+		return "", fmt.Errorf("failed to store credential: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE agent_enrollment_tokens
+		SET use_count = use_count + 1, last_used_at = NOW()
+		WHERE id = $1
+	`, token.ID); err != nil {
+		return "", fmt.Errorf("failed to update enrollment token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit enrollment: %w", err)
+	}
+
+	log.Printf("🔑 Agent %s enrolled with token %s (%s)", agentID, token.TokenPrefix, token.Name)
+	return secret, nil
+}
+
+// revokeAgentCredential revokes the active credential of an agent, returns false if there was none
+func (s *SyncToolServer) revokeAgentCredential(agentID, revokedBy string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE agent_credentials
+		SET revoked_at = NOW(), revoked_by = $2
+		WHERE agent_id = $1 AND revoked_at IS NULL
+	`, agentID, revokedBy)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// disconnectAgent closes the WebSocket of a connected agent
+func (s *SyncToolServer) disconnectAgent(agentID string) bool {
+	s.hub.mutex.RLock()
+	client, exists := s.hub.agents[agentID]
+	s.hub.mutex.RUnlock()
+
+	if !exists || client.conn == nil {
+		return false
+	}
+	client.conn.Close()
+	return true
+}
+
+// requestUsername returns the username of the authenticated user, if any
+func requestUsername(r *http.Request) string {
+	if claims, ok := r.Context().Value("user_claims").(*models.JWTClaims); ok {
+		return claims.Username
+	}
+	return ""
+}
+
+// handleEnrollmentTokens handles enrollment token list and create
+// GET /api/v1/enrollment-tokens - List tokens
+// POST /api/v1/enrollment-tokens - Create token {"name", "max_uses" (default 1, 0 = unlimited), "expires_in" (e.g. "24h")}
+func (s *SyncToolServer) handleEnrollmentTokens(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.listEnrollmentTokens(w, r)
+	case "POST":
+		s.createEnrollmentToken(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEnrollmentTokenActions handles actions on a specific enrollment token
+// GET /api/v1/enrollment-tokens/{id} - Get token
+// DELETE /api/v1/enrollment-tokens/{id} - Revoke token (agents already enrolled keep their credentials)
+func (s *SyncToolServer) handleEnrollmentTokenActions(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/enrollment-tokens/"), "/")
+	tokenID, err := strconv.Atoi(pathParts[0])
+	if err != nil || len(pathParts) != 1 {
+		http.Error(w, `{"error": "Invalid URL format. Expected: /api/v1/enrollment-tokens/{id}"}`, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		token, err := scanEnrollmentToken(s.db.QueryRow(
+			`SELECT `+enrollmentTokenColumns+` FROM agent_enrollment_tokens WHERE id = $1`, tokenID))
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"error": "Enrollment token not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Failed to get enrollment token: %v", err)
+			http.Error(w, `{"error": "Failed to get enrollment token"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(token)
+
+	case "DELETE":
+		result, err := s.db.Exec(`
+			UPDATE agent_enrollment_tokens SET revoked_at = NOW()
+			WHERE id = $1 AND revoked_at IS NULL
+		`, tokenID)
+		if err != nil {
+			log.Printf("❌ Failed to revoke enrollment token: %v", err)
+			http.Error(w, `{"error": "Failed to revoke enrollment token"}`, http.StatusInternalServerError)
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			http.Error(w, `{"error": "Enrollment token not found or already revoked"}`, http.StatusNotFound)
+			return
+		}
+
+		log.Printf("🔑 Enrollment token %d revoked", tokenID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Enrollment token revoked successfully",
+		})
+
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// listEnrollmentTokens retrieves all enrollment tokens, newest first
+func (s *SyncToolServer) listEnrollmentTokens(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.Query(`SELECT ` + enrollmentTokenColumns + ` FROM agent_enrollment_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		log.Printf("❌ Failed to query enrollment tokens: %v", err)
+		http.Error(w, `{"error": "Failed to fetch enrollment tokens"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	tokens := []*EnrollmentToken{}
+	for rows.Next() {
+		token, err := scanEnrollmentToken(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan enrollment token: %v", err)
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  tokens,
+		"total": len(tokens),
+	})
+}
+
+// createEnrollmentToken creates a token; the plaintext token is only returned in this response
+func (s *SyncToolServer) createEnrollmentToken(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Name      string `json:"name"`
+		MaxUses   *int   `json:"max_uses"`
+		ExpiresIn string `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	lifetime := defaultEnrollmentTokenLifetime
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > maxEnrollmentTokenLifetime {
+			http.Error(w, fmt.Sprintf(`{"error": "expires_in must be a positive duration up to %v (e.g. \"24h\")"}`, maxEnrollmentTokenLifetime), http.StatusBadRequest)
+			return
+		}
+		lifetime = d
+	}
+
+	// Single use by default, 0 = unlimited
+	var maxUses interface{} = 1
+	if req.MaxUses != nil {
+		switch {
+		case *req.MaxUses < 0:
+			http.Error(w, `{"error": "max_uses must not be negative"}`, http.StatusBadRequest)
+			return
+		case *req.MaxUses == 0:
+			maxUses = nil
+		default:
+			maxUses = *req.MaxUses
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		log.Printf("❌ Failed to generate enrollment token: %v", err)
+		http.Error(w, `{"error": "Failed to generate enrollment token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	token, err := scanEnrollmentToken(s.db.QueryRow(`
+		INSERT INTO agent_enrollment_tokens (name, token_hash, token_prefix, max_uses, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING `+enrollmentTokenColumns,
+		req.Name, hashSecret(secret), secret[:enrollmentTokenPrefixLength], maxUses,
+		time.Now().Add(lifetime), nullIfEmpty(requestUsername(r))))
+	if err != nil {
+		log.Printf("❌ Failed to create enrollment token: %v", err)
+		http.Error(w, `{"error": "Failed to create enrollment token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("🔑 Enrollment token created: ID=%d, Name=%s, Prefix=%s, Expires=%s",
+		token.ID, token.Name, token.TokenPrefix, token.ExpiresAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Enrollment token created successfully. Store the token now, it cannot be shown again.",
+		"token":   secret,
+		"data":    token,
+	})
+}
+
+// handleAgentCredential handles the credential of an agent
+// GET /api/agents/{agentId}/credential - Credential status
+// DELETE /api/agents/{agentId}/credential - Revoke credential and certificates, reset the approval and disconnect the agent
+func (s *SyncToolServer) handleAgentCredential(w http.ResponseWriter, r *http.Request, agentID string) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		rows, err := s.db.Query(`
+			SELECT id, agent_id, enrollment_token_id, created_at, last_used_at,
+				COALESCE(last_remote_addr, ''), revoked_at, COALESCE(revoked_by, '')
+			FROM agent_credentials
+			WHERE agent_id = $1
+			ORDER BY created_at DESC
+		`, agentID)
+		if err != nil {
+			log.Printf("❌ Failed to query credentials of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to fetch agent credentials"}`, http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		credentials := []*AgentCredential{}
+		enrolled := false
+		for rows.Next() {
+			credential := &AgentCredential{}
+			var tokenID sql.NullInt64
+			if err := rows.Scan(&credential.ID, &credential.AgentID, &tokenID, &credential.CreatedAt, &credential.LastUsedAt,
+				&credential.LastRemoteAddr, &credential.RevokedAt, &credential.RevokedBy); err != nil {
+				log.Printf("❌ Failed to scan agent credential: %v", err)
+				continue
+			}
+			if tokenID.Valid {
+				id := int(tokenID.Int64)
+				credential.EnrollmentTokenID = &id
+			}
+			if credential.RevokedAt == nil {
+				enrolled = true
+			}
+			credentials = append(credentials, credential)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"agent_id": agentID,
+			"enrolled": enrolled,
+			"data":     credentials,
+			"total":    len(credentials),
+		})
+
+	case "DELETE":
+		revoked, err := s.revokeAgentCredential(agentID, requestUsername(r))
+		if err != nil {
+			log.Printf("❌ Failed to revoke credential of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to revoke agent credential"}`, http.StatusInternalServerError)
+			return
+		}
+		if !revoked {
+			http.Error(w, `{"error": "Agent has no active credential"}`, http.StatusNotFound)
+			return
+		}
+
+		// Its certificates would still let the agent in and keep it from enrolling again
+		if _, err := s.revokeAgentCertificates(agentID, "", "credential revoked by "+requestUsername(r)); err != nil {
+			log.Printf("❌ Failed to revoke certificates of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to revoke agent certificates"}`, http.StatusInternalServerError)
+			return
+		}
+		if err := s.updateAgentApprovalStatus(agentID, "pending"); err != nil {
+			log.Printf("❌ Failed to reset approval of agent %s: %v", agentID, err)
+			http.Error(w, `{"error": "Failed to reset agent approval"}`, http.StatusInternalServerError)
+			return
+		}
+
+		disconnected := s.disconnectAgent(agentID)
+		log.Printf("🔑 Credential of agent %s revoked (disconnected: %v)", agentID, disconnected)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":      true,
+			"message":      "Agent credential and certificates revoked. The agent must enroll again with a new enrollment token and be approved.",
+			"disconnected": disconnected,
+		})
+
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEnrollmentTokenStatus(t *testing.T) {
+	now := time.Date(2030, 3, 8, 12, 0, 0, 0, time.UTC)
+	one, two := 1, 2
+
+	tests := []struct {
+		name  string
+		token EnrollmentToken
+		want  string
+	}{
+		{name: "active", token: EnrollmentToken{ExpiresAt: now.Add(time.Hour)}, want: "active"},
+		{name: "unlimited uses", token: EnrollmentToken{ExpiresAt: now.Add(time.Hour), UseCount: 100}, want: "active"},
+		{name: "uses left", token: EnrollmentToken{ExpiresAt: now.Add(time.Hour), MaxUses: &two, UseCount: 1}, want: "active"},
+		{name: "max uses reached", token: EnrollmentToken{ExpiresAt: now.Add(time.Hour), MaxUses: &one, UseCount: 1}, want: "exhausted"},
+		{name: "expiry is exclusive", token: EnrollmentToken{ExpiresAt: now}, want: "expired"},
+		{name: "expired", token: EnrollmentToken{ExpiresAt: now.Add(-time.Hour)}, want: "expired"},
+		{name: "expired and exhausted", token: EnrollmentToken{ExpiresAt: now.Add(-time.Hour), MaxUses: &one, UseCount: 1}, want: "expired"},
+		{name: "revoked", token: EnrollmentToken{ExpiresAt: now.Add(time.Hour), RevokedAt: &now}, want: "revoked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.status(now); got != tt.want {
+				t.Errorf("status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	first, err := generateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := generateSecret()
+	if len(first) != 64 || first == second {
+		t.Errorf("generateSecret() = %q, %q, want two different 64 character secrets", first, second)
+	}
+	if hashSecret(first) == first || hashSecret(first) != hashSecret(first) || hashSecret(first) == hashSecret(second) {
+		t.Error("hashSecret() must be deterministic and differ from the secret")
+	}
+}
+
+func TestVerifyAgentCredential(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     bool
+	}{
+		{name: "active credential", affected: 1, want: true},
+		{name: "wrong or revoked credential", affected: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, &fakeStatement{
+				query:    "revoked_at IS NULL",
+				args:     []driver.Value{"agent-1", hashSecret("secret"), "10.0.0.1:4000"},
+				affected: tt.affected,
+			})
+			s := &SyncToolServer{db: db}
+
+			got, err := s.verifyAgentCredential("agent-1", "secret", "10.0.0.1:4000")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("verifyAgentCredential() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeAgentCredential(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     bool
+	}{
+		{name: "active credential", affected: 1, want: true},
+		{name: "no active credential", affected: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, &fakeStatement{
+				query:    "SET revoked_at = NOW(), revoked_by = $2",
+				args:     []driver.Value{"agent-1", "admin"},
+				affected: tt.affected,
+			})
+			s := &SyncToolServer{db: db}
+
+			got, err := s.revokeAgentCredential("agent-1", "admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("revokeAgentCredential() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// enrollmentTokenRow returns a row of enrollmentTokenColumns
+func enrollmentTokenRow(maxUses interface{}, useCount int64, expiresAt time.Time, revokedAt interface{}) []driver.Value {
+	return []driver.Value{int64(7), "ci", "0123abcd", maxUses, useCount, expiresAt, revokedAt, "admin", expiresAt.Add(-24 * time.Hour), nil}
+}
+
+var enrollmentTokenColumnNames = []string{"id", "name", "token_prefix", "max_uses", "use_count", "expires_at",
+	"revoked_at", "created_by", "created_at", "last_used_at"}
+
+func TestEnrollAgent(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+
+	tokenLookup := func(rows ...[]driver.Value) *fakeStatement {
+		return &fakeStatement{
+			query:   "FOR UPDATE",
+			args:    []driver.Value{hashSecret("token")},
+			columns: enrollmentTokenColumnNames,
+			rows:    rows,
+		}
+	}
+	enrollmentCheck := func(enrolled, enrolledBefore bool) *fakeStatement {
+		return &fakeStatement{query: "FROM agent_certificates WHERE agent_id = $1)", columns: []string{"enrolled", "enrolled_before"},
+			rows: [][]driver.Value{{enrolled, enrolledBefore}}}
+	}
+	issue := []*fakeStatement{
+		{query: "INSERT INTO agent_credentials", affected: 1},
+		{query: "SET use_count = use_count + 1", affected: 1},
+	}
+	resetApproval := &fakeStatement{query: "SET approval_status = 'pending'", args: []driver.Value{"agent-1"}, affected: 1}
+
+	tests := []struct {
+		name       string
+		statements []*fakeStatement
+		wantErr    bool
+	}{
+		{name: "unknown token", statements: []*fakeStatement{tokenLookup()}, wantErr: true},
+		{name: "expired token", statements: []*fakeStatement{tokenLookup(enrollmentTokenRow(nil, 0, earlier, nil))}, wantErr: true},
+		{name: "exhausted token", statements: []*fakeStatement{tokenLookup(enrollmentTokenRow(int64(1), 1, later, nil))}, wantErr: true},
+		{name: "revoked token", statements: []*fakeStatement{tokenLookup(enrollmentTokenRow(nil, 0, later, earlier))}, wantErr: true},
+		{
+			name:       "agent with active credential or certificate",
+			statements: []*fakeStatement{tokenLookup(enrollmentTokenRow(nil, 0, later, nil)), enrollmentCheck(true, true)},
+			wantErr:    true,
+		},
+		{
+			name:       "new agent",
+			statements: append([]*fakeStatement{tokenLookup(enrollmentTokenRow(int64(2), 1, later, nil)), enrollmentCheck(false, false)}, issue...),
+		},
+		{
+			name: "enrolling again resets the approval",
+			statements: append([]*fakeStatement{tokenLookup(enrollmentTokenRow(nil, 0, later, nil)), enrollmentCheck(false, true),
+				resetApproval}, issue...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, tt.statements...)
+			s := &SyncToolServer{db: db}
+
+			secret, err := s.enrollAgent("agent-1", "token")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("enrollAgent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if secret != "" || fake.ran("COMMIT") {
+					t.Error("refused enrollment issued a credential")
+				}
+				return
+			}
+			if len(secret) != 64 || !fake.ran("COMMIT") {
+				t.Errorf("enrollAgent() = %q, want a committed 64 character credential", secret)
+			}
+		})
+	}
+}
+
+// TestAuthenticateAgentKeepsEnrolledAgents makes sure an enrollment token never takes over an
+// agent that still has a valid certificate or credential
+func TestAuthenticateAgentKeepsEnrolledAgents(t *testing.T) {
+	ca, err := loadOrCreateAgentCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+
+	certificateCheck := func(valid bool) *fakeStatement {
+		return &fakeStatement{query: "not_after > NOW()", args: []driver.Value{"agent-1"}, columns: []string{"exists"},
+			rows: [][]driver.Value{{valid}}}
+	}
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		statements []*fakeStatement
+		wantErr    error
+	}{
+		{
+			name:       "enrollment token for an agent with a valid certificate",
+			headers:    map[string]string{enrollmentTokenHeader: "token"},
+			statements: []*fakeStatement{certificateCheck(true)},
+			wantErr:    errClientCertificateRequired,
+		},
+		{
+			name:       "credential without the certificate",
+			headers:    map[string]string{agentCredentialHeader: "secret"},
+			statements: []*fakeStatement{certificateCheck(true)},
+			wantErr:    errClientCertificateRequired,
+		},
+		{
+			name:    "enrollment token for an agent with an active credential",
+			headers: map[string]string{enrollmentTokenHeader: "token"},
+			statements: []*fakeStatement{
+				certificateCheck(false),
+				{query: "FOR UPDATE", columns: enrollmentTokenColumnNames, rows: [][]driver.Value{enrollmentTokenRow(nil, 0, later, nil)}},
+				{query: "FROM agent_certificates WHERE agent_id = $1)", columns: []string{"enrolled", "enrolled_before"},
+					rows: [][]driver.Value{{true, true}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, tt.statements...)
+			config := DefaultConfig()
+			config.AgentAuth.Required = true
+			s := &SyncToolServer{db: db, agentCA: ca, config: config}
+
+			r := httptest.NewRequest("GET", "/ws/agent?agent_id=agent-1", nil)
+			for header, value := range tt.headers {
+				r.Header.Set(header, value)
+			}
+
+			issued, verified, err := s.authenticateAgent(r, "agent-1")
+			if err == nil || issued != "" || verified {
+				t.Fatalf("authenticateAgent() = %q, %v, %v, want the connection rejected", issued, verified, err)
+			}
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if fake.ran("INSERT INTO agent_credentials") || fake.ran("COMMIT") {
+				t.Error("a new credential was issued")
+			}
+		})
+	}
+}
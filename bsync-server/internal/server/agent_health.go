@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultHealthHistoryRange = 24 * time.Hour
+	defaultHealthHistoryLimit = 1000
+	maxHealthHistoryLimit     = 10000
+)
+
+// AgentHealthSample is one health report of an agent, or the average of the reports in a bucket
+type AgentHealthSample struct {
+	RecordedAt       time.Time       `json:"recorded_at"`
+	CPUUsage         *float64        `json:"cpu_usage"`
+	CPUCount         *int64          `json:"cpu_count,omitempty"`
+	Load1            *float64        `json:"load_1"`
+	Load5            *float64        `json:"load_5"`
+	Load15           *float64        `json:"load_15"`
+	MemoryUsedBytes  *int64          `json:"memory_used_bytes"`
+	MemoryTotalBytes *int64          `json:"memory_total_bytes"`
+	DiskUsedBytes    *int64          `json:"disk_used_bytes"`
+	DiskTotalBytes   *int64          `json:"disk_total_bytes"`
+	DiskFreeBytes    *int64          `json:"disk_free_bytes"`
+	UptimeSeconds    *int64          `json:"uptime_seconds"`
+	Disks            json.RawMessage `json:"disks,omitempty"` // per folder filesystem, raw samples only
+	Samples          int             `json:"samples"`         // reports in the bucket, 1 for raw samples
+}
+
+// recordAgentHealthSample stores the host metrics of a health report.
+// Agents before the host metrics change send placeholder values without cpu_count; those are skipped.
+func (s *SyncToolServer) recordAgentHealthSample(agentID string, systemInfo map[string]interface{}) {
+	if s.db == nil || !s.config.HealthHistory.Enabled {
+		return
+	}
+	if _, ok := systemInfo["cpu_count"]; !ok {
+		return
+	}
+
+	number := func(key string) interface{} {
+		if value, ok := systemInfo[key].(float64); ok {
+			return value
+		}
+		return nil
+	}
+	integer := func(key string) interface{} {
+		if value, ok := systemInfo[key].(float64); ok {
+			return int64(value)
+		}
+		return nil
+	}
+
+	var loads [3]interface{}
+	if values, ok := systemInfo["load_average"].([]interface{}); ok {
+		for i := 0; i < len(values) && i < len(loads); i++ {
+			if value, ok := values[i].(float64); ok {
+				loads[i] = value
+			}
+		}
+	}
+
+	disks := []byte("[]")
+	if value, ok := systemInfo["disks"]; ok && value != nil {
+		if data, err := json.Marshal(value); err == nil {
+			disks = data
+		}
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO agent_health_samples (agent_id, cpu_usage, cpu_count, load_1, load_5, load_15,
+			memory_used_bytes, memory_total_bytes, disk_used_bytes, disk_total_bytes, disk_free_bytes,
+			uptime_seconds, disks)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, agentID, number("cpu_usage"), integer("cpu_count"), loads[0], loads[1], loads[2],
+		integer("memory_usage"), integer("memory_total"), integer("disk_usage"), integer("disk_total"), integer("disk_free"),
+		integer("uptime"), string(disks))
+	if err != nil {
+		log.Printf("❌ Failed to record health sample of agent %s: %v", agentID, err)
+	}
+}
+
+// handleAgentHealthHistory handles GET /api/agents/{agentId}/health
+// Query: since, until (RFC3339, default the last 24 hours), interval (Go duration, averages
+// the samples per bucket, e.g. 5m), limit (default 1000, newest samples are kept)
+func (s *SyncToolServer) handleAgentHealthHistory(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != "GET" {
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	until := time.Now()
+	if value := query.Get("until"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid until, expected RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+		until = parsed
+	}
+	since := until.Add(-defaultHealthHistoryRange)
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid since, expected RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+	if !since.Before(until) {
+		http.Error(w, `{"error": "since must be before until"}`, http.StatusBadRequest)
+		return
+	}
+
+	var interval time.Duration
+	if value := query.Get("interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < time.Minute {
+			http.Error(w, `{"error": "Invalid interval, expected a duration of at least 1m"}`, http.StatusBadRequest)
+			return
+		}
+		interval = parsed
+	}
+
+	limit := defaultHealthHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxHealthHistoryLimit {
+			http.Error(w, fmt.Sprintf(`{"error": "limit must be between 1 and %d"}`, maxHealthHistoryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM integrated_agents WHERE agent_id = $1)`, agentID).Scan(&exists); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to look up agent: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, `{"error": "Agent not found"}`, http.StatusNotFound)
+		return
+	}
+
+	samples, err := s.loadAgentHealthSamples(agentID, since, until, interval, limit)
+	if err != nil {
+		log.Printf("❌ Failed to query health history of agent %s: %v", agentID, err)
+		http.Error(w, `{"error": "Failed to fetch health history"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"agent_id": agentID,
+		"since":    since.Format(time.RFC3339),
+		"until":    until.Format(time.RFC3339),
+		"data":     samples,
+		"total":    len(samples),
+	}
+	if interval > 0 {
+		response["interval"] = interval.String()
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadAgentHealthSamples returns the samples of an agent in [since, until) in chronological order,
+// averaged per interval when interval is set
+func (s *SyncToolServer) loadAgentHealthSamples(agentID string, since, until time.Time, interval time.Duration, limit int) ([]AgentHealthSample, error) {
+	var query string
+	args := []interface{}{agentID, since, until, limit}
+	if interval > 0 {
+		query = `
+			SELECT * FROM (
+				SELECT to_timestamp(floor(extract(epoch FROM recorded_at) / $5) * $5) AS bucket,
+					AVG(cpu_usage), MAX(cpu_count), AVG(load_1), AVG(load_5), AVG(load_15),
+					AVG(memory_used_bytes)::BIGINT, MAX(memory_total_bytes), AVG(disk_used_bytes)::BIGINT,
+					MAX(disk_total_bytes), MIN(disk_free_bytes), MAX(uptime_seconds), NULL::JSONB, COUNT(*)
+				FROM agent_health_samples
+				WHERE agent_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+				GROUP BY bucket
+				ORDER BY bucket DESC
+				LIMIT $4
+			) buckets ORDER BY bucket`
+		args = append(args, interval.Seconds())
+	} else {
+		query = `
+			SELECT * FROM (
+				SELECT recorded_at, cpu_usage, cpu_count, load_1, load_5, load_15,
+					memory_used_bytes, memory_total_bytes, disk_used_bytes,
+					disk_total_bytes, disk_free_bytes, uptime_seconds, disks, 1
+				FROM agent_health_samples
+				WHERE agent_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+				ORDER BY recorded_at DESC
+				LIMIT $4
+			) samples ORDER BY recorded_at`
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []AgentHealthSample{}
+	for rows.Next() {
+		var sample AgentHealthSample
+		var disks []byte
+		if err := rows.Scan(&sample.RecordedAt, &sample.CPUUsage, &sample.CPUCount, &sample.Load1, &sample.Load5, &sample.Load15,
+			&sample.MemoryUsedBytes, &sample.MemoryTotalBytes, &sample.DiskUsedBytes,
+			&sample.DiskTotalBytes, &sample.DiskFreeBytes, &sample.UptimeSeconds, &disks, &sample.Samples); err != nil {
+			return nil, err
+		}
+		if len(disks) > 0 {
+			sample.Disks = json.RawMessage(disks)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// runHealthHistoryCleanup removes health samples older than the retention period every hour
+func (s *SyncToolServer) runHealthHistoryCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		s.cleanupAgentHealthSamples()
+
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanupAgentHealthSamples removes samples older than the retention period
+func (s *SyncToolServer) cleanupAgentHealthSamples() {
+	result, err := s.db.Exec(`DELETE FROM agent_health_samples WHERE recorded_at < $1`,
+		time.Now().Add(-s.config.HealthHistory.Retention))
+	if err != nil {
+		log.Printf("❌ Failed to clean up agent health samples: %v", err)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		log.Printf("🧹 Removed %d agent health samples older than %v", deleted, s.config.HealthHistory.Retention)
+	}
+}
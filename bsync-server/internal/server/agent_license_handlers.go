@@ -16,16 +16,6 @@ import (
 // GET /api/v1/agent-licenses - List all agent-license mappings
 // POST /api/v1/agent-licenses - Create new agent-license mapping
 func (s *SyncToolServer) handleAgentLicenses(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
 		return
@@ -45,16 +35,6 @@ func (s *SyncToolServer) handleAgentLicenses(w http.ResponseWriter, r *http.Requ
 // GET /api/v1/agent-licenses/{agent_id} - Get mapping by agent ID
 // DELETE /api/v1/agent-licenses/{agent_id} - Delete mapping (stops jobs)
 func (s *SyncToolServer) handleAgentLicenseActions(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
 		return
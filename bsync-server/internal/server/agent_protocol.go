@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// negotiateProtocol decodes a register message and agrees on a protocol version with the agent.
+// Agents that announce a version get a register_ack; legacy agents are served with protocol.LegacyVersion.
+func (c *AgentClient) negotiateProtocol(rawMessage []byte) (*protocol.Register, error) {
+	var reg protocol.Register
+	if err := protocol.Decode(rawMessage, &reg); err != nil {
+		if reg.ProtocolVersion > 0 {
+			c.sendMessage(&protocol.RegisterAck{Type: protocol.TypeRegisterAck, ServerVersion: protocol.Version, Error: err.Error()})
+		}
+		return nil, err
+	}
+
+	version, err := protocol.Negotiate(reg.ProtocolVersion, reg.MinProtocolVersion)
+	if reg.ProtocolVersion > 0 {
+		ack := &protocol.RegisterAck{Type: protocol.TypeRegisterAck, ProtocolVersion: version, ServerVersion: protocol.Version}
+		if err != nil {
+			ack.Error = err.Error()
+		}
+		c.sendMessage(ack)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	peerVersion := reg.ProtocolVersion
+	if peerVersion == 0 {
+		peerVersion = protocol.LegacyVersion
+	}
+	c.protocolVersion = version
+
+	c.hub.mutex.Lock()
+	if existingAgent, exists := c.hub.agents[c.ID]; exists {
+		existingAgent.protocolVersion = version
+	}
+	c.hub.mutex.Unlock()
+
+	log.Printf("🤝 Agent %s speaks protocol v%d (agent supports v%d)", c.ID, version, peerVersion)
+	return &reg, nil
+}
+
+// decodeAgentMessage decodes a message from the agent, logging why it was rejected
+func (c *AgentClient) decodeAgentMessage(rawMessage []byte, msg protocol.Message) bool {
+	if err := protocol.Decode(rawMessage, msg); err != nil {
+		log.Printf("❌ Rejected message from agent %s: %v", c.ID, err)
+		return false
+	}
+	return true
+}
+
+// sendMessage queues a typed message for the agent
+func (c *AgentClient) sendMessage(msg protocol.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s message: %w", msg.MessageType(), err)
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		return fmt.Errorf("agent %s channel is full", c.ID)
+	}
+}
+
+// closeAfterFlush closes the agent connection once queued messages had time to be written
+func (c *AgentClient) closeAfterFlush() {
+	time.AfterFunc(time.Second, func() {
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// agentRequestTimeout is how long the server waits for an agent to answer a request
+const agentRequestTimeout = 30 * time.Second
+
+// requestFromAgent sends a message with a unique request_id to an agent and waits for
+// the response carrying the same request_id (see handleAgentResponse)
+func (s *SyncToolServer) requestFromAgent(agentID string, message map[string]interface{}, timeout time.Duration) (map[string]interface{}, error) {
+	requestID := fmt.Sprintf("%s-%s-%d", agentID, message["type"], time.Now().UnixNano())
+	message["request_id"] = requestID
+
+	// Buffered so a late response never blocks the agent reader
+	responseChannel := make(chan map[string]interface{}, 1)
+
+	s.hub.mutex.Lock()
+	s.hub.agentRequests[requestID] = responseChannel
+	s.hub.mutex.Unlock()
+
+	defer func() {
+		s.hub.mutex.Lock()
+		delete(s.hub.agentRequests, requestID)
+		s.hub.mutex.Unlock()
+	}()
+
+	if err := s.sendJobToAgent(agentID, message); err != nil {
+		return nil, err
+	}
+
+	select {
+	case response := <-responseChannel:
+		if errorMsg, isError := response["error"]; isError {
+			return nil, fmt.Errorf("%v", errorMsg)
+		}
+		return response, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("agent %s did not respond within %v", agentID, timeout)
+	}
+}
+
+// requestMessageFromAgent sends a typed request to an agent and decodes its answer into response
+func (s *SyncToolServer) requestMessageFromAgent(agentID string, request, response protocol.Message, timeout time.Duration) error {
+	data, err := s.requestFromAgent(agentID, protocol.ToMap(request), timeout)
+	if err != nil {
+		return err
+	}
+	return protocol.DecodeMap(data, response)
+}
+
+// handleAgentResponse delivers an agent response to the request waiting for its request_id
+func (h *Hub) handleAgentResponse(agentID string, msgData map[string]interface{}) {
+	requestID, _ := msgData["request_id"].(string)
+
+	h.mutex.Lock()
+	responseChannel, exists := h.agentRequests[requestID]
+	if exists {
+		delete(h.agentRequests, requestID)
+	}
+	h.mutex.Unlock()
+
+	if !exists {
+		log.Printf("⚠️  No pending request %q for agent %s (timed out?)", requestID, agentID)
+		return
+	}
+
+	select {
+	case responseChannel <- msgData:
+	default:
+	}
+}
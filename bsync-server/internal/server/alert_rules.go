@@ -0,0 +1,901 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"bsync-server/utils"
+)
+
+// Alert rule types
+const (
+	AlertRuleAgentOffline       = "agent_offline"        // approved agent disconnected for longer than the duration
+	AlertRuleJobNeedFiles       = "job_need_files"       // job folder needFiles above the threshold for longer than the duration
+	AlertRuleDestinationErrors  = "destination_errors"   // destination error_count above the threshold
+	AlertRuleDestinationDiskLow = "destination_disk_low" // destination folder paused by the disk guard for longer than the duration
+)
+
+// alertRuleTypes lists the supported alert rule types
+var alertRuleTypes = []string{AlertRuleAgentOffline, AlertRuleJobNeedFiles, AlertRuleDestinationErrors, AlertRuleDestinationDiskLow}
+
+// Alert states stored in alert_states.status
+const (
+	alertStatePending  = "pending" // condition holds, duration not reached yet
+	alertStateFiring   = "firing"
+	alertStateResolved = "resolved"
+)
+
+// AlertRule sends an email to its recipients when its condition holds for a subject (agent, job, destination)
+type AlertRule struct {
+	ID              int       `json:"id"`
+	Name            string    `json:"name"`
+	RuleType        string    `json:"rule_type"`
+	Threshold       int       `json:"threshold"`
+	DurationSeconds int       `json:"duration_seconds"`
+	CooldownSeconds int       `json:"cooldown_seconds"`
+	Recipients      []string  `json:"recipients"`
+	SubjectTemplate string    `json:"subject_template,omitempty"` // empty uses utils.DefaultAlertSubjectTemplate
+	HTMLTemplate    string    `json:"html_template,omitempty"`    // empty uses the built-in template of the rule type
+	NotifyResolved  bool      `json:"notify_resolved"`
+	Enabled         bool      `json:"enabled"`
+	CreatedBy       string    `json:"created_by,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// AlertState tracks a rule for one subject across evaluations
+type AlertState struct {
+	ID                int        `json:"id"`
+	RuleID            int        `json:"rule_id"`
+	SubjectKey        string     `json:"subject_key"`
+	Status            string     `json:"status"`
+	Value             float64    `json:"value"`
+	FirstSeenAt       time.Time  `json:"first_seen_at"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	ResolvedAt        *time.Time `json:"resolved_at,omitempty"`
+	Notified          bool       `json:"notified"`
+	LastNotifiedAt    *time.Time `json:"last_notified_at,omitempty"`
+	NotificationCount int        `json:"notification_count"`
+	LastError         string     `json:"last_error,omitempty"`
+}
+
+// alertObservation is a subject matching a rule condition in one evaluation
+type alertObservation struct {
+	Key     string
+	Subject string
+	Value   float64
+	Since   *time.Time // start of the condition when known, otherwise the first evaluation that saw it
+	Details map[string]interface{}
+}
+
+// subjectTemplate returns the subject template used by the rule
+func (rule *AlertRule) subjectTemplate() string {
+	if rule.SubjectTemplate != "" {
+		return rule.SubjectTemplate
+	}
+	return utils.DefaultAlertSubjectTemplate
+}
+
+// htmlTemplate returns the HTML template used by the rule
+func (rule *AlertRule) htmlTemplate() string {
+	if rule.HTMLTemplate != "" {
+		return rule.HTMLTemplate
+	}
+	return utils.DefaultAlertEmailTemplate(rule.RuleType)
+}
+
+// prepare validates the rule and normalizes its fields
+func (rule *AlertRule) prepare() error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.SubjectTemplate = strings.TrimSpace(rule.SubjectTemplate)
+	rule.HTMLTemplate = strings.TrimSpace(rule.HTMLTemplate)
+
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !contains(alertRuleTypes, rule.RuleType) {
+		return fmt.Errorf("rule_type must be one of %s", strings.Join(alertRuleTypes, ", "))
+	}
+	if rule.Threshold < 0 {
+		return fmt.Errorf("threshold must not be negative")
+	}
+	if rule.DurationSeconds < 0 {
+		return fmt.Errorf("duration_seconds must not be negative")
+	}
+	if rule.CooldownSeconds < 0 {
+		return fmt.Errorf("cooldown_seconds must not be negative")
+	}
+
+	recipients := []string{}
+	for _, recipient := range rule.Recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" || contains(recipients, recipient) {
+			continue
+		}
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return fmt.Errorf("invalid recipient %q", recipient)
+		}
+		recipients = append(recipients, recipient)
+	}
+	if rule.Enabled && len(recipients) == 0 {
+		return fmt.Errorf("at least one recipient is required to enable the rule")
+	}
+	rule.Recipients = recipients
+
+	return utils.ParseAlertTemplates(rule.subjectTemplate(), rule.htmlTemplate())
+}
+
+const alertRuleColumns = `id, name, rule_type, threshold, duration_seconds, cooldown_seconds, recipients,
+	COALESCE(subject_template, ''), COALESCE(html_template, ''), notify_resolved, enabled,
+	COALESCE(created_by, ''), created_at, updated_at`
+
+// scanAlertRule reads an alert_rules row selected with alertRuleColumns
+func scanAlertRule(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*AlertRule, error) {
+	rule := &AlertRule{}
+	var recipients pq.StringArray
+	if err := scanner.Scan(&rule.ID, &rule.Name, &rule.RuleType, &rule.Threshold, &rule.DurationSeconds,
+		&rule.CooldownSeconds, &recipients, &rule.SubjectTemplate, &rule.HTMLTemplate, &rule.NotifyResolved,
+		&rule.Enabled, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	rule.Recipients = []string(recipients)
+	return rule, nil
+}
+
+const alertStateColumns = `id, rule_id, subject_key, status, COALESCE(value, 0), first_seen_at, last_seen_at,
+	resolved_at, notified, last_notified_at, notification_count, COALESCE(last_error, '')`
+
+// scanAlertState reads an alert_states row selected with alertStateColumns
+func scanAlertState(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*AlertState, error) {
+	st := &AlertState{}
+	if err := scanner.Scan(&st.ID, &st.RuleID, &st.SubjectKey, &st.Status, &st.Value, &st.FirstSeenAt,
+		&st.LastSeenAt, &st.ResolvedAt, &st.Notified, &st.LastNotifiedAt, &st.NotificationCount,
+		&st.LastError); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// runAlertEvaluator evaluates enabled alert rules every check interval until the server shuts down
+func (s *SyncToolServer) runAlertEvaluator() {
+	ticker := time.NewTicker(s.config.Alerts.CheckInterval)
+	defer ticker.Stop()
+
+	log.Printf("🔔 Alert evaluator started (interval: %v)", s.config.Alerts.CheckInterval)
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			s.evaluateAlertRules()
+		}
+	}
+}
+
+// evaluateAlertRules evaluates every enabled rule once
+func (s *SyncToolServer) evaluateAlertRules() {
+	rows, err := s.db.Query(`SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE enabled = true ORDER BY id ASC`)
+	if err != nil {
+		log.Printf("❌ Failed to load alert rules: %v", err)
+		return
+	}
+
+	rules := []*AlertRule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan alert rule: %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	rows.Close()
+
+	for _, rule := range rules {
+		observations, err := s.observeAlertRule(rule)
+		if err != nil {
+			log.Printf("❌ Failed to evaluate alert rule %d (%s): %v", rule.ID, rule.Name, err)
+			continue
+		}
+		if err := s.applyAlertObservations(rule, observations, time.Now()); err != nil {
+			log.Printf("❌ Failed to update alert rule %d (%s): %v", rule.ID, rule.Name, err)
+		}
+	}
+}
+
+// observeAlertRule returns the subjects currently matching the rule condition, keyed by subject key
+func (s *SyncToolServer) observeAlertRule(rule *AlertRule) (map[string]*alertObservation, error) {
+	switch rule.RuleType {
+	case AlertRuleAgentOffline:
+		return s.observeOfflineAgents()
+	case AlertRuleJobNeedFiles:
+		return s.observeJobNeedFiles(rule.Threshold)
+	case AlertRuleDestinationErrors:
+		return s.observeDestinationErrors(rule.Threshold)
+	case AlertRuleDestinationDiskLow:
+		return s.observeDestinationDiskLow()
+	}
+	return nil, fmt.Errorf("unknown rule type %q", rule.RuleType)
+}
+
+// observeOfflineAgents returns approved agents that are not connected
+func (s *SyncToolServer) observeOfflineAgents() (map[string]*alertObservation, error) {
+	rows, err := s.db.Query(`
+		SELECT agent_id, COALESCE(hostname, ''), COALESCE(ip_address, ''), last_heartbeat
+		FROM integrated_agents
+		WHERE approval_status = 'approved' AND connected = false
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	observations := map[string]*alertObservation{}
+	for rows.Next() {
+		var agentID, hostname, ipAddress string
+		var lastHeartbeat *time.Time
+		if err := rows.Scan(&agentID, &hostname, &ipAddress, &lastHeartbeat); err != nil {
+			return nil, err
+		}
+
+		subject := agentID
+		if hostname != "" && hostname != agentID {
+			subject = fmt.Sprintf("%s (%s)", hostname, agentID)
+		}
+		details := map[string]interface{}{
+			"agent_id":   agentID,
+			"ip_address": ipAddress,
+		}
+		if lastHeartbeat != nil {
+			details["last_heartbeat"] = lastHeartbeat.Format(time.RFC3339)
+		}
+
+		observations[agentID] = &alertObservation{
+			Key:     agentID,
+			Subject: subject,
+			Since:   lastHeartbeat,
+			Details: details,
+		}
+	}
+	return observations, rows.Err()
+}
+
+// observeJobNeedFiles returns job folders whose latest stats report more than threshold files to sync.
+// Paused jobs are skipped since they are expected to fall behind.
+func (s *SyncToolServer) observeJobNeedFiles(threshold int) (map[string]*alertObservation, error) {
+	rows, err := s.db.Query(`SELECT id, name FROM sync_jobs WHERE status NOT IN ('paused', 'completed', 'failed') AND window_paused = false`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobNames := map[string]string{}
+	for rows.Next() {
+		var jobID int
+		var name string
+		if err := rows.Scan(&jobID, &name); err != nil {
+			return nil, err
+		}
+		jobNames[fmt.Sprintf("job-%d", jobID)] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	observations := map[string]*alertObservation{}
+
+	s.folderStatsMu.RLock()
+	defer s.folderStatsMu.RUnlock()
+
+	// Keys are "agentID:folderID"
+	for key, stats := range s.folderStats {
+		separator := strings.LastIndex(key, ":")
+		if separator < 0 {
+			continue
+		}
+		agentID, folderID := key[:separator], key[separator+1:]
+
+		jobName, ok := jobNames[folderID]
+		if !ok {
+			continue
+		}
+		statsData, ok := stats["stats"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		needFiles, _ := statsData["needFiles"].(float64)
+		if needFiles <= float64(threshold) {
+			continue
+		}
+		needBytes, _ := statsData["needBytes"].(float64)
+
+		observations[key] = &alertObservation{
+			Key:     key,
+			Subject: fmt.Sprintf("%s on %s", jobName, agentID),
+			Value:   needFiles,
+			Details: map[string]interface{}{
+				"job_id":     strings.TrimPrefix(folderID, "job-"),
+				"job_name":   jobName,
+				"agent_id":   agentID,
+				"need_files": int64(needFiles),
+				"need_bytes": int64(needBytes),
+			},
+		}
+	}
+	return observations, nil
+}
+
+// observeDestinationErrors returns job destinations with more than threshold errors
+func (s *SyncToolServer) observeDestinationErrors(threshold int) (map[string]*alertObservation, error) {
+	rows, err := s.db.Query(`
+		SELECT d.job_id, j.name, d.destination_agent_id, COALESCE(d.error_count, 0), COALESCE(d.last_error, '')
+		FROM sync_job_destinations d
+		JOIN sync_jobs j ON j.id = d.job_id
+		WHERE COALESCE(d.error_count, 0) > $1
+	`, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	observations := map[string]*alertObservation{}
+	for rows.Next() {
+		var jobID, errorCount int
+		var jobName, agentID, lastError string
+		if err := rows.Scan(&jobID, &jobName, &agentID, &errorCount, &lastError); err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s:job-%d", agentID, jobID)
+		observations[key] = &alertObservation{
+			Key:     key,
+			Subject: fmt.Sprintf("%s → %s", jobName, agentID),
+			Value:   float64(errorCount),
+			Details: map[string]interface{}{
+				"job_id":               jobID,
+				"job_name":             jobName,
+				"destination_agent_id": agentID,
+				"error_count":          errorCount,
+				"last_error":           lastError,
+			},
+		}
+	}
+	return observations, rows.Err()
+}
+
+// recordDestinationError counts a failed job operation on a destination agent (see set_destination_error)
+func (s *SyncToolServer) recordDestinationError(jobID, agentID, message string) {
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return
+	}
+	if _, err := s.db.Exec(`SELECT set_destination_error($1, $2, $3)`, id, agentID, message); err != nil {
+		log.Printf("⚠️  Failed to record destination error for job %s on %s: %v", jobID, agentID, err)
+	}
+}
+
+// clearDestinationErrors resets the error count of a destination after a successful deployment
+func (s *SyncToolServer) clearDestinationErrors(jobID, agentID string) {
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return
+	}
+	_, err = s.db.Exec(`
+		UPDATE sync_job_destinations
+		SET error_count = 0, last_error = NULL, updated_at = NOW()
+		WHERE job_id = $1 AND destination_agent_id = $2 AND (error_count > 0 OR last_error IS NOT NULL)
+	`, id, agentID)
+	if err != nil {
+		log.Printf("⚠️  Failed to reset destination errors for job %s on %s: %v", jobID, agentID, err)
+	}
+}
+
+// recordDeployWarning resets the error count of a destination that deployed a job with a warning,
+// such as a versioning policy it replaced, and keeps the warning as its last error for the job API
+func (s *SyncToolServer) recordDeployWarning(jobID, agentID, warning string) {
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return
+	}
+	_, err = s.db.Exec(`
+		UPDATE sync_job_destinations
+		SET error_count = 0, last_error = $3, updated_at = NOW()
+		WHERE job_id = $1 AND destination_agent_id = $2
+	`, id, agentID, warning)
+	if err != nil {
+		log.Printf("⚠️  Failed to record deploy warning for job %s on %s: %v", jobID, agentID, err)
+	}
+}
+
+// applyAlertObservations updates the rule states with the current observations and sends the emails due.
+// A subject is notified once its condition held for the rule duration, then at most once per cooldown;
+// the cooldown is kept across episodes so a flapping agent does not trigger an email every time.
+func (s *SyncToolServer) applyAlertObservations(rule *AlertRule, observations map[string]*alertObservation, now time.Time) error {
+	rows, err := s.db.Query(`SELECT `+alertStateColumns+` FROM alert_states WHERE rule_id = $1`, rule.ID)
+	if err != nil {
+		return err
+	}
+	states := map[string]*AlertState{}
+	for rows.Next() {
+		st, err := scanAlertState(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		states[st.SubjectKey] = st
+	}
+	rows.Close()
+
+	duration := time.Duration(rule.DurationSeconds) * time.Second
+	cooldown := time.Duration(rule.CooldownSeconds) * time.Second
+
+	for key, obs := range observations {
+		st, exists := states[key]
+		if !exists || st.Status == alertStateResolved {
+			// New episode
+			if !exists {
+				st = &AlertState{RuleID: rule.ID, SubjectKey: key}
+			}
+			st.Status = alertStatePending
+			st.FirstSeenAt = now
+			if obs.Since != nil && obs.Since.Before(now) {
+				st.FirstSeenAt = *obs.Since
+			}
+			st.ResolvedAt = nil
+			st.Notified = false
+		}
+		st.Value = obs.Value
+		st.LastSeenAt = now
+
+		if now.Sub(st.FirstSeenAt) >= duration {
+			st.Status = alertStateFiring
+			if st.LastNotifiedAt == nil || now.Sub(*st.LastNotifiedAt) >= cooldown {
+				s.sendAlertEmail(rule, st, obs, false, now)
+			}
+		}
+
+		if err := s.saveAlertState(st); err != nil {
+			return err
+		}
+	}
+
+	for key, st := range states {
+		if _, stillMatching := observations[key]; stillMatching || st.Status == alertStateResolved {
+			continue
+		}
+
+		if st.Status == alertStateFiring && st.Notified && rule.NotifyResolved {
+			s.sendAlertEmail(rule, st, &alertObservation{Key: key, Subject: key, Value: st.Value}, true, now)
+		}
+		st.Status = alertStateResolved
+		st.ResolvedAt = &now
+
+		if err := s.saveAlertState(st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendAlertEmail renders the rule template for a subject and sends it to the rule recipients
+func (s *SyncToolServer) sendAlertEmail(rule *AlertRule, st *AlertState, obs *alertObservation, resolved bool, now time.Time) {
+	data := utils.AlertEmailData{
+		RuleName:     rule.Name,
+		RuleType:     rule.RuleType,
+		Subject:      obs.Subject,
+		Value:        obs.Value,
+		Threshold:    rule.Threshold,
+		Since:        st.FirstSeenAt,
+		Duration:     formatDuration(int64(now.Sub(st.FirstSeenAt).Seconds())),
+		Details:      obs.Details,
+		Resolved:     resolved,
+		DashboardURL: s.config.WebURL,
+		Time:         now,
+	}
+
+	if err := utils.SendAlertEmail(rule.Recipients, rule.subjectTemplate(), rule.htmlTemplate(), data); err != nil {
+		log.Printf("❌ Failed to send alert email for rule %d (%s), subject %s: %v", rule.ID, rule.Name, st.SubjectKey, err)
+		st.LastError = err.Error()
+		return
+	}
+
+	log.Printf("🔔 Alert email sent for rule %d (%s), subject %s (resolved: %v) to %d recipient(s)",
+		rule.ID, rule.Name, st.SubjectKey, resolved, len(rule.Recipients))
+	st.LastError = ""
+	if !resolved {
+		st.Notified = true
+		st.LastNotifiedAt = &now
+	}
+	st.NotificationCount++
+}
+
+// saveAlertState inserts or updates the state of a rule for one subject
+func (s *SyncToolServer) saveAlertState(st *AlertState) error {
+	return s.db.QueryRow(`
+		INSERT INTO alert_states (rule_id, subject_key, status, value, first_seen_at, last_seen_at, resolved_at,
+			notified, last_notified_at, notification_count, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (rule_id, subject_key) DO UPDATE SET
+			status = EXCLUDED.status,
+			value = EXCLUDED.value,
+			first_seen_at = EXCLUDED.first_seen_at,
+			last_seen_at = EXCLUDED.last_seen_at,
+			resolved_at = EXCLUDED.resolved_at,
+			notified = EXCLUDED.notified,
+			last_notified_at = EXCLUDED.last_notified_at,
+			notification_count = EXCLUDED.notification_count,
+			last_error = EXCLUDED.last_error
+		RETURNING id
+	`, st.RuleID, st.SubjectKey, st.Status, st.Value, st.FirstSeenAt, st.LastSeenAt, st.ResolvedAt,
+		st.Notified, st.LastNotifiedAt, st.NotificationCount, nullIfEmpty(st.LastError)).Scan(&st.ID)
+}
+
+// handleAlertRules handles alert rule list and create
+// GET /api/v1/alert-rules - List alert rules
+// POST /api/v1/alert-rules - Create alert rule
+func (s *SyncToolServer) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.listAlertRules(w, r)
+	case "POST":
+		s.createAlertRule(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertRuleActions handles actions on a specific alert rule
+// GET /api/v1/alert-rules/types - Rule types with their built-in HTML template
+// GET /api/v1/alert-rules/{id} - Get alert rule
+// PUT /api/v1/alert-rules/{id} - Update alert rule
+// DELETE /api/v1/alert-rules/{id} - Delete alert rule
+// GET /api/v1/alert-rules/{id}/states - Current state per subject
+// POST /api/v1/alert-rules/{id}/test - Send a sample email to the rule recipients
+func (s *SyncToolServer) handleAlertRuleActions(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Parse URL path: /api/v1/alert-rules/{id}[/action]
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/alert-rules/"), "/")
+	if len(pathParts) < 1 || pathParts[0] == "" {
+		http.Error(w, `{"error": "Invalid URL format. Expected: /api/v1/alert-rules/{id}"}`, http.StatusBadRequest)
+		return
+	}
+
+	if pathParts[0] == "types" {
+		if r.Method != "GET" {
+			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		types := []map[string]interface{}{}
+		for _, ruleType := range alertRuleTypes {
+			types = append(types, map[string]interface{}{
+				"rule_type":        ruleType,
+				"subject_template": utils.DefaultAlertSubjectTemplate,
+				"html_template":    utils.DefaultAlertEmailTemplate(ruleType),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  types,
+			"total": len(types),
+		})
+		return
+	}
+
+	ruleID, err := strconv.Atoi(pathParts[0])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid alert rule ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if len(pathParts) > 1 {
+		switch {
+		case pathParts[1] == "states" && r.Method == "GET":
+			s.listAlertStates(w, r, ruleID)
+		case pathParts[1] == "test" && r.Method == "POST":
+			s.testAlertRule(w, r, ruleID)
+		case pathParts[1] == "states" || pathParts[1] == "test":
+			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		default:
+			http.Error(w, `{"error": "Unknown alert rule action"}`, http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.getAlertRule(w, r, ruleID)
+	case "PUT":
+		s.updateAlertRule(w, r, ruleID)
+	case "DELETE":
+		s.deleteAlertRule(w, r, ruleID)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// loadAlertRule loads an alert rule by ID
+func (s *SyncToolServer) loadAlertRule(ruleID int) (*AlertRule, error) {
+	return scanAlertRule(s.db.QueryRow(`SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = $1`, ruleID))
+}
+
+// listAlertRules retrieves all alert rules
+func (s *SyncToolServer) listAlertRules(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.Query(`SELECT ` + alertRuleColumns + ` FROM alert_rules ORDER BY id ASC`)
+	if err != nil {
+		log.Printf("❌ Failed to query alert rules: %v", err)
+		http.Error(w, `{"error": "Failed to fetch alert rules"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rules := []*AlertRule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan alert rule: %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  rules,
+		"total": len(rules),
+	})
+}
+
+// createAlertRule creates an alert rule
+func (s *SyncToolServer) createAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := &AlertRule{Enabled: true, CooldownSeconds: int(s.config.Alerts.DefaultCooldown / time.Second)}
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := rule.prepare(); err != nil {
+		writeAlertRuleError(w, err)
+		return
+	}
+	rule.CreatedBy = requestUsername(r)
+
+	err := s.db.QueryRow(`
+		INSERT INTO alert_rules (name, rule_type, threshold, duration_seconds, cooldown_seconds, recipients,
+			subject_template, html_template, notify_resolved, enabled, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, rule.Name, rule.RuleType, rule.Threshold, rule.DurationSeconds, rule.CooldownSeconds,
+		pq.Array(rule.Recipients), nullIfEmpty(rule.SubjectTemplate), nullIfEmpty(rule.HTMLTemplate),
+		rule.NotifyResolved, rule.Enabled, nullIfEmpty(rule.CreatedBy)).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		log.Printf("❌ Failed to create alert rule: %v", err)
+		http.Error(w, `{"error": "Failed to create alert rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Alert rule created successfully",
+		"data":    rule,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Alert rule created: ID=%d, Name=%s, Type=%s", rule.ID, rule.Name, rule.RuleType)
+}
+
+// getAlertRule retrieves a specific alert rule
+func (s *SyncToolServer) getAlertRule(w http.ResponseWriter, r *http.Request, ruleID int) {
+	rule, err := s.loadAlertRule(ruleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Alert rule not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get alert rule: %v", err)
+		http.Error(w, `{"error": "Failed to get alert rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// updateAlertRule updates an alert rule; fields missing from the body keep their current value.
+// States of the rule are kept, so the cooldown of subjects already notified still applies.
+func (s *SyncToolServer) updateAlertRule(w http.ResponseWriter, r *http.Request, ruleID int) {
+	rule, err := s.loadAlertRule(ruleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Alert rule not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get alert rule: %v", err)
+		http.Error(w, `{"error": "Failed to update alert rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	ruleType := rule.RuleType
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	rule.ID = ruleID
+
+	if rule.RuleType != ruleType {
+		writeAlertRuleError(w, fmt.Errorf("rule_type cannot be changed, create a new rule instead"))
+		return
+	}
+	if err := rule.prepare(); err != nil {
+		writeAlertRuleError(w, err)
+		return
+	}
+
+	err = s.db.QueryRow(`
+		UPDATE alert_rules
+		SET name = $1, threshold = $2, duration_seconds = $3, cooldown_seconds = $4, recipients = $5,
+			subject_template = $6, html_template = $7, notify_resolved = $8, enabled = $9, updated_at = NOW()
+		WHERE id = $10
+		RETURNING updated_at
+	`, rule.Name, rule.Threshold, rule.DurationSeconds, rule.CooldownSeconds, pq.Array(rule.Recipients),
+		nullIfEmpty(rule.SubjectTemplate), nullIfEmpty(rule.HTMLTemplate), rule.NotifyResolved, rule.Enabled,
+		ruleID).Scan(&rule.UpdatedAt)
+	if err != nil {
+		log.Printf("❌ Failed to update alert rule: %v", err)
+		http.Error(w, `{"error": "Failed to update alert rule"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Alert rule updated successfully",
+		"data":    rule,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Alert rule updated: ID=%d, Name=%s", ruleID, rule.Name)
+}
+
+// deleteAlertRule deletes an alert rule with its states
+func (s *SyncToolServer) deleteAlertRule(w http.ResponseWriter, r *http.Request, ruleID int) {
+	result, err := s.db.Exec(`DELETE FROM alert_rules WHERE id = $1`, ruleID)
+	if err != nil {
+		log.Printf("❌ Failed to delete alert rule: %v", err)
+		http.Error(w, `{"error": "Failed to delete alert rule"}`, http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, `{"error": "Alert rule not found"}`, http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Alert rule deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Alert rule deleted: ID=%d", ruleID)
+}
+
+// listAlertStates retrieves the state of a rule per subject (?status= filters by status)
+func (s *SyncToolServer) listAlertStates(w http.ResponseWriter, r *http.Request, ruleID int) {
+	query := `SELECT ` + alertStateColumns + ` FROM alert_states WHERE rule_id = $1`
+	args := []interface{}{ruleID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += ` AND status = $2`
+		args = append(args, status)
+	}
+	query += ` ORDER BY last_seen_at DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("❌ Failed to query alert states: %v", err)
+		http.Error(w, `{"error": "Failed to fetch alert states"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	states := []*AlertState{}
+	for rows.Next() {
+		st, err := scanAlertState(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan alert state: %v", err)
+			continue
+		}
+		states = append(states, st)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  states,
+		"total": len(states),
+	})
+}
+
+// testAlertRule sends a sample email rendered with the rule templates to the rule recipients
+func (s *SyncToolServer) testAlertRule(w http.ResponseWriter, r *http.Request, ruleID int) {
+	rule, err := s.loadAlertRule(ruleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Alert rule not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get alert rule: %v", err)
+		http.Error(w, `{"error": "Failed to test alert rule"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(rule.Recipients) == 0 {
+		writeAlertRuleError(w, fmt.Errorf("rule has no recipients"))
+		return
+	}
+
+	now := time.Now()
+	since := now.Add(-time.Duration(rule.DurationSeconds) * time.Second)
+	data := utils.AlertEmailData{
+		RuleName:     rule.Name,
+		RuleType:     rule.RuleType,
+		Subject:      "test-subject",
+		Value:        float64(rule.Threshold + 1),
+		Threshold:    rule.Threshold,
+		Since:        since,
+		Duration:     formatDuration(int64(now.Sub(since).Seconds())),
+		Details:      map[string]interface{}{"note": "This is a test email sent by " + requestUsername(r)},
+		DashboardURL: s.config.WebURL,
+		Time:         now,
+	}
+
+	if err := utils.SendAlertEmail(rule.Recipients, rule.subjectTemplate(), rule.htmlTemplate(), data); err != nil {
+		log.Printf("❌ Failed to send test email for alert rule %d: %v", ruleID, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Failed to send test email: %v", err),
+		})
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Test email sent to %s", strings.Join(rule.Recipients, ", ")),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeAlertRuleError writes a 400 response for an invalid alert rule
+func writeAlertRuleError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid alert rule: %v", err),
+	})
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// BandwidthLimit is a send/receive rate limit in KiB/s (0 = unlimited), the unit used by
+// the agents' sync engine (2 Mbps ≈ 250 KiB/s). The first matching rate profile rule
+// overrides the base limit, e.g. 250 KiB/s in business hours and unlimited at night.
+type BandwidthLimit struct {
+	MaxSendKbps int               `json:"max_send_kbps"`
+	MaxRecvKbps int               `json:"max_recv_kbps"`
+	Timezone    string            `json:"timezone,omitempty"`
+	RateProfile []RateProfileRule `json:"rate_profile,omitempty"`
+}
+
+// RateProfileRule overrides the base limit during a daily time window
+type RateProfileRule struct {
+	Name        string `json:"name,omitempty"`
+	DaysOfWeek  []int  `json:"days_of_week,omitempty"`
+	StartTime   string `json:"start_time"`
+	EndTime     string `json:"end_time"`
+	MaxSendKbps int    `json:"max_send_kbps"`
+	MaxRecvKbps int    `json:"max_recv_kbps"`
+}
+
+// validate checks limits, days, times and time zone
+func (bl *BandwidthLimit) validate() error {
+	if bl.MaxSendKbps < 0 || bl.MaxRecvKbps < 0 {
+		return fmt.Errorf("max_send_kbps and max_recv_kbps must not be negative")
+	}
+	if bl.Timezone == "" {
+		bl.Timezone = defaultScheduleTimezone
+	}
+	if _, err := time.LoadLocation(bl.Timezone); err != nil {
+		return fmt.Errorf("invalid time zone %q: must be an IANA name such as Europe/Berlin", bl.Timezone)
+	}
+
+	for i, rule := range bl.RateProfile {
+		if rule.MaxSendKbps < 0 || rule.MaxRecvKbps < 0 {
+			return fmt.Errorf("rate_profile[%d]: limits must not be negative", i)
+		}
+		for _, day := range rule.DaysOfWeek {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("rate_profile[%d]: days_of_week values must be 0 (Sunday) to 6 (Saturday)", i)
+			}
+		}
+		if _, err := parseClockMinutes(rule.StartTime); err != nil {
+			return fmt.Errorf("rate_profile[%d]: invalid start_time: %v", i, err)
+		}
+		if _, err := parseClockMinutes(rule.EndTime); err != nil {
+			return fmt.Errorf("rate_profile[%d]: invalid end_time: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// parseBandwidthLimit converts a request body field into a validated limit (null = no limit)
+func parseBandwidthLimit(raw interface{}) (*BandwidthLimit, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var limit BandwidthLimit
+	if err := json.Unmarshal(data, &limit); err != nil {
+		return nil, err
+	}
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// bandwidthFromJobData reads "bandwidth_limit" from a create/update request body.
+// present is false if the field was not sent at all.
+func bandwidthFromJobData(jobData map[string]interface{}) (limit *BandwidthLimit, present bool, err error) {
+	raw, present := jobData["bandwidth_limit"]
+	if !present {
+		return nil, false, nil
+	}
+	limit, err = parseBandwidthLimit(raw)
+	return limit, true, err
+}
+
+// bandwidthLimitJSON converts a limit into a value for a JSONB column
+func bandwidthLimitJSON(limit *BandwidthLimit) interface{} {
+	if limit == nil {
+		return nil
+	}
+	data, _ := json.Marshal(limit)
+	return string(data)
+}
+
+// scanBandwidthLimit decodes a nullable JSONB column
+func scanBandwidthLimit(raw sql.NullString) *BandwidthLimit {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var limit BandwidthLimit
+	if err := json.Unmarshal([]byte(raw.String), &limit); err != nil {
+		log.Printf("⚠️ Ignoring invalid stored bandwidth limit: %v", err)
+		return nil
+	}
+	return &limit
+}
+
+// jobBandwidthLimit returns the bandwidth limit of a job, nil if unlimited
+func (s *SyncToolServer) jobBandwidthLimit(jobID string) *BandwidthLimit {
+	if s.db == nil {
+		return nil
+	}
+	var raw sql.NullString
+	if err := s.db.QueryRow(`SELECT bandwidth_limit FROM sync_jobs WHERE id = $1`, jobID).Scan(&raw); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load bandwidth limit for job %s: %v", jobID, err)
+		}
+		return nil
+	}
+	return scanBandwidthLimit(raw)
+}
+
+// agentBandwidthLimit returns the agent-wide bandwidth limit, nil if unlimited
+func (s *SyncToolServer) agentBandwidthLimit(agentID string) (*BandwidthLimit, error) {
+	var raw sql.NullString
+	err := s.db.QueryRow(`SELECT bandwidth_limit FROM integrated_agents WHERE agent_id = $1`, agentID).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	return scanBandwidthLimit(raw), nil
+}
+
+// pushAgentBandwidthLimit sends the agent-wide limit to a connected agent
+func (s *SyncToolServer) pushAgentBandwidthLimit(agentID string) {
+	limit, err := s.agentBandwidthLimit(agentID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load bandwidth limit for agent %s: %v", agentID, err)
+		}
+		return
+	}
+
+	message := map[string]interface{}{
+		"type":        "set_bandwidth_limits",
+		"agent_limit": limit,
+	}
+	if err := s.sendJobToAgent(agentID, message); err != nil {
+		log.Printf("⚠️ Failed to push bandwidth limit to agent %s: %v", agentID, err)
+		return
+	}
+	log.Printf("🚦 Pushed bandwidth limit to agent %s", agentID)
+}
+
+// handleAgentBandwidth handles GET/PUT/DELETE /api/agents/{agentId}/bandwidth
+func (s *SyncToolServer) handleAgentBandwidth(w http.ResponseWriter, r *http.Request, agentID string) {
+	switch r.Method {
+	case "GET":
+		limit, err := s.agentBandwidthLimit(agentID)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"error": "Agent not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to get bandwidth limit: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"agent_id":        agentID,
+			"bandwidth_limit": limit,
+		})
+
+	case "PUT", "DELETE":
+		var limit *BandwidthLimit
+		if r.Method == "PUT" {
+			var body interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+				return
+			}
+			var err error
+			if limit, err = parseBandwidthLimit(body); err != nil {
+				writeBandwidthLimitError(w, err)
+				return
+			}
+		}
+
+		result, err := s.db.Exec(`UPDATE integrated_agents SET bandwidth_limit = $1, updated_at = NOW() WHERE agent_id = $2`, bandwidthLimitJSON(limit), agentID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to save bandwidth limit: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			http.Error(w, `{"error": "Agent not found"}`, http.StatusNotFound)
+			return
+		}
+
+		go s.pushAgentBandwidthLimit(agentID)
+
+		message := fmt.Sprintf("Bandwidth limit for agent %s updated", agentID)
+		if limit == nil {
+			message = fmt.Sprintf("Bandwidth limit for agent %s removed", agentID)
+		}
+		log.Printf("🚦 %s", message)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":         true,
+			"message":         message,
+			"bandwidth_limit": limit,
+		})
+
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// writeBandwidthLimitError writes a 400 response for an invalid bandwidth limit
+func writeBandwidthLimitError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid bandwidth limit: %v", err),
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import "testing"
+
+func TestParseBandwidthLimit(t *testing.T) {
+	rule := func(start, end string, days ...int) map[string]interface{} {
+		return map[string]interface{}{"start_time": start, "end_time": end, "days_of_week": days, "max_send_kbps": 250, "max_recv_kbps": 250}
+	}
+
+	tests := []struct {
+		name         string
+		raw          interface{}
+		wantNil      bool
+		wantTimezone string
+		wantRules    int
+		wantErr      bool
+	}{
+		{name: "null removes the limit", raw: nil, wantNil: true},
+		{name: "base limit only", raw: map[string]interface{}{"max_send_kbps": 1000}, wantTimezone: "UTC"},
+		{name: "rate profile", raw: map[string]interface{}{
+			"timezone":     "Europe/Berlin",
+			"rate_profile": []interface{}{rule("08:00", "18:00", 1, 2, 3, 4, 5), rule("22:00", "06:00")},
+		}, wantTimezone: "Europe/Berlin", wantRules: 2},
+		{name: "negative base limit", raw: map[string]interface{}{"max_recv_kbps": -1}, wantErr: true},
+		{name: "invalid time zone", raw: map[string]interface{}{"timezone": "Mars/Olympus"}, wantErr: true},
+		{name: "negative rule limit", raw: map[string]interface{}{"rate_profile": []interface{}{
+			map[string]interface{}{"start_time": "08:00", "end_time": "18:00", "max_send_kbps": -5},
+		}}, wantErr: true},
+		{name: "invalid rule day", raw: map[string]interface{}{"rate_profile": []interface{}{rule("08:00", "18:00", 7)}}, wantErr: true},
+		{name: "invalid rule time", raw: map[string]interface{}{"rate_profile": []interface{}{rule("8am", "18:00")}}, wantErr: true},
+		{name: "missing rule end", raw: map[string]interface{}{"rate_profile": []interface{}{rule("08:00", "")}}, wantErr: true},
+		{name: "wrong type", raw: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := parseBandwidthLimit(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (limit == nil) != tt.wantNil {
+				t.Fatalf("limit = %+v, want nil %v", limit, tt.wantNil)
+			}
+			if limit == nil {
+				return
+			}
+			if limit.Timezone != tt.wantTimezone || len(limit.RateProfile) != tt.wantRules {
+				t.Errorf("got time zone %q and %d rules, want %q and %d", limit.Timezone, len(limit.RateProfile), tt.wantTimezone, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestBandwidthFromJobData(t *testing.T) {
+	if _, present, err := bandwidthFromJobData(map[string]interface{}{"name": "job"}); present || err != nil {
+		t.Errorf("missing field: present = %v, err = %v", present, err)
+	}
+	if limit, present, err := bandwidthFromJobData(map[string]interface{}{"bandwidth_limit": nil}); !present || limit != nil || err != nil {
+		t.Errorf("null field: limit = %v, present = %v, err = %v", limit, present, err)
+	}
+	if _, present, err := bandwidthFromJobData(map[string]interface{}{"bandwidth_limit": map[string]interface{}{"max_send_kbps": -1}}); !present || err == nil {
+		t.Errorf("invalid field: present = %v, err = %v", present, err)
+	}
+}
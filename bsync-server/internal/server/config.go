@@ -0,0 +1,614 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"bsync-server/config"
+)
+
+// Config holds the server configuration loaded from defaults, config file,
+// environment variables and command line flags (in that order of precedence)
+type Config struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	LogLevel string `yaml:"log_level"`
+	WebURL   string `yaml:"web_url"`
+
+	Database   DatabaseConfig   `yaml:"database"`
+	Auth       AuthConfig       `yaml:"auth"`
+	CORS       CORSConfig       `yaml:"cors"`
+	SMTP       SMTPConfig       `yaml:"smtp"`
+	TLS        TLSConfig        `yaml:"tls"`
+	Scheduler  SchedulerConfig  `yaml:"scheduler"`
+	EventStore EventStoreConfig `yaml:"event_store"`
+	AgentAuth  AgentAuthConfig  `yaml:"agent_auth"`
+	Webhooks   WebhookConfig    `yaml:"webhooks"`
+	Alerts     AlertConfig      `yaml:"alerts"`
+	Metrics    MetricsConfig    `yaml:"metrics"`
+
+	HealthHistory HealthHistoryConfig `yaml:"health_history"`
+	DiskGuard     DiskGuardConfig     `yaml:"disk_guard"`
+	Verification  VerificationConfig  `yaml:"verification"`
+	Mirror        MirrorConfig        `yaml:"mirror"`
+}
+
+// DatabaseConfig holds PostgreSQL connection settings
+type DatabaseConfig struct {
+	// DSN overrides all individual connection fields when set
+	DSN             string        `yaml:"dsn"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// AuthConfig holds JWT settings for user authentication
+type AuthConfig struct {
+	JWTSecret     string        `yaml:"jwt_secret"`
+	TokenLifetime time.Duration `yaml:"token_lifetime"`
+}
+
+// CORSConfig holds allowed origins for browser clients
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// SMTPConfig holds outgoing mail settings
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// SchedulerConfig holds settings for the scheduled job runner
+type SchedulerConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	CheckInterval time.Duration `yaml:"check_interval"`
+	BatchSize     int           `yaml:"batch_size"`
+}
+
+// EventStoreConfig holds settings for the agent event store
+type EventStoreConfig struct {
+	Type       string `yaml:"type"` // memory, postgres
+	BufferSize int    `yaml:"buffer_size"`
+
+	// Postgres store only
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	Retention     time.Duration `yaml:"retention"` // 0 keeps events forever
+}
+
+// AgentAuthConfig holds settings for authenticating agent WebSocket connections
+type AgentAuthConfig struct {
+	// Required rejects agents that present neither a credential nor an enrollment token (default).
+	// Turn off only temporarily while agents from before enrollment are being enrolled.
+	Required bool `yaml:"required"`
+}
+
+// WebhookConfig holds settings for webhook deliveries
+type WebhookConfig struct {
+	Timeout        time.Duration `yaml:"timeout"`          // per attempt
+	MaxAttempts    int           `yaml:"max_attempts"`     // default for new webhooks
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"` // doubled after every failed attempt
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+	AllowHTTP      bool          `yaml:"allow_http"` // accept http:// endpoints (testing only)
+	Retention      time.Duration `yaml:"retention"`  // delivery log retention, 0 keeps deliveries forever
+
+	// LicenseExpiryWarning is how long before the expiry of a license license_expiring is sent, 0 turns it off
+	LicenseExpiryWarning time.Duration `yaml:"license_expiry_warning"`
+}
+
+// AlertConfig holds settings for email alert rules
+type AlertConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	CheckInterval   time.Duration `yaml:"check_interval"`   // how often rules are evaluated
+	DefaultCooldown time.Duration `yaml:"default_cooldown"` // cooldown of rules created without one
+}
+
+// MetricsConfig holds settings for the Prometheus /metrics endpoint
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"` // bearer token required to scrape, empty leaves /metrics open
+}
+
+// HealthHistoryConfig holds settings for the time series of agent health reports
+type HealthHistoryConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Retention time.Duration `yaml:"retention"` // 0 keeps samples forever
+}
+
+// DiskGuardConfig holds the disk space checks of destination agents
+type DiskGuardConfig struct {
+	CheckBeforeDeploy bool    `yaml:"check_before_deploy"` // refuse deployments whose source data does not fit a destination
+	MinFreeMB         int     `yaml:"min_free_mb"`         // default minimum free space of jobs without their own disk_guard
+	MinFreePercent    float64 `yaml:"min_free_percent"`    // the larger threshold applies, both 0 disables the guard
+}
+
+// VerificationConfig holds the content integrity checks of job destinations
+type VerificationConfig struct {
+	Timeout   time.Duration `yaml:"timeout"`   // how long agents may take to hash a job folder
+	Retention time.Duration `yaml:"retention"` // 0 keeps verification reports forever
+}
+
+// MirrorConfig holds the progress checks of one-shot mirror jobs
+type MirrorConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval"` // how often the folder state of running mirrors is polled
+	Timeout       time.Duration `yaml:"timeout"`        // a mirror fails if it is not complete after this long, 0 = no limit
+}
+
+const minJWTSecretLength = 32
+
+// DefaultConfig returns a configuration with development defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Host:     "0.0.0.0",
+		Port:     8090,
+		LogLevel: "info",
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "bsync",
+			Password:        "bsync_password",
+			Name:            "bsync",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Auth: AuthConfig{
+			TokenLifetime: 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+		},
+		SMTP: SMTPConfig{
+			Host: "smtp.gmail.com",
+			Port: 587,
+		},
+		TLS: TLSConfig{
+			MTLS: MTLSConfig{
+				CADir:        "./data/ca",
+				CertLifetime: 90 * 24 * time.Hour,
+				RenewBefore:  30 * 24 * time.Hour,
+			},
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:       true,
+			CheckInterval: 1 * time.Minute,
+			BatchSize:     50,
+		},
+		EventStore: EventStoreConfig{
+			Type:          "memory",
+			BufferSize:    10000,
+			BatchSize:     100,
+			FlushInterval: 1 * time.Second,
+		},
+		AgentAuth: AgentAuthConfig{
+			Required: true,
+		},
+		Webhooks: WebhookConfig{
+			Timeout:        10 * time.Second,
+			MaxAttempts:    6,
+			RetryBaseDelay: 30 * time.Second,
+			RetryMaxDelay:  1 * time.Hour,
+			Retention:      30 * 24 * time.Hour,
+
+			LicenseExpiryWarning: 14 * 24 * time.Hour,
+		},
+		Alerts: AlertConfig{
+			Enabled:         true,
+			CheckInterval:   1 * time.Minute,
+			DefaultCooldown: 1 * time.Hour,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		HealthHistory: HealthHistoryConfig{
+			Enabled:   true,
+			Retention: 30 * 24 * time.Hour,
+		},
+		DiskGuard: DiskGuardConfig{
+			CheckBeforeDeploy: true,
+			MinFreeMB:         1024,
+			MinFreePercent:    5,
+		},
+		Verification: VerificationConfig{
+			Timeout:   1 * time.Hour,
+			Retention: 90 * 24 * time.Hour,
+		},
+		Mirror: MirrorConfig{
+			CheckInterval: 30 * time.Second,
+			Timeout:       72 * time.Hour,
+		},
+	}
+}
+
+// LoadFromFile reads a YAML config file on top of the current values.
+// Keys missing from the file keep their current value.
+func (c *Config) LoadFromFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ApplyEnvOverrides overrides config values with environment variables when set
+func (c *Config) ApplyEnvOverrides() error {
+	var errs []string
+
+	setString := func(name string, dst *string) {
+		if v := os.Getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	setInt := func(name string, dst *int) {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid integer %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	setBool := func(name string, dst *bool) {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid boolean %q", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	setFloat := func(name string, dst *float64) {
+		if v := os.Getenv(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid number %q", name, v))
+				return
+			}
+			*dst = f
+		}
+	}
+	setDuration := func(name string, dst *time.Duration) {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid duration %q", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	setString("BSYNC_HOST", &c.Host)
+	setInt("BSYNC_PORT", &c.Port)
+	setString("BSYNC_LOG_LEVEL", &c.LogLevel)
+	setString("WEB_URL", &c.WebURL)
+
+	setString("DATABASE_URL", &c.Database.DSN)
+	setString("DB_HOST", &c.Database.Host)
+	setInt("DB_PORT", &c.Database.Port)
+	setString("DB_USER", &c.Database.User)
+	setString("DB_PASSWORD", &c.Database.Password)
+	setString("DB_NAME", &c.Database.Name)
+	setString("DB_SSLMODE", &c.Database.SSLMode)
+	setInt("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	setInt("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+
+	setString("JWT_SECRET", &c.Auth.JWTSecret)
+	setDuration("JWT_TOKEN_LIFETIME", &c.Auth.TokenLifetime)
+
+	if v := os.Getenv("CORS_ALLOWED_ORIGIN"); v != "" {
+		c.CORS.AllowedOrigins = splitAndTrim(v)
+	}
+
+	setString("SMTP_HOST", &c.SMTP.Host)
+	setInt("SMTP_PORT", &c.SMTP.Port)
+	setString("SMTP_USERNAME", &c.SMTP.Username)
+	setString("SMTP_PASSWORD", &c.SMTP.Password)
+	setString("SMTP_FROM", &c.SMTP.From)
+
+	setBool("TLS_ENABLED", &c.TLS.Enabled)
+	setString("TLS_CERT_FILE", &c.TLS.CertFile)
+	setString("TLS_KEY_FILE", &c.TLS.KeyFile)
+	setBool("TLS_AUTO", &c.TLS.AutoTLS)
+	setBool("TLS_MTLS_ENABLED", &c.TLS.MTLS.Enabled)
+	setBool("TLS_MTLS_REQUIRED", &c.TLS.MTLS.Required)
+	setString("TLS_MTLS_CA_DIR", &c.TLS.MTLS.CADir)
+	setDuration("TLS_MTLS_CERT_LIFETIME", &c.TLS.MTLS.CertLifetime)
+	setDuration("TLS_MTLS_RENEW_BEFORE", &c.TLS.MTLS.RenewBefore)
+
+	setBool("SCHEDULER_ENABLED", &c.Scheduler.Enabled)
+	setDuration("SCHEDULER_CHECK_INTERVAL", &c.Scheduler.CheckInterval)
+	setInt("SCHEDULER_BATCH_SIZE", &c.Scheduler.BatchSize)
+
+	setString("EVENT_STORE_TYPE", &c.EventStore.Type)
+	setInt("EVENT_STORE_BUFFER_SIZE", &c.EventStore.BufferSize)
+	setInt("EVENT_STORE_BATCH_SIZE", &c.EventStore.BatchSize)
+	setDuration("EVENT_STORE_FLUSH_INTERVAL", &c.EventStore.FlushInterval)
+	setDuration("EVENT_STORE_RETENTION", &c.EventStore.Retention)
+
+	setBool("AGENT_AUTH_REQUIRED", &c.AgentAuth.Required)
+
+	setDuration("WEBHOOK_TIMEOUT", &c.Webhooks.Timeout)
+	setInt("WEBHOOK_MAX_ATTEMPTS", &c.Webhooks.MaxAttempts)
+	setDuration("WEBHOOK_RETRY_BASE_DELAY", &c.Webhooks.RetryBaseDelay)
+	setDuration("WEBHOOK_RETRY_MAX_DELAY", &c.Webhooks.RetryMaxDelay)
+	setBool("WEBHOOK_ALLOW_HTTP", &c.Webhooks.AllowHTTP)
+	setDuration("WEBHOOK_RETENTION", &c.Webhooks.Retention)
+	setDuration("WEBHOOK_LICENSE_EXPIRY_WARNING", &c.Webhooks.LicenseExpiryWarning)
+
+	setBool("ALERTS_ENABLED", &c.Alerts.Enabled)
+	setDuration("ALERT_CHECK_INTERVAL", &c.Alerts.CheckInterval)
+	setDuration("ALERT_DEFAULT_COOLDOWN", &c.Alerts.DefaultCooldown)
+
+	setBool("METRICS_ENABLED", &c.Metrics.Enabled)
+	setString("METRICS_TOKEN", &c.Metrics.Token)
+
+	setBool("HEALTH_HISTORY_ENABLED", &c.HealthHistory.Enabled)
+	setDuration("HEALTH_HISTORY_RETENTION", &c.HealthHistory.Retention)
+
+	setBool("DISK_GUARD_CHECK_BEFORE_DEPLOY", &c.DiskGuard.CheckBeforeDeploy)
+	setInt("DISK_GUARD_MIN_FREE_MB", &c.DiskGuard.MinFreeMB)
+	setFloat("DISK_GUARD_MIN_FREE_PERCENT", &c.DiskGuard.MinFreePercent)
+
+	setDuration("VERIFICATION_TIMEOUT", &c.Verification.Timeout)
+	setDuration("VERIFICATION_RETENTION", &c.Verification.Retention)
+
+	setDuration("MIRROR_CHECK_INTERVAL", &c.Mirror.CheckInterval)
+	setDuration("MIRROR_TIMEOUT", &c.Mirror.Timeout)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment overrides: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Validate checks the configuration and returns all problems found
+func (c *Config) Validate() error {
+	var errs []string
+
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Sprintf("port must be between 1 and 65535 (got %d)", c.Port))
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Sprintf("log_level must be one of debug, info, warn, error (got %q)", c.LogLevel))
+	}
+
+	if c.Database.DSN == "" {
+		if c.Database.Host == "" {
+			errs = append(errs, "database.host is required when database.dsn is not set")
+		}
+		if c.Database.Name == "" {
+			errs = append(errs, "database.name is required when database.dsn is not set")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			errs = append(errs, fmt.Sprintf("database.port must be between 1 and 65535 (got %d)", c.Database.Port))
+		}
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, "database connection pool sizes must not be negative")
+	}
+
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < minJWTSecretLength {
+		errs = append(errs, fmt.Sprintf("auth.jwt_secret must be at least %d characters", minJWTSecretLength))
+	}
+	if c.Auth.TokenLifetime <= 0 {
+		errs = append(errs, "auth.token_lifetime must be positive")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, "cors.allowed_origins must contain at least one origin (use \"*\" to allow all)")
+	}
+
+	if c.SMTP.Host != "" && (c.SMTP.Port <= 0 || c.SMTP.Port > 65535) {
+		errs = append(errs, fmt.Sprintf("smtp.port must be between 1 and 65535 (got %d)", c.SMTP.Port))
+	}
+
+	if c.TLS.Enabled && !c.TLS.AutoTLS {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			errs = append(errs, "tls.cert_file and tls.key_file are required when TLS is enabled without auto_tls")
+		} else {
+			if _, err := os.Stat(c.TLS.CertFile); err != nil {
+				errs = append(errs, fmt.Sprintf("tls.cert_file: %v", err))
+			}
+			if _, err := os.Stat(c.TLS.KeyFile); err != nil {
+				errs = append(errs, fmt.Sprintf("tls.key_file: %v", err))
+			}
+		}
+	}
+
+	if c.TLS.MTLS.Enabled {
+		if !c.TLS.Enabled {
+			errs = append(errs, "tls.mtls requires tls.enabled")
+		}
+		if c.TLS.MTLS.CADir == "" {
+			errs = append(errs, "tls.mtls.ca_dir is required when mTLS is enabled")
+		}
+		if c.TLS.MTLS.CertLifetime < time.Hour {
+			errs = append(errs, "tls.mtls.cert_lifetime must be at least 1h")
+		}
+		if c.TLS.MTLS.RenewBefore <= 0 || c.TLS.MTLS.RenewBefore >= c.TLS.MTLS.CertLifetime {
+			errs = append(errs, "tls.mtls.renew_before must be positive and shorter than tls.mtls.cert_lifetime")
+		}
+	}
+
+	if c.Scheduler.Enabled {
+		if c.Scheduler.CheckInterval < time.Second {
+			errs = append(errs, "scheduler.check_interval must be at least 1s")
+		}
+		if c.Scheduler.BatchSize <= 0 {
+			errs = append(errs, "scheduler.batch_size must be positive")
+		}
+	}
+
+	switch c.EventStore.Type {
+	case "memory":
+		if c.EventStore.BufferSize <= 0 {
+			errs = append(errs, "event_store.buffer_size must be positive")
+		}
+	case "postgres":
+		if c.EventStore.BatchSize <= 0 {
+			errs = append(errs, "event_store.batch_size must be positive")
+		}
+		if c.EventStore.FlushInterval <= 0 {
+			errs = append(errs, "event_store.flush_interval must be positive")
+		}
+		if c.EventStore.Retention < 0 {
+			errs = append(errs, "event_store.retention must not be negative")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("event_store.type must be \"memory\" or \"postgres\" (got %q)", c.EventStore.Type))
+	}
+
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, "webhooks.timeout must be positive")
+	}
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.MaxAttempts > maxWebhookAttempts {
+		errs = append(errs, fmt.Sprintf("webhooks.max_attempts must be between 1 and %d", maxWebhookAttempts))
+	}
+	if c.Webhooks.RetryBaseDelay <= 0 {
+		errs = append(errs, "webhooks.retry_base_delay must be positive")
+	}
+	if c.Webhooks.RetryMaxDelay < c.Webhooks.RetryBaseDelay {
+		errs = append(errs, "webhooks.retry_max_delay must not be less than webhooks.retry_base_delay")
+	}
+	if c.Webhooks.Retention < 0 {
+		errs = append(errs, "webhooks.retention must not be negative")
+	}
+	if c.Webhooks.LicenseExpiryWarning < 0 {
+		errs = append(errs, "webhooks.license_expiry_warning must not be negative")
+	}
+
+	if c.Alerts.Enabled && c.Alerts.CheckInterval <= 0 {
+		errs = append(errs, "alerts.check_interval must be positive")
+	}
+	if c.Alerts.DefaultCooldown < 0 {
+		errs = append(errs, "alerts.default_cooldown must not be negative")
+	}
+
+	if c.HealthHistory.Retention < 0 {
+		errs = append(errs, "health_history.retention must not be negative")
+	}
+
+	if c.DiskGuard.MinFreeMB < 0 {
+		errs = append(errs, "disk_guard.min_free_mb must not be negative")
+	}
+	if c.DiskGuard.MinFreePercent < 0 || c.DiskGuard.MinFreePercent >= 100 {
+		errs = append(errs, "disk_guard.min_free_percent must be between 0 and 100")
+	}
+
+	if c.Verification.Timeout <= 0 {
+		errs = append(errs, "verification.timeout must be positive")
+	}
+	if c.Verification.Retention < 0 {
+		errs = append(errs, "verification.retention must not be negative")
+	}
+
+	if c.Mirror.CheckInterval <= 0 {
+		errs = append(errs, "mirror.check_interval must be positive")
+	}
+	if c.Mirror.Timeout < 0 {
+		errs = append(errs, "mirror.timeout must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// DataSourceName builds the PostgreSQL connection string
+func (d *DatabaseConfig) DataSourceName() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	parts := []string{
+		fmt.Sprintf("host=%s", d.Host),
+		fmt.Sprintf("port=%d", d.Port),
+		fmt.Sprintf("dbname=%s", d.Name),
+	}
+	if d.User != "" {
+		parts = append(parts, fmt.Sprintf("user=%s", d.User))
+	}
+	if d.Password != "" {
+		parts = append(parts, fmt.Sprintf("password=%s", quoteDSNValue(d.Password)))
+	}
+	if d.SSLMode != "" {
+		parts = append(parts, fmt.Sprintf("sslmode=%s", d.SSLMode))
+	}
+	return strings.Join(parts, " ")
+}
+
+// IsOriginAllowed reports whether a browser origin is in the CORS allow list
+func (c *CORSConfig) IsOriginAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMailSettings registers SMTP and web URL settings with the shared config package
+// used by the utils mailer
+func (c *Config) applyMailSettings() {
+	config.SetSMTPConfig(&config.SMTPConfig{
+		Host:     c.SMTP.Host,
+		Port:     strconv.Itoa(c.SMTP.Port),
+		Username: c.SMTP.Username,
+		Password: c.SMTP.Password,
+		From:     c.SMTP.From,
+	})
+
+	if c.WebURL != "" {
+		config.SetWebURL(c.WebURL)
+	}
+}
+
+// quoteDSNValue quotes a libpq connection string value if it contains spaces or quotes
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	escaped := strings.Replace(value, `\`, `\\`, -1)
+	escaped = strings.Replace(escaped, `'`, `\'`, -1)
+	return "'" + escaped + "'"
+}
+
+// splitAndTrim splits a comma separated list and drops empty entries
+func splitAndTrim(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
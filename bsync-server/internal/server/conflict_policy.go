@@ -0,0 +1,167 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// Conflict policy strategies, applied by the agent that finds a conflict copy
+const (
+	conflictStrategySourceWins = "source_wins" // the version of the source agent is kept
+	conflictStrategyNewest     = "newest"      // the version with the newest modification time is kept
+	conflictStrategyLargest    = "largest"     // the largest version is kept
+	conflictStrategyKeepBoth   = "keep_both"   // conflict copies are left in place, up to max_conflicts per file
+)
+
+// maxConflictsLimit bounds max_conflicts
+const maxConflictsLimit = 1000
+
+// ConflictPolicy resolves the conflicts of a sendreceive job automatically.
+// MaxConflicts is the number of conflict copies kept per file (0 = unlimited), older copies are deleted.
+type ConflictPolicy struct {
+	Strategy     string `json:"strategy"`
+	MaxConflicts int    `json:"max_conflicts"`
+}
+
+// validate checks a conflict policy
+func (p *ConflictPolicy) validate() error {
+	switch p.Strategy {
+	case conflictStrategySourceWins, conflictStrategyNewest, conflictStrategyLargest, conflictStrategyKeepBoth:
+	default:
+		return fmt.Errorf("strategy must be source_wins, newest, largest or keep_both")
+	}
+	if p.MaxConflicts < 0 || p.MaxConflicts > maxConflictsLimit {
+		return fmt.Errorf("max_conflicts must be between 0 (unlimited) and %d", maxConflictsLimit)
+	}
+	return nil
+}
+
+// conflictPolicyFromJobData reads "conflict_policy" from a create/update request body.
+// present is false if the field was not sent at all; null resolves conflicts manually.
+func conflictPolicyFromJobData(jobData map[string]interface{}) (policy *ConflictPolicy, present bool, err error) {
+	raw, present := jobData["conflict_policy"]
+	if !present || raw == nil {
+		return nil, present, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, true, err
+	}
+	policy = &ConflictPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, true, err
+	}
+	policy.Strategy = strings.ToLower(strings.TrimSpace(policy.Strategy))
+	if err := policy.validate(); err != nil {
+		return nil, true, err
+	}
+	return policy, true, nil
+}
+
+// conflictPolicyJSON converts a policy into a value for a JSONB column
+func conflictPolicyJSON(policy *ConflictPolicy) interface{} {
+	if policy == nil {
+		return nil
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// scanConflictPolicy decodes a nullable JSONB column
+func scanConflictPolicy(raw sql.NullString) *ConflictPolicy {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var policy ConflictPolicy
+	if err := json.Unmarshal([]byte(raw.String), &policy); err != nil {
+		log.Printf("⚠️ Ignoring invalid stored conflict policy: %v", err)
+		return nil
+	}
+	return &policy
+}
+
+// jobConflictPolicy returns the conflict policy of a job, nil if conflicts are resolved manually
+func (s *SyncToolServer) jobConflictPolicy(jobID string) *ConflictPolicy {
+	if s.db == nil {
+		return nil
+	}
+	var raw sql.NullString
+	if err := s.db.QueryRow(`SELECT conflict_policy FROM sync_jobs WHERE id = $1`, jobID).Scan(&raw); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load conflict policy for job %s: %v", jobID, err)
+		}
+		return nil
+	}
+	return scanConflictPolicy(raw)
+}
+
+// handleConflictAutoResolved records a decision an agent took on a conflict copy following the
+// conflict policy of the job, in job_conflicts and in file_transfer_logs
+func (s *SyncToolServer) handleConflictAutoResolved(agentID string, decision *protocol.ConflictAutoResolved) {
+	jobID := decision.JobID
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return
+	}
+	path := decision.Path
+	originalPath := decision.OriginalPath
+	strategy := decision.Strategy
+	resolution := decision.Resolution
+	reason := decision.Reason
+	errorMsg := decision.Error
+	size := decision.Size
+
+	decidedAt := decision.DecidedAt
+	if decidedAt.IsZero() {
+		decidedAt = time.Now()
+	}
+
+	status := "completed"
+	if errorMsg != "" {
+		status = "failed"
+		log.Printf("❌ Conflict policy %s of job %s failed on agent %s for %s: %s", strategy, jobID, agentID, path, errorMsg)
+	} else {
+		log.Printf("⚔️ Conflict policy %s of job %s on agent %s kept %s for %s (%s)", strategy, jobID, agentID, resolution, originalPath, reason)
+
+		switch resolution {
+		case conflictKeepLocal, conflictKeepRemote, conflictKeepBoth:
+			if _, err := s.db.Exec(`
+				UPDATE job_conflicts
+				SET status = 'resolved', resolution = $1, resolved_by = $2, resolved_at = $3, updated_at = NOW()
+				WHERE job_id = $4 AND path = $5 AND status = 'open'
+			`, resolution, "policy:"+strategy, decidedAt, id, path); err != nil {
+				log.Printf("❌ Failed to mark conflict %s of job %s as resolved: %v", path, jobID, err)
+			}
+		}
+	}
+
+	var jobName string
+	s.db.QueryRow(`SELECT name FROM sync_jobs WHERE id = $1`, id).Scan(&jobName)
+
+	// file_transfer_logs keys jobs by folder ID; the action names the version that was kept
+	if _, err := s.db.Exec(`
+		INSERT INTO file_transfer_logs (
+			job_id, job_name, agent_id, file_name, file_path, file_size,
+			status, action, progress, error_message, started_at, completed_at, version, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 100, $9, $10, $10, 1, NOW())
+	`, fmt.Sprintf("job-%d", id), jobName, agentID, path, originalPath, size,
+		status, "conflict_keep_"+resolution, errorMsg, decidedAt); err != nil {
+		log.Printf("❌ Failed to log conflict decision of job %s: %v", jobID, err)
+	}
+}
+
+// writeConflictPolicyError writes a 400 response for an invalid conflict policy
+func writeConflictPolicyError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid conflict_policy: %v", err),
+	})
+}
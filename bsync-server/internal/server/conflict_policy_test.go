@@ -0,0 +1,42 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConflictPolicyFromJobData(t *testing.T) {
+	tests := []struct {
+		name        string
+		jobData     map[string]interface{}
+		want        *ConflictPolicy
+		wantPresent bool
+		wantErr     bool
+	}{
+		{name: "missing", jobData: map[string]interface{}{}},
+		{name: "null resolves manually", jobData: map[string]interface{}{"conflict_policy": nil}, wantPresent: true},
+		{name: "newest", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"strategy": "newest"}}, want: &ConflictPolicy{Strategy: conflictStrategyNewest}, wantPresent: true},
+		{name: "strategy is normalized", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"strategy": " Source_Wins ", "max_conflicts": float64(3)}}, want: &ConflictPolicy{Strategy: conflictStrategySourceWins, MaxConflicts: 3}, wantPresent: true},
+		{name: "keep both without limit", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"strategy": "keep_both", "max_conflicts": float64(0)}}, want: &ConflictPolicy{Strategy: conflictStrategyKeepBoth}, wantPresent: true},
+		{name: "unknown strategy", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"strategy": "oldest"}}, wantPresent: true, wantErr: true},
+		{name: "missing strategy", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"max_conflicts": float64(3)}}, wantPresent: true, wantErr: true},
+		{name: "negative max_conflicts", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"strategy": "largest", "max_conflicts": float64(-1)}}, wantPresent: true, wantErr: true},
+		{name: "too many conflicts kept", jobData: map[string]interface{}{"conflict_policy": map[string]interface{}{"strategy": "largest", "max_conflicts": float64(maxConflictsLimit + 1)}}, wantPresent: true, wantErr: true},
+		{name: "not an object", jobData: map[string]interface{}{"conflict_policy": "newest"}, wantPresent: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, present, err := conflictPolicyFromJobData(tt.jobData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if present != tt.wantPresent {
+				t.Errorf("present = %v, want %v", present, tt.wantPresent)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conflict_policy = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// Conflict states stored in job_conflicts.status
+const (
+	conflictOpen     = "open"     // the conflict copy is in the job folder
+	conflictResolved = "resolved" // resolved through the API
+	conflictRemoved  = "removed"  // the conflict copy was deleted outside the API
+)
+
+// Resolutions of a conflict, executed by the agent that reported it. The conflict copy holds the
+// version of that agent, the file at the original path the version received from another device.
+const (
+	conflictKeepLocal  = "local"  // the conflict copy replaces the file
+	conflictKeepRemote = "remote" // the conflict copy is deleted
+	conflictKeepBoth   = "both"   // the conflict copy is renamed to a regular file
+)
+
+// maxConflictsPage is the largest page of conflicts returned at once
+const maxConflictsPage = 500
+
+// JobConflict is a conflict copy found in a job folder
+type JobConflict struct {
+	ID           int        `json:"id"`
+	JobID        int        `json:"job_id"`
+	AgentID      string     `json:"agent_id"`
+	Path         string     `json:"path"`
+	OriginalPath string     `json:"original_path"`
+	ModifiedBy   *string    `json:"modified_by"`
+	ConflictTime *time.Time `json:"conflict_time"`
+	Size         int64      `json:"size"`
+	Status       string     `json:"status"`
+	Resolution   *string    `json:"resolution"`
+	ResolvedBy   *string    `json:"resolved_by"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	DetectedAt   time.Time  `json:"detected_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// handleConflictEvent records the conflict_detected and conflict_removed events of an agent
+func (s *SyncToolServer) handleConflictEvent(agentID, eventType string, conflict *protocol.ConflictEvent) {
+	if s.db == nil {
+		return
+	}
+	folderID := conflict.FolderID
+	path := conflict.Path
+	jobID, err := strconv.Atoi(strings.TrimPrefix(folderID, "job-"))
+	if err != nil || !strings.HasPrefix(folderID, "job-") || path == "" {
+		return
+	}
+
+	if eventType == protocol.EventConflictRemoved {
+		result, err := s.db.Exec(`
+			UPDATE job_conflicts SET status = 'removed', updated_at = NOW()
+			WHERE job_id = $1 AND path = $2 AND status = 'open'
+		`, jobID, path)
+		if err != nil {
+			log.Printf("❌ Failed to mark conflict %s of job %d as removed: %v", path, jobID, err)
+		} else if removed, _ := result.RowsAffected(); removed > 0 {
+			log.Printf("🧹 Conflict copy %s of job %d was removed on agent %s", path, jobID, agentID)
+		}
+		return
+	}
+
+	originalPath := conflict.OriginalPath
+	modifiedBy := conflict.ModifiedBy
+	var conflictTime *time.Time
+	if !conflict.ConflictTime.IsZero() {
+		conflictTime = &conflict.ConflictTime
+	}
+
+	// A copy reported again (modified, or restored after a removal) is open again
+	var conflictID int
+	var inserted bool
+	err = s.db.QueryRow(`
+		INSERT INTO job_conflicts (job_id, agent_id, path, original_path, modified_by, conflict_time, size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (job_id, path) DO UPDATE SET
+			agent_id = EXCLUDED.agent_id, size = EXCLUDED.size, status = 'open',
+			resolution = NULL, resolved_by = NULL, resolved_at = NULL, updated_at = NOW()
+		RETURNING id, (xmax = 0)
+	`, jobID, agentID, path, originalPath, nullIfEmpty(modifiedBy), conflictTime, conflict.Size).Scan(&conflictID, &inserted)
+	if err != nil {
+		log.Printf("❌ Failed to record conflict %s of job %d: %v", path, jobID, err)
+		return
+	}
+	if !inserted {
+		return
+	}
+
+	log.Printf("⚔️ Conflict in job %d on agent %s: %s (copy of %s)", jobID, agentID, path, originalPath)
+
+	go s.emitWebhookEvent(WebhookEventConflictDetected, map[string]interface{}{
+		"job_id":        jobID,
+		"agent_id":      agentID,
+		"conflict_id":   conflictID,
+		"path":          path,
+		"original_path": originalPath,
+		"modified_by":   modifiedBy,
+	})
+}
+
+// loadJobConflicts returns a page of the conflicts of a job, newest first, and their count.
+// status "all" returns conflicts in any state.
+func (s *SyncToolServer) loadJobConflicts(jobID, conflictID, status, agentID string, limit, offset int) ([]JobConflict, int, error) {
+	where := `WHERE job_id = $1`
+	args := []interface{}{jobID}
+	if conflictID != "" {
+		args = append(args, conflictID)
+		where += fmt.Sprintf(` AND id = $%d`, len(args))
+	}
+	if status != "" && status != "all" {
+		args = append(args, status)
+		where += fmt.Sprintf(` AND status = $%d`, len(args))
+	}
+	if agentID != "" {
+		args = append(args, agentID)
+		where += fmt.Sprintf(` AND agent_id = $%d`, len(args))
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM job_conflicts `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT id, job_id, agent_id, path, original_path, modified_by, conflict_time, size, status,
+		       resolution, resolved_by, resolved_at, detected_at, updated_at
+		FROM job_conflicts
+		%s
+		ORDER BY detected_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	conflicts := []JobConflict{}
+	for rows.Next() {
+		var c JobConflict
+		if err := rows.Scan(&c.ID, &c.JobID, &c.AgentID, &c.Path, &c.OriginalPath, &c.ModifiedBy, &c.ConflictTime, &c.Size,
+			&c.Status, &c.Resolution, &c.ResolvedBy, &c.ResolvedAt, &c.DetectedAt, &c.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, total, rows.Err()
+}
+
+// openConflictCount returns the number of unresolved conflicts of a job
+func (s *SyncToolServer) openConflictCount(jobID string) int {
+	if s.db == nil {
+		return 0
+	}
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM job_conflicts WHERE job_id = $1 AND status = 'open'`, jobID).Scan(&count); err != nil {
+		log.Printf("⚠️ Failed to count conflicts of job %s: %v", jobID, err)
+	}
+	return count
+}
+
+// handleJobConflicts handles the conflict copies found in a job's folders:
+//   GET  /api/v1/sync-jobs/{id}/conflicts?status=open|resolved|removed|all&agent_id=...&limit=...&offset=...
+//   GET  /api/v1/sync-jobs/{id}/conflicts/{conflict_id}
+//   POST /api/v1/sync-jobs/{id}/conflicts/{conflict_id}/resolve  {"resolution": "local|remote|both"}
+func (s *SyncToolServer) handleJobConflicts(w http.ResponseWriter, r *http.Request, jobID string, subPath []string) {
+	if len(subPath) >= 1 {
+		if _, err := strconv.Atoi(subPath[0]); err != nil {
+			http.Error(w, `{"error": "Invalid conflict ID"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	query := r.URL.Query()
+
+	switch {
+	case len(subPath) == 0 && r.Method == "GET":
+		status := query.Get("status")
+		switch status {
+		case "":
+			status = conflictOpen
+		case conflictOpen, conflictResolved, conflictRemoved, "all":
+		default:
+			http.Error(w, `{"error": "status must be open, resolved, removed or all"}`, http.StatusBadRequest)
+			return
+		}
+		limit := 100
+		if value, err := strconv.Atoi(query.Get("limit")); err == nil && value > 0 && value <= maxConflictsPage {
+			limit = value
+		}
+		offset := 0
+		if value, err := strconv.Atoi(query.Get("offset")); err == nil && value > 0 {
+			offset = value
+		}
+
+		var exists bool
+		if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sync_jobs WHERE id = $1)`, jobID).Scan(&exists); err != nil || !exists {
+			http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+			return
+		}
+
+		conflicts, total, err := s.loadJobConflicts(jobID, "", status, query.Get("agent_id"), limit, offset)
+		if err != nil {
+			log.Printf("❌ Failed to query conflicts of job %s: %v", jobID, err)
+			http.Error(w, `{"error": "Failed to fetch conflicts"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":         jobID,
+			"open_conflicts": s.openConflictCount(jobID),
+			"data":           conflicts,
+			"total":          total,
+			"limit":          limit,
+			"offset":         offset,
+		})
+
+	case len(subPath) == 1 && r.Method == "GET":
+		conflicts, _, err := s.loadJobConflicts(jobID, subPath[0], "all", "", 1, 0)
+		if err != nil {
+			log.Printf("❌ Failed to query conflict %s of job %s: %v", subPath[0], jobID, err)
+			http.Error(w, `{"error": "Failed to fetch conflict"}`, http.StatusInternalServerError)
+			return
+		}
+		if len(conflicts) == 0 {
+			http.Error(w, `{"error": "Conflict not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(conflicts[0])
+
+	case len(subPath) == 2 && subPath[1] == "resolve" && r.Method == "POST":
+		var req struct {
+			Resolution string `json:"resolution"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+			return
+		}
+		switch req.Resolution {
+		case conflictKeepLocal, conflictKeepRemote, conflictKeepBoth:
+		default:
+			http.Error(w, `{"error": "resolution must be local, remote or both"}`, http.StatusBadRequest)
+			return
+		}
+		s.resolveJobConflict(w, r, jobID, subPath[0], req.Resolution)
+
+	default:
+		http.Error(w, `{"error": "Invalid conflicts request. Expected: GET /conflicts, GET /conflicts/{conflict_id} or POST /conflicts/{conflict_id}/resolve"}`, http.StatusBadRequest)
+	}
+}
+
+// resolveJobConflict has the agent that reported a conflict apply the resolution to its folder;
+// the change then syncs to the other devices of the job
+func (s *SyncToolServer) resolveJobConflict(w http.ResponseWriter, r *http.Request, jobID, conflictID, resolution string) {
+	conflicts, _, err := s.loadJobConflicts(jobID, conflictID, "all", "", 1, 0)
+	if err != nil {
+		log.Printf("❌ Failed to query conflict %s of job %s: %v", conflictID, jobID, err)
+		http.Error(w, `{"error": "Failed to fetch conflict"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(conflicts) == 0 {
+		http.Error(w, `{"error": "Conflict not found"}`, http.StatusNotFound)
+		return
+	}
+	conflict := conflicts[0]
+	if conflict.Status != conflictOpen {
+		http.Error(w, fmt.Sprintf(`{"error": "Conflict is already %s"}`, conflict.Status), http.StatusConflict)
+		return
+	}
+
+	var response protocol.ConflictResolveResponse
+	if err := s.requestMessageFromAgent(conflict.AgentID, &protocol.ResolveConflict{
+		Type:         protocol.TypeResolveConflict,
+		JobID:        jobID,
+		Path:         conflict.Path,
+		OriginalPath: conflict.OriginalPath,
+		Resolution:   resolution,
+	}, &response, agentRequestTimeout); err != nil {
+		log.Printf("❌ Failed to resolve conflict %s of job %s on agent %s: %v", conflict.Path, jobID, conflict.AgentID, err)
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to resolve conflict: %v"}`, err), http.StatusBadGateway)
+		return
+	}
+
+	resolvedBy := requestUsername(r)
+	if err := s.db.QueryRow(`
+		UPDATE job_conflicts
+		SET status = 'resolved', resolution = $1, resolved_by = $2, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $3
+		RETURNING resolved_at, updated_at
+	`, resolution, nullIfEmpty(resolvedBy), conflict.ID).Scan(&conflict.ResolvedAt, &conflict.UpdatedAt); err != nil && err != sql.ErrNoRows {
+		log.Printf("❌ Failed to mark conflict %d of job %s as resolved: %v", conflict.ID, jobID, err)
+	}
+	conflict.Status = conflictResolved
+	conflict.Resolution = &resolution
+	if resolvedBy != "" {
+		conflict.ResolvedBy = &resolvedBy
+	}
+
+	log.Printf("✅ Conflict %s of job %s resolved on agent %s: kept %s", conflict.Path, jobID, conflict.AgentID, resolution)
+
+	result := map[string]interface{}{
+		"success":  true,
+		"message":  fmt.Sprintf("Conflict resolved, kept %s", resolution),
+		"conflict": conflict,
+	}
+	if response.RenamedTo != "" {
+		result["renamed_to"] = response.RenamedTo
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"bsync-server/pkg/protocol"
+)
+
+func TestHandleConflictEvent(t *testing.T) {
+	copyPath := "report.sync-conflict-20300308-120000-ABCDEFG.txt"
+
+	tests := []struct {
+		name       string
+		eventType  string
+		conflict   protocol.ConflictEvent
+		statements []*fakeStatement
+	}{
+		{
+			name:      "folder of another application",
+			eventType: protocol.EventConflictDetected,
+			conflict:  protocol.ConflictEvent{FolderID: "default", Path: copyPath},
+		},
+		{
+			name:      "missing path",
+			eventType: protocol.EventConflictDetected,
+			conflict:  protocol.ConflictEvent{FolderID: "job-3"},
+		},
+		{
+			name:      "copy removed",
+			eventType: protocol.EventConflictRemoved,
+			conflict:  protocol.ConflictEvent{FolderID: "job-3", Path: copyPath},
+			statements: []*fakeStatement{
+				{query: "SET status = 'removed'", args: []driver.Value{3, copyPath}, affected: 1},
+			},
+		},
+		{
+			name:      "copy reported again is reopened without a new notification",
+			eventType: protocol.EventConflictDetected,
+			conflict:  protocol.ConflictEvent{FolderID: "job-3", Path: copyPath, OriginalPath: "report.txt", Size: 42},
+			statements: []*fakeStatement{
+				{query: "ON CONFLICT (job_id, path) DO UPDATE", columns: []string{"id", "inserted"}, rows: [][]driver.Value{{int64(7), false}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, tt.statements...)
+			s := &SyncToolServer{db: db}
+
+			conflict := tt.conflict
+			s.handleConflictEvent("agent-1", tt.eventType, &conflict)
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// pauseReasonDeletionGuard tags the pause of the destinations after a large deletion on the source
+// and the resume that confirms the deletions (agents only release their hold on this reason)
+const pauseReasonDeletionGuard = "deletion_guard"
+
+// Deletion hold states stored in deletion_guard_holds.status
+const (
+	deletionHoldPending   = "pending"
+	deletionHoldConfirmed = "confirmed"
+)
+
+const deletionHoldHistoryLimit = 20
+
+// DeletionGuardPolicy pauses a job when a scan on the source deletes more than MaxFiles files or
+// MaxPercent of the folder, until an administrator confirms the deletions. Destinations keep deleted
+// files in quarantine (trashcan versioning in .stversions) for QuarantineDays unless the job has its
+// own versioning policy.
+type DeletionGuardPolicy struct {
+	MaxFiles       int64   `json:"max_files"`       // 0 = no file count threshold
+	MaxPercent     float64 `json:"max_percent"`     // 0 = no percentage threshold
+	QuarantineDays int     `json:"quarantine_days"` // purge quarantined files after N days (0 = never)
+}
+
+// validate checks the thresholds and the quarantine retention
+func (p *DeletionGuardPolicy) validate() error {
+	if p.MaxFiles < 0 || p.QuarantineDays < 0 {
+		return fmt.Errorf("max_files and quarantine_days must not be negative")
+	}
+	if p.MaxPercent < 0 || p.MaxPercent > 100 {
+		return fmt.Errorf("max_percent must be between 0 and 100")
+	}
+	if p.MaxFiles == 0 && p.MaxPercent == 0 {
+		return fmt.Errorf("max_files or max_percent is required (send null to turn the guard off)")
+	}
+	return nil
+}
+
+// DeletionHold is a job paused by the deletion guard
+type DeletionHold struct {
+	ID           int        `json:"id"`
+	JobID        int        `json:"job_id"`
+	AgentID      string     `json:"agent_id"`
+	DeletedFiles int64      `json:"deleted_files"`
+	TotalFiles   int64      `json:"total_files"`
+	SamplePaths  []string   `json:"sample_paths"`
+	Status       string     `json:"status"`
+	DetectedAt   time.Time  `json:"detected_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	ConfirmedBy  *string    `json:"confirmed_by,omitempty"`
+}
+
+// deletionGuardFromJobData reads "deletion_guard" from a create/update request body.
+// present is false if the field was not sent at all; null turns the guard off.
+func deletionGuardFromJobData(jobData map[string]interface{}) (policy *DeletionGuardPolicy, present bool, err error) {
+	raw, present := jobData["deletion_guard"]
+	if !present || raw == nil {
+		return nil, present, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, true, err
+	}
+	policy = &DeletionGuardPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, true, err
+	}
+	if err := policy.validate(); err != nil {
+		return nil, true, err
+	}
+	return policy, true, nil
+}
+
+// deletionGuardPolicyJSON converts a policy into a value for a JSONB column
+func deletionGuardPolicyJSON(policy *DeletionGuardPolicy) interface{} {
+	if policy == nil {
+		return nil
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// scanDeletionGuardPolicy decodes a nullable JSONB column
+func scanDeletionGuardPolicy(raw sql.NullString) *DeletionGuardPolicy {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var policy DeletionGuardPolicy
+	if err := json.Unmarshal([]byte(raw.String), &policy); err != nil {
+		log.Printf("⚠️ Ignoring invalid stored deletion guard: %v", err)
+		return nil
+	}
+	return &policy
+}
+
+// jobDeletionGuard returns the deletion guard of a job, nil if the guard is off
+func (s *SyncToolServer) jobDeletionGuard(jobID string) *DeletionGuardPolicy {
+	if s.db == nil {
+		return nil
+	}
+	var raw sql.NullString
+	if err := s.db.QueryRow(`SELECT deletion_guard FROM sync_jobs WHERE id = $1`, jobID).Scan(&raw); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load deletion guard for job %s: %v", jobID, err)
+		}
+		return nil
+	}
+	return scanDeletionGuardPolicy(raw)
+}
+
+// jobDestinationVersioning returns the versioning policy sent to the destinations of a job: its own
+// policy, or a trashcan acting as quarantine for jobs with a deletion guard and no versioning.
+// Quarantined files are listed and restored with the versions API.
+func (s *SyncToolServer) jobDestinationVersioning(jobID string) *VersioningPolicy {
+	if versioning := s.jobVersioningPolicy(jobID); versioning != nil {
+		return versioning
+	}
+	if guard := s.jobDeletionGuard(jobID); guard != nil {
+		return &VersioningPolicy{Type: VersioningTrashcan, CleanoutDays: guard.QuarantineDays}
+	}
+	return nil
+}
+
+// handleDeletionGuardTriggered records a job the source agent paused after a large deletion and
+// pauses the other agents of the job, so destinations keep their files until the deletions are confirmed
+func (s *SyncToolServer) handleDeletionGuardTriggered(agentID string, trigger *protocol.DeletionGuardTriggered) {
+	jobID := trigger.JobID
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return
+	}
+	deletedFiles := trigger.DeletedFiles
+	totalFiles := trigger.TotalFiles
+
+	samplePaths := trigger.SamplePaths
+	if samplePaths == nil {
+		samplePaths = []string{}
+	}
+	samples, _ := json.Marshal(samplePaths)
+
+	detectedAt := trigger.Since
+	if detectedAt.IsZero() {
+		detectedAt = time.Now()
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO deletion_guard_holds (job_id, agent_id, deleted_files, total_files, sample_paths, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (job_id) WHERE status = 'pending' DO NOTHING
+	`, id, agentID, deletedFiles, totalFiles, string(samples), detectedAt)
+	if err != nil {
+		log.Printf("❌ Failed to record deletion guard hold of job %s: %v", jobID, err)
+		return
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		log.Printf("🛡️ Job %s is already waiting for a deletion confirmation", jobID)
+		return
+	}
+
+	if _, err := s.db.Exec(`UPDATE sync_jobs SET status = 'paused', updated_at = $1 WHERE id = $2`, time.Now(), id); err != nil {
+		log.Printf("❌ Failed to mark job %s as paused: %v", jobID, err)
+	}
+
+	log.Printf("🛡️ Job %s paused by the deletion guard: %d of %d files deleted on %s", jobID, deletedFiles, totalFiles, agentID)
+
+	// The source paused itself; destinations that are offline stay unpaused, but receive no
+	// further changes from the paused source
+	agentIDs, err := s.jobAgentIDs(jobID)
+	if err != nil {
+		log.Printf("❌ Failed to pause destinations of job %s: %v", jobID, err)
+	}
+	pauseConfig := protocol.ToMap(protocol.NewJobControl(protocol.TypePauseJob, jobID, pauseReasonDeletionGuard))
+	for _, destAgentID := range agentIDs {
+		if destAgentID == agentID {
+			continue
+		}
+		if err := s.sendJobToAgent(destAgentID, pauseConfig); err != nil {
+			log.Printf("⚠️ Failed to pause job %s on %s after a large deletion: %v", jobID, destAgentID, err)
+		}
+	}
+
+	var jobName string
+	s.db.QueryRow(`SELECT name FROM sync_jobs WHERE id = $1`, id).Scan(&jobName)
+
+	go s.emitWebhookEvent(WebhookEventDeletionGuard, map[string]interface{}{
+		"job_id":        jobID,
+		"job_name":      jobName,
+		"agent_id":      agentID,
+		"deleted_files": deletedFiles,
+		"total_files":   totalFiles,
+		"sample_paths":  samplePaths,
+		"confirm":       fmt.Sprintf("POST /api/v1/sync-jobs/%s/deletion-guard/confirm", jobID),
+	})
+}
+
+// loadDeletionHolds returns the holds of a job, newest first
+func (s *SyncToolServer) loadDeletionHolds(jobID string, pendingOnly bool, limit int) ([]*DeletionHold, error) {
+	query := `
+		SELECT id, job_id, agent_id, deleted_files, total_files, sample_paths, status, detected_at, confirmed_at, confirmed_by
+		FROM deletion_guard_holds
+		WHERE job_id = $1`
+	if pendingOnly {
+		query += ` AND status = 'pending'`
+	}
+	query += ` ORDER BY detected_at DESC LIMIT $2`
+
+	rows, err := s.db.Query(query, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []*DeletionHold{}
+	for rows.Next() {
+		var hold DeletionHold
+		var samples []byte
+		if err := rows.Scan(&hold.ID, &hold.JobID, &hold.AgentID, &hold.DeletedFiles, &hold.TotalFiles, &samples,
+			&hold.Status, &hold.DetectedAt, &hold.ConfirmedAt, &hold.ConfirmedBy); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(samples, &hold.SamplePaths); err != nil || hold.SamplePaths == nil {
+			hold.SamplePaths = []string{}
+		}
+		holds = append(holds, &hold)
+	}
+	return holds, rows.Err()
+}
+
+// pendingDeletionHold returns the hold waiting for a confirmation, nil if the job is not held
+func (s *SyncToolServer) pendingDeletionHold(jobID string) *DeletionHold {
+	if s.db == nil {
+		return nil
+	}
+	holds, err := s.loadDeletionHolds(jobID, true, 1)
+	if err != nil {
+		log.Printf("⚠️ Failed to load deletion guard hold of job %s: %v", jobID, err)
+		return nil
+	}
+	if len(holds) == 0 {
+		return nil
+	}
+	return holds[0]
+}
+
+// handleJobDeletionGuard handles the deletion guard of a job:
+//   GET  /api/v1/sync-jobs/{id}/deletion-guard          policy, quarantine, pending hold and recent holds
+//   POST /api/v1/sync-jobs/{id}/deletion-guard/confirm  let the deletions propagate and resume the job (admin only,
+//        ?force=true also releases holds the server has no record of)
+func (s *SyncToolServer) handleJobDeletionGuard(w http.ResponseWriter, r *http.Request, jobID string, subPath []string) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sync_jobs WHERE id = $1)`, jobID).Scan(&exists); err != nil || !exists {
+		http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(subPath) == 0 && r.Method == "GET":
+		holds, err := s.loadDeletionHolds(jobID, false, deletionHoldHistoryLimit)
+		if err != nil {
+			log.Printf("❌ Failed to query deletion guard holds of job %s: %v", jobID, err)
+			http.Error(w, `{"error": "Failed to fetch deletion guard holds"}`, http.StatusInternalServerError)
+			return
+		}
+		var pending *DeletionHold
+		if len(holds) > 0 && holds[0].Status == deletionHoldPending {
+			pending = holds[0]
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":         jobID,
+			"deletion_guard": s.jobDeletionGuard(jobID),
+			"quarantine":     s.jobDestinationVersioning(jobID),
+			"pending":        pending,
+			"data":           holds,
+			"total":          len(holds),
+		})
+
+	case len(subPath) == 1 && subPath[0] == "confirm" && r.Method == "POST":
+		// Confirming lets the held deletions reach every destination
+		if !s.callerIsAdmin(r) {
+			s.writeJSONError(w, http.StatusForbidden, "Access denied: Admin only")
+			return
+		}
+
+		// force also releases holds the server has no record of, e.g. when the report of the source was lost
+		hold := s.pendingDeletionHold(jobID)
+		if hold == nil && r.URL.Query().Get("force") != "true" {
+			http.Error(w, `{"error": "No deletions are waiting for confirmation (use ?force=true to resume a job held by its source anyway)"}`, http.StatusConflict)
+			return
+		}
+
+		if err := s.resumeJobOnAgentsWithReason(jobID, pauseReasonDeletionGuard); err != nil {
+			log.Printf("❌ Failed to resume job %s after deletion confirmation: %v", jobID, err)
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to resume job on agents: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		username := requestUsername(r)
+		if _, err := s.db.Exec(`
+			UPDATE deletion_guard_holds SET status = $2, confirmed_at = $3, confirmed_by = $4
+			WHERE job_id = $1 AND status = 'pending'
+		`, jobID, deletionHoldConfirmed, now, nullIfEmpty(username)); err != nil {
+			log.Printf("❌ Failed to confirm deletion guard hold of job %s: %v", jobID, err)
+			http.Error(w, `{"error": "Failed to confirm deletions"}`, http.StatusInternalServerError)
+			return
+		}
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET status = 'active', window_paused = false, updated_at = $1 WHERE id = $2`, now, jobID); err != nil {
+			log.Printf("❌ Failed to update sync job status in database: %v", err)
+		}
+
+		message := "No deletions were waiting for confirmation, job resumed"
+		if hold != nil {
+			hold.Status = deletionHoldConfirmed
+			hold.ConfirmedAt = &now
+			if username != "" {
+				hold.ConfirmedBy = &username
+			}
+			message = fmt.Sprintf("Deletion of %d file(s) confirmed, job resumed", hold.DeletedFiles)
+		}
+		log.Printf("🛡️ Job %s: %s (by %s)", jobID, message, username)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": message,
+			"hold":    hold,
+		})
+
+	default:
+		http.Error(w, `{"error": "Invalid deletion guard request. Expected: GET /deletion-guard or POST /deletion-guard/confirm"}`, http.StatusBadRequest)
+	}
+}
+
+// writeDeletionGuardError writes a 400 response for an invalid deletion guard policy
+func writeDeletionGuardError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid deletion guard: %v", err),
+	})
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bsync-server/internal/models"
+)
+
+func TestJobDeletionGuardConfirm(t *testing.T) {
+	jobExists := &fakeStatement{query: "SELECT EXISTS", args: []driver.Value{"1"}, columns: []string{"exists"}, rows: [][]driver.Value{{true}}}
+	noPendingHold := &fakeStatement{query: "AND status = 'pending'", args: []driver.Value{"1", 1}}
+
+	tests := []struct {
+		name       string
+		role       string
+		statements []*fakeStatement
+		wantStatus int
+	}{
+		{name: "operator", role: models.RoleOperator, statements: []*fakeStatement{jobExists}, wantStatus: http.StatusForbidden},
+		{name: "admin without a pending hold", role: models.RoleAdmin, statements: []*fakeStatement{jobExists, noPendingHold}, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, tt.statements...)
+			s := &SyncToolServer{db: db}
+
+			r := httptest.NewRequest("POST", "/api/v1/sync-jobs/1/deletion-guard/confirm", nil)
+			r = r.WithContext(context.WithValue(r.Context(), "user_claims", &models.JWTClaims{Role: tt.role}))
+			w := httptest.NewRecorder()
+
+			s.handleJobDeletionGuard(w, r, "1", []string{"confirm"})
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if fake.ran("UPDATE") {
+				t.Error("the job was resumed")
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// DiskGuardPolicy is the minimum free space destination agents keep on the filesystem of a job folder.
+// The larger of both thresholds applies; the folder is paused while free space is below it.
+// Both 0 disables the guard for the job.
+type DiskGuardPolicy struct {
+	MinFreeMB      int64   `json:"min_free_mb"`
+	MinFreePercent float64 `json:"min_free_percent"`
+}
+
+// validate checks the thresholds
+func (p *DiskGuardPolicy) validate() error {
+	if p.MinFreeMB < 0 {
+		return fmt.Errorf("min_free_mb must not be negative")
+	}
+	if p.MinFreePercent < 0 || p.MinFreePercent >= 100 {
+		return fmt.Errorf("min_free_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// disabled reports whether the policy turns the guard off
+func (p *DiskGuardPolicy) disabled() bool {
+	return p.MinFreeMB == 0 && p.MinFreePercent == 0
+}
+
+// thresholdBytes returns the minimum free space in bytes on a filesystem of the given size
+func (p *DiskGuardPolicy) thresholdBytes(totalBytes int64) int64 {
+	threshold := p.MinFreeMB * 1024 * 1024
+	if byPercent := int64(float64(totalBytes) * p.MinFreePercent / 100); byPercent > threshold {
+		threshold = byPercent
+	}
+	return threshold
+}
+
+// diskGuardFromJobData reads "disk_guard" from a create/update request body.
+// present is false if the field was not sent at all; null means the server defaults.
+func diskGuardFromJobData(jobData map[string]interface{}) (policy *DiskGuardPolicy, present bool, err error) {
+	raw, present := jobData["disk_guard"]
+	if !present || raw == nil {
+		return nil, present, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, true, err
+	}
+	policy = &DiskGuardPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, true, err
+	}
+	if err := policy.validate(); err != nil {
+		return nil, true, err
+	}
+	return policy, true, nil
+}
+
+// diskGuardPolicyJSON converts a policy into a value for a JSONB column
+func diskGuardPolicyJSON(policy *DiskGuardPolicy) interface{} {
+	if policy == nil {
+		return nil
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// scanDiskGuardPolicy decodes a nullable JSONB column
+func scanDiskGuardPolicy(raw sql.NullString) *DiskGuardPolicy {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var policy DiskGuardPolicy
+	if err := json.Unmarshal([]byte(raw.String), &policy); err != nil {
+		log.Printf("⚠️ Ignoring invalid stored disk guard policy: %v", err)
+		return nil
+	}
+	return &policy
+}
+
+// effectiveDiskGuard returns the policy sent to destinations: the job's own policy, or the
+// server defaults for jobs without one. nil means the guard is off.
+func (s *SyncToolServer) effectiveDiskGuard(policy *DiskGuardPolicy) *DiskGuardPolicy {
+	if policy == nil {
+		policy = &DiskGuardPolicy{
+			MinFreeMB:      int64(s.config.DiskGuard.MinFreeMB),
+			MinFreePercent: s.config.DiskGuard.MinFreePercent,
+		}
+	}
+	if policy.disabled() {
+		return nil
+	}
+	return policy
+}
+
+// jobDiskGuard returns the effective disk guard of a job, nil if the guard is off
+func (s *SyncToolServer) jobDiskGuard(jobID string) *DiskGuardPolicy {
+	if s.db == nil {
+		return nil
+	}
+	var raw sql.NullString
+	if err := s.db.QueryRow(`SELECT disk_guard FROM sync_jobs WHERE id = $1`, jobID).Scan(&raw); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load disk guard for job %s: %v", jobID, err)
+		}
+		return s.effectiveDiskGuard(nil)
+	}
+	return s.effectiveDiskGuard(scanDiskGuardPolicy(raw))
+}
+
+// destinationCapacity is the pre-deploy capacity check of one destination
+type destinationCapacity struct {
+	AgentID       string `json:"agent_id"`
+	Path          string `json:"path"`
+	TotalBytes    int64  `json:"total_bytes"`
+	FreeBytes     int64  `json:"free_bytes"`
+	RequiredBytes int64  `json:"required_bytes"` // source size minus the data the job folder already holds there
+	MinFreeBytes  int64  `json:"min_free_bytes"` // disk guard threshold, kept free after the transfer
+	Fits          bool   `json:"fits"`
+	Error         string `json:"error,omitempty"` // the destination was not checked
+}
+
+// CapacityCheck compares the size of the source folder with the free space on each destination
+type CapacityCheck struct {
+	SourceBytes    int64                  `json:"source_bytes"`
+	SourceComplete bool                   `json:"source_complete"` // false if measuring timed out, source_bytes is then a lower bound
+	SourceError    string                 `json:"source_error,omitempty"`
+	Destinations   []*destinationCapacity `json:"destinations"`
+}
+
+// checkDeployCapacity asks the source agent for the size of the source folder and each destination
+// agent for the free space at its path. It returns an error if the data does not fit a destination
+// while keeping the disk guard threshold free. Agents that cannot be checked (e.g. older agents
+// that do not answer) are reported in the result but do not block the deployment.
+func (s *SyncToolServer) checkDeployCapacity(jobID, sourceAgentID, sourcePath string, destinations []map[string]interface{}, guard *DiskGuardPolicy) (*CapacityCheck, error) {
+	check := &CapacityCheck{Destinations: make([]*destinationCapacity, len(destinations))}
+
+	var wg sync.WaitGroup
+	var sourceResponse protocol.DiskSpaceResponse
+	var sourceErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sourceErr = s.requestMessageFromAgent(sourceAgentID, &protocol.CheckDiskSpace{
+			Type:        protocol.TypeCheckDiskSpace,
+			JobID:       jobID,
+			Path:        sourcePath,
+			MeasureSize: true,
+		}, &sourceResponse, agentRequestTimeout)
+	}()
+
+	responses := make([]protocol.DiskSpaceResponse, len(destinations))
+	errs := make([]error, len(destinations))
+	for i, dest := range destinations {
+		agentID, _ := dest["agent_id"].(string)
+		path, _ := dest["path"].(string)
+		check.Destinations[i] = &destinationCapacity{AgentID: agentID, Path: path}
+
+		wg.Add(1)
+		go func(i int, agentID, path string) {
+			defer wg.Done()
+			errs[i] = s.requestMessageFromAgent(agentID, &protocol.CheckDiskSpace{
+				Type:  protocol.TypeCheckDiskSpace,
+				JobID: jobID,
+				Path:  path,
+			}, &responses[i], agentRequestTimeout)
+		}(i, agentID, path)
+	}
+	wg.Wait()
+
+	if sourceErr != nil {
+		log.Printf("⚠️ Capacity check skipped for job %s: size of %s on %s unknown: %v", jobID, sourcePath, sourceAgentID, sourceErr)
+		check.SourceError = sourceErr.Error()
+		return check, nil
+	}
+	check.SourceBytes = sourceResponse.SizeBytes
+	check.SourceComplete = sourceResponse.SizeComplete
+
+	var tooSmall []string
+	for i, dest := range check.Destinations {
+		if errs[i] != nil {
+			log.Printf("⚠️ Capacity check skipped for job %s on %s: %v", jobID, dest.AgentID, errs[i])
+			dest.Error = errs[i].Error()
+			continue
+		}
+
+		dest.TotalBytes = responses[i].TotalBytes
+		dest.FreeBytes = responses[i].FreeBytes
+		dest.RequiredBytes = check.SourceBytes - responses[i].FolderBytes
+		if dest.RequiredBytes < 0 {
+			dest.RequiredBytes = 0
+		}
+		if guard != nil {
+			dest.MinFreeBytes = guard.thresholdBytes(dest.TotalBytes)
+		}
+		dest.Fits = dest.FreeBytes-dest.RequiredBytes >= dest.MinFreeBytes
+
+		if !dest.Fits {
+			tooSmall = append(tooSmall, fmt.Sprintf("%s:%s needs %s plus %s kept free, has %s free",
+				dest.AgentID, dest.Path, formatBytes(dest.RequiredBytes), formatBytes(dest.MinFreeBytes), formatBytes(dest.FreeBytes)))
+		}
+	}
+
+	if len(tooSmall) > 0 {
+		return check, fmt.Errorf("not enough disk space on %s", strings.Join(tooSmall, "; "))
+	}
+	return check, nil
+}
+
+// writeCapacityError answers a create/update request refused by the capacity check
+func writeCapacityError(w http.ResponseWriter, check *CapacityCheck, err error) {
+	w.WriteHeader(http.StatusInsufficientStorage)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":          err.Error(),
+		"capacity_check": check,
+		"hint":           `set "skip_capacity_check": true to deploy anyway`,
+	})
+}
+
+// handleDiskSpaceReport records a destination folder paused by the disk guard of its agent
+// (disk_space_low) or resumed (disk_space_recovered). Agents repeat disk_space_low while the
+// folder stays paused; webhooks are only notified when the state changes.
+func (s *SyncToolServer) handleDiskSpaceReport(agentID string, report *protocol.DiskSpaceReport) {
+	jobID := report.JobID
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return
+	}
+	path := report.Path
+	freeBytes := report.FreeBytes
+	totalBytes := report.TotalBytes
+	thresholdBytes := report.ThresholdBytes
+
+	if _, err := s.db.Exec(`
+		UPDATE sync_job_destinations
+		SET disk_free_bytes = $3, disk_total_bytes = $4, disk_min_free_bytes = $5, disk_checked_at = NOW()
+		WHERE job_id = $1 AND destination_agent_id = $2
+	`, id, agentID, freeBytes, totalBytes, thresholdBytes); err != nil {
+		log.Printf("❌ Failed to record disk space of job %s on %s: %v", jobID, agentID, err)
+		return
+	}
+
+	low := report.Type == protocol.TypeDiskSpaceLow
+	var result sql.Result
+	if low {
+		since := time.Now()
+		if report.Since != nil {
+			since = *report.Since
+		}
+		result, err = s.db.Exec(`
+			UPDATE sync_job_destinations SET disk_low_since = $3, updated_at = NOW()
+			WHERE job_id = $1 AND destination_agent_id = $2 AND disk_low_since IS NULL
+		`, id, agentID, since)
+	} else {
+		result, err = s.db.Exec(`
+			UPDATE sync_job_destinations SET disk_low_since = NULL, updated_at = NOW()
+			WHERE job_id = $1 AND destination_agent_id = $2 AND disk_low_since IS NOT NULL
+		`, id, agentID)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to record disk guard state of job %s on %s: %v", jobID, agentID, err)
+		return
+	}
+	if changed, _ := result.RowsAffected(); changed == 0 {
+		return
+	}
+
+	event := WebhookEventDiskSpaceRecovered
+	if low {
+		event = WebhookEventDiskSpaceLow
+		log.Printf("💾 Job %s paused on %s: %s free on %s, minimum is %s",
+			jobID, agentID, formatBytes(freeBytes), path, formatBytes(thresholdBytes))
+	} else {
+		log.Printf("💾 Job %s resumed on %s: %s free on %s", jobID, agentID, formatBytes(freeBytes), path)
+	}
+
+	go s.emitWebhookEvent(event, map[string]interface{}{
+		"job_id":          jobID,
+		"agent_id":        agentID,
+		"path":            path,
+		"free_bytes":      freeBytes,
+		"total_bytes":     totalBytes,
+		"threshold_bytes": thresholdBytes,
+	})
+}
+
+// observeDestinationDiskLow returns job destinations paused by the disk guard of their agent
+func (s *SyncToolServer) observeDestinationDiskLow() (map[string]*alertObservation, error) {
+	rows, err := s.db.Query(`
+		SELECT d.job_id, j.name, d.destination_agent_id, d.destination_path, d.disk_low_since,
+			COALESCE(d.disk_free_bytes, 0), COALESCE(d.disk_total_bytes, 0), COALESCE(d.disk_min_free_bytes, 0)
+		FROM sync_job_destinations d
+		JOIN sync_jobs j ON j.id = d.job_id
+		WHERE d.disk_low_since IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	observations := map[string]*alertObservation{}
+	for rows.Next() {
+		var jobID int
+		var jobName, agentID, path string
+		var since time.Time
+		var freeBytes, totalBytes, minFreeBytes int64
+		if err := rows.Scan(&jobID, &jobName, &agentID, &path, &since, &freeBytes, &totalBytes, &minFreeBytes); err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s:job-%d", agentID, jobID)
+		observations[key] = &alertObservation{
+			Key:     key,
+			Subject: fmt.Sprintf("%s → %s", jobName, agentID),
+			Value:   float64(freeBytes) / (1024 * 1024),
+			Since:   &since,
+			Details: map[string]interface{}{
+				"job_id":               jobID,
+				"job_name":             jobName,
+				"destination_agent_id": agentID,
+				"destination_path":     path,
+				"free":                 formatBytes(freeBytes),
+				"total":                formatBytes(totalBytes),
+				"minimum_free":         formatBytes(minFreeBytes),
+			},
+		}
+	}
+	return observations, rows.Err()
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. 1.5 GiB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// diskPausedAgents returns the destination agents keeping the job paused for low disk space
+func (s *SyncToolServer) diskPausedAgents(jobID string) []string {
+	agentIDs := []string{}
+	rows, err := s.db.Query(`
+		SELECT destination_agent_id FROM sync_job_destinations
+		WHERE job_id = $1 AND disk_low_since IS NOT NULL
+		ORDER BY destination_agent_id
+	`, jobID)
+	if err != nil {
+		log.Printf("⚠️ Failed to load disk guard state of job %s: %v", jobID, err)
+		return agentIDs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var agentID string
+		if err := rows.Scan(&agentID); err == nil {
+			agentIDs = append(agentIDs, agentID)
+		}
+	}
+	return agentIDs
+}
+
+// writeDiskGuardError writes a 400 response for an invalid disk guard policy
+func writeDiskGuardError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid disk guard: %v", err),
+	})
+}
@@ -11,6 +11,9 @@ import (
 
 // EventStore interface for different storage implementations
 type EventStore interface {
+	// Store saves an event. MemoryEventStore sets event.ID; stores writing in the background
+	// (PostgresEventStore) leave it 0, the ID is assigned when the event is written and returned
+	// by the Get methods.
 	Store(event *Event) error
 	GetEvents(agentID string, since int64, limit int) ([]*Event, error)
 	GetEventsByType(agentID string, eventType string, limit int) ([]*Event, error)
@@ -26,7 +29,7 @@ type Event struct {
 	Type      string          `json:"type"`
 	Timestamp time.Time       `json:"timestamp"`
 	Data      json.RawMessage `json:"data"`
-	Processed bool            `json:"processed"`
+	Processed bool            `json:"processed"` // false when persisting the event's data failed
 	CreatedAt time.Time       `json:"created_at"`
 }
 
@@ -58,6 +61,26 @@ func NewMemoryEventStore(bufferSize int) *MemoryEventStore {
 	}
 }
 
+// newEventStore creates the event store selected by configuration.
+// Falls back to the memory store if Postgres is unavailable.
+func newEventStore(cfg EventStoreConfig, db *sql.DB) EventStore {
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+
+	if cfg.Type == "postgres" {
+		store, err := NewPostgresEventStore(db, cfg)
+		if err == nil {
+			return store
+		}
+		log.Printf("⚠️ Postgres event store unavailable, falling back to memory store: %v", err)
+	}
+
+	log.Printf("✅ Memory event store initialized (buffer=%d)", bufferSize)
+	return NewMemoryEventStore(bufferSize)
+}
+
 // Store adds an event to the circular buffer
 func (m *MemoryEventStore) Store(event *Event) error {
 	m.mutex.Lock()
@@ -243,24 +266,23 @@ func (p *EventProcessor) ProcessEvent(agentID string, eventData []byte) error {
 		Type:      eventType,
 		Timestamp: timestamp,
 		Data:      eventData,
-		Processed: false,
+		Processed: true,
 	}
 
-	// Store in memory
-	if err := p.store.Store(event); err != nil {
-		return err
-	}
-
-	// Also persist specific events to database if database is available
+	// Persist specific events to database tables first, so the stored event records whether
+	// that succeeded (stores may keep a copy of the event)
 	if p.db != nil {
 		if err := p.persistEventToDatabase(agentID, eventType, rawEvent, timestamp); err != nil {
 			log.Printf("❌ Failed to persist event to database: %v", err)
 			// Don't fail the entire operation if database write fails
+			event.Processed = false
 		}
 	}
 
-	// Mark as processed after successful storage
-	event.Processed = true
+	if err := p.store.Store(event); err != nil {
+		return err
+	}
+	eventsProcessed.Inc(eventType)
 
 	return nil
 }
@@ -625,6 +647,11 @@ func (p *EventProcessor) persistGeneralEvent(agentID, eventType string, rawEvent
 
 // Stop stops the EventProcessor and cleans up resources
 func (p *EventProcessor) Stop() {
+	if p.store != nil {
+		if err := p.store.Close(); err != nil {
+			log.Printf("❌ EventProcessor: failed to close event store: %v", err)
+		}
+	}
 	if p.syncStateManager != nil {
 		p.syncStateManager.Stop()
 		log.Printf("✅ EventProcessor: SyncStateManager stopped")
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTimeRangeEvents caps GetEventsInTimeRange so a wide range can't load the whole table
+const maxTimeRangeEvents = 10000
+
+// eventEnqueueTimeout bounds how long Store waits for room in a full queue. Store is called from
+// the agent WebSocket read loop, which must not stall while the database is slow or down.
+const eventEnqueueTimeout = 100 * time.Millisecond
+
+// PostgresEventStore implements durable event storage in the agent_events table.
+// Events are queued in memory and written in batches by a background writer.
+type PostgresEventStore struct {
+	db            *sql.DB
+	queue         chan *Event
+	batchSize     int
+	flushInterval time.Duration
+	retention     time.Duration
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	closeOnce     sync.Once
+}
+
+// NewPostgresEventStore creates a Postgres event store and starts its batch writer
+func NewPostgresEventStore(db *sql.DB, cfg EventStoreConfig) (*PostgresEventStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres event store requires a database connection")
+	}
+
+	// Make sure the table exists (migrations/008_add_agent_event_store.sql)
+	if _, err := db.Exec(`SELECT 1 FROM agent_events LIMIT 1`); err != nil {
+		return nil, fmt.Errorf("agent_events table not available (run migration 008): %w", err)
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = 1 * time.Second
+	}
+	queueSize := cfg.BufferSize
+	if queueSize < batchSize {
+		queueSize = batchSize * 10
+	}
+
+	ps := &PostgresEventStore{
+		db:            db,
+		queue:         make(chan *Event, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		retention:     cfg.Retention,
+		stopChan:      make(chan struct{}),
+	}
+
+	ps.wg.Add(1)
+	go ps.runWriter()
+
+	if ps.retention > 0 {
+		ps.wg.Add(1)
+		go ps.runRetentionCleanup()
+	}
+
+	log.Printf("✅ Postgres event store initialized (batch=%d, flush=%s, retention=%s)",
+		batchSize, flushInterval, ps.retention)
+	return ps, nil
+}
+
+// Store queues a copy of the event for the next batch insert, so event.ID is not set.
+// When the queue stays full for eventEnqueueTimeout the event is dropped and counted in
+// bsync_events_dropped_total rather than blocking the caller.
+func (ps *PostgresEventStore) Store(event *Event) error {
+	queued := *event
+	if queued.CreatedAt.IsZero() {
+		queued.CreatedAt = time.Now()
+	}
+	if queued.Timestamp.IsZero() {
+		queued.Timestamp = queued.CreatedAt
+	}
+
+	select {
+	case <-ps.stopChan:
+		return fmt.Errorf("event store is closed")
+	default:
+	}
+
+	select {
+	case ps.queue <- &queued:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(eventEnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case ps.queue <- &queued:
+		return nil
+	case <-timer.C:
+		eventsDropped.Inc("queue_full")
+		return fmt.Errorf("event queue full (%d events), event dropped", cap(ps.queue))
+	case <-ps.stopChan:
+		return fmt.Errorf("event store is closed")
+	}
+}
+
+// runWriter drains the queue and writes events in batches
+func (ps *PostgresEventStore) runWriter() {
+	defer ps.wg.Done()
+
+	ticker := time.NewTicker(ps.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Event, 0, ps.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ps.writeBatch(batch)
+		batch = make([]*Event, 0, ps.batchSize)
+	}
+
+	for {
+		select {
+		case event := <-ps.queue:
+			batch = append(batch, event)
+			if len(batch) >= ps.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ps.stopChan:
+			// Drain whatever is still queued before exiting
+			for {
+				select {
+				case event := <-ps.queue:
+					batch = append(batch, event)
+					if len(batch) >= ps.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch inserts a batch with a single multi-row INSERT, retrying transient failures.
+// Retries stop early when the store is closed so shutdown isn't held up by a database outage.
+func (ps *PostgresEventStore) writeBatch(batch []*Event) {
+	const maxAttempts = 3
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if lastErr = ps.insertBatch(batch); lastErr == nil {
+			return
+		}
+		log.Printf("⚠️ Failed to write %d events (attempt %d/%d): %v", len(batch), attempt, maxAttempts, lastErr)
+		if attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+		case <-ps.stopChan:
+			attempt = maxAttempts
+		}
+	}
+
+	eventsDropped.Add(float64(len(batch)), "write_failed")
+	log.Printf("❌ Dropping %d events after failed write attempts: %v", len(batch), lastErr)
+}
+
+// insertBatch performs the multi-row INSERT. The events are copies made by Store, the generated
+// IDs are not read back.
+func (ps *PostgresEventStore) insertBatch(batch []*Event) error {
+	placeholders := make([]string, 0, len(batch))
+	args := make([]interface{}, 0, len(batch)*6)
+
+	for i, event := range batch {
+		base := i * 6
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)",
+			base+1, base+2, base+3, base+4, base+5, base+6))
+
+		var data interface{}
+		if len(event.Data) > 0 && json.Valid(event.Data) {
+			data = string(event.Data)
+		}
+		args = append(args, event.AgentID, event.Type, event.Timestamp, data, event.Processed, event.CreatedAt)
+	}
+
+	query := `
+		INSERT INTO agent_events (agent_id, event_type, event_timestamp, data, processed, created_at)
+		VALUES ` + strings.Join(placeholders, ", ")
+
+	_, err := ps.db.Exec(query, args...)
+	return err
+}
+
+// runRetentionCleanup deletes events older than the retention period once per hour
+func (ps *PostgresEventStore) runRetentionCleanup() {
+	defer ps.wg.Done()
+
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	cleanup := func() {
+		result, err := ps.db.Exec(`DELETE FROM agent_events WHERE created_at < $1`, time.Now().Add(-ps.retention))
+		if err != nil {
+			log.Printf("❌ Failed to clean up old events: %v", err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("🧹 Removed %d events older than %s", n, ps.retention)
+		}
+	}
+
+	cleanup()
+	for {
+		select {
+		case <-ticker.C:
+			cleanup()
+		case <-ps.stopChan:
+			return
+		}
+	}
+}
+
+// GetEvents retrieves the newest events after a given ID, returned oldest first
+func (ps *PostgresEventStore) GetEvents(agentID string, since int64, limit int) ([]*Event, error) {
+	rows, err := ps.db.Query(`
+		SELECT id, agent_id, event_type, event_timestamp, data, processed, created_at
+		FROM (
+			SELECT id, agent_id, event_type, event_timestamp, data, processed, created_at
+			FROM agent_events
+			WHERE id > $1 AND ($2 = '' OR agent_id = $2)
+			ORDER BY id DESC
+			LIMIT $3
+		) newest
+		ORDER BY id ASC
+	`, since, agentID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetEventsByType retrieves the newest events of a type, newest first
+func (ps *PostgresEventStore) GetEventsByType(agentID string, eventType string, limit int) ([]*Event, error) {
+	rows, err := ps.db.Query(`
+		SELECT id, agent_id, event_type, event_timestamp, data, processed, created_at
+		FROM agent_events
+		WHERE event_type = $1 AND ($2 = '' OR agent_id = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, eventType, agentID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events by type: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetEventsInTimeRange retrieves events with a timestamp strictly between from and to
+func (ps *PostgresEventStore) GetEventsInTimeRange(agentID string, from, to time.Time) ([]*Event, error) {
+	rows, err := ps.db.Query(`
+		SELECT id, agent_id, event_type, event_timestamp, data, processed, created_at
+		FROM agent_events
+		WHERE event_timestamp > $1 AND event_timestamp < $2 AND ($3 = '' OR agent_id = $3)
+		ORDER BY event_timestamp ASC, id ASC
+		LIMIT $4
+	`, from, to, agentID, maxTimeRangeEvents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events in time range: %w", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetEventStats returns statistics about stored events
+func (ps *PostgresEventStore) GetEventStats(agentID string) (*EventStats, error) {
+	stats := &EventStats{
+		EventsByType:  make(map[string]int64),
+		EventsPerHour: make([]int64, 24),
+	}
+
+	// Totals by type
+	rows, err := ps.db.Query(`
+		SELECT event_type, COUNT(*)
+		FROM agent_events
+		WHERE ($1 = '' OR agent_id = $1)
+		GROUP BY event_type
+	`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event type counts: %w", err)
+	}
+	for rows.Next() {
+		var eventType string
+		var count int64
+		if err := rows.Scan(&eventType, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.EventsByType[eventType] = count
+		stats.TotalEvents += count
+	}
+	rows.Close()
+
+	// Events per hour for the last 24 hours (index 23 = current hour)
+	now := time.Now()
+	rows, err = ps.db.Query(`
+		SELECT FLOOR(EXTRACT(EPOCH FROM ($2::timestamptz - event_timestamp)) / 3600)::int AS hours_ago, COUNT(*)
+		FROM agent_events
+		WHERE ($1 = '' OR agent_id = $1)
+		AND event_timestamp > $2::timestamptz - INTERVAL '24 hours'
+		AND event_timestamp <= $2::timestamptz
+		GROUP BY hours_ago
+	`, agentID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hourly event counts: %w", err)
+	}
+	for rows.Next() {
+		var hoursAgo int
+		var count int64
+		if err := rows.Scan(&hoursAgo, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if hoursAgo >= 0 && hoursAgo < 24 {
+			stats.EventsPerHour[23-hoursAgo] = count
+		}
+	}
+	rows.Close()
+
+	var lastEventTime *time.Time
+	if err := ps.db.QueryRow(`
+		SELECT MAX(event_timestamp) FROM agent_events WHERE ($1 = '' OR agent_id = $1)
+	`, agentID).Scan(&lastEventTime); err != nil {
+		return nil, fmt.Errorf("failed to query last event time: %w", err)
+	}
+	if lastEventTime != nil {
+		stats.LastEventTime = *lastEventTime
+	}
+
+	// Processing rate: events per second over the most recent hour
+	stats.ProcessingRate = float64(stats.EventsPerHour[23]) / 3600
+
+	return stats, nil
+}
+
+// Close flushes queued events and stops background workers
+func (ps *PostgresEventStore) Close() error {
+	ps.closeOnce.Do(func() {
+		close(ps.stopChan)
+		ps.wg.Wait()
+		log.Printf("✅ Postgres event store closed")
+	})
+	return nil
+}
+
+// scanEvents converts agent_events rows into Event values
+func scanEvents(rows *sql.Rows) ([]*Event, error) {
+	var result []*Event
+	for rows.Next() {
+		event := &Event{}
+		var data []byte
+		if err := rows.Scan(&event.ID, &event.AgentID, &event.Type, &event.Timestamp,
+			&data, &event.Processed, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		if len(data) > 0 {
+			event.Data = json.RawMessage(data)
+		}
+		result = append(result, event)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPostgresEventStoreStoreQueuesCopy(t *testing.T) {
+	ps := &PostgresEventStore{queue: make(chan *Event, 1), stopChan: make(chan struct{})}
+	event := &Event{AgentID: "agent-1", Type: "FolderCompletion"}
+
+	if err := ps.Store(event); err != nil {
+		t.Fatal(err)
+	}
+	queued := <-ps.queue
+	if queued == event {
+		t.Fatal("Store() queued the caller's event instead of a copy")
+	}
+	if queued.CreatedAt.IsZero() || !queued.Timestamp.Equal(queued.CreatedAt) {
+		t.Errorf("queued event times = %v, %v, want both set to the time it was stored", queued.Timestamp, queued.CreatedAt)
+	}
+	if event.ID != 0 || !event.CreatedAt.IsZero() {
+		t.Errorf("caller's event changed to %+v", event)
+	}
+
+	if err := ps.Store(event); err != nil {
+		t.Fatal(err)
+	}
+	close(ps.stopChan)
+	if err := ps.Store(event); err == nil {
+		t.Error("Store() accepted an event after the store was closed")
+	}
+}
+
+func TestPostgresEventStoreInsertBatch(t *testing.T) {
+	at := time.Date(2030, 3, 8, 12, 0, 0, 0, time.UTC)
+	batch := []*Event{
+		{AgentID: "agent-1", Type: "FolderCompletion", Timestamp: at, Data: json.RawMessage(`{"folder":"job-1"}`), Processed: true, CreatedAt: at},
+		{AgentID: "agent-2", Type: "StateChanged", Timestamp: at, Data: json.RawMessage(`not json`), CreatedAt: at},
+	}
+
+	db, _ := newFakeDB(t, &fakeStatement{
+		query: "($1, $2, $3, $4, $5, $6), ($7, $8, $9, $10, $11, $12)",
+		args: []driver.Value{
+			"agent-1", "FolderCompletion", at, `{"folder":"job-1"}`, true, at,
+			"agent-2", "StateChanged", at, nil, false, at,
+		},
+		affected: 2,
+	})
+	ps := &PostgresEventStore{db: db}
+
+	if err := ps.insertBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeStatement is a statement a test expects, with the result the fake database returns for it
+type fakeStatement struct {
+	query    string                          // substring of the statement
+	args     []driver.Value                  // checked unless nil
+	check    func(args []driver.Value) error // called with the arguments unless nil
+	columns  []string                        // query result
+	rows     [][]driver.Value
+	affected int64 // exec result
+	err      error
+}
+
+// fakeDB is a scripted database/sql driver: every statement must match the next expectation.
+// Transactions are recorded as BEGIN, COMMIT and ROLLBACK in executed.
+type fakeDB struct {
+	t        *testing.T
+	mutex    sync.Mutex
+	expected []*fakeStatement
+	executed []string
+}
+
+// newFakeDB returns a database that expects the given statements in order; statements left
+// over when the test ends fail it
+func newFakeDB(t *testing.T, expected ...*fakeStatement) (*sql.DB, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{t: t, expected: expected}
+	db := sql.OpenDB(fake)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+		for _, stmt := range fake.expected {
+			t.Errorf("statement not executed: %s", stmt.query)
+		}
+	})
+	return db, fake
+}
+
+// ran reports whether a statement containing query was executed
+func (f *fakeDB) ran(query string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, executed := range f.executed {
+		if strings.Contains(executed, query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeDB) next(query string, args []driver.NamedValue) (*fakeStatement, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.executed = append(f.executed, query)
+
+	if len(f.expected) == 0 {
+		f.t.Errorf("unexpected statement: %s", query)
+		return nil, fmt.Errorf("unexpected statement")
+	}
+	stmt := f.expected[0]
+	f.expected = f.expected[1:]
+	if !strings.Contains(query, stmt.query) {
+		f.t.Errorf("statement %q, want one containing %q", query, stmt.query)
+		return nil, fmt.Errorf("unexpected statement")
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	if stmt.args != nil && !reflect.DeepEqual(values, stmt.args) {
+		f.t.Errorf("arguments of %q = %v, want %v", stmt.query, values, stmt.args)
+	}
+	if stmt.check != nil {
+		if err := stmt.check(values); err != nil {
+			f.t.Errorf("arguments of %q: %v", stmt.query, err)
+		}
+	}
+	return stmt, stmt.err
+}
+
+func (f *fakeDB) record(statement string) {
+	f.mutex.Lock()
+	f.executed = append(f.executed, statement)
+	f.mutex.Unlock()
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fake database does not prepare statements")
+}
+func (c *fakeConn) Close() error                             { return nil }
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN")
+	return c, nil
+}
+func (c *fakeConn) Commit() error {
+	c.db.record("COMMIT")
+	return nil
+}
+func (c *fakeConn) Rollback() error {
+	c.db.record("ROLLBACK")
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmt, err := c.db.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(stmt.affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := c.db.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: stmt.columns, rows: stmt.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"bsync-server/pkg/protocol"
+)
+
+// Hooks run by the agents around a sync
+const (
+	hookPreScan     = "pre_scan"     // source agent, before every scan it starts
+	hookPostSession = "post_session" // destination agents, after a session that transferred files
+)
+
+const (
+	defaultHookTimeoutSeconds = 300
+	maxHookTimeoutSeconds     = 24 * 3600
+	maxHookCommandLength      = 4096
+	maxHookEnvVars            = 50
+)
+
+// hookEnvName matches the names of the extra environment variables of a hook
+var hookEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// HookCommand is a shell command run by an agent. The agent adds BSYNC_JOB_ID, BSYNC_SESSION_ID,
+// BSYNC_FILES_TRANSFERRED, ... to Env (names starting with BSYNC_ are reserved).
+type HookCommand struct {
+	Command         string            `json:"command"`
+	TimeoutSeconds  int               `json:"timeout_seconds"`
+	Env             map[string]string `json:"env,omitempty"`
+	ContinueOnError bool              `json:"continue_on_error,omitempty"` // pre_scan only: scan even if the hook fails
+}
+
+// JobHooks are the commands run around the syncs of a job
+type JobHooks struct {
+	PreScan     *HookCommand `json:"pre_scan,omitempty"`
+	PostSession *HookCommand `json:"post_session,omitempty"`
+}
+
+// HookResult is the outcome of a hook as reported by an agent and stored in sync_sessions.hook_results
+type HookResult struct {
+	Hook        string    `json:"hook"`
+	AgentID     string    `json:"agent_id"`
+	Command     string    `json:"command"`
+	ExitCode    int       `json:"exit_code"` // -1 if the command could not be started or was killed
+	Output      string    `json:"output"`
+	Truncated   bool      `json:"truncated,omitempty"`
+	TimedOut    bool      `json:"timed_out,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ScanSkipped bool      `json:"scan_skipped,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+// validate checks a hook command and applies the default timeout
+func (h *HookCommand) validate(hook string) error {
+	h.Command = strings.TrimSpace(h.Command)
+	if h.Command == "" {
+		return fmt.Errorf("%s.command is required", hook)
+	}
+	if len(h.Command) > maxHookCommandLength {
+		return fmt.Errorf("%s.command must not exceed %d characters", hook, maxHookCommandLength)
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = defaultHookTimeoutSeconds
+	}
+	if h.TimeoutSeconds < 0 || h.TimeoutSeconds > maxHookTimeoutSeconds {
+		return fmt.Errorf("%s.timeout_seconds must be between 1 and %d", hook, maxHookTimeoutSeconds)
+	}
+	if len(h.Env) > maxHookEnvVars {
+		return fmt.Errorf("%s.env must not have more than %d variables", hook, maxHookEnvVars)
+	}
+	for name := range h.Env {
+		if !hookEnvName.MatchString(name) {
+			return fmt.Errorf("%s.env: invalid variable name %q", hook, name)
+		}
+		if strings.HasPrefix(strings.ToUpper(name), "BSYNC_") {
+			return fmt.Errorf("%s.env: %s is reserved, BSYNC_* variables are set by the agent", hook, name)
+		}
+	}
+	if hook == hookPostSession && h.ContinueOnError {
+		return fmt.Errorf("continue_on_error only applies to pre_scan")
+	}
+	return nil
+}
+
+// hooksFromJobData reads "hooks" from a create/update request body.
+// present is false if the field was not sent at all; null removes all hooks.
+func hooksFromJobData(jobData map[string]interface{}) (hooks *JobHooks, present bool, err error) {
+	raw, present := jobData["hooks"]
+	if !present || raw == nil {
+		return nil, present, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, true, err
+	}
+	hooks = &JobHooks{}
+	if err := json.Unmarshal(data, hooks); err != nil {
+		return nil, true, err
+	}
+	if hooks.PreScan != nil {
+		if err := hooks.PreScan.validate(hookPreScan); err != nil {
+			return nil, true, err
+		}
+	}
+	if hooks.PostSession != nil {
+		if err := hooks.PostSession.validate(hookPostSession); err != nil {
+			return nil, true, err
+		}
+	}
+	if hooks.PreScan == nil && hooks.PostSession == nil {
+		return nil, true, nil
+	}
+	return hooks, true, nil
+}
+
+// jobHooksJSON converts hooks into a value for a JSONB column
+func jobHooksJSON(hooks *JobHooks) interface{} {
+	if hooks == nil {
+		return nil
+	}
+	data, _ := json.Marshal(hooks)
+	return string(data)
+}
+
+// hooksChanged reports whether requested differs from the current hooks of a job
+func hooksChanged(current, requested *JobHooks) bool {
+	return jobHooksJSON(current) != jobHooksJSON(requested)
+}
+
+// scanJobHooks decodes a nullable JSONB column
+func scanJobHooks(raw sql.NullString) *JobHooks {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var hooks JobHooks
+	if err := json.Unmarshal([]byte(raw.String), &hooks); err != nil {
+		log.Printf("⚠️ Ignoring invalid stored hooks: %v", err)
+		return nil
+	}
+	return &hooks
+}
+
+// jobHooks returns the hooks of a job, nil if it has none
+func (s *SyncToolServer) jobHooks(jobID string) *JobHooks {
+	if s.db == nil {
+		return nil
+	}
+	var raw sql.NullString
+	if err := s.db.QueryRow(`SELECT hooks FROM sync_jobs WHERE id = $1`, jobID).Scan(&raw); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load hooks for job %s: %v", jobID, err)
+		}
+		return nil
+	}
+	return scanJobHooks(raw)
+}
+
+// handleHookResult stores the result of a hook in the session it belongs to. A pre-scan hook that
+// failed and skipped the scan has no session, it is recorded as a failed session of its own.
+func (s *SyncToolServer) handleHookResult(agentID string, msg *protocol.HookResult) {
+	jobID := strings.TrimPrefix(msg.JobID, "job-")
+	sessionID := msg.SessionID
+
+	result := HookResult{
+		Hook:        msg.Hook,
+		AgentID:     agentID,
+		Command:     msg.Command,
+		ExitCode:    msg.ExitCode,
+		Output:      msg.Output,
+		Truncated:   msg.Truncated,
+		TimedOut:    msg.TimedOut,
+		Error:       msg.Error,
+		ScanSkipped: msg.ScanSkipped,
+		StartedAt:   msg.StartedAt,
+		DurationMs:  msg.DurationMs,
+	}
+	resultJSON, _ := json.Marshal(result)
+
+	failed := result.ExitCode != 0 || result.TimedOut || result.Error != ""
+	if failed {
+		log.Printf("❌ %s hook of job %s failed on agent %s: exit code %d %s", result.Hook, jobID, agentID, result.ExitCode, result.Error)
+	} else {
+		log.Printf("🪝 %s hook of job %s succeeded on agent %s in %dms", result.Hook, jobID, agentID, result.DurationMs)
+	}
+
+	if sessionID != "" {
+		res, err := s.db.Exec(`
+			UPDATE sync_sessions
+			SET hook_results = COALESCE(hook_results, '[]'::jsonb) || jsonb_build_array($1::jsonb), updated_at = NOW()
+			WHERE session_id = $2
+		`, string(resultJSON), sessionID)
+		if err != nil {
+			log.Printf("❌ Failed to store hook result of session %s: %v", sessionID, err)
+		} else if affected, _ := res.RowsAffected(); affected == 0 {
+			log.Printf("⚠️ Hook result for unknown session %s of job %s", sessionID, jobID)
+		}
+	} else {
+		var jobName string
+		s.db.QueryRow(`SELECT name FROM sync_jobs WHERE id = $1`, jobID).Scan(&jobName)
+
+		status, errMsg := "completed", ""
+		if failed {
+			status = "failed"
+			switch {
+			case result.TimedOut:
+				errMsg = fmt.Sprintf("%s hook timed out", result.Hook)
+			case result.Error != "":
+				errMsg = fmt.Sprintf("%s hook failed: %s", result.Hook, result.Error)
+			default:
+				errMsg = fmt.Sprintf("%s hook failed with exit code %d", result.Hook, result.ExitCode)
+			}
+			if result.ScanSkipped {
+				errMsg += ", scan skipped"
+			}
+		}
+
+		startedAt := result.StartedAt
+		if startedAt.IsZero() {
+			startedAt = time.Now()
+		}
+		endedAt := startedAt.Add(time.Duration(result.DurationMs) * time.Millisecond)
+		sessionID = fmt.Sprintf("%s-hook-%s-%d", jobID, agentID, startedAt.UnixNano())
+		if _, err := s.db.Exec(`
+			INSERT INTO sync_sessions (
+				session_id, job_id, job_name, agent_id, session_start_time, session_end_time, total_duration_seconds,
+				current_state, status, error_message, hook_results, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, 'idle', $8, $9, jsonb_build_array($10::jsonb), NOW())
+			ON CONFLICT (session_id) DO NOTHING
+		`, sessionID, jobID, jobName, agentID, startedAt, endedAt, int64(endedAt.Sub(startedAt).Seconds()),
+			status, nullIfEmpty(errMsg), string(resultJSON)); err != nil {
+			log.Printf("❌ Failed to record hook session of job %s: %v", jobID, err)
+		}
+	}
+
+	if failed {
+		go s.emitWebhookEvent(WebhookEventJobFailed, map[string]interface{}{
+			"job_id":     jobID,
+			"agent_id":   agentID,
+			"session_id": sessionID,
+			"operation":  result.Hook + "_hook",
+			"message":    fmt.Sprintf("exit code %d %s", result.ExitCode, result.Error),
+			"exit_code":  result.ExitCode,
+			"timed_out":  result.TimedOut,
+		})
+	}
+}
+
+// writeHooksError writes a 400 response for invalid hooks
+func writeHooksError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid hooks: %v", err),
+	})
+}
+
+// scanHookResults decodes the nullable sync_sessions.hook_results column
+func scanHookResults(raw sql.NullString) []HookResult {
+	results := []HookResult{}
+	if raw.Valid && raw.String != "" {
+		if err := json.Unmarshal([]byte(raw.String), &results); err != nil {
+			log.Printf("⚠️ Ignoring invalid stored hook results: %v", err)
+		}
+	}
+	return results
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"bsync-server/internal/models"
+)
+
+func TestHooksChanged(t *testing.T) {
+	backup := &JobHooks{PreScan: &HookCommand{Command: "/usr/local/bin/backup", TimeoutSeconds: 300}}
+
+	tests := []struct {
+		name      string
+		current   *JobHooks
+		requested *JobHooks
+		want      bool
+	}{
+		{name: "none", want: false},
+		{name: "same hooks sent again", current: backup, requested: &JobHooks{PreScan: &HookCommand{Command: "/usr/local/bin/backup", TimeoutSeconds: 300}}, want: false},
+		{name: "added", requested: backup, want: true},
+		{name: "removed", current: backup, want: true},
+		{name: "other command", current: backup, requested: &JobHooks{PreScan: &HookCommand{Command: "/bin/true", TimeoutSeconds: 300}}, want: true},
+		{name: "other hook", current: backup, requested: &JobHooks{PostSession: backup.PreScan}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hooksChanged(tt.current, tt.requested); got != tt.want {
+				t.Errorf("hooksChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallerIsAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims *models.JWTClaims
+		want   bool
+	}{
+		{name: "authentication off", want: true},
+		{name: "admin", claims: &models.JWTClaims{Role: models.RoleAdmin}, want: true},
+		{name: "operator", claims: &models.JWTClaims{Role: models.RoleOperator}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/api/v1/jobs/1", nil)
+			if tt.claims != nil {
+				r = r.WithContext(context.WithValue(r.Context(), "user_claims", tt.claims))
+			}
+			s := &SyncToolServer{}
+			if got := s.callerIsAdmin(r); got != tt.want {
+				t.Errorf("callerIsAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
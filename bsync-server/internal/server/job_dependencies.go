@@ -0,0 +1,603 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Results of a dependency trigger stored in job_dependencies.last_trigger_result
+const (
+	dependencyTriggered = "triggered" // rescan sent to the source agent of the dependent job
+	dependencySkipped   = "skipped"   // dependent job paused, finished or in a closed maintenance window
+	dependencyFailed    = "failed"    // source agent of the dependent job not reachable
+)
+
+// States of a job in a dependency chain
+const (
+	chainIdle    = "idle"
+	chainSyncing = "syncing"
+	chainBlocked = "blocked"
+)
+
+// maxJobDependencies caps the jobs a single job can depend on
+const maxJobDependencies = 50
+
+// jobDependenciesLockKey is the transaction advisory lock that serializes dependency changes:
+// two concurrent updates could each pass the cycle check and together close a cycle
+const jobDependenciesLockKey = 0x6a6f6264 // "jobd"
+
+// dependencyQuerier runs the dependency lookups on the database or inside a transaction
+type dependencyQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// JobDependency is an edge of a job chain: JobID is triggered by successful sessions of DependsOnJobID
+type JobDependency struct {
+	JobID                int        `json:"job_id"`
+	DependsOnJobID       int        `json:"depends_on_job_id"`
+	LastTriggeredAt      *time.Time `json:"last_triggered_at"`
+	LastTriggerSessionID string     `json:"last_trigger_session_id,omitempty"`
+	LastTriggerResult    string     `json:"last_trigger_result,omitempty"`
+	LastTriggerError     string     `json:"last_trigger_error,omitempty"`
+}
+
+// ChainSession is the latest session of a job in a dependency chain
+type ChainSession struct {
+	SessionID        string     `json:"session_id"`
+	AgentID          string     `json:"agent_id"`
+	Status           string     `json:"status"`
+	StartedAt        time.Time  `json:"started_at"`
+	EndedAt          *time.Time `json:"ended_at"`
+	FilesTransferred int64      `json:"files_transferred"`
+}
+
+// ChainJob is a job of a dependency chain with its current state
+type ChainJob struct {
+	ID           int           `json:"id"`
+	Name         string        `json:"name"`
+	Status       string        `json:"status"`
+	JobType      string        `json:"job_type"`
+	MirrorStatus *string       `json:"mirror_status"`
+	WindowPaused bool          `json:"window_paused"`
+	Level        int           `json:"level"` // 0 for jobs without dependencies, then one more than the deepest dependency
+	ChainState   string        `json:"chain_state"`
+	DependsOn    []int         `json:"depends_on"`
+	LastSession  *ChainSession `json:"last_session"`
+}
+
+// dependsOnFromJobData reads "depends_on" from a request body: a list of job IDs, null or [] removes all
+func dependsOnFromJobData(jobData map[string]interface{}) (dependsOn []int, present bool, err error) {
+	raw, present := jobData["depends_on"]
+	if !present || raw == nil {
+		return []int{}, present, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, true, fmt.Errorf("depends_on must be a list of job IDs")
+	}
+
+	dependsOn = []int{}
+	for _, item := range list {
+		var id int
+		switch value := item.(type) {
+		case float64:
+			id = int(value)
+			if float64(id) != value {
+				return nil, true, fmt.Errorf("invalid job ID %v", value)
+			}
+		case string:
+			if id, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, true, fmt.Errorf("invalid job ID %q", value)
+			}
+		default:
+			return nil, true, fmt.Errorf("invalid job ID %v", item)
+		}
+		if id <= 0 {
+			return nil, true, fmt.Errorf("invalid job ID %d", id)
+		}
+		if !containsInt(dependsOn, id) {
+			dependsOn = append(dependsOn, id)
+		}
+	}
+	if len(dependsOn) > maxJobDependencies {
+		return nil, true, fmt.Errorf("a job can depend on at most %d jobs", maxJobDependencies)
+	}
+	sort.Ints(dependsOn)
+	return dependsOn, true, nil
+}
+
+// loadDependencyEdges returns the dependencies of every job: job ID -> IDs of the jobs it depends on
+func loadDependencyEdges(q dependencyQuerier) (map[int][]int, error) {
+	rows, err := q.Query(`SELECT job_id, depends_on_job_id FROM job_dependencies ORDER BY job_id, depends_on_job_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edges := make(map[int][]int)
+	for rows.Next() {
+		var jobID, dependsOnJobID int
+		if err := rows.Scan(&jobID, &dependsOnJobID); err != nil {
+			return nil, err
+		}
+		edges[jobID] = append(edges[jobID], dependsOnJobID)
+	}
+	return edges, rows.Err()
+}
+
+// validateJobDependencies checks that the jobs exist and that depending on them does not close a
+// cycle. jobID is 0 for a job that is being created, nothing can depend on it yet. Run it on the
+// database to reject a request early; saveJobDependencies runs it again under the lock.
+func validateJobDependencies(q dependencyQuerier, jobID int, dependsOn []int) error {
+	if len(dependsOn) == 0 {
+		return nil
+	}
+	if containsInt(dependsOn, jobID) {
+		return fmt.Errorf("a job cannot depend on itself")
+	}
+
+	rows, err := q.Query(`SELECT id FROM sync_jobs WHERE id = ANY($1)`, pq.Array(dependsOn))
+	if err != nil {
+		return fmt.Errorf("failed to look up jobs: %v", err)
+	}
+	found := []int{}
+	for rows.Next() {
+		var id int
+		if rows.Scan(&id) == nil {
+			found = append(found, id)
+		}
+	}
+	rows.Close()
+	for _, id := range dependsOn {
+		if !containsInt(found, id) {
+			return fmt.Errorf("job %d does not exist", id)
+		}
+	}
+
+	if jobID == 0 {
+		return nil
+	}
+
+	edges, err := loadDependencyEdges(q)
+	if err != nil {
+		return fmt.Errorf("failed to load job dependencies: %v", err)
+	}
+	edges[jobID] = dependsOn
+	if cycle := findDependencyCycle(edges, jobID); cycle != nil {
+		path := make([]string, len(cycle))
+		for i, id := range cycle {
+			path[i] = strconv.Itoa(id)
+		}
+		return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+	}
+	return nil
+}
+
+// findDependencyCycle follows the dependencies of jobID and returns the path back to it, nil if there is none
+func findDependencyCycle(edges map[int][]int, jobID int) []int {
+	visited := make(map[int]bool)
+	var walk func(id int, path []int) []int
+	walk = func(id int, path []int) []int {
+		for _, next := range edges[id] {
+			if next == jobID {
+				return append(path, next)
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if cycle := walk(next, append(path, next)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return walk(jobID, []int{jobID})
+}
+
+// replaceJobDependencies stores the jobs a job depends on, keeping the trigger state of unchanged edges
+func replaceJobDependencies(tx *sql.Tx, jobID int, dependsOn []int) error {
+	if _, err := tx.Exec(`DELETE FROM job_dependencies WHERE job_id = $1 AND NOT (depends_on_job_id = ANY($2))`, jobID, pq.Array(dependsOn)); err != nil {
+		return err
+	}
+	for _, dependsOnJobID := range dependsOn {
+		if _, err := tx.Exec(`
+			INSERT INTO job_dependencies (job_id, depends_on_job_id) VALUES ($1, $2)
+			ON CONFLICT (job_id, depends_on_job_id) DO NOTHING
+		`, jobID, dependsOnJobID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobDependsOn returns the IDs of the jobs a job depends on
+func (s *SyncToolServer) jobDependsOn(jobID string) []int {
+	dependsOn := []int{}
+	rows, err := s.db.Query(`SELECT depends_on_job_id FROM job_dependencies WHERE job_id = $1 ORDER BY depends_on_job_id`, jobID)
+	if err != nil {
+		return dependsOn
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if rows.Scan(&id) == nil {
+			dependsOn = append(dependsOn, id)
+		}
+	}
+	return dependsOn
+}
+
+// triggerDependentJobs starts the jobs depending on a job after one of its sessions completed
+// successfully on agentID (empty when a mirror finished on every destination). A dependent job
+// whose source agent takes part in the finished job only starts once the data arrived there.
+func (s *SyncToolServer) triggerDependentJobs(upstreamJobID, agentID, sessionID string) {
+	type dependentJob struct {
+		id            int
+		name          string
+		sourceAgentID string
+		status        string
+		windowPaused  bool
+	}
+
+	rows, err := s.db.Query(`
+		SELECT j.id, j.name, j.source_agent_id, j.status, COALESCE(j.window_paused, false)
+		FROM job_dependencies d
+		JOIN sync_jobs j ON j.id = d.job_id
+		WHERE d.depends_on_job_id = $1
+		ORDER BY j.id
+	`, upstreamJobID)
+	if err != nil {
+		log.Printf("❌ Failed to query jobs depending on job %s: %v", upstreamJobID, err)
+		return
+	}
+	var dependents []dependentJob
+	for rows.Next() {
+		var job dependentJob
+		if err := rows.Scan(&job.id, &job.name, &job.sourceAgentID, &job.status, &job.windowPaused); err != nil {
+			continue
+		}
+		dependents = append(dependents, job)
+	}
+	rows.Close()
+	if len(dependents) == 0 {
+		return
+	}
+
+	upstreamAgents, err := s.jobAgentIDs(upstreamJobID)
+	if err != nil {
+		log.Printf("❌ Failed to trigger jobs depending on job %s: %v", upstreamJobID, err)
+		return
+	}
+
+	for _, job := range dependents {
+		if agentID != "" && agentID != job.sourceAgentID && contains(upstreamAgents, job.sourceAgentID) {
+			continue
+		}
+
+		result, errMsg := dependencyTriggered, ""
+		if job.status != "active" || job.windowPaused {
+			result = dependencySkipped
+			errMsg = fmt.Sprintf("job is %s", job.status)
+			if job.windowPaused {
+				errMsg = "job is paused by a maintenance window"
+			}
+			log.Printf("⏭️ Job %d (%s) not triggered by job %s: %s", job.id, job.name, upstreamJobID, errMsg)
+		} else if err := s.sendJobToAgent(job.sourceAgentID, map[string]interface{}{
+			"type":      "scan-folder",
+			"folder_id": fmt.Sprintf("job-%d", job.id),
+		}); err != nil {
+			result, errMsg = dependencyFailed, err.Error()
+			log.Printf("❌ Failed to trigger job %d (%s) after job %s: %v", job.id, job.name, upstreamJobID, err)
+		} else {
+			log.Printf("🔗 Job %d (%s) triggered by session %s of job %s", job.id, job.name, sessionID, upstreamJobID)
+		}
+
+		if _, err := s.db.Exec(`
+			UPDATE job_dependencies
+			SET last_triggered_at = NOW(), last_trigger_session_id = $1, last_trigger_result = $2, last_trigger_error = $3
+			WHERE job_id = $4 AND depends_on_job_id = $5
+		`, nullIfEmpty(sessionID), result, nullIfEmpty(errMsg), job.id, upstreamJobID); err != nil {
+			log.Printf("❌ Failed to record trigger of job %d: %v", job.id, err)
+		}
+	}
+}
+
+// loadJobChain returns the jobs connected to a job through dependencies, in either direction, and their edges
+func (s *SyncToolServer) loadJobChain(jobID int) ([]*ChainJob, []JobDependency, error) {
+	rows, err := s.db.Query(`
+		SELECT job_id, depends_on_job_id, last_triggered_at, COALESCE(last_trigger_session_id, ''),
+		       COALESCE(last_trigger_result, ''), COALESCE(last_trigger_error, '')
+		FROM job_dependencies ORDER BY job_id, depends_on_job_id
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	var allEdges []JobDependency
+	neighbours := make(map[int][]int)
+	for rows.Next() {
+		var edge JobDependency
+		if err := rows.Scan(&edge.JobID, &edge.DependsOnJobID, &edge.LastTriggeredAt, &edge.LastTriggerSessionID,
+			&edge.LastTriggerResult, &edge.LastTriggerError); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		allEdges = append(allEdges, edge)
+		neighbours[edge.JobID] = append(neighbours[edge.JobID], edge.DependsOnJobID)
+		neighbours[edge.DependsOnJobID] = append(neighbours[edge.DependsOnJobID], edge.JobID)
+	}
+	rows.Close()
+
+	member := map[int]bool{jobID: true}
+	queue := []int{jobID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range neighbours[id] {
+			if !member[next] {
+				member[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(member))
+	for id := range member {
+		ids = append(ids, id)
+	}
+	edges := []JobDependency{}
+	for _, edge := range allEdges {
+		if member[edge.JobID] {
+			edges = append(edges, edge)
+		}
+	}
+
+	rows, err = s.db.Query(`
+		SELECT id, name, status, COALESCE(job_type, 'sync'), mirror_status, COALESCE(window_paused, false)
+		FROM sync_jobs WHERE id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs := make(map[int]*ChainJob, len(ids))
+	sessionJobIDs := make([]string, 0, 2*len(ids))
+	for rows.Next() {
+		job := &ChainJob{DependsOn: []int{}}
+		if err := rows.Scan(&job.ID, &job.Name, &job.Status, &job.JobType, &job.MirrorStatus, &job.WindowPaused); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		jobs[job.ID] = job
+		sessionJobIDs = append(sessionJobIDs, strconv.Itoa(job.ID), fmt.Sprintf("job-%d", job.ID))
+	}
+	rows.Close()
+	if len(jobs) == 0 {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	// Sessions reported by agents carry the bare job ID, final sessions of mirrors the folder ID
+	rows, err = s.db.Query(`
+		SELECT DISTINCT ON (REPLACE(job_id, 'job-', '')) REPLACE(job_id, 'job-', ''), session_id, agent_id, COALESCE(status, ''),
+		       session_start_time, session_end_time, COALESCE(files_transferred, 0)
+		FROM sync_sessions WHERE job_id = ANY($1)
+		ORDER BY REPLACE(job_id, 'job-', ''), session_start_time DESC
+	`, pq.Array(sessionJobIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var sessionJobID string
+		session := &ChainSession{}
+		if err := rows.Scan(&sessionJobID, &session.SessionID, &session.AgentID, &session.Status, &session.StartedAt, &session.EndedAt, &session.FilesTransferred); err != nil {
+			continue
+		}
+		if id, err := strconv.Atoi(sessionJobID); err == nil && jobs[id] != nil {
+			jobs[id].LastSession = session
+		}
+	}
+	rows.Close()
+
+	for _, edge := range edges {
+		if job := jobs[edge.JobID]; job != nil {
+			job.DependsOn = append(job.DependsOn, edge.DependsOnJobID)
+		}
+	}
+
+	// Level of a job: one more than the deepest job it depends on
+	levels := make(map[int]int, len(jobs))
+	var level func(id int, depth int) int
+	level = func(id int, depth int) int {
+		if value, ok := levels[id]; ok {
+			return value
+		}
+		value := 0
+		if job := jobs[id]; job != nil && depth <= len(jobs) {
+			for _, dependsOnJobID := range job.DependsOn {
+				if next := level(dependsOnJobID, depth+1) + 1; next > value {
+					value = next
+				}
+			}
+		}
+		levels[id] = value
+		return value
+	}
+
+	chain := make([]*ChainJob, 0, len(jobs))
+	for _, job := range jobs {
+		job.Level = level(job.ID, 0)
+		job.ChainState = chainJobState(job)
+		chain = append(chain, job)
+	}
+	sort.Slice(chain, func(i, j int) bool {
+		if chain[i].Level != chain[j].Level {
+			return chain[i].Level < chain[j].Level
+		}
+		return chain[i].ID < chain[j].ID
+	})
+	return chain, edges, nil
+}
+
+// chainJobState tells whether a job of a chain is running, cannot run or waits for a trigger
+func chainJobState(job *ChainJob) string {
+	mirrorStatus := ""
+	if job.MirrorStatus != nil {
+		mirrorStatus = *job.MirrorStatus
+	}
+	switch {
+	case mirrorStatus == mirrorFailed, job.Status == "paused", job.Status == "failed", job.WindowPaused:
+		return chainBlocked
+	case mirrorStatus == mirrorPending, mirrorStatus == mirrorSyncing:
+		return chainSyncing
+	case job.LastSession != nil && job.LastSession.EndedAt == nil && job.LastSession.Status == "active":
+		return chainSyncing
+	}
+	return chainIdle
+}
+
+// handleJobDependencies replaces the jobs a job depends on: PUT /api/v1/sync-jobs/{id}/dependencies {"depends_on": [1, 2]}
+func (s *SyncToolServer) handleJobDependencies(w http.ResponseWriter, r *http.Request, jobID string) {
+	if r.Method != "PUT" {
+		http.Error(w, `{"error": "Only PUT method allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(jobID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid job ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var jobData map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&jobData); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	dependsOn, present, err := dependsOnFromJobData(jobData)
+	if err == nil && !present {
+		err = fmt.Errorf("missing depends_on")
+	}
+	if err != nil {
+		writeJobDependencyError(w, err)
+		return
+	}
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sync_jobs WHERE id = $1)`, id).Scan(&exists); err != nil || !exists {
+		http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := s.saveJobDependencies(id, dependsOn); err != nil {
+		writeJobDependencyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    "Job dependencies updated",
+		"job_id":     id,
+		"depends_on": dependsOn,
+	})
+}
+
+// saveJobDependencies validates and stores the jobs an existing job depends on
+func (s *SyncToolServer) saveJobDependencies(jobID int, dependsOn []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The edges the cycle check sees must not change before the new ones are committed
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, jobDependenciesLockKey); err != nil {
+		return fmt.Errorf("failed to lock job dependencies: %v", err)
+	}
+	if err := validateJobDependencies(tx, jobID, dependsOn); err != nil {
+		return err
+	}
+	if err := replaceJobDependencies(tx, jobID, dependsOn); err != nil {
+		log.Printf("❌ Failed to save dependencies of job %d: %v", jobID, err)
+		return fmt.Errorf("failed to save dependencies: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save dependencies: %v", err)
+	}
+
+	log.Printf("🔗 Job %d depends on %v", jobID, dependsOn)
+	return nil
+}
+
+// handleJobChain returns the dependency chain a job belongs to: GET /api/v1/sync-jobs/{id}/chain
+func (s *SyncToolServer) handleJobChain(w http.ResponseWriter, r *http.Request, jobID string) {
+	if r.Method != "GET" {
+		http.Error(w, `{"error": "Only GET method allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(jobID)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid job ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	jobs, edges, err := s.loadJobChain(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to load dependency chain of job %s: %v", jobID, err)
+		http.Error(w, `{"error": "Failed to load dependency chain"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// The chain is blocked as soon as one job cannot run, syncing while any job runs
+	chainStatus := chainIdle
+	blocked := []int{}
+	for _, job := range jobs {
+		switch job.ChainState {
+		case chainBlocked:
+			blocked = append(blocked, job.ID)
+			chainStatus = chainBlocked
+		case chainSyncing:
+			if chainStatus == chainIdle {
+				chainStatus = chainSyncing
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":       id,
+		"chain_status": chainStatus,
+		"blocked_jobs": blocked,
+		"jobs":         jobs,
+		"dependencies": edges,
+		"total":        len(jobs),
+	})
+}
+
+// writeJobDependencyError writes a 400 response for invalid job dependencies
+func writeJobDependencyError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid depends_on: %v", err),
+	})
+}
+
+// containsInt checks if an int exists in a slice
+func containsInt(slice []int, item int) bool {
+	for _, value := range slice {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges map[int][]int
+		jobID int
+		want  []int
+	}{
+		{name: "no dependencies", edges: map[int][]int{}, jobID: 1},
+		{name: "chain", edges: map[int][]int{1: {2}, 2: {3}}, jobID: 1},
+		{name: "diamond", edges: map[int][]int{1: {2, 3}, 2: {4}, 3: {4}}, jobID: 1},
+		{name: "direct cycle", edges: map[int][]int{1: {2}, 2: {1}}, jobID: 1, want: []int{1, 2, 1}},
+		{name: "longer cycle", edges: map[int][]int{1: {2}, 2: {3}, 3: {1}}, jobID: 1, want: []int{1, 2, 3, 1}},
+		{name: "cycle behind a dead end", edges: map[int][]int{1: {2, 3}, 2: {4}, 3: {1}}, jobID: 1, want: []int{1, 3, 1}},
+		{name: "cycle not through the job", edges: map[int][]int{1: {2}, 2: {3}, 3: {2}}, jobID: 1},
+		{name: "cycle elsewhere in the graph", edges: map[int][]int{1: {2}, 3: {4}, 4: {3}}, jobID: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findDependencyCycle(tt.edges, tt.jobID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findDependencyCycle(%d) = %v, want %v", tt.jobID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependsOnFromJobData(t *testing.T) {
+	tests := []struct {
+		name        string
+		jobData     map[string]interface{}
+		want        []int
+		wantPresent bool
+		wantErr     bool
+	}{
+		{name: "missing", jobData: map[string]interface{}{}, want: []int{}},
+		{name: "null removes all", jobData: map[string]interface{}{"depends_on": nil}, want: []int{}, wantPresent: true},
+		{name: "empty list", jobData: map[string]interface{}{"depends_on": []interface{}{}}, want: []int{}, wantPresent: true},
+		{name: "sorted and deduplicated", jobData: map[string]interface{}{"depends_on": []interface{}{float64(3), "1", float64(3)}}, want: []int{1, 3}, wantPresent: true},
+		{name: "not a list", jobData: map[string]interface{}{"depends_on": "1"}, wantPresent: true, wantErr: true},
+		{name: "fraction", jobData: map[string]interface{}{"depends_on": []interface{}{1.5}}, wantPresent: true, wantErr: true},
+		{name: "zero", jobData: map[string]interface{}{"depends_on": []interface{}{float64(0)}}, wantPresent: true, wantErr: true},
+		{name: "not a number", jobData: map[string]interface{}{"depends_on": []interface{}{"abc"}}, wantPresent: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, present, err := dependsOnFromJobData(tt.jobData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if present != tt.wantPresent {
+				t.Errorf("present = %v, want %v", present, tt.wantPresent)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("depends_on = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
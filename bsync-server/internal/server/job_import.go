@@ -0,0 +1,729 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-server/internal/models"
+	"bsync-server/pkg/protocol"
+
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v2"
+)
+
+// jobDocumentVersion is the version of the YAML job document
+const jobDocumentVersion = 1
+
+// jobDocumentSizeLimit bounds the size of an imported job document
+const jobDocumentSizeLimit = 4 << 20
+
+// Plan actions of a job import
+const (
+	planCreate = "create"
+	planUpdate = "update"
+	planDelete = "delete"
+)
+
+// JobDocument is the declarative form of all sync jobs, kept as YAML in version control.
+// Jobs are matched by name; mirror jobs are not part of the document.
+type JobDocument struct {
+	Version int           `yaml:"version" json:"version"`
+	Jobs    []DeclaredJob `yaml:"jobs" json:"jobs"`
+}
+
+// DeclaredJob is one sync job of a job document. Settings not in the document (bandwidth limit,
+// versioning, guards, hooks, ...) are managed through the job API and left unchanged by an import.
+type DeclaredJob struct {
+	Name           string             `yaml:"name" json:"name"`
+	Source         DeclaredEndpoint   `yaml:"source" json:"source"`
+	Destinations   []DeclaredEndpoint `yaml:"destinations" json:"destinations"`
+	SyncType       string             `yaml:"sync_type,omitempty" json:"sync_type,omitempty"`
+	RescanInterval *int               `yaml:"rescan_interval,omitempty" json:"rescan_interval,omitempty"`
+	IgnorePatterns []string           `yaml:"ignore_patterns,omitempty" json:"ignore_patterns,omitempty"`
+	Schedule       *DeclaredSchedule  `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+}
+
+// DeclaredEndpoint is the agent and folder of a job source or destination
+type DeclaredEndpoint struct {
+	AgentID string `yaml:"agent_id" json:"agent_id"`
+	Path    string `yaml:"path" json:"path"`
+}
+
+// DeclaredSchedule is the schedule of a job; jobs without one sync continuously
+type DeclaredSchedule struct {
+	Type           string `yaml:"type" json:"type"`
+	CronExpression string `yaml:"cron_expression,omitempty" json:"cron_expression,omitempty"`
+	Timezone       string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// normalize validates a declared job and fills in the defaults of a new job, so declared and
+// stored jobs can be compared field by field
+func (job *DeclaredJob) normalize() error {
+	job.Name = strings.TrimSpace(job.Name)
+	job.Source.AgentID = strings.TrimSpace(job.Source.AgentID)
+	job.Source.Path = strings.TrimSpace(job.Source.Path)
+
+	if job.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if job.Source.AgentID == "" || job.Source.Path == "" {
+		return fmt.Errorf("source.agent_id and source.path are required")
+	}
+	if len(job.Destinations) == 0 {
+		return fmt.Errorf("at least one destination is required")
+	}
+	seen := map[string]bool{job.Source.AgentID: true}
+	for i := range job.Destinations {
+		dest := &job.Destinations[i]
+		dest.AgentID = strings.TrimSpace(dest.AgentID)
+		dest.Path = strings.TrimSpace(dest.Path)
+		if dest.AgentID == "" || dest.Path == "" {
+			return fmt.Errorf("destination %d: agent_id and path are required", i+1)
+		}
+		if seen[dest.AgentID] {
+			return fmt.Errorf("destination %d: agent %s is already part of the job", i+1, dest.AgentID)
+		}
+		seen[dest.AgentID] = true
+	}
+	sort.Slice(job.Destinations, func(i, j int) bool { return job.Destinations[i].AgentID < job.Destinations[j].AgentID })
+
+	switch job.SyncType {
+	case "":
+		job.SyncType = "sendreceive"
+	case "sendreceive", "sendonly", "receiveonly":
+	default:
+		return fmt.Errorf("sync_type must be sendreceive, sendonly or receiveonly")
+	}
+
+	if job.RescanInterval == nil {
+		interval := 3600
+		job.RescanInterval = &interval
+	} else if *job.RescanInterval < 0 {
+		return fmt.Errorf("rescan_interval must not be negative")
+	}
+
+	var patterns []string
+	for _, pattern := range job.IgnorePatterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	job.IgnorePatterns = patterns
+
+	if job.Schedule != nil {
+		schedule, err := ParseJobSchedule(job.Schedule.Type, job.Schedule.CronExpression, job.Schedule.Timezone)
+		if err != nil {
+			return fmt.Errorf("schedule: %v", err)
+		}
+		job.Schedule = declaredSchedule(schedule)
+	}
+	return nil
+}
+
+// jobSchedule returns the parsed schedule of a normalized job
+func (job *DeclaredJob) jobSchedule() *JobSchedule {
+	if job.Schedule == nil {
+		schedule, _ := ParseJobSchedule(ScheduleContinuous, "", "")
+		return schedule
+	}
+	schedule, _ := ParseJobSchedule(job.Schedule.Type, job.Schedule.CronExpression, job.Schedule.Timezone)
+	return schedule
+}
+
+// destinationMaps returns the destinations in the form taken by the deploy functions
+func (job *DeclaredJob) destinationMaps() []map[string]interface{} {
+	destinations := make([]map[string]interface{}, 0, len(job.Destinations))
+	for _, dest := range job.Destinations {
+		destinations = append(destinations, map[string]interface{}{
+			"agent_id": dest.AgentID,
+			"path":     dest.Path,
+		})
+	}
+	return destinations
+}
+
+// agentIDs returns the source and destination agents of a job
+func (job *DeclaredJob) agentIDs() []string {
+	agentIDs := []string{job.Source.AgentID}
+	for _, dest := range job.Destinations {
+		agentIDs = append(agentIDs, dest.AgentID)
+	}
+	return agentIDs
+}
+
+// declaredSchedule converts a schedule into its document form; continuous jobs have none
+func declaredSchedule(schedule *JobSchedule) *DeclaredSchedule {
+	if schedule == nil || schedule.Type == ScheduleContinuous {
+		return nil
+	}
+	return &DeclaredSchedule{
+		Type:           schedule.Type,
+		CronExpression: schedule.CronExpression,
+		Timezone:       schedule.Timezone,
+	}
+}
+
+func (sched *DeclaredSchedule) String() string {
+	if sched == nil {
+		return ScheduleContinuous
+	}
+	if sched.CronExpression != "" {
+		return fmt.Sprintf("%s %q (%s)", sched.Type, sched.CronExpression, sched.Timezone)
+	}
+	return fmt.Sprintf("%s (%s)", sched.Type, sched.Timezone)
+}
+
+// storedJob is a sync job as currently stored, in document form
+type storedJob struct {
+	id     int
+	status string
+	job    DeclaredJob
+}
+
+// loadStoredJobs loads all sync jobs (mirror jobs excluded) in document form
+func (s *SyncToolServer) loadStoredJobs() ([]*storedJob, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, status, source_agent_id, source_path, COALESCE(target_agent_id, ''), COALESCE(target_path, ''), sync_type,
+		       COALESCE(rescan_interval, 3600), ignore_patterns, COALESCE(schedule_type, 'continuous'), COALESCE(cron_expression, ''), COALESCE(timezone, 'UTC')
+		FROM sync_jobs
+		WHERE COALESCE(job_type, 'sync') = 'sync'
+		ORDER BY name, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync jobs: %w", err)
+	}
+
+	var jobs []*storedJob
+	byID := make(map[int]*storedJob)
+	legacyTargets := make(map[int]DeclaredEndpoint)
+	for rows.Next() {
+		stored := &storedJob{}
+		var targetAgentID, targetPath, scheduleType, cronExpression, timezone string
+		var rescanInterval int
+		var ignorePatterns pq.StringArray
+		if err := rows.Scan(&stored.id, &stored.job.Name, &stored.status, &stored.job.Source.AgentID, &stored.job.Source.Path,
+			&targetAgentID, &targetPath, &stored.job.SyncType, &rescanInterval, &ignorePatterns,
+			&scheduleType, &cronExpression, &timezone); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan sync job: %w", err)
+		}
+		stored.job.RescanInterval = &rescanInterval
+		stored.job.IgnorePatterns = []string(ignorePatterns)
+		if schedule, err := ParseJobSchedule(scheduleType, cronExpression, timezone); err == nil {
+			stored.job.Schedule = declaredSchedule(schedule)
+		} else {
+			log.Printf("⚠️ Job %d has an invalid stored schedule, exported as continuous: %v", stored.id, err)
+		}
+		if targetAgentID != "" {
+			legacyTargets[stored.id] = DeclaredEndpoint{AgentID: targetAgentID, Path: targetPath}
+		}
+		jobs = append(jobs, stored)
+		byID[stored.id] = stored
+	}
+	rows.Close()
+
+	destRows, err := s.db.Query(`
+		SELECT d.job_id, d.destination_agent_id, d.destination_path
+		FROM sync_job_destinations d
+		JOIN sync_jobs j ON j.id = d.job_id
+		WHERE COALESCE(j.job_type, 'sync') = 'sync'
+		ORDER BY d.job_id, d.destination_agent_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destinations: %w", err)
+	}
+	defer destRows.Close()
+	for destRows.Next() {
+		var jobID int
+		var dest DeclaredEndpoint
+		if err := destRows.Scan(&jobID, &dest.AgentID, &dest.Path); err != nil {
+			return nil, fmt.Errorf("failed to scan destination: %w", err)
+		}
+		if stored := byID[jobID]; stored != nil {
+			stored.job.Destinations = append(stored.job.Destinations, dest)
+		}
+	}
+
+	// Jobs created before multi-destination support only have the target columns
+	for _, stored := range jobs {
+		if len(stored.job.Destinations) == 0 {
+			if target, ok := legacyTargets[stored.id]; ok {
+				stored.job.Destinations = []DeclaredEndpoint{target}
+			}
+		}
+		stored.job.normalize()
+	}
+	return jobs, destRows.Err()
+}
+
+// JobPlanEntry is one change of an import plan
+type JobPlanEntry struct {
+	Action  string   `json:"action"`
+	Name    string   `json:"name"`
+	JobID   int      `json:"job_id,omitempty"`
+	Changes []string `json:"changes,omitempty"`
+	Status  string   `json:"status,omitempty"` // applied or failed, once the plan is applied
+	Error   string   `json:"error,omitempty"`
+
+	desired *DeclaredJob
+	stored  *storedJob
+}
+
+// planJobImport diffs a job document against the stored jobs. Stored jobs missing from the
+// document are deleted.
+func planJobImport(doc *JobDocument, stored []*storedJob) ([]*JobPlanEntry, int, error) {
+	storedByName := make(map[string]*storedJob)
+	var duplicates []string
+	for _, current := range stored {
+		if storedByName[current.job.Name] != nil {
+			duplicates = append(duplicates, current.job.Name)
+			continue
+		}
+		storedByName[current.job.Name] = current
+	}
+	if len(duplicates) > 0 {
+		return nil, 0, fmt.Errorf("job names must be unique to be managed by a document, rename the existing jobs %s", strings.Join(duplicates, ", "))
+	}
+
+	var plan []*JobPlanEntry
+	unchanged := 0
+	declared := make(map[string]bool)
+	for i := range doc.Jobs {
+		desired := &doc.Jobs[i]
+		declared[desired.Name] = true
+
+		current := storedByName[desired.Name]
+		if current == nil {
+			plan = append(plan, &JobPlanEntry{Action: planCreate, Name: desired.Name, desired: desired})
+			continue
+		}
+		if changes := diffDeclaredJobs(&current.job, desired); len(changes) > 0 {
+			plan = append(plan, &JobPlanEntry{Action: planUpdate, Name: desired.Name, JobID: current.id, Changes: changes, desired: desired, stored: current})
+		} else {
+			unchanged++
+		}
+	}
+	for _, current := range stored {
+		if !declared[current.job.Name] {
+			plan = append(plan, &JobPlanEntry{Action: planDelete, Name: current.job.Name, JobID: current.id, stored: current})
+		}
+	}
+
+	// Deletions first so a renamed job can take over the folders of the old one
+	order := map[string]int{planDelete: 0, planUpdate: 1, planCreate: 2}
+	sort.SliceStable(plan, func(i, j int) bool { return order[plan[i].Action] < order[plan[j].Action] })
+	return plan, unchanged, nil
+}
+
+// diffDeclaredJobs describes the differences between two normalized jobs
+func diffDeclaredJobs(current, desired *DeclaredJob) []string {
+	var changes []string
+	if current.Source != desired.Source {
+		changes = append(changes, fmt.Sprintf("source: %s:%s → %s:%s", current.Source.AgentID, current.Source.Path, desired.Source.AgentID, desired.Source.Path))
+	}
+
+	currentDests := make(map[string]string)
+	for _, dest := range current.Destinations {
+		currentDests[dest.AgentID] = dest.Path
+	}
+	desiredDests := make(map[string]bool)
+	for _, dest := range desired.Destinations {
+		desiredDests[dest.AgentID] = true
+		if path, ok := currentDests[dest.AgentID]; !ok {
+			changes = append(changes, fmt.Sprintf("destination added: %s:%s", dest.AgentID, dest.Path))
+		} else if path != dest.Path {
+			changes = append(changes, fmt.Sprintf("destination %s: %s → %s", dest.AgentID, path, dest.Path))
+		}
+	}
+	for _, dest := range current.Destinations {
+		if !desiredDests[dest.AgentID] {
+			changes = append(changes, fmt.Sprintf("destination removed: %s:%s", dest.AgentID, dest.Path))
+		}
+	}
+
+	if current.SyncType != desired.SyncType {
+		changes = append(changes, fmt.Sprintf("sync_type: %s → %s", current.SyncType, desired.SyncType))
+	}
+	if *current.RescanInterval != *desired.RescanInterval {
+		changes = append(changes, fmt.Sprintf("rescan_interval: %d → %d", *current.RescanInterval, *desired.RescanInterval))
+	}
+	if strings.Join(current.IgnorePatterns, "\n") != strings.Join(desired.IgnorePatterns, "\n") {
+		changes = append(changes, fmt.Sprintf("ignore_patterns: %q → %q", current.IgnorePatterns, desired.IgnorePatterns))
+	}
+	if current.Schedule.String() != desired.Schedule.String() {
+		changes = append(changes, fmt.Sprintf("schedule: %s → %s", current.Schedule, desired.Schedule))
+	}
+	return changes
+}
+
+// jobPlanID identifies a plan, so a confirmed import only applies the plan that was reviewed
+func jobPlanID(plan []*JobPlanEntry) string {
+	data, _ := json.Marshal(plan)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseJobDocument reads and validates a YAML (or JSON) job document
+func parseJobDocument(data []byte) (*JobDocument, error) {
+	doc := &JobDocument{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != jobDocumentVersion {
+		return nil, fmt.Errorf("version must be %d", jobDocumentVersion)
+	}
+
+	names := make(map[string]bool)
+	for i := range doc.Jobs {
+		job := &doc.Jobs[i]
+		if err := job.normalize(); err != nil {
+			if job.Name != "" {
+				return nil, fmt.Errorf("job %q: %v", job.Name, err)
+			}
+			return nil, fmt.Errorf("job %d: %v", i+1, err)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("job %q is declared more than once", job.Name)
+		}
+		names[job.Name] = true
+	}
+	return doc, nil
+}
+
+// applyJobPlan applies a plan with the deploy functions; entries are applied independently and
+// each records whether it succeeded
+func (s *SyncToolServer) applyJobPlan(plan []*JobPlanEntry) {
+	for _, entry := range plan {
+		var err error
+		switch entry.Action {
+		case planDelete:
+			err = s.applyJobDelete(entry)
+		case planUpdate:
+			err = s.applyJobUpdate(entry)
+		case planCreate:
+			err = s.applyJobCreate(entry)
+		}
+
+		if err != nil {
+			log.Printf("❌ Import: failed to %s job %s: %v", entry.Action, entry.Name, err)
+			entry.Status, entry.Error = "failed", err.Error()
+			continue
+		}
+		log.Printf("✅ Import: %s job %s (ID %d)", entry.Action, entry.Name, entry.JobID)
+		entry.Status = "applied"
+	}
+}
+
+// applyJobDelete removes a job from its agents and the database
+func (s *SyncToolServer) applyJobDelete(entry *JobPlanEntry) error {
+	jobID := strconv.Itoa(entry.JobID)
+	if err := s.deleteJobOnAgentsSync(jobID); err != nil {
+		return fmt.Errorf("failed to delete job from agents: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM sync_jobs WHERE id = $1`, entry.JobID); err != nil {
+		return fmt.Errorf("failed to delete sync job from database: %v", err)
+	}
+
+	folderID := fmt.Sprintf("job-%d", entry.JobID)
+	for _, agentID := range entry.stored.job.agentIDs() {
+		s.clearFolderStatsForFolder(agentID, folderID)
+	}
+	return nil
+}
+
+// applyJobUpdate saves the declared settings and destinations of a job and redeploys it; agents
+// that are no longer part of the job remove its folder
+func (s *SyncToolServer) applyJobUpdate(entry *JobPlanEntry) error {
+	desired := entry.desired
+	jobID := strconv.Itoa(entry.JobID)
+
+	schedule := desired.jobSchedule()
+	var nextScheduledRun *time.Time
+	if schedule.IsScheduled() {
+		next := schedule.Next(nil)
+		nextScheduledRun = &next
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE sync_jobs
+		SET source_agent_id = $1, source_path = $2, target_agent_id = NULL, target_path = NULL, is_multi_destination = true,
+		    sync_type = $3, rescan_interval = $4, ignore_patterns = $5, schedule_type = $6, cron_expression = $7, timezone = $8,
+		    next_scheduled_run = $9, updated_at = NOW()
+		WHERE id = $10
+	`, desired.Source.AgentID, desired.Source.Path, desired.SyncType, *desired.RescanInterval, pq.Array(desired.IgnorePatterns),
+		schedule.Type, nullIfEmpty(schedule.CronExpression), schedule.Timezone, nextScheduledRun, entry.JobID); err != nil {
+		return fmt.Errorf("failed to update sync job: %v", err)
+	}
+
+	var destAgentIDs []string
+	for _, dest := range desired.Destinations {
+		destAgentIDs = append(destAgentIDs, dest.AgentID)
+		result, err := tx.Exec(`
+			UPDATE sync_job_destinations SET destination_path = $3, updated_at = NOW()
+			WHERE job_id = $1 AND destination_agent_id = $2
+		`, entry.JobID, dest.AgentID, dest.Path)
+		if err != nil {
+			return fmt.Errorf("failed to update destination %s: %v", dest.AgentID, err)
+		}
+		if updated, _ := result.RowsAffected(); updated == 0 {
+			if _, err := tx.Exec(`
+				INSERT INTO sync_job_destinations (job_id, destination_agent_id, destination_path, status)
+				VALUES ($1, $2, $3, $4)
+			`, entry.JobID, dest.AgentID, dest.Path, "active"); err != nil {
+				return fmt.Errorf("failed to add destination %s: %v", dest.AgentID, err)
+			}
+		}
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM sync_job_destinations WHERE job_id = $1 AND NOT (destination_agent_id = ANY($2))
+	`, entry.JobID, pq.Array(destAgentIDs)); err != nil {
+		return fmt.Errorf("failed to remove destinations: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// Agents dropped from the job, including a previous source, remove its folder
+	deleteConfig := protocol.ToMap(protocol.NewJobControl(protocol.TypeDeleteJob, jobID, ""))
+	remaining := desired.agentIDs()
+	for _, agentID := range entry.stored.job.agentIDs() {
+		if contains(remaining, agentID) {
+			continue
+		}
+		if err := s.sendJobToAgentSync(agentID, deleteConfig); err != nil {
+			log.Printf("⚠️ Import: failed to remove job %s from agent %s: %v (continuing anyway)", jobID, agentID, err)
+		}
+		s.clearFolderStatsForFolder(agentID, "job-"+jobID)
+	}
+
+	if err := s.redeployJobToAgents(jobID); err != nil {
+		return fmt.Errorf("job updated in database but failed to re-deploy to agents: %v", err)
+	}
+	return nil
+}
+
+// applyJobCreate saves a declared job and deploys it; the job is removed again if the deployment fails
+func (s *SyncToolServer) applyJobCreate(entry *JobPlanEntry) error {
+	desired := entry.desired
+	destinations := desired.destinationMaps()
+
+	if s.config.DiskGuard.CheckBeforeDeploy {
+		if _, err := s.checkDeployCapacity("", desired.Source.AgentID, desired.Source.Path, destinations, s.effectiveDiskGuard(nil)); err != nil {
+			return fmt.Errorf("capacity check failed: %v", err)
+		}
+	}
+
+	schedule := desired.jobSchedule()
+	var nextScheduledRun *time.Time
+	if schedule.IsScheduled() {
+		next := schedule.Next(nil)
+		nextScheduledRun = &next
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var jobID int
+	err = tx.QueryRow(`
+		INSERT INTO sync_jobs (name, source_agent_id, source_path, sync_type, status, rescan_interval, ignore_patterns, schedule_type,
+		                       is_multi_destination, cron_expression, timezone, next_scheduled_run, job_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, true, $9, $10, $11, $12)
+		RETURNING id
+	`, desired.Name, desired.Source.AgentID, desired.Source.Path, desired.SyncType, "active", *desired.RescanInterval,
+		pq.Array(desired.IgnorePatterns), schedule.Type, nullIfEmpty(schedule.CronExpression), schedule.Timezone, nextScheduledRun,
+		JobTypeSync).Scan(&jobID)
+	if err != nil {
+		return fmt.Errorf("failed to save sync job to database: %v", err)
+	}
+
+	for _, dest := range desired.Destinations {
+		if _, err := tx.Exec(`
+			INSERT INTO sync_job_destinations (job_id, destination_agent_id, destination_path, status)
+			VALUES ($1, $2, $3, $4)
+		`, jobID, dest.AgentID, dest.Path, "active"); err != nil {
+			return fmt.Errorf("failed to save destination to database: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	entry.JobID = jobID
+
+	if err := s.deployJobToAgentsSyncMulti(strconv.Itoa(jobID), desired.Name, desired.Source.AgentID, desired.Source.Path,
+		destinations, desired.SyncType, *desired.RescanInterval, desired.IgnorePatterns); err != nil {
+		s.db.Exec(`DELETE FROM sync_jobs WHERE id = $1`, jobID)
+		entry.JobID = 0
+		return fmt.Errorf("failed to deploy job to agents: %v", err)
+	}
+	return nil
+}
+
+// handleJobExport writes all sync jobs as a YAML job document
+// GET /api/v1/sync-jobs/export
+func (s *SyncToolServer) handleJobExport(w http.ResponseWriter, r *http.Request) {
+	stored, err := s.loadStoredJobs()
+	if err != nil {
+		log.Printf("❌ Failed to export sync jobs: %v", err)
+		http.Error(w, `{"error": "Failed to export sync jobs"}`, http.StatusInternalServerError)
+		return
+	}
+
+	doc := &JobDocument{Version: jobDocumentVersion, Jobs: []DeclaredJob{}}
+	for _, current := range stored {
+		doc.Jobs = append(doc.Jobs, current.job)
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		log.Printf("❌ Failed to encode job document: %v", err)
+		http.Error(w, `{"error": "Failed to export sync jobs"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="sync-jobs.yaml"`)
+	w.Write(data)
+}
+
+// handleJobImport diffs a YAML job document against the stored jobs and returns the plan.
+// POST /api/v1/sync-jobs/import?dry_run=true - Plan only (the default)
+// POST /api/v1/sync-jobs/import?confirm=true&plan_id=... - Apply the plan, only if it still matches plan_id
+func (s *SyncToolServer) handleJobImport(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, jobDocumentSizeLimit+1))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to read job document"}`, http.StatusBadRequest)
+		return
+	}
+	if len(data) > jobDocumentSizeLimit {
+		http.Error(w, `{"error": "Job document too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	doc, err := parseJobDocument(data)
+	if err != nil {
+		writeJobDocumentError(w, err)
+		return
+	}
+
+	confirm := r.URL.Query().Get("confirm") == "true" && r.URL.Query().Get("dry_run") != "true"
+
+	// Jobs missing from the document are deleted, so only a reviewed plan is applied
+	expectedPlanID := r.URL.Query().Get("plan_id")
+	if confirm && expectedPlanID == "" {
+		http.Error(w, `{"error": "plan_id is required to apply an import, make a dry run first"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Imports are serialized so a plan is applied against the state it was made from
+	s.jobImportMu.Lock()
+	defer s.jobImportMu.Unlock()
+
+	stored, err := s.loadStoredJobs()
+	if err != nil {
+		log.Printf("❌ Failed to load sync jobs for import: %v", err)
+		http.Error(w, `{"error": "Failed to load sync jobs"}`, http.StatusInternalServerError)
+		return
+	}
+
+	plan, unchanged, err := planJobImport(doc, stored)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	planID := jobPlanID(plan)
+
+	summary := map[string]int{planCreate: 0, planUpdate: 0, planDelete: 0, "unchanged": unchanged}
+	for _, entry := range plan {
+		summary[entry.Action]++
+	}
+
+	response := map[string]interface{}{
+		"dry_run": !confirm,
+		"plan_id": planID,
+		"summary": summary,
+		"plan":    plan,
+	}
+
+	if !confirm {
+		if len(plan) > 0 {
+			response["hint"] = "apply the plan with POST /api/v1/sync-jobs/import?confirm=true&plan_id=" + planID
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if expectedPlanID != planID {
+		response["dry_run"] = true
+		response["error"] = "The jobs changed since the plan was made, review the new plan"
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	log.Printf("📋 Importing job document by %s: %d create, %d update, %d delete",
+		requestUsername(r), summary[planCreate], summary[planUpdate], summary[planDelete])
+	s.applyJobPlan(plan)
+
+	failed := 0
+	for _, entry := range plan {
+		if entry.Status != "applied" {
+			failed++
+		}
+	}
+	response["success"] = failed == 0
+	response["message"] = fmt.Sprintf("Applied %d of %d change(s)", len(plan)-failed, len(plan))
+
+	if failed > 0 {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleJobDocument routes export and import of job documents, both admin only
+func (s *SyncToolServer) handleJobDocument(w http.ResponseWriter, r *http.Request, action string) {
+	if claims, ok := s.getUserClaims(r); ok && claims.Role != models.RoleAdmin {
+		s.writeJSONError(w, http.StatusForbidden, "Access denied: Admin only")
+		return
+	}
+
+	switch {
+	case action == "export" && r.Method == "GET":
+		s.handleJobExport(w, r)
+	case action == "import" && r.Method == "POST":
+		s.handleJobImport(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJobDocumentError writes a 400 response for an invalid job document
+func writeJobDocumentError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid job document: %v", err),
+	})
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testJobDocument parses a job document or fails the test
+func testJobDocument(t *testing.T, yaml string) *JobDocument {
+	t.Helper()
+	doc, err := parseJobDocument([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// testStoredJobs returns the jobs of a document as stored jobs with ids starting at 1
+func testStoredJobs(t *testing.T, yaml string) []*storedJob {
+	t.Helper()
+	var stored []*storedJob
+	for i, job := range testJobDocument(t, yaml).Jobs {
+		stored = append(stored, &storedJob{id: i + 1, status: "active", job: job})
+	}
+	return stored
+}
+
+const storedJobsYAML = `
+version: 1
+jobs:
+  - name: photos
+    source: {agent_id: nas, path: /photos}
+    destinations: [{agent_id: backup, path: /backup/photos}]
+  - name: documents
+    source: {agent_id: nas, path: /documents}
+    destinations: [{agent_id: backup, path: /backup/documents}]
+    schedule: {type: cron, cron_expression: "0 2 * * *", timezone: UTC}
+`
+
+func TestParseJobDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{name: "valid", yaml: storedJobsYAML},
+		{name: "empty", yaml: "version: 1\njobs: []\n"},
+		{name: "wrong version", yaml: "version: 2\njobs: []\n", wantErr: true},
+		{name: "unknown field", yaml: "version: 1\njobs:\n  - name: a\n    hooks: {}\n", wantErr: true},
+		{name: "missing source", yaml: "version: 1\njobs:\n  - name: a\n    destinations: [{agent_id: b, path: /b}]\n", wantErr: true},
+		{name: "no destination", yaml: "version: 1\njobs:\n  - name: a\n    source: {agent_id: a, path: /a}\n", wantErr: true},
+		{name: "source as destination", yaml: "version: 1\njobs:\n  - name: a\n    source: {agent_id: a, path: /a}\n    destinations: [{agent_id: a, path: /b}]\n", wantErr: true},
+		{name: "duplicate name", yaml: "version: 1\njobs:\n  - {name: a, source: {agent_id: a, path: /a}, destinations: [{agent_id: b, path: /b}]}\n  - {name: a, source: {agent_id: a, path: /c}, destinations: [{agent_id: b, path: /d}]}\n", wantErr: true},
+		{name: "invalid sync type", yaml: "version: 1\njobs:\n  - {name: a, sync_type: both, source: {agent_id: a, path: /a}, destinations: [{agent_id: b, path: /b}]}\n", wantErr: true},
+		{name: "invalid schedule", yaml: "version: 1\njobs:\n  - {name: a, schedule: {type: cron, cron_expression: nope}, source: {agent_id: a, path: /a}, destinations: [{agent_id: b, path: /b}]}\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJobDocument([]byte(tt.yaml))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseJobDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	doc := testJobDocument(t, "version: 1\njobs:\n  - name: ' a '\n    source: {agent_id: a, path: /a}\n    destinations: [{agent_id: c, path: /c}, {agent_id: b, path: /b}]\n    ignore_patterns: [' *.tmp ', '']\n")
+	job := doc.Jobs[0]
+	if job.Name != "a" || job.SyncType != "sendreceive" || *job.RescanInterval != 3600 || job.Schedule != nil {
+		t.Errorf("defaults not applied: %+v", job)
+	}
+	if job.Destinations[0].AgentID != "b" || !reflect.DeepEqual(job.IgnorePatterns, []string{"*.tmp"}) {
+		t.Errorf("destinations %v and ignore_patterns %q not normalized", job.Destinations, job.IgnorePatterns)
+	}
+}
+
+func TestPlanJobImport(t *testing.T) {
+	tests := []struct {
+		name          string
+		yaml          string
+		wantActions   []string
+		wantChanges   [][]string
+		wantUnchanged int
+	}{
+		{name: "unchanged", yaml: storedJobsYAML, wantUnchanged: 2},
+		{
+			name: "create, update and delete",
+			yaml: `
+version: 1
+jobs:
+  - name: music
+    source: {agent_id: nas, path: /music}
+    destinations: [{agent_id: backup, path: /backup/music}]
+  - name: photos
+    source: {agent_id: nas, path: /photos}
+    destinations: [{agent_id: backup, path: /backup/pictures}, {agent_id: offsite, path: /photos}]
+    rescan_interval: 60
+`,
+			wantActions: []string{planDelete, planUpdate, planCreate},
+			wantChanges: [][]string{nil, {
+				"destination backup: /backup/photos → /backup/pictures",
+				"destination added: offsite:/photos",
+				"rescan_interval: 3600 → 60",
+			}, nil},
+		},
+		{
+			name:          "schedule removed",
+			yaml:          "version: 1\njobs:\n  - {name: photos, source: {agent_id: nas, path: /photos}, destinations: [{agent_id: backup, path: /backup/photos}]}\n  - {name: documents, source: {agent_id: nas, path: /documents}, destinations: [{agent_id: backup, path: /backup/documents}]}\n",
+			wantActions:   []string{planUpdate},
+			wantChanges:   [][]string{{`schedule: cron "0 2 * * *" (UTC) → continuous`}},
+			wantUnchanged: 1,
+		},
+		{name: "empty document deletes all", yaml: "version: 1\njobs: []\n", wantActions: []string{planDelete, planDelete}, wantChanges: [][]string{nil, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, unchanged, err := planJobImport(testJobDocument(t, tt.yaml), testStoredJobs(t, storedJobsYAML))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unchanged != tt.wantUnchanged {
+				t.Errorf("unchanged = %d, want %d", unchanged, tt.wantUnchanged)
+			}
+			var actions []string
+			var changes [][]string
+			for _, entry := range plan {
+				actions = append(actions, entry.Action)
+				changes = append(changes, entry.Changes)
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) || !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("plan = %v %q, want %v %q", actions, changes, tt.wantActions, tt.wantChanges)
+			}
+		})
+	}
+
+	t.Run("duplicate stored names", func(t *testing.T) {
+		stored := testStoredJobs(t, storedJobsYAML)
+		stored = append(stored, &storedJob{id: 3, job: stored[0].job})
+		if _, _, err := planJobImport(testJobDocument(t, storedJobsYAML), stored); err == nil {
+			t.Error("planJobImport() accepted two stored jobs with the same name")
+		}
+	})
+}
+
+func TestJobPlanID(t *testing.T) {
+	const changed = "version: 1\njobs:\n  - {name: photos, rescan_interval: 60, source: {agent_id: nas, path: /photos}, destinations: [{agent_id: backup, path: /backup/photos}]}\n"
+
+	planID := func(yaml string) string {
+		plan, _, err := planJobImport(testJobDocument(t, yaml), testStoredJobs(t, storedJobsYAML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return jobPlanID(plan)
+	}
+
+	first := planID(changed)
+	if len(first) != 64 {
+		t.Fatalf("jobPlanID() = %q, want a sha256 hex digest", first)
+	}
+	if again := planID(changed); again != first {
+		t.Errorf("jobPlanID() of the same plan = %s, then %s", first, again)
+	}
+	// Order of the destinations in the document does not change the plan
+	reordered := "version: 1\njobs:\n  - {name: photos, rescan_interval: 60, source: {agent_id: nas, path: /photos}, destinations: [{agent_id: x, path: /x}, {agent_id: backup, path: /backup/photos}]}\n"
+	swapped := "version: 1\njobs:\n  - {name: photos, rescan_interval: 60, source: {agent_id: nas, path: /photos}, destinations: [{agent_id: backup, path: /backup/photos}, {agent_id: x, path: /x}]}\n"
+	if planID(reordered) != planID(swapped) {
+		t.Error("jobPlanID() depends on the order of the destinations")
+	}
+	if planID(storedJobsYAML) == first || planID(swapped) == first {
+		t.Error("jobPlanID() is the same for different plans")
+	}
+}
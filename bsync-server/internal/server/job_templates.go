@@ -0,0 +1,760 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-server/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// defaultTemplateJobName names the jobs of a template that does not set job_name
+const defaultTemplateJobName = "{template} ({hostname})"
+
+// Placeholders expanded in the job name and paths of a template. {hostname} and {agent_id} refer to
+// the agent the path belongs to (the source agent in the job name and source path).
+var templatePlaceholders = []string{"{template}", "{hostname}", "{agent_id}", "{source_hostname}", "{source_agent_id}"}
+
+var templatePlaceholderPattern = regexp.MustCompile(`\{[^{}/\\]*\}`)
+
+// templateSettingKeys are the job settings a template holds; agents, paths and the job name belong
+// to the template itself, dependencies and mirror jobs are not templated
+var templateSettingKeys = map[string]bool{
+	"sync_type":       true,
+	"rescan_interval": true,
+	"ignore_patterns": true,
+	"schedule_type":   true,
+	"cron_expression": true,
+	"timezone":        true,
+	"bandwidth_limit": true,
+	"versioning":      true,
+	"disk_guard":      true,
+	"deletion_guard":  true,
+	"verify_schedule": true,
+	"hooks":           true,
+	"conflict_policy": true,
+}
+
+// JobTemplate holds the settings shared by sync jobs. Jobs are created from a template by choosing
+// their agents; the job name and paths are expanded for those agents.
+type JobTemplate struct {
+	ID              int                    `json:"id"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	JobName         string                 `json:"job_name"`
+	SourcePath      string                 `json:"source_path"`
+	DestinationPath string                 `json:"destination_path"`
+	Settings        map[string]interface{} `json:"settings"`
+	CreatedBy       string                 `json:"created_by,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	JobCount        int                    `json:"job_count"`
+}
+
+// prepare validates the template and fills in defaults
+func (t *JobTemplate) prepare() error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.JobName = strings.TrimSpace(t.JobName)
+	t.SourcePath = strings.TrimSpace(t.SourcePath)
+	t.DestinationPath = strings.TrimSpace(t.DestinationPath)
+
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if t.JobName == "" {
+		t.JobName = defaultTemplateJobName
+	}
+	if t.SourcePath == "" {
+		return fmt.Errorf("source_path is required")
+	}
+	if t.DestinationPath == "" {
+		return fmt.Errorf("destination_path is required")
+	}
+	for field, value := range map[string]string{"job_name": t.JobName, "source_path": t.SourcePath, "destination_path": t.DestinationPath} {
+		for _, placeholder := range templatePlaceholderPattern.FindAllString(value, -1) {
+			if !isTemplatePlaceholder(placeholder) {
+				return fmt.Errorf("unknown placeholder %s in %s (expected one of %s)", placeholder, field, strings.Join(templatePlaceholders, ", "))
+			}
+		}
+	}
+
+	if t.Settings == nil {
+		t.Settings = map[string]interface{}{}
+	}
+	if _, err := parseJobTemplateSettings(t.Settings); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isTemplatePlaceholder(placeholder string) bool {
+	for _, known := range templatePlaceholders {
+		if placeholder == known {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces the placeholders of a template value for one agent of a job
+func (t *JobTemplate) expand(value, agentID, hostname, sourceAgentID, sourceHostname string) string {
+	return strings.NewReplacer(
+		"{template}", t.Name,
+		"{hostname}", hostname,
+		"{agent_id}", agentID,
+		"{source_hostname}", sourceHostname,
+		"{source_agent_id}", sourceAgentID,
+	).Replace(value)
+}
+
+// jobTemplateSettings are the parsed settings of a template, as stored in the sync_jobs columns
+type jobTemplateSettings struct {
+	syncType       string
+	rescanInterval int
+	ignorePatterns []string
+	schedule       *JobSchedule
+	bandwidthLimit *BandwidthLimit
+	versioning     *VersioningPolicy
+	diskGuard      *DiskGuardPolicy
+	deletionGuard  *DeletionGuardPolicy
+	verifySchedule *JobSchedule
+	hooks          *JobHooks
+	conflictPolicy *ConflictPolicy
+}
+
+// parseJobTemplateSettings validates the settings of a template with the parsers of the job API.
+// Settings missing from the template get the defaults of a new job.
+func parseJobTemplateSettings(settings map[string]interface{}) (*jobTemplateSettings, error) {
+	var unknown []string
+	for key := range settings {
+		if !templateSettingKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("settings cannot contain %s (agents, paths and names are set by the template and its jobs)", strings.Join(unknown, ", "))
+	}
+
+	parsed := &jobTemplateSettings{syncType: "sendreceive", rescanInterval: 3600}
+	var err error
+
+	if raw, present := settings["sync_type"]; present && raw != nil {
+		syncType, _ := raw.(string)
+		switch syncType {
+		case "sendreceive", "sendonly", "receiveonly":
+			parsed.syncType = syncType
+		default:
+			return nil, fmt.Errorf("sync_type must be sendreceive, sendonly or receiveonly")
+		}
+	}
+	if raw, present := settings["rescan_interval"]; present && raw != nil {
+		interval, ok := raw.(float64)
+		if !ok || interval < 0 {
+			return nil, fmt.Errorf("rescan_interval must be a number of seconds")
+		}
+		parsed.rescanInterval = int(interval)
+	}
+	if raw, present := settings["ignore_patterns"]; present && raw != nil {
+		patterns, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ignore_patterns must be a list of patterns")
+		}
+		for _, p := range patterns {
+			if pattern, ok := p.(string); ok && strings.TrimSpace(pattern) != "" {
+				parsed.ignorePatterns = append(parsed.ignorePatterns, strings.TrimSpace(pattern))
+			}
+		}
+	}
+
+	if parsed.schedule, err = parseScheduleFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("schedule: %v", err)
+	}
+	if parsed.bandwidthLimit, _, err = bandwidthFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("bandwidth_limit: %v", err)
+	}
+	if parsed.versioning, _, err = versioningFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("versioning: %v", err)
+	}
+	if parsed.diskGuard, _, err = diskGuardFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("disk_guard: %v", err)
+	}
+	if parsed.deletionGuard, _, err = deletionGuardFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("deletion_guard: %v", err)
+	}
+	if parsed.verifySchedule, _, err = verifyScheduleFromJobData(settings, parsed.schedule.Timezone); err != nil {
+		return nil, fmt.Errorf("verify_schedule: %v", err)
+	}
+	// Templates are changed by admins only (handleJobTemplates, handleJobTemplateActions), so jobs
+	// created from them may carry hooks even when an operator creates them
+	if parsed.hooks, _, err = hooksFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("hooks: %v", err)
+	}
+	if parsed.conflictPolicy, _, err = conflictPolicyFromJobData(settings); err != nil {
+		return nil, fmt.Errorf("conflict_policy: %v", err)
+	}
+	return parsed, nil
+}
+
+const jobTemplateColumns = `t.id, t.name, t.description, t.job_name, t.source_path, t.destination_path, t.settings,
+	COALESCE(t.created_by, ''), t.created_at, t.updated_at,
+	(SELECT COUNT(*) FROM sync_jobs j WHERE j.template_id = t.id)`
+
+// scanJobTemplate reads a job_templates row selected with jobTemplateColumns
+func scanJobTemplate(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*JobTemplate, error) {
+	t := &JobTemplate{}
+	var settings []byte
+
+	if err := scanner.Scan(&t.ID, &t.Name, &t.Description, &t.JobName, &t.SourcePath, &t.DestinationPath, &settings,
+		&t.CreatedBy, &t.CreatedAt, &t.UpdatedAt, &t.JobCount); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(settings, &t.Settings); err != nil || t.Settings == nil {
+		// Rows are validated on write, so this only happens for hand-edited rows
+		log.Printf("⚠️ Ignoring invalid stored settings of job template %d: %v", t.ID, err)
+		t.Settings = map[string]interface{}{}
+	}
+	return t, nil
+}
+
+// loadJobTemplate loads one template, sql.ErrNoRows if it does not exist
+func (s *SyncToolServer) loadJobTemplate(templateID int) (*JobTemplate, error) {
+	return scanJobTemplate(s.db.QueryRow(`SELECT `+jobTemplateColumns+` FROM job_templates t WHERE t.id = $1`, templateID))
+}
+
+// agentHostname returns the hostname an agent registered with, its ID if it is unknown
+func (s *SyncToolServer) agentHostname(agentID string) string {
+	var hostname sql.NullString
+	s.db.QueryRow(`SELECT hostname FROM integrated_agents WHERE agent_id = $1`, agentID).Scan(&hostname)
+	if !hostname.Valid || strings.TrimSpace(hostname.String) == "" {
+		return agentID
+	}
+	return hostname.String
+}
+
+// templateJobData builds the body of a job create request from a template and the agents of the job
+func (s *SyncToolServer) templateJobData(t *JobTemplate, sourceAgentID string, destinationAgentIDs []string) map[string]interface{} {
+	jobData := make(map[string]interface{}, len(t.Settings)+4)
+	for key, value := range t.Settings {
+		jobData[key] = value
+	}
+
+	sourceHostname := s.agentHostname(sourceAgentID)
+	jobData["name"] = t.expand(t.JobName, sourceAgentID, sourceHostname, sourceAgentID, sourceHostname)
+	jobData["source_agent_id"] = sourceAgentID
+	jobData["source_path"] = t.expand(t.SourcePath, sourceAgentID, sourceHostname, sourceAgentID, sourceHostname)
+
+	destinations := make([]interface{}, 0, len(destinationAgentIDs))
+	for _, agentID := range destinationAgentIDs {
+		destinations = append(destinations, map[string]interface{}{
+			"agent_id": agentID,
+			"path":     t.expand(t.DestinationPath, agentID, s.agentHostname(agentID), sourceAgentID, sourceHostname),
+		})
+	}
+	jobData["destinations"] = destinations
+	return jobData
+}
+
+// propagateJobTemplate applies the settings of a template to the jobs created from it and redeploys them.
+// Names and paths were expanded for the agents of each job when it was created and are left unchanged.
+func (s *SyncToolServer) propagateJobTemplate(t *JobTemplate) ([]map[string]interface{}, error) {
+	settings, err := parseJobTemplateSettings(t.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name FROM sync_jobs
+		WHERE template_id = $1 AND COALESCE(job_type, 'sync') = 'sync'
+		ORDER BY id
+	`, t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs of template: %w", err)
+	}
+	type templateJob struct {
+		id   int
+		name string
+	}
+	var jobs []templateJob
+	for rows.Next() {
+		var job templateJob
+		if err := rows.Scan(&job.id, &job.name); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	rows.Close()
+
+	var nextScheduledRun *time.Time
+	if settings.schedule.IsScheduled() {
+		next := settings.schedule.Next(nil)
+		nextScheduledRun = &next
+	}
+	verifyExpression, nextVerification := verifyScheduleColumns(settings.verifySchedule)
+
+	results := make([]map[string]interface{}, 0, len(jobs))
+	for _, job := range jobs {
+		result := map[string]interface{}{
+			"job_id": job.id,
+			"name":   job.name,
+			"status": "updated",
+		}
+		results = append(results, result)
+
+		_, err := s.db.Exec(`
+			UPDATE sync_jobs
+			SET sync_type = $1, rescan_interval = $2, ignore_patterns = $3, schedule_type = $4, cron_expression = $5, timezone = $6,
+			    next_scheduled_run = $7, bandwidth_limit = $8, versioning = $9, disk_guard = $10, deletion_guard = $11,
+			    verify_schedule = $12, next_verification_at = $13, hooks = $14, conflict_policy = $15, updated_at = NOW()
+			WHERE id = $16
+		`, settings.syncType, settings.rescanInterval, pq.Array(settings.ignorePatterns), settings.schedule.Type,
+			nullIfEmpty(settings.schedule.CronExpression), settings.schedule.Timezone, nextScheduledRun,
+			bandwidthLimitJSON(settings.bandwidthLimit), versioningPolicyJSON(settings.versioning), diskGuardPolicyJSON(settings.diskGuard),
+			deletionGuardPolicyJSON(settings.deletionGuard), verifyExpression, nextVerification,
+			jobHooksJSON(settings.hooks), conflictPolicyJSON(settings.conflictPolicy), job.id)
+		if err != nil {
+			log.Printf("❌ Failed to apply template %d to job %d: %v", t.ID, job.id, err)
+			result["status"], result["error"] = "failed", "Failed to update sync job"
+			continue
+		}
+
+		if err := s.redeployJobToAgents(strconv.Itoa(job.id)); err != nil {
+			log.Printf("❌ Failed to re-deploy job %d after template %d changed: %v", job.id, t.ID, err)
+			result["status"], result["error"] = "deploy_failed", err.Error()
+			continue
+		}
+		log.Printf("✅ Template %d (%s) applied to job %d (%s)", t.ID, t.Name, job.id, job.name)
+	}
+	return results, nil
+}
+
+// handleJobTemplates handles job template list and create
+// GET /api/v1/job-templates - List templates
+// POST /api/v1/job-templates - Create template
+func (s *SyncToolServer) handleJobTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.listJobTemplates(w, r)
+	case "POST":
+		s.createJobTemplate(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleJobTemplateActions handles actions on a specific job template
+// GET /api/v1/job-templates/{id} - Get template
+// PUT /api/v1/job-templates/{id} - Update template (?propagate=true applies it to its jobs)
+// DELETE /api/v1/job-templates/{id} - Delete template, its jobs are kept
+// GET /api/v1/job-templates/{id}/jobs - List the jobs created from the template
+// POST /api/v1/job-templates/{id}/jobs - Create a job from the template
+// POST /api/v1/job-templates/{id}/propagate - Apply the template to its jobs and redeploy them
+func (s *SyncToolServer) handleJobTemplateActions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Parse URL path: /api/v1/job-templates/{id}/{action}
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/job-templates/"), "/")
+	if len(pathParts) < 1 || pathParts[0] == "" {
+		http.Error(w, `{"error": "Invalid URL format. Expected: /api/v1/job-templates/{id}"}`, http.StatusBadRequest)
+		return
+	}
+
+	templateID, err := strconv.Atoi(pathParts[0])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid job template ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Every user allowed to create jobs may create them from a template, changing templates is for admins
+	creatingJob := len(pathParts) == 2 && pathParts[1] == "jobs" && r.Method == "POST"
+	if r.Method != "GET" && !creatingJob {
+		if claims, ok := s.getUserClaims(r); ok && claims.Role != models.RoleAdmin {
+			s.writeJSONError(w, http.StatusForbidden, "Access denied: Admin only")
+			return
+		}
+	}
+
+	if len(pathParts) == 2 {
+		switch {
+		case pathParts[1] == "jobs" && r.Method == "GET":
+			s.listJobTemplateJobs(w, r, templateID)
+		case pathParts[1] == "jobs" && r.Method == "POST":
+			s.createJobFromTemplate(w, r, templateID)
+		case pathParts[1] == "propagate" && r.Method == "POST":
+			s.propagateJobTemplateRequest(w, r, templateID)
+		case pathParts[1] == "jobs" || pathParts[1] == "propagate":
+			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		default:
+			http.Error(w, fmt.Sprintf(`{"error": "Unknown action: %s"}`, pathParts[1]), http.StatusBadRequest)
+		}
+		return
+	}
+	if len(pathParts) > 2 {
+		http.Error(w, `{"error": "Invalid URL format"}`, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.getJobTemplate(w, r, templateID)
+	case "PUT":
+		s.updateJobTemplate(w, r, templateID)
+	case "DELETE":
+		s.deleteJobTemplate(w, r, templateID)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// listJobTemplates retrieves all templates with the number of jobs created from them
+func (s *SyncToolServer) listJobTemplates(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.Query(`SELECT ` + jobTemplateColumns + ` FROM job_templates t ORDER BY t.name ASC`)
+	if err != nil {
+		log.Printf("❌ Failed to query job templates: %v", err)
+		http.Error(w, `{"error": "Failed to fetch job templates"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	templates := []*JobTemplate{}
+	for rows.Next() {
+		t, err := scanJobTemplate(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan job template: %v", err)
+			continue
+		}
+		templates = append(templates, t)
+	}
+
+	response := map[string]interface{}{
+		"data":         templates,
+		"total":        len(templates),
+		"placeholders": templatePlaceholders,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// createJobTemplate creates a new template
+func (s *SyncToolServer) createJobTemplate(w http.ResponseWriter, r *http.Request) {
+	t := &JobTemplate{}
+	if err := json.NewDecoder(r.Body).Decode(t); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := t.prepare(); err != nil {
+		writeJobTemplateError(w, err)
+		return
+	}
+	t.CreatedBy, t.JobCount = requestUsername(r), 0
+
+	settings, _ := json.Marshal(t.Settings)
+	err := s.db.QueryRow(`
+		INSERT INTO job_templates (name, description, job_name, source_path, destination_path, settings, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, t.Name, t.Description, t.JobName, t.SourcePath, t.DestinationPath, string(settings), nullIfEmpty(t.CreatedBy)).
+		Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "uq_job_templates_name") {
+			http.Error(w, `{"error": "A job template with this name already exists"}`, http.StatusConflict)
+		} else {
+			log.Printf("❌ Failed to create job template: %v", err)
+			http.Error(w, `{"error": "Failed to create job template"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Job template created successfully",
+		"data":    t,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Job template created: ID=%d, Name=%s", t.ID, t.Name)
+}
+
+// getJobTemplate retrieves a specific template
+func (s *SyncToolServer) getJobTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	t, err := s.loadJobTemplate(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Job template not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get job template: %v", err)
+		http.Error(w, `{"error": "Failed to get job template"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(t)
+}
+
+// updateJobTemplate updates a template; fields missing from the body keep their current value and
+// settings are replaced as a whole. The jobs of the template only change with ?propagate=true.
+func (s *SyncToolServer) updateJobTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	t, err := s.loadJobTemplate(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Job template not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get job template: %v", err)
+		http.Error(w, `{"error": "Failed to update job template"}`, http.StatusInternalServerError)
+		return
+	}
+
+	currentSettings, createdBy, createdAt, jobCount := t.Settings, t.CreatedBy, t.CreatedAt, t.JobCount
+	t.Settings = nil
+	if err := json.NewDecoder(r.Body).Decode(t); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if t.Settings == nil {
+		t.Settings = currentSettings
+	}
+	t.ID, t.CreatedBy, t.CreatedAt, t.JobCount = templateID, createdBy, createdAt, jobCount
+
+	if err := t.prepare(); err != nil {
+		writeJobTemplateError(w, err)
+		return
+	}
+
+	settings, _ := json.Marshal(t.Settings)
+	err = s.db.QueryRow(`
+		UPDATE job_templates
+		SET name = $1, description = $2, job_name = $3, source_path = $4, destination_path = $5, settings = $6, updated_at = NOW()
+		WHERE id = $7
+		RETURNING updated_at
+	`, t.Name, t.Description, t.JobName, t.SourcePath, t.DestinationPath, string(settings), templateID).Scan(&t.UpdatedAt)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "uq_job_templates_name") {
+			http.Error(w, `{"error": "A job template with this name already exists"}`, http.StatusConflict)
+		} else {
+			log.Printf("❌ Failed to update job template: %v", err)
+			http.Error(w, `{"error": "Failed to update job template"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Job template updated successfully",
+		"data":    t,
+	}
+
+	if r.URL.Query().Get("propagate") == "true" {
+		results, err := s.propagateJobTemplate(t)
+		if err != nil {
+			log.Printf("❌ Failed to propagate job template %d: %v", templateID, err)
+			http.Error(w, `{"error": "Job template updated but failed to propagate it to its jobs"}`, http.StatusInternalServerError)
+			return
+		}
+		response["propagated"] = results
+	} else if t.JobCount > 0 {
+		response["hint"] = fmt.Sprintf("%d job(s) were created from this template, apply the change with POST /api/v1/job-templates/%d/propagate", t.JobCount, templateID)
+	}
+
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Job template updated: ID=%d, Name=%s", templateID, t.Name)
+}
+
+// deleteJobTemplate deletes a template; the jobs created from it are kept as standalone jobs
+func (s *SyncToolServer) deleteJobTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	var detachedJobs int
+	s.db.QueryRow(`SELECT COUNT(*) FROM sync_jobs WHERE template_id = $1`, templateID).Scan(&detachedJobs)
+
+	result, err := s.db.Exec(`DELETE FROM job_templates WHERE id = $1`, templateID)
+	if err != nil {
+		log.Printf("❌ Failed to delete job template: %v", err)
+		http.Error(w, `{"error": "Failed to delete job template"}`, http.StatusInternalServerError)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		http.Error(w, `{"error": "Job template not found"}`, http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":       true,
+		"message":       "Job template deleted successfully",
+		"detached_jobs": detachedJobs,
+	}
+
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Job template deleted: ID=%d, %d job(s) detached", templateID, detachedJobs)
+}
+
+// listJobTemplateJobs lists the jobs created from a template
+func (s *SyncToolServer) listJobTemplateJobs(w http.ResponseWriter, r *http.Request, templateID int) {
+	rows, err := s.db.Query(`
+		SELECT id, name, source_agent_id, source_path, status, updated_at
+		FROM sync_jobs WHERE template_id = $1
+		ORDER BY id
+	`, templateID)
+	if err != nil {
+		log.Printf("❌ Failed to query jobs of template %d: %v", templateID, err)
+		http.Error(w, `{"error": "Failed to fetch jobs of template"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	jobs := []map[string]interface{}{}
+	for rows.Next() {
+		var id int
+		var name, sourceAgentID, sourcePath, status string
+		var updatedAt time.Time
+		if err := rows.Scan(&id, &name, &sourceAgentID, &sourcePath, &status, &updatedAt); err != nil {
+			log.Printf("❌ Failed to scan sync job row: %v", err)
+			continue
+		}
+		jobs = append(jobs, map[string]interface{}{
+			"id":              id,
+			"name":            name,
+			"source_agent_id": sourceAgentID,
+			"source_path":     sourcePath,
+			"status":          status,
+			"updated_at":      updatedAt.Format(time.RFC3339),
+		})
+	}
+
+	response := map[string]interface{}{
+		"template_id": templateID,
+		"data":        jobs,
+		"total":       len(jobs),
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// createJobFromTemplate creates and deploys a job from a template; the body only names the agents:
+// {"source_agent_id": "...", "destination_agent_ids": ["..."], "name": "optional", "skip_capacity_check": false}
+func (s *SyncToolServer) createJobFromTemplate(w http.ResponseWriter, r *http.Request, templateID int) {
+	var request struct {
+		SourceAgentID       string   `json:"source_agent_id"`
+		DestinationAgentIDs []string `json:"destination_agent_ids"`
+		Name                string   `json:"name"`
+		SkipCapacityCheck   bool     `json:"skip_capacity_check"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	request.SourceAgentID = strings.TrimSpace(request.SourceAgentID)
+	if request.SourceAgentID == "" {
+		http.Error(w, `{"error": "Missing or invalid source_agent_id"}`, http.StatusBadRequest)
+		return
+	}
+	var destinationAgentIDs []string
+	for _, agentID := range request.DestinationAgentIDs {
+		agentID = strings.TrimSpace(agentID)
+		if agentID == "" || contains(destinationAgentIDs, agentID) {
+			continue
+		}
+		if agentID == request.SourceAgentID {
+			http.Error(w, `{"error": "The source agent cannot be a destination of its own job"}`, http.StatusBadRequest)
+			return
+		}
+		destinationAgentIDs = append(destinationAgentIDs, agentID)
+	}
+	if len(destinationAgentIDs) == 0 {
+		http.Error(w, `{"error": "At least one destination agent is required in destination_agent_ids"}`, http.StatusBadRequest)
+		return
+	}
+
+	t, err := s.loadJobTemplate(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Job template not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get job template: %v", err)
+		http.Error(w, `{"error": "Failed to get job template"}`, http.StatusInternalServerError)
+		return
+	}
+
+	jobData := s.templateJobData(t, request.SourceAgentID, destinationAgentIDs)
+	if name := strings.TrimSpace(request.Name); name != "" {
+		jobData["name"] = name
+	}
+	jobData["skip_capacity_check"] = request.SkipCapacityCheck
+
+	log.Printf("📋 Creating job %v from template %d (%s)", jobData["name"], t.ID, t.Name)
+	s.createSyncJob(w, r, jobData, &t.ID)
+}
+
+// propagateJobTemplateRequest applies a template to its jobs on demand
+func (s *SyncToolServer) propagateJobTemplateRequest(w http.ResponseWriter, r *http.Request, templateID int) {
+	t, err := s.loadJobTemplate(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Job template not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get job template: %v", err)
+		http.Error(w, `{"error": "Failed to get job template"}`, http.StatusInternalServerError)
+		return
+	}
+
+	results, err := s.propagateJobTemplate(t)
+	if err != nil {
+		log.Printf("❌ Failed to propagate job template %d: %v", templateID, err)
+		http.Error(w, `{"error": "Failed to propagate job template"}`, http.StatusInternalServerError)
+		return
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result["status"] != "updated" {
+			failed++
+		}
+	}
+
+	response := map[string]interface{}{
+		"success":    failed == 0,
+		"message":    fmt.Sprintf("Template applied to %d of %d job(s)", len(results)-failed, len(results)),
+		"propagated": results,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeJobTemplateError writes a 400 response for an invalid template
+func writeJobTemplateError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid job template: %v", err),
+	})
+}
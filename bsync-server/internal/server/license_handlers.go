@@ -14,18 +14,8 @@ import (
 
 // handleLicenses handles CRUD operations for licenses
 // GET /api/v1/licenses - List all licenses
-// POST /api/v1/licenses - Create new license
+// POST /api/v1/licenses - Create new license {"license_key", "expires_at" (optional)}
 func (s *SyncToolServer) handleLicenses(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
 		return
@@ -43,19 +33,9 @@ func (s *SyncToolServer) handleLicenses(w http.ResponseWriter, r *http.Request)
 
 // handleLicenseActions handles actions on specific licenses
 // GET /api/v1/licenses/{id} - Get license by ID
-// PUT /api/v1/licenses/{id} - Update license
+// PUT /api/v1/licenses/{id} - Update license {"license_key", "expires_at" (omitted or null: no expiry)}
 // DELETE /api/v1/licenses/{id} - Delete license
 func (s *SyncToolServer) handleLicenseActions(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
 		return
@@ -89,7 +69,7 @@ func (s *SyncToolServer) handleLicenseActions(w http.ResponseWriter, r *http.Req
 // listLicenses retrieves all licenses
 func (s *SyncToolServer) listLicenses(w http.ResponseWriter, r *http.Request) {
 	rows, err := s.db.Query(`
-		SELECT l.id, l.license_key, l.created_at, l.updated_at,
+		SELECT l.id, l.license_key, l.expires_at, l.created_at, l.updated_at,
 		       al.agent_id
 		FROM licenses l
 		LEFT JOIN agent_licenses al ON l.id = al.license_id
@@ -107,10 +87,11 @@ func (s *SyncToolServer) listLicenses(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var id int
 		var licenseKey string
+		var expiresAt sql.NullTime
 		var createdAt, updatedAt time.Time
 		var agentID sql.NullString
 		
-		if err := rows.Scan(&id, &licenseKey, &createdAt, &updatedAt, &agentID); err != nil {
+		if err := rows.Scan(&id, &licenseKey, &expiresAt, &createdAt, &updatedAt, &agentID); err != nil {
 			log.Printf("❌ Failed to scan license row: %v", err)
 			continue
 		}
@@ -126,6 +107,9 @@ func (s *SyncToolServer) listLicenses(w http.ResponseWriter, r *http.Request) {
 		if agentID.Valid {
 			license["assigned_to"] = agentID.String
 		}
+		if expiresAt.Valid {
+			license["expires_at"] = expiresAt.Time.Format(time.RFC3339)
+		}
 		
 		licenses = append(licenses, license)
 	}
@@ -154,10 +138,10 @@ func (s *SyncToolServer) createLicense(w http.ResponseWriter, r *http.Request) {
 
 	var id int
 	err := s.db.QueryRow(`
-		INSERT INTO licenses (license_key, created_at, updated_at) 
-		VALUES ($1, NOW(), NOW()) 
+		INSERT INTO licenses (license_key, expires_at, created_at, updated_at) 
+		VALUES ($1, $2, NOW(), NOW()) 
 		RETURNING id
-	`, req.LicenseKey).Scan(&id)
+	`, req.LicenseKey, req.ExpiresAt).Scan(&id)
 	
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") {
@@ -174,6 +158,7 @@ func (s *SyncToolServer) createLicense(w http.ResponseWriter, r *http.Request) {
 		"message":     "License created successfully",
 		"id":          id,
 		"license_key": req.LicenseKey,
+		"expires_at":  req.ExpiresAt,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -188,12 +173,12 @@ func (s *SyncToolServer) getLicense(w http.ResponseWriter, r *http.Request, lice
 	var agentID sql.NullString
 	
 	err := s.db.QueryRow(`
-		SELECT l.id, l.license_key, l.created_at, l.updated_at,
+		SELECT l.id, l.license_key, l.expires_at, l.created_at, l.updated_at,
 		       al.agent_id
 		FROM licenses l
 		LEFT JOIN agent_licenses al ON l.id = al.license_id
 		WHERE l.id = $1
-	`, licenseID).Scan(&license.ID, &license.LicenseKey, &license.CreatedAt, &license.UpdatedAt, &agentID)
+	`, licenseID).Scan(&license.ID, &license.LicenseKey, &license.ExpiresAt, &license.CreatedAt, &license.UpdatedAt, &agentID)
 	
 	if err == sql.ErrNoRows {
 		http.Error(w, `{"error": "License not found"}`, http.StatusNotFound)
@@ -217,6 +202,9 @@ func (s *SyncToolServer) getLicense(w http.ResponseWriter, r *http.Request, lice
 	if agentID.Valid {
 		response["assigned_to"] = agentID.String
 	}
+	if license.ExpiresAt != nil {
+		response["expires_at"] = license.ExpiresAt.Format(time.RFC3339)
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -237,9 +225,10 @@ func (s *SyncToolServer) updateLicense(w http.ResponseWriter, r *http.Request, l
 
 	result, err := s.db.Exec(`
 		UPDATE licenses 
-		SET license_key = $1, updated_at = NOW() 
+		SET license_key = $1, expires_at = $3, updated_at = NOW(),
+		    expiry_notified_at = CASE WHEN expires_at IS DISTINCT FROM $3 THEN NULL ELSE expiry_notified_at END
 		WHERE id = $2
-	`, req.LicenseKey, licenseID)
+	`, req.LicenseKey, licenseID, req.ExpiresAt)
 	
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") {
@@ -262,6 +251,7 @@ func (s *SyncToolServer) updateLicense(w http.ResponseWriter, r *http.Request, l
 		"message":     "License updated successfully",
 		"id":          licenseID,
 		"license_key": req.LicenseKey,
+		"expires_at":  req.ExpiresAt,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -392,4 +382,47 @@ func (s *SyncToolServer) getJobsByAgent(tx *sql.Tx, agentID string) ([]struct{ J
 	}
 	
 	return jobs, nil
-}
\ No newline at end of file
+}
+
+// checkExpiringLicenses sends license_expiring once for every license that expires within
+// webhooks.license_expiry_warning of now, licenses that already expired unnoticed included.
+// Changing the expiry date of a license (updateLicense) notifies again.
+func (s *SyncToolServer) checkExpiringLicenses(now time.Time) {
+	rows, err := s.db.Query(`
+		UPDATE licenses l SET expiry_notified_at = $2
+		WHERE l.expires_at IS NOT NULL AND l.expiry_notified_at IS NULL AND l.expires_at <= $1
+		RETURNING l.id, l.expires_at, (SELECT al.agent_id FROM agent_licenses al WHERE al.license_id = l.id LIMIT 1)
+	`, now.Add(s.config.Webhooks.LicenseExpiryWarning), now)
+	if err != nil {
+		log.Printf("❌ Failed to check license expiry dates: %v", err)
+		return
+	}
+
+	var events []map[string]interface{}
+	for rows.Next() {
+		var id int
+		var expiresAt time.Time
+		var agentID sql.NullString
+		if err := rows.Scan(&id, &expiresAt, &agentID); err != nil {
+			log.Printf("❌ Failed to scan expiring license: %v", err)
+			continue
+		}
+
+		event := map[string]interface{}{
+			"license_id": id,
+			"expires_at": expiresAt.Format(time.RFC3339),
+			"expired":    !expiresAt.After(now),
+			"days_left":  int(expiresAt.Sub(now).Hours() / 24),
+		}
+		if agentID.Valid {
+			event["agent_id"] = agentID.String
+		}
+		log.Printf("📜 License %d expires at %s", id, expiresAt.Format(time.RFC3339))
+		events = append(events, event)
+	}
+	rows.Close()
+
+	for _, event := range events {
+		s.emitWebhookEvent(WebhookEventLicenseExpiring, event)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckExpiringLicenses(t *testing.T) {
+	now := time.Date(2030, 3, 8, 12, 0, 0, 0, time.UTC)
+	config := DefaultConfig()
+	config.Webhooks.LicenseExpiryWarning = 14 * 24 * time.Hour
+
+	// queued expects the delivery of a license_expiring event with data
+	queued := func(data map[string]interface{}) *fakeStatement {
+		return &fakeStatement{
+			query:    "INSERT INTO webhook_deliveries",
+			affected: 1,
+			check: func(args []driver.Value) error {
+				var payload struct {
+					Event string                 `json:"event"`
+					Data  map[string]interface{} `json:"data"`
+				}
+				if err := json.Unmarshal([]byte(args[2].(string)), &payload); err != nil {
+					return err
+				}
+				if args[1] != WebhookEventLicenseExpiring || payload.Event != WebhookEventLicenseExpiring || !reflect.DeepEqual(payload.Data, data) {
+					return fmt.Errorf("queued %s %v, want %s %v", payload.Event, payload.Data, WebhookEventLicenseExpiring, data)
+				}
+				return nil
+			},
+		}
+	}
+
+	db, _ := newFakeDB(t,
+		&fakeStatement{
+			query:   "UPDATE licenses l SET expiry_notified_at = $2",
+			args:    []driver.Value{now.Add(14 * 24 * time.Hour), now},
+			columns: []string{"id", "expires_at", "agent_id"},
+			rows: [][]driver.Value{
+				{int64(1), now.Add(72 * time.Hour), "agent-1"},
+				{int64(2), now.Add(-time.Hour), nil},
+			},
+		},
+		queued(map[string]interface{}{"license_id": float64(1), "expires_at": "2030-03-11T12:00:00Z", "expired": false, "days_left": float64(3), "agent_id": "agent-1"}),
+		queued(map[string]interface{}{"license_id": float64(2), "expires_at": "2030-03-08T11:00:00Z", "expired": true, "days_left": float64(0)}),
+	)
+	s := &SyncToolServer{db: db, config: config}
+
+	s.checkExpiringLicenses(now)
+}
@@ -0,0 +1,753 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Maintenance window types stored in maintenance_windows.window_type
+const (
+	WindowTypeAllow    = "allow"    // job may only transfer inside allow windows
+	WindowTypeBlackout = "blackout" // job may not transfer inside the window
+)
+
+// pauseReasonMaintenanceWindow marks pause/resume messages sent by window enforcement
+const pauseReasonMaintenanceWindow = "maintenance_window"
+
+// MaintenanceWindow restricts when a job (or every job, if JobID is nil) may transfer.
+// A window has a recurring part (days_of_week, start_time/end_time), an absolute part
+// (start_at/end_at) or both, in which case it is only active inside the date range.
+type MaintenanceWindow struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	JobID      *int       `json:"job_id"`
+	WindowType string     `json:"window_type"`
+	DaysOfWeek []int      `json:"days_of_week"`
+	StartTime  string     `json:"start_time,omitempty"`
+	EndTime    string     `json:"end_time,omitempty"`
+	StartAt    *time.Time `json:"start_at,omitempty"`
+	EndAt      *time.Time `json:"end_at,omitempty"`
+	Timezone   string     `json:"timezone"`
+	Enabled    bool       `json:"enabled"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	location    *time.Location
+	startMinute int
+	endMinute   int
+}
+
+// prepare validates the window and caches its time zone and daily start/end minutes
+func (mw *MaintenanceWindow) prepare() error {
+	mw.Name = strings.TrimSpace(mw.Name)
+	mw.StartTime = strings.TrimSpace(mw.StartTime)
+	mw.EndTime = strings.TrimSpace(mw.EndTime)
+	mw.Timezone = strings.TrimSpace(mw.Timezone)
+
+	if mw.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if mw.WindowType == "" {
+		mw.WindowType = WindowTypeBlackout
+	}
+	if mw.WindowType != WindowTypeAllow && mw.WindowType != WindowTypeBlackout {
+		return fmt.Errorf("invalid window_type %q (expected allow or blackout)", mw.WindowType)
+	}
+	if mw.Timezone == "" {
+		mw.Timezone = defaultScheduleTimezone
+	}
+	location, err := time.LoadLocation(mw.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid time zone %q: must be an IANA name such as Europe/Berlin", mw.Timezone)
+	}
+	mw.location = location
+
+	if mw.DaysOfWeek == nil {
+		mw.DaysOfWeek = []int{}
+	}
+	for _, day := range mw.DaysOfWeek {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("invalid day of week %d (expected 0 = Sunday ... 6 = Saturday)", day)
+		}
+	}
+
+	if (mw.StartTime == "") != (mw.EndTime == "") {
+		return fmt.Errorf("start_time and end_time must be set together")
+	}
+	if mw.StartTime != "" {
+		if mw.startMinute, err = parseClockMinutes(mw.StartTime); err != nil {
+			return fmt.Errorf("invalid start_time: %v", err)
+		}
+		if mw.endMinute, err = parseClockMinutes(mw.EndTime); err != nil {
+			return fmt.Errorf("invalid end_time: %v", err)
+		}
+	}
+
+	if mw.StartAt != nil && mw.EndAt != nil && !mw.StartAt.Before(*mw.EndAt) {
+		return fmt.Errorf("start_at must be before end_at")
+	}
+	if mw.StartTime == "" && len(mw.DaysOfWeek) == 0 && mw.StartAt == nil && mw.EndAt == nil {
+		return fmt.Errorf("window needs start_time/end_time, days_of_week or start_at/end_at")
+	}
+
+	return nil
+}
+
+// ActiveAt returns true if the window covers the given instant
+func (mw *MaintenanceWindow) ActiveAt(t time.Time) bool {
+	if !mw.Enabled {
+		return false
+	}
+	if mw.StartAt != nil && t.Before(*mw.StartAt) {
+		return false
+	}
+	if mw.EndAt != nil && !t.Before(*mw.EndAt) {
+		return false
+	}
+
+	location := mw.location
+	if location == nil {
+		location = time.UTC
+	}
+	local := t.In(location)
+	today := local.Weekday()
+
+	if mw.StartTime == "" {
+		// Whole days (or just the absolute range)
+		return mw.onDay(today)
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case mw.startMinute == mw.endMinute:
+		// 00:00-00:00 and friends cover the whole day
+		return mw.onDay(today)
+	case mw.startMinute < mw.endMinute:
+		return mw.onDay(today) && minute >= mw.startMinute && minute < mw.endMinute
+	default:
+		// Wraps past midnight: the early-morning part belongs to the day the window started
+		return (mw.onDay(today) && minute >= mw.startMinute) || (mw.onDay(yesterday) && minute < mw.endMinute)
+	}
+}
+
+// onDay returns true if the window applies to the given weekday
+func (mw *MaintenanceWindow) onDay(day time.Weekday) bool {
+	if len(mw.DaysOfWeek) == 0 {
+		return true
+	}
+	for _, d := range mw.DaysOfWeek {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClockMinutes parses "HH:MM" into minutes after midnight
+func parseClockMinutes(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// evaluateMaintenanceWindows decides whether a job may transfer at t.
+// A job is allowed when it is inside one of its allow windows (or has none)
+// and not inside any blackout window.
+func evaluateMaintenanceWindows(windows []*MaintenanceWindow, t time.Time) (bool, string) {
+	hasAllow := false
+	inAllow := false
+
+	for _, mw := range windows {
+		if !mw.Enabled {
+			continue
+		}
+		switch mw.WindowType {
+		case WindowTypeBlackout:
+			if mw.ActiveAt(t) {
+				return false, fmt.Sprintf("inside blackout window %q", mw.Name)
+			}
+		case WindowTypeAllow:
+			hasAllow = true
+			if mw.ActiveAt(t) {
+				inAllow = true
+			}
+		}
+	}
+
+	if hasAllow && !inAllow {
+		return false, "outside allowed transfer windows"
+	}
+	return true, ""
+}
+
+// windowsForJob returns the global windows plus the windows of a single job
+func windowsForJob(windows []*MaintenanceWindow, jobID int) []*MaintenanceWindow {
+	result := []*MaintenanceWindow{}
+	for _, mw := range windows {
+		if mw.JobID == nil || *mw.JobID == jobID {
+			result = append(result, mw)
+		}
+	}
+	return result
+}
+
+const maintenanceWindowColumns = `id, name, job_id, window_type, days_of_week,
+	COALESCE(to_char(start_time, 'HH24:MI'), ''), COALESCE(to_char(end_time, 'HH24:MI'), ''),
+	start_at, end_at, timezone, enabled, created_at, updated_at`
+
+// scanMaintenanceWindow reads a maintenance_windows row selected with maintenanceWindowColumns
+func scanMaintenanceWindow(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*MaintenanceWindow, error) {
+	mw := &MaintenanceWindow{}
+	var jobID sql.NullInt64
+	var days pq.Int64Array
+
+	if err := scanner.Scan(&mw.ID, &mw.Name, &jobID, &mw.WindowType, &days,
+		&mw.StartTime, &mw.EndTime, &mw.StartAt, &mw.EndAt, &mw.Timezone, &mw.Enabled,
+		&mw.CreatedAt, &mw.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if jobID.Valid {
+		id := int(jobID.Int64)
+		mw.JobID = &id
+	}
+	mw.DaysOfWeek = make([]int, 0, len(days))
+	for _, d := range days {
+		mw.DaysOfWeek = append(mw.DaysOfWeek, int(d))
+	}
+
+	if err := mw.prepare(); err != nil {
+		// Rows are validated on write, so this only happens for hand-edited rows
+		log.Printf("⚠️ Maintenance window %d is invalid and will be ignored: %v", mw.ID, err)
+		mw.Enabled = false
+	}
+	return mw, nil
+}
+
+// loadMaintenanceWindows loads windows, optionally only enabled ones
+func (s *SyncToolServer) loadMaintenanceWindows(onlyEnabled bool) ([]*MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceWindowColumns + ` FROM maintenance_windows`
+	if onlyEnabled {
+		query += ` WHERE enabled = true`
+	}
+	query += ` ORDER BY id ASC`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []*MaintenanceWindow{}
+	for rows.Next() {
+		mw, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan maintenance window: %w", err)
+		}
+		windows = append(windows, mw)
+	}
+	return windows, rows.Err()
+}
+
+// maintenanceWindowsForJob returns the enabled windows that apply to a job,
+// used to ship the windows to agents with deploy_job
+func (s *SyncToolServer) maintenanceWindowsForJob(jobID string) []*MaintenanceWindow {
+	id, err := strconv.Atoi(jobID)
+	if err != nil || s.db == nil {
+		return []*MaintenanceWindow{}
+	}
+
+	windows, err := s.loadMaintenanceWindows(true)
+	if err != nil {
+		log.Printf("⚠️ Failed to load maintenance windows for job %s: %v", jobID, err)
+		return []*MaintenanceWindow{}
+	}
+	return windowsForJob(windows, id)
+}
+
+// enforceMaintenanceWindows pauses active jobs that are outside their windows and
+// resumes jobs that were paused by a window once transfers are allowed again.
+// Jobs paused manually (status = 'paused') are left alone.
+func (s *SyncToolServer) enforceMaintenanceWindows() error {
+	if s.db == nil {
+		return nil
+	}
+
+	s.maintenanceMu.Lock()
+	defer s.maintenanceMu.Unlock()
+
+	windows, err := s.loadMaintenanceWindows(true)
+	if err != nil {
+		return err
+	}
+
+	type jobWindowState struct {
+		id           int
+		name         string
+		windowPaused bool
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(window_paused, false)
+		FROM sync_jobs
+		WHERE status = 'active'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query active jobs: %w", err)
+	}
+	var jobs []jobWindowState
+	for rows.Next() {
+		var job jobWindowState
+		if err := rows.Scan(&job.id, &job.name, &job.windowPaused); err != nil {
+			log.Printf("❌ Failed to scan job row: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, job := range jobs {
+		allowed, reason := evaluateMaintenanceWindows(windowsForJob(windows, job.id), now)
+		jobID := strconv.Itoa(job.id)
+
+		if !allowed && !job.windowPaused {
+			if err := s.pauseJobOnAgentsWithReason(jobID, pauseReasonMaintenanceWindow); err != nil {
+				log.Printf("❌ Failed to pause job %d (%s) for maintenance window: %v", job.id, job.name, err)
+				continue
+			}
+			if _, err := s.db.Exec(`UPDATE sync_jobs SET window_paused = true, updated_at = $1 WHERE id = $2`, now, job.id); err != nil {
+				log.Printf("❌ Failed to mark job %d as window paused: %v", job.id, err)
+				continue
+			}
+			log.Printf("⏸️ Job %d (%s) paused by maintenance window: %s", job.id, job.name, reason)
+		} else if allowed && job.windowPaused {
+			if err := s.resumeJobOnAgentsWithReason(jobID, pauseReasonMaintenanceWindow); err != nil {
+				log.Printf("❌ Failed to resume job %d (%s) after maintenance window: %v", job.id, job.name, err)
+				continue
+			}
+			if _, err := s.db.Exec(`UPDATE sync_jobs SET window_paused = false, updated_at = $1 WHERE id = $2`, now, job.id); err != nil {
+				log.Printf("❌ Failed to clear window pause for job %d: %v", job.id, err)
+				continue
+			}
+			log.Printf("▶️ Job %d (%s) resumed, maintenance window allows transfers again", job.id, job.name)
+		}
+	}
+
+	return nil
+}
+
+// jobAgentIDs returns the source and all destination agents of a job
+func (s *SyncToolServer) jobAgentIDs(jobID string) ([]string, error) {
+	var sourceAgentID, targetAgentID string
+	var isMultiDest bool
+	err := s.db.QueryRow(`
+		SELECT source_agent_id, COALESCE(target_agent_id, ''), COALESCE(is_multi_destination, false)
+		FROM sync_jobs WHERE id = $1
+	`, jobID).Scan(&sourceAgentID, &targetAgentID, &isMultiDest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job details: %v", err)
+	}
+
+	agentIDs := []string{sourceAgentID}
+	if !isMultiDest {
+		if targetAgentID != "" && targetAgentID != sourceAgentID {
+			agentIDs = append(agentIDs, targetAgentID)
+		}
+		return agentIDs, nil
+	}
+
+	rows, err := s.db.Query(`SELECT destination_agent_id FROM sync_job_destinations WHERE job_id = $1`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destinations: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var destAgentID string
+		if err := rows.Scan(&destAgentID); err != nil {
+			continue
+		}
+		if !contains(agentIDs, destAgentID) {
+			agentIDs = append(agentIDs, destAgentID)
+		}
+	}
+	return agentIDs, nil
+}
+
+// pushMaintenanceWindows sends the current windows to the agents of the affected jobs
+// (all active jobs for a global window) and re-evaluates the windows right away
+func (s *SyncToolServer) pushMaintenanceWindows(jobID *int) {
+	windows, err := s.loadMaintenanceWindows(true)
+	if err != nil {
+		log.Printf("❌ Failed to load maintenance windows: %v", err)
+		return
+	}
+
+	var jobIDs []int
+	if jobID != nil {
+		jobIDs = []int{*jobID}
+	} else {
+		rows, err := s.db.Query(`SELECT id FROM sync_jobs WHERE status = 'active'`)
+		if err != nil {
+			log.Printf("❌ Failed to query active jobs: %v", err)
+			return
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err == nil {
+				jobIDs = append(jobIDs, id)
+			}
+		}
+		rows.Close()
+	}
+
+	for _, id := range jobIDs {
+		jobIDStr := strconv.Itoa(id)
+		agentIDs, err := s.jobAgentIDs(jobIDStr)
+		if err != nil {
+			log.Printf("⚠️ Cannot push maintenance windows for job %d: %v", id, err)
+			continue
+		}
+
+		message := map[string]interface{}{
+			"type":                "maintenance_windows",
+			"job_id":              jobIDStr,
+			"maintenance_windows": windowsForJob(windows, id),
+		}
+		for _, agentID := range agentIDs {
+			if err := s.sendJobToAgent(agentID, message); err != nil {
+				log.Printf("⚠️ Failed to push maintenance windows for job %d to agent %s: %v", id, agentID, err)
+			}
+		}
+	}
+
+	if err := s.enforceMaintenanceWindows(); err != nil {
+		log.Printf("❌ Failed to enforce maintenance windows: %v", err)
+	}
+}
+
+// handleMaintenanceWindows handles maintenance window list and create
+// GET /api/v1/maintenance-windows - List windows (?job_id= returns the job's windows plus global ones)
+// POST /api/v1/maintenance-windows - Create window
+func (s *SyncToolServer) handleMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.listMaintenanceWindows(w, r)
+	case "POST":
+		s.createMaintenanceWindow(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMaintenanceWindowActions handles actions on a specific maintenance window
+// GET /api/v1/maintenance-windows/check?job_id={id} - Check whether a job may transfer now
+// GET /api/v1/maintenance-windows/{id} - Get window
+// PUT /api/v1/maintenance-windows/{id} - Update window
+// DELETE /api/v1/maintenance-windows/{id} - Delete window
+func (s *SyncToolServer) handleMaintenanceWindowActions(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Parse URL path: /api/v1/maintenance-windows/{id}
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/maintenance-windows/"), "/")
+	if len(pathParts) < 1 || pathParts[0] == "" {
+		http.Error(w, `{"error": "Invalid URL format. Expected: /api/v1/maintenance-windows/{id}"}`, http.StatusBadRequest)
+		return
+	}
+
+	if pathParts[0] == "check" {
+		if r.Method != "GET" {
+			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		s.checkMaintenanceWindows(w, r)
+		return
+	}
+
+	windowID, err := strconv.Atoi(pathParts[0])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid maintenance window ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.getMaintenanceWindow(w, r, windowID)
+	case "PUT":
+		s.updateMaintenanceWindow(w, r, windowID)
+	case "DELETE":
+		s.deleteMaintenanceWindow(w, r, windowID)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// listMaintenanceWindows retrieves all windows, or the windows that apply to one job
+func (s *SyncToolServer) listMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := s.loadMaintenanceWindows(false)
+	if err != nil {
+		log.Printf("❌ Failed to query maintenance windows: %v", err)
+		http.Error(w, `{"error": "Failed to fetch maintenance windows"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if jobIDParam := r.URL.Query().Get("job_id"); jobIDParam != "" {
+		jobID, err := strconv.Atoi(jobIDParam)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid job_id"}`, http.StatusBadRequest)
+			return
+		}
+		windows = windowsForJob(windows, jobID)
+	}
+
+	response := map[string]interface{}{
+		"data":  windows,
+		"total": len(windows),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// createMaintenanceWindow creates a new window
+func (s *SyncToolServer) createMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	mw := &MaintenanceWindow{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(mw); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := mw.prepare(); err != nil {
+		writeMaintenanceWindowError(w, err)
+		return
+	}
+
+	err := s.db.QueryRow(`
+		INSERT INTO maintenance_windows (name, job_id, window_type, days_of_week, start_time, end_time,
+			start_at, end_at, timezone, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, mw.Name, mw.JobID, mw.WindowType, pq.Array(mw.DaysOfWeek), nullIfEmpty(mw.StartTime), nullIfEmpty(mw.EndTime),
+		mw.StartAt, mw.EndAt, mw.Timezone, mw.Enabled).Scan(&mw.ID, &mw.CreatedAt, &mw.UpdatedAt)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "fk_maintenance_windows_job_id") {
+			http.Error(w, `{"error": "Sync job not found"}`, http.StatusBadRequest)
+		} else {
+			log.Printf("❌ Failed to create maintenance window: %v", err)
+			http.Error(w, `{"error": "Failed to create maintenance window"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	go s.pushMaintenanceWindows(mw.JobID)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Maintenance window created successfully",
+		"data":    mw,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Maintenance window created: ID=%d, Name=%s, Type=%s", mw.ID, mw.Name, mw.WindowType)
+}
+
+// getMaintenanceWindow retrieves a specific window
+func (s *SyncToolServer) getMaintenanceWindow(w http.ResponseWriter, r *http.Request, windowID int) {
+	mw, err := scanMaintenanceWindow(s.db.QueryRow(
+		`SELECT `+maintenanceWindowColumns+` FROM maintenance_windows WHERE id = $1`, windowID))
+
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Maintenance window not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Printf("❌ Failed to get maintenance window: %v", err)
+		http.Error(w, `{"error": "Failed to get maintenance window"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mw)
+}
+
+// updateMaintenanceWindow updates a window; fields missing from the body keep their current value
+func (s *SyncToolServer) updateMaintenanceWindow(w http.ResponseWriter, r *http.Request, windowID int) {
+	mw, err := scanMaintenanceWindow(s.db.QueryRow(
+		`SELECT `+maintenanceWindowColumns+` FROM maintenance_windows WHERE id = $1`, windowID))
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Maintenance window not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get maintenance window: %v", err)
+		http.Error(w, `{"error": "Failed to update maintenance window"}`, http.StatusInternalServerError)
+		return
+	}
+
+	previousJobID := mw.JobID
+	if err := json.NewDecoder(r.Body).Decode(mw); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	mw.ID = windowID
+
+	if err := mw.prepare(); err != nil {
+		writeMaintenanceWindowError(w, err)
+		return
+	}
+
+	err = s.db.QueryRow(`
+		UPDATE maintenance_windows
+		SET name = $1, job_id = $2, window_type = $3, days_of_week = $4, start_time = $5, end_time = $6,
+			start_at = $7, end_at = $8, timezone = $9, enabled = $10, updated_at = NOW()
+		WHERE id = $11
+		RETURNING updated_at
+	`, mw.Name, mw.JobID, mw.WindowType, pq.Array(mw.DaysOfWeek), nullIfEmpty(mw.StartTime), nullIfEmpty(mw.EndTime),
+		mw.StartAt, mw.EndAt, mw.Timezone, mw.Enabled, windowID).Scan(&mw.UpdatedAt)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "fk_maintenance_windows_job_id") {
+			http.Error(w, `{"error": "Sync job not found"}`, http.StatusBadRequest)
+		} else {
+			log.Printf("❌ Failed to update maintenance window: %v", err)
+			http.Error(w, `{"error": "Failed to update maintenance window"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Moving a window between jobs (or to/from global) affects both scopes
+	if previousJobID != nil && (mw.JobID == nil || *mw.JobID != *previousJobID) {
+		go s.pushMaintenanceWindows(previousJobID)
+	}
+	go s.pushMaintenanceWindows(mw.JobID)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Maintenance window updated successfully",
+		"data":    mw,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Maintenance window updated: ID=%d, Name=%s", windowID, mw.Name)
+}
+
+// deleteMaintenanceWindow deletes a window; paused jobs resume on the next evaluation
+func (s *SyncToolServer) deleteMaintenanceWindow(w http.ResponseWriter, r *http.Request, windowID int) {
+	var jobID sql.NullInt64
+	err := s.db.QueryRow(`DELETE FROM maintenance_windows WHERE id = $1 RETURNING job_id`, windowID).Scan(&jobID)
+
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Maintenance window not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		log.Printf("❌ Failed to delete maintenance window: %v", err)
+		http.Error(w, `{"error": "Failed to delete maintenance window"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var affectedJobID *int
+	if jobID.Valid {
+		id := int(jobID.Int64)
+		affectedJobID = &id
+	}
+	go s.pushMaintenanceWindows(affectedJobID)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Maintenance window deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Maintenance window deleted: ID=%d", windowID)
+}
+
+// checkMaintenanceWindows reports whether a job may transfer now (or at ?at=RFC3339)
+func (s *SyncToolServer) checkMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.URL.Query().Get("job_id"))
+	if err != nil {
+		http.Error(w, `{"error": "job_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	at := time.Now()
+	if atParam := r.URL.Query().Get("at"); atParam != "" {
+		if at, err = time.Parse(time.RFC3339, atParam); err != nil {
+			http.Error(w, `{"error": "Invalid at, expected RFC3339"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	windows, err := s.loadMaintenanceWindows(true)
+	if err != nil {
+		log.Printf("❌ Failed to query maintenance windows: %v", err)
+		http.Error(w, `{"error": "Failed to fetch maintenance windows"}`, http.StatusInternalServerError)
+		return
+	}
+
+	jobWindows := windowsForJob(windows, jobID)
+	allowed, reason := evaluateMaintenanceWindows(jobWindows, at)
+
+	activeWindows := []*MaintenanceWindow{}
+	for _, mw := range jobWindows {
+		if mw.ActiveAt(at) {
+			activeWindows = append(activeWindows, mw)
+		}
+	}
+
+	var windowPaused bool
+	s.db.QueryRow(`SELECT COALESCE(window_paused, false) FROM sync_jobs WHERE id = $1`, jobID).Scan(&windowPaused)
+
+	response := map[string]interface{}{
+		"job_id":         jobID,
+		"at":             at.Format(time.RFC3339),
+		"allowed":        allowed,
+		"reason":         reason,
+		"window_paused":  windowPaused,
+		"active_windows": activeWindows,
+		"total_windows":  len(jobWindows),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeMaintenanceWindowError writes a 400 response for an invalid window
+func writeMaintenanceWindowError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid maintenance window: %v", err),
+	})
+}
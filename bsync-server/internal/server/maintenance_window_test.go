@@ -0,0 +1,135 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// mustTime parses an RFC3339 timestamp for test tables
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestMaintenanceWindowPrepare(t *testing.T) {
+	start := time.Date(2030, 3, 8, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		window  MaintenanceWindow
+		wantErr bool
+	}{
+		{name: "daily times", window: MaintenanceWindow{Name: "night", StartTime: "22:00", EndTime: "06:00"}},
+		{name: "days only", window: MaintenanceWindow{Name: "weekend", DaysOfWeek: []int{0, 6}}},
+		{name: "absolute range", window: MaintenanceWindow{Name: "migration", StartAt: &start, EndAt: &end}},
+		{name: "missing name", window: MaintenanceWindow{StartTime: "22:00", EndTime: "06:00"}, wantErr: true},
+		{name: "unknown type", window: MaintenanceWindow{Name: "x", WindowType: "deny", DaysOfWeek: []int{1}}, wantErr: true},
+		{name: "invalid day", window: MaintenanceWindow{Name: "x", DaysOfWeek: []int{7}}, wantErr: true},
+		{name: "start without end", window: MaintenanceWindow{Name: "x", StartTime: "22:00"}, wantErr: true},
+		{name: "invalid time", window: MaintenanceWindow{Name: "x", StartTime: "24:30", EndTime: "06:00"}, wantErr: true},
+		{name: "invalid time zone", window: MaintenanceWindow{Name: "x", DaysOfWeek: []int{1}, Timezone: "Mars/Olympus"}, wantErr: true},
+		{name: "range ends before start", window: MaintenanceWindow{Name: "x", StartAt: &end, EndAt: &start}, wantErr: true},
+		{name: "nothing to restrict", window: MaintenanceWindow{Name: "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.window.prepare()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prepare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.window.WindowType != WindowTypeBlackout && tt.window.WindowType != WindowTypeAllow {
+				t.Errorf("window_type %q was not defaulted", tt.window.WindowType)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowActiveAt(t *testing.T) {
+	// 2030-03-08 is a Friday (5), 2030-03-09 a Saturday (6)
+	rangeStart := mustTime(t, "2030-03-08T12:00:00Z")
+	rangeEnd := mustTime(t, "2030-03-09T12:00:00Z")
+
+	tests := []struct {
+		name   string
+		window MaintenanceWindow
+		at     string
+		want   bool
+	}{
+		{name: "inside daily window", window: MaintenanceWindow{StartTime: "09:00", EndTime: "17:00"}, at: "2030-03-08T10:00:00Z", want: true},
+		{name: "before daily window", window: MaintenanceWindow{StartTime: "09:00", EndTime: "17:00"}, at: "2030-03-08T08:59:00Z", want: false},
+		{name: "end is exclusive", window: MaintenanceWindow{StartTime: "09:00", EndTime: "17:00"}, at: "2030-03-08T17:00:00Z", want: false},
+		{name: "wrong day", window: MaintenanceWindow{DaysOfWeek: []int{1, 2, 3, 4, 5}, StartTime: "09:00", EndTime: "17:00"}, at: "2030-03-09T10:00:00Z", want: false},
+		{name: "whole day", window: MaintenanceWindow{DaysOfWeek: []int{6}}, at: "2030-03-09T23:59:00Z", want: true},
+		{name: "same start and end covers the day", window: MaintenanceWindow{DaysOfWeek: []int{5}, StartTime: "00:00", EndTime: "00:00"}, at: "2030-03-08T18:00:00Z", want: true},
+		{name: "overnight before midnight", window: MaintenanceWindow{DaysOfWeek: []int{5}, StartTime: "22:00", EndTime: "06:00"}, at: "2030-03-08T23:00:00Z", want: true},
+		{name: "overnight after midnight belongs to start day", window: MaintenanceWindow{DaysOfWeek: []int{5}, StartTime: "22:00", EndTime: "06:00"}, at: "2030-03-09T05:59:00Z", want: true},
+		{name: "overnight ended", window: MaintenanceWindow{DaysOfWeek: []int{5}, StartTime: "22:00", EndTime: "06:00"}, at: "2030-03-09T06:00:00Z", want: false},
+		{name: "overnight of an unlisted day", window: MaintenanceWindow{DaysOfWeek: []int{5}, StartTime: "22:00", EndTime: "06:00"}, at: "2030-03-08T05:00:00Z", want: false},
+		{name: "time zone", window: MaintenanceWindow{StartTime: "09:00", EndTime: "17:00", Timezone: "Europe/Berlin"}, at: "2030-03-08T08:30:00Z", want: true},
+		{name: "time zone after end", window: MaintenanceWindow{StartTime: "09:00", EndTime: "17:00", Timezone: "Europe/Berlin"}, at: "2030-03-08T16:30:00Z", want: false},
+		{name: "time zone moves the day", window: MaintenanceWindow{DaysOfWeek: []int{6}, Timezone: "Asia/Tokyo"}, at: "2030-03-08T16:00:00Z", want: true},
+		{name: "inside absolute range", window: MaintenanceWindow{StartAt: &rangeStart, EndAt: &rangeEnd}, at: "2030-03-08T12:00:00Z", want: true},
+		{name: "absolute range end is exclusive", window: MaintenanceWindow{StartAt: &rangeStart, EndAt: &rangeEnd}, at: "2030-03-09T12:00:00Z", want: false},
+		{name: "daily part limited by range", window: MaintenanceWindow{StartTime: "09:00", EndTime: "17:00", StartAt: &rangeStart, EndAt: &rangeEnd}, at: "2030-03-08T10:00:00Z", want: false},
+		{name: "disabled", window: MaintenanceWindow{StartTime: "00:00", EndTime: "00:00"}, at: "2030-03-08T10:00:00Z", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := tt.window
+			window.Name = tt.name
+			window.Enabled = tt.name != "disabled"
+			if err := window.prepare(); err != nil {
+				t.Fatalf("prepare() error = %v", err)
+			}
+			if got := window.ActiveAt(mustTime(t, tt.at)); got != tt.want {
+				t.Errorf("ActiveAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMaintenanceWindows(t *testing.T) {
+	window := func(windowType, startTime, endTime string, enabled bool) *MaintenanceWindow {
+		mw := &MaintenanceWindow{Name: windowType + " " + startTime, WindowType: windowType, StartTime: startTime, EndTime: endTime, Enabled: enabled}
+		if err := mw.prepare(); err != nil {
+			t.Fatal(err)
+		}
+		return mw
+	}
+	night := window(WindowTypeAllow, "22:00", "06:00", true)
+	backup := window(WindowTypeBlackout, "01:00", "02:00", true)
+	disabledAllow := window(WindowTypeAllow, "08:00", "09:00", false)
+
+	tests := []struct {
+		name    string
+		windows []*MaintenanceWindow
+		at      string
+		want    bool
+	}{
+		{name: "no windows", at: "2030-03-08T12:00:00Z", want: true},
+		{name: "inside allow window", windows: []*MaintenanceWindow{night}, at: "2030-03-08T23:00:00Z", want: true},
+		{name: "outside allow window", windows: []*MaintenanceWindow{night}, at: "2030-03-08T12:00:00Z", want: false},
+		{name: "blackout inside allow window", windows: []*MaintenanceWindow{night, backup}, at: "2030-03-09T01:30:00Z", want: false},
+		{name: "blackout only", windows: []*MaintenanceWindow{backup}, at: "2030-03-08T12:00:00Z", want: true},
+		{name: "disabled allow window is ignored", windows: []*MaintenanceWindow{disabledAllow}, at: "2030-03-08T12:00:00Z", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := evaluateMaintenanceWindows(tt.windows, mustTime(t, tt.at))
+			if allowed != tt.want {
+				t.Errorf("allowed = %v (%s), want %v", allowed, reason, tt.want)
+			}
+			if !allowed && reason == "" {
+				t.Error("blocked without a reason")
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bsync-server/pkg/metrics"
+)
+
+// Metrics accumulated between scrapes; everything else is read at scrape time
+var (
+	dbQueryDuration = metrics.NewHistogramVec("bsync_db_query_duration_seconds",
+		"Latency of database queries, execs and transaction starts.",
+		metrics.DefaultDurationBuckets, "operation", "status")
+
+	sessionDuration = metrics.NewHistogramVec("bsync_sync_session_duration_seconds",
+		"Duration of completed sync sessions.",
+		[]float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 21600, 86400}, "status")
+
+	eventsProcessed = metrics.NewCounterVec("bsync_events_processed_total",
+		"Agent events processed by the event processor.", "type")
+
+	eventsDropped = metrics.NewCounterVec("bsync_events_dropped_total",
+		"Agent events the event store dropped (queue_full, write_failed).", "reason")
+)
+
+// handleMetrics serves server metrics in the Prometheus text format.
+// GET /metrics - requires "Authorization: Bearer <metrics.token>" when a token is configured
+func (s *SyncToolServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if token := s.config.Metrics.Token; token != "" {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	metrics.Handler(s.collectMetrics)(w, r)
+}
+
+// collectMetrics writes all server metrics
+func (s *SyncToolServer) collectMetrics(w *metrics.Writer) {
+	s.collectHubMetrics(w)
+	s.collectEventMetrics(w)
+	s.collectSchedulerMetrics(w)
+	s.collectJobMetrics(w)
+	s.collectDBMetrics(w)
+
+	sessionDuration.Write(w)
+}
+
+// collectHubMetrics writes agent connection state and WebSocket send queue depth
+func (s *SyncToolServer) collectHubMetrics(w *metrics.Writer) {
+	h := s.hub
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	w.Gauge("bsync_server_uptime_seconds", "Seconds since the server started.", time.Since(h.startTime).Seconds())
+
+	online := 0
+	for _, agent := range h.agents {
+		if agent.isOnline {
+			online++
+		}
+	}
+	w.Gauge("bsync_hub_agents", "Agents known to the hub since the server started.", float64(len(h.agents)))
+	w.Gauge("bsync_hub_agents_online", "Agents currently connected.", float64(online))
+	w.Gauge("bsync_hub_cli_clients", "CLI and dashboard WebSocket clients currently connected.", float64(len(h.cliClients)))
+
+	w.Header("bsync_agent_connected", "Whether the agent is connected (1) or not (0).", metrics.TypeGauge)
+	for id, agent := range h.agents {
+		w.Sample("bsync_agent_connected", metrics.Labels{"agent_id": id, "hostname": agent.hostname}, metrics.Bool(agent.isOnline))
+	}
+
+	w.Header("bsync_agent_last_seen_timestamp_seconds", "Unix time the agent was last seen.", metrics.TypeGauge)
+	for id, agent := range h.agents {
+		if !agent.lastSeen.IsZero() {
+			w.Sample("bsync_agent_last_seen_timestamp_seconds", metrics.Labels{"agent_id": id}, float64(agent.lastSeen.Unix()))
+		}
+	}
+
+	w.Header("bsync_agent_protocol_version", "Protocol version negotiated with the agent.", metrics.TypeGauge)
+	for id, agent := range h.agents {
+		if agent.isOnline {
+			w.Sample("bsync_agent_protocol_version", metrics.Labels{"agent_id": id}, float64(agent.protocolVersion))
+		}
+	}
+
+	type sendQueue struct {
+		labels           metrics.Labels
+		length, capacity int
+	}
+	queues := []sendQueue{}
+	for id, agent := range h.agents {
+		if agent.send != nil {
+			queues = append(queues, sendQueue{metrics.Labels{"client_type": "agent", "client_id": id}, len(agent.send), cap(agent.send)})
+		}
+	}
+	for id, client := range h.cliClients {
+		if client.send != nil {
+			queues = append(queues, sendQueue{metrics.Labels{"client_type": "cli", "client_id": id}, len(client.send), cap(client.send)})
+		}
+	}
+
+	w.Header("bsync_websocket_send_queue_length", "Messages queued for a WebSocket client.", metrics.TypeGauge)
+	for _, queue := range queues {
+		w.Sample("bsync_websocket_send_queue_length", queue.labels, float64(queue.length))
+	}
+	w.Header("bsync_websocket_send_queue_capacity", "Capacity of the send queue of a WebSocket client.", metrics.TypeGauge)
+	for _, queue := range queues {
+		w.Sample("bsync_websocket_send_queue_capacity", queue.labels, float64(queue.capacity))
+	}
+}
+
+// collectEventMetrics writes event store and sync state manager statistics
+func (s *SyncToolServer) collectEventMetrics(w *metrics.Writer) {
+	eventsProcessed.Write(w)
+	eventsDropped.Write(w)
+
+	if s.eventProcessor == nil {
+		return
+	}
+
+	if stats, err := s.eventProcessor.GetEventStats(""); err != nil {
+		log.Printf("⚠️  Failed to get event stats for metrics: %v", err)
+	} else {
+		w.Gauge("bsync_events_stored", "Events held by the event store.", float64(stats.TotalEvents))
+		w.Gauge("bsync_event_processing_rate", "Event processing rate in events per second, as reported by the event store.", stats.ProcessingRate)
+
+		w.Header("bsync_events_stored_by_type", "Events held by the event store, by type.", metrics.TypeGauge)
+		for eventType, count := range stats.EventsByType {
+			w.Sample("bsync_events_stored_by_type", metrics.Labels{"type": eventType}, float64(count))
+		}
+	}
+
+	if ssm := s.eventProcessor.syncStateManager; ssm != nil {
+		stats := ssm.GetStats()
+		for _, counter := range []struct{ key, name, help string }{
+			{"processed_events", "bsync_sync_state_processed_events_total", "File transfer events processed by the sync state manager."},
+			{"deduplicated_events", "bsync_sync_state_deduplicated_events_total", "File transfer events dropped as duplicates."},
+			{"conflict_events", "bsync_sync_state_conflict_events_total", "File transfer events that conflicted with the recorded state."},
+		} {
+			w.Counter(counter.name, counter.help, toFloat(stats[counter.key]))
+		}
+		w.Gauge("bsync_sync_state_active_transfers", "File transfers in progress.", toFloat(stats["active_transfers"]))
+		w.Gauge("bsync_sync_state_cached_events", "Events cached for deduplication.", toFloat(stats["cached_events"]))
+	}
+}
+
+// collectSchedulerMetrics writes scheduled job counts per schedule type
+func (s *SyncToolServer) collectSchedulerMetrics(w *metrics.Writer) {
+	if s.scheduler == nil {
+		w.Gauge("bsync_scheduler_running", "Whether the job scheduler is running.", 0)
+		return
+	}
+
+	status, err := s.scheduler.GetScheduledJobsStatus()
+	if err != nil {
+		log.Printf("⚠️  Failed to get scheduler status for metrics: %v", err)
+		return
+	}
+
+	running, _ := status["scheduler_running"].(bool)
+	w.Gauge("bsync_scheduler_running", "Whether the job scheduler is running.", metrics.Bool(running))
+
+	schedules, _ := status["schedules"].(map[string]interface{})
+	w.Header("bsync_scheduled_jobs", "Active scheduled jobs by schedule type.", metrics.TypeGauge)
+	for scheduleType, value := range schedules {
+		if schedule, ok := value.(map[string]interface{}); ok {
+			w.Sample("bsync_scheduled_jobs", metrics.Labels{"schedule_type": scheduleType}, toFloat(schedule["total_jobs"]))
+		}
+	}
+	w.Header("bsync_scheduled_jobs_ready_to_run", "Scheduled jobs whose next run is due.", metrics.TypeGauge)
+	for scheduleType, value := range schedules {
+		if schedule, ok := value.(map[string]interface{}); ok {
+			w.Sample("bsync_scheduled_jobs_ready_to_run", metrics.Labels{"schedule_type": scheduleType}, toFloat(schedule["ready_to_run"]))
+		}
+	}
+}
+
+// collectJobMetrics writes files and bytes synced per job, summed over recorded sync sessions
+func (s *SyncToolServer) collectJobMetrics(w *metrics.Writer) {
+	if s.db == nil {
+		return
+	}
+
+	rows, err := s.db.Query(`
+		SELECT job_id, COALESCE(MAX(job_name), ''), COUNT(*),
+			COALESCE(SUM(files_transferred), 0), COALESCE(SUM(total_delta_bytes), 0), COALESCE(SUM(total_full_file_size), 0)
+		FROM sync_sessions
+		GROUP BY job_id
+	`)
+	if err != nil {
+		log.Printf("⚠️  Failed to query job stats for metrics: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	type jobStats struct {
+		labels                                 metrics.Labels
+		sessions, files, deltaBytes, fullBytes float64
+	}
+	jobs := []jobStats{}
+	for rows.Next() {
+		var jobID, jobName string
+		var job jobStats
+		if err := rows.Scan(&jobID, &jobName, &job.sessions, &job.files, &job.deltaBytes, &job.fullBytes); err != nil {
+			log.Printf("⚠️  Failed to scan job stats for metrics: %v", err)
+			return
+		}
+		job.labels = metrics.Labels{"job_id": jobID, "job_name": jobName}
+		jobs = append(jobs, job)
+	}
+
+	w.Header("bsync_job_sessions_total", "Sync sessions recorded for the job.", metrics.TypeCounter)
+	for _, job := range jobs {
+		w.Sample("bsync_job_sessions_total", job.labels, job.sessions)
+	}
+	w.Header("bsync_job_files_synced_total", "Files transferred by the job.", metrics.TypeCounter)
+	for _, job := range jobs {
+		w.Sample("bsync_job_files_synced_total", job.labels, job.files)
+	}
+	w.Header("bsync_job_bytes_synced_total", "Bytes actually transferred by the job (delta).", metrics.TypeCounter)
+	for _, job := range jobs {
+		w.Sample("bsync_job_bytes_synced_total", job.labels, job.deltaBytes)
+	}
+	w.Header("bsync_job_file_bytes_synced_total", "Combined size of the files transferred by the job.", metrics.TypeCounter)
+	for _, job := range jobs {
+		w.Sample("bsync_job_file_bytes_synced_total", job.labels, job.fullBytes)
+	}
+}
+
+// collectDBMetrics writes connection pool statistics and query latency
+func (s *SyncToolServer) collectDBMetrics(w *metrics.Writer) {
+	w.Gauge("bsync_db_up", "Whether the database is available.", metrics.Bool(s.db != nil))
+	if s.db == nil {
+		return
+	}
+
+	stats := s.db.Stats()
+	w.Gauge("bsync_db_open_connections", "Open database connections.", float64(stats.OpenConnections))
+	w.Gauge("bsync_db_in_use_connections", "Database connections in use.", float64(stats.InUse))
+	w.Gauge("bsync_db_idle_connections", "Idle database connections.", float64(stats.Idle))
+	w.Counter("bsync_db_wait_count_total", "Times a query waited for a free connection.", float64(stats.WaitCount))
+	w.Counter("bsync_db_wait_duration_seconds_total", "Time spent waiting for a free connection.", stats.WaitDuration.Seconds())
+
+	dbQueryDuration.Write(w)
+}
+
+// toFloat converts a numeric stats value to float64
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
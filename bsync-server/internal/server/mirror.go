@@ -0,0 +1,407 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+
+	"bsync-server/pkg/protocol"
+)
+
+// Job types stored in sync_jobs.job_type
+const (
+	JobTypeSync   = "sync"   // long-lived folder, continuous or scheduled
+	JobTypeMirror = "mirror" // one-shot copy, removed from the agents once complete
+)
+
+// Mirror states stored in sync_jobs.mirror_status (and sync_jobs.status once finished)
+const (
+	mirrorPending   = "pending" // deployed, waiting for the source scan
+	mirrorSyncing   = "syncing"
+	mirrorCompleted = "completed"
+	mirrorFailed    = "failed"
+)
+
+// mirrorSettleChecks is the number of consecutive checks every destination must be complete,
+// so a destination that has not received the full index yet is not taken for finished
+const mirrorSettleChecks = 2
+
+// MirrorDestinationProgress is the folder state of a mirror destination at the last check
+type MirrorDestinationProgress struct {
+	AgentID     string    `json:"agent_id"`
+	State       string    `json:"state,omitempty"`
+	NeedFiles   int64     `json:"need_files"`
+	NeedBytes   int64     `json:"need_bytes"`
+	GlobalFiles int64     `json:"global_files"`
+	LocalFiles  int64     `json:"local_files"`
+	LocalBytes  int64     `json:"local_bytes"`
+	Errors      int       `json:"errors"` // files the destination failed to pull
+	Complete    bool      `json:"complete"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// mirrorFolderStats is the part of an agent's folder status used by mirror checks
+type mirrorFolderStats struct {
+	State       string   `json:"state"`
+	GlobalFiles int64    `json:"globalFiles"`
+	GlobalBytes int64    `json:"globalBytes"`
+	LocalFiles  int64    `json:"localFiles"`
+	LocalBytes  int64    `json:"localBytes"`
+	NeedFiles   int64    `json:"needFiles"`
+	NeedBytes   int64    `json:"needBytes"`
+	Errors      []string `json:"errors"`
+}
+
+// jobTypeFromJobData reads "job_type" from a create request body (default sync)
+func jobTypeFromJobData(jobData map[string]interface{}) (string, error) {
+	jobType, _ := jobData["job_type"].(string)
+	switch jobType {
+	case "", JobTypeSync:
+		return JobTypeSync, nil
+	case JobTypeMirror:
+		return JobTypeMirror, nil
+	}
+	return "", fmt.Errorf("unknown job_type %q (expected sync or mirror)", jobType)
+}
+
+// validateMirrorJob checks the fields a mirror job cannot combine with: it runs once, one-way
+func validateMirrorJob(jobData map[string]interface{}, schedule *JobSchedule) error {
+	if schedule.IsScheduled() {
+		return fmt.Errorf("mirror jobs run once and cannot have a schedule")
+	}
+	if raw, ok := jobData["verify_schedule"]; ok && raw != nil && raw != "" {
+		return fmt.Errorf("mirror jobs are removed when complete and cannot have a verify_schedule")
+	}
+	if syncType, _ := jobData["sync_type"].(string); syncType != "" && syncType != "sendonly" {
+		return fmt.Errorf("mirror jobs are one-way, sync_type must be sendonly")
+	}
+	if syncMode, _ := jobData["sync_mode"].(string); syncMode != "" && syncMode != "one-way" {
+		return fmt.Errorf("mirror jobs are one-way, sync_mode must be one-way")
+	}
+	return nil
+}
+
+// jobMirrorStatus returns the mirror state of a job, "" for sync jobs
+func (s *SyncToolServer) jobMirrorStatus(jobID string) string {
+	var mirrorStatus sql.NullString
+	s.db.QueryRow(`SELECT mirror_status FROM sync_jobs WHERE id = $1 AND job_type = 'mirror'`, jobID).Scan(&mirrorStatus)
+	return mirrorStatus.String
+}
+
+// isFinishedMirror returns true for mirror states whose folders were removed from the agents
+func isFinishedMirror(mirrorStatus string) bool {
+	return mirrorStatus == mirrorCompleted || mirrorStatus == mirrorFailed
+}
+
+// requestFolderStats asks an agent for the current state of a job folder
+func (s *SyncToolServer) requestFolderStats(agentID, jobID string) (*mirrorFolderStats, error) {
+	response, err := s.requestFromAgent(agentID, map[string]interface{}{
+		"type":      "get_folder_stats",
+		"folder_id": fmt.Sprintf("job-%s", jobID),
+	}, agentRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(response["stats"])
+	if err != nil {
+		return nil, err
+	}
+	var stats mirrorFolderStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("invalid folder stats: %v", err)
+	}
+	return &stats, nil
+}
+
+// runMirrorMonitor follows running mirror jobs until they are complete and removed from the agents
+func (s *SyncToolServer) runMirrorMonitor() {
+	ticker := time.NewTicker(s.config.Mirror.CheckInterval)
+	defer ticker.Stop()
+
+	// Consecutive checks each running mirror was complete, only read by this goroutine
+	settled := make(map[string]int)
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			s.checkMirrorJobs(settled)
+		}
+	}
+}
+
+// checkMirrorJobs checks the progress of running mirrors and retries pending teardowns
+func (s *SyncToolServer) checkMirrorJobs(settled map[string]int) {
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(mirror_status, 'pending'), COALESCE(mirror_started_at, created_at), mirror_teardown_pending
+		FROM sync_jobs
+		WHERE job_type = 'mirror'
+		AND (mirror_status IN ('pending', 'syncing') OR mirror_teardown_pending IS NOT NULL)
+	`)
+	if err != nil {
+		log.Printf("❌ Failed to query mirror jobs: %v", err)
+		return
+	}
+
+	type mirrorJob struct {
+		jobID, name, status string
+		startedAt           time.Time
+		teardown            pq.StringArray
+	}
+	var jobs []mirrorJob
+	for rows.Next() {
+		var job mirrorJob
+		if err := rows.Scan(&job.jobID, &job.name, &job.status, &job.startedAt, &job.teardown); err != nil {
+			log.Printf("❌ Failed to scan mirror job: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	for _, job := range jobs {
+		if job.teardown != nil {
+			s.teardownMirror(job.jobID, job.teardown)
+			continue
+		}
+		s.checkMirrorJob(job.jobID, job.name, job.status, job.startedAt, settled)
+	}
+}
+
+// checkMirrorJob compares the folder state of the destinations of a mirror with its source and
+// finishes the mirror once every destination has all files, or when it runs out of time
+func (s *SyncToolServer) checkMirrorJob(jobID, name, status string, startedAt time.Time, settled map[string]int) {
+	agentIDs, err := s.jobAgentIDs(jobID)
+	if err != nil {
+		log.Printf("❌ Failed to check mirror job %s: %v", jobID, err)
+		return
+	}
+	sourceAgentID, destinationAgentIDs := agentIDs[0], agentIDs[1:]
+
+	if timeout := s.config.Mirror.Timeout; timeout > 0 && time.Since(startedAt) > timeout {
+		s.finishMirror(jobID, name, startedAt, agentIDs, mirrorFailed, fmt.Sprintf("Not complete after %v", timeout), settled)
+		return
+	}
+
+	source, err := s.requestFolderStats(sourceAgentID, jobID)
+	if err != nil {
+		log.Printf("⚠️ Mirror job %s: no folder state from source %s: %v", jobID, sourceAgentID, err)
+		settled[jobID] = 0
+		return
+	}
+	// Destinations only know the full file list once the source finished scanning
+	if source.State != "idle" {
+		settled[jobID] = 0
+		return
+	}
+	if status == mirrorPending {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET mirror_status = $1, updated_at = $2 WHERE id = $3`, mirrorSyncing, time.Now(), jobID); err != nil {
+			log.Printf("❌ Failed to update mirror job %s: %v", jobID, err)
+		}
+		log.Printf("🪞 Mirror job %s syncing %d file(s) to %d destination(s)", jobID, source.GlobalFiles, len(destinationAgentIDs))
+	}
+
+	progress := make([]MirrorDestinationProgress, 0, len(destinationAgentIDs))
+	allComplete := true
+	for _, agentID := range destinationAgentIDs {
+		entry := MirrorDestinationProgress{AgentID: agentID, CheckedAt: time.Now()}
+		stats, err := s.requestFolderStats(agentID, jobID)
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.State = stats.State
+			entry.NeedFiles = stats.NeedFiles
+			entry.NeedBytes = stats.NeedBytes
+			entry.GlobalFiles = stats.GlobalFiles
+			entry.LocalFiles = stats.LocalFiles
+			entry.LocalBytes = stats.LocalBytes
+			entry.Errors = len(stats.Errors)
+			// Same global file list as the source, i.e. the destination received the full index
+			entry.Complete = stats.State == "idle" && stats.NeedFiles == 0 && stats.NeedBytes == 0 &&
+				stats.GlobalFiles == source.GlobalFiles && stats.GlobalBytes == source.GlobalBytes
+		}
+		allComplete = allComplete && entry.Complete
+		progress = append(progress, entry)
+	}
+
+	progressJSON, _ := json.Marshal(progress)
+	if _, err := s.db.Exec(`UPDATE sync_jobs SET mirror_progress = $1 WHERE id = $2`, string(progressJSON), jobID); err != nil {
+		log.Printf("❌ Failed to store progress of mirror job %s: %v", jobID, err)
+	}
+
+	if !allComplete {
+		settled[jobID] = 0
+		return
+	}
+	settled[jobID]++
+	if settled[jobID] >= mirrorSettleChecks {
+		s.finishMirror(jobID, name, startedAt, agentIDs, mirrorCompleted, "", settled)
+	}
+}
+
+// finishMirror records the final state and sessions of a mirror and removes its folders from the agents
+func (s *SyncToolServer) finishMirror(jobID, name string, startedAt time.Time, agentIDs []string, status, errMsg string, settled map[string]int) {
+	delete(settled, jobID)
+	now := time.Now()
+
+	_, err := s.db.Exec(`
+		UPDATE sync_jobs
+		SET mirror_status = $1, status = $1, mirror_error = $2, mirror_completed_at = $3, mirror_teardown_pending = $4, updated_at = $3
+		WHERE id = $5
+	`, status, nullIfEmpty(errMsg), now, pq.Array(agentIDs), jobID)
+	if err != nil {
+		log.Printf("❌ Failed to finish mirror job %s: %v", jobID, err)
+		return
+	}
+
+	if status == mirrorCompleted {
+		log.Printf("✅ Mirror job %s completed in %v", jobID, now.Sub(startedAt).Round(time.Second))
+	} else {
+		log.Printf("❌ Mirror job %s failed: %s", jobID, errMsg)
+	}
+
+	// One final session per destination with the state of its copy at the last check
+	var progressJSON sql.NullString
+	s.db.QueryRow(`SELECT mirror_progress FROM sync_jobs WHERE id = $1`, jobID).Scan(&progressJSON)
+	var progress []MirrorDestinationProgress
+	if progressJSON.Valid {
+		json.Unmarshal([]byte(progressJSON.String), &progress)
+	}
+	progressByAgent := make(map[string]MirrorDestinationProgress, len(progress))
+	for _, entry := range progress {
+		progressByAgent[entry.AgentID] = entry
+	}
+
+	duration := int64(now.Sub(startedAt).Seconds())
+	for _, agentID := range agentIDs[1:] {
+		entry := progressByAgent[agentID]
+		sessionID := fmt.Sprintf("mirror-%s-%s", jobID, agentID)
+		if _, err := s.db.Exec(`
+			INSERT INTO sync_sessions (
+				session_id, job_id, job_name, agent_id, session_start_time, session_end_time, total_duration_seconds,
+				files_scanned, bytes_scanned, files_transferred, total_full_file_size, current_state, status, error_message, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW())
+			ON CONFLICT (session_id) DO NOTHING
+		`, sessionID, fmt.Sprintf("job-%s", jobID), name, agentID, startedAt, now, duration,
+			entry.GlobalFiles, entry.LocalBytes, entry.LocalFiles, entry.LocalBytes, status, status, nullIfEmpty(errMsg)); err != nil {
+			log.Printf("❌ Failed to record final session of mirror job %s on %s: %v", jobID, agentID, err)
+		}
+	}
+
+	go s.emitWebhookEvent(WebhookEventMirrorCompleted, map[string]interface{}{
+		"job_id":           jobID,
+		"job_name":         name,
+		"status":           status,
+		"error":            errMsg,
+		"started_at":       startedAt,
+		"completed_at":     now,
+		"duration_seconds": duration,
+		"destinations":     progress,
+	})
+
+	s.teardownMirror(jobID, agentIDs)
+
+	// Jobs depending on the mirror start once every destination has the full copy
+	if status == mirrorCompleted {
+		go s.triggerDependentJobs(jobID, "", fmt.Sprintf("mirror-%s", jobID))
+	}
+}
+
+// teardownMirror removes the job folder from the agents of a finished mirror. Agents that are
+// offline stay in mirror_teardown_pending and are retried on the next check.
+func (s *SyncToolServer) teardownMirror(jobID string, agentIDs []string) {
+	deleteConfig := protocol.ToMap(protocol.NewJobControl(protocol.TypeDeleteJob, jobID, ""))
+	folderID := fmt.Sprintf("job-%s", jobID)
+
+	remaining := []string{}
+	for _, agentID := range agentIDs {
+		if err := s.sendJobToAgent(agentID, deleteConfig); err != nil {
+			remaining = append(remaining, agentID)
+			continue
+		}
+		s.clearFolderStatsForFolder(agentID, folderID)
+	}
+
+	var pending interface{}
+	if len(remaining) > 0 {
+		pending = pq.Array(remaining)
+		log.Printf("⏳ Mirror job %s: folder removal pending on %d offline agent(s)", jobID, len(remaining))
+	} else {
+		log.Printf("🗑️ Mirror job %s removed from all agents", jobID)
+	}
+	if _, err := s.db.Exec(`UPDATE sync_jobs SET mirror_teardown_pending = $1 WHERE id = $2`, pending, jobID); err != nil {
+		log.Printf("❌ Failed to update teardown of mirror job %s: %v", jobID, err)
+	}
+}
+
+// retryMirrorTeardown sends the folder removal once more to the agents a finished mirror could not reach
+func (s *SyncToolServer) retryMirrorTeardown(jobID string) {
+	var teardown pq.StringArray
+	if err := s.db.QueryRow(`SELECT mirror_teardown_pending FROM sync_jobs WHERE id = $1`, jobID).Scan(&teardown); err != nil || teardown == nil {
+		return
+	}
+	s.teardownMirror(jobID, teardown)
+}
+
+// handleJobMirror returns the state of a mirror job: GET /api/v1/sync-jobs/{id}/mirror
+func (s *SyncToolServer) handleJobMirror(w http.ResponseWriter, r *http.Request, jobID string) {
+	if r.Method != "GET" {
+		http.Error(w, `{"error": "Only GET method allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var jobType string
+	var mirrorStatus, mirrorError, progressJSON sql.NullString
+	var startedAt, completedAt *time.Time
+	var teardown pq.StringArray
+	err := s.db.QueryRow(`
+		SELECT COALESCE(job_type, 'sync'), mirror_status, mirror_started_at, mirror_completed_at, mirror_error, mirror_progress, mirror_teardown_pending
+		FROM sync_jobs WHERE id = $1
+	`, jobID).Scan(&jobType, &mirrorStatus, &startedAt, &completedAt, &mirrorError, &progressJSON, &teardown)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get mirror state of job %s: %v", jobID, err)
+		http.Error(w, `{"error": "Failed to get mirror state"}`, http.StatusInternalServerError)
+		return
+	}
+	if jobType != JobTypeMirror {
+		http.Error(w, `{"error": "Not a mirror job"}`, http.StatusBadRequest)
+		return
+	}
+
+	progress := []MirrorDestinationProgress{}
+	if progressJSON.Valid {
+		json.Unmarshal([]byte(progressJSON.String), &progress)
+	}
+	finished := mirrorStatus.String == mirrorCompleted || mirrorStatus.String == mirrorFailed
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":           jobID,
+		"status":           mirrorStatus.String,
+		"started_at":       startedAt,
+		"completed_at":     completedAt,
+		"error":            mirrorError.String,
+		"destinations":     progress,
+		"torn_down":        finished && teardown == nil,
+		"teardown_pending": teardown,
+	})
+}
+
+// writeMirrorJobError writes a 400 response for an invalid mirror job
+func writeMirrorJobError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid mirror job: %v", err),
+	})
+}
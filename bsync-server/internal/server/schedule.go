@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule types stored in sync_jobs.schedule_type
+const (
+	ScheduleContinuous = "continuous"
+	ScheduleHourly     = "hourly"
+	ScheduleDaily      = "daily"
+	ScheduleCron       = "cron"
+)
+
+const (
+	defaultScheduleTimezone = "UTC"
+	defaultPreviewRuns      = 5
+	maxPreviewRuns          = 50
+)
+
+// cronParser accepts standard 5-field expressions and descriptors (@daily, @every 15m, ...)
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// JobSchedule describes when a scheduled sync job runs
+type JobSchedule struct {
+	Type           string `json:"schedule_type"`
+	CronExpression string `json:"cron_expression,omitempty"`
+	Timezone       string `json:"timezone"`
+
+	location *time.Location
+	cron     cron.Schedule
+}
+
+// ParseJobSchedule validates a schedule type, cron expression and IANA time zone.
+// A cron expression passed directly as the schedule type is accepted as well.
+func ParseJobSchedule(scheduleType, cronExpression, timezone string) (*JobSchedule, error) {
+	scheduleType = strings.TrimSpace(scheduleType)
+	cronExpression = strings.TrimSpace(cronExpression)
+	timezone = strings.TrimSpace(timezone)
+
+	if scheduleType == "" {
+		scheduleType = ScheduleContinuous
+	}
+	if timezone == "" {
+		timezone = defaultScheduleTimezone
+	}
+
+	// Allow "schedule": "30 2 * * 1-5" as shorthand for a cron schedule
+	switch scheduleType {
+	case ScheduleContinuous, ScheduleHourly, ScheduleDaily, ScheduleCron:
+	default:
+		if cronExpression != "" || !strings.ContainsAny(scheduleType, " @") {
+			return nil, fmt.Errorf("unknown schedule type %q (expected continuous, hourly, daily or cron)", scheduleType)
+		}
+		cronExpression = scheduleType
+		scheduleType = ScheduleCron
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: must be an IANA name such as Europe/Berlin", timezone)
+	}
+
+	schedule := &JobSchedule{
+		Type:     scheduleType,
+		Timezone: timezone,
+		location: location,
+	}
+
+	if scheduleType == ScheduleCron {
+		if cronExpression == "" {
+			return nil, fmt.Errorf("cron_expression is required for schedule type \"cron\"")
+		}
+		parsed, err := cronParser.Parse(cronExpression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", cronExpression, err)
+		}
+		schedule.CronExpression = cronExpression
+		schedule.cron = parsed
+	}
+
+	return schedule, nil
+}
+
+// IsScheduled returns true if the job runs on a schedule instead of continuously
+func (js *JobSchedule) IsScheduled() bool {
+	return js.Type != ScheduleContinuous
+}
+
+// Next returns the first run time after lastRun (or after now if the job never ran)
+func (js *JobSchedule) Next(lastRun *time.Time) time.Time {
+	now := time.Now().In(js.location)
+
+	switch js.Type {
+	case ScheduleHourly:
+		if lastRun == nil {
+			// First run: next full hour
+			return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, js.location)
+		}
+		return lastRun.Add(1 * time.Hour)
+
+	case ScheduleDaily:
+		from := now
+		if lastRun != nil {
+			from = lastRun.In(js.location)
+		}
+		// Next midnight in the job's time zone (DST-safe, unlike adding 24h)
+		return time.Date(from.Year(), from.Month(), from.Day()+1, 0, 0, 0, 0, js.location)
+
+	case ScheduleCron:
+		from := now
+		if lastRun != nil && lastRun.After(now) {
+			from = lastRun.In(js.location)
+		}
+		return js.cron.Next(from)
+	}
+
+	return time.Time{}
+}
+
+// NextRuns returns the next n run times starting from now
+func (js *JobSchedule) NextRuns(n int) []time.Time {
+	if !js.IsScheduled() || n <= 0 {
+		return []time.Time{}
+	}
+
+	runs := make([]time.Time, 0, n)
+	var last *time.Time
+	for i := 0; i < n; i++ {
+		next := js.Next(last)
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+		last = &next
+	}
+	return runs
+}
+
+// formatScheduleRuns formats run times as RFC3339 in the job's time zone
+func formatScheduleRuns(runs []time.Time) []string {
+	formatted := make([]string, 0, len(runs))
+	for _, run := range runs {
+		formatted = append(formatted, run.Format(time.RFC3339))
+	}
+	return formatted
+}
+
+// parseScheduleFromJobData reads schedule fields from a create/update request body.
+// Supports both "schedule" and "schedule_type" for the type.
+func parseScheduleFromJobData(jobData map[string]interface{}) (*JobSchedule, error) {
+	scheduleType := ScheduleContinuous
+	if sched, ok := jobData["schedule_type"].(string); ok && sched != "" {
+		scheduleType = sched
+	} else if sched, ok := jobData["schedule"].(string); ok && sched != "" {
+		scheduleType = sched
+	}
+
+	cronExpression, _ := jobData["cron_expression"].(string)
+	timezone, _ := jobData["timezone"].(string)
+
+	return ParseJobSchedule(scheduleType, cronExpression, timezone)
+}
+
+// previewRunCount reads the requested number of preview run times from the request body
+func previewRunCount(jobData map[string]interface{}) int {
+	n := defaultPreviewRuns
+	if v, ok := jobData["preview_runs"].(float64); ok {
+		n = int(v)
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > maxPreviewRuns {
+		n = maxPreviewRuns
+	}
+	return n
+}
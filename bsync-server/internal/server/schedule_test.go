@@ -0,0 +1,115 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJobSchedule(t *testing.T) {
+	tests := []struct {
+		name           string
+		scheduleType   string
+		cronExpression string
+		timezone       string
+		wantType       string
+		wantCron       string
+		wantTimezone   string
+		wantErr        bool
+	}{
+		{name: "empty defaults to continuous", wantType: ScheduleContinuous, wantTimezone: "UTC"},
+		{name: "daily with time zone", scheduleType: "daily", timezone: "Europe/Berlin", wantType: ScheduleDaily, wantTimezone: "Europe/Berlin"},
+		{name: "cron", scheduleType: "cron", cronExpression: "30 2 * * 1-5", wantType: ScheduleCron, wantCron: "30 2 * * 1-5", wantTimezone: "UTC"},
+		{name: "cron shorthand", scheduleType: "30 2 * * 1-5", wantType: ScheduleCron, wantCron: "30 2 * * 1-5", wantTimezone: "UTC"},
+		{name: "descriptor shorthand", scheduleType: "@every 15m", wantType: ScheduleCron, wantCron: "@every 15m", wantTimezone: "UTC"},
+		{name: "surrounding whitespace", scheduleType: " cron ", cronExpression: " 0 * * * * ", timezone: " UTC ", wantType: ScheduleCron, wantCron: "0 * * * *", wantTimezone: "UTC"},
+		{name: "cron without expression", scheduleType: "cron", wantErr: true},
+		{name: "invalid cron expression", scheduleType: "cron", cronExpression: "61 * * * *", wantErr: true},
+		{name: "six fields", scheduleType: "cron", cronExpression: "0 0 2 * * *", wantErr: true},
+		{name: "unknown type", scheduleType: "weekly", wantErr: true},
+		{name: "unknown type with expression", scheduleType: "0 2 * * *", cronExpression: "0 3 * * *", wantErr: true},
+		{name: "invalid time zone", scheduleType: "daily", timezone: "Mars/Olympus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseJobSchedule(tt.scheduleType, tt.cronExpression, tt.timezone)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got schedule %+v", schedule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if schedule.Type != tt.wantType || schedule.CronExpression != tt.wantCron || schedule.Timezone != tt.wantTimezone {
+				t.Errorf("got %q/%q/%q, want %q/%q/%q", schedule.Type, schedule.CronExpression, schedule.Timezone,
+					tt.wantType, tt.wantCron, tt.wantTimezone)
+			}
+		})
+	}
+}
+
+func TestJobScheduleNext(t *testing.T) {
+	// Last runs lie in the future so Next doesn't depend on the current time
+	tests := []struct {
+		name           string
+		scheduleType   string
+		cronExpression string
+		timezone       string
+		lastRun        string
+		want           string
+	}{
+		{name: "hourly", scheduleType: ScheduleHourly, lastRun: "2030-03-08T10:15:00Z", want: "2030-03-08T11:15:00Z"},
+		{name: "daily UTC", scheduleType: ScheduleDaily, lastRun: "2030-03-08T23:59:00Z", want: "2030-03-09T00:00:00Z"},
+		{name: "daily before DST change", scheduleType: ScheduleDaily, timezone: "Europe/Berlin", lastRun: "2030-03-30T10:00:00+01:00", want: "2030-03-30T23:00:00Z"},
+		{name: "daily after DST change", scheduleType: ScheduleDaily, timezone: "Europe/Berlin", lastRun: "2030-03-31T10:00:00+02:00", want: "2030-03-31T22:00:00Z"},
+		{name: "daily in job time zone", scheduleType: ScheduleDaily, timezone: "Asia/Tokyo", lastRun: "2030-03-08T16:00:00Z", want: "2030-03-09T15:00:00Z"},
+		{name: "cron UTC", scheduleType: ScheduleCron, cronExpression: "30 2 * * *", lastRun: "2030-03-08T03:00:00Z", want: "2030-03-09T02:30:00Z"},
+		{name: "cron weekdays skips weekend", scheduleType: ScheduleCron, cronExpression: "0 9 * * 1-5", timezone: "Europe/Berlin", lastRun: "2030-03-29T10:00:00+01:00", want: "2030-04-01T07:00:00Z"},
+		{name: "cron across US DST change", scheduleType: ScheduleCron, cronExpression: "0 9 * * 1-5", timezone: "America/New_York", lastRun: "2030-03-08T10:00:00-05:00", want: "2030-03-11T13:00:00Z"},
+		{name: "cron descriptor", scheduleType: ScheduleCron, cronExpression: "@every 15m", lastRun: "2030-03-08T10:00:00Z", want: "2030-03-08T10:15:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseJobSchedule(tt.scheduleType, tt.cronExpression, tt.timezone)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			lastRun, err := time.Parse(time.RFC3339, tt.lastRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := time.Parse(time.RFC3339, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := schedule.Next(&lastRun); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.lastRun, got.UTC().Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestJobScheduleNextRuns(t *testing.T) {
+	continuous, _ := ParseJobSchedule(ScheduleContinuous, "", "")
+	if runs := continuous.NextRuns(5); len(runs) != 0 {
+		t.Errorf("continuous schedule returned %d runs, want none", len(runs))
+	}
+
+	hourly, _ := ParseJobSchedule(ScheduleHourly, "", "Europe/Berlin")
+	runs := hourly.NextRuns(3)
+	if len(runs) != 3 {
+		t.Fatalf("got %d runs, want 3", len(runs))
+	}
+	if runs[0].Minute() != 0 || runs[0].Second() != 0 || !runs[0].After(time.Now()) {
+		t.Errorf("first hourly run %s is not the next full hour", runs[0])
+	}
+	for i := 1; i < len(runs); i++ {
+		if runs[i].Sub(runs[i-1]) != time.Hour {
+			t.Errorf("run %d is %s after the previous one, want 1h", i, runs[i].Sub(runs[i-1]))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 )
 
@@ -13,6 +14,8 @@ type ScheduledJob struct {
 	SourceAgentID    string    `json:"source_agent_id"`
 	TargetAgentID    string    `json:"target_agent_id"`
 	ScheduleType     string    `json:"schedule_type"`
+	CronExpression   string    `json:"cron_expression"`
+	Timezone         string    `json:"timezone"`
 	Status           string    `json:"status"`
 	LastScheduledRun *time.Time `json:"last_scheduled_run"`
 	NextScheduledRun *time.Time `json:"next_scheduled_run"`
@@ -34,7 +37,7 @@ func NewJobScheduler(server *SyncToolServer) *JobScheduler {
 	}
 }
 
-// Start begins the scheduler with a ticker that runs every minute
+// Start begins the scheduler with a ticker that runs every check interval (default one minute)
 func (js *JobScheduler) Start() {
 	if js.running {
 		log.Println("⚠️ Job scheduler is already running")
@@ -49,19 +52,32 @@ func (js *JobScheduler) Start() {
 		log.Printf("❌ Failed to initialize schedules: %v", err)
 	}
 
-	// Create ticker that runs every minute
-	ticker := time.NewTicker(1 * time.Minute)
+	// Apply maintenance windows right away instead of waiting for the first tick
+	if err := js.server.enforceMaintenanceWindows(); err != nil {
+		log.Printf("❌ Failed to enforce maintenance windows: %v", err)
+	}
+
+	// Create ticker that runs every check interval
+	interval := js.checkInterval()
+	ticker := time.NewTicker(interval)
 	
 	go func() {
 		defer ticker.Stop()
-		log.Println("✅ Job scheduler started - checking every minute")
+		log.Printf("✅ Job scheduler started - checking every %s", interval)
 
 		for {
 			select {
 			case <-ticker.C:
+				// Windows first, so a job paused by a window is not triggered below
+				if err := js.server.enforceMaintenanceWindows(); err != nil {
+					log.Printf("❌ Error enforcing maintenance windows: %v", err)
+				}
 				if err := js.processScheduledJobs(); err != nil {
 					log.Printf("❌ Error processing scheduled jobs: %v", err)
 				}
+				if err := js.server.runDueVerifications(); err != nil {
+					log.Printf("❌ Error starting scheduled verifications: %v", err)
+				}
 			case <-js.stopChan:
 				log.Println("🛑 Job scheduler stopping...")
 				js.running = false
@@ -81,13 +97,29 @@ func (js *JobScheduler) Stop() {
 	close(js.stopChan)
 }
 
+// checkInterval returns the configured scheduler tick interval
+func (js *JobScheduler) checkInterval() time.Duration {
+	if js.server.config != nil && js.server.config.Scheduler.CheckInterval > 0 {
+		return js.server.config.Scheduler.CheckInterval
+	}
+	return 1 * time.Minute
+}
+
+// batchSize returns the maximum number of jobs executed per tick
+func (js *JobScheduler) batchSize() int {
+	if js.server.config != nil && js.server.config.Scheduler.BatchSize > 0 {
+		return js.server.config.Scheduler.BatchSize
+	}
+	return 50
+}
+
 // initializeSchedules sets up next_scheduled_run for jobs that don't have it set
 func (js *JobScheduler) initializeSchedules() error {
 	log.Println("🔧 Initializing schedules for jobs without next_scheduled_run...")
 
 	// Get jobs that need initialization
 	rows, err := js.server.db.Query(`
-		SELECT id, schedule_type, last_scheduled_run
+		SELECT id, schedule_type, COALESCE(cron_expression, ''), COALESCE(timezone, ''), last_scheduled_run
 		FROM sync_jobs 
 		WHERE schedule_type != 'continuous' 
 		AND status = 'active'
@@ -101,19 +133,23 @@ func (js *JobScheduler) initializeSchedules() error {
 	var updateCount int
 	for rows.Next() {
 		var jobID int
-		var scheduleType string
+		var scheduleType, cronExpression, timezone string
 		var lastRun *time.Time
 
-		if err := rows.Scan(&jobID, &scheduleType, &lastRun); err != nil {
+		if err := rows.Scan(&jobID, &scheduleType, &cronExpression, &timezone, &lastRun); err != nil {
 			log.Printf("❌ Failed to scan job row: %v", err)
 			continue
 		}
 
 		// Calculate next run time
-		nextRun := js.calculateNextRun(scheduleType, lastRun)
+		nextRun, err := js.calculateNextRun(scheduleType, cronExpression, timezone, lastRun)
+		if err != nil {
+			log.Printf("❌ Invalid schedule for job %d: %v", jobID, err)
+			continue
+		}
 		
 		// Update the job
-		_, err := js.server.db.Exec(`
+		_, err = js.server.db.Exec(`
 			UPDATE sync_jobs 
 			SET next_scheduled_run = $1, updated_at = $2
 			WHERE id = $3
@@ -126,7 +162,7 @@ func (js *JobScheduler) initializeSchedules() error {
 
 		updateCount++
 		log.Printf("🕒 Initialized schedule for job %d (%s): next run at %s", 
-			jobID, scheduleType, nextRun.Format("2006-01-02 15:04:05"))
+			jobID, scheduleType, nextRun.Format("2006-01-02 15:04:05 MST"))
 	}
 
 	log.Printf("✅ Initialized %d job schedules", updateCount)
@@ -157,6 +193,13 @@ func (js *JobScheduler) processScheduledJobs() error {
 	for _, job := range jobs {
 		if err := js.executeJob(job); err != nil {
 			log.Printf("❌ Failed to execute job %d (%s): %v", job.ID, job.Name, err)
+			go js.server.emitWebhookEvent(WebhookEventJobFailed, map[string]interface{}{
+				"job_id":          strconv.Itoa(job.ID),
+				"job_name":        job.Name,
+				"source_agent_id": job.SourceAgentID,
+				"operation":       "scheduled_run",
+				"message":         err.Error(),
+			})
 		} else {
 			log.Printf("✅ Successfully executed job %d (%s)", job.ID, job.Name)
 		}
@@ -165,18 +208,21 @@ func (js *JobScheduler) processScheduledJobs() error {
 	return nil
 }
 
-// getJobsToRun queries the database for jobs ready to be executed
+// getJobsToRun queries the database for jobs ready to be executed.
+// Jobs paused by a maintenance window are skipped and run once the window opens.
 func (js *JobScheduler) getJobsToRun(now time.Time) ([]ScheduledJob, error) {
 	rows, err := js.server.db.Query(`
-		SELECT id, name, source_agent_id, target_agent_id, schedule_type, status,
+		SELECT id, name, source_agent_id, COALESCE(target_agent_id, ''), schedule_type,
+			   COALESCE(cron_expression, ''), COALESCE(timezone, ''), status,
 			   last_scheduled_run, next_scheduled_run
 		FROM sync_jobs 
 		WHERE schedule_type != 'continuous' 
 		AND status = 'active'
+		AND COALESCE(window_paused, false) = false
 		AND next_scheduled_run <= $1
 		ORDER BY next_scheduled_run ASC
-		LIMIT 50
-	`, now)
+		LIMIT $2
+	`, now, js.batchSize())
 	if err != nil {
 		return nil, err
 	}
@@ -187,7 +233,8 @@ func (js *JobScheduler) getJobsToRun(now time.Time) ([]ScheduledJob, error) {
 		var job ScheduledJob
 		if err := rows.Scan(
 			&job.ID, &job.Name, &job.SourceAgentID, &job.TargetAgentID,
-			&job.ScheduleType, &job.Status, &job.LastScheduledRun, &job.NextScheduledRun,
+			&job.ScheduleType, &job.CronExpression, &job.Timezone, &job.Status,
+			&job.LastScheduledRun, &job.NextScheduledRun,
 		); err != nil {
 			log.Printf("❌ Failed to scan job row: %v", err)
 			continue
@@ -217,7 +264,16 @@ func (js *JobScheduler) executeJob(job ScheduledJob) error {
 	}
 
 	// Update job execution times
-	nextRun := js.calculateNextRun(job.ScheduleType, &now)
+	nextRun, scheduleErr := js.calculateNextRun(job.ScheduleType, job.CronExpression, job.Timezone, &now)
+	if scheduleErr != nil {
+		// Clear next run so an invalid schedule doesn't fire on every tick
+		js.server.db.Exec(`
+			UPDATE sync_jobs 
+			SET last_scheduled_run = $1, next_scheduled_run = NULL, updated_at = $1
+			WHERE id = $2
+		`, now, job.ID)
+		return fmt.Errorf("invalid schedule, job will not run again until fixed: %w", scheduleErr)
+	}
 	
 	_, err := js.server.db.Exec(`
 		UPDATE sync_jobs 
@@ -229,34 +285,21 @@ func (js *JobScheduler) executeJob(job ScheduledJob) error {
 		return fmt.Errorf("failed to update job execution times: %w", err)
 	}
 
-	log.Printf("📅 Job %d next run scheduled for %s", job.ID, nextRun.Format("2006-01-02 15:04:05"))
+	log.Printf("📅 Job %d next run scheduled for %s", job.ID, nextRun.Format("2006-01-02 15:04:05 MST"))
 	return nil
 }
 
-// calculateNextRun determines the next execution time based on schedule type
-func (js *JobScheduler) calculateNextRun(scheduleType string, lastRun *time.Time) time.Time {
-	now := time.Now()
-	
-	switch scheduleType {
-	case "hourly":
-		if lastRun == nil {
-			// First run: next full hour
-			return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location())
-		}
-		return lastRun.Add(1 * time.Hour)
-		
-	case "daily":
-		if lastRun == nil {
-			// First run: tomorrow at midnight
-			return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-		}
-		return lastRun.Add(24 * time.Hour)
-		
-	default:
-		// Default to hourly for unknown schedule types
-		log.Printf("⚠️ Unknown schedule type: %s, defaulting to hourly", scheduleType)
-		return now.Add(1 * time.Hour)
+// calculateNextRun determines the next execution time based on schedule type,
+// cron expression and the job's time zone
+func (js *JobScheduler) calculateNextRun(scheduleType, cronExpression, timezone string, lastRun *time.Time) (time.Time, error) {
+	schedule, err := ParseJobSchedule(scheduleType, cronExpression, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !schedule.IsScheduled() {
+		return time.Time{}, fmt.Errorf("schedule type %q has no next run", scheduleType)
 	}
+	return schedule.Next(lastRun), nil
 }
 
 // GetScheduledJobsStatus returns status of all scheduled jobs for monitoring
@@ -26,19 +26,10 @@ import (
 	"bsync-server/internal/auth"
 	"bsync-server/internal/models"
 	"bsync-server/internal/repository"
+	"bsync-server/pkg/protocol"
 	"bsync-server/utils"
 )
 
-type Config struct {
-	Host     string
-	Port     int
-	LogLevel string
-}
-
-func (c *Config) LoadFromFile(path string) error {
-	return nil
-}
-
 type SyncToolServer struct {
 	config         *Config
 	hub            *Hub
@@ -51,6 +42,10 @@ type SyncToolServer struct {
 	folderStatsMu  sync.RWMutex
 	activeSyncJobs map[string]bool                   // agent_id -> is_syncing
 	syncJobsMu     sync.RWMutex
+	maintenanceMu  sync.Mutex                        // serializes maintenance window enforcement
+	jobImportMu    sync.Mutex                        // serializes declarative job imports
+	agentCA        *AgentCA                          // Issues agent client certificates (nil unless mTLS is enabled)
+	webhookWake    chan struct{}                     // Wakes the webhook dispatcher when events are queued
 
 	// User management
 	userRepo    *repository.UserRepository
@@ -112,14 +107,22 @@ type FileTransferLogQueryBuilder struct {
 }
 
 func NewSyncToolServer(config *Config) (*SyncToolServer, error) {
-	// Create event store (default to memory store with 1000 events buffer)
-	eventStore := NewMemoryEventStore(10000)
-	
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	// Register mail settings for the utils mailer
+	config.applyMailSettings()
+
 	// Connect to database
-	db, err := sql.Open("postgres", "host=localhost user=bsync password=bsync_password dbname=bsync sslmode=disable")
+	db, err := sql.Open(instrumentedPostgresDriver, config.Database.DataSourceName())
 	if err != nil {
 		log.Printf("⚠️  Failed to connect to database: %v", err)
 	} else {
+		db.SetMaxOpenConns(config.Database.MaxOpenConns)
+		db.SetMaxIdleConns(config.Database.MaxIdleConns)
+		db.SetConnMaxLifetime(config.Database.ConnMaxLifetime)
+
 		if err := db.Ping(); err != nil {
 			log.Printf("⚠️  Failed to ping database: %v", err)
 			db = nil
@@ -135,21 +138,25 @@ func NewSyncToolServer(config *Config) (*SyncToolServer, error) {
 	if db != nil {
 		userRepo = repository.NewUserRepository(db)
 
-		// Get JWT secret from environment
-		jwtSecret := os.Getenv("JWT_SECRET")
+		// Get JWT secret from config (auth.jwt_secret or JWT_SECRET)
+		jwtSecret := config.Auth.JWTSecret
 		if jwtSecret == "" {
 			jwtSecret = "dev-secret-change-in-production-min-32-chars"
-			log.Println("⚠️  Using default JWT secret! Set JWT_SECRET environment variable for production")
+			log.Println("⚠️  Using default JWT secret! Set auth.jwt_secret or JWT_SECRET for production")
 		}
 
-		// Token duration: 24 hours
-		tokenDuration := 24 * time.Hour
+		authService = auth.NewAuthService(userRepo, jwtSecret, config.Auth.TokenLifetime)
 
-		authService = auth.NewAuthService(userRepo, jwtSecret, tokenDuration)
+		if !config.AgentAuth.Required {
+			log.Println("⚠️  agent_auth.required is off: agents without a credential can connect with just their agent ID. Enroll them and set agent_auth.required: true")
+		}
 
 		log.Println("✅ User management initialized")
 	}
 
+	// Create event store (memory ring buffer or durable Postgres store)
+	eventStore := newEventStore(config.EventStore, db)
+
 	s := &SyncToolServer{
 		config:         config,
 		hub:            NewHub(),
@@ -158,6 +165,7 @@ func NewSyncToolServer(config *Config) (*SyncToolServer, error) {
 		db:             db,
 		folderStats:    make(map[string]map[string]interface{}),
 		activeSyncJobs: make(map[string]bool),
+		webhookWake:    make(chan struct{}, 1),
 		userRepo:       userRepo,
 		authService:    authService,
 	}
@@ -166,9 +174,11 @@ func NewSyncToolServer(config *Config) (*SyncToolServer, error) {
 	s.hub.eventProcessor = s.eventProcessor
 
 	// Initialize scheduler if database is available
-	if db != nil {
+	if db != nil && config.Scheduler.Enabled {
 		s.scheduler = NewJobScheduler(s)
 		log.Println("✅ Job scheduler initialized")
+	} else if !config.Scheduler.Enabled {
+		log.Println("⚠️ Job scheduler disabled by configuration")
 	} else {
 		log.Println("⚠️ Job scheduler disabled - no database connection")
 	}
@@ -179,6 +189,18 @@ func NewSyncToolServer(config *Config) (*SyncToolServer, error) {
 	// Start database sync if database is available
 	if s.db != nil {
 		go s.startDatabaseSync()
+		go s.runWebhookDispatcher()
+		if config.Alerts.Enabled {
+			go s.runAlertEvaluator()
+		}
+		if config.HealthHistory.Enabled && config.HealthHistory.Retention > 0 {
+			go s.runHealthHistoryCleanup()
+		}
+		s.failInterruptedVerifications()
+		if config.Verification.Retention > 0 {
+			go s.runVerificationCleanup()
+		}
+		go s.runMirrorMonitor()
 	}
 
 	return s, nil
@@ -191,14 +213,20 @@ func NewSyncToolServer(config *Config) (*SyncToolServer, error) {
 // corsMiddleware handles CORS headers for all requests
 func (s *SyncToolServer) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get allowed origin from environment variable, default to allow all in development
-		allowedOrigin := os.Getenv("CORS_ALLOWED_ORIGIN")
-		if allowedOrigin == "" {
+		// Resolve allowed origin from config (cors.allowed_origins or CORS_ALLOWED_ORIGIN)
+		allowedOrigin := ""
+		origin := r.Header.Get("Origin")
+		if len(s.config.CORS.AllowedOrigins) == 1 && s.config.CORS.AllowedOrigins[0] == "*" {
 			allowedOrigin = "*" // Allow all origins in development
+		} else if origin != "" && s.config.CORS.IsOriginAllowed(origin) {
+			allowedOrigin = origin
+			w.Header().Add("Vary", "Origin")
 		}
 
 		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+		if allowedOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -329,6 +357,13 @@ func (s *SyncToolServer) getUserClaims(r *http.Request) (*models.JWTClaims, bool
 	return claims, ok
 }
 
+// callerIsAdmin reports whether the caller may use admin only settings such as hooks. Requests
+// without user claims only reach the handlers when authentication is off.
+func (s *SyncToolServer) callerIsAdmin(r *http.Request) bool {
+	claims, ok := s.getUserClaims(r)
+	return !ok || claims.Role == models.RoleAdmin
+}
+
 // writeJSONError helper to write JSON error response
 func (s *SyncToolServer) writeJSONError(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -357,11 +392,17 @@ func (s *SyncToolServer) Start() error {
 	// PUBLIC ROUTES (No authentication)
 	// ========================================
 	mux.HandleFunc("/health", s.handleHealth)
+	if s.config.Metrics.Enabled {
+		mux.HandleFunc("/metrics", s.handleMetrics) // Prometheus scrape endpoint (optional bearer token)
+	}
 
 	// WebSocket endpoints (agents use their own auth)
 	mux.HandleFunc("/ws/agent", s.handleAgentWebSocket)
 	mux.HandleFunc("/ws/cli", s.handleCLIWebSocket)
 
+	// Agent CA certificate and revocation list (public)
+	mux.HandleFunc("/api/v1/agent-ca/", s.handleAgentCA)
+
 	// Authentication endpoints (public)
 	if s.authService != nil {
 		mux.HandleFunc("/api/v1/auth/login", s.handleUserLogin)
@@ -402,6 +443,16 @@ func (s *SyncToolServer) Start() error {
 		mux.HandleFunc("/api/folder-stats", s.withAuth(s.handleFolderStats)) // Get folder statistics from agent
 		mux.HandleFunc("/api/v1/folder-stats/stats", s.withAuth(s.handleFolderStatsOverall)) // Dashboard statistics
 		mux.HandleFunc("/api/v1/scheduler/status", s.withAuth(s.handleSchedulerStatus)) // Scheduler status
+		mux.HandleFunc("/api/v1/maintenance-windows", s.withAuth(s.withAdminRoleForMutations(s.handleMaintenanceWindows)))        // Maintenance windows
+		mux.HandleFunc("/api/v1/maintenance-windows/", s.withAuth(s.withAdminRoleForMutations(s.handleMaintenanceWindowActions))) // Maintenance window actions
+		mux.HandleFunc("/api/v1/enrollment-tokens", s.withAuth(s.withAdminRoleForMutations(s.handleEnrollmentTokens)))        // Agent enrollment tokens
+		mux.HandleFunc("/api/v1/enrollment-tokens/", s.withAuth(s.withAdminRoleForMutations(s.handleEnrollmentTokenActions))) // Enrollment token actions
+		mux.HandleFunc("/api/v1/webhooks", s.withAuth(s.withAdminRole(s.handleWebhooks)))                                      // Webhooks
+		mux.HandleFunc("/api/v1/webhooks/", s.withAuth(s.withAdminRole(s.handleWebhookActions)))                               // Webhook actions, test and delivery log
+		mux.HandleFunc("/api/v1/alert-rules", s.withAuth(s.withAdminRoleForMutations(s.handleAlertRules)))                     // Email alert rules
+		mux.HandleFunc("/api/v1/alert-rules/", s.withAuth(s.withAdminRoleForMutations(s.handleAlertRuleActions)))              // Alert rule actions, states and test email
+		mux.HandleFunc("/api/v1/job-templates", s.withAuth(s.withAdminRoleForMutations(s.handleJobTemplates)))                 // Sync job templates
+		mux.HandleFunc("/api/v1/job-templates/", s.withAuth(s.handleJobTemplateActions))                                       // Template actions, jobs created from a template and propagation
 		mux.HandleFunc("/api/v1/licenses", s.withAuth(s.handleLicenses))               // License CRUD
 		mux.HandleFunc("/api/v1/licenses/", s.withAuth(s.handleLicenseActions))        // License actions
 		mux.HandleFunc("/api/v1/agent-licenses", s.withAuth(s.handleAgentLicenses))    // Agent-license mapping
@@ -443,6 +494,16 @@ func (s *SyncToolServer) Start() error {
 		mux.HandleFunc("/api/folder-stats", s.handleFolderStats)
 		mux.HandleFunc("/api/v1/folder-stats/stats", s.handleFolderStatsOverall)
 		mux.HandleFunc("/api/v1/scheduler/status", s.handleSchedulerStatus)
+		mux.HandleFunc("/api/v1/maintenance-windows", s.handleMaintenanceWindows)
+		mux.HandleFunc("/api/v1/maintenance-windows/", s.handleMaintenanceWindowActions)
+		mux.HandleFunc("/api/v1/enrollment-tokens", s.handleEnrollmentTokens)
+		mux.HandleFunc("/api/v1/enrollment-tokens/", s.handleEnrollmentTokenActions)
+		mux.HandleFunc("/api/v1/webhooks", s.handleWebhooks)
+		mux.HandleFunc("/api/v1/webhooks/", s.handleWebhookActions)
+		mux.HandleFunc("/api/v1/alert-rules", s.handleAlertRules)
+		mux.HandleFunc("/api/v1/alert-rules/", s.handleAlertRuleActions)
+		mux.HandleFunc("/api/v1/job-templates", s.handleJobTemplates)
+		mux.HandleFunc("/api/v1/job-templates/", s.handleJobTemplateActions)
 		mux.HandleFunc("/api/v1/licenses", s.handleLicenses)
 		mux.HandleFunc("/api/v1/licenses/", s.handleLicenseActions)
 		mux.HandleFunc("/api/v1/agent-licenses", s.handleAgentLicenses)
@@ -511,9 +572,29 @@ func (s *SyncToolServer) handleAgentWebSocket(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	issuedCredential, certVerified, err := s.authenticateAgent(r, agentID)
+	if err != nil {
+		if _, rejected := err.(*certificateRejectedError); rejected {
+			w.Header().Set(agentCertificateHeader, "rejected")
+		}
+		log.Printf("🔒 Rejected agent %s from %s: %v", agentID, r.RemoteAddr, err)
+		http.Error(w, fmt.Sprintf("unauthorized: %v", err), http.StatusUnauthorized)
+		return
+	}
+
+	var responseHeader http.Header
+	if issuedCredential != "" {
+		responseHeader = http.Header{}
+		responseHeader.Set(agentCredentialHeader, issuedCredential)
+	}
+
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade failed: %v", err)
+		if issuedCredential != "" {
+			// The agent never received the credential, let it enroll again
+			s.revokeAgentCredential(agentID, "handshake failed")
+		}
 		return
 	}
 
@@ -569,6 +650,11 @@ func (s *SyncToolServer) handleAgentWebSocket(w http.ResponseWriter, r *http.Req
 		architecture: architecture,
 		deviceID:     deviceID,
 		dataDir:      dataDir,
+		// With tls.mtls.required an agent without a client certificate may only obtain one
+		certificateOnly: s.agentCA != nil && s.config.TLS.MTLS.Required && !certVerified,
+	}
+	if client.certificateOnly {
+		log.Printf("🔐 Agent %s connected without client certificate, limited to certificate issuance", agentID)
 	}
 
 	s.hub.Register(client)
@@ -602,16 +688,6 @@ func (s *SyncToolServer) handleCLIWebSocket(w http.ResponseWriter, r *http.Reque
 }
 
 func (s *SyncToolServer) handleStatus(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	status := map[string]interface{}{
 		"server": "running",
 		"agents": s.hub.GetConnectedAgents(),
@@ -623,16 +699,6 @@ func (s *SyncToolServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *SyncToolServer) handleAgents(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	// Get user claims from context for role-based filtering
 	var userRole string
 	var assignedAgents []string
@@ -681,16 +747,6 @@ func (s *SyncToolServer) handleAgents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *SyncToolServer) handleIntegratedAgents(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, "Database not available", http.StatusServiceUnavailable)
 		return
@@ -937,16 +993,6 @@ func (s *SyncToolServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *SyncToolServer) handleEvents(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	agentID := r.URL.Query().Get("agent_id")
 	sinceStr := r.URL.Query().Get("since")
 	limitStr := r.URL.Query().Get("limit")
@@ -972,16 +1018,6 @@ func (s *SyncToolServer) handleEvents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *SyncToolServer) handleEventStats(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	agentID := r.URL.Query().Get("agent_id")
 	
 	stats, err := s.eventProcessor.GetEventStats(agentID)
@@ -1004,6 +1040,7 @@ type Hub struct {
 	startTime      time.Time
 	eventProcessor *EventProcessor
 	browseRequests map[string]chan map[string]interface{} // Track pending browse requests
+	agentRequests  map[string]chan map[string]interface{} // Track pending agent requests by request_id
 	server         *SyncToolServer // Reference to main server for database access
 }
 
@@ -1016,6 +1053,7 @@ func NewHub() *Hub {
 		broadcast:      make(chan Message),
 		startTime:      time.Now(),
 		browseRequests: make(map[string]chan map[string]interface{}),
+		agentRequests:  make(map[string]chan map[string]interface{}),
 	}
 }
 
@@ -1088,6 +1126,14 @@ func (h *Hub) Run() {
 					}
 					
 					log.Printf("📊 Agent disconnected (marked offline): %s (Total agents: %d)", client.ID, len(h.agents))
+
+					if h.server != nil {
+						go h.server.emitWebhookEvent(WebhookEventAgentOffline, map[string]interface{}{
+							"agent_id":  client.ID,
+							"hostname":  existingAgent.hostname,
+							"last_seen": existingAgent.lastSeen,
+						})
+					}
 				}
 			} else {
 				// CLI clients can be removed since they're temporary
@@ -1119,7 +1165,10 @@ func (h *Hub) handleMessage(msg Message) {
 	case "command":
 		if targetAgent, ok := h.agents[msg.To]; ok {
 			// Check if agent is online and channel is not closed
-			if targetAgent.isOnline && targetAgent.send != nil {
+			if targetAgent.certificateOnly {
+				log.Printf("⚠️  Agent %s has no client certificate yet", msg.To)
+				h.sendErrorToCLI(msg.From, fmt.Sprintf("Agent %s has no client certificate yet", msg.To))
+			} else if targetAgent.isOnline && targetAgent.send != nil {
 				select {
 				case targetAgent.send <- msg.Data:
 					log.Printf("✅ Command forwarded to agent: %s", msg.To)
@@ -1409,6 +1458,8 @@ type AgentClient struct {
 	isOnline     bool  // Track online/offline status
 	deviceID     string
 	dataDir      string  // Agent data directory
+	protocolVersion int // Negotiated protocol version
+	certificateOnly bool // Connected without client certificate while mTLS is required (see certificateSessionMessage)
 }
 
 func (c *AgentClient) ReadPump() {
@@ -1453,6 +1504,11 @@ func (c *AgentClient) ReadPump() {
 func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMessage []byte) {
 	msgType, _ := msgData["type"].(string)
 	
+	if c.certificateOnly && !certificateSessionMessage(msgType) {
+		log.Printf("🔐 Ignored %s from agent %s: no client certificate yet", msgType, c.ID)
+		return
+	}
+
 	switch msgType {
 	case "event":
 		// Store event if event processor is available
@@ -1460,6 +1516,19 @@ func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMess
 			c.hub.eventProcessor.ProcessEvent(c.ID, rawMessage)
 		}
 		
+		// Conflict copies found in job folders
+		if eventData, ok := msgData["event"].(map[string]interface{}); ok && c.hub.server != nil {
+			if eventType, _ := eventData["type"].(string); eventType == protocol.EventConflictDetected || eventType == protocol.EventConflictRemoved {
+				var conflict protocol.ConflictEvent
+				data, _ := json.Marshal(eventData["data"])
+				if err := json.Unmarshal(data, &conflict); err != nil {
+					log.Printf("❌ Rejected %s event from agent %s: %v", eventType, c.ID, err)
+				} else {
+					c.hub.server.handleConflictEvent(c.ID, eventType, &conflict)
+				}
+			}
+		}
+		
 		// Check for state changes to track sync status
 		if eventData, ok := msgData["event"].(map[string]interface{}); ok {
 			if eventType, ok := eventData["type"].(string); ok && eventType == "state_changed" {
@@ -1502,7 +1571,16 @@ func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMess
 		if c.hub.server != nil {
 			c.hub.server.storeFolderStatsResponse(c.ID, msgData)
 		}
+		// Stats requested with requestFromAgent (mirror jobs)
+		if requestID, _ := msgData["request_id"].(string); requestID != "" {
+			c.hub.handleAgentResponse(c.ID, msgData)
+		}
 	case "folder_stats_periodic":
+		var stats protocol.FolderStatsPeriodic
+		if !c.decodeAgentMessage(rawMessage, &stats) {
+			return
+		}
+
 		// Handle periodic folder stats from agent during syncing
 		log.Printf("📊 Received periodic folder stats from agent %s: %s", c.ID, string(rawMessage))
 		
@@ -1514,15 +1592,31 @@ func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMess
 	case "folder_stats_error":
 		// Handle folder stats error response from agent
 		log.Printf("❌ Received folder stats error from agent %s: %s", c.ID, string(rawMessage))
+		if requestID, _ := msgData["request_id"].(string); requestID != "" {
+			msgData["error"] = msgData["message"]
+			c.hub.handleAgentResponse(c.ID, msgData)
+		}
 	case "session_event":
+		var event protocol.SessionEvent
+		if !c.decodeAgentMessage(rawMessage, &event) {
+			return
+		}
+
 		// Handle session tracking events from agent
 		if c.hub.server != nil {
 			c.hub.server.handleSessionEvent(c.ID, msgData)
 		}
 	case "register":
+		reg, err := c.negotiateProtocol(rawMessage)
+		if err != nil {
+			log.Printf("❌ Rejected registration of agent %s: %v", c.ID, err)
+			c.closeAfterFlush()
+			return
+		}
+
 		// Handle agent registration with device ID validation
-		newDeviceID, _ := msgData["device_id"].(string)
-		newDataDir, _ := msgData["data_dir"].(string)
+		newDeviceID := reg.DeviceID
+		newDataDir := reg.DataDir
 		
 		// Check if Device ID has changed (indicating a potentially different agent)
 		needsApproval := false
@@ -1616,21 +1710,45 @@ func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMess
 			} else {
 				log.Printf("✅ Agent %s persisted to database", c.ID)
 			}
+
+			// Agent-wide bandwidth limit is only kept in the database; push it on every connect
+			if !c.certificateOnly {
+				go c.hub.server.pushAgentBandwidthLimit(c.ID)
+			}
+
+			// Issue or rotate the client certificate of approved agents
+			go c.hub.server.ensureAgentCertificate(c.ID)
+
+			agentEvent := map[string]interface{}{
+				"agent_id":   c.ID,
+				"hostname":   hostname,
+				"device_id":  c.deviceID,
+				"ip_address": c.remoteAddr,
+			}
+			go c.hub.server.emitWebhookEvent(WebhookEventAgentOnline, agentEvent)
+			if approvalStatus == "pending" {
+				go c.hub.server.emitWebhookEvent(WebhookEventAgentPendingApproval, agentEvent)
+			}
 		}
 		
 		log.Printf("📋 Agent %s registered with device ID: %s, data dir: %s", c.ID, c.deviceID, c.dataDir)
 		log.Printf("📨 Agent message: %s", string(rawMessage))
 	case "health":
+		var health protocol.Health
+		if !c.decodeAgentMessage(rawMessage, &health) {
+			return
+		}
+
 		// Update last seen time for heartbeat
 		c.lastSeen = time.Now()
 		
 		// Check if data_dir is included in health message
-		if dataDir, ok := msgData["data_dir"].(string); ok && dataDir != "" {
-			c.dataDir = dataDir
+		if health.DataDir != "" {
+			c.dataDir = health.DataDir
 		}
 		
 		// Handle health message with system info
-		if systemInfo, ok := msgData["system_info"].(map[string]interface{}); ok {
+		if systemInfo := health.SystemInfo; systemInfo != nil {
 			if hostname, ok := systemInfo["hostname"].(string); ok {
 				c.hostname = hostname
 			}
@@ -1673,6 +1791,9 @@ func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMess
 				}
 			}
 			
+			// Keep the host metrics as a time series
+			c.hub.server.recordAgentHealthSample(c.ID, systemInfo)
+			
 			log.Printf("🏥 Agent %s health update: hostname=%s, os=%s, arch=%s, data_dir=%s", 
 				c.ID, c.hostname, c.os, c.architecture, c.dataDir)
 		}
@@ -1683,6 +1804,77 @@ func (c *AgentClient) handleAgentMessage(msgData map[string]interface{}, rawMess
 	case "browse_error":
 		// Handle browse folders error from agent
 		c.hub.handleBrowseError(c.ID, msgData)
+	case "file_versions_response", "file_versions_restore_response", "certificate_request_response", "disk_space_response", "manifest_response", "conflict_resolve_response":
+		// Responses to requests made with requestFromAgent
+		c.hub.handleAgentResponse(c.ID, msgData)
+	case "disk_space_low", "disk_space_recovered":
+		report := protocol.DiskSpaceReport{Type: msgType}
+		if !c.decodeAgentMessage(rawMessage, &report) {
+			return
+		}
+
+		// Disk guard of a destination folder paused or resumed it
+		if c.hub.server != nil {
+			c.hub.server.handleDiskSpaceReport(c.ID, &report)
+		}
+	case "deletion_guard_triggered":
+		var trigger protocol.DeletionGuardTriggered
+		if !c.decodeAgentMessage(rawMessage, &trigger) {
+			return
+		}
+
+		// The source agent paused a job after a large deletion
+		if c.hub.server != nil {
+			c.hub.server.handleDeletionGuardTriggered(c.ID, &trigger)
+		}
+	case "conflict_auto_resolved":
+		var decision protocol.ConflictAutoResolved
+		if !c.decodeAgentMessage(rawMessage, &decision) {
+			return
+		}
+
+		// An agent resolved a conflict copy following the conflict policy of the job
+		if c.hub.server != nil {
+			c.hub.server.handleConflictAutoResolved(c.ID, &decision)
+		}
+	case "hook_result":
+		var result protocol.HookResult
+		if !c.decodeAgentMessage(rawMessage, &result) {
+			return
+		}
+
+		// A pre-scan or post-session hook of a job finished on the agent
+		if c.hub.server != nil {
+			c.hub.server.handleHookResult(c.ID, &result)
+		}
+	case "job_deployed":
+		// Deployment succeeded, reset the consecutive error count of the destination
+		jobID, _ := msgData["job_id"].(string)
+		warning, _ := msgData["warning"].(string)
+		if c.hub.server != nil {
+			if warning != "" {
+				log.Printf("⚠️ Agent %s deployed job %s with a warning: %s", c.ID, jobID, warning)
+				c.hub.server.recordDeployWarning(jobID, c.ID, warning)
+			} else {
+				c.hub.server.clearDestinationErrors(jobID, c.ID)
+			}
+		}
+		log.Printf("📨 Agent message: %s", string(rawMessage))
+	case "job_deploy_error", "job_pause_error", "job_resume_error", "job_delete_error":
+		// Agent could not apply a job operation
+		jobID, _ := msgData["job_id"].(string)
+		message, _ := msgData["message"].(string)
+		log.Printf("❌ Agent %s failed %s for job %s: %s", c.ID, msgType, jobID, message)
+
+		if c.hub.server != nil {
+			c.hub.server.recordDestinationError(jobID, c.ID, message)
+			go c.hub.server.emitWebhookEvent(WebhookEventJobFailed, map[string]interface{}{
+				"job_id":    jobID,
+				"agent_id":  c.ID,
+				"operation": strings.TrimSuffix(strings.TrimPrefix(msgType, "job_"), "_error"),
+				"message":   message,
+			})
+		}
 	default:
 		log.Printf("📨 Agent message: %s", string(rawMessage))
 	}
@@ -1743,16 +1935,8 @@ func (c *AgentClient) WritePump() {
 
 // Handle agent actions like approve/reject
 func (s *SyncToolServer) handleAgentActions(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not connected"}`, http.StatusServiceUnavailable)
 		return
@@ -1768,6 +1952,42 @@ func (s *SyncToolServer) handleAgentActions(w http.ResponseWriter, r *http.Reque
 	agentID := pathParts[0]
 	action := pathParts[1]
 
+	// Health history: GET /api/agents/{agentId}/health
+	if action == "health" {
+		s.handleAgentHealthHistory(w, r, agentID)
+		return
+	}
+
+	// Bandwidth limits support GET/PUT/DELETE
+	if action == "bandwidth" {
+		s.handleAgentBandwidth(w, r, agentID)
+		return
+	}
+
+	// Credential status (GET) and revocation (DELETE, admin only)
+	if action == "credential" {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			s.handleAgentCredential(w, r, agentID)
+		}
+		if s.authService != nil {
+			handler = s.withAdminRoleForMutations(handler)
+		}
+		handler(w, r)
+		return
+	}
+
+	// Client certificates: list (GET), issue (POST), revoke (DELETE), mutations admin only
+	if action == "certificates" {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			s.handleAgentCertificates(w, r, agentID)
+		}
+		if s.authService != nil {
+			handler = s.withAdminRoleForMutations(handler)
+		}
+		handler(w, r)
+		return
+	}
+
 	// Allow GET method for browse action
 	if r.Method != "POST" && r.Method != "GET" {
 		http.Error(w, `{"error": "Only POST and GET methods allowed"}`, http.StatusMethodNotAllowed)
@@ -1783,6 +2003,7 @@ func (s *SyncToolServer) handleAgentActions(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		log.Printf("✅ Agent %s approved successfully", agentID)
+		go s.ensureAgentCertificate(agentID)
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -1797,6 +2018,11 @@ func (s *SyncToolServer) handleAgentActions(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		log.Printf("✅ Agent %s rejected successfully", agentID)
+		if s.agentCA != nil {
+			if _, err := s.revokeAgentCertificates(agentID, "", "agent rejected"); err != nil {
+				log.Printf("⚠️ Failed to revoke certificates of rejected agent %s: %v", agentID, err)
+			}
+		}
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -1894,17 +2120,8 @@ func (s *SyncToolServer) deleteAgent(agentID string) error {
 
 // Handle sync jobs API
 func (s *SyncToolServer) handleSyncJobs(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	w.Header().Set("Content-Type", "application/json")
 	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
 		return
@@ -1922,17 +2139,8 @@ func (s *SyncToolServer) handleSyncJobs(w http.ResponseWriter, r *http.Request)
 
 // Handle sync job actions (update, delete, pause, resume)
 func (s *SyncToolServer) handleSyncJobActions(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	w.Header().Set("Content-Type", "application/json")
 	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if s.db == nil {
 		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
 		return
@@ -1946,6 +2154,74 @@ func (s *SyncToolServer) handleSyncJobActions(w http.ResponseWriter, r *http.Req
 	}
 
 	jobID := pathParts[0]
+
+	// Schedule preview: POST /api/v1/sync-jobs/validate-schedule
+	if jobID == "validate-schedule" && len(pathParts) == 1 {
+		if r.Method != "POST" {
+			http.Error(w, `{"error": "Only POST method allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleValidateSchedule(w, r)
+		return
+	}
+
+	// Job documents: GET /api/v1/sync-jobs/export, POST /api/v1/sync-jobs/import
+	if (jobID == "export" || jobID == "import") && len(pathParts) == 1 {
+		s.handleJobDocument(w, r, jobID)
+		return
+	}
+	
+	// Archived file versions on a destination: GET /{id}/versions, POST /{id}/versions/restore
+	if len(pathParts) >= 2 && pathParts[1] == "versions" {
+		s.handleJobFileVersions(w, r, jobID, pathParts[2:])
+		return
+	}
+	
+	// Large deletion guard: GET /{id}/deletion-guard, POST /{id}/deletion-guard/confirm
+	if len(pathParts) >= 2 && pathParts[1] == "deletion-guard" {
+		s.handleJobDeletionGuard(w, r, jobID, pathParts[2:])
+		return
+	}
+	
+	// Content verification reports: GET /{id}/verifications, GET /{id}/verifications/{verification_id}
+	if len(pathParts) >= 2 && pathParts[1] == "verifications" {
+		s.handleJobVerifications(w, r, jobID, pathParts[2:])
+		return
+	}
+	
+	// Conflict copies: GET /{id}/conflicts, GET /{id}/conflicts/{conflict_id}, POST /{id}/conflicts/{conflict_id}/resolve
+	if len(pathParts) >= 2 && pathParts[1] == "conflicts" {
+		s.handleJobConflicts(w, r, jobID, pathParts[2:])
+		return
+	}
+	
+	// One-shot mirror progress: GET /{id}/mirror
+	if len(pathParts) == 2 && pathParts[1] == "mirror" {
+		s.handleJobMirror(w, r, jobID)
+		return
+	}
+	
+	// Mirrors run with the settings they were created with; once finished their folders are gone
+	// from the agents and only GET and DELETE remain
+	if r.Method == "PUT" || r.Method == "POST" {
+		if mirrorStatus := s.jobMirrorStatus(jobID); isFinishedMirror(mirrorStatus) {
+			http.Error(w, `{"error": "Mirror job already finished, its folders were removed from the agents"}`, http.StatusConflict)
+			return
+		} else if mirrorStatus != "" && r.Method == "PUT" {
+			http.Error(w, `{"error": "Mirror jobs cannot be changed, delete and create it again"}`, http.StatusConflict)
+			return
+		}
+	}
+	
+	// Job dependencies: PUT /{id}/dependencies, GET /{id}/chain
+	if len(pathParts) == 2 && pathParts[1] == "dependencies" {
+		s.handleJobDependencies(w, r, jobID)
+		return
+	}
+	if len(pathParts) == 2 && pathParts[1] == "chain" {
+		s.handleJobChain(w, r, jobID)
+		return
+	}
 	
 	if len(pathParts) == 1 {
 		// Single job operations: GET, PUT, DELETE
@@ -1960,7 +2236,7 @@ func (s *SyncToolServer) handleSyncJobActions(w http.ResponseWriter, r *http.Req
 			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
 		}
 	} else if len(pathParts) == 2 {
-		// Job actions: pause, resume
+		// Job actions: pause, resume, verify
 		action := pathParts[1]
 		if r.Method != "POST" {
 			http.Error(w, `{"error": "Only POST method allowed for actions"}`, http.StatusMethodNotAllowed)
@@ -1972,6 +2248,8 @@ func (s *SyncToolServer) handleSyncJobActions(w http.ResponseWriter, r *http.Req
 			s.handlePauseSyncJob(w, r, jobID)
 		case "resume":
 			s.handleResumeSyncJob(w, r, jobID)
+		case "verify":
+			s.handleVerifySyncJob(w, r, jobID)
 		default:
 			http.Error(w, fmt.Sprintf(`{"error": "Unknown action: %s"}`, action), http.StatusBadRequest)
 		}
@@ -2163,6 +2441,8 @@ func (s *SyncToolServer) handleGetSyncJobs(w http.ResponseWriter, r *http.Reques
 			sj.rescan_interval, sj.ignore_patterns, sj.schedule_type,
 			sj.status, sj.created_at, sj.updated_at,
 			COALESCE(sj.is_multi_destination, false) as is_multi_destination,
+			COALESCE(sj.cron_expression, '') as cron_expression, COALESCE(sj.timezone, 'UTC') as timezone,
+			sj.next_scheduled_run,
 			sa.hostname as source_agent_name, da.hostname as destination_agent_name
 		FROM sync_jobs sj
 		LEFT JOIN integrated_agents sa ON sj.source_agent_id = sa.agent_id
@@ -2234,8 +2514,10 @@ func (s *SyncToolServer) handleGetSyncJobs(w http.ResponseWriter, r *http.Reques
 		var rescanInterval int
 		var ignorePatterns pq.StringArray
 		var isMultiDest bool
+		var cronExpression, timezone string
+		var nextScheduledRun *time.Time
 
-		if err := rows.Scan(&id, &name, &sourceAgentID, &targetAgentID, &sourcePath, &targetPath, &syncType, &rescanInterval, &ignorePatterns, &scheduleType, &status, &createdAt, &updatedAt, &isMultiDest, &sourceAgentName, &destinationAgentName); err != nil {
+		if err := rows.Scan(&id, &name, &sourceAgentID, &targetAgentID, &sourcePath, &targetPath, &syncType, &rescanInterval, &ignorePatterns, &scheduleType, &status, &createdAt, &updatedAt, &isMultiDest, &cronExpression, &timezone, &nextScheduledRun, &sourceAgentName, &destinationAgentName); err != nil {
 			log.Printf("❌ Failed to scan sync job row: %v", err)
 			continue
 		}
@@ -2258,6 +2540,9 @@ func (s *SyncToolServer) handleGetSyncJobs(w http.ResponseWriter, r *http.Reques
 			"sync_mode":            syncMode,
 			"sync_type":            syncType,
 			"schedule":             scheduleType,
+			"cron_expression":      cronExpression,
+			"timezone":             timezone,
+			"next_scheduled_run":   nextScheduledRun,
 			"rescan_interval":      rescanInterval,
 			"max_file_size":        104857600, // Default 100MB
 			"ignore_patterns":      []string(ignorePatterns),
@@ -2279,7 +2564,7 @@ func (s *SyncToolServer) handleGetSyncJobs(w http.ResponseWriter, r *http.Reques
 					sjd.destination_agent_id, sjd.destination_path,
 					sjd.status, sjd.last_sync_status,
 					sjd.files_synced, sjd.bytes_synced, sjd.last_sync_time,
-					ia.hostname as agent_name
+					ia.hostname as agent_name, COALESCE(sjd.last_error, '')
 				FROM sync_job_destinations sjd
 				LEFT JOIN integrated_agents ia ON sjd.destination_agent_id = ia.agent_id
 				WHERE sjd.job_id = $1
@@ -2297,8 +2582,9 @@ func (s *SyncToolServer) handleGetSyncJobs(w http.ResponseWriter, r *http.Reques
 					var destFilesSynced, destBytesSynced int64
 					var destLastSyncTime *time.Time
 					var destAgentName *string
+					var destLastError string
 
-					if err := destRows.Scan(&destAgentID, &destPath, &destStatus, &destLastSyncStatus, &destFilesSynced, &destBytesSynced, &destLastSyncTime, &destAgentName); err != nil {
+					if err := destRows.Scan(&destAgentID, &destPath, &destStatus, &destLastSyncStatus, &destFilesSynced, &destBytesSynced, &destLastSyncTime, &destAgentName, &destLastError); err != nil {
 						log.Printf("❌ Failed to scan destination: %v", err)
 						continue
 					}
@@ -2316,6 +2602,10 @@ func (s *SyncToolServer) handleGetSyncJobs(w http.ResponseWriter, r *http.Reques
 					if destLastSyncTime != nil {
 						dest["last_sync_time"] = destLastSyncTime.Format(time.RFC3339)
 					}
+					if destLastError != "" {
+						// Last deploy error or warning of the destination, e.g. a versioning policy it refused
+						dest["last_error"] = destLastError
+					}
 
 					destinations = append(destinations, dest)
 				}
@@ -2380,6 +2670,11 @@ func (s *SyncToolServer) handleCreateSyncJob(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	s.createSyncJob(w, r, jobData, nil)
+}
+
+// createSyncJob validates, saves and deploys a new job; templateID links jobs created from a template
+func (s *SyncToolServer) createSyncJob(w http.ResponseWriter, r *http.Request, jobData map[string]interface{}, templateID *int) {
 	// Extract and validate required fields
 	name, ok := jobData["name"].(string)
 	if !ok || name == "" {
@@ -2466,6 +2761,13 @@ func (s *SyncToolServer) handleCreateSyncJob(w http.ResponseWriter, r *http.Requ
 		log.Printf("✅ Operator %s validated: has access to source and all %d destination(s)", claims.Username, len(destinations))
 	}
 
+	// Job type: long-lived sync job (default) or one-shot mirror
+	jobType, err := jobTypeFromJobData(jobData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
 	// Get sync_type - support both sync_type (direct) and sync_mode (legacy)
 	syncType := "sendreceive" // Default two-way
 
@@ -2497,12 +2799,109 @@ func (s *SyncToolServer) handleCreateSyncJob(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	// Extract schedule - support both "schedule" and "schedule_type"
-	schedule := "continuous" // default
-	if sched, ok := jobData["schedule_type"].(string); ok && sched != "" {
-		schedule = sched
-	} else if sched, ok := jobData["schedule"].(string); ok && sched != "" {
-		schedule = sched
+	// Extract and validate schedule (type, cron expression, time zone)
+	jobSchedule, err := parseScheduleFromJobData(jobData)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	var nextScheduledRun *time.Time
+	if jobSchedule.IsScheduled() {
+		next := jobSchedule.Next(nil)
+		nextScheduledRun = &next
+	}
+
+	// A mirror copies the source once to the destinations, then it is removed from the agents
+	var mirrorStatus interface{}
+	var mirrorStartedAt *time.Time
+	if jobType == JobTypeMirror {
+		if err := validateMirrorJob(jobData, jobSchedule); err != nil {
+			writeMirrorJobError(w, err)
+			return
+		}
+		syncType = "sendonly"
+		now := time.Now()
+		mirrorStatus, mirrorStartedAt = mirrorPending, &now
+	}
+
+	// Extract and validate bandwidth limit (optional, null = unlimited)
+	bandwidthLimit, _, err := bandwidthFromJobData(jobData)
+	if err != nil {
+		writeBandwidthLimitError(w, err)
+		return
+	}
+
+	// Extract and validate versioning policy for the destinations (optional, null = off)
+	versioning, _, err := versioningFromJobData(jobData)
+	if err != nil {
+		writeVersioningError(w, err)
+		return
+	}
+	if versioning.runsCommand() && templateID == nil && !s.callerIsAdmin(r) {
+		s.writeJSONError(w, http.StatusForbidden, "Access denied: only admins can set external versioning")
+		return
+	}
+
+	// Extract and validate minimum free space on the destinations (optional, null = server defaults)
+	diskGuard, _, err := diskGuardFromJobData(jobData)
+	if err != nil {
+		writeDiskGuardError(w, err)
+		return
+	}
+
+	// Extract and validate the large deletion guard (optional, null = off)
+	deletionGuard, _, err := deletionGuardFromJobData(jobData)
+	if err != nil {
+		writeDeletionGuardError(w, err)
+		return
+	}
+
+	// Extract and validate the content verification schedule (optional, null = on demand only)
+	verifySchedule, _, err := verifyScheduleFromJobData(jobData, jobSchedule.Timezone)
+	if err != nil {
+		writeVerifyScheduleError(w, err)
+		return
+	}
+	verifyExpression, nextVerification := verifyScheduleColumns(verifySchedule)
+
+	// Extract and validate the commands the agents run around the syncs (optional)
+	hooks, _, err := hooksFromJobData(jobData)
+	if err != nil {
+		writeHooksError(w, err)
+		return
+	}
+	// Hooks run commands on the agents: only admins set them, or admins through a template
+	if hooks != nil && templateID == nil && !s.callerIsAdmin(r) {
+		s.writeJSONError(w, http.StatusForbidden, "Access denied: only admins can set hooks")
+		return
+	}
+
+	// Extract and validate how the agents resolve conflict copies (optional, null = manually)
+	conflictPolicy, _, err := conflictPolicyFromJobData(jobData)
+	if err != nil {
+		writeConflictPolicyError(w, err)
+		return
+	}
+
+	// Extract and validate the jobs this one is triggered by (optional)
+	dependsOn, _, err := dependsOnFromJobData(jobData)
+	if err == nil {
+		err = validateJobDependencies(s.db, 0, dependsOn)
+	}
+	if err != nil {
+		writeJobDependencyError(w, err)
+		return
+	}
+
+	// Make sure the source data fits every destination before creating the job
+	var capacityCheck *CapacityCheck
+	if skip, _ := jobData["skip_capacity_check"].(bool); s.config.DiskGuard.CheckBeforeDeploy && !skip {
+		capacityCheck, err = s.checkDeployCapacity("", sourceAgentID, sourcePath, destinations, s.effectiveDiskGuard(diskGuard))
+		if err != nil {
+			log.Printf("❌ Capacity check failed for new job %s: %v", name, err)
+			writeCapacityError(w, capacityCheck, err)
+			return
+		}
 	}
 
 	// Start transaction for atomic job creation
@@ -2524,10 +2923,14 @@ func (s *SyncToolServer) handleCreateSyncJob(w http.ResponseWriter, r *http.Requ
 
 	var jobID int
 	err = tx.QueryRow(`
-		INSERT INTO sync_jobs (name, source_agent_id, target_agent_id, source_path, target_path, sync_type, status, rescan_interval, ignore_patterns, schedule_type, is_multi_destination)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO sync_jobs (name, source_agent_id, target_agent_id, source_path, target_path, sync_type, status, rescan_interval, ignore_patterns, schedule_type, is_multi_destination, cron_expression, timezone, next_scheduled_run, bandwidth_limit, versioning, disk_guard, deletion_guard, verify_schedule, next_verification_at,
+		                       job_type, mirror_status, mirror_started_at, hooks, conflict_policy, template_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
 		RETURNING id
-	`, name, sourceAgentID, targetAgentID, sourcePath, targetPath, syncType, "active", rescanInterval, pq.Array(ignorePatterns), schedule, isMultiDestination).Scan(&jobID)
+	`, name, sourceAgentID, targetAgentID, sourcePath, targetPath, syncType, "active", rescanInterval, pq.Array(ignorePatterns), jobSchedule.Type, isMultiDestination,
+		nullIfEmpty(jobSchedule.CronExpression), jobSchedule.Timezone, nextScheduledRun, bandwidthLimitJSON(bandwidthLimit), versioningPolicyJSON(versioning),
+		diskGuardPolicyJSON(diskGuard), deletionGuardPolicyJSON(deletionGuard), verifyExpression, nextVerification,
+		jobType, mirrorStatus, mirrorStartedAt, jobHooksJSON(hooks), conflictPolicyJSON(conflictPolicy), templateID).Scan(&jobID)
 
 	if err != nil {
 		log.Printf("❌ Failed to save sync job to database: %v", err)
@@ -2563,6 +2966,12 @@ func (s *SyncToolServer) handleCreateSyncJob(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	if err := replaceJobDependencies(tx, jobID, dependsOn); err != nil {
+		log.Printf("❌ Failed to save job dependencies to database: %v", err)
+		http.Error(w, `{"error": "Failed to save job dependencies to database"}`, http.StatusInternalServerError)
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		log.Printf("❌ Failed to commit transaction: %v", err)
@@ -2588,6 +2997,23 @@ func (s *SyncToolServer) handleCreateSyncJob(w http.ResponseWriter, r *http.Requ
 		"job_id":               jobID,
 		"is_multi_destination": isMultiDestination,
 		"destination_count":    len(destinations),
+		"schedule_type":        jobSchedule.Type,
+		"cron_expression":      jobSchedule.CronExpression,
+		"timezone":             jobSchedule.Timezone,
+		"next_runs":            formatScheduleRuns(jobSchedule.NextRuns(previewRunCount(jobData))),
+		"bandwidth_limit":      bandwidthLimit,
+		"versioning":           versioning,
+		"disk_guard":           diskGuard,
+		"deletion_guard":       deletionGuard,
+		"verify_schedule":      verifyExpression,
+		"next_verification_at": nextVerification,
+		"job_type":             jobType,
+		"mirror_status":        mirrorStatus,
+		"hooks":                hooks,
+		"conflict_policy":      conflictPolicy,
+		"depends_on":           dependsOn,
+		"template_id":          templateID,
+		"capacity_check":       capacityCheck,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -2625,21 +3051,121 @@ func (s *SyncToolServer) handleUpdateSyncJob(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	// Extract schedule from frontend data
-	schedule := "continuous" // default
-	if sched, ok := jobData["schedule"].(string); ok && sched != "" {
-		schedule = sched
+	// Extract and validate schedule (type, cron expression, time zone)
+	jobSchedule, err := parseScheduleFromJobData(jobData)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	var nextScheduledRun *time.Time
+	if jobSchedule.IsScheduled() {
+		next := jobSchedule.Next(nil)
+		nextScheduledRun = &next
 	}
 
-	_, err := s.db.Exec(`
+	// Bandwidth limit is only changed if the field is sent (null removes it)
+	bandwidthLimit, bandwidthPresent, err := bandwidthFromJobData(jobData)
+	if err != nil {
+		writeBandwidthLimitError(w, err)
+		return
+	}
+
+	// Versioning policy is only changed if the field is sent (null removes it)
+	versioning, versioningPresent, err := versioningFromJobData(jobData)
+	if err != nil {
+		writeVersioningError(w, err)
+		return
+	}
+	if versioningPresent && versioningCommandChanged(s.jobVersioningPolicy(jobID), versioning) && !s.callerIsAdmin(r) {
+		s.writeJSONError(w, http.StatusForbidden, "Access denied: only admins can change external versioning")
+		return
+	}
+
+	// Disk guard is only changed if the field is sent (null restores the server defaults)
+	diskGuard, diskGuardPresent, err := diskGuardFromJobData(jobData)
+	if err != nil {
+		writeDiskGuardError(w, err)
+		return
+	}
+
+	// Deletion guard is only changed if the field is sent (null turns it off)
+	deletionGuard, deletionGuardPresent, err := deletionGuardFromJobData(jobData)
+	if err != nil {
+		writeDeletionGuardError(w, err)
+		return
+	}
+
+	// Verification schedule is only changed if the field is sent (null removes it)
+	verifySchedule, verifySchedulePresent, err := verifyScheduleFromJobData(jobData, jobSchedule.Timezone)
+	if err != nil {
+		writeVerifyScheduleError(w, err)
+		return
+	}
+
+	// Hooks are only changed if the field is sent (null removes them)
+	hooks, hooksPresent, err := hooksFromJobData(jobData)
+	if err != nil {
+		writeHooksError(w, err)
+		return
+	}
+	if hooksPresent && hooksChanged(s.jobHooks(jobID), hooks) && !s.callerIsAdmin(r) {
+		s.writeJSONError(w, http.StatusForbidden, "Access denied: only admins can change hooks")
+		return
+	}
+
+	// The conflict policy is only changed if the field is sent (null resolves conflicts manually)
+	conflictPolicy, conflictPolicyPresent, err := conflictPolicyFromJobData(jobData)
+	if err != nil {
+		writeConflictPolicyError(w, err)
+		return
+	}
+
+	// Dependencies are only changed if the field is sent (null or [] removes them)
+	dependsOn, dependsOnPresent, err := dependsOnFromJobData(jobData)
+	if err != nil {
+		writeJobDependencyError(w, err)
+		return
+	}
+	numericJobID, _ := strconv.Atoi(jobID)
+	if dependsOnPresent {
+		if err := validateJobDependencies(s.db, numericJobID, dependsOn); err != nil {
+			writeJobDependencyError(w, err)
+			return
+		}
+	}
+
+	// The source data must still fit the destination, e.g. after changing the source path
+	var capacityCheck *CapacityCheck
+	if skip, _ := jobData["skip_capacity_check"].(bool); s.config.DiskGuard.CheckBeforeDeploy && !skip {
+		effectiveGuard := s.jobDiskGuard(jobID)
+		if diskGuardPresent {
+			effectiveGuard = s.effectiveDiskGuard(diskGuard)
+		}
+		sourceAgentID, _ := jobData["source_agent_id"].(string)
+		sourcePath, _ := jobData["source_path"].(string)
+		destinations := []map[string]interface{}{{
+			"agent_id": jobData["destination_agent_id"],
+			"path":     jobData["destination_path"],
+		}}
+		capacityCheck, err = s.checkDeployCapacity(jobID, sourceAgentID, sourcePath, destinations, effectiveGuard)
+		if err != nil {
+			log.Printf("❌ Capacity check failed for job %s: %v", jobID, err)
+			writeCapacityError(w, capacityCheck, err)
+			return
+		}
+	}
+
+	_, err = s.db.Exec(`
 		UPDATE sync_jobs 
 		SET name = $1, source_agent_id = $2, target_agent_id = $3, 
 		    source_path = $4, target_path = $5, sync_type = $6, 
-		    rescan_interval = $7, ignore_patterns = $8, schedule_type = $9, updated_at = $10
+		    rescan_interval = $7, ignore_patterns = $8, schedule_type = $9, updated_at = $10,
+		    cron_expression = $12, timezone = $13, next_scheduled_run = $14
 		WHERE id = $11
 	`, jobData["name"], jobData["source_agent_id"], jobData["destination_agent_id"],
 	   jobData["source_path"], jobData["destination_path"], syncType, 
-	   rescanInterval, pq.Array(ignorePatterns), schedule, time.Now(), jobID)
+	   rescanInterval, pq.Array(ignorePatterns), jobSchedule.Type, time.Now(), jobID,
+	   nullIfEmpty(jobSchedule.CronExpression), jobSchedule.Timezone, nextScheduledRun)
 	
 	if err != nil {
 		log.Printf("❌ Failed to update sync job: %v", err)
@@ -2647,7 +3173,72 @@ func (s *SyncToolServer) handleUpdateSyncJob(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Re-deploy job configuration to agents after update
+	if bandwidthPresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET bandwidth_limit = $1 WHERE id = $2`, bandwidthLimitJSON(bandwidthLimit), jobID); err != nil {
+			log.Printf("❌ Failed to update bandwidth limit of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update bandwidth limit"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if versioningPresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET versioning = $1 WHERE id = $2`, versioningPolicyJSON(versioning), jobID); err != nil {
+			log.Printf("❌ Failed to update versioning policy of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update versioning policy"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if diskGuardPresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET disk_guard = $1 WHERE id = $2`, diskGuardPolicyJSON(diskGuard), jobID); err != nil {
+			log.Printf("❌ Failed to update disk guard of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update disk guard"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if deletionGuardPresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET deletion_guard = $1 WHERE id = $2`, deletionGuardPolicyJSON(deletionGuard), jobID); err != nil {
+			log.Printf("❌ Failed to update deletion guard of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update deletion guard"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	verifyExpression, nextVerification := verifyScheduleColumns(verifySchedule)
+	if verifySchedulePresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET verify_schedule = $1, next_verification_at = $2 WHERE id = $3`, verifyExpression, nextVerification, jobID); err != nil {
+			log.Printf("❌ Failed to update verification schedule of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update verification schedule"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if hooksPresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET hooks = $1 WHERE id = $2`, jobHooksJSON(hooks), jobID); err != nil {
+			log.Printf("❌ Failed to update hooks of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update hooks"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if conflictPolicyPresent {
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET conflict_policy = $1 WHERE id = $2`, conflictPolicyJSON(conflictPolicy), jobID); err != nil {
+			log.Printf("❌ Failed to update conflict policy of sync job: %v", err)
+			http.Error(w, `{"error": "Failed to update conflict policy"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if dependsOnPresent {
+		if err := s.saveJobDependencies(numericJobID, dependsOn); err != nil {
+			log.Printf("❌ Failed to update dependencies of sync job: %v", err)
+			writeJobDependencyError(w, err)
+			return
+		}
+	}
+
+	// Re-deploy job configuration to agents after update (carries bandwidth limit, versioning, disk and deletion guard, hooks, conflict policy)
 	sourceAgentID := jobData["source_agent_id"].(string)
 	destinationAgentID := jobData["destination_agent_id"].(string)
 	sourcePath := jobData["source_path"].(string)
@@ -2664,8 +3255,40 @@ func (s *SyncToolServer) handleUpdateSyncJob(w http.ResponseWriter, r *http.Requ
 	}
 
 	response := map[string]interface{}{
-		"success": true,
-		"message": "Sync job updated successfully",
+		"success":         true,
+		"message":         "Sync job updated successfully",
+		"schedule_type":   jobSchedule.Type,
+		"cron_expression": jobSchedule.CronExpression,
+		"timezone":        jobSchedule.Timezone,
+		"next_runs":       formatScheduleRuns(jobSchedule.NextRuns(previewRunCount(jobData))),
+	}
+	if bandwidthPresent {
+		response["bandwidth_limit"] = bandwidthLimit
+	}
+	if versioningPresent {
+		response["versioning"] = versioning
+	}
+	if diskGuardPresent {
+		response["disk_guard"] = diskGuard
+	}
+	if deletionGuardPresent {
+		response["deletion_guard"] = deletionGuard
+	}
+	if verifySchedulePresent {
+		response["verify_schedule"] = verifyExpression
+		response["next_verification_at"] = nextVerification
+	}
+	if hooksPresent {
+		response["hooks"] = hooks
+	}
+	if conflictPolicyPresent {
+		response["conflict_policy"] = conflictPolicy
+	}
+	if dependsOnPresent {
+		response["depends_on"] = dependsOn
+	}
+	if capacityCheck != nil {
+		response["capacity_check"] = capacityCheck
 	}
 	
 	json.NewEncoder(w).Encode(response)
@@ -2683,8 +3306,13 @@ func (s *SyncToolServer) handleDeleteSyncJob(w http.ResponseWriter, r *http.Requ
 		log.Printf("❌ Failed to get job details: %v", err)
 	}
 
-	// First, try to delete from agents
-	deleteErr := s.deleteJobOnAgentsSync(jobID)
+	// First, try to delete from agents (a finished mirror already removed its folders)
+	var deleteErr error
+	if isFinishedMirror(s.jobMirrorStatus(jobID)) {
+		s.retryMirrorTeardown(jobID)
+	} else {
+		deleteErr = s.deleteJobOnAgentsSync(jobID)
+	}
 	if deleteErr != nil {
 		log.Printf("❌ Failed to delete job from agents: %v", deleteErr)
 		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete job from agents: %v"}`, deleteErr), http.StatusInternalServerError)
@@ -2747,6 +3375,17 @@ func (s *SyncToolServer) handlePauseSyncJob(w http.ResponseWriter, r *http.Reque
 
 // Resume sync job
 func (s *SyncToolServer) handleResumeSyncJob(w http.ResponseWriter, r *http.Request, jobID string) {
+	// A job paused after a large deletion only resumes once the deletions are confirmed
+	if hold := s.pendingDeletionHold(jobID); hold != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": fmt.Sprintf("Job is paused by the deletion guard: %d of %d files deleted on %s", hold.DeletedFiles, hold.TotalFiles, hold.AgentID),
+			"hold":  hold,
+			"hint":  fmt.Sprintf("confirm the deletions with POST /api/v1/sync-jobs/%s/deletion-guard/confirm", jobID),
+		})
+		return
+	}
+
 	// First, try to resume on agents
 	resumeErr := s.resumeJobOnAgentsSync(jobID)
 	if resumeErr != nil {
@@ -2755,8 +3394,9 @@ func (s *SyncToolServer) handleResumeSyncJob(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Only update database if agent resume was successful
-	_, err := s.db.Exec("UPDATE sync_jobs SET status = 'active', updated_at = $1 WHERE id = $2", time.Now(), jobID)
+	// Only update database if agent resume was successful.
+	// Clearing window_paused lets the next window evaluation pause the job again if needed.
+	_, err := s.db.Exec("UPDATE sync_jobs SET status = 'active', window_paused = false, updated_at = $1 WHERE id = $2", time.Now(), jobID)
 	if err != nil {
 		log.Printf("❌ Failed to update sync job status in database: %v", err)
 		// Rollback: pause the job on agents since DB update failed
@@ -2777,12 +3417,25 @@ func (s *SyncToolServer) handleResumeSyncJob(w http.ResponseWriter, r *http.Requ
 func (s *SyncToolServer) handleGetSyncJob(w http.ResponseWriter, r *http.Request, jobID string) {
 	var id int
 	var name, sourceAgentID, destinationAgentID, sourcePath, destinationPath, syncType, status string
+	var scheduleType, cronExpression, timezone string
+	var nextScheduledRun, nextVerification *time.Time
+	var windowPaused bool
+	var bandwidthLimit, versioning, diskGuard, deletionGuard, hooks, conflictPolicy sql.NullString
+	var verifySchedule *string
+	var jobType string
+	var mirrorStatus *string
+	var templateID *int
 	var createdAt, updatedAt time.Time
 	
 	err := s.db.QueryRow(`
-		SELECT id, name, source_agent_id, target_agent_id, source_path, target_path, sync_type, status, created_at, updated_at
+		SELECT id, name, source_agent_id, COALESCE(target_agent_id, ''), source_path, COALESCE(target_path, ''), sync_type, status, created_at, updated_at,
+		       COALESCE(schedule_type, 'continuous'), COALESCE(cron_expression, ''), COALESCE(timezone, 'UTC'), next_scheduled_run,
+		       COALESCE(window_paused, false), bandwidth_limit, versioning, disk_guard, deletion_guard, verify_schedule, next_verification_at,
+		       COALESCE(job_type, 'sync'), mirror_status, hooks, conflict_policy, template_id
 		FROM sync_jobs WHERE id = $1
-	`, jobID).Scan(&id, &name, &sourceAgentID, &destinationAgentID, &sourcePath, &destinationPath, &syncType, &status, &createdAt, &updatedAt)
+	`, jobID).Scan(&id, &name, &sourceAgentID, &destinationAgentID, &sourcePath, &destinationPath, &syncType, &status, &createdAt, &updatedAt,
+		&scheduleType, &cronExpression, &timezone, &nextScheduledRun, &windowPaused, &bandwidthLimit, &versioning, &diskGuard, &deletionGuard,
+		&verifySchedule, &nextVerification, &jobType, &mirrorStatus, &hooks, &conflictPolicy, &templateID)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -2812,12 +3465,32 @@ func (s *SyncToolServer) handleGetSyncJob(w http.ResponseWriter, r *http.Request
 		"destination_path":     destinationPath,
 		"sync_mode":            syncMode,
 		"sync_type":            syncType,
-		"schedule":             "continuous",
+		"schedule":             scheduleType,
+		"cron_expression":      cronExpression,
+		"timezone":             timezone,
+		"next_scheduled_run":   nextScheduledRun,
 		"rescan_interval":      3600,
 		"max_file_size":        104857600,
 		"ignore_patterns":      []string{},
 		"auto_accept":          true,
 		"is_paused":            isPaused,
+		"window_paused":        windowPaused,
+		"bandwidth_limit":      scanBandwidthLimit(bandwidthLimit),
+		"versioning":           scanVersioningPolicy(versioning),
+		"disk_guard":           scanDiskGuardPolicy(diskGuard),
+		"disk_paused_agents":   s.diskPausedAgents(jobID),
+		"deletion_guard":       scanDeletionGuardPolicy(deletionGuard),
+		"deletion_hold":        s.pendingDeletionHold(jobID),
+		"verify_schedule":      verifySchedule,
+		"next_verification_at": nextVerification,
+		"last_verification":    s.lastJobVerification(jobID),
+		"job_type":             jobType,
+		"mirror_status":        mirrorStatus,
+		"hooks":                scanJobHooks(hooks),
+		"conflict_policy":      scanConflictPolicy(conflictPolicy),
+		"depends_on":           s.jobDependsOn(jobID),
+		"open_conflicts":       s.openConflictCount(jobID),
+		"template_id":          templateID,
 		"status":               status,
 		"created_at":           createdAt.Format(time.RFC3339),
 		"updated_at":           updatedAt.Format(time.RFC3339),
@@ -2826,6 +3499,47 @@ func (s *SyncToolServer) handleGetSyncJob(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(syncJob)
 }
 
+// handleValidateSchedule validates schedule fields without saving and returns the next run times
+func (s *SyncToolServer) handleValidateSchedule(w http.ResponseWriter, r *http.Request) {
+	var jobData map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&jobData); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	jobSchedule, err := parseScheduleFromJobData(jobData)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"valid":           true,
+		"schedule_type":   jobSchedule.Type,
+		"cron_expression": jobSchedule.CronExpression,
+		"timezone":        jobSchedule.Timezone,
+		"next_runs":       formatScheduleRuns(jobSchedule.NextRuns(previewRunCount(jobData))),
+	})
+}
+
+// writeScheduleError writes a 400 response for an invalid schedule
+func writeScheduleError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid schedule: %v", err),
+		"valid": false,
+	})
+}
+
+// nullIfEmpty converts an empty string to NULL for optional columns
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
 // Helper function to get string value from nullable string pointer
 func getStringValue(s *string) string {
 	if s == nil {
@@ -2844,95 +3558,69 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-// Deploy job to agents via WebSocket
-func (s *SyncToolServer) deployJobToAgents(jobID int, name, sourceAgentID, destinationAgentID, sourcePath, destinationPath, syncType string) {
-	log.Printf("🚀 Deploying sync job %d to agents: %s → %s", jobID, sourceAgentID, destinationAgentID)
-	
-	// Get source and destination agents device IDs
-	sourceDeviceID, destDeviceID, err := s.getAgentDeviceIDs(sourceAgentID, destinationAgentID)
+// redeployJobToAgents sends the current configuration of a job to its source and destination agents,
+// e.g. after settings were changed outside the job API. Jobs paused on the server stay paused.
+func (s *SyncToolServer) redeployJobToAgents(jobID string) error {
+	var name, sourceAgentID, sourcePath, syncType, status string
+	var targetAgentID, targetPath string
+	var rescanInterval int
+	var ignorePatterns pq.StringArray
+	var windowPaused bool
+
+	err := s.db.QueryRow(`
+		SELECT name, source_agent_id, source_path, sync_type, status, COALESCE(target_agent_id, ''), COALESCE(target_path, ''),
+		       COALESCE(rescan_interval, 3600), ignore_patterns, COALESCE(window_paused, false)
+		FROM sync_jobs WHERE id = $1
+	`, jobID).Scan(&name, &sourceAgentID, &sourcePath, &syncType, &status, &targetAgentID, &targetPath,
+		&rescanInterval, &ignorePatterns, &windowPaused)
 	if err != nil {
-		log.Printf("❌ Failed to get device IDs for job %d: %v", jobID, err)
-		return
+		return fmt.Errorf("failed to get job details: %v", err)
 	}
-	
-	folderID := fmt.Sprintf("job-%d", jobID)
-	
-	// Create job configuration for source agent (sender)
-	sourceJobConfig := map[string]interface{}{
-		"type":         "deploy_job",
-		"job_id":       jobID,
-		"folder_id":    folderID,
-		"folder_name":  name,
-		"folder_path":  sourcePath,
-		"folder_type":  syncType,
-		"device_id":    destDeviceID,
-		"device_name":  destinationAgentID,
-		"role":         "source",
+
+	rows, err := s.db.Query(`
+		SELECT destination_agent_id, destination_path FROM sync_job_destinations
+		WHERE job_id = $1 ORDER BY id
+	`, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to get destinations: %v", err)
 	}
-	
-	// Create job configuration for destination agent (receiver)  
-	destJobConfig := map[string]interface{}{
-		"type":         "deploy_job",
-		"job_id":       jobID,
-		"folder_id":    folderID,
-		"folder_name":  name,
-		"folder_path":  destinationPath,
-		"folder_type":  syncType,
-		"device_id":    sourceDeviceID,
-		"device_name":  sourceAgentID,
-		"role":         "destination",
+	var destinations []map[string]interface{}
+	for rows.Next() {
+		var destAgentID, destPath string
+		if err := rows.Scan(&destAgentID, &destPath); err != nil {
+			continue
+		}
+		destinations = append(destinations, map[string]interface{}{
+			"agent_id": destAgentID,
+			"path":     destPath,
+		})
 	}
-	
-	// Send to source agent
-	if err := s.sendJobToAgent(sourceAgentID, sourceJobConfig); err != nil {
-		log.Printf("❌ Failed to send job to source agent %s: %v", sourceAgentID, err)
+	rows.Close()
+
+	// Jobs created before multi-destination support only have the target columns
+	if len(destinations) == 0 && targetAgentID != "" {
+		destinations = []map[string]interface{}{{
+			"agent_id": targetAgentID,
+			"path":     targetPath,
+		}}
 	}
-	
-	// Send to destination agent
-	if err := s.sendJobToAgent(destinationAgentID, destJobConfig); err != nil {
-		log.Printf("❌ Failed to send job to destination agent %s: %v", destinationAgentID, err)
+	if len(destinations) == 0 {
+		return fmt.Errorf("job has no destinations")
 	}
-}
 
-// Re-deploy job when resumed
-func (s *SyncToolServer) redeployJobToAgents(jobIDStr string) {
-	jobID := jobIDStr // String conversion if needed
-	
-	// Get job details from database
-	var id int
-	var name, sourceAgentID, destinationAgentID, sourcePath, destinationPath, syncType string
-	
-	err := s.db.QueryRow(`
-		SELECT id, name, source_agent_id, target_agent_id, source_path, target_path, sync_type
-		FROM sync_jobs WHERE id = $1
-	`, jobID).Scan(&id, &name, &sourceAgentID, &destinationAgentID, &sourcePath, &destinationPath, &syncType)
-	
-	if err != nil {
-		log.Printf("❌ Failed to get job details for redeploy: %v", err)
-		return
+	log.Printf("🔄 Re-deploying job %s to source and %d destination(s)", jobID, len(destinations))
+	if err := s.deployJobToAgentsSyncMulti(jobID, name, sourceAgentID, sourcePath, destinations, syncType, rescanInterval, []string(ignorePatterns)); err != nil {
+		return err
 	}
-	
-	log.Printf("🔄 Re-deploying job %d after resume", id)
-	s.deployJobToAgents(id, name, sourceAgentID, destinationAgentID, sourcePath, destinationPath, syncType)
-}
 
-// Get device IDs for source and destination agents
-func (s *SyncToolServer) getAgentDeviceIDs(sourceAgentID, destinationAgentID string) (string, string, error) {
-	var sourceDeviceID, destDeviceID string
-	
-	// Get source agent device ID
-	err := s.db.QueryRow("SELECT device_id FROM integrated_agents WHERE agent_id = $1", sourceAgentID).Scan(&sourceDeviceID)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get source agent device ID: %w", err)
+	// A deployed folder is running on the agents
+	if status == "paused" {
+		return s.pauseJobOnAgentsSync(jobID)
 	}
-	
-	// Get destination agent device ID
-	err = s.db.QueryRow("SELECT device_id FROM integrated_agents WHERE agent_id = $1", destinationAgentID).Scan(&destDeviceID)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get destination agent device ID: %w", err)
+	if windowPaused {
+		return s.pauseJobOnAgentsWithReason(jobID, pauseReasonMaintenanceWindow)
 	}
-	
-	return sourceDeviceID, destDeviceID, nil
+	return nil
 }
 
 // Send job configuration to specific agent via WebSocket
@@ -2948,6 +3636,9 @@ func (s *SyncToolServer) sendJobToAgent(agentID string, jobConfig map[string]int
 	if !agent.isOnline || agent.send == nil {
 		return fmt.Errorf("agent %s is offline or channel closed", agentID)
 	}
+	if msgType, _ := jobConfig["type"].(string); agent.certificateOnly && !certificateSessionMessage(msgType) {
+		return fmt.Errorf("agent %s has no client certificate yet", agentID)
+	}
 	
 	// Serialize job config to JSON
 	jobData, err := json.Marshal(jobConfig)
@@ -3080,22 +3771,29 @@ func (s *SyncToolServer) deployJobToAgentsSync(jobID, name, sourceAgentID, desti
 	log.Printf("📋 Job deployment details: source=%s(%s), dest=%s(%s)", sourceAgentID, sourceIPAddress, destinationAgentID, destinationIPAddress)
 	
 	// Create job configuration with agent IDs for role identification and device IDs for Syncthing
-	jobConfig := map[string]interface{}{
-		"type":                  "deploy_job",
-		"job_id":                jobID,
-		"name":                  name,
-		"source_agent_id":       sourceAgentID,         // Use agent ID for role identification
-		"destination_agent_id":  destinationAgentID,    // Use agent ID for role identification
-		"source_device_id":      sourceDeviceID,        // Use device ID for Syncthing configuration
-		"destination_device_id": destinationDeviceID,   // Use device ID for Syncthing configuration
-		"source_ip_address":     sourceIPAddress,       // 🆕 IP address for automatic device pairing
-		"destination_ip_address": destinationIPAddress, // 🆕 IP address for automatic device pairing
-		"source_path":           sourcePath,
-		"destination_path":      destinationPath,
-		"sync_type":             syncType,
-		"rescan_interval_s":     rescanInterval,        // Add rescan interval support
-		"ignore_patterns":       ignorePatterns,        // Add ignore patterns support
-	}
+	jobConfig := protocol.ToMap(&protocol.DeployJob{
+		Type:                 protocol.TypeDeployJob,
+		JobID:                jobID,
+		Name:                 name,
+		SourceAgentID:        sourceAgentID,         // Use agent ID for role identification
+		DestinationAgentID:   destinationAgentID,    // Use agent ID for role identification
+		SourceDeviceID:       sourceDeviceID,        // Use device ID for Syncthing configuration
+		DestinationDeviceID:  destinationDeviceID,   // Use device ID for Syncthing configuration
+		SourceIPAddress:      sourceIPAddress,       // 🆕 IP address for automatic device pairing
+		DestinationIPAddress: destinationIPAddress,  // 🆕 IP address for automatic device pairing
+		SourcePath:           sourcePath,
+		DestinationPath:      destinationPath,
+		SyncType:             syncType,
+		RescanIntervalS:      &rescanInterval,       // Add rescan interval support
+		IgnorePatterns:       ignorePatterns,        // Add ignore patterns support
+		MaintenanceWindows:   s.maintenanceWindowsForJob(jobID), // Evaluated locally by the agents
+		BandwidthLimit:       s.jobBandwidthLimit(jobID),        // Applied to the device config by the agents
+		Versioning:           s.jobDestinationVersioning(jobID), // Applied to the destination folder only
+		DiskGuard:            s.jobDiskGuard(jobID),             // Enforced by the destination agent only
+		DeletionGuard:        s.jobDeletionGuard(jobID),         // Enforced by the source agent only
+		Hooks:                s.jobHooks(jobID),                 // pre_scan run by the source, post_session by the destination
+		ConflictPolicy:       s.jobConflictPolicy(jobID),        // Enforced by the agent that finds a conflict copy
+	})
 	
 	// Send to both agents and wait for confirmation
 	sourceErr := s.sendJobToAgentSync(sourceAgentID, jobConfig)
@@ -3167,22 +3865,36 @@ func (s *SyncToolServer) deployJobToAgentsSyncMulti(jobID, name, sourceAgentID,
 		log.Printf("📋 Destination %d: agent=%s(%s), device=%s", i+1, destAgentID, destIPAddress, destDeviceID)
 	}
 
+	// Windows are evaluated locally by the agents as well
+	maintenanceWindows := s.maintenanceWindowsForJob(jobID)
+	bandwidthLimit := s.jobBandwidthLimit(jobID)
+	versioning := s.jobDestinationVersioning(jobID)
+	diskGuard := s.jobDiskGuard(jobID)
+	deletionGuard := s.jobDeletionGuard(jobID)
+	hooks := s.jobHooks(jobID)
+	conflictPolicy := s.jobConflictPolicy(jobID)
+
 	// Deploy to source agent
 	// Source folder will sync to ALL destination devices
-	sourceJobConfig := map[string]interface{}{
-		"type":                    "deploy_job",
-		"job_id":                  jobID,
-		"name":                    name,
-		"source_agent_id":         sourceAgentID,
-		"destination_agent_ids":   destAgentIDs,      // Array of all dest agent IDs
-		"destination_device_ids":  destDeviceIDs,     // Array of all dest device IDs
-		"destination_ip_addresses": destIPAddresses,  // Array of all dest IPs
-		"source_path":             sourcePath,
-		"sync_type":               syncType,
-		"rescan_interval_s":       rescanInterval,
-		"ignore_patterns":         ignorePatterns,
-		"is_multi_destination":    true,
-	}
+	sourceJobConfig := protocol.ToMap(&protocol.DeployJob{
+		Type:                   protocol.TypeDeployJob,
+		JobID:                  jobID,
+		Name:                   name,
+		SourceAgentID:          sourceAgentID,
+		DestinationAgentIDs:    destAgentIDs,    // Array of all dest agent IDs
+		DestinationDeviceIDs:   destDeviceIDs,   // Array of all dest device IDs
+		DestinationIPAddresses: destIPAddresses, // Array of all dest IPs
+		SourcePath:             sourcePath,
+		SyncType:               syncType,
+		RescanIntervalS:        &rescanInterval,
+		IgnorePatterns:         ignorePatterns,
+		IsMultiDestination:     true,
+		MaintenanceWindows:     maintenanceWindows,
+		BandwidthLimit:         bandwidthLimit,
+		DeletionGuard:          deletionGuard,
+		Hooks:                  hooks,
+		ConflictPolicy:         conflictPolicy,
+	})
 
 	sourceErr := s.sendJobToAgentSync(sourceAgentID, sourceJobConfig)
 	if sourceErr != nil {
@@ -3194,22 +3906,28 @@ func (s *SyncToolServer) deployJobToAgentsSyncMulti(jobID, name, sourceAgentID,
 	// Deploy to each destination agent
 	var deployedDests []string
 	for destAgentID, destConfig := range destConfigs {
-		destJobConfig := map[string]interface{}{
-			"type":                  "deploy_job",
-			"job_id":                jobID,
-			"name":                  name,
-			"source_agent_id":       sourceAgentID,
-			"destination_agent_id":  destAgentID, // This dest's agent ID
-			"source_device_id":      sourceDeviceID,
-			"destination_device_id": destConfig["device_id"].(string),
-			"source_ip_address":     sourceIPAddress,
-			"destination_ip_address": destConfig["ip_address"].(string),
-			"destination_path":      destConfig["path"].(string),
-			"sync_type":             syncType,
-			"rescan_interval_s":     rescanInterval,
-			"ignore_patterns":       ignorePatterns,
-			"is_multi_destination":  true,
-		}
+		destJobConfig := protocol.ToMap(&protocol.DeployJob{
+			Type:                 protocol.TypeDeployJob,
+			JobID:                jobID,
+			Name:                 name,
+			SourceAgentID:        sourceAgentID,
+			DestinationAgentID:   destAgentID, // This dest's agent ID
+			SourceDeviceID:       sourceDeviceID,
+			DestinationDeviceID:  destConfig["device_id"].(string),
+			SourceIPAddress:      sourceIPAddress,
+			DestinationIPAddress: destConfig["ip_address"].(string),
+			DestinationPath:      destConfig["path"].(string),
+			SyncType:             syncType,
+			RescanIntervalS:      &rescanInterval,
+			IgnorePatterns:       ignorePatterns,
+			IsMultiDestination:   true,
+			MaintenanceWindows:   maintenanceWindows,
+			BandwidthLimit:       bandwidthLimit,
+			Versioning:           versioning,
+			DiskGuard:            diskGuard,
+			Hooks:                hooks,
+			ConflictPolicy:       conflictPolicy,
+		})
 
 		destErr := s.sendJobToAgentSync(destAgentID, destJobConfig)
 		if destErr != nil {
@@ -3236,6 +3954,12 @@ func (s *SyncToolServer) deployJobToAgentsSyncMulti(jobID, name, sourceAgentID,
 
 // Synchronous job pause - waits for confirmation from agents
 func (s *SyncToolServer) pauseJobOnAgentsSync(jobID string) error {
+	return s.pauseJobOnAgentsWithReason(jobID, "")
+}
+
+// pauseJobOnAgentsWithReason pauses a job on all its agents, tagging the messages with
+// a reason (e.g. maintenance_window) so agents can tell automatic pauses from manual ones
+func (s *SyncToolServer) pauseJobOnAgentsWithReason(jobID, reason string) error {
 	// Get job details and check if multi-destination
 	var sourceAgentID string
 	var isMultiDest bool
@@ -3247,10 +3971,7 @@ func (s *SyncToolServer) pauseJobOnAgentsSync(jobID string) error {
 		return fmt.Errorf("failed to get job details: %v", err)
 	}
 
-	pauseConfig := map[string]interface{}{
-		"type":   "pause_job",
-		"job_id": jobID,
-	}
+	pauseConfig := protocol.ToMap(protocol.NewJobControl(protocol.TypePauseJob, jobID, reason))
 
 	// Pause source agent
 	sourceErr := s.sendJobToAgentSync(sourceAgentID, pauseConfig)
@@ -3326,6 +4047,11 @@ func (s *SyncToolServer) pauseJobOnAgentsSync(jobID string) error {
 
 // Synchronous job resume - waits for confirmation from agents
 func (s *SyncToolServer) resumeJobOnAgentsSync(jobID string) error {
+	return s.resumeJobOnAgentsWithReason(jobID, "")
+}
+
+// resumeJobOnAgentsWithReason resumes a job on all its agents, tagging the messages with a reason
+func (s *SyncToolServer) resumeJobOnAgentsWithReason(jobID, reason string) error {
 	// Get job details and check if multi-destination
 	var sourceAgentID string
 	var isMultiDest bool
@@ -3337,10 +4063,7 @@ func (s *SyncToolServer) resumeJobOnAgentsSync(jobID string) error {
 		return fmt.Errorf("failed to get job details: %v", err)
 	}
 
-	resumeConfig := map[string]interface{}{
-		"type":   "resume_job",
-		"job_id": jobID,
-	}
+	resumeConfig := protocol.ToMap(protocol.NewJobControl(protocol.TypeResumeJob, jobID, reason))
 
 	// Resume source agent
 	sourceErr := s.sendJobToAgentSync(sourceAgentID, resumeConfig)
@@ -3427,10 +4150,7 @@ func (s *SyncToolServer) deleteJobOnAgentsSync(jobID string) error {
 		return fmt.Errorf("failed to get job details: %v", err)
 	}
 
-	deleteConfig := map[string]interface{}{
-		"type":   "delete_job",
-		"job_id": jobID,
-	}
+	deleteConfig := protocol.ToMap(protocol.NewJobControl(protocol.TypeDeleteJob, jobID, ""))
 
 	// Delete from source agent
 	sourceErr := s.sendJobToAgentSync(sourceAgentID, deleteConfig)
@@ -3647,7 +4367,7 @@ func (s *SyncToolServer) handleBrowseFolders(w http.ResponseWriter, r *http.Requ
 	// Check if agent is online
 	s.hub.mutex.RLock()
 	agent, exists := s.hub.agents[agentID]
-	if !exists || !agent.isOnline || agent.send == nil {
+	if !exists || !agent.isOnline || agent.send == nil || agent.certificateOnly {
 		s.hub.mutex.RUnlock()
 		log.Printf("❌ Agent %s is not online for browse request", agentID)
 		http.Error(w, `{"error": "Agent is not online"}`, http.StatusServiceUnavailable)
@@ -4246,17 +4966,6 @@ func (qb *FileTransferLogQueryBuilder) scanLogRow(rows *sql.Rows) (map[string]in
 // handleFileTransferLogs handles API requests for file transfer logs with hybrid pagination
 // Features: 100-page limit + cursor pagination + enhanced search/filter
 func (s *SyncToolServer) handleFileTransferLogs(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers for all requests
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	// Handle preflight OPTIONS request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -4378,17 +5087,6 @@ func (s *SyncToolServer) handleFileTransferLogs(w http.ResponseWriter, r *http.R
 
 // handleTransferStats handles API requests for file transfer statistics
 func (s *SyncToolServer) handleTransferStats(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers for all requests
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	// Handle preflight OPTIONS request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -4552,17 +5250,6 @@ func (s *SyncToolServer) handleTransferStats(w http.ResponseWriter, r *http.Requ
 
 // handleFilterOptions handles API requests for filter dropdown options
 func (s *SyncToolServer) handleFilterOptions(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers for all requests
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	// Handle preflight OPTIONS request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -4753,17 +5440,6 @@ func (s *SyncToolServer) handleFilterOptions(w http.ResponseWriter, r *http.Requ
 
 // handleJobsList handles API requests for job names list
 func (s *SyncToolServer) handleJobsList(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers for all requests
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	// Handle preflight OPTIONS request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -4815,16 +5491,6 @@ func (s *SyncToolServer) handleJobsList(w http.ResponseWriter, r *http.Request)
 
 // handleTriggerScan handles manual scan trigger for testing scheduled jobs
 func (s *SyncToolServer) handleTriggerScan(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -4877,16 +5543,6 @@ func (s *SyncToolServer) handleTriggerScan(w http.ResponseWriter, r *http.Reques
 
 // handleSchedulerStatus returns the status of the job scheduler
 func (s *SyncToolServer) handleSchedulerStatus(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -4916,16 +5572,6 @@ func (s *SyncToolServer) handleSchedulerStatus(w http.ResponseWriter, r *http.Re
 
 // handleFolderStats handles folder statistics requests
 func (s *SyncToolServer) handleFolderStats(w http.ResponseWriter, r *http.Request) {
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	
 	if r.Method != http.MethodGet && r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -5111,16 +5757,6 @@ func (s *SyncToolServer) handleFolderStats(w http.ResponseWriter, r *http.Reques
 
 // handleFolderStatsOverall handles dashboard statistics from all jobs
 func (s *SyncToolServer) handleFolderStatsOverall(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -5582,6 +6218,29 @@ func (s *SyncToolServer) handleSessionCompleted(agentID string, data map[string]
 
 	log.Printf("✅ [SESSION] Session completed: %s | Files: %d | Delta: %d bytes | Full: %d bytes | Ratio: %.2f%% | Duration: %ds",
 		sessionID, filesTransferred, totalDeltaBytes, totalFullFileSize, compressionRatio*100, totalDuration)
+
+	sessionDuration.Observe(float64(totalDuration), status)
+
+	jobID, _ := data["job_id"].(string)
+
+	// Jobs depending on this one start once it delivered new data (agents send the job ID with or without the folder prefix)
+	if upstreamJobID := strings.TrimPrefix(jobID, "job-"); status == "completed" && filesTransferred > 0 && upstreamJobID != "" {
+		if _, err := strconv.Atoi(upstreamJobID); err == nil {
+			go s.triggerDependentJobs(upstreamJobID, agentID, sessionID)
+		}
+	}
+
+	go s.emitWebhookEvent(WebhookEventSessionCompleted, map[string]interface{}{
+		"session_id":             sessionID,
+		"job_id":                 jobID,
+		"agent_id":               agentID,
+		"status":                 status,
+		"files_transferred":      filesTransferred,
+		"total_delta_bytes":      totalDeltaBytes,
+		"total_full_file_size":   totalFullFileSize,
+		"total_duration_seconds": totalDuration,
+		"session_end_time":       sessionEndTime,
+	})
 }
 
 // insertSessionEvent inserts an event into sync_session_events table
@@ -5632,7 +6291,7 @@ func (s *SyncToolServer) handleSessions(w http.ResponseWriter, r *http.Request)
 			scan_duration_seconds, transfer_duration_seconds,
 			files_transferred, total_delta_bytes, total_full_file_size,
 			compression_ratio, average_transfer_rate, peak_transfer_rate,
-			current_state, status, created_at
+			current_state, status, created_at, hook_results
 		FROM sync_sessions
 		WHERE 1=1
 	`
@@ -5678,6 +6337,7 @@ func (s *SyncToolServer) handleSessions(w http.ResponseWriter, r *http.Request)
 			totalDuration, scanDuration, transferDuration                *int64
 			filesTransferred, totalDeltaBytes, totalFullFileSize         int64
 			compressionRatio, avgRate, peakRate                          *float64
+			hookResults                                                  sql.NullString
 		)
 
 		err := rows.Scan(
@@ -5686,7 +6346,7 @@ func (s *SyncToolServer) handleSessions(w http.ResponseWriter, r *http.Request)
 			&scanDuration, &transferDuration,
 			&filesTransferred, &totalDeltaBytes, &totalFullFileSize,
 			&compressionRatio, &avgRate, &peakRate,
-			&currentState, &statusVal, &createdAt,
+			&currentState, &statusVal, &createdAt, &hookResults,
 		)
 
 		if err != nil {
@@ -5713,6 +6373,7 @@ func (s *SyncToolServer) handleSessions(w http.ResponseWriter, r *http.Request)
 			"current_state":      currentState,
 			"status":             statusVal,
 			"created_at":         createdAt,
+			"hook_results":       scanHookResults(hookResults),
 		}
 
 		// Calculate efficiency percentage
@@ -5757,7 +6418,7 @@ func (s *SyncToolServer) handleSessionDetails(w http.ResponseWriter, r *http.Req
 			transfer_start_time, transfer_end_time, transfer_duration_seconds,
 			files_transferred, total_delta_bytes, total_full_file_size,
 			compression_ratio, average_transfer_rate, peak_transfer_rate,
-			current_state, status, error_message, created_at, updated_at
+			current_state, status, error_message, created_at, updated_at, hook_results
 		FROM sync_sessions
 		WHERE session_id = $1
 	`
@@ -5771,6 +6432,7 @@ func (s *SyncToolServer) handleSessionDetails(w http.ResponseWriter, r *http.Req
 		filesTransferred, totalDeltaBytes, totalFullFileSize  int64
 		compressionRatio, avgRate, peakRate                   *float64
 		errorMessage                                          *string
+		hookResults                                           sql.NullString
 	)
 
 	err := s.db.QueryRow(query, sessionID).Scan(
@@ -5780,7 +6442,7 @@ func (s *SyncToolServer) handleSessionDetails(w http.ResponseWriter, r *http.Req
 		&transferStartTime, &transferEndTime, &transferDuration,
 		&filesTransferred, &totalDeltaBytes, &totalFullFileSize,
 		&compressionRatio, &avgRate, &peakRate,
-		&currentState, &statusVal, &errorMessage, &createdAt, &updatedAt,
+		&currentState, &statusVal, &errorMessage, &createdAt, &updatedAt, &hookResults,
 	)
 
 	if err == sql.ErrNoRows {
@@ -5859,6 +6521,7 @@ func (s *SyncToolServer) handleSessionDetails(w http.ResponseWriter, r *http.Req
 		"error_message":      errorMessage,
 		"created_at":         createdAt,
 		"updated_at":         updatedAt,
+		"hook_results":       scanHookResults(hookResults),
 		"events":             events,
 	}
 
@@ -5898,16 +6561,6 @@ func formatDuration(seconds int64) string {
 
 // handleGetSyncStatusMaster returns list of available sync status options for filters
 func (s *SyncToolServer) handleGetSyncStatusMaster(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -5973,16 +6626,6 @@ func (s *SyncToolServer) handleGetSyncStatusMaster(w http.ResponseWriter, r *htt
 
 // handleGetJobStatusMaster returns list of available job status options
 func (s *SyncToolServer) handleGetJobStatusMaster(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -5,13 +5,29 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 )
 
 type TLSConfig struct {
-	Enabled  bool   `json:"enabled"`
-	CertFile string `json:"cert_file"`
-	KeyFile  string `json:"key_file"`
-	AutoTLS  bool   `json:"auto_tls"`
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	AutoTLS  bool   `json:"auto_tls" yaml:"auto_tls"`
+
+	// Mutual TLS for agent connections using the built-in agent CA
+	MTLS MTLSConfig `json:"mtls" yaml:"mtls"`
+}
+
+// MTLSConfig holds settings for the built-in CA that issues agent client certificates
+type MTLSConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Required limits agents without a client certificate to obtaining one: they connect with their
+	// credential or enrollment token, but no jobs or commands are exchanged until they reconnect
+	// with the certificate issued after approval. Agents with a valid certificate must present it.
+	Required     bool          `json:"required" yaml:"required"`
+	CADir        string        `json:"ca_dir" yaml:"ca_dir"`
+	CertLifetime time.Duration `json:"cert_lifetime" yaml:"cert_lifetime"`
+	RenewBefore  time.Duration `json:"renew_before" yaml:"renew_before"`
 }
 
 func (s *SyncToolServer) StartWithTLS(tlsConfig *TLSConfig) error {
@@ -28,11 +44,17 @@ func (s *SyncToolServer) StartWithTLS(tlsConfig *TLSConfig) error {
 	// PUBLIC ROUTES (No authentication)
 	// ========================================
 	mux.HandleFunc("/health", s.handleHealth)
+	if s.config.Metrics.Enabled {
+		mux.HandleFunc("/metrics", s.handleMetrics) // Prometheus scrape endpoint (optional bearer token)
+	}
 
 	// WebSocket endpoints (agents use their own auth)
 	mux.HandleFunc("/ws/agent", s.handleAgentWebSocket)
 	mux.HandleFunc("/ws/cli", s.handleCLIWebSocket)
 
+	// Agent CA certificate and revocation list (public)
+	mux.HandleFunc("/api/v1/agent-ca/", s.handleAgentCA)
+
 	// Authentication endpoints (public)
 	if s.authService != nil {
 		mux.HandleFunc("/api/v1/auth/login", s.handleUserLogin)
@@ -73,6 +95,16 @@ func (s *SyncToolServer) StartWithTLS(tlsConfig *TLSConfig) error {
 		mux.HandleFunc("/api/folder-stats", s.withAuth(s.handleFolderStats)) // Get folder statistics from agent
 		mux.HandleFunc("/api/v1/folder-stats/stats", s.withAuth(s.handleFolderStatsOverall)) // Dashboard statistics
 		mux.HandleFunc("/api/v1/scheduler/status", s.withAuth(s.handleSchedulerStatus)) // Scheduler status
+		mux.HandleFunc("/api/v1/maintenance-windows", s.withAuth(s.withAdminRoleForMutations(s.handleMaintenanceWindows)))        // Maintenance windows
+		mux.HandleFunc("/api/v1/maintenance-windows/", s.withAuth(s.withAdminRoleForMutations(s.handleMaintenanceWindowActions))) // Maintenance window actions
+		mux.HandleFunc("/api/v1/enrollment-tokens", s.withAuth(s.withAdminRoleForMutations(s.handleEnrollmentTokens)))        // Agent enrollment tokens
+		mux.HandleFunc("/api/v1/enrollment-tokens/", s.withAuth(s.withAdminRoleForMutations(s.handleEnrollmentTokenActions))) // Enrollment token actions
+		mux.HandleFunc("/api/v1/webhooks", s.withAuth(s.withAdminRole(s.handleWebhooks)))                                      // Webhooks
+		mux.HandleFunc("/api/v1/webhooks/", s.withAuth(s.withAdminRole(s.handleWebhookActions)))                               // Webhook actions, test and delivery log
+		mux.HandleFunc("/api/v1/alert-rules", s.withAuth(s.withAdminRoleForMutations(s.handleAlertRules)))                     // Email alert rules
+		mux.HandleFunc("/api/v1/alert-rules/", s.withAuth(s.withAdminRoleForMutations(s.handleAlertRuleActions)))              // Alert rule actions, states and test email
+		mux.HandleFunc("/api/v1/job-templates", s.withAuth(s.withAdminRoleForMutations(s.handleJobTemplates)))                 // Sync job templates
+		mux.HandleFunc("/api/v1/job-templates/", s.withAuth(s.handleJobTemplateActions))                                       // Template actions, jobs created from a template and propagation
 		mux.HandleFunc("/api/v1/licenses", s.withAuth(s.handleLicenses))               // License CRUD
 		mux.HandleFunc("/api/v1/licenses/", s.withAuth(s.handleLicenseActions))        // License actions
 		mux.HandleFunc("/api/v1/agent-licenses", s.withAuth(s.handleAgentLicenses))    // Agent-license mapping
@@ -114,6 +146,16 @@ func (s *SyncToolServer) StartWithTLS(tlsConfig *TLSConfig) error {
 		mux.HandleFunc("/api/folder-stats", s.handleFolderStats)
 		mux.HandleFunc("/api/v1/folder-stats/stats", s.handleFolderStatsOverall)
 		mux.HandleFunc("/api/v1/scheduler/status", s.handleSchedulerStatus)
+		mux.HandleFunc("/api/v1/maintenance-windows", s.handleMaintenanceWindows)
+		mux.HandleFunc("/api/v1/maintenance-windows/", s.handleMaintenanceWindowActions)
+		mux.HandleFunc("/api/v1/enrollment-tokens", s.handleEnrollmentTokens)
+		mux.HandleFunc("/api/v1/enrollment-tokens/", s.handleEnrollmentTokenActions)
+		mux.HandleFunc("/api/v1/webhooks", s.handleWebhooks)
+		mux.HandleFunc("/api/v1/webhooks/", s.handleWebhookActions)
+		mux.HandleFunc("/api/v1/alert-rules", s.handleAlertRules)
+		mux.HandleFunc("/api/v1/alert-rules/", s.handleAlertRuleActions)
+		mux.HandleFunc("/api/v1/job-templates", s.handleJobTemplates)
+		mux.HandleFunc("/api/v1/job-templates/", s.handleJobTemplateActions)
 		mux.HandleFunc("/api/v1/licenses", s.handleLicenses)
 		mux.HandleFunc("/api/v1/licenses/", s.handleLicenseActions)
 		mux.HandleFunc("/api/v1/agent-licenses", s.handleAgentLicenses)
@@ -142,7 +184,23 @@ func (s *SyncToolServer) StartWithTLS(tlsConfig *TLSConfig) error {
 		protocol = "https"
 		wsProtocol = "wss"
 		
-		if tlsConfig.AutoTLS {
+		if tlsConfig.MTLS.Enabled {
+			ca, err := loadOrCreateAgentCA(tlsConfig.MTLS.CADir)
+			if err != nil {
+				return fmt.Errorf("failed to load agent CA: %v", err)
+			}
+			s.agentCA = ca
+		}
+
+		if tlsConfig.AutoTLS && s.agentCA != nil {
+			// Server certificate from the agent CA, so agents can pin the server with ca_file
+			cert, key, err := s.agentCA.issueServerCertificate(s.config.Host)
+			if err != nil {
+				return fmt.Errorf("failed to issue server certificate: %v", err)
+			}
+			tlsConfig.CertFile = cert
+			tlsConfig.KeyFile = key
+		} else if tlsConfig.AutoTLS {
 			// Generate self-signed certificate for development
 			cert, key, err := generateSelfSignedCert(s.config.Host)
 			if err != nil {
@@ -165,6 +223,14 @@ func (s *SyncToolServer) StartWithTLS(tlsConfig *TLSConfig) error {
 			},
 		}
 		
+		if s.agentCA != nil {
+			// Browsers and API clients connect without a certificate; /ws/agent checks it
+			tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+			tlsCfg.ClientCAs = s.agentCA.CertPool()
+			go s.runCertificateRotation()
+			log.Printf("🔐 Mutual TLS for agents enabled")
+		}
+
 		s.server.TLSConfig = tlsCfg
 	}
 
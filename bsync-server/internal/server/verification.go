@@ -0,0 +1,700 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"bsync-server/pkg/protocol"
+)
+
+// Verification triggers and states stored in job_verifications
+const (
+	verificationTriggerManual    = "manual"
+	verificationTriggerScheduled = "scheduled"
+
+	verificationRunning   = "running"
+	verificationCompleted = "completed"
+	verificationFailed    = "failed"
+)
+
+// Problems of a file stored in job_verification_files.problem
+const (
+	verificationProblemMismatched = "mismatched" // size or content differs
+	verificationProblemMissing    = "missing"    // on the source only
+	verificationProblemExtra      = "extra"      // on the destination only
+)
+
+const (
+	// maxVerificationFilesStored caps the files stored per destination, counts stay exact
+	maxVerificationFilesStored = 10000
+	verificationHistoryLimit   = 20
+	maxVerificationFilesPage   = 1000
+)
+
+// errVerificationRunning is returned when a job is already being verified
+var errVerificationRunning = errors.New("a verification of this job is already running")
+
+// ManifestEntry is a file of a job folder as hashed by an agent
+type ManifestEntry = protocol.ManifestEntry
+
+// VerificationDestination summarizes the comparison of one destination with the source
+type VerificationDestination struct {
+	AgentID    string `json:"agent_id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	Files      int64  `json:"files"`
+	Bytes      int64  `json:"bytes"`
+	Mismatched int64  `json:"mismatched"`
+	Missing    int64  `json:"missing"`
+	Extra      int64  `json:"extra"`
+	Truncated  bool   `json:"truncated,omitempty"` // more than maxVerificationFilesStored files differ
+}
+
+// JobVerification is a verification report of a job
+type JobVerification struct {
+	ID              int                       `json:"id"`
+	JobID           int                       `json:"job_id"`
+	Trigger         string                    `json:"trigger"`
+	Status          string                    `json:"status"`
+	Consistent      *bool                     `json:"consistent"`
+	SourceAgentID   string                    `json:"source_agent_id"`
+	SourceFiles     int64                     `json:"source_files"`
+	SourceBytes     int64                     `json:"source_bytes"`
+	Destinations    []VerificationDestination `json:"destinations"`
+	MismatchedFiles int64                     `json:"mismatched_files"`
+	MissingFiles    int64                     `json:"missing_files"`
+	ExtraFiles      int64                     `json:"extra_files"`
+	Error           *string                   `json:"error,omitempty"`
+	RequestedBy     *string                   `json:"requested_by,omitempty"`
+	StartedAt       time.Time                 `json:"started_at"`
+	CompletedAt     *time.Time                `json:"completed_at,omitempty"`
+}
+
+// VerificationFile is a file that differs between the source and a destination
+type VerificationFile struct {
+	DestinationAgentID string     `json:"destination_agent_id"`
+	Path               string     `json:"path"`
+	Problem            string     `json:"problem"`
+	SourceSize         *int64     `json:"source_size,omitempty"`
+	SourceModTime      *time.Time `json:"source_mtime,omitempty"`
+	SourceSHA256       *string    `json:"source_sha256,omitempty"`
+	DestSize           *int64     `json:"dest_size,omitempty"`
+	DestModTime        *time.Time `json:"dest_mtime,omitempty"`
+	DestSHA256         *string    `json:"dest_sha256,omitempty"`
+}
+
+// compareManifests returns the files of a destination that differ from the source, sorted by path.
+// Modification times are reported but not compared, a touched file with the same content matches.
+func compareManifests(destinationAgentID string, source, destination []ManifestEntry) []VerificationFile {
+	destinationByPath := make(map[string]*ManifestEntry, len(destination))
+	for i := range destination {
+		destinationByPath[destination[i].Path] = &destination[i]
+	}
+
+	files := []VerificationFile{}
+	for i := range source {
+		src := &source[i]
+		dst, found := destinationByPath[src.Path]
+		if !found {
+			files = append(files, VerificationFile{
+				DestinationAgentID: destinationAgentID,
+				Path:               src.Path,
+				Problem:            verificationProblemMissing,
+				SourceSize:         &src.Size,
+				SourceModTime:      &src.ModTime,
+				SourceSHA256:       &src.SHA256,
+			})
+			continue
+		}
+		delete(destinationByPath, src.Path)
+
+		if src.Size != dst.Size || src.SHA256 != dst.SHA256 {
+			files = append(files, VerificationFile{
+				DestinationAgentID: destinationAgentID,
+				Path:               src.Path,
+				Problem:            verificationProblemMismatched,
+				SourceSize:         &src.Size,
+				SourceModTime:      &src.ModTime,
+				SourceSHA256:       &src.SHA256,
+				DestSize:           &dst.Size,
+				DestModTime:        &dst.ModTime,
+				DestSHA256:         &dst.SHA256,
+			})
+		}
+	}
+
+	for _, dst := range destinationByPath {
+		files = append(files, VerificationFile{
+			DestinationAgentID: destinationAgentID,
+			Path:               dst.Path,
+			Problem:            verificationProblemExtra,
+			DestSize:           &dst.Size,
+			DestModTime:        &dst.ModTime,
+			DestSHA256:         &dst.SHA256,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// verifyScheduleFromJobData reads "verify_schedule" (cron expression or descriptor in the job's time zone)
+// from a create/update request body. present is false if the field was not sent; null or "" removes it.
+func verifyScheduleFromJobData(jobData map[string]interface{}, timezone string) (schedule *JobSchedule, present bool, err error) {
+	raw, present := jobData["verify_schedule"]
+	if !present || raw == nil {
+		return nil, present, nil
+	}
+	expression, ok := raw.(string)
+	if !ok {
+		return nil, true, fmt.Errorf("verify_schedule must be a cron expression or null")
+	}
+	if strings.TrimSpace(expression) == "" {
+		return nil, true, nil
+	}
+	schedule, err = ParseJobSchedule(ScheduleCron, expression, timezone)
+	return schedule, true, err
+}
+
+// verifyScheduleColumns returns the verify_schedule and next_verification_at values of a schedule
+func verifyScheduleColumns(schedule *JobSchedule) (interface{}, *time.Time) {
+	if schedule == nil {
+		return nil, nil
+	}
+	next := schedule.Next(nil)
+	return schedule.CronExpression, &next
+}
+
+// startJobVerification records a running verification and requests the manifests in the background
+func (s *SyncToolServer) startJobVerification(jobID, trigger, requestedBy string) (int, error) {
+	agentIDs, err := s.jobAgentIDs(jobID)
+	if err != nil {
+		return 0, err
+	}
+	if len(agentIDs) < 2 {
+		return 0, fmt.Errorf("job %s has no destination to verify", jobID)
+	}
+
+	var verificationID int
+	err = s.db.QueryRow(`
+		INSERT INTO job_verifications (job_id, trigger, status, source_agent_id, requested_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (job_id) WHERE status = 'running' DO NOTHING
+		RETURNING id
+	`, jobID, trigger, verificationRunning, agentIDs[0], nullIfEmpty(requestedBy)).Scan(&verificationID)
+	if err == sql.ErrNoRows {
+		return 0, errVerificationRunning
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to record verification: %v", err)
+	}
+
+	log.Printf("🔐 Verification %d of job %s started (%s, %d destination(s))", verificationID, jobID, trigger, len(agentIDs)-1)
+	go s.runJobVerification(verificationID, jobID, trigger, agentIDs[0], agentIDs[1:])
+	return verificationID, nil
+}
+
+// requestManifest asks an agent to hash its job folder
+func (s *SyncToolServer) requestManifest(agentID, jobID string) ([]ManifestEntry, error) {
+	timeout := s.config.Verification.Timeout
+	var response protocol.ManifestResponse
+	if err := s.requestMessageFromAgent(agentID, &protocol.BuildManifest{
+		Type:           protocol.TypeBuildManifest,
+		JobID:          jobID,
+		TimeoutSeconds: int(timeout.Seconds()),
+	}, &response, timeout); err != nil {
+		return nil, err
+	}
+
+	if response.Data == nil {
+		return []ManifestEntry{}, nil
+	}
+	return response.Data, nil
+}
+
+// runJobVerification builds the manifests of all agents of a job in parallel and stores the comparison
+func (s *SyncToolServer) runJobVerification(verificationID int, jobID, trigger, sourceAgentID string, destinationAgentIDs []string) {
+	agentIDs := append([]string{sourceAgentID}, destinationAgentIDs...)
+	manifests := make([][]ManifestEntry, len(agentIDs))
+	manifestErrors := make([]error, len(agentIDs))
+
+	var wg sync.WaitGroup
+	for i, agentID := range agentIDs {
+		wg.Add(1)
+		go func(i int, agentID string) {
+			defer wg.Done()
+			manifests[i], manifestErrors[i] = s.requestManifest(agentID, jobID)
+		}(i, agentID)
+	}
+	wg.Wait()
+
+	report := &JobVerification{
+		ID:            verificationID,
+		Trigger:       trigger,
+		SourceAgentID: sourceAgentID,
+		Destinations:  []VerificationDestination{},
+	}
+	var files []VerificationFile
+
+	if err := manifestErrors[0]; err != nil {
+		log.Printf("❌ Verification %d of job %s: source agent %s could not build its manifest: %v", verificationID, jobID, sourceAgentID, err)
+		errMsg := fmt.Sprintf("Source agent %s: %v", sourceAgentID, err)
+		report.Status = verificationFailed
+		report.Error = &errMsg
+	} else {
+		report.Status = verificationCompleted
+		source := manifests[0]
+		report.SourceFiles = int64(len(source))
+		for _, entry := range source {
+			report.SourceBytes += entry.Size
+		}
+
+		allCompared := true
+		for i, agentID := range destinationAgentIDs {
+			destination := VerificationDestination{AgentID: agentID, Status: verificationCompleted}
+			if err := manifestErrors[i+1]; err != nil {
+				log.Printf("⚠️ Verification %d of job %s: destination %s could not build its manifest: %v", verificationID, jobID, agentID, err)
+				destination.Status = verificationFailed
+				destination.Error = err.Error()
+				allCompared = false
+				report.Destinations = append(report.Destinations, destination)
+				continue
+			}
+
+			destination.Files = int64(len(manifests[i+1]))
+			for _, entry := range manifests[i+1] {
+				destination.Bytes += entry.Size
+			}
+
+			differences := compareManifests(agentID, manifests[0], manifests[i+1])
+			for _, file := range differences {
+				switch file.Problem {
+				case verificationProblemMismatched:
+					destination.Mismatched++
+				case verificationProblemMissing:
+					destination.Missing++
+				case verificationProblemExtra:
+					destination.Extra++
+				}
+			}
+			if len(differences) > maxVerificationFilesStored {
+				differences = differences[:maxVerificationFilesStored]
+				destination.Truncated = true
+			}
+			files = append(files, differences...)
+
+			report.MismatchedFiles += destination.Mismatched
+			report.MissingFiles += destination.Missing
+			report.ExtraFiles += destination.Extra
+			report.Destinations = append(report.Destinations, destination)
+		}
+
+		// Unknown while a destination could not be compared, unless another one already differs
+		problems := report.MismatchedFiles + report.MissingFiles + report.ExtraFiles
+		if problems > 0 || allCompared {
+			consistent := problems == 0
+			report.Consistent = &consistent
+		}
+	}
+
+	if err := s.saveJobVerification(report, files); err != nil {
+		log.Printf("❌ Failed to save verification %d of job %s: %v", verificationID, jobID, err)
+		s.db.Exec(`
+			UPDATE job_verifications SET status = $2, error = $3, completed_at = $4 WHERE id = $1
+		`, verificationID, verificationFailed, fmt.Sprintf("Failed to save report: %v", err), time.Now())
+		return
+	}
+
+	log.Printf("🔐 Verification %d of job %s %s: %d mismatched, %d missing, %d extra file(s)",
+		verificationID, jobID, report.Status, report.MismatchedFiles, report.MissingFiles, report.ExtraFiles)
+
+	var jobName string
+	s.db.QueryRow(`SELECT name FROM sync_jobs WHERE id = $1`, jobID).Scan(&jobName)
+
+	go s.emitWebhookEvent(WebhookEventVerificationCompleted, map[string]interface{}{
+		"job_id":           jobID,
+		"job_name":         jobName,
+		"verification_id":  verificationID,
+		"trigger":          trigger,
+		"status":           report.Status,
+		"consistent":       report.Consistent,
+		"error":            report.Error,
+		"source_files":     report.SourceFiles,
+		"mismatched_files": report.MismatchedFiles,
+		"missing_files":    report.MissingFiles,
+		"extra_files":      report.ExtraFiles,
+		"destinations":     report.Destinations,
+	})
+}
+
+// saveJobVerification stores the result and the differing files of a verification
+func (s *SyncToolServer) saveJobVerification(report *JobVerification, files []VerificationFile) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(files) > 0 {
+		stmt, err := tx.Prepare(pq.CopyIn("job_verification_files",
+			"verification_id", "destination_agent_id", "path", "problem",
+			"source_size", "source_mtime", "source_sha256", "dest_size", "dest_mtime", "dest_sha256"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if _, err := stmt.Exec(report.ID, file.DestinationAgentID, file.Path, file.Problem,
+				file.SourceSize, file.SourceModTime, file.SourceSHA256, file.DestSize, file.DestModTime, file.DestSHA256); err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return err
+		}
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+	}
+
+	destinations, _ := json.Marshal(report.Destinations)
+	if _, err := tx.Exec(`
+		UPDATE job_verifications
+		SET status = $2, consistent = $3, source_files = $4, source_bytes = $5, destinations = $6,
+		    mismatched_files = $7, missing_files = $8, extra_files = $9, error = $10, completed_at = $11
+		WHERE id = $1
+	`, report.ID, report.Status, report.Consistent, report.SourceFiles, report.SourceBytes, string(destinations),
+		report.MismatchedFiles, report.MissingFiles, report.ExtraFiles, report.Error, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// failInterruptedVerifications fails verifications left running by a previous server process
+func (s *SyncToolServer) failInterruptedVerifications() {
+	result, err := s.db.Exec(`
+		UPDATE job_verifications SET status = $1, error = 'Interrupted by a server restart', completed_at = $2
+		WHERE status = 'running'
+	`, verificationFailed, time.Now())
+	if err != nil {
+		log.Printf("⚠️ Failed to clean up interrupted verifications: %v", err)
+		return
+	}
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		log.Printf("🧹 Marked %d verification(s) interrupted by the restart as failed", updated)
+	}
+}
+
+// runDueVerifications starts the scheduled verifications that are due, called on every scheduler tick
+func (s *SyncToolServer) runDueVerifications() error {
+	rows, err := s.db.Query(`
+		SELECT id, verify_schedule, COALESCE(timezone, 'UTC'), next_verification_at
+		FROM sync_jobs
+		WHERE verify_schedule IS NOT NULL
+		AND (next_verification_at IS NULL OR next_verification_at <= $1)
+	`, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to query scheduled verifications: %w", err)
+	}
+
+	type dueJob struct {
+		jobID, expression, timezone string
+		due                         bool
+	}
+	var jobs []dueJob
+	for rows.Next() {
+		var job dueJob
+		var nextVerification *time.Time
+		if err := rows.Scan(&job.jobID, &job.expression, &job.timezone, &nextVerification); err != nil {
+			log.Printf("❌ Failed to scan scheduled verification: %v", err)
+			continue
+		}
+		job.due = nextVerification != nil
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	for _, job := range jobs {
+		schedule, err := ParseJobSchedule(ScheduleCron, job.expression, job.timezone)
+		if err != nil {
+			log.Printf("❌ Invalid verify_schedule for job %s: %v", job.jobID, err)
+			continue
+		}
+		if _, err := s.db.Exec(`UPDATE sync_jobs SET next_verification_at = $1 WHERE id = $2`, schedule.Next(nil), job.jobID); err != nil {
+			log.Printf("❌ Failed to update next verification of job %s: %v", job.jobID, err)
+			continue
+		}
+		// Jobs without a next verification time are only initialized
+		if !job.due {
+			continue
+		}
+		if _, err := s.startJobVerification(job.jobID, verificationTriggerScheduled, ""); err != nil {
+			log.Printf("⚠️ Scheduled verification of job %s not started: %v", job.jobID, err)
+		}
+	}
+	return nil
+}
+
+// runVerificationCleanup removes verification reports older than the retention period every hour
+func (s *SyncToolServer) runVerificationCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		s.cleanupJobVerifications()
+
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanupJobVerifications removes finished reports older than the retention period with their files
+func (s *SyncToolServer) cleanupJobVerifications() {
+	result, err := s.db.Exec(`DELETE FROM job_verifications WHERE status != 'running' AND started_at < $1`,
+		time.Now().Add(-s.config.Verification.Retention))
+	if err != nil {
+		log.Printf("❌ Failed to clean up verification reports: %v", err)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		log.Printf("🧹 Removed %d verification reports older than %v", deleted, s.config.Verification.Retention)
+	}
+}
+
+// loadJobVerifications returns the verification reports of a job, newest first
+func (s *SyncToolServer) loadJobVerifications(jobID string, verificationID string, limit int) ([]*JobVerification, error) {
+	query := `
+		SELECT id, job_id, trigger, status, consistent, source_agent_id, source_files, source_bytes, destinations,
+		       mismatched_files, missing_files, extra_files, error, requested_by, started_at, completed_at
+		FROM job_verifications
+		WHERE job_id = $1`
+	args := []interface{}{jobID, limit}
+	if verificationID != "" {
+		query += ` AND id = $3`
+		args = append(args, verificationID)
+	}
+	query += ` ORDER BY started_at DESC LIMIT $2`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verifications := []*JobVerification{}
+	for rows.Next() {
+		var v JobVerification
+		var destinations []byte
+		if err := rows.Scan(&v.ID, &v.JobID, &v.Trigger, &v.Status, &v.Consistent, &v.SourceAgentID, &v.SourceFiles, &v.SourceBytes,
+			&destinations, &v.MismatchedFiles, &v.MissingFiles, &v.ExtraFiles, &v.Error, &v.RequestedBy, &v.StartedAt, &v.CompletedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(destinations, &v.Destinations); err != nil || v.Destinations == nil {
+			v.Destinations = []VerificationDestination{}
+		}
+		verifications = append(verifications, &v)
+	}
+	return verifications, rows.Err()
+}
+
+// lastJobVerification returns the newest verification of a job, nil if it was never verified
+func (s *SyncToolServer) lastJobVerification(jobID string) *JobVerification {
+	if s.db == nil {
+		return nil
+	}
+	verifications, err := s.loadJobVerifications(jobID, "", 1)
+	if err != nil {
+		log.Printf("⚠️ Failed to load last verification of job %s: %v", jobID, err)
+		return nil
+	}
+	if len(verifications) == 0 {
+		return nil
+	}
+	return verifications[0]
+}
+
+// loadVerificationFiles returns a page of the differing files of a verification and the number of matching files
+func (s *SyncToolServer) loadVerificationFiles(verificationID int, agentID, problem string, limit, offset int) ([]VerificationFile, int, error) {
+	where := `WHERE verification_id = $1`
+	args := []interface{}{verificationID}
+	if agentID != "" {
+		args = append(args, agentID)
+		where += fmt.Sprintf(` AND destination_agent_id = $%d`, len(args))
+	}
+	if problem != "" {
+		args = append(args, problem)
+		where += fmt.Sprintf(` AND problem = $%d`, len(args))
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM job_verification_files `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT destination_agent_id, path, problem, source_size, source_mtime, source_sha256, dest_size, dest_mtime, dest_sha256
+		FROM job_verification_files
+		%s
+		ORDER BY destination_agent_id, path
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := []VerificationFile{}
+	for rows.Next() {
+		var f VerificationFile
+		if err := rows.Scan(&f.DestinationAgentID, &f.Path, &f.Problem, &f.SourceSize, &f.SourceModTime, &f.SourceSHA256,
+			&f.DestSize, &f.DestModTime, &f.DestSHA256); err != nil {
+			return nil, 0, err
+		}
+		files = append(files, f)
+	}
+	return files, total, rows.Err()
+}
+
+// handleVerifySyncJob starts an on-demand verification: POST /api/v1/sync-jobs/{id}/verify
+func (s *SyncToolServer) handleVerifySyncJob(w http.ResponseWriter, r *http.Request, jobID string) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sync_jobs WHERE id = $1)`, jobID).Scan(&exists); err != nil || !exists {
+		http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+		return
+	}
+
+	verificationID, err := s.startJobVerification(jobID, verificationTriggerManual, requestUsername(r))
+	if err == errVerificationRunning {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to start verification of job %s: %v", jobID, err)
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to start verification: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"message":         "Verification started, agents are hashing the job folders",
+		"verification_id": verificationID,
+	})
+}
+
+// handleJobVerifications handles the verification reports of a job:
+//   GET /api/v1/sync-jobs/{id}/verifications?limit=...
+//   GET /api/v1/sync-jobs/{id}/verifications/{verification_id}?agent_id=...&problem=...&limit=...&offset=...
+func (s *SyncToolServer) handleJobVerifications(w http.ResponseWriter, r *http.Request, jobID string, subPath []string) {
+	if r.Method != "GET" {
+		http.Error(w, `{"error": "Only GET method allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+
+	switch len(subPath) {
+	case 0:
+		limit := verificationHistoryLimit
+		if value, err := strconv.Atoi(query.Get("limit")); err == nil && value > 0 && value <= 500 {
+			limit = value
+		}
+		verifications, err := s.loadJobVerifications(jobID, "", limit)
+		if err != nil {
+			log.Printf("❌ Failed to query verifications of job %s: %v", jobID, err)
+			http.Error(w, `{"error": "Failed to fetch verifications"}`, http.StatusInternalServerError)
+			return
+		}
+
+		var verifySchedule *string
+		var nextVerification *time.Time
+		if err := s.db.QueryRow(`SELECT verify_schedule, next_verification_at FROM sync_jobs WHERE id = $1`, jobID).Scan(&verifySchedule, &nextVerification); err == sql.ErrNoRows {
+			http.Error(w, `{"error": "Sync job not found"}`, http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":               jobID,
+			"verify_schedule":      verifySchedule,
+			"next_verification_at": nextVerification,
+			"data":                 verifications,
+			"total":                len(verifications),
+		})
+
+	case 1:
+		if _, err := strconv.Atoi(subPath[0]); err != nil {
+			http.Error(w, `{"error": "Invalid verification ID"}`, http.StatusBadRequest)
+			return
+		}
+		verifications, err := s.loadJobVerifications(jobID, subPath[0], 1)
+		if err != nil {
+			log.Printf("❌ Failed to query verification %s of job %s: %v", subPath[0], jobID, err)
+			http.Error(w, `{"error": "Failed to fetch verification"}`, http.StatusInternalServerError)
+			return
+		}
+		if len(verifications) == 0 {
+			http.Error(w, `{"error": "Verification not found"}`, http.StatusNotFound)
+			return
+		}
+
+		problem := query.Get("problem")
+		switch problem {
+		case "", verificationProblemMismatched, verificationProblemMissing, verificationProblemExtra:
+		default:
+			http.Error(w, `{"error": "problem must be mismatched, missing or extra"}`, http.StatusBadRequest)
+			return
+		}
+		limit := 100
+		if value, err := strconv.Atoi(query.Get("limit")); err == nil && value > 0 && value <= maxVerificationFilesPage {
+			limit = value
+		}
+		offset := 0
+		if value, err := strconv.Atoi(query.Get("offset")); err == nil && value > 0 {
+			offset = value
+		}
+
+		files, total, err := s.loadVerificationFiles(verifications[0].ID, query.Get("agent_id"), problem, limit, offset)
+		if err != nil {
+			log.Printf("❌ Failed to query files of verification %d: %v", verifications[0].ID, err)
+			http.Error(w, `{"error": "Failed to fetch verification files"}`, http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"verification": verifications[0],
+			"data":         files,
+			"total":        total,
+			"limit":        limit,
+			"offset":       offset,
+		})
+
+	default:
+		http.Error(w, `{"error": "Invalid verifications request. Expected: GET /verifications or GET /verifications/{verification_id}"}`, http.StatusBadRequest)
+	}
+}
+
+// writeVerifyScheduleError writes a 400 response for an invalid verify_schedule
+func writeVerifyScheduleError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid verify_schedule: %v", err),
+	})
+}
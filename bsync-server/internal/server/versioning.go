@@ -0,0 +1,253 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Versioning types stored in sync_jobs.versioning (applied to destination folders)
+const (
+	VersioningNone      = "none"
+	VersioningTrashcan  = "trashcan"
+	VersioningSimple    = "simple"
+	VersioningStaggered = "staggered"
+	VersioningExternal  = "external"
+)
+
+const defaultSimpleVersionsKept = 5
+
+// VersioningPolicy describes how destination agents archive replaced and deleted files
+type VersioningPolicy struct {
+	Type           string `json:"type"`
+	CleanoutDays   int    `json:"cleanout_days,omitempty"`    // trashcan: remove archived files after N days (0 = never)
+	Keep           int    `json:"keep,omitempty"`             // simple: number of versions kept per file
+	MaxAgeDays     int    `json:"max_age_days,omitempty"`     // staggered: maximum age of versions (0 = forever)
+	CleanIntervalS int    `json:"clean_interval_s,omitempty"` // staggered: how often old versions are removed
+	VersionsPath   string `json:"versions_path,omitempty"`    // staggered: archive directory (default .stversions)
+	Command        string `json:"command,omitempty"`          // external: command run instead of deleting a file, only on agents with versioning.allow_external
+}
+
+// validate checks the versioning type and its retention settings
+func (vp *VersioningPolicy) validate() error {
+	if vp.CleanoutDays < 0 || vp.Keep < 0 || vp.MaxAgeDays < 0 || vp.CleanIntervalS < 0 {
+		return fmt.Errorf("retention values must not be negative")
+	}
+
+	switch vp.Type {
+	case VersioningTrashcan, VersioningStaggered:
+	case VersioningSimple:
+		if vp.Keep == 0 {
+			vp.Keep = defaultSimpleVersionsKept
+		}
+	case VersioningExternal:
+		if vp.Command == "" {
+			return fmt.Errorf("command is required for versioning type \"external\"")
+		}
+	default:
+		return fmt.Errorf("unknown versioning type %q (expected none, trashcan, simple, staggered or external)", vp.Type)
+	}
+
+	return nil
+}
+
+// parseVersioningPolicy converts a request body field into a validated policy (null or "none" = no versioning)
+func parseVersioningPolicy(raw interface{}) (*VersioningPolicy, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var policy VersioningPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	if policy.Type == "" || policy.Type == VersioningNone {
+		return nil, nil
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// versioningFromJobData reads "versioning" from a create/update request body.
+// present is false if the field was not sent at all.
+func versioningFromJobData(jobData map[string]interface{}) (policy *VersioningPolicy, present bool, err error) {
+	raw, present := jobData["versioning"]
+	if !present {
+		return nil, false, nil
+	}
+	policy, err = parseVersioningPolicy(raw)
+	return policy, true, err
+}
+
+// runsCommand reports whether the policy runs a command on the destination agents
+func (vp *VersioningPolicy) runsCommand() bool {
+	return vp != nil && vp.Type == VersioningExternal
+}
+
+// versioningCommandChanged reports whether a request sets, changes or removes an external
+// versioning command of a job
+func versioningCommandChanged(current, requested *VersioningPolicy) bool {
+	if !current.runsCommand() && !requested.runsCommand() {
+		return false
+	}
+	return versioningPolicyJSON(current) != versioningPolicyJSON(requested)
+}
+
+// versioningPolicyJSON converts a policy into a value for a JSONB column
+func versioningPolicyJSON(policy *VersioningPolicy) interface{} {
+	if policy == nil {
+		return nil
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// scanVersioningPolicy decodes a nullable JSONB column
+func scanVersioningPolicy(raw sql.NullString) *VersioningPolicy {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var policy VersioningPolicy
+	if err := json.Unmarshal([]byte(raw.String), &policy); err != nil {
+		log.Printf("⚠️ Ignoring invalid stored versioning policy: %v", err)
+		return nil
+	}
+	return &policy
+}
+
+// jobVersioningPolicy returns the versioning policy of a job, nil if versioning is off
+func (s *SyncToolServer) jobVersioningPolicy(jobID string) *VersioningPolicy {
+	if s.db == nil {
+		return nil
+	}
+	var raw sql.NullString
+	if err := s.db.QueryRow(`SELECT versioning FROM sync_jobs WHERE id = $1`, jobID).Scan(&raw); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("⚠️ Failed to load versioning policy for job %s: %v", jobID, err)
+		}
+		return nil
+	}
+	return scanVersioningPolicy(raw)
+}
+
+// versionsDestinationAgent resolves the destination agent for a versions request.
+// agent_id may be omitted for jobs with a single destination.
+func (s *SyncToolServer) versionsDestinationAgent(jobID, agentID string) (string, error) {
+	agentIDs, err := s.jobAgentIDs(jobID)
+	if err != nil {
+		return "", err
+	}
+	destinations := agentIDs[1:] // First entry is the source agent
+
+	if agentID == "" {
+		if len(destinations) != 1 {
+			return "", fmt.Errorf("agent_id is required for jobs with %d destinations", len(destinations))
+		}
+		return destinations[0], nil
+	}
+	if !contains(destinations, agentID) {
+		return "", fmt.Errorf("agent %s is not a destination of job %s", agentID, jobID)
+	}
+	return agentID, nil
+}
+
+// handleJobFileVersions handles archived file versions on a job destination:
+//   GET  /api/v1/sync-jobs/{id}/versions?agent_id=...&path=...
+//   POST /api/v1/sync-jobs/{id}/versions/restore  {"agent_id": "...", "versions": {"path": "version_time"}}
+func (s *SyncToolServer) handleJobFileVersions(w http.ResponseWriter, r *http.Request, jobID string, subPath []string) {
+	switch {
+	case len(subPath) == 0 && r.Method == "GET":
+		agentID, err := s.versionsDestinationAgent(jobID, r.URL.Query().Get("agent_id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+			return
+		}
+
+		response, err := s.requestFromAgent(agentID, map[string]interface{}{
+			"type":   "list_file_versions",
+			"job_id": jobID,
+			"path":   r.URL.Query().Get("path"),
+		}, agentRequestTimeout)
+		if err != nil {
+			log.Printf("❌ Failed to list file versions of job %s on agent %s: %v", jobID, agentID, err)
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to list file versions: %v"}`, err), http.StatusBadGateway)
+			return
+		}
+
+		files, _ := response["data"].([]interface{})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":   jobID,
+			"agent_id": agentID,
+			"data":     files,
+			"total":    len(files),
+		})
+
+	case len(subPath) == 1 && subPath[0] == "restore" && r.Method == "POST":
+		var req struct {
+			AgentID  string            `json:"agent_id"`
+			Versions map[string]string `json:"versions"` // file path -> version_time (RFC3339)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+			return
+		}
+		if len(req.Versions) == 0 {
+			http.Error(w, `{"error": "versions must map at least one file path to a version_time"}`, http.StatusBadRequest)
+			return
+		}
+		for path, versionTime := range req.Versions {
+			if _, err := time.Parse(time.RFC3339, versionTime); err != nil {
+				http.Error(w, fmt.Sprintf(`{"error": "Invalid version_time for %s: must be RFC3339"}`, path), http.StatusBadRequest)
+				return
+			}
+		}
+
+		agentID, err := s.versionsDestinationAgent(jobID, req.AgentID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+			return
+		}
+
+		response, err := s.requestFromAgent(agentID, map[string]interface{}{
+			"type":     "restore_file_versions",
+			"job_id":   jobID,
+			"versions": req.Versions,
+		}, agentRequestTimeout)
+		if err != nil {
+			log.Printf("❌ Failed to restore file versions of job %s on agent %s: %v", jobID, agentID, err)
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to restore file versions: %v"}`, err), http.StatusBadGateway)
+			return
+		}
+
+		restored, _ := response["restored"].([]interface{})
+		failed, _ := response["failed"].(map[string]interface{})
+		log.Printf("♻️ Restored %d file version(s) of job %s on agent %s (%d failed)", len(restored), jobID, agentID, len(failed))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  len(failed) == 0,
+			"message":  fmt.Sprintf("Restored %d of %d file version(s)", len(restored), len(req.Versions)),
+			"agent_id": agentID,
+			"restored": restored,
+			"failed":   failed,
+		})
+
+	default:
+		http.Error(w, `{"error": "Invalid versions request. Expected: GET /versions or POST /versions/restore"}`, http.StatusBadRequest)
+	}
+}
+
+// writeVersioningError writes a 400 response for an invalid versioning policy
+func writeVersioningError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid versioning policy: %v", err),
+	})
+}
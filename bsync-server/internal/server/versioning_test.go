@@ -0,0 +1,31 @@
+package server
+
+import "testing"
+
+func TestVersioningCommandChanged(t *testing.T) {
+	external := &VersioningPolicy{Type: VersioningExternal, Command: "/usr/local/bin/archive"}
+	trashcan := &VersioningPolicy{Type: VersioningTrashcan, CleanoutDays: 30}
+
+	tests := []struct {
+		name      string
+		current   *VersioningPolicy
+		requested *VersioningPolicy
+		want      bool
+	}{
+		{name: "no versioning", want: false},
+		{name: "trashcan added", requested: trashcan, want: false},
+		{name: "trashcan changed", current: trashcan, requested: &VersioningPolicy{Type: VersioningSimple, Keep: 5}, want: false},
+		{name: "external added", current: trashcan, requested: external, want: true},
+		{name: "same external sent again", current: external, requested: &VersioningPolicy{Type: VersioningExternal, Command: "/usr/local/bin/archive"}, want: false},
+		{name: "external command changed", current: external, requested: &VersioningPolicy{Type: VersioningExternal, Command: "/bin/rm"}, want: true},
+		{name: "external removed", current: external, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versioningCommandChanged(tt.current, tt.requested); got != tt.want {
+				t.Errorf("versioningCommandChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,890 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook event types
+const (
+	WebhookEventJobFailed             = "job_failed"        // an agent rejected a job operation or a scheduled run failed
+	WebhookEventSessionCompleted      = "session_completed" // a sync session finished
+	WebhookEventAgentOnline           = "agent_online"
+	WebhookEventAgentOffline          = "agent_offline"
+	WebhookEventAgentPendingApproval  = "agent_pending_approval"   // new agent or device ID change
+	WebhookEventLicenseExpiring       = "license_expiring"         // a license expires within webhooks.license_expiry_warning
+	WebhookEventDiskSpaceLow          = "disk_space_low"           // a destination paused a job folder below its minimum free space
+	WebhookEventDiskSpaceRecovered    = "disk_space_recovered"     // the destination has enough free space again
+	WebhookEventDeletionGuard         = "deletion_guard_triggered" // a large deletion on the source paused a job until it is confirmed
+	WebhookEventVerificationCompleted = "verification_completed"   // a content verification of a job finished or failed
+	WebhookEventMirrorCompleted       = "mirror_completed"         // a one-shot mirror job completed or failed and was removed from the agents
+	WebhookEventConflictDetected      = "conflict_detected"        // an agent found a conflict copy in a job folder
+	WebhookEventTest                  = "test"                     // only sent by the send test action
+)
+
+// webhookEventAll subscribes a webhook to every event type
+const webhookEventAll = "*"
+
+// webhookEventTypes lists the event types webhooks can subscribe to
+var webhookEventTypes = []string{
+	WebhookEventJobFailed,
+	WebhookEventSessionCompleted,
+	WebhookEventAgentOnline,
+	WebhookEventAgentOffline,
+	WebhookEventAgentPendingApproval,
+	WebhookEventLicenseExpiring,
+	WebhookEventDiskSpaceLow,
+	WebhookEventDiskSpaceRecovered,
+	WebhookEventDeletionGuard,
+	WebhookEventVerificationCompleted,
+	WebhookEventMirrorCompleted,
+	WebhookEventConflictDetected,
+}
+
+// Delivery states stored in webhook_deliveries.status
+const (
+	webhookDeliveryPending   = "pending" // waiting for the first attempt or a retry
+	webhookDeliveryDelivered = "delivered"
+	webhookDeliveryFailed    = "failed" // out of attempts
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret, prefixed with "sha256=".
+const (
+	webhookEventHeader     = "X-BSync-Event"
+	webhookDeliveryHeader  = "X-BSync-Delivery"
+	webhookTimestampHeader = "X-BSync-Timestamp"
+	webhookSignatureHeader = "X-BSync-Signature"
+)
+
+const (
+	maxWebhookAttempts       = 20
+	webhookPollInterval      = 10 * time.Second
+	webhookBatchSize         = 20
+	webhookResponseBodyLimit = 2048
+)
+
+// Webhook is an endpoint notified about the events it subscribes to
+type Webhook struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"` // only returned when the webhook is created
+	Events      []string  `json:"events"`
+	Enabled     bool      `json:"enabled"`
+	MaxAttempts int       `json:"max_attempts"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent (or to be sent) to one webhook
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMs     *int            `json:"duration_ms,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// Endpoint, loaded when the delivery is claimed
+	url     string
+	secret  string
+	enabled bool
+}
+
+// prepare validates the webhook and normalizes its fields
+func (wh *Webhook) prepare(allowHTTP bool) error {
+	wh.Name = strings.TrimSpace(wh.Name)
+	wh.URL = strings.TrimSpace(wh.URL)
+
+	if wh.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	endpoint, err := url.Parse(wh.URL)
+	if err != nil || endpoint.Host == "" {
+		return fmt.Errorf("invalid url %q", wh.URL)
+	}
+	if endpoint.Scheme != "https" && !(endpoint.Scheme == "http" && allowHTTP) {
+		return fmt.Errorf("url must use https")
+	}
+
+	events := []string{}
+	seen := map[string]bool{}
+	for _, event := range wh.Events {
+		event = strings.TrimSpace(event)
+		if seen[event] {
+			continue
+		}
+		if event != webhookEventAll && !contains(webhookEventTypes, event) {
+			return fmt.Errorf("unknown event %q (expected %s or %s)", event, strings.Join(webhookEventTypes, ", "), webhookEventAll)
+		}
+		seen[event] = true
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	wh.Events = events
+
+	if wh.MaxAttempts < 1 || wh.MaxAttempts > maxWebhookAttempts {
+		return fmt.Errorf("max_attempts must be between 1 and %d", maxWebhookAttempts)
+	}
+	return nil
+}
+
+const webhookColumns = `id, name, url, secret, events, enabled, max_attempts,
+	COALESCE(created_by, ''), created_at, updated_at`
+
+// scanWebhook reads a webhooks row selected with webhookColumns
+func scanWebhook(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*Webhook, error) {
+	wh := &Webhook{}
+	var events pq.StringArray
+	if err := scanner.Scan(&wh.ID, &wh.Name, &wh.URL, &wh.Secret, &events, &wh.Enabled, &wh.MaxAttempts,
+		&wh.CreatedBy, &wh.CreatedAt, &wh.UpdatedAt); err != nil {
+		return nil, err
+	}
+	wh.Events = []string(events)
+	return wh, nil
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, max_attempts,
+	next_attempt_at, last_attempt_at, response_status, COALESCE(response_body, ''), COALESCE(error, ''),
+	duration_ms, created_at, delivered_at`
+
+// scanWebhookDelivery reads a webhook_deliveries row selected with webhookDeliveryColumns
+func scanWebhookDelivery(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*WebhookDelivery, error) {
+	d := &WebhookDelivery{}
+	var payload []byte
+	if err := scanner.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.MaxAttempts, &d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.ResponseBody, &d.Error,
+		&d.DurationMs, &d.CreatedAt, &d.DeliveredAt); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+// newWebhookEventID returns a random ID shared by all deliveries of one event
+func newWebhookEventID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// webhookPayload builds the JSON body sent for an event
+func webhookPayload(eventID, eventType string, data map[string]interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"event":      eventType,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"data":       data,
+	})
+}
+
+// signWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns the backoff after the given number of failed attempts
+func (s *SyncToolServer) webhookRetryDelay(attempts int) time.Duration {
+	delay := s.config.Webhooks.RetryBaseDelay
+	for i := 1; i < attempts && delay < s.config.Webhooks.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.config.Webhooks.RetryMaxDelay {
+		delay = s.config.Webhooks.RetryMaxDelay
+	}
+	return delay
+}
+
+// emitWebhookEvent queues an event for every enabled webhook subscribed to it.
+// Callers run it in a goroutine; deliveries happen in runWebhookDispatcher.
+func (s *SyncToolServer) emitWebhookEvent(eventType string, data map[string]interface{}) {
+	if s.db == nil {
+		return
+	}
+
+	eventID, err := newWebhookEventID()
+	if err != nil {
+		log.Printf("❌ Failed to create webhook event ID: %v", err)
+		return
+	}
+	payload, err := webhookPayload(eventID, eventType, data)
+	if err != nil {
+		log.Printf("❌ Failed to serialize %s webhook event: %v", eventType, err)
+		return
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, max_attempts, next_attempt_at)
+		SELECT id, $1, $2, $3, max_attempts, NOW()
+		FROM webhooks
+		WHERE enabled = true AND ($2 = ANY(events) OR $4 = ANY(events))
+	`, eventID, eventType, string(payload), webhookEventAll)
+	if err != nil {
+		log.Printf("❌ Failed to queue %s webhook event: %v", eventType, err)
+		return
+	}
+
+	if queued, _ := result.RowsAffected(); queued > 0 {
+		log.Printf("🪝 Queued %s event %s for %d webhook(s)", eventType, eventID, queued)
+		s.wakeWebhookDispatcher()
+	}
+}
+
+// wakeWebhookDispatcher makes the dispatcher look for due deliveries right away
+func (s *SyncToolServer) wakeWebhookDispatcher() {
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// runWebhookDispatcher delivers due webhook deliveries until the server shuts down.
+// Pending deliveries are kept in webhook_deliveries, so retries survive a restart.
+func (s *SyncToolServer) runWebhookDispatcher() {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	log.Printf("🪝 Webhook dispatcher started")
+
+	var lastHourly time.Time
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+		case <-s.webhookWake:
+		}
+
+		s.dispatchWebhookDeliveries()
+
+		if time.Since(lastHourly) > time.Hour {
+			if s.config.Webhooks.Retention > 0 {
+				s.cleanupWebhookDeliveries()
+			}
+			if s.config.Webhooks.LicenseExpiryWarning > 0 {
+				s.checkExpiringLicenses(time.Now())
+			}
+			lastHourly = time.Now()
+		}
+	}
+}
+
+// dispatchWebhookDeliveries sends due deliveries in batches until none are left
+func (s *SyncToolServer) dispatchWebhookDeliveries() {
+	for {
+		deliveries, err := s.claimWebhookDeliveries()
+		if err != nil {
+			log.Printf("❌ Failed to claim webhook deliveries: %v", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func(d *WebhookDelivery) {
+				defer wg.Done()
+				s.attemptWebhookDelivery(d)
+			}(d)
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// claimWebhookDeliveries leases due deliveries by pushing next_attempt_at past the attempt timeout,
+// so a delivery is not sent twice if another server instance polls at the same time
+func (s *SyncToolServer) claimWebhookDeliveries() ([]*WebhookDelivery, error) {
+	lease := s.config.Webhooks.Timeout + time.Minute
+
+	rows, err := s.db.Query(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + ($2 * INTERVAL '1 second')
+		FROM webhooks w
+		WHERE d.webhook_id = w.id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.max_attempts,
+			w.url, w.secret, w.enabled
+	`, webhookBatchSize, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		d := &WebhookDelivery{Status: webhookDeliveryPending}
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Attempts, &d.MaxAttempts,
+			&d.url, &d.secret, &d.enabled); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// attemptWebhookDelivery sends a delivery once and records the outcome: delivered, retry later
+// with exponential backoff, or failed once max_attempts is reached
+func (s *SyncToolServer) attemptWebhookDelivery(d *WebhookDelivery) {
+	now := time.Now()
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.NextAttemptAt = nil
+	d.ResponseStatus = nil
+	d.ResponseBody = ""
+	d.Error = ""
+
+	if !d.enabled {
+		// Webhook disabled after the event was queued
+		d.Status = webhookDeliveryFailed
+		d.Error = "Webhook is disabled"
+	} else {
+		statusCode, body, err := s.postWebhook(d)
+		duration := int(time.Since(now) / time.Millisecond)
+		d.DurationMs = &duration
+		d.ResponseBody = body
+		if statusCode != 0 {
+			d.ResponseStatus = &statusCode
+		}
+
+		switch {
+		case err == nil:
+			d.Status = webhookDeliveryDelivered
+			d.DeliveredAt = &now
+		case d.Attempts >= d.MaxAttempts:
+			d.Status = webhookDeliveryFailed
+			d.Error = err.Error()
+		default:
+			d.Status = webhookDeliveryPending
+			d.Error = err.Error()
+			next := now.Add(s.webhookRetryDelay(d.Attempts))
+			d.NextAttemptAt = &next
+		}
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = $4, response_status = $5,
+			response_body = $6, error = $7, duration_ms = $8, delivered_at = $9
+		WHERE id = $10
+	`, d.Status, d.Attempts, d.NextAttemptAt, d.LastAttemptAt, d.ResponseStatus,
+		nullIfEmpty(d.ResponseBody), nullIfEmpty(d.Error), d.DurationMs, d.DeliveredAt, d.ID)
+	if err != nil {
+		log.Printf("❌ Failed to record webhook delivery %d: %v", d.ID, err)
+	}
+
+	switch d.Status {
+	case webhookDeliveryDelivered:
+		log.Printf("✅ Webhook delivery %d (%s) delivered to webhook %d", d.ID, d.EventType, d.WebhookID)
+	case webhookDeliveryFailed:
+		log.Printf("❌ Webhook delivery %d (%s) to webhook %d failed after %d attempt(s): %s",
+			d.ID, d.EventType, d.WebhookID, d.Attempts, d.Error)
+	default:
+		log.Printf("⚠️ Webhook delivery %d (%s) to webhook %d failed, retrying at %s: %s",
+			d.ID, d.EventType, d.WebhookID, d.NextAttemptAt.Format(time.RFC3339), d.Error)
+	}
+}
+
+// postWebhook sends the signed payload and returns the response status and (truncated) body.
+// Any response outside 2xx is an error; redirects are not followed.
+func (s *SyncToolServer) postWebhook(d *WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest("POST", d.url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BSync-Webhook/1.0")
+	req.Header.Set(webhookEventHeader, d.EventType)
+	req.Header.Set(webhookDeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(d.secret, timestamp, d.Payload))
+
+	client := &http.Client{
+		Timeout: s.config.Webhooks.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, webhookResponseBodyLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// cleanupWebhookDeliveries removes finished deliveries older than the retention period
+func (s *SyncToolServer) cleanupWebhookDeliveries() {
+	result, err := s.db.Exec(`
+		DELETE FROM webhook_deliveries
+		WHERE status != 'pending' AND created_at < $1
+	`, time.Now().Add(-s.config.Webhooks.Retention))
+	if err != nil {
+		log.Printf("❌ Failed to clean up webhook deliveries: %v", err)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		log.Printf("🧹 Removed %d webhook deliveries older than %v", deleted, s.config.Webhooks.Retention)
+	}
+}
+
+// handleWebhooks handles webhook list and create
+// GET /api/v1/webhooks - List webhooks
+// POST /api/v1/webhooks - Create webhook {"name", "url", "events", "enabled", "max_attempts", "secret" (generated if empty)}
+func (s *SyncToolServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.listWebhooks(w, r)
+	case "POST":
+		s.createWebhook(w, r)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWebhookActions handles actions on a specific webhook
+// GET /api/v1/webhooks/events - List event types
+// GET /api/v1/webhooks/{id} - Get webhook
+// PUT /api/v1/webhooks/{id} - Update webhook
+// DELETE /api/v1/webhooks/{id} - Delete webhook and its delivery log
+// POST /api/v1/webhooks/{id}/test - Send a test event and return the delivery
+// GET /api/v1/webhooks/{id}/deliveries - Delivery log (?status=, ?event=, ?limit=, ?offset=)
+func (s *SyncToolServer) handleWebhookActions(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, `{"error": "Database not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Parse URL path: /api/v1/webhooks/{id}[/action]
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/webhooks/"), "/")
+	if len(pathParts) < 1 || pathParts[0] == "" {
+		http.Error(w, `{"error": "Invalid URL format. Expected: /api/v1/webhooks/{id}"}`, http.StatusBadRequest)
+		return
+	}
+
+	if pathParts[0] == "events" {
+		if r.Method != "GET" {
+			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  webhookEventTypes,
+			"total": len(webhookEventTypes),
+		})
+		return
+	}
+
+	webhookID, err := strconv.Atoi(pathParts[0])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid webhook ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if len(pathParts) > 1 {
+		switch {
+		case pathParts[1] == "test" && r.Method == "POST":
+			s.testWebhook(w, r, webhookID)
+		case pathParts[1] == "deliveries" && r.Method == "GET":
+			s.listWebhookDeliveries(w, r, webhookID)
+		case pathParts[1] == "test" || pathParts[1] == "deliveries":
+			http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+		default:
+			http.Error(w, `{"error": "Unknown webhook action"}`, http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.getWebhook(w, r, webhookID)
+	case "PUT":
+		s.updateWebhook(w, r, webhookID)
+	case "DELETE":
+		s.deleteWebhook(w, r, webhookID)
+	default:
+		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// loadWebhook loads a webhook including its secret
+func (s *SyncToolServer) loadWebhook(webhookID int) (*Webhook, error) {
+	return scanWebhook(s.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, webhookID))
+}
+
+// listWebhooks retrieves all webhooks without their secrets
+func (s *SyncToolServer) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id ASC`)
+	if err != nil {
+		log.Printf("❌ Failed to query webhooks: %v", err)
+		http.Error(w, `{"error": "Failed to fetch webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan webhook: %v", err)
+			continue
+		}
+		wh.Secret = ""
+		webhooks = append(webhooks, wh)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  webhooks,
+		"total": len(webhooks),
+	})
+}
+
+// createWebhook creates a webhook; the secret is only returned in this response
+func (s *SyncToolServer) createWebhook(w http.ResponseWriter, r *http.Request) {
+	wh := &Webhook{Enabled: true, MaxAttempts: s.config.Webhooks.MaxAttempts}
+	if err := json.NewDecoder(r.Body).Decode(wh); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := wh.prepare(s.config.Webhooks.AllowHTTP); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	if wh.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			log.Printf("❌ Failed to generate webhook secret: %v", err)
+			http.Error(w, `{"error": "Failed to create webhook"}`, http.StatusInternalServerError)
+			return
+		}
+		wh.Secret = secret
+	}
+	wh.CreatedBy = requestUsername(r)
+
+	err := s.db.QueryRow(`
+		INSERT INTO webhooks (name, url, secret, events, enabled, max_attempts, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, wh.Name, wh.URL, wh.Secret, pq.Array(wh.Events), wh.Enabled, wh.MaxAttempts,
+		nullIfEmpty(wh.CreatedBy)).Scan(&wh.ID, &wh.CreatedAt, &wh.UpdatedAt)
+	if err != nil {
+		log.Printf("❌ Failed to create webhook: %v", err)
+		http.Error(w, `{"error": "Failed to create webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Webhook created successfully. Store the secret now, it is not shown again.",
+		"data":    wh,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Webhook created: ID=%d, Name=%s, Events=%v", wh.ID, wh.Name, wh.Events)
+}
+
+// getWebhook retrieves a specific webhook without its secret
+func (s *SyncToolServer) getWebhook(w http.ResponseWriter, r *http.Request, webhookID int) {
+	wh, err := s.loadWebhook(webhookID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Webhook not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get webhook: %v", err)
+		http.Error(w, `{"error": "Failed to get webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	wh.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wh)
+}
+
+// updateWebhook updates a webhook; fields missing from the body keep their current value
+func (s *SyncToolServer) updateWebhook(w http.ResponseWriter, r *http.Request, webhookID int) {
+	wh, err := s.loadWebhook(webhookID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Webhook not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get webhook: %v", err)
+		http.Error(w, `{"error": "Failed to update webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(wh); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	wh.ID = webhookID
+
+	if err := wh.prepare(s.config.Webhooks.AllowHTTP); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	if wh.Secret == "" {
+		writeWebhookError(w, fmt.Errorf("secret must not be empty"))
+		return
+	}
+
+	err = s.db.QueryRow(`
+		UPDATE webhooks
+		SET name = $1, url = $2, secret = $3, events = $4, enabled = $5, max_attempts = $6, updated_at = NOW()
+		WHERE id = $7
+		RETURNING updated_at
+	`, wh.Name, wh.URL, wh.Secret, pq.Array(wh.Events), wh.Enabled, wh.MaxAttempts, webhookID).Scan(&wh.UpdatedAt)
+	if err != nil {
+		log.Printf("❌ Failed to update webhook: %v", err)
+		http.Error(w, `{"error": "Failed to update webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	wh.Secret = ""
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Webhook updated successfully",
+		"data":    wh,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Webhook updated: ID=%d, Name=%s", webhookID, wh.Name)
+}
+
+// deleteWebhook deletes a webhook with its delivery log
+func (s *SyncToolServer) deleteWebhook(w http.ResponseWriter, r *http.Request, webhookID int) {
+	result, err := s.db.Exec(`DELETE FROM webhooks WHERE id = $1`, webhookID)
+	if err != nil {
+		log.Printf("❌ Failed to delete webhook: %v", err)
+		http.Error(w, `{"error": "Failed to delete webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, `{"error": "Webhook not found"}`, http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Webhook deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	log.Printf("✅ Webhook deleted: ID=%d", webhookID)
+}
+
+// testWebhook sends a test event right away, even to a disabled webhook. The delivery is made once,
+// without retries, and recorded in the delivery log.
+func (s *SyncToolServer) testWebhook(w http.ResponseWriter, r *http.Request, webhookID int) {
+	wh, err := s.loadWebhook(webhookID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Webhook not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to get webhook: %v", err)
+		http.Error(w, `{"error": "Failed to test webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	eventID, err := newWebhookEventID()
+	if err != nil {
+		log.Printf("❌ Failed to create webhook event ID: %v", err)
+		http.Error(w, `{"error": "Failed to test webhook"}`, http.StatusInternalServerError)
+		return
+	}
+	payload, err := webhookPayload(eventID, WebhookEventTest, map[string]interface{}{
+		"webhook_id":   wh.ID,
+		"webhook_name": wh.Name,
+		"message":      "Test delivery from BSync Server",
+		"requested_by": requestUsername(r),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to serialize test webhook event: %v", err)
+		http.Error(w, `{"error": "Failed to test webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// next_attempt_at stays NULL so the dispatcher never picks the test delivery up
+	d := &WebhookDelivery{
+		WebhookID:   wh.ID,
+		EventID:     eventID,
+		EventType:   WebhookEventTest,
+		Payload:     json.RawMessage(payload),
+		MaxAttempts: 1,
+		url:         wh.URL,
+		secret:      wh.Secret,
+		enabled:     true,
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, max_attempts, next_attempt_at)
+		VALUES ($1, $2, $3, $4, 1, NULL)
+		RETURNING id, created_at
+	`, d.WebhookID, d.EventID, d.EventType, string(payload)).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		log.Printf("❌ Failed to record test webhook delivery: %v", err)
+		http.Error(w, `{"error": "Failed to test webhook"}`, http.StatusInternalServerError)
+		return
+	}
+
+	s.attemptWebhookDelivery(d)
+
+	message := "Test event delivered successfully"
+	if d.Status != webhookDeliveryDelivered {
+		message = fmt.Sprintf("Test event delivery failed: %s", d.Error)
+	}
+
+	response := map[string]interface{}{
+		"success": d.Status == webhookDeliveryDelivered,
+		"message": message,
+		"data":    d,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// listWebhookDeliveries retrieves the delivery log of a webhook, newest first
+func (s *SyncToolServer) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, webhookID int) {
+	query := r.URL.Query()
+
+	limit := 50
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+	offset := 0
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	where := []string{"webhook_id = $1"}
+	args := []interface{}{webhookID}
+	if status := query.Get("status"); status != "" {
+		args = append(args, status)
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if event := query.Get("event"); event != "" {
+		args = append(args, event)
+		where = append(where, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE `+whereClause, args...).Scan(&total); err != nil {
+		log.Printf("❌ Failed to count webhook deliveries: %v", err)
+		http.Error(w, `{"error": "Failed to fetch webhook deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT %s FROM webhook_deliveries
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, webhookDeliveryColumns, whereClause, len(args)-1, len(args)), args...)
+	if err != nil {
+		log.Printf("❌ Failed to query webhook deliveries: %v", err)
+		http.Error(w, `{"error": "Failed to fetch webhook deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			log.Printf("❌ Failed to scan webhook delivery: %v", err)
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":   deliveries,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// writeWebhookError writes a 400 response for an invalid webhook
+func writeWebhookError(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Invalid webhook: %v", err),
+	})
+}
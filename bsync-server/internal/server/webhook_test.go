@@ -0,0 +1,72 @@
+package server
+
+import "testing"
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"event":"test"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		want      string
+	}{
+		{name: "payload", secret: "secret", timestamp: "1700000000", body: body, want: "e6a22eb66e93669c75e7a035a110d9a2ccfa7cdef62d0ecb361671b92718ee9f"},
+		{name: "other secret", secret: "other", timestamp: "1700000000", body: body, want: "47ccf0a100eb437d39b47abc0956d2b477ea6b476e1e6e473d85f83a4f3e8dc3"},
+		{name: "other timestamp", secret: "secret", timestamp: "1700000001", body: body, want: "9b811456c10f55f63708004dbc3656675e7b71303e75f62946f3b2c8280bd981"},
+		{name: "empty secret and body", timestamp: "1700000000", want: "c1da1b6c6b8e9da7f4bbb90f7cab0820f271ad19ccbf80c88479c4e14f37d1c6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signWebhookPayload(tt.secret, tt.timestamp, tt.body); got != tt.want {
+				t.Errorf("signWebhookPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookPrepare(t *testing.T) {
+	tests := []struct {
+		name       string
+		webhook    Webhook
+		allowHTTP  bool
+		wantEvents []string
+		wantErr    bool
+	}{
+		{name: "single event", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", Events: []string{WebhookEventJobFailed}, MaxAttempts: 5}, wantEvents: []string{WebhookEventJobFailed}},
+		{name: "license expiry", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", Events: []string{"license_expiring"}, MaxAttempts: 5}, wantEvents: []string{WebhookEventLicenseExpiring}},
+		{name: "all events", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", Events: []string{webhookEventAll}, MaxAttempts: 5}, wantEvents: []string{webhookEventAll}},
+		{name: "duplicates and whitespace", webhook: Webhook{Name: " ops ", URL: " https://example.com/hook ", Events: []string{"agent_online", " agent_online "}, MaxAttempts: 1}, wantEvents: []string{WebhookEventAgentOnline}},
+		{name: "http when allowed", webhook: Webhook{Name: "ops", URL: "http://localhost:8080/hook", Events: []string{"*"}, MaxAttempts: 1}, allowHTTP: true, wantEvents: []string{webhookEventAll}},
+		{name: "http when not allowed", webhook: Webhook{Name: "ops", URL: "http://localhost:8080/hook", Events: []string{"*"}, MaxAttempts: 1}, wantErr: true},
+		{name: "missing name", webhook: Webhook{URL: "https://example.com/hook", Events: []string{"*"}, MaxAttempts: 1}, wantErr: true},
+		{name: "invalid url", webhook: Webhook{Name: "ops", URL: "example.com", Events: []string{"*"}, MaxAttempts: 1}, wantErr: true},
+		{name: "no events", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", MaxAttempts: 1}, wantErr: true},
+		{name: "unknown event", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", Events: []string{"license_expired"}, MaxAttempts: 1}, wantErr: true},
+		{name: "test event is not subscribable", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", Events: []string{WebhookEventTest}, MaxAttempts: 1}, wantErr: true},
+		{name: "too many attempts", webhook: Webhook{Name: "ops", URL: "https://example.com/hook", Events: []string{"*"}, MaxAttempts: maxWebhookAttempts + 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wh := tt.webhook
+			err := wh.prepare(tt.allowHTTP)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prepare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(wh.Events) != len(tt.wantEvents) {
+				t.Fatalf("events = %v, want %v", wh.Events, tt.wantEvents)
+			}
+			for i := range wh.Events {
+				if wh.Events[i] != tt.wantEvents[i] {
+					t.Errorf("events = %v, want %v", wh.Events, tt.wantEvents)
+				}
+			}
+		})
+	}
+}
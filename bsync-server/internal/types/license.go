@@ -4,10 +4,11 @@ import "time"
 
 // License represents a license in the system
 type License struct {
-	ID         int       `json:"id" db:"id"`
-	LicenseKey string    `json:"license_key" db:"license_key"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID         int        `json:"id" db:"id"`
+	LicenseKey string     `json:"license_key" db:"license_key"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"` // nil if the license does not expire
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // AgentLicense represents the mapping between an agent and a license
@@ -45,7 +46,8 @@ type AgentWithLicense struct {
 
 // CreateLicenseRequest represents the request to create a new license
 type CreateLicenseRequest struct {
-	LicenseKey string `json:"license_key" validate:"required"`
+	LicenseKey string     `json:"license_key" validate:"required"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // RFC 3339, omitted or null if the license does not expire
 }
 
 // CreateAgentLicenseRequest represents the request to map an agent to a license
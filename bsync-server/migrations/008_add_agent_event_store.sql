@@ -0,0 +1,40 @@
+-- Migration: Add Durable Agent Event Store
+-- Date: 2026-10-16
+-- Description: Adds agent_events table backing PostgresEventStore (event_store.type: postgres)
+
+-- ============================================
+-- 1. CREATE agent_events TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS agent_events (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    event_timestamp TIMESTAMPTZ NOT NULL,
+    data JSONB,
+    processed BOOLEAN DEFAULT false,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+COMMENT ON TABLE agent_events IS 'Raw events received from agents (durable replacement for the in-memory event ring buffer)';
+COMMENT ON COLUMN agent_events.event_type IS 'Top-level message type as sent by the agent (event, session_event, ...)';
+COMMENT ON COLUMN agent_events.event_timestamp IS 'Timestamp reported by the agent';
+COMMENT ON COLUMN agent_events.data IS 'Full raw event payload';
+COMMENT ON COLUMN agent_events.processed IS 'Whether the event was persisted to its database tables (file transfer logs, sessions, ...)';
+
+-- ============================================
+-- 2. CREATE INDEXES
+-- ============================================
+-- GetEvents: id > since, optionally filtered by agent
+CREATE INDEX IF NOT EXISTS idx_agent_events_agent_id_id ON agent_events(agent_id, id);
+-- GetEventsByType: newest events of a type, optionally filtered by agent
+CREATE INDEX IF NOT EXISTS idx_agent_events_type_id ON agent_events(event_type, id);
+CREATE INDEX IF NOT EXISTS idx_agent_events_agent_type_id ON agent_events(agent_id, event_type, id);
+-- GetEventsInTimeRange / GetEventStats / retention cleanup
+CREATE INDEX IF NOT EXISTS idx_agent_events_timestamp ON agent_events(event_timestamp);
+CREATE INDEX IF NOT EXISTS idx_agent_events_agent_timestamp ON agent_events(agent_id, event_timestamp);
+CREATE INDEX IF NOT EXISTS idx_agent_events_created_at ON agent_events(created_at);
+
+-- ============================================
+-- 3. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON agent_events TO PUBLIC;
@@ -0,0 +1,22 @@
+-- Migration: Add Cron Schedules and Time Zones for Sync Jobs
+-- Date: 2026-10-16
+-- Description: Adds cron expression and IANA time zone columns used by JobScheduler
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS cron_expression VARCHAR(255),
+ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+COMMENT ON COLUMN sync_jobs.schedule_type IS 'Schedule: continuous, hourly, daily, cron';
+COMMENT ON COLUMN sync_jobs.cron_expression IS 'Standard 5-field cron expression (minute hour day-of-month month day-of-week), used when schedule_type = cron';
+COMMENT ON COLUMN sync_jobs.timezone IS 'IANA time zone used to evaluate the schedule (e.g. Europe/Berlin)';
+
+-- ============================================
+-- 2. CREATE INDEXES
+-- ============================================
+-- Scheduler polls active, non-continuous jobs by next_scheduled_run
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_next_scheduled_run
+    ON sync_jobs(next_scheduled_run)
+    WHERE schedule_type != 'continuous' AND status = 'active';
@@ -0,0 +1,66 @@
+-- Migration: Add Maintenance Windows and Blackout Periods
+-- Date: 2026-10-16
+-- Description: Adds per-job and global windows that restrict when sync jobs may transfer
+
+-- ============================================
+-- 1. CREATE maintenance_windows TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    job_id INTEGER,                                   -- NULL = global window (applies to every job)
+    window_type VARCHAR(20) NOT NULL DEFAULT 'blackout',
+
+    -- Recurring part: days of week and time of day (both optional)
+    days_of_week INTEGER[] NOT NULL DEFAULT '{}',
+    start_time TIME,
+    end_time TIME,
+
+    -- Absolute part: date range (both optional)
+    start_at TIMESTAMPTZ,
+    end_at TIMESTAMPTZ,
+
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT fk_maintenance_windows_job_id
+        FOREIGN KEY (job_id)
+        REFERENCES sync_jobs(id)
+        ON DELETE CASCADE,
+    CONSTRAINT chk_maintenance_windows_type
+        CHECK (window_type IN ('allow', 'blackout')),
+    CONSTRAINT chk_maintenance_windows_time_pair
+        CHECK ((start_time IS NULL) = (end_time IS NULL)),
+    CONSTRAINT chk_maintenance_windows_range
+        CHECK (start_at IS NULL OR end_at IS NULL OR start_at < end_at)
+);
+
+COMMENT ON TABLE maintenance_windows IS 'Time windows that allow (allow) or forbid (blackout) sync transfers for one job or all jobs';
+COMMENT ON COLUMN maintenance_windows.job_id IS 'Job the window applies to, NULL for a global window';
+COMMENT ON COLUMN maintenance_windows.window_type IS 'allow: job may only transfer inside allow windows; blackout: job may not transfer inside the window';
+COMMENT ON COLUMN maintenance_windows.days_of_week IS 'Days the window starts on (0 = Sunday ... 6 = Saturday), empty = every day';
+COMMENT ON COLUMN maintenance_windows.start_time IS 'Daily start time; end_time before start_time wraps past midnight';
+COMMENT ON COLUMN maintenance_windows.start_at IS 'Optional absolute start (e.g. month-end freeze)';
+COMMENT ON COLUMN maintenance_windows.timezone IS 'IANA time zone used to evaluate days_of_week and start/end time';
+
+-- ============================================
+-- 2. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS window_paused BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN sync_jobs.window_paused IS 'True while the job is paused by a maintenance window (status stays active)';
+
+-- ============================================
+-- 3. CREATE INDEXES
+-- ============================================
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_job_id ON maintenance_windows(job_id);
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_enabled ON maintenance_windows(enabled) WHERE enabled = true;
+
+-- ============================================
+-- 4. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON maintenance_windows TO PUBLIC;
@@ -0,0 +1,19 @@
+-- Migration: Add Bandwidth Limits
+-- Date: 2026-10-16
+-- Description: Adds per-job and per-agent send/receive rate limits with optional time-of-day rate profiles
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS bandwidth_limit JSONB;
+
+COMMENT ON COLUMN sync_jobs.bandwidth_limit IS 'Send/receive limit for the job''s device connections: {max_send_kbps, max_recv_kbps, timezone, rate_profile[]} in KiB/s, NULL = unlimited';
+
+-- ============================================
+-- 2. ALTER integrated_agents TABLE
+-- ============================================
+ALTER TABLE integrated_agents
+ADD COLUMN IF NOT EXISTS bandwidth_limit JSONB;
+
+COMMENT ON COLUMN integrated_agents.bandwidth_limit IS 'Agent-wide send/receive limit (same format as sync_jobs.bandwidth_limit), NULL = unlimited';
@@ -0,0 +1,11 @@
+-- Migration: Add File Versioning Policies
+-- Date: 2026-10-16
+-- Description: Adds a versioning policy to sync jobs so destinations archive replaced and deleted files
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS versioning JSONB;
+
+COMMENT ON COLUMN sync_jobs.versioning IS 'Versioning policy applied to destination folders: {type: trashcan|simple|staggered|external, cleanout_days, keep, max_age_days, clean_interval_s, versions_path, command}, NULL = no versioning';
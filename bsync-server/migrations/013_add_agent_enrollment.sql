@@ -0,0 +1,64 @@
+-- Migration: Add Agent Enrollment Tokens and Credentials
+-- Date: 2026-10-16
+-- Description: Agents exchange an admin-issued enrollment token for a long-lived per-agent credential
+
+-- ============================================
+-- 1. CREATE agent_enrollment_tokens TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS agent_enrollment_tokens (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    token_hash VARCHAR(64) NOT NULL UNIQUE,           -- SHA-256 of the token, the token itself is never stored
+    token_prefix VARCHAR(16) NOT NULL,
+    max_uses INTEGER,                                 -- NULL = unlimited
+    use_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_by VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+
+    CONSTRAINT chk_agent_enrollment_tokens_max_uses
+        CHECK (max_uses IS NULL OR max_uses > 0)
+);
+
+COMMENT ON TABLE agent_enrollment_tokens IS 'Admin-generated tokens an agent presents once to obtain its credential';
+COMMENT ON COLUMN agent_enrollment_tokens.token_prefix IS 'First characters of the token, shown to identify it';
+COMMENT ON COLUMN agent_enrollment_tokens.max_uses IS 'Number of agents that may enroll with the token (1 = single use, NULL = unlimited)';
+
+-- ============================================
+-- 2. CREATE agent_credentials TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS agent_credentials (
+    id SERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL UNIQUE,          -- SHA-256 of the credential secret
+    enrollment_token_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    last_remote_addr VARCHAR(255),
+    revoked_at TIMESTAMPTZ,
+    revoked_by VARCHAR(255),
+
+    CONSTRAINT fk_agent_credentials_enrollment_token
+        FOREIGN KEY (enrollment_token_id)
+        REFERENCES agent_enrollment_tokens(id)
+        ON DELETE SET NULL
+);
+
+COMMENT ON TABLE agent_credentials IS 'Per-agent credentials presented on every agent WebSocket connection';
+COMMENT ON COLUMN agent_credentials.revoked_at IS 'Set when an admin revokes the credential; the agent must enroll again';
+
+-- ============================================
+-- 3. CREATE INDEXES
+-- ============================================
+-- At most one active credential per agent
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_credentials_active_agent
+    ON agent_credentials(agent_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_agent_credentials_agent_id ON agent_credentials(agent_id);
+
+-- ============================================
+-- 4. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON agent_enrollment_tokens TO PUBLIC;
+GRANT SELECT ON agent_credentials TO PUBLIC;
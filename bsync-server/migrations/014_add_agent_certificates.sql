@@ -0,0 +1,32 @@
+-- Migration: Add Agent Client Certificates
+-- Date: 2026-10-16
+-- Description: Client certificates issued to agents by the built-in CA, with revocation for mTLS on /ws/agent
+
+-- ============================================
+-- 1. CREATE agent_certificates TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS agent_certificates (
+    id SERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    serial_number VARCHAR(64) NOT NULL UNIQUE,         -- Hex serial of the certificate
+    fingerprint VARCHAR(64) NOT NULL,                  -- SHA-256 of the DER certificate
+    not_before TIMESTAMPTZ NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    revoke_reason VARCHAR(255)
+);
+
+COMMENT ON TABLE agent_certificates IS 'Client certificates issued to agents; revoked rows form the certificate revocation list';
+COMMENT ON COLUMN agent_certificates.revoke_reason IS 'Why the certificate was revoked (superseded, rejected, admin revocation, ...)';
+
+-- ============================================
+-- 2. CREATE INDEXES
+-- ============================================
+CREATE INDEX IF NOT EXISTS idx_agent_certificates_agent_id ON agent_certificates(agent_id);
+CREATE INDEX IF NOT EXISTS idx_agent_certificates_revoked ON agent_certificates(revoked_at) WHERE revoked_at IS NOT NULL;
+
+-- ============================================
+-- 3. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON agent_certificates TO PUBLIC;
@@ -0,0 +1,64 @@
+-- Migration: Add Webhooks
+-- Date: 2026-10-16
+-- Description: Webhook endpoints subscribed to job and agent lifecycle events, with a delivery log used as retry queue
+
+-- ============================================
+-- 1. CREATE webhooks TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,                      -- HMAC-SHA256 signing key, needed in clear to sign deliveries
+    events TEXT[] NOT NULL DEFAULT '{}',               -- Subscribed event types, '*' for all
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    max_attempts INTEGER NOT NULL DEFAULT 6,
+    created_by VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT chk_webhooks_max_attempts CHECK (max_attempts BETWEEN 1 AND 20)
+);
+
+COMMENT ON TABLE webhooks IS 'HTTPS endpoints notified about job and agent lifecycle events';
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, or * for all';
+
+-- ============================================
+-- 2. CREATE webhook_deliveries TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event_id VARCHAR(64) NOT NULL,                     -- Same for all webhooks notified about one event
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',     -- pending, delivered, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 6,
+    next_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+    last_attempt_at TIMESTAMPTZ,
+    response_status INTEGER,
+    response_body TEXT,                                -- Truncated response of the last attempt
+    error TEXT,
+    duration_ms INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+
+    CONSTRAINT fk_webhook_deliveries_webhook_id FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE,
+    CONSTRAINT chk_webhook_deliveries_status CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+COMMENT ON TABLE webhook_deliveries IS 'Delivery log of webhook events; pending rows are retried with exponential backoff';
+
+-- ============================================
+-- 3. CREATE INDEXES
+-- ============================================
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
+
+-- ============================================
+-- 4. GRANT PERMISSIONS
+-- ============================================
+-- webhooks is not granted: it holds signing secrets in clear
+GRANT SELECT ON webhook_deliveries TO PUBLIC;
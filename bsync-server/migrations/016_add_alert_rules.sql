@@ -0,0 +1,76 @@
+-- Migration: Add Email Alert Rules
+-- Date: 2026-10-16
+-- Description: Alert rules evaluated periodically by the server and sent by email, with per-subject state used for cooldown
+
+-- ============================================
+-- 1. CREATE alert_rules TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    rule_type VARCHAR(50) NOT NULL,                    -- agent_offline, job_need_files, destination_errors
+    threshold INTEGER NOT NULL DEFAULT 0,              -- Value must exceed the threshold (unused by agent_offline)
+    duration_seconds INTEGER NOT NULL DEFAULT 0,       -- Condition must hold this long before alerting
+    cooldown_seconds INTEGER NOT NULL DEFAULT 3600,    -- Minimum time between two emails for the same subject
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    subject_template TEXT,                             -- Go text/template, NULL uses the default subject
+    html_template TEXT,                                -- Go html/template, NULL uses the built-in template of the rule type
+    notify_resolved BOOLEAN NOT NULL DEFAULT false,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_by VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT chk_alert_rules_rule_type CHECK (rule_type IN ('agent_offline', 'job_need_files', 'destination_errors')),
+    CONSTRAINT chk_alert_rules_duration CHECK (duration_seconds >= 0),
+    CONSTRAINT chk_alert_rules_cooldown CHECK (cooldown_seconds >= 0)
+);
+
+COMMENT ON TABLE alert_rules IS 'Email alert rules evaluated periodically by the server';
+COMMENT ON COLUMN alert_rules.rule_type IS 'agent_offline: approved agent disconnected; job_need_files: job folder needFiles > threshold; destination_errors: destination error_count > threshold';
+
+-- ============================================
+-- 2. CREATE alert_states TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS alert_states (
+    id SERIAL PRIMARY KEY,
+    rule_id INTEGER NOT NULL,
+    subject_key VARCHAR(255) NOT NULL,                 -- Agent ID, or agent/job pair, the rule matched
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',     -- pending, firing, resolved
+    value DOUBLE PRECISION,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),  -- Start of the current episode
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    notified BOOLEAN NOT NULL DEFAULT false,           -- An email was sent during the current episode
+    last_notified_at TIMESTAMPTZ,                      -- Kept across episodes so a flapping subject respects the cooldown
+    notification_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+
+    CONSTRAINT fk_alert_states_rule_id FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE,
+    CONSTRAINT uq_alert_states_rule_subject UNIQUE (rule_id, subject_key),
+    CONSTRAINT chk_alert_states_status CHECK (status IN ('pending', 'firing', 'resolved'))
+);
+
+COMMENT ON TABLE alert_states IS 'Per rule and subject alert state used for duration tracking, deduplication and cooldown';
+
+-- ============================================
+-- 3. CREATE INDEXES
+-- ============================================
+CREATE INDEX IF NOT EXISTS idx_alert_states_status ON alert_states(status);
+
+-- ============================================
+-- 4. EXAMPLE RULES (disabled until recipients are set)
+-- ============================================
+INSERT INTO alert_rules (name, rule_type, threshold, duration_seconds, cooldown_seconds, enabled, created_by)
+SELECT * FROM (VALUES
+    ('Agent offline for more than 10 minutes', 'agent_offline', 0, 600, 3600, false, 'migration'),
+    ('Job has files to sync for more than 1 hour', 'job_need_files', 0, 3600, 3600, false, 'migration'),
+    ('Destination error count exceeds 5', 'destination_errors', 5, 0, 3600, false, 'migration')
+) AS examples(name, rule_type, threshold, duration_seconds, cooldown_seconds, enabled, created_by)
+WHERE NOT EXISTS (SELECT 1 FROM alert_rules);
+
+-- ============================================
+-- 5. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON alert_rules TO PUBLIC;
+GRANT SELECT ON alert_states TO PUBLIC;
@@ -0,0 +1,39 @@
+-- Migration: Add Agent Health History
+-- Date: 2026-10-16
+-- Description: Time series of the host metrics agents send in their periodic health reports
+
+-- ============================================
+-- 1. CREATE agent_health_samples TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS agent_health_samples (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    cpu_usage DOUBLE PRECISION,                        -- Percent of all CPUs since the previous report
+    cpu_count INTEGER,
+    load_1 DOUBLE PRECISION,                           -- Load averages, NULL on platforms without them
+    load_5 DOUBLE PRECISION,
+    load_15 DOUBLE PRECISION,
+    memory_used_bytes BIGINT,
+    memory_total_bytes BIGINT,
+    disk_used_bytes BIGINT,                            -- Filesystem of the agent data directory
+    disk_total_bytes BIGINT,
+    disk_free_bytes BIGINT,
+    uptime_seconds BIGINT,                             -- Host uptime
+    disks JSONB NOT NULL DEFAULT '[]',                 -- [{folder_id, path, total_bytes, free_bytes, used_bytes, used_percent}]
+
+    CONSTRAINT fk_agent_health_samples_agent_id FOREIGN KEY (agent_id) REFERENCES integrated_agents(agent_id) ON DELETE CASCADE
+);
+
+COMMENT ON TABLE agent_health_samples IS 'Host metrics from agent health reports, one row per report, pruned after health_history.retention';
+
+-- ============================================
+-- 2. CREATE INDEXES
+-- ============================================
+CREATE INDEX IF NOT EXISTS idx_agent_health_samples_agent_recorded ON agent_health_samples(agent_id, recorded_at DESC);
+CREATE INDEX IF NOT EXISTS idx_agent_health_samples_recorded_at ON agent_health_samples(recorded_at);
+
+-- ============================================
+-- 3. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON agent_health_samples TO PUBLIC;
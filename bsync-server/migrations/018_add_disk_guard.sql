@@ -0,0 +1,44 @@
+-- Migration: Add Disk Space Guard
+-- Date: 2026-10-16
+-- Description: Per-job minimum free space on destinations, disk guard state of each destination and the destination_disk_low alert rule type
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS disk_guard JSONB;
+
+COMMENT ON COLUMN sync_jobs.disk_guard IS 'Minimum free space kept on destinations: {min_free_mb, min_free_percent}, the larger applies, both 0 = off, NULL = server defaults (disk_guard in the server config)';
+
+-- ============================================
+-- 2. ALTER sync_job_destinations TABLE
+-- ============================================
+ALTER TABLE sync_job_destinations
+ADD COLUMN IF NOT EXISTS disk_low_since TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS disk_free_bytes BIGINT,
+ADD COLUMN IF NOT EXISTS disk_total_bytes BIGINT,
+ADD COLUMN IF NOT EXISTS disk_min_free_bytes BIGINT,
+ADD COLUMN IF NOT EXISTS disk_checked_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN sync_job_destinations.disk_low_since IS 'Set while the destination agent keeps the job folder paused for low disk space';
+COMMENT ON COLUMN sync_job_destinations.disk_min_free_bytes IS 'Disk guard threshold in bytes last reported by the destination agent';
+
+CREATE INDEX IF NOT EXISTS idx_sync_job_destinations_disk_low ON sync_job_destinations(job_id) WHERE disk_low_since IS NOT NULL;
+
+-- ============================================
+-- 3. ALTER alert_rules RULE TYPES
+-- ============================================
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS chk_alert_rules_rule_type;
+ALTER TABLE alert_rules ADD CONSTRAINT chk_alert_rules_rule_type
+    CHECK (rule_type IN ('agent_offline', 'job_need_files', 'destination_errors', 'destination_disk_low'));
+
+COMMENT ON COLUMN alert_rules.rule_type IS 'agent_offline: approved agent disconnected; job_need_files: job folder needFiles > threshold; destination_errors: destination error_count > threshold; destination_disk_low: destination paused for low disk space';
+
+INSERT INTO alert_rules (name, rule_type, threshold, duration_seconds, cooldown_seconds, enabled, created_by)
+SELECT 'Destination paused for low disk space', 'destination_disk_low', 0, 0, 3600, false, 'migration'
+WHERE NOT EXISTS (SELECT 1 FROM alert_rules WHERE rule_type = 'destination_disk_low');
+
+-- ============================================
+-- 4. WEBHOOK EVENT TYPES
+-- ============================================
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, disk_space_low, disk_space_recovered, or * for all';
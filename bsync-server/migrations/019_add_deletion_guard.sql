@@ -0,0 +1,52 @@
+-- Migration: Add Large Deletion Guard
+-- Date: 2026-10-16
+-- Description: Per-job deletion thresholds, holds waiting for an administrator to confirm a large deletion and quarantine retention of destinations
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS deletion_guard JSONB;
+
+COMMENT ON COLUMN sync_jobs.deletion_guard IS 'Large deletion guard: {max_files, max_percent, quarantine_days}; the job pauses when a source scan deletes more files, destinations keep deleted files in .stversions for quarantine_days (0 = forever) unless the job has its own versioning, NULL = off';
+
+-- ============================================
+-- 2. CREATE deletion_guard_holds TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS deletion_guard_holds (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL,
+    agent_id VARCHAR(255) NOT NULL,                   -- Source agent that detected the deletions
+    deleted_files BIGINT NOT NULL DEFAULT 0,
+    total_files BIGINT NOT NULL DEFAULT 0,            -- Files in the source folder before the deletions
+    sample_paths JSONB NOT NULL DEFAULT '[]',         -- First deleted paths
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmed_at TIMESTAMPTZ,
+    confirmed_by VARCHAR(255),
+
+    CONSTRAINT fk_deletion_guard_holds_job_id
+        FOREIGN KEY (job_id)
+        REFERENCES sync_jobs(id)
+        ON DELETE CASCADE,
+    CONSTRAINT chk_deletion_guard_holds_status
+        CHECK (status IN ('pending', 'confirmed'))
+);
+
+COMMENT ON TABLE deletion_guard_holds IS 'Jobs paused by the deletion guard; pending until an administrator confirms the deletions';
+
+-- ============================================
+-- 3. CREATE INDEXES
+-- ============================================
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deletion_guard_holds_pending ON deletion_guard_holds(job_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_deletion_guard_holds_job_detected ON deletion_guard_holds(job_id, detected_at DESC);
+
+-- ============================================
+-- 4. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON deletion_guard_holds TO PUBLIC;
+
+-- ============================================
+-- 5. WEBHOOK EVENT TYPES
+-- ============================================
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, disk_space_low, disk_space_recovered, deletion_guard_triggered, or * for all';
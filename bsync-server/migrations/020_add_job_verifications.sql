@@ -0,0 +1,83 @@
+-- Migration: Add Job Verifications
+-- Date: 2026-10-16
+-- Description: Content integrity verification of job destinations against SHA-256 manifests of the source, on demand or on a per-job schedule
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS verify_schedule VARCHAR(100),
+ADD COLUMN IF NOT EXISTS next_verification_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN sync_jobs.verify_schedule IS 'Cron expression or descriptor (@daily, @weekly, ...) of scheduled verifications in the job time zone, NULL = on demand only';
+COMMENT ON COLUMN sync_jobs.next_verification_at IS 'Next scheduled verification, maintained by the job scheduler';
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_next_verification ON sync_jobs(next_verification_at) WHERE verify_schedule IS NOT NULL;
+
+-- ============================================
+-- 2. CREATE job_verifications TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS job_verifications (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    trigger VARCHAR(20) NOT NULL DEFAULT 'manual',
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    consistent BOOLEAN,
+    source_agent_id VARCHAR(255) NOT NULL,
+    source_files BIGINT NOT NULL DEFAULT 0,
+    source_bytes BIGINT NOT NULL DEFAULT 0,
+    destinations JSONB NOT NULL DEFAULT '[]',
+    mismatched_files BIGINT NOT NULL DEFAULT 0,
+    missing_files BIGINT NOT NULL DEFAULT 0,
+    extra_files BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    requested_by VARCHAR(255),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    CONSTRAINT chk_job_verifications_trigger CHECK (trigger IN ('manual', 'scheduled')),
+    CONSTRAINT chk_job_verifications_status CHECK (status IN ('running', 'completed', 'failed'))
+);
+
+COMMENT ON TABLE job_verifications IS 'Verification reports comparing the hash manifests of the source and destination folders of a job';
+COMMENT ON COLUMN job_verifications.consistent IS 'true when every destination matched the source, NULL while running or when a manifest could not be built';
+COMMENT ON COLUMN job_verifications.destinations IS 'Per destination summary: [{agent_id, status, error, files, bytes, mismatched, missing, extra, truncated}]';
+
+CREATE INDEX IF NOT EXISTS idx_job_verifications_job ON job_verifications(job_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_job_verifications_started ON job_verifications(started_at);
+
+-- One verification per job at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_verifications_running ON job_verifications(job_id) WHERE status = 'running';
+
+-- ============================================
+-- 3. CREATE job_verification_files TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS job_verification_files (
+    id BIGSERIAL PRIMARY KEY,
+    verification_id INTEGER NOT NULL REFERENCES job_verifications(id) ON DELETE CASCADE,
+    destination_agent_id VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    problem VARCHAR(20) NOT NULL,
+    source_size BIGINT,
+    source_mtime TIMESTAMPTZ,
+    source_sha256 VARCHAR(64),
+    dest_size BIGINT,
+    dest_mtime TIMESTAMPTZ,
+    dest_sha256 VARCHAR(64),
+    CONSTRAINT chk_job_verification_files_problem CHECK (problem IN ('mismatched', 'missing', 'extra'))
+);
+
+COMMENT ON TABLE job_verification_files IS 'Files of a verification that differ between the source and a destination';
+COMMENT ON COLUMN job_verification_files.problem IS 'mismatched: size or SHA-256 differs; missing: on the source only; extra: on the destination only';
+
+CREATE INDEX IF NOT EXISTS idx_job_verification_files_verification ON job_verification_files(verification_id, destination_agent_id, problem);
+
+-- ============================================
+-- 4. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON job_verifications TO PUBLIC;
+GRANT SELECT ON job_verification_files TO PUBLIC;
+
+-- ============================================
+-- 5. WEBHOOK EVENT TYPES
+-- ============================================
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, disk_space_low, disk_space_recovered, deletion_guard_triggered, verification_completed, or * for all';
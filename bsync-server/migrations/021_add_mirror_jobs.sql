@@ -0,0 +1,36 @@
+-- Migration: Add One-Shot Mirror Jobs
+-- Date: 2026-10-16
+-- Description: Run-once jobs that sync until every destination has all files, record a final session and remove their folders from all agents
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS job_type VARCHAR(20) NOT NULL DEFAULT 'sync',
+ADD COLUMN IF NOT EXISTS mirror_status VARCHAR(20),
+ADD COLUMN IF NOT EXISTS mirror_started_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS mirror_completed_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS mirror_error TEXT,
+ADD COLUMN IF NOT EXISTS mirror_progress JSONB,
+ADD COLUMN IF NOT EXISTS mirror_teardown_pending TEXT[];
+
+ALTER TABLE sync_jobs DROP CONSTRAINT IF EXISTS chk_sync_jobs_job_type;
+ALTER TABLE sync_jobs ADD CONSTRAINT chk_sync_jobs_job_type
+    CHECK (job_type IN ('sync', 'mirror'));
+
+ALTER TABLE sync_jobs DROP CONSTRAINT IF EXISTS chk_sync_jobs_mirror_status;
+ALTER TABLE sync_jobs ADD CONSTRAINT chk_sync_jobs_mirror_status
+    CHECK (mirror_status IS NULL OR mirror_status IN ('pending', 'syncing', 'completed', 'failed'));
+
+COMMENT ON COLUMN sync_jobs.job_type IS 'sync: long-lived folder; mirror: one-shot copy, folders are removed from the agents once every destination has all files';
+COMMENT ON COLUMN sync_jobs.mirror_status IS 'Mirror jobs only: pending (deployed, source scanning), syncing, completed or failed';
+COMMENT ON COLUMN sync_jobs.mirror_progress IS 'Last folder state observed on each destination: [{agent_id, state, need_files, need_bytes, global_files, local_files, ...}]';
+COMMENT ON COLUMN sync_jobs.mirror_teardown_pending IS 'Agents whose job folder still has to be removed after the mirror finished, NULL once all are removed';
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_mirror_open ON sync_jobs(id)
+    WHERE job_type = 'mirror' AND (mirror_status IN ('pending', 'syncing') OR mirror_teardown_pending IS NOT NULL);
+
+-- ============================================
+-- 2. WEBHOOK EVENT TYPES
+-- ============================================
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, disk_space_low, disk_space_recovered, deletion_guard_triggered, verification_completed, mirror_completed, or * for all';
@@ -0,0 +1,29 @@
+-- Migration: Add Job Dependencies
+-- Date: 2026-10-16
+-- Description: Jobs triggered when a job they depend on completes a sync session successfully
+
+-- ============================================
+-- 1. CREATE job_dependencies TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS job_dependencies (
+    job_id INTEGER NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    depends_on_job_id INTEGER NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_triggered_at TIMESTAMPTZ,
+    last_trigger_session_id VARCHAR(255),
+    last_trigger_result VARCHAR(20),
+    last_trigger_error TEXT,
+    PRIMARY KEY (job_id, depends_on_job_id),
+    CONSTRAINT chk_job_dependencies_self CHECK (job_id <> depends_on_job_id),
+    CONSTRAINT chk_job_dependencies_result CHECK (last_trigger_result IS NULL OR last_trigger_result IN ('triggered', 'skipped', 'failed'))
+);
+
+COMMENT ON TABLE job_dependencies IS 'job_id is triggered (rescan of its source) when a session of depends_on_job_id completes successfully on one of its destinations';
+COMMENT ON COLUMN job_dependencies.last_trigger_result IS 'triggered: rescan sent; skipped: job paused or not active; failed: source agent not reachable';
+
+CREATE INDEX IF NOT EXISTS idx_job_dependencies_depends_on ON job_dependencies(depends_on_job_id);
+
+-- ============================================
+-- 2. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON job_dependencies TO PUBLIC;
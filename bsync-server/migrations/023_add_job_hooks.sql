@@ -0,0 +1,19 @@
+-- Migration: Add Job Hooks
+-- Date: 2026-10-16
+-- Description: Commands run by the agents around a sync (pre-scan on the source, post-session on the destinations) and their results per session
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS hooks JSONB;
+
+COMMENT ON COLUMN sync_jobs.hooks IS 'Hook commands: {"pre_scan": {command, timeout_seconds, env, continue_on_error}, "post_session": {command, timeout_seconds, env}}, NULL = no hooks';
+
+-- ============================================
+-- 2. ALTER sync_sessions TABLE
+-- ============================================
+ALTER TABLE sync_sessions
+ADD COLUMN IF NOT EXISTS hook_results JSONB;
+
+COMMENT ON COLUMN sync_sessions.hook_results IS 'Hooks run for the session: [{hook, agent_id, command, exit_code, output, truncated, timed_out, error, scan_skipped, started_at, duration_ms}]';
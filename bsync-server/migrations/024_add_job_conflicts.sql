@@ -0,0 +1,44 @@
+-- Migration: Add Job Conflicts
+-- Date: 2026-10-16
+-- Description: Conflict copies (.sync-conflict-*) found by the agents in job folders and how they were resolved
+
+-- ============================================
+-- 1. CREATE job_conflicts TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS job_conflicts (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES sync_jobs(id) ON DELETE CASCADE,
+    agent_id VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    original_path TEXT NOT NULL,
+    modified_by VARCHAR(20),
+    conflict_time TIMESTAMPTZ,
+    size BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    resolution VARCHAR(20),
+    resolved_by VARCHAR(255),
+    resolved_at TIMESTAMPTZ,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_job_conflicts_path UNIQUE (job_id, path),
+    CONSTRAINT chk_job_conflicts_status CHECK (status IN ('open', 'resolved', 'removed')),
+    CONSTRAINT chk_job_conflicts_resolution CHECK (resolution IS NULL OR resolution IN ('local', 'remote', 'both'))
+);
+
+COMMENT ON TABLE job_conflicts IS 'Conflict copies in job folders; the copy holds the version of agent_id, the file at original_path the version received from another device';
+COMMENT ON COLUMN job_conflicts.path IS 'Conflict copy relative to the job folder, slash separated';
+COMMENT ON COLUMN job_conflicts.modified_by IS 'Short device ID from the conflict copy name';
+COMMENT ON COLUMN job_conflicts.status IS 'open: copy present; resolved: resolved through the API; removed: copy deleted outside the API';
+COMMENT ON COLUMN job_conflicts.resolution IS 'local: copy replaced the file; remote: copy deleted; both: copy renamed to a regular file';
+
+CREATE INDEX IF NOT EXISTS idx_job_conflicts_job_status ON job_conflicts(job_id, status);
+
+-- ============================================
+-- 2. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON job_conflicts TO PUBLIC;
+
+-- ============================================
+-- 3. WEBHOOK EVENT TYPES
+-- ============================================
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, disk_space_low, disk_space_recovered, deletion_guard_triggered, verification_completed, mirror_completed, conflict_detected, or * for all';
@@ -0,0 +1,16 @@
+-- Migration: Add Conflict Policy
+-- Date: 2026-10-16
+-- Description: Per-job policy the agents apply automatically to conflict copies of sendreceive jobs
+
+-- ============================================
+-- 1. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS conflict_policy JSONB;
+
+COMMENT ON COLUMN sync_jobs.conflict_policy IS 'Conflict policy: {"strategy": "source_wins|newest|largest|keep_both", "max_conflicts": N (copies kept per file, 0 = unlimited)}, NULL = conflicts resolved manually';
+
+-- ============================================
+-- 2. ALTER job_conflicts TABLE
+-- ============================================
+COMMENT ON COLUMN job_conflicts.resolved_by IS 'User who resolved the conflict through the API, or policy:<strategy> for automatic decisions (also logged in file_transfer_logs with action conflict_keep_<resolution>)';
@@ -0,0 +1,41 @@
+-- Migration: Add Job Templates
+-- Date: 2026-10-16
+-- Description: Reusable sync job settings; jobs are created from a template by choosing the agents only
+
+-- ============================================
+-- 1. CREATE job_templates TABLE
+-- ============================================
+CREATE TABLE IF NOT EXISTS job_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    job_name VARCHAR(255) NOT NULL,
+    source_path TEXT NOT NULL,
+    destination_path TEXT NOT NULL,
+    settings JSONB NOT NULL DEFAULT '{}',
+    created_by VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_job_templates_name UNIQUE (name)
+);
+
+COMMENT ON TABLE job_templates IS 'Settings shared by sync jobs; job_name and the paths may contain placeholders expanded per agent when a job is created';
+COMMENT ON COLUMN job_templates.job_name IS 'Name of the created jobs, placeholders: {template}, {hostname}/{agent_id} of the source agent';
+COMMENT ON COLUMN job_templates.source_path IS 'Source path, placeholders: {hostname}, {agent_id} of the source agent';
+COMMENT ON COLUMN job_templates.destination_path IS 'Destination path, placeholders: {hostname}, {agent_id} of each destination agent, {source_hostname}, {source_agent_id}';
+COMMENT ON COLUMN job_templates.settings IS 'Job settings as sent to POST /api/v1/sync-jobs: sync_type, rescan_interval, ignore_patterns, schedule_type, cron_expression, timezone, bandwidth_limit, versioning, disk_guard, deletion_guard, verify_schedule, hooks, conflict_policy';
+
+-- ============================================
+-- 2. ALTER sync_jobs TABLE
+-- ============================================
+ALTER TABLE sync_jobs
+ADD COLUMN IF NOT EXISTS template_id INTEGER REFERENCES job_templates(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN sync_jobs.template_id IS 'Template the job was created from, template changes can be propagated to it; NULL = standalone job';
+
+CREATE INDEX IF NOT EXISTS idx_sync_jobs_template ON sync_jobs(template_id) WHERE template_id IS NOT NULL;
+
+-- ============================================
+-- 3. GRANT PERMISSIONS
+-- ============================================
+GRANT SELECT ON job_templates TO PUBLIC;
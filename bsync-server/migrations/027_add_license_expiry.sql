@@ -0,0 +1,21 @@
+-- Migration: Add License Expiry
+-- Date: 2026-10-16
+-- Description: Optional expiry date of a license; the server sends a license_expiring webhook event
+-- once per license when it enters the warning period (webhooks.license_expiry_warning)
+
+-- ============================================
+-- 1. ALTER licenses TABLE
+-- ============================================
+ALTER TABLE licenses ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE licenses ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN licenses.expires_at IS 'Expiry date of the license, NULL for licenses that do not expire';
+COMMENT ON COLUMN licenses.expiry_notified_at IS 'When license_expiring was sent for the current expires_at, reset when the expiry date changes';
+
+CREATE INDEX IF NOT EXISTS idx_licenses_expiry_pending ON licenses(expires_at)
+    WHERE expires_at IS NOT NULL AND expiry_notified_at IS NULL;
+
+-- ============================================
+-- 2. WEBHOOK EVENT TYPES
+-- ============================================
+COMMENT ON COLUMN webhooks.events IS 'Event types: job_failed, session_completed, agent_online, agent_offline, agent_pending_approval, license_expiring, disk_space_low, disk_space_recovered, deletion_guard_triggered, verification_completed, mirror_completed, conflict_detected, or * for all';
@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// AlertEmailData is passed to alert subject and HTML templates
+type AlertEmailData struct {
+	RuleName     string
+	RuleType     string
+	Subject      string // what the alert is about, e.g. agent hostname or job name
+	Value        float64
+	Threshold    int
+	Since        time.Time // start of the condition
+	Duration     string    // how long the condition has held
+	Details      map[string]interface{}
+	Resolved     bool
+	DashboardURL string
+	Time         time.Time
+}
+
+// DefaultAlertSubjectTemplate is used by alert rules without a subject template
+const DefaultAlertSubjectTemplate = `[BSync {{if .Resolved}}Resolved{{else}}Alert{{end}}] {{.RuleName}}: {{.Subject}}`
+
+const alertEmailHead = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.RuleName}}</title>
+    <style>
+        body {
+            margin: 0;
+            padding: 0;
+            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+            background-color: #f4f7fa;
+        }
+        .email-container {
+            max-width: 600px;
+            margin: 40px auto;
+            background-color: #ffffff;
+            border-radius: 12px;
+            overflow: hidden;
+            box-shadow: 0 4px 12px rgba(0, 0, 0, 0.08);
+        }
+        .email-header {
+            background: linear-gradient(135deg, #dc2626 0%, #991b1b 100%);
+            padding: 40px 30px;
+            text-align: center;
+        }
+        .email-header.resolved {
+            background: linear-gradient(135deg, #16a34a 0%, #166534 100%);
+        }
+        .email-header h1 {
+            color: #ffffff;
+            margin: 0;
+            font-size: 26px;
+            font-weight: 600;
+        }
+        .email-header p {
+            color: #fee2e2;
+            margin: 10px 0 0 0;
+            font-size: 16px;
+        }
+        .email-body {
+            padding: 40px 30px;
+        }
+        .message {
+            font-size: 15px;
+            color: #666666;
+            line-height: 1.6;
+            margin-bottom: 30px;
+        }
+        .details-box {
+            background-color: #f8f9fc;
+            border-left: 4px solid #dc2626;
+            border-radius: 8px;
+            padding: 25px;
+            margin: 30px 0;
+        }
+        .details-box h3 {
+            margin: 0 0 15px 0;
+            color: #333333;
+            font-size: 16px;
+            font-weight: 600;
+        }
+        .detail-item {
+            display: flex;
+            justify-content: space-between;
+            padding: 10px 0;
+            border-bottom: 1px solid #e2e8f0;
+            font-size: 14px;
+        }
+        .detail-item:last-child {
+            border-bottom: none;
+        }
+        .detail-label {
+            font-weight: 500;
+            color: #555555;
+        }
+        .detail-value {
+            font-family: 'Courier New', monospace;
+            color: #333333;
+            word-break: break-all;
+        }
+        .cta-button {
+            display: inline-block;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            color: #ffffff;
+            text-decoration: none;
+            padding: 14px 32px;
+            border-radius: 8px;
+            font-weight: 600;
+            font-size: 16px;
+        }
+        .email-footer {
+            background-color: #f8f9fc;
+            padding: 30px;
+            text-align: center;
+            border-top: 1px solid #e2e8f0;
+        }
+        .email-footer p {
+            margin: 5px 0;
+            font-size: 13px;
+            color: #888888;
+        }
+    </style>
+</head>
+<body>
+    <div class="email-container">
+`
+
+const alertEmailFoot = `
+            <div class="details-box">
+                <h3>📋 Details</h3>
+                <div class="detail-item">
+                    <span class="detail-label">Rule:</span>
+                    <span class="detail-value">{{.RuleName}}</span>
+                </div>
+                <div class="detail-item">
+                    <span class="detail-label">Since:</span>
+                    <span class="detail-value">{{.Since.Format "2006-01-02 15:04:05 MST"}} ({{.Duration}})</span>
+                </div>
+                {{range $key, $value := .Details}}
+                <div class="detail-item">
+                    <span class="detail-label">{{$key}}:</span>
+                    <span class="detail-value">{{$value}}</span>
+                </div>
+                {{end}}
+            </div>
+
+            {{if .DashboardURL}}
+            <div style="text-align: center;">
+                <a href="{{.DashboardURL}}" class="cta-button">Open BSync</a>
+            </div>
+            {{end}}
+        </div>
+
+        <div class="email-footer">
+            <p><strong>BSync - Business Synchronization Platform</strong></p>
+            <p>Sent by alert rule "{{.RuleName}}" at {{.Time.Format "2006-01-02 15:04:05 MST"}}.</p>
+            <p>This is an automated message, please do not reply to this email.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+// AgentOfflineAlertTemplate is the built-in template of agent_offline rules
+const AgentOfflineAlertTemplate = alertEmailHead + `
+        <div class="email-header{{if .Resolved}} resolved{{end}}">
+            <h1>{{if .Resolved}}✅ Agent Back Online{{else}}🔌 Agent Offline{{end}}</h1>
+            <p>{{.Subject}}</p>
+        </div>
+
+        <div class="email-body">
+            <p class="message">
+                {{if .Resolved}}
+                Agent <strong>{{.Subject}}</strong> is connected again.
+                {{else}}
+                Agent <strong>{{.Subject}}</strong> has not been connected to the server for {{.Duration}}.
+                Jobs using this agent are not synchronizing until it reconnects.
+                {{end}}
+            </p>
+` + alertEmailFoot
+
+// JobNeedFilesAlertTemplate is the built-in template of job_need_files rules
+const JobNeedFilesAlertTemplate = alertEmailHead + `
+        <div class="email-header{{if .Resolved}} resolved{{end}}">
+            <h1>{{if .Resolved}}✅ Job In Sync{{else}}⏳ Job Not In Sync{{end}}</h1>
+            <p>{{.Subject}}</p>
+        </div>
+
+        <div class="email-body">
+            <p class="message">
+                {{if .Resolved}}
+                Job <strong>{{.Subject}}</strong> has caught up.
+                {{else}}
+                Job <strong>{{.Subject}}</strong> still has <strong>{{printf "%.0f" .Value}}</strong> file(s) to synchronize
+                after {{.Duration}} (threshold: {{.Threshold}}).
+                Check the destination agent, free disk space and network connectivity.
+                {{end}}
+            </p>
+` + alertEmailFoot
+
+// DestinationErrorsAlertTemplate is the built-in template of destination_errors rules
+const DestinationErrorsAlertTemplate = alertEmailHead + `
+        <div class="email-header{{if .Resolved}} resolved{{end}}">
+            <h1>{{if .Resolved}}✅ Destination Recovered{{else}}⚠️ Destination Errors{{end}}</h1>
+            <p>{{.Subject}}</p>
+        </div>
+
+        <div class="email-body">
+            <p class="message">
+                {{if .Resolved}}
+                Destination <strong>{{.Subject}}</strong> is no longer above the error threshold.
+                {{else}}
+                Destination <strong>{{.Subject}}</strong> reported <strong>{{printf "%.0f" .Value}}</strong> error(s),
+                more than the threshold of {{.Threshold}}.
+                {{end}}
+            </p>
+` + alertEmailFoot
+
+// DestinationDiskLowAlertTemplate is the built-in template of destination_disk_low rules
+const DestinationDiskLowAlertTemplate = alertEmailHead + `
+        <div class="email-header{{if .Resolved}} resolved{{end}}">
+            <h1>{{if .Resolved}}✅ Disk Space Recovered{{else}}💾 Destination Disk Almost Full{{end}}</h1>
+            <p>{{.Subject}}</p>
+        </div>
+
+        <div class="email-body">
+            <p class="message">
+                {{if .Resolved}}
+                Destination <strong>{{.Subject}}</strong> has enough free disk space again and synchronization resumed.
+                {{else}}
+                Destination <strong>{{.Subject}}</strong> has been paused for {{.Duration}} because only
+                <strong>{{printf "%.0f" .Value}} MB</strong> are free, below the minimum free space of the job.
+                Free up space on the destination or raise its capacity, the job resumes automatically.
+                {{end}}
+            </p>
+` + alertEmailFoot
+
+// DefaultAlertEmailTemplate returns the built-in HTML template of an alert rule type
+func DefaultAlertEmailTemplate(ruleType string) string {
+	switch ruleType {
+	case "agent_offline":
+		return AgentOfflineAlertTemplate
+	case "job_need_files":
+		return JobNeedFilesAlertTemplate
+	case "destination_errors":
+		return DestinationErrorsAlertTemplate
+	case "destination_disk_low":
+		return DestinationDiskLowAlertTemplate
+	}
+	return ""
+}
+
+// ParseAlertTemplates checks that alert subject and HTML templates parse
+func ParseAlertTemplates(subjectTemplate, htmlTemplate string) error {
+	if _, err := texttemplate.New("subject").Parse(subjectTemplate); err != nil {
+		return fmt.Errorf("invalid subject template: %v", err)
+	}
+	if _, err := htmltemplate.New("body").Parse(htmlTemplate); err != nil {
+		return fmt.Errorf("invalid HTML template: %v", err)
+	}
+	return nil
+}
+
+// RenderAlertEmail renders the subject (text/template) and body (html/template) of an alert email
+func RenderAlertEmail(subjectTemplate, htmlTemplate string, data AlertEmailData) (string, string, error) {
+	subjectTmpl, err := texttemplate.New("subject").Parse(subjectTemplate)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid subject template: %v", err)
+	}
+	bodyTmpl, err := htmltemplate.New("body").Parse(htmlTemplate)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid HTML template: %v", err)
+	}
+
+	var subject, body bytes.Buffer
+	if err := subjectTmpl.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render subject: %v", err)
+	}
+	if err := bodyTmpl.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render HTML: %v", err)
+	}
+
+	// The subject is written to a mail header, keep it on one line
+	return strings.Join(strings.Fields(subject.String()), " "), body.String(), nil
+}
+
+// SendAlertEmail renders and sends an alert email
+func SendAlertEmail(to []string, subjectTemplate, htmlTemplate string, data AlertEmailData) error {
+	subject, htmlBody, err := RenderAlertEmail(subjectTemplate, htmlTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	return SendHTMLEmail(to, subject, htmlBody)
+}